@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -17,6 +18,10 @@ type Config struct {
 	Port  int  `json:"port"`
 	Debug bool `json:"debug"`
 
+	// 日志级别：LogLevel 为所有子系统的默认级别，LogLevels 按子系统覆盖（如 providers=debug,access=warn）
+	LogLevel  string `json:"log_level"`
+	LogLevels string `json:"log_levels"`
+
 	// API配置
 	APIKey             string `json:"api_key"`
 	Models             string `json:"models"`
@@ -28,6 +33,10 @@ type Config struct {
 	RateLimitRPS   int `json:"rate_limit_rps"`
 	RateLimitBurst int `json:"rate_limit_burst"`
 
+	// 受信任的反向代理（逗号分隔的 IP 或 CIDR），只有来自这些地址的 X-Forwarded-For 才用于确定客户端 IP；
+	// 未设置时信任所有来源（gin 默认行为），密钥 IP 白名单应配合此项使用
+	TrustedProxies string `json:"trusted_proxies"`
+
 	// SMTP邮件配置
 	SMTPHost     string `json:"smtp_host"`
 	SMTPPort     int    `json:"smtp_port"`
@@ -47,6 +56,9 @@ type Config struct {
 	DBMaxIdleConns    int    `json:"db_max_idle_conns"`   // 最大空闲连接数
 	DBConnMaxLifetime string `json:"db_conn_max_lifetime"` // 连接最大生命周期
 	DBConnMaxIdleTime string `json:"db_conn_max_idle_time"` // 空闲连接最大生命周期
+	DBHealthCheckInterval int    `json:"db_health_check_interval"` // 数据库健康检查间隔（秒）
+	DBDegradedWindow      int    `json:"db_degraded_window"`       // 数据库不可用时缓存凭据继续服务的时长（秒）
+	BillingJournalPath    string `json:"billing_journal_path"`     // 数据库不可用期间计费事件的磁盘日志
 
 	// Cursor相关配置
 	ScriptURL string `json:"script_url"`
@@ -60,6 +72,95 @@ type Config struct {
 	
 	// AI Provider configurations
 	Providers ProviderConfig `json:"providers"`
+
+	// QoS scheduling configuration
+	QoS QoSConfig `json:"qos"`
+
+	// Latency SLO alerting configuration
+	LatencySLO LatencySLOConfig `json:"latency_slo"`
+
+	// Provider monthly spend cap configuration
+	ProviderSpend ProviderSpendConfig `json:"provider_spend"`
+
+	// Provider health probing and circuit breaker configuration
+	ProviderHealth ProviderHealthConfig `json:"provider_health"`
+
+	// Files API storage configuration
+	Files FilesConfig `json:"files"`
+
+	// Conversation → Cursor session affinity TTL (seconds, 0 disables)
+	SessionAffinityTTL int `json:"session_affinity_ttl"`
+
+	// SSE chunk coalescing defaults (0 flushes every event), overridable per key
+	StreamFlushIntervalMs int `json:"stream_flush_interval_ms"`
+	StreamFlushBytes      int `json:"stream_flush_bytes"`
+
+	// Seconds of idle time before a keep-alive ping is written on SSE responses (0 disables)
+	SSEKeepAliveInterval int `json:"sse_keepalive_interval"`
+
+	// Require API key owners to accept the current terms of service
+	TOSEnforceAPI bool `json:"tos_enforce_api"`
+
+	// Detect the prompt language of API requests for usage records and language-specific rules
+	LanguageDetection bool `json:"language_detection"`
+
+	// Secret for signed share/download/resume tokens (empty: generated and stored in the database)
+	TokenSigningSecret string `json:"-"`
+
+	// Login mode for the web console: "session" (database sessions) or "jwt" (access + refresh tokens)
+	AuthMode string `json:"auth_mode"`
+
+	// HMAC secret for JWT access and refresh tokens (empty: generated and stored in the database)
+	JWTSecret string `json:"-"`
+
+	// Lifetime of JWT access and refresh tokens, in seconds
+	JWTAccessTTL  int `json:"jwt_access_ttl"`
+	JWTRefreshTTL int `json:"jwt_refresh_ttl"`
+
+	// Seconds between purges of expired sessions, verification codes and OAuth states (0 disables)
+	VacuumInterval int `json:"vacuum_interval"`
+
+	// Days a chat conversation must be idle before its messages are compressed (0 disables archiving)
+	ChatArchiveDays int `json:"chat_archive_days"`
+
+	// Name new chat conversations from their first exchange in the background
+	ChatAutoTitle bool `json:"chat_auto_title"`
+
+	// Model that writes conversation titles (empty: the conversation's model)
+	ChatTitleModel string `json:"chat_title_model"`
+
+	// Largest file accepted as a chat message attachment, in bytes
+	ChatAttachmentMaxBytes int64 `json:"chat_attachment_max_bytes"`
+
+	// Characters of text kept from one chat attachment; longer extracted text is truncated
+	ChatAttachmentMaxChars int `json:"chat_attachment_max_chars"`
+
+	// Public URL clients use to reach this gateway, e.g. https://api.example.com (empty: derived from the request)
+	PublicBaseURL string `json:"public_base_url"`
+
+	// Recharge page linked from 402 insufficient balance responses (empty: the dashboard under PublicBaseURL)
+	RechargeURL string `json:"recharge_url"`
+
+	// YAML seed file applied at startup (empty disables); SeedForce overwrites existing entries
+	SeedFile  string `json:"seed_file"`
+	SeedForce bool   `json:"seed_force"`
+
+	// Background workers processing /v1/batches requests (0 disables the Batch API)
+	BatchWorkers     int `json:"batch_workers"`
+	BatchMaxRequests int `json:"batch_max_requests"`
+
+	// Allow user webhooks to target loopback and private network addresses (off: only public hosts)
+	UserWebhookAllowPrivate bool `json:"user_webhook_allow_private"`
+
+	// Model request timeouts in seconds: global default, per-model overrides ("o1*=900,..."),
+	// and the bounds for per-request overrides via X-Request-Timeout (admin settings take precedence)
+	RequestTimeout    int    `json:"request_timeout"`
+	ModelTimeouts     string `json:"model_timeouts"`
+	RequestTimeoutMin int    `json:"request_timeout_min"`
+	RequestTimeoutMax int    `json:"request_timeout_max"`
+
+	// Cache of non-streaming responses to identical requests
+	ResponseCache ResponseCacheConfig `json:"response_cache"`
 }
 
 // FP 指纹配置结构
@@ -92,23 +193,81 @@ type UsageTrackingConfig struct {
 	RetentionDays  int  `json:"retention_days"`   // Number of days to retain usage records
 	CleanupHour    int  `json:"cleanup_hour"`     // Hour of day to run cleanup (0-23, UTC)
 	CleanupMinute  int  `json:"cleanup_minute"`   // Minute of hour to run cleanup (0-59)
+	// Privacy: admin usage views only expose aggregates spanning at least MinGroupSize users
+	AggregateOnly bool `json:"aggregate_only"`
+	MinGroupSize  int  `json:"min_group_size"`
+}
+
+// QoSConfig 请求调度配置结构
+type QoSConfig struct {
+	MaxConcurrent  int    `json:"max_concurrent"`  // Max concurrent /v1 requests, 0 disables the scheduler
+	QueueTimeout   int    `json:"queue_timeout"`   // Max time a request waits for a slot (seconds)
+	ProviderLimits string `json:"provider_limits"` // Per-provider concurrency caps, e.g. "cursor=8,openai=4"
+	ProviderRPM    string `json:"provider_rpm"`    // Per-provider requests per minute, e.g. "cursor=60,openai=500"
+	ProviderTPM    string `json:"provider_tpm"`    // Per-provider tokens per minute, e.g. "openai=90000"
+	RateLimitWait  int    `json:"rate_limit_wait"` // Max time a request waits for RPM/TPM capacity before a 429 (seconds)
+}
+
+// LatencySLOConfig 延迟预算告警配置结构
+type LatencySLOConfig struct {
+	WindowSeconds int    `json:"window_seconds"` // Evaluation window length (seconds)
+	WebhookURL    string `json:"webhook_url"`    // Optional URL that receives alert/resolve notifications
+}
+
+// ProviderSpendConfig 提供商月度消费上限配置结构
+type ProviderSpendConfig struct {
+	CheckInterval int    `json:"check_interval"` // Seconds between spend evaluations
+	WebhookURL    string `json:"webhook_url"`    // Optional URL that receives warning/capped/released notifications
+}
+
+// ProviderHealthConfig 提供商健康探测与熔断配置
+type ProviderHealthConfig struct {
+	ProbeInterval    int `json:"probe_interval"`    // Seconds between health probes, 0 disables probing
+	FailureThreshold int `json:"failure_threshold"` // Consecutive failures that open a provider's circuit
+	Cooldown         int `json:"cooldown"`          // Seconds an open circuit rejects traffic before retrying
+}
+
+// FilesConfig 文件存储配置（/v1/files）
+type FilesConfig struct {
+	Storage     string `json:"storage"`     // "disk" or "s3"
+	Dir         string `json:"dir"`         // Directory for disk storage
+	MaxBytes    int64  `json:"max_bytes"`   // Largest accepted upload
+	S3Endpoint  string `json:"s3_endpoint"` // e.g. https://s3.us-east-1.amazonaws.com or a MinIO URL
+	S3Region    string `json:"s3_region"`   // Signing region
+	S3Bucket    string `json:"s3_bucket"`   // Bucket holding the files
+	S3AccessKey string `json:"-"`
+	S3SecretKey string `json:"-"`
+	S3PathStyle bool   `json:"s3_path_style"` // Address the bucket in the path instead of the host name
+}
+
+// ResponseCacheConfig 相同请求的响应缓存配置（内存，可选 Redis 供多实例共享）
+type ResponseCacheConfig struct {
+	Enabled    bool   `json:"enabled"`     // Serve repeated identical non-streaming requests from the cache
+	TTL        int    `json:"ttl"`         // Seconds a cached response stays valid
+	MaxEntries int    `json:"max_entries"` // In-memory entries kept before evicting the least recently used
+	MaxBytes   int    `json:"max_bytes"`   // Largest response body that is cached
+	RedisURL   string `json:"-"`           // e.g. redis://:password@localhost:6379/0; empty keeps the cache in memory only
 }
 
 // OpenAIConfig OpenAI provider configuration
 type OpenAIConfig struct {
 	APIKey  string `json:"api_key"`
 	BaseURL string `json:"base_url"`
+	Direct  bool   `json:"direct"` // Serve gpt-*/o* models from OpenAI instead of through Cursor
 }
 
 // AnthropicConfig Anthropic provider configuration
 type AnthropicConfig struct {
 	APIKey  string `json:"api_key"`
 	BaseURL string `json:"base_url"`
+	Direct  bool   `json:"direct"` // Serve claude-* models from Anthropic instead of through Cursor
 }
 
 // GoogleConfig Google AI provider configuration
 type GoogleConfig struct {
-	APIKey string `json:"api_key"`
+	APIKey  string `json:"api_key"`
+	BaseURL string `json:"base_url"`
+	Direct  bool   `json:"direct"` // Serve gemini-* models from Google instead of through Cursor
 }
 
 // DeepSeekConfig DeepSeek provider configuration
@@ -117,12 +276,33 @@ type DeepSeekConfig struct {
 	BaseURL string `json:"base_url"`
 }
 
+// OpenRouterConfig OpenRouter provider configuration (free models)
+type OpenRouterConfig struct {
+	APIKey            string `json:"api_key"`
+	BaseURL           string `json:"base_url"`
+	ModelSyncInterval int    `json:"model_sync_interval"` // Seconds between free model list refreshes, 0 disables
+}
+
+// OllamaConfig local Ollama provider configuration (models are exposed as "ollama/<name>")
+type OllamaConfig struct {
+	BaseURL           string `json:"base_url"`            // Ollama server root, e.g. http://localhost:11434; empty disables
+	APIKey            string `json:"api_key"`             // Optional bearer token for an authenticating proxy
+	ModelSyncInterval int    `json:"model_sync_interval"` // Seconds between local model list refreshes, 0 syncs once
+	Billing           bool   `json:"billing"`             // Deduct user balance for local model usage
+}
+
 // ProviderConfig AI provider configurations
 type ProviderConfig struct {
-	OpenAI    OpenAIConfig    `json:"openai"`
-	Anthropic AnthropicConfig `json:"anthropic"`
-	Google    GoogleConfig    `json:"google"`
-	DeepSeek  DeepSeekConfig  `json:"deepseek"`
+	OpenAI     OpenAIConfig     `json:"openai"`
+	Anthropic  AnthropicConfig  `json:"anthropic"`
+	Google     GoogleConfig     `json:"google"`
+	DeepSeek   DeepSeekConfig   `json:"deepseek"`
+	OpenRouter OpenRouterConfig `json:"openrouter"`
+	Ollama     OllamaConfig     `json:"ollama"`
+
+	// Ordered per-model fallback providers for the chat service,
+	// e.g. "gpt-4o=openai,azure,cursor;*=cursor" ("*" applies to models without a chain)
+	FailoverChains string `json:"failover_chains"`
 }
 
 // LoadConfig 加载配置
@@ -136,6 +316,7 @@ func LoadConfig() (*Config, error) {
 		// 设置默认值
 		Port:               getEnvAsInt("PORT", 8002),
 		Debug:              getEnvAsBool("DEBUG", false),
+		LogLevels:          getEnv("LOG_LEVELS", ""),
 		APIKey:             getEnv("API_KEY", "0000"),
 		Models:             getEnv("MODELS", "gpt-5.2,gpt-5,gpt-5.1,gpt-4o,claude-3.5-sonnet"),
 		SystemPromptInject: getEnv("SYSTEM_PROMPT_INJECT", ""),
@@ -143,6 +324,7 @@ func LoadConfig() (*Config, error) {
 		MaxInputLength:     getEnvAsInt("MAX_INPUT_LENGTH", 200000),
 		RateLimitRPS:       getEnvAsInt("RATE_LIMIT_RPS", 10),
 		RateLimitBurst:     getEnvAsInt("RATE_LIMIT_BURST", 20),
+		TrustedProxies:     getEnv("TRUSTED_PROXIES", ""),
 		// SMTP配置（163邮箱）
 		SMTPHost:     getEnv("SMTP_HOST", "smtp.163.com"),
 		SMTPPort:     getEnvAsInt("SMTP_PORT", 465),
@@ -161,6 +343,9 @@ func LoadConfig() (*Config, error) {
 		DBMaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 25),
 		DBConnMaxLifetime: getEnv("DB_CONN_MAX_LIFETIME", "5m"),
 		DBConnMaxIdleTime: getEnv("DB_CONN_MAX_IDLE_TIME", "10m"),
+		DBHealthCheckInterval: getEnvAsInt("DB_HEALTH_CHECK_INTERVAL", 5),
+		DBDegradedWindow:      getEnvAsInt("DB_DEGRADED_WINDOW", 300),
+		BillingJournalPath:    getEnv("BILLING_JOURNAL_PATH", "data/billing_journal.jsonl"),
 		ScriptURL:    getEnv("SCRIPT_URL", "https://cursor.com/_next/static/chunks/pages/_app.js"),
 		FP: FP{
 			UserAgent:               getEnv("USER_AGENT", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36"),
@@ -189,26 +374,124 @@ func LoadConfig() (*Config, error) {
 			RetentionDays:  getEnvAsInt("USAGE_RETENTION_DAYS", 90),
 			CleanupHour:    getEnvAsInt("USAGE_CLEANUP_HOUR", 3),
 			CleanupMinute:  getEnvAsInt("USAGE_CLEANUP_MINUTE", 0),
+			AggregateOnly:  getEnvAsBool("USAGE_AGGREGATE_ONLY", false),
+			MinGroupSize:   getEnvAsInt("USAGE_MIN_GROUP_SIZE", 5),
 		},
 		// AI Provider configurations
 		Providers: ProviderConfig{
 			OpenAI: OpenAIConfig{
 				APIKey:  getEnv("OPENAI_API_KEY", ""),
 				BaseURL: getEnv("OPENAI_API_BASE", "https://api.openai.com/v1"),
+				Direct:  getEnvAsBool("OPENAI_DIRECT", false),
 			},
 			Anthropic: AnthropicConfig{
 				APIKey:  getEnv("ANTHROPIC_API_KEY", ""),
 				BaseURL: getEnv("ANTHROPIC_API_BASE", "https://api.anthropic.com/v1"),
+				Direct:  getEnvAsBool("ANTHROPIC_DIRECT", false),
 			},
 			Google: GoogleConfig{
-				APIKey: getEnv("GOOGLE_AI_API_KEY", ""),
+				APIKey:  getEnv("GOOGLE_AI_API_KEY", ""),
+				BaseURL: getEnv("GOOGLE_AI_API_BASE", "https://generativelanguage.googleapis.com/v1beta"),
+				Direct:  getEnvAsBool("GOOGLE_DIRECT", false),
 			},
 			DeepSeek: DeepSeekConfig{
 				APIKey:  getEnv("DEEPSEEK_API_KEY", ""),
 				BaseURL: getEnv("DEEPSEEK_API_BASE", "https://api.deepseek.com/v1"),
 			},
+			OpenRouter: OpenRouterConfig{
+				APIKey:            getEnv("OPENROUTER_API_KEY", ""),
+				BaseURL:           getEnv("OPENROUTER_API_BASE", "https://openrouter.ai/api/v1"),
+				ModelSyncInterval: getEnvAsInt("OPENROUTER_MODEL_SYNC_INTERVAL", 21600),
+			},
+			Ollama: OllamaConfig{
+				BaseURL:           getEnv("OLLAMA_BASE_URL", ""),
+				APIKey:            getEnv("OLLAMA_API_KEY", ""),
+				ModelSyncInterval: getEnvAsInt("OLLAMA_MODEL_SYNC_INTERVAL", 300),
+				Billing:           getEnvAsBool("OLLAMA_BILLING", false),
+			},
+			FailoverChains: getEnv("PROVIDER_FAILOVER_CHAINS", ""),
+		},
+		// QoS scheduling configuration
+		QoS: QoSConfig{
+			MaxConcurrent:  getEnvAsInt("QOS_MAX_CONCURRENT", 0),
+			QueueTimeout:   getEnvAsInt("QOS_QUEUE_TIMEOUT", 30),
+			ProviderLimits: getEnv("QOS_PROVIDER_LIMITS", ""),
+			ProviderRPM:    getEnv("QOS_PROVIDER_RPM", ""),
+			ProviderTPM:    getEnv("QOS_PROVIDER_TPM", ""),
+			RateLimitWait:  getEnvAsInt("QOS_RATE_LIMIT_WAIT", 5),
 		},
+		// Latency SLO alerting configuration
+		LatencySLO: LatencySLOConfig{
+			WindowSeconds: getEnvAsInt("LATENCY_SLO_WINDOW", 60),
+			WebhookURL:    getEnv("LATENCY_SLO_WEBHOOK_URL", ""),
+		},
+		// Provider monthly spend cap configuration
+		ProviderSpend: ProviderSpendConfig{
+			CheckInterval: getEnvAsInt("PROVIDER_SPEND_CHECK_INTERVAL", 300),
+			WebhookURL:    getEnv("PROVIDER_SPEND_WEBHOOK_URL", ""),
+		},
+		// Provider health probing and circuit breaker configuration
+		ProviderHealth: ProviderHealthConfig{
+			ProbeInterval:    getEnvAsInt("PROVIDER_HEALTH_PROBE_INTERVAL", 60),
+			FailureThreshold: getEnvAsInt("PROVIDER_CIRCUIT_FAILURE_THRESHOLD", 5),
+			Cooldown:         getEnvAsInt("PROVIDER_CIRCUIT_COOLDOWN", 60),
+		},
+		// Files API storage configuration
+		Files: FilesConfig{
+			Storage:     strings.ToLower(getEnv("FILES_STORAGE", "disk")),
+			Dir:         getEnv("FILES_DIR", "data/files"),
+			MaxBytes:    getEnvAsInt64("FILES_MAX_BYTES", 100<<20),
+			S3Endpoint:  strings.TrimRight(getEnv("S3_ENDPOINT", ""), "/"),
+			S3Region:    getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:    getEnv("S3_BUCKET", ""),
+			S3AccessKey: getEnv("S3_ACCESS_KEY_ID", ""),
+			S3SecretKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+			S3PathStyle: getEnvAsBool("S3_PATH_STYLE", false),
+		},
+		SessionAffinityTTL:    getEnvAsInt("SESSION_AFFINITY_TTL", 0),
+		StreamFlushIntervalMs: getEnvAsInt("STREAM_FLUSH_INTERVAL_MS", 0),
+		StreamFlushBytes:      getEnvAsInt("STREAM_FLUSH_BYTES", 0),
+		SSEKeepAliveInterval:  getEnvAsInt("SSE_KEEPALIVE_INTERVAL", 15),
+		TOSEnforceAPI:         getEnvAsBool("TOS_ENFORCE_API", false),
+		LanguageDetection:     getEnvAsBool("LANGUAGE_DETECTION_ENABLED", false),
+		TokenSigningSecret:    getEnv("TOKEN_SIGNING_SECRET", ""),
+		AuthMode:              strings.ToLower(getEnv("AUTH_MODE", "session")),
+		JWTSecret:             getEnv("JWT_SECRET", ""),
+		JWTAccessTTL:          getEnvAsInt("JWT_ACCESS_TTL", 900),
+		JWTRefreshTTL:         getEnvAsInt("JWT_REFRESH_TTL", 604800),
+		VacuumInterval:        getEnvAsInt("VACUUM_INTERVAL", 3600),
+		ChatArchiveDays:       getEnvAsInt("CHAT_ARCHIVE_DAYS", 0),
+		ChatAutoTitle:         getEnvAsBool("CHAT_AUTO_TITLE", true),
+		ChatTitleModel:        getEnv("CHAT_TITLE_MODEL", ""),
+		ChatAttachmentMaxBytes: getEnvAsInt64("CHAT_ATTACHMENT_MAX_BYTES", 10<<20),
+		ChatAttachmentMaxChars: getEnvAsInt("CHAT_ATTACHMENT_MAX_CHARS", 50000),
+		PublicBaseURL:         strings.TrimRight(getEnv("PUBLIC_BASE_URL", ""), "/"),
+		RechargeURL:           getEnv("RECHARGE_URL", ""),
+		SeedFile:              getEnv("SEED_FILE", ""),
+		SeedForce:             getEnvAsBool("SEED_FORCE", false),
+		BatchWorkers:          getEnvAsInt("BATCH_WORKERS", 4),
+		BatchMaxRequests:      getEnvAsInt("BATCH_MAX_REQUESTS", 1000),
+		UserWebhookAllowPrivate: getEnvAsBool("USER_WEBHOOK_ALLOW_PRIVATE", false),
+		RequestTimeout:        getEnvAsInt("REQUEST_TIMEOUT", 300),
+		ModelTimeouts:         getEnv("MODEL_TIMEOUTS", ""),
+		RequestTimeoutMin:     getEnvAsInt("REQUEST_TIMEOUT_MIN", 10),
+		RequestTimeoutMax:     getEnvAsInt("REQUEST_TIMEOUT_MAX", 1800),
+		// Response cache configuration
+		ResponseCache: ResponseCacheConfig{
+			Enabled:    getEnvAsBool("RESPONSE_CACHE_ENABLED", false),
+			TTL:        getEnvAsInt("RESPONSE_CACHE_TTL", 300),
+			MaxEntries: getEnvAsInt("RESPONSE_CACHE_MAX_ENTRIES", 1000),
+			MaxBytes:   getEnvAsInt("RESPONSE_CACHE_MAX_BYTES", 1<<20),
+			RedisURL:   getEnv("RESPONSE_CACHE_REDIS_URL", ""),
+		},
+	}
+
+	// 未设置 LOG_LEVEL 时沿用 DEBUG 开关
+	defaultLogLevel := "info"
+	if config.Debug {
+		defaultLogLevel = "debug"
 	}
+	config.LogLevel = getEnv("LOG_LEVEL", defaultLogLevel)
 
 	// 验证必要的配置
 	if err := config.validate(); err != nil {
@@ -244,12 +527,81 @@ func (c *Config) validate() error {
 		return fmt.Errorf("rate limit burst must be positive")
 	}
 
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+
+	if c.AuthMode != "session" && c.AuthMode != "jwt" {
+		return fmt.Errorf("invalid AUTH_MODE: %q (expected session or jwt)", c.AuthMode)
+	}
+
+	if c.AuthMode == "jwt" && (c.JWTAccessTTL <= 0 || c.JWTRefreshTTL < c.JWTAccessTTL) {
+		return fmt.Errorf("JWT_ACCESS_TTL must be positive and no longer than JWT_REFRESH_TTL")
+	}
+
 	return nil
 }
 
+// runtimeMu 保护运行时可修改的配置（模型列表、自定义模型别名）
+var (
+	runtimeMu          sync.RWMutex
+	customModelAliases = map[string]string{}
+)
+
+// SetModels 运行时替换启用的模型列表
+func (c *Config) SetModels(models []string) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	c.Models = strings.Join(models, ",")
+}
+
+// GetModelAliases 获取自定义模型别名（别名 -> 模型）
+func GetModelAliases() map[string]string {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	aliases := make(map[string]string, len(customModelAliases))
+	for k, v := range customModelAliases {
+		aliases[k] = v
+	}
+	return aliases
+}
+
+// SetModelAliases 替换自定义模型别名，优先于内置映射
+func SetModelAliases(aliases map[string]string) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	customModelAliases = make(map[string]string, len(aliases))
+	for k, v := range aliases {
+		customModelAliases[k] = v
+	}
+}
+
+// customProviderModels 管理员自定义上游提供的模型（模型 -> 优先级最高的上游名称）
+var customProviderModels = map[string]string{}
+
+// SetCustomProviderModels 替换自定义上游的模型路由表，使这些模型通过模型校验
+func SetCustomProviderModels(models map[string]string) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	customProviderModels = make(map[string]string, len(models))
+	for model, provider := range models {
+		customProviderModels[model] = provider
+	}
+}
+
+// CustomProviderForModel 返回服务该模型的自定义上游名称
+func CustomProviderForModel(model string) (string, bool) {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	provider, ok := customProviderModels[model]
+	return provider, ok
+}
+
 // GetModels 获取模型列表
 func (c *Config) GetModels() []string {
+	runtimeMu.RLock()
 	models := strings.Split(c.Models, ",")
+	runtimeMu.RUnlock()
 	result := make([]string, 0, len(models))
 	for _, model := range models {
 		if trimmed := strings.TrimSpace(model); trimmed != "" {
@@ -259,6 +611,17 @@ func (c *Config) GetModels() []string {
 	return result
 }
 
+// GetTrustedProxies 返回受信任的反向代理列表，未配置时返回 nil
+func (c *Config) GetTrustedProxies() []string {
+	var result []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if trimmed := strings.TrimSpace(proxy); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
 // GetAvailableProviders returns list of providers with valid API keys
 func (c *Config) GetAvailableProviders() []string {
 	providers := make([]string, 0, 4)
@@ -275,6 +638,12 @@ func (c *Config) GetAvailableProviders() []string {
 	if c.Providers.DeepSeek.APIKey != "" {
 		providers = append(providers, "deepseek")
 	}
+	if c.Providers.OpenRouter.APIKey != "" {
+		providers = append(providers, "openrouter")
+	}
+	if c.Providers.Ollama.BaseURL != "" {
+		providers = append(providers, "ollama")
+	}
 	
 	// Cursor is always available as it uses the existing system
 	providers = append(providers, "cursor")
@@ -284,6 +653,14 @@ func (c *Config) GetAvailableProviders() []string {
 
 // NormalizeModelName 标准化模型名称，将完整的模型标识符映射到配置中的简短名称
 func (c *Config) NormalizeModelName(model string) string {
+	// 自定义别名优先
+	runtimeMu.RLock()
+	alias, ok := customModelAliases[model]
+	runtimeMu.RUnlock()
+	if ok {
+		return alias
+	}
+
 	// 模型名称映射表：完整标识符 -> 配置中的简短名称
 	modelMappings := map[string]string{
 		// Claude 3.5 Sonnet (旧版本)
@@ -378,7 +755,8 @@ func (c *Config) NormalizeModelName(model string) string {
 	return model
 }
 
-// OpenRouter 免费模型列表
+// OpenRouter 免费模型列表（启动时的内置列表，同步后由 RegisterOpenRouterFreeModels 补充）
+var openRouterFreeModelsMu sync.RWMutex
 var openRouterFreeModels = map[string]bool{
 	// Alibaba
 	"alibaba/tongyi-deepresearch-30b-a3b": true,
@@ -430,13 +808,29 @@ var openRouterFreeModels = map[string]bool{
 	"glm-4.5-air": true,
 }
 
+// OllamaModelPrefix 本地 Ollama 模型的名称前缀
+const OllamaModelPrefix = "ollama/"
+
 // IsOpenRouterFreeModel 检查是否为 OpenRouter 免费模型
 func IsOpenRouterFreeModel(model string) bool {
+	openRouterFreeModelsMu.RLock()
+	defer openRouterFreeModelsMu.RUnlock()
 	return openRouterFreeModels[model]
 }
 
+// RegisterOpenRouterFreeModels 登记从 OpenRouter 同步到的免费模型，使其通过模型校验
+func RegisterOpenRouterFreeModels(models []string) {
+	openRouterFreeModelsMu.Lock()
+	defer openRouterFreeModelsMu.Unlock()
+	for _, model := range models {
+		openRouterFreeModels[model] = true
+	}
+}
+
 // GetOpenRouterFreeModels 获取所有 OpenRouter 免费模型列表
 func GetOpenRouterFreeModels() []string {
+	openRouterFreeModelsMu.RLock()
+	defer openRouterFreeModelsMu.RUnlock()
 	models := make([]string, 0, len(openRouterFreeModels))
 	for model := range openRouterFreeModels {
 		models = append(models, model)
@@ -450,6 +844,16 @@ func (c *Config) IsValidModel(model string) bool {
 	if IsOpenRouterFreeModel(model) {
 		return true
 	}
+
+	// 管理员自定义上游允许的模型
+	if _, ok := CustomProviderForModel(model); ok {
+		return true
+	}
+
+	// 配置了 Ollama 时接受所有 ollama/ 前缀的本地模型（模型是否存在由 Ollama 判断）
+	if c.Providers.Ollama.BaseURL != "" && strings.HasPrefix(model, OllamaModelPrefix) {
+		return true
+	}
 	
 	// 先尝试标准化模型名称
 	normalizedModel := c.NormalizeModelName(model)
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrChangelogEntryNotFound = errors.New("changelog entry not found")
+
+// API 变更日志分类
+const (
+	ChangelogCategoryBehavior    = "behavior"    // 网关行为变化
+	ChangelogCategoryRateLimit   = "rate_limit"  // 限流默认值变化
+	ChangelogCategoryDeprecation = "deprecation" // 模型别名或接口弃用
+	ChangelogCategoryRemoval     = "removal"     // 已移除的模型或接口
+)
+
+// ChangelogEntry 面向 API 调用方的变更日志条目。已发布且在提示期内的条目通过
+// X-CurryAPI-Notice 响应头提示调用方；设置 Models 时只在请求这些模型时提示
+type ChangelogEntry struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Category    string     `json:"category"`
+	Models      []string   `json:"models,omitempty"`       // 受影响的模型，支持 * 通配符；为空表示所有请求
+	EffectiveAt *time.Time `json:"effective_at,omitempty"` // 变更生效时间
+	NoticeFrom  time.Time  `json:"notice_from"`            // 开始在响应头中提示
+	NoticeUntil *time.Time `json:"notice_until,omitempty"` // 停止提示，为空表示一直提示
+	Published   bool       `json:"published"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NoticeActive 条目在 now 时是否应在响应头中提示
+func (e *ChangelogEntry) NoticeActive(now time.Time) bool {
+	return e.Published && !now.Before(e.NoticeFrom) && (e.NoticeUntil == nil || now.Before(*e.NoticeUntil))
+}
+
+const changelogColumns = `id, title, description, category, models, effective_at, notice_from, notice_until, published, created_at, updated_at`
+
+func scanChangelogEntry(row interface{ Scan(...interface{}) error }) (*ChangelogEntry, error) {
+	e := &ChangelogEntry{}
+	var modelsJSON sql.NullString
+	var effectiveAt, noticeUntil sql.NullTime
+	if err := row.Scan(
+		&e.ID,
+		&e.Title,
+		&e.Description,
+		&e.Category,
+		&modelsJSON,
+		&effectiveAt,
+		&e.NoticeFrom,
+		&noticeUntil,
+		&e.Published,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if modelsJSON.Valid && modelsJSON.String != "" {
+		if err := json.Unmarshal([]byte(modelsJSON.String), &e.Models); err != nil {
+			return nil, err
+		}
+	}
+	if effectiveAt.Valid {
+		e.EffectiveAt = &effectiveAt.Time
+	}
+	if noticeUntil.Valid {
+		e.NoticeUntil = &noticeUntil.Time
+	}
+	return e, nil
+}
+
+// encodeChangelogModels 序列化受影响的模型，为空时存 NULL
+func encodeChangelogModels(models []string) (interface{}, error) {
+	if len(models) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(models)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// CreateChangelogEntry 创建变更日志条目
+func CreateChangelogEntry(e *ChangelogEntry) error {
+	modelsJSON, err := encodeChangelogModels(e.Models)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO api_changelog (title, description, category, models, effective_at, notice_from, notice_until, published, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Title, e.Description, e.Category, modelsJSON, e.EffectiveAt, e.NoticeFrom, e.NoticeUntil, e.Published, now, now,
+	)
+	if err != nil {
+		return err
+	}
+	e.ID, err = result.LastInsertId()
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	return err
+}
+
+// GetChangelogEntry 根据ID获取变更日志条目
+func GetChangelogEntry(id int64) (*ChangelogEntry, error) {
+	e, err := scanChangelogEntry(db.QueryRow(
+		`SELECT `+changelogColumns+` FROM api_changelog WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrChangelogEntryNotFound
+	}
+	return e, err
+}
+
+// ListChangelogEntries 获取变更日志条目（按提示开始时间倒序），publishedOnly 时只返回已发布的条目
+func ListChangelogEntries(publishedOnly bool) ([]*ChangelogEntry, error) {
+	query := `SELECT ` + changelogColumns + ` FROM api_changelog`
+	if publishedOnly {
+		query += ` WHERE published = TRUE`
+	}
+	rows, err := db.Query(query + ` ORDER BY notice_from DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*ChangelogEntry{}
+	for rows.Next() {
+		e, err := scanChangelogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// UpdateChangelogEntry 更新变更日志条目
+func UpdateChangelogEntry(e *ChangelogEntry) error {
+	modelsJSON, err := encodeChangelogModels(e.Models)
+	if err != nil {
+		return err
+	}
+
+	e.UpdatedAt = time.Now()
+	result, err := db.Exec(
+		`UPDATE api_changelog SET title = ?, description = ?, category = ?, models = ?, effective_at = ?,
+		 notice_from = ?, notice_until = ?, published = ?, updated_at = ?
+		 WHERE id = ?`,
+		e.Title, e.Description, e.Category, modelsJSON, e.EffectiveAt,
+		e.NoticeFrom, e.NoticeUntil, e.Published, e.UpdatedAt, e.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrChangelogEntryNotFound
+	}
+	return nil
+}
+
+// DeleteChangelogEntry 删除变更日志条目
+func DeleteChangelogEntry(id int64) error {
+	result, err := db.Exec(`DELETE FROM api_changelog WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrChangelogEntryNotFound
+	}
+	return nil
+}
@@ -41,9 +41,10 @@ func AddAPIKeyWithName(key string, userID *int64, tokenName string) error {
 
 // APIKeyOptions contains optional parameters for creating an API key
 type APIKeyOptions struct {
-	QuotaLimit    *float64   // Quota limit in USD, nil means unlimited
-	ExpiresAt     *time.Time // Expiration time, nil means never expires
-	AllowedModels []string   // Allowed models, nil/empty means all models
+	QuotaLimit      *float64   // Quota limit in USD, nil means unlimited
+	ExpiresAt       *time.Time // Expiration time, nil means never expires
+	AllowedModels   []string   // Allowed models, nil/empty means all models
+	PriorityTrusted bool       // Whether the X-Priority header is honored for this key
 }
 
 // AddAPIKeyWithOptions 添加API密钥（带完整选项）
@@ -54,10 +55,12 @@ func AddAPIKeyWithOptions(key string, userID *int64, tokenName string, opts *API
 	var quotaLimit *float64
 	var expiresAt *time.Time
 	var allowedModelsJSON *string
+	var priorityTrusted bool
 	
 	if opts != nil {
 		quotaLimit = opts.QuotaLimit
 		expiresAt = opts.ExpiresAt
+		priorityTrusted = opts.PriorityTrusted
 		if len(opts.AllowedModels) > 0 {
 			jsonBytes, err := json.Marshal(opts.AllowedModels)
 			if err != nil {
@@ -69,9 +72,9 @@ func AddAPIKeyWithOptions(key string, userID *int64, tokenName string, opts *API
 	}
 	
 	_, err := db.Exec(
-		"INSERT INTO api_keys (key_value, masked_key, token_name, user_id, created_at, usage_count, is_active, quota_limit, quota_used, expires_at, allowed_models) "+
-			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		key, maskedKey, tokenName, userID, time.Now(), 0, true, quotaLimit, 0.0, expiresAt, allowedModelsJSON,
+		"INSERT INTO api_keys (key_value, masked_key, token_name, user_id, created_at, usage_count, is_active, quota_limit, quota_used, expires_at, allowed_models, priority_trusted) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		key, maskedKey, tokenName, userID, time.Now(), 0, true, quotaLimit, 0.0, expiresAt, allowedModelsJSON, priorityTrusted,
 	)
 	if err != nil {
 		fmt.Printf("AddAPIKeyWithOptions error: %v\n", err)
@@ -88,14 +91,19 @@ func GetAPIKey(key string) (*models.KeyInfo, error) {
 	var quotaUsed sql.NullFloat64
 	var expiresAt sql.NullTime
 	var allowedModelsJSON sql.NullString
+	var signingSecret sql.NullString
+	var tagsJSON sql.NullString
+	var scopesJSON sql.NullString
+	var allowedIPsJSON sql.NullString
 	
 	err := db.QueryRow(
 		"SELECT key_value, masked_key, token_name, user_id, created_at, usage_count, last_used_at, is_active, "+
-			"quota_limit, quota_used, expires_at, allowed_models "+
+			"quota_limit, quota_used, expires_at, allowed_models, priority_trusted, stream_flush_interval_ms, stream_flush_bytes, signing_secret, tags, scopes, allowed_ips "+
 			"FROM api_keys WHERE key_value = ? AND is_active = TRUE",
 		key,
 	).Scan(&keyInfo.Key, &keyInfo.MaskedKey, &tokenName, &keyInfo.UserID, &keyInfo.CreatedAt, &keyInfo.UsageCount, 
-		&lastUsedAt, &keyInfo.IsActive, &quotaLimit, &quotaUsed, &expiresAt, &allowedModelsJSON)
+		&lastUsedAt, &keyInfo.IsActive, &quotaLimit, &quotaUsed, &expiresAt, &allowedModelsJSON, &keyInfo.PriorityTrusted,
+		&keyInfo.StreamFlushIntervalMs, &keyInfo.StreamFlushBytes, &signingSecret, &tagsJSON, &scopesJSON, &allowedIPsJSON)
 	
 	if err == sql.ErrNoRows {
 		return nil, ErrKeyNotFound
@@ -125,6 +133,13 @@ func GetAPIKey(key string) (*models.KeyInfo, error) {
 			keyInfo.AllowedModels = models
 		}
 	}
+	if signingSecret.Valid && signingSecret.String != "" {
+		keyInfo.SigningSecret = signingSecret.String
+		keyInfo.SigningEnabled = true
+	}
+	keyInfo.Tags = decodeKeyTags(tagsJSON)
+	keyInfo.Scopes = decodeKeyTags(scopesJSON)
+	keyInfo.AllowedIPs = decodeKeyTags(allowedIPsJSON)
 	
 	return keyInfo, nil
 }
@@ -133,7 +148,8 @@ func GetAPIKey(key string) (*models.KeyInfo, error) {
 func ListAPIKeys() ([]*models.KeyInfo, error) {
 	rows, err := db.Query(
 		"SELECT k.key_value, k.masked_key, k.token_name, k.user_id, k.created_at, k.usage_count, k.last_used_at, k.is_active, " +
-			"k.quota_limit, k.quota_used, k.expires_at, k.allowed_models, u.username " +
+			"k.quota_limit, k.quota_used, k.expires_at, k.allowed_models, k.priority_trusted, " +
+			"k.stream_flush_interval_ms, k.stream_flush_bytes, k.signing_secret, k.tags, k.scopes, k.allowed_ips, u.username " +
 			"FROM api_keys k " +
 			"LEFT JOIN users u ON k.user_id = u.id " +
 			"WHERE k.is_active = TRUE " +
@@ -154,9 +170,14 @@ func ListAPIKeys() ([]*models.KeyInfo, error) {
 		var quotaUsed sql.NullFloat64
 		var expiresAt sql.NullTime
 		var allowedModelsJSON sql.NullString
+		var signingSecret sql.NullString
+		var tagsJSON sql.NullString
+		var scopesJSON sql.NullString
+		var allowedIPsJSON sql.NullString
 		
 		err := rows.Scan(&key.Key, &key.MaskedKey, &tokenName, &key.UserID, &key.CreatedAt, &key.UsageCount, 
-			&lastUsedAt, &key.IsActive, &quotaLimit, &quotaUsed, &expiresAt, &allowedModelsJSON, &username)
+			&lastUsedAt, &key.IsActive, &quotaLimit, &quotaUsed, &expiresAt, &allowedModelsJSON, &key.PriorityTrusted,
+			&key.StreamFlushIntervalMs, &key.StreamFlushBytes, &signingSecret, &tagsJSON, &scopesJSON, &allowedIPsJSON, &username)
 		if err != nil {
 			return nil, err
 		}
@@ -184,6 +205,13 @@ func ListAPIKeys() ([]*models.KeyInfo, error) {
 				key.AllowedModels = models
 			}
 		}
+		if signingSecret.Valid && signingSecret.String != "" {
+			key.SigningSecret = signingSecret.String
+			key.SigningEnabled = true
+		}
+		key.Tags = decodeKeyTags(tagsJSON)
+		key.Scopes = decodeKeyTags(scopesJSON)
+		key.AllowedIPs = decodeKeyTags(allowedIPsJSON)
 		keys = append(keys, key)
 	}
 	
@@ -212,8 +240,8 @@ func RemoveAPIKey(key string) error {
 // IncrementKeyUsage 增加密钥使用次数
 func IncrementKeyUsage(key string) error {
 	_, err := db.Exec(
-		"UPDATE api_keys SET usage_count = usage_count + 1 WHERE key_value = ?",
-		key,
+		"UPDATE api_keys SET usage_count = usage_count + 1, last_used_at = ? WHERE key_value = ?",
+		time.Now(), key,
 	)
 	return err
 }
@@ -311,6 +339,294 @@ func UpdateAPIKeyName(key, name string) error {
 	return nil
 }
 
+// SetAPIKeyPriorityTrusted 设置API密钥是否允许使用 X-Priority 请求头
+func SetAPIKeyPriorityTrusted(key string, trusted bool) error {
+	var exists bool
+	if err := db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)",
+		key,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	_, err := db.Exec(
+		"UPDATE api_keys SET priority_trusted = ? WHERE key_value = ?",
+		trusted, key,
+	)
+	return err
+}
+
+// decodeKeyTags 解析 tags、scopes、allowed_ips 列（JSON 数组），NULL 或格式错误时返回 nil
+func decodeKeyTags(tagsJSON sql.NullString) []string {
+	if !tagsJSON.Valid || tagsJSON.String == "" {
+		return nil
+	}
+	var tags []string
+	if err := json.Unmarshal([]byte(tagsJSON.String), &tags); err != nil {
+		return nil
+	}
+	return tags
+}
+
+// SetAPIKeyTags 设置API密钥的标签，空列表表示清除
+func SetAPIKeyTags(key string, tags []string) error {
+	var tagsJSON *string
+	if len(tags) > 0 {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		tagsJSON = &s
+	}
+	result, err := db.Exec("UPDATE api_keys SET tags = ? WHERE key_value = ?", tagsJSON, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// SetAPIKeyExpiration 设置API密钥的过期时间，nil 表示永不过期
+func SetAPIKeyExpiration(key string, expiresAt *time.Time) error {
+	result, err := db.Exec("UPDATE api_keys SET expires_at = ? WHERE key_value = ?", expiresAt, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// ResetAPIKeyQuotaUsed 将API密钥的已用额度清零
+func ResetAPIKeyQuotaUsed(key string) error {
+	result, err := db.Exec("UPDATE api_keys SET quota_used = 0 WHERE key_value = ?", key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// SetAPIKeyAllowedModels 设置API密钥的模型白名单，空列表表示不限制
+func SetAPIKeyAllowedModels(key string, allowedModels []string) error {
+	var modelsJSON *string
+	if len(allowedModels) > 0 {
+		data, err := json.Marshal(allowedModels)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		modelsJSON = &s
+	}
+	result, err := db.Exec("UPDATE api_keys SET allowed_models = ? WHERE key_value = ?", modelsJSON, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// SetAPIKeyScopes 设置API密钥的作用域，空列表表示不限制
+func SetAPIKeyScopes(key string, scopes []string) error {
+	var scopesJSON *string
+	if len(scopes) > 0 {
+		data, err := json.Marshal(scopes)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		scopesJSON = &s
+	}
+	result, err := db.Exec("UPDATE api_keys SET scopes = ? WHERE key_value = ?", scopesJSON, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// SetAPIKeyAllowedIPs 设置API密钥的 IP 白名单（CIDR 列表），空列表表示不限制
+func SetAPIKeyAllowedIPs(key string, allowedIPs []string) error {
+	var ipsJSON *string
+	if len(allowedIPs) > 0 {
+		data, err := json.Marshal(allowedIPs)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		ipsJSON = &s
+	}
+	result, err := db.Exec("UPDATE api_keys SET allowed_ips = ? WHERE key_value = ?", ipsJSON, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// SetAPIKeySigningSecret 设置API密钥的请求签名密钥，nil 表示关闭签名校验
+func SetAPIKeySigningSecret(key string, secret *string) error {
+	result, err := db.Exec(
+		"UPDATE api_keys SET signing_secret = ? WHERE key_value = ?",
+		secret, key,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// SetAPIKeyStreamFlush 设置API密钥的 SSE 合并策略，nil 表示使用服务器默认值
+func SetAPIKeyStreamFlush(key string, intervalMs, bytes *int) error {
+	result, err := db.Exec(
+		"UPDATE api_keys SET stream_flush_interval_ms = ?, stream_flush_bytes = ? WHERE key_value = ?",
+		intervalMs, bytes, key,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)", key).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrKeyNotFound
+		}
+	}
+	return nil
+}
+
+// ListRotatableKeysByUser 列出用户当前可用（启用、未过期、未被轮换）的密钥
+func ListRotatableKeysByUser(userID int64) ([]string, error) {
+	rows, err := db.Query(
+		`SELECT key_value FROM api_keys
+		 WHERE user_id = ? AND is_active = TRUE AND rotated_at IS NULL
+		   AND (expires_at IS NULL OR expires_at > ?)
+		 ORDER BY created_at`,
+		userID, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RotateAPIKey 以 newKey 替换 oldKey：新密钥继承名称、用户、额度、过期时间、模型限制、优先级/流式设置、签名密钥、作用域与 IP 白名单，
+// 旧密钥在 graceUntil 时过期（不晚于原过期时间），graceUntil 为 nil 时立即禁用
+func RotateAPIKey(oldKey, newKey string, graceUntil *time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO api_keys (key_value, masked_key, token_name, user_id, created_at, usage_count, is_active,
+		   quota_limit, quota_used, expires_at, allowed_models, priority_trusted, stream_flush_interval_ms, stream_flush_bytes, signing_secret, scopes, allowed_ips)
+		 SELECT ?, ?, token_name, user_id, ?, 0, TRUE,
+		   quota_limit, quota_used, expires_at, allowed_models, priority_trusted, stream_flush_interval_ms, stream_flush_bytes, signing_secret, scopes, allowed_ips
+		 FROM api_keys WHERE key_value = ? AND rotated_at IS NULL`,
+		newKey, maskKey(newKey), now, oldKey,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrKeyNotFound
+	}
+
+	if graceUntil == nil {
+		_, err = tx.Exec(
+			`UPDATE api_keys SET is_active = FALSE, rotated_at = ? WHERE key_value = ?`,
+			now, oldKey,
+		)
+	} else {
+		_, err = tx.Exec(
+			`UPDATE api_keys SET rotated_at = ?,
+			   expires_at = CASE WHEN expires_at IS NULL OR expires_at > ? THEN ? ELSE expires_at END
+			 WHERE key_value = ?`,
+			now, *graceUntil, *graceUntil, oldKey,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // UpdateAPIKeyLastUsed 更新API密钥的最后使用时间
 func UpdateAPIKeyLastUsed(key string, timestamp time.Time) error {
 	_, err := db.Exec(
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Audit log actions
+const (
+	AuditActionKeyRotation          = "api_keys.rotate"
+	AuditActionTermsPublish         = "terms.publish"
+	AuditActionSudoGrant            = "sudo.grant"              // 重新验证密码进入 sudo 模式
+	AuditActionSudoDenied           = "sudo.denied"             // 进入 sudo 模式时密码或两步验证码错误
+	AuditActionSudoOperate          = "sudo.operation"          // 在 sudo 模式下执行危险操作
+	AuditActionTwoFactorEnable      = "two_factor.enable"       // 启用两步验证
+	AuditActionTwoFactorDisable     = "two_factor.disable"      // 关闭两步验证
+	AuditActionTwoFactorBackupCodes = "two_factor.backup_codes" // 重新生成备用码
+	AuditActionTwoFactorBackupLogin = "two_factor.backup_login" // 使用备用码登录
+	AuditActionTwoFactorPolicy      = "two_factor.policy"       // 修改管理员两步验证要求
+	AuditActionPasswordReset        = "auth.password_reset"     // 通过邮箱验证码重置密码
+)
+
+// AuditLog 管理操作审计记录
+type AuditLog struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// CreateAuditLog 写入审计记录，details 序列化为 JSON
+func CreateAuditLog(actorID *int64, action, targetType, targetID string, details interface{}) error {
+	var detailsJSON *string
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		detailsJSON = &s
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO audit_logs (actor_id, action, target_type, target_id, details, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		actorID, action, targetType, targetID, detailsJSON, time.Now(),
+	)
+	return err
+}
+
+// ListAuditLogs 按时间倒序列出审计记录，action 为空时返回全部
+func ListAuditLogs(action string, limit int) ([]*AuditLog, error) {
+	query := `SELECT id, actor_id, action, target_type, target_id, details, created_at FROM audit_logs`
+	args := []interface{}{}
+	if action != "" {
+		query += ` WHERE action = ?`
+		args = append(args, action)
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(query+` ORDER BY created_at DESC, id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []*AuditLog{}
+	for rows.Next() {
+		entry := &AuditLog{}
+		var actorID sql.NullInt64
+		var details sql.NullString
+		if err := rows.Scan(
+			&entry.ID,
+			&actorID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&details,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if actorID.Valid {
+			entry.ActorID = &actorID.Int64
+		}
+		if details.Valid {
+			entry.Details = json.RawMessage(details.String)
+		}
+		logs = append(logs, entry)
+	}
+	return logs, rows.Err()
+}
@@ -23,6 +23,7 @@ const (
 	TransactionTypeAPIUsage      = "api_usage"
 	TransactionTypeReferralBonus = "referral_bonus"
 	TransactionTypeAdminAdjust   = "admin_adjust"
+	TransactionTypeCheckinReward = "checkin_reward"
 )
 
 // Errors
@@ -60,6 +61,8 @@ type BalanceTransaction struct {
 	AdminID       *int64     `json:"admin_id,omitempty"`
 	APIToken      string     `json:"api_token,omitempty"`
 	Model         string     `json:"model,omitempty"`
+	TaxRate       float64    `json:"tax_rate,omitempty"`   // VAT rate applied to recharges
+	TaxAmount     float64    `json:"tax_amount,omitempty"` // VAT amount charged on top of the credited amount
 	CreatedAt     time.Time  `json:"created_at"`
 }
 
@@ -199,8 +202,12 @@ func CalculateCost(tokens int) float64 {
 // DeductBalance deducts balance based on token usage and creates a transaction record
 // Requirements: 2.1, 2.2, 2.3
 func DeductBalance(userID int64, tokens int, apiToken, model string) (*BalanceTransaction, error) {
-	cost := CalculateCost(tokens)
-	
+	return DeductBalanceCost(userID, CalculateCost(tokens), tokens, apiToken, model)
+}
+
+// DeductBalanceCost deducts a precomputed cost, such as a per-image price, and creates
+// a transaction record; tokens is recorded on the transaction only
+func DeductBalanceCost(userID int64, cost float64, tokens int, apiToken, model string) (*BalanceTransaction, error) {
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
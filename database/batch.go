@@ -0,0 +1,344 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrBatchNotFound = errors.New("batch not found")
+
+// 批处理状态
+const (
+	BatchStatusInProgress = "in_progress"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCompleted  = "completed"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// 批处理中单个请求的状态
+const (
+	BatchRequestPending   = "pending"
+	BatchRequestCompleted = "completed"
+	BatchRequestFailed    = "failed"
+	BatchRequestCancelled = "cancelled"
+)
+
+// Batch 异步批处理任务，计数与用量随请求完成累加
+type Batch struct {
+	ID               string            `json:"id"`
+	UserID           int64             `json:"user_id"`
+	Username         string            `json:"-"`
+	APIKey           string            `json:"-"`
+	TokenName        string            `json:"-"`
+	Endpoint         string            `json:"endpoint"`
+	Status           string            `json:"status"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Total            int               `json:"total"`
+	Completed        int               `json:"completed"`
+	Failed           int               `json:"failed"`
+	PromptTokens     int64             `json:"prompt_tokens"`
+	CompletionTokens int64             `json:"completion_tokens"`
+	TotalTokens      int64             `json:"total_tokens"`
+	Cost             float64           `json:"cost"`
+	CreatedAt        time.Time         `json:"created_at"`
+	CompletedAt      *time.Time        `json:"completed_at,omitempty"`
+	CancelledAt      *time.Time        `json:"cancelled_at,omitempty"`
+}
+
+// BatchRequest 批处理中的单个请求；Response 为上游成功响应的 JSON
+type BatchRequest struct {
+	ID               int64      `json:"id"`
+	BatchID          string     `json:"batch_id"`
+	Line             int        `json:"line"`
+	CustomID         string     `json:"custom_id"`
+	Body             string     `json:"-"`
+	Status           string     `json:"status"`
+	StatusCode       int        `json:"status_code"`
+	Response         string     `json:"-"`
+	Error            string     `json:"error,omitempty"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	Cost             float64    `json:"cost"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
+
+const batchColumns = `id, user_id, username, api_key, token_name, endpoint, status, metadata,
+	total_requests, completed_requests, failed_requests, prompt_tokens, completion_tokens, total_tokens,
+	cost, created_at, completed_at, cancelled_at`
+
+func scanBatch(row interface{ Scan(...interface{}) error }) (*Batch, error) {
+	b := &Batch{}
+	var metadata sql.NullString
+	var completedAt, cancelledAt sql.NullTime
+	if err := row.Scan(
+		&b.ID,
+		&b.UserID,
+		&b.Username,
+		&b.APIKey,
+		&b.TokenName,
+		&b.Endpoint,
+		&b.Status,
+		&metadata,
+		&b.Total,
+		&b.Completed,
+		&b.Failed,
+		&b.PromptTokens,
+		&b.CompletionTokens,
+		&b.TotalTokens,
+		&b.Cost,
+		&b.CreatedAt,
+		&completedAt,
+		&cancelledAt,
+	); err != nil {
+		return nil, err
+	}
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &b.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	if completedAt.Valid {
+		b.CompletedAt = &completedAt.Time
+	}
+	if cancelledAt.Valid {
+		b.CancelledAt = &cancelledAt.Time
+	}
+	return b, nil
+}
+
+// CreateBatch 在一个事务中创建批处理及其全部请求
+func CreateBatch(b *Batch, requests []*BatchRequest) error {
+	metadata, err := json.Marshal(b.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	b.Status = BatchStatusInProgress
+	b.Total = len(requests)
+	b.CreatedAt = time.Now()
+	if _, err := tx.Exec(
+		`INSERT INTO batches (id, user_id, username, api_key, token_name, endpoint, status, metadata, total_requests, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.UserID, b.Username, b.APIKey, b.TokenName, b.Endpoint, b.Status, string(metadata), b.Total, b.CreatedAt,
+	); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO batch_requests (batch_id, line, custom_id, body, status) VALUES (?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range requests {
+		r.BatchID = b.ID
+		r.Status = BatchRequestPending
+		result, err := stmt.Exec(r.BatchID, r.Line, r.CustomID, r.Body, r.Status)
+		if err != nil {
+			return err
+		}
+		r.ID, _ = result.LastInsertId()
+	}
+	return tx.Commit()
+}
+
+// GetBatch 获取批处理
+func GetBatch(id string) (*Batch, error) {
+	b, err := scanBatch(db.QueryRow(`SELECT `+batchColumns+` FROM batches WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrBatchNotFound
+	}
+	return b, err
+}
+
+// ListBatchesByUser 获取用户最近的批处理
+func ListBatchesByUser(userID int64, limit int) ([]*Batch, error) {
+	rows, err := db.Query(
+		`SELECT `+batchColumns+` FROM batches WHERE user_id = ? ORDER BY created_at DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make([]*Batch, 0)
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// ListBatchesByStatus 获取处于指定状态的批处理（启动时恢复未完成的批处理）
+func ListBatchesByStatus(status string) ([]*Batch, error) {
+	rows, err := db.Query(
+		`SELECT `+batchColumns+` FROM batches WHERE status = ? ORDER BY created_at`,
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []*Batch
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// ListBatchRequests 按行号获取批处理的请求；pendingOnly 时只返回待处理的请求（含请求体）
+func ListBatchRequests(batchID string, pendingOnly bool) ([]*BatchRequest, error) {
+	query := `SELECT id, batch_id, line, custom_id, body, status, status_code, response, error,
+		prompt_tokens, completion_tokens, cost, completed_at
+		FROM batch_requests WHERE batch_id = ?`
+	args := []interface{}{batchID}
+	if pendingOnly {
+		query += ` AND status = ?`
+		args = append(args, BatchRequestPending)
+	}
+	rows, err := db.Query(query+` ORDER BY line`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*BatchRequest
+	for rows.Next() {
+		r := &BatchRequest{}
+		var response, errMsg sql.NullString
+		var completedAt sql.NullTime
+		if err := rows.Scan(
+			&r.ID,
+			&r.BatchID,
+			&r.Line,
+			&r.CustomID,
+			&r.Body,
+			&r.Status,
+			&r.StatusCode,
+			&response,
+			&errMsg,
+			&r.PromptTokens,
+			&r.CompletionTokens,
+			&r.Cost,
+			&completedAt,
+		); err != nil {
+			return nil, err
+		}
+		r.Response = response.String
+		r.Error = errMsg.String
+		if completedAt.Valid {
+			r.CompletedAt = &completedAt.Time
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+// CompleteBatchRequest 保存待处理请求的结果并累加到批处理的计数与用量
+func CompleteBatchRequest(r *BatchRequest) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`UPDATE batch_requests SET status = ?, status_code = ?, response = ?, error = ?,
+		 prompt_tokens = ?, completion_tokens = ?, cost = ?, completed_at = ?
+		 WHERE id = ? AND status = ?`,
+		r.Status, r.StatusCode, r.Response, r.Error, r.PromptTokens, r.CompletionTokens, r.Cost, now,
+		r.ID, BatchRequestPending,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// 已被取消或重复处理
+		return nil
+	}
+
+	completed, failed := 1, 0
+	if r.Status != BatchRequestCompleted {
+		completed, failed = 0, 1
+	}
+	if _, err := tx.Exec(
+		`UPDATE batches SET completed_requests = completed_requests + ?, failed_requests = failed_requests + ?,
+		 prompt_tokens = prompt_tokens + ?, completion_tokens = completion_tokens + ?,
+		 total_tokens = total_tokens + ?, cost = cost + ?
+		 WHERE id = ?`,
+		completed, failed, r.PromptTokens, r.CompletionTokens, r.PromptTokens+r.CompletionTokens, r.Cost, r.BatchID,
+	); err != nil {
+		return err
+	}
+	r.CompletedAt = &now
+	return tx.Commit()
+}
+
+// FinishBatch 所有请求处理完后将批处理标记为 completed
+func FinishBatch(id string) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE batches SET status = ?, completed_at = ?
+		 WHERE id = ? AND status = ? AND completed_requests + failed_requests >= total_requests`,
+		BatchStatusCompleted, time.Now(), id, BatchStatusInProgress,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// RequestBatchCancel 将进行中的批处理标记为 cancelling，返回是否发生了状态变化
+func RequestBatchCancel(id string) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE batches SET status = ? WHERE id = ? AND status = ?`,
+		BatchStatusCancelling, id, BatchStatusInProgress,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// CancelBatch 将批处理剩余的待处理请求标记为 cancelled，并结束批处理
+func CancelBatch(id string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE batch_requests SET status = ? WHERE batch_id = ? AND status = ?`,
+		BatchRequestCancelled, id, BatchRequestPending,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`UPDATE batches SET status = ?, cancelled_at = ? WHERE id = ? AND status = ?`,
+		BatchStatusCancelled, time.Now(), id, BatchStatusCancelling,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
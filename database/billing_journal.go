@@ -0,0 +1,133 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"Curry2API-go/utils"
+)
+
+// billingLog logs billing journal replays
+var billingLog = utils.SubsystemLogger(utils.LogSubsystemBilling)
+
+// BillingJournalEntry 数据库不可用期间记录到磁盘的计费事件
+type BillingJournalEntry struct {
+	UserID    int64     `json:"user_id"`
+	Tokens    int       `json:"tokens"`
+	APIToken  string    `json:"api_token"`
+	Model     string    `json:"model"`
+	Cost      float64   `json:"cost"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	billingJournalPath = "data/billing_journal.jsonl"
+	billingJournalMu   sync.Mutex
+)
+
+// SetBillingJournalPath 设置计费日志文件路径
+func SetBillingJournalPath(path string) {
+	if path == "" {
+		return
+	}
+	billingJournalMu.Lock()
+	defer billingJournalMu.Unlock()
+	billingJournalPath = path
+}
+
+// AppendBillingJournal 追加一条计费事件到磁盘日志
+func AppendBillingJournal(entry *BillingJournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	billingJournalMu.Lock()
+	defer billingJournalMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(billingJournalPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(billingJournalPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// ReplayBillingJournal 重放磁盘上的计费日志，apply 失败的条目会保留到下次重放
+// 返回成功重放的条目数
+func ReplayBillingJournal(apply func(*BillingJournalEntry) error) (int, error) {
+	billingJournalMu.Lock()
+	defer billingJournalMu.Unlock()
+
+	f, err := os.Open(billingJournalPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []*BillingJournalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		entry := &BillingJournalEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			billingLog.WithError(err).Warn("Skipping malformed billing journal entry")
+			continue
+		}
+		pending = append(pending, entry)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	var remaining []*BillingJournalEntry
+	for i, entry := range pending {
+		if err := apply(entry); err != nil {
+			if IsConnectionError(err) {
+				// 数据库再次不可用，剩余条目全部保留
+				remaining = append(remaining, pending[i:]...)
+				break
+			}
+			billingLog.WithError(err).WithField("user_id", entry.UserID).Warn("Failed to replay billing journal entry")
+			remaining = append(remaining, entry)
+			continue
+		}
+		replayed++
+	}
+
+	if len(remaining) == 0 {
+		return replayed, os.Remove(billingJournalPath)
+	}
+
+	tmpPath := billingJournalPath + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return replayed, err
+	}
+	for _, entry := range remaining {
+		data, _ := json.Marshal(entry)
+		out.Write(append(data, '\n'))
+	}
+	if err := out.Close(); err != nil {
+		return replayed, err
+	}
+	return replayed, os.Rename(tmpPath, billingJournalPath)
+}
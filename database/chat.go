@@ -2,7 +2,9 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"Curry2API-go/models"
@@ -14,6 +16,9 @@ var (
 	ErrMessageNotFound      = errors.New("message not found")
 )
 
+// conversationCostColumn selects the cumulative message cost of conversation c
+const conversationCostColumn = `(SELECT COALESCE(SUM(m.cost), 0) FROM chat_messages m WHERE m.conversation_id = c.id)`
+
 // CreateConversation creates a new chat conversation for a user
 // Requirements: 1.1
 func CreateConversation(userID int64, title, model string) (*models.Conversation, error) {
@@ -43,33 +48,64 @@ func CreateConversation(userID int64, title, model string) (*models.Conversation
 	}, nil
 }
 
-// GetConversations retrieves paginated conversations for a user, sorted by updated_at DESC
+// ConversationFilter narrows a conversation listing; the zero value lists every conversation
+type ConversationFilter struct {
+	FolderID *int64 // Only conversations in this folder
+	Unfiled  bool   // Only conversations in no folder
+	Tag      string // Only conversations with this tag
+
+	IncludeArchived bool // Also list archived conversations, which are hidden by default
+}
+
+// where returns the conditions and arguments selecting the user's conversations matching the filter
+func (f ConversationFilter) where(userID int64) (string, []interface{}) {
+	where := `c.user_id = ?`
+	args := []interface{}{userID}
+	if f.FolderID != nil {
+		where += ` AND c.folder_id = ?`
+		args = append(args, *f.FolderID)
+	} else if f.Unfiled {
+		where += ` AND c.folder_id IS NULL`
+	}
+	if f.Tag != "" {
+		where += ` AND EXISTS (SELECT 1 FROM chat_conversation_tags t WHERE t.conversation_id = c.id AND t.tag = ?)`
+		args = append(args, f.Tag)
+	}
+	if !f.IncludeArchived {
+		where += ` AND c.archived = FALSE`
+	}
+	return where, args
+}
+
+// GetConversations retrieves paginated conversations for a user matching the filter, pinned
+// conversations first, then by updated_at DESC
 // Requirements: 1.2, 7.3
-func GetConversations(userID int64, page, limit int) ([]models.Conversation, int, error) {
+func GetConversations(userID int64, filter ConversationFilter, page, limit int) ([]models.Conversation, int, error) {
 	// Calculate offset
 	offset := (page - 1) * limit
 	if offset < 0 {
 		offset = 0
 	}
+	where, args := filter.where(userID)
 
 	// Get total count
 	var total int
 	err := db.QueryRow(
-		`SELECT COUNT(*) FROM chat_conversations WHERE user_id = ?`,
-		userID,
+		`SELECT COUNT(*) FROM chat_conversations c WHERE `+where,
+		args...,
 	).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// Get conversations sorted by updated_at DESC
+	// Get conversations, pinned first, sorted by updated_at DESC
 	rows, err := db.Query(
-		`SELECT id, user_id, title, model, COALESCE(system_prompt, ''), created_at, updated_at
-		 FROM chat_conversations 
-		 WHERE user_id = ? 
-		 ORDER BY updated_at DESC 
+		`SELECT id, user_id, title, model, COALESCE(system_prompt, ''), max_cost, deterministic, seed, folder_id, prompt_template_id, pinned, archived, `+conversationCostColumn+`, created_at, updated_at
+		 FROM chat_conversations c
+		 WHERE `+where+`
+		 ORDER BY pinned DESC, updated_at DESC
 		 LIMIT ? OFFSET ?`,
-		userID, limit, offset,
+		append(args, limit, offset)...,
 	)
 	if err != nil {
 		return nil, 0, err
@@ -80,11 +116,25 @@ func GetConversations(userID int64, page, limit int) ([]models.Conversation, int
 	conversations := make([]models.Conversation, 0)
 	for rows.Next() {
 		var conv models.Conversation
+		var maxCost sql.NullFloat64
+		var seed, folderID, templateID sql.NullInt64
 		err := rows.Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.Model,
-			&conv.SystemPrompt, &conv.CreatedAt, &conv.UpdatedAt)
+			&conv.SystemPrompt, &maxCost, &conv.Deterministic, &seed, &folderID, &templateID, &conv.Pinned, &conv.Archived, &conv.TotalCost, &conv.CreatedAt, &conv.UpdatedAt)
 		if err != nil {
 			return nil, 0, err
 		}
+		if maxCost.Valid {
+			conv.MaxCost = &maxCost.Float64
+		}
+		if seed.Valid {
+			conv.Seed = &seed.Int64
+		}
+		if folderID.Valid {
+			conv.FolderID = &folderID.Int64
+		}
+		if templateID.Valid {
+			conv.PromptTemplateID = &templateID.Int64
+		}
 		conversations = append(conversations, conv)
 	}
 
@@ -92,6 +142,10 @@ func GetConversations(userID int64, page, limit int) ([]models.Conversation, int
 		return nil, 0, err
 	}
 
+	if err := attachConversationTags(conversations); err != nil {
+		return nil, 0, err
+	}
+
 	return conversations, total, nil
 }
 
@@ -99,14 +153,16 @@ func GetConversations(userID int64, page, limit int) ([]models.Conversation, int
 // Requirements: 1.3
 func GetConversation(id, userID int64) (*models.Conversation, error) {
 	conv := &models.Conversation{}
+	var maxCost sql.NullFloat64
+	var seed, folderID, templateID sql.NullInt64
 
 	err := db.QueryRow(
-		`SELECT id, user_id, title, model, COALESCE(system_prompt, ''), created_at, updated_at
-		 FROM chat_conversations 
+		`SELECT id, user_id, title, model, COALESCE(system_prompt, ''), max_cost, deterministic, seed, folder_id, prompt_template_id, pinned, archived, `+conversationCostColumn+`, created_at, updated_at
+		 FROM chat_conversations c
 		 WHERE id = ? AND user_id = ?`,
 		id, userID,
 	).Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.Model,
-		&conv.SystemPrompt, &conv.CreatedAt, &conv.UpdatedAt)
+		&conv.SystemPrompt, &maxCost, &conv.Deterministic, &seed, &folderID, &templateID, &conv.Pinned, &conv.Archived, &conv.TotalCost, &conv.CreatedAt, &conv.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrConversationNotFound
@@ -114,6 +170,18 @@ func GetConversation(id, userID int64) (*models.Conversation, error) {
 	if err != nil {
 		return nil, err
 	}
+	if maxCost.Valid {
+		conv.MaxCost = &maxCost.Float64
+	}
+	if seed.Valid {
+		conv.Seed = &seed.Int64
+	}
+	if folderID.Valid {
+		conv.FolderID = &folderID.Int64
+	}
+	if templateID.Valid {
+		conv.PromptTemplateID = &templateID.Int64
+	}
 
 	return conv, nil
 }
@@ -143,6 +211,98 @@ func UpdateConversation(id, userID int64, title, model string) error {
 	return nil
 }
 
+// SetConversationSystemPrompt sets the conversation's system prompt and the template it was taken
+// from; an empty prompt removes it and a nil template records none
+func SetConversationSystemPrompt(id, userID int64, systemPrompt string, templateID *int64) error {
+	var prompt interface{}
+	if systemPrompt != "" {
+		prompt = systemPrompt
+	}
+	result, err := db.Exec(
+		`UPDATE chat_conversations SET system_prompt = ?, prompt_template_id = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+		prompt, templateID, time.Now(), id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// SetConversationPinned pins or unpins a conversation without touching updated_at
+func SetConversationPinned(id, userID int64, pinned bool) error {
+	return setConversationFlags(id, userID, `pinned = ?`, pinned)
+}
+
+// SetConversationArchived archives or restores a conversation without touching updated_at;
+// archiving also unpins it
+func SetConversationArchived(id, userID int64, archived bool) error {
+	if archived {
+		return setConversationFlags(id, userID, `archived = TRUE, pinned = FALSE`)
+	}
+	return setConversationFlags(id, userID, `archived = FALSE`)
+}
+
+// setConversationFlags applies a SET clause to one of the user's conversations, keeping updated_at
+func setConversationFlags(id, userID int64, set string, args ...interface{}) error {
+	result, err := db.Exec(
+		`UPDATE chat_conversations SET `+set+`, updated_at = updated_at WHERE id = ? AND user_id = ?`,
+		append(args, id, userID)...,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		// Setting a flag to its current value changes no rows
+		belongs, err := ConversationBelongsToUser(id, userID)
+		if err != nil {
+			return err
+		}
+		if !belongs {
+			return ErrConversationNotFound
+		}
+	}
+	return nil
+}
+
+// SetGeneratedConversationTitle replaces the conversation's title with a generated one, unless it
+// no longer carries the expected title. The conversation keeps its place in the recent list
+func SetGeneratedConversationTitle(id, userID int64, title, expected string) error {
+	_, err := db.Exec(
+		`UPDATE chat_conversations SET title = ?, updated_at = updated_at WHERE id = ? AND user_id = ? AND title = ?`,
+		title, id, userID, expected,
+	)
+	return err
+}
+
+// SetConversationMaxCost sets the conversation's spend ceiling; nil removes it
+func SetConversationMaxCost(id, userID int64, maxCost *float64) error {
+	result, err := db.Exec(
+		`UPDATE chat_conversations SET max_cost = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+		maxCost, time.Now(), id, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrConversationNotFound
+	}
+
+	return nil
+}
+
 // DeleteConversation deletes a conversation and all its messages (cascade)
 // Requirements: 1.4
 func DeleteConversation(id, userID int64) error {
@@ -168,10 +328,52 @@ func DeleteConversation(id, userID int64) error {
 	return nil
 }
 
-// CreateMessage creates a new message in a conversation
+// SetConversationDeterministic turns deterministic mode on or off; seed nil uses the default seed
+func SetConversationDeterministic(id, userID int64, deterministic bool, seed *int64) error {
+	result, err := db.Exec(
+		`UPDATE chat_conversations SET deterministic = ?, seed = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+		deterministic, seed, time.Now(), id, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrConversationNotFound
+	}
+
+	return nil
+}
+
+// CreateMessage creates a new message at the end of the conversation's active branch
 // Requirements: 2.1
-func CreateMessage(conversationID int64, role, content string, tokens int, cost float64) (*models.ChatMessage, error) {
+func CreateMessage(conversationID int64, role, content string, promptTokens, completionTokens int, cost float64) (*models.ChatMessage, error) {
+	return CreateMessageWithArtifacts(conversationID, role, content, promptTokens, completionTokens, cost, nil, nil, false, nil)
+}
+
+// CreateMessageWithArtifacts creates a message together with its token usage, the code artifacts
+// extracted from it and the sampling seed it was generated with (nil when none was sent); tokens
+// is stored as the sum of promptTokens and completionTokens. stopped marks a reply
+// the user cut short. The message follows parentID, or the leaf of the active branch when
+// parentID is nil (a pointer to 0 starts a branch at the beginning of the conversation), and
+// becomes the new leaf of the active branch
+func CreateMessageWithArtifacts(conversationID int64, role, content string, promptTokens, completionTokens int, cost float64, artifacts []models.ChatArtifact, seed *int64, stopped bool, parentID *int64) (*models.ChatMessage, error) {
 	now := time.Now()
+	tokens := promptTokens + completionTokens
+
+	var artifactsJSON sql.NullString
+	if len(artifacts) > 0 {
+		data, err := json.Marshal(artifacts)
+		if err != nil {
+			return nil, err
+		}
+		artifactsJSON = sql.NullString{String: string(data), Valid: true}
+	}
 
 	// Start transaction to update conversation's updated_at as well
 	tx, err := db.Begin()
@@ -180,11 +382,23 @@ func CreateMessage(conversationID int64, role, content string, tokens int, cost
 	}
 	defer tx.Rollback()
 
+	leaf, err := lockConversationLeaf(tx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	var parent *int64
+	switch {
+	case parentID == nil && leaf != 0:
+		parent = &leaf
+	case parentID != nil && *parentID != 0:
+		parent = parentID
+	}
+
 	// Insert message
 	result, err := tx.Exec(
-		`INSERT INTO chat_messages (conversation_id, role, content, tokens, cost, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		conversationID, role, content, tokens, cost, now,
+		`INSERT INTO chat_messages (conversation_id, parent_message_id, role, content, artifacts, tokens, prompt_tokens, completion_tokens, cost, seed, stopped, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		conversationID, parent, role, content, artifactsJSON, tokens, promptTokens, completionTokens, cost, seed, stopped, now,
 	)
 	if err != nil {
 		return nil, err
@@ -195,10 +409,10 @@ func CreateMessage(conversationID int64, role, content string, tokens int, cost
 		return nil, err
 	}
 
-	// Update conversation's updated_at
+	// Update conversation's updated_at and make the message the leaf of the active branch
 	_, err = tx.Exec(
-		`UPDATE chat_conversations SET updated_at = ? WHERE id = ?`,
-		now, conversationID,
+		`UPDATE chat_conversations SET updated_at = ?, current_message_id = ? WHERE id = ?`,
+		now, id, conversationID,
 	)
 	if err != nil {
 		return nil, err
@@ -209,102 +423,137 @@ func CreateMessage(conversationID int64, role, content string, tokens int, cost
 	}
 
 	return &models.ChatMessage{
-		ID:             id,
-		ConversationID: conversationID,
-		Role:           role,
-		Content:        content,
-		Tokens:         tokens,
-		Cost:           cost,
-		CreatedAt:      now,
+		ID:               id,
+		ConversationID:   conversationID,
+		ParentMessageID:  parent,
+		Role:             role,
+		Content:          content,
+		Artifacts:        artifacts,
+		Seed:             seed,
+		Stopped:          stopped,
+		Tokens:           tokens,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Cost:             cost,
+		CreatedAt:        now,
 	}, nil
 }
 
-// GetMessages retrieves paginated messages for a conversation, sorted by created_at ASC
-// Requirements: 1.3, 7.2
-func GetMessages(conversationID int64, page, limit int) ([]models.ChatMessage, int, error) {
-	// Calculate offset
-	offset := (page - 1) * limit
-	if offset < 0 {
-		offset = 0
+// chatMessageColumns are the chat_messages columns read by scanChatMessage
+const chatMessageColumns = `id, conversation_id, parent_message_id, role, content, artifacts, tokens, prompt_tokens, completion_tokens, cost, seed, stopped, created_at, content_zstd`
+
+// scanChatMessage scans a chat_messages row selected with chatMessageColumns, followed by
+// any extra columns into extra. Archived messages are decompressed transparently
+func scanChatMessage(rows *sql.Rows, extra ...interface{}) (models.ChatMessage, error) {
+	var msg models.ChatMessage
+	var artifactsJSON sql.NullString
+	var seed, parent sql.NullInt64
+	var archived []byte
+	dest := []interface{}{&msg.ID, &msg.ConversationID, &parent, &msg.Role, &msg.Content, &artifactsJSON,
+		&msg.Tokens, &msg.PromptTokens, &msg.CompletionTokens, &msg.Cost, &seed, &msg.Stopped, &msg.CreatedAt, &archived}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return msg, err
 	}
-
-	// Get total count
-	var total int
-	err := db.QueryRow(
-		`SELECT COUNT(*) FROM chat_messages WHERE conversation_id = ?`,
-		conversationID,
-	).Scan(&total)
-	if err != nil {
-		return nil, 0, err
+	if archived != nil {
+		content, artifacts, err := unarchiveChatMessage(archived)
+		if err != nil {
+			return msg, fmt.Errorf("message %d: %w", msg.ID, err)
+		}
+		msg.Content = content
+		artifactsJSON = sql.NullString{String: artifacts, Valid: artifacts != ""}
 	}
-
-	// Get messages sorted by created_at ASC (chronological order)
-	rows, err := db.Query(
-		`SELECT id, conversation_id, role, content, tokens, cost, created_at
-		 FROM chat_messages 
-		 WHERE conversation_id = ? 
-		 ORDER BY created_at ASC 
-		 LIMIT ? OFFSET ?`,
-		conversationID, limit, offset,
-	)
-	if err != nil {
-		return nil, 0, err
+	if seed.Valid {
+		msg.Seed = &seed.Int64
 	}
-	defer rows.Close()
-
-	// Initialize as empty slice to ensure JSON serializes to [] instead of null
-	messages := make([]models.ChatMessage, 0)
-	for rows.Next() {
-		var msg models.ChatMessage
-		err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Role, &msg.Content,
-			&msg.Tokens, &msg.Cost, &msg.CreatedAt)
-		if err != nil {
-			return nil, 0, err
+	if parent.Valid {
+		msg.ParentMessageID = &parent.Int64
+	}
+	if artifactsJSON.Valid && artifactsJSON.String != "" {
+		if err := json.Unmarshal([]byte(artifactsJSON.String), &msg.Artifacts); err != nil {
+			return msg, err
 		}
-		messages = append(messages, msg)
 	}
+	return msg, nil
+}
 
-	if err := rows.Err(); err != nil {
+// GetMessages retrieves a page of the conversation's active branch in chronological order
+// Requirements: 1.3, 7.2
+func GetMessages(conversationID int64, page, limit int) ([]models.ChatMessage, int, error) {
+	messages, err := GetAllMessages(conversationID)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	return messages, total, nil
+	// Calculate offset
+	total := len(messages)
+	offset := (page - 1) * limit
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return messages[offset:end], total, nil
 }
 
-// GetAllMessages retrieves all messages for a conversation (for context building)
+// GetAllMessages retrieves the messages of the conversation's active branch, from the first
+// message to the current leaf (for display and context building)
 // Requirements: 2.3
 func GetAllMessages(conversationID int64) ([]models.ChatMessage, error) {
-	rows, err := db.Query(
-		`SELECT id, conversation_id, role, content, tokens, cost, created_at
-		 FROM chat_messages 
-		 WHERE conversation_id = ? 
-		 ORDER BY created_at ASC`,
-		conversationID,
-	)
+	var leaf sql.NullInt64
+	err := db.QueryRow(`SELECT current_message_id FROM chat_conversations WHERE id = ?`, conversationID).Scan(&leaf)
+	if err == sql.ErrNoRows {
+		return make([]models.ChatMessage, 0), nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	// Initialize as empty slice to ensure JSON serializes to [] instead of null
-	messages := make([]models.ChatMessage, 0)
-	for rows.Next() {
-		var msg models.ChatMessage
-		err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Role, &msg.Content,
-			&msg.Tokens, &msg.Cost, &msg.CreatedAt)
-		if err != nil {
-			return nil, err
+	all, err := listConversationMessages(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	var messages []models.ChatMessage
+	if leaf.Valid {
+		messages = messagePath(all, leaf.Int64)
+	} else {
+		// Written before branching existed: one linear history, minus regenerated-away replies
+		messages = make([]models.ChatMessage, 0, len(all))
+		for _, msg := range all {
+			if !msg.superseded {
+				messages = append(messages, msg.ChatMessage)
+			}
 		}
-		messages = append(messages, msg)
 	}
-
-	if err := rows.Err(); err != nil {
+	if err := loadChatAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := loadChatFeedback(messages); err != nil {
 		return nil, err
 	}
-
 	return messages, nil
 }
 
+// SupersedeMessage marks a reply that was replaced by a regenerated one. The row stays in the
+// message tree as an alternative branch, and its cost still counts towards the conversation total
+func SupersedeMessage(id, replacementID int64) error {
+	_, err := db.Exec(
+		`UPDATE chat_messages SET superseded_by = ? WHERE id = ? AND superseded_by IS NULL`,
+		replacementID, id,
+	)
+	return err
+}
+
+// SetMessageModel records the model and provider that generated an assistant reply
+func SetMessageModel(id int64, model, provider string) error {
+	_, err := db.Exec(`UPDATE chat_messages SET model = ?, provider = ? WHERE id = ?`, model, provider, id)
+	return err
+}
+
 // UpdateConversationTimestamp updates only the updated_at timestamp of a conversation
 func UpdateConversationTimestamp(conversationID int64) error {
 	_, err := db.Exec(
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// chatArchiveMinBytes is the smallest message (content plus artifacts) worth compressing;
+// shorter messages rarely shrink once the zstd frame header is added
+const chatArchiveMinBytes = 512
+
+// zstd coders shared by every archive and read; EncodeAll and DecodeAll are safe for concurrent use
+var (
+	chatArchiveEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	chatArchiveDecoder, _ = zstd.NewReader(nil)
+)
+
+// archivedChatMessage is the payload compressed into chat_messages.content_zstd
+type archivedChatMessage struct {
+	Content   string `json:"content"`
+	Artifacts string `json:"artifacts,omitempty"` // Raw artifacts JSON
+}
+
+// ChatArchiveResult reports what archiving a conversation saved
+type ChatArchiveResult struct {
+	Messages    int   `json:"messages"`     // Messages compressed
+	BytesBefore int64 `json:"bytes_before"` // Content and artifacts size of those messages
+	BytesAfter  int64 `json:"bytes_after"`  // Compressed size of those messages
+}
+
+// archiveChatMessage compresses a message's content and artifacts
+func archiveChatMessage(content, artifacts string) ([]byte, error) {
+	payload, err := json.Marshal(archivedChatMessage{Content: content, Artifacts: artifacts})
+	if err != nil {
+		return nil, err
+	}
+	return chatArchiveEncoder.EncodeAll(payload, nil), nil
+}
+
+// unarchiveChatMessage restores the content and raw artifacts JSON of an archived message
+func unarchiveChatMessage(data []byte) (string, string, error) {
+	payload, err := chatArchiveDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("decompress archived message: %w", err)
+	}
+	var msg archivedChatMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return "", "", fmt.Errorf("decode archived message: %w", err)
+	}
+	return msg.Content, msg.Artifacts, nil
+}
+
+// ListArchivableConversations returns up to limit conversations idle since before cutoff that
+// have messages added since they were last archived, oldest first
+func ListArchivableConversations(cutoff time.Time, limit int) ([]int64, error) {
+	rows, err := db.Query(
+		`SELECT id FROM chat_conversations
+		 WHERE updated_at < ? AND (archived_at IS NULL OR archived_at < updated_at)
+		 ORDER BY updated_at ASC, id ASC
+		 LIMIT ?`,
+		cutoff, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ArchiveConversationMessages compresses the conversation's not yet archived messages into
+// content_zstd, clearing their content and artifacts, and marks the conversation archived.
+// Small messages and messages that would not shrink are left as they are. Message metadata
+// (role, tokens, cost, branches) stays queryable; archived text is no longer found by search
+func ArchiveConversationMessages(conversationID int64) (ChatArchiveResult, error) {
+	var result ChatArchiveResult
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT id, content, artifacts FROM chat_messages
+		 WHERE conversation_id = ? AND content_zstd IS NULL
+		 FOR UPDATE`,
+		conversationID,
+	)
+	if err != nil {
+		return result, err
+	}
+	type pendingMessage struct {
+		id         int64
+		compressed []byte
+	}
+	var pending []pendingMessage
+	for rows.Next() {
+		var id int64
+		var content string
+		var artifacts sql.NullString
+		if err := rows.Scan(&id, &content, &artifacts); err != nil {
+			rows.Close()
+			return result, err
+		}
+		size := len(content) + len(artifacts.String)
+		if size < chatArchiveMinBytes {
+			continue
+		}
+		compressed, err := archiveChatMessage(content, artifacts.String)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		if len(compressed) >= size {
+			continue
+		}
+		pending = append(pending, pendingMessage{id: id, compressed: compressed})
+		result.BytesBefore += int64(size)
+		result.BytesAfter += int64(len(compressed))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	for _, m := range pending {
+		if _, err := tx.Exec(
+			`UPDATE chat_messages SET content = '', artifacts = NULL, content_zstd = ? WHERE id = ?`,
+			m.compressed, m.id,
+		); err != nil {
+			return result, err
+		}
+	}
+	// Keep updated_at so archiving does not move the conversation up the recent list
+	if _, err := tx.Exec(
+		`UPDATE chat_conversations SET archived_at = ?, updated_at = updated_at WHERE id = ?`,
+		time.Now(), conversationID,
+	); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	result.Messages = len(pending)
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"Curry2API-go/models"
+)
+
+// chatAttachmentPendingTTL is how long an uploaded attachment may wait to be sent before it is vacuumed
+const chatAttachmentPendingTTL = 24 * time.Hour
+
+// ErrChatAttachmentNotFound is returned for an attachment that does not exist, belongs to
+// another user or was already sent with a message
+var ErrChatAttachmentNotFound = errors.New("chat attachment not found")
+
+// CreateChatAttachment saves an uploaded attachment, not yet linked to a message
+func CreateChatAttachment(userID int64, a *models.ChatAttachment) error {
+	a.CreatedAt = time.Now()
+	_, err := db.Exec(
+		`INSERT INTO chat_attachments (id, user_id, filename, content_type, bytes, text, chars, truncated, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, userID, a.Filename, a.ContentType, a.Bytes, a.Text, a.Chars, a.Truncated, a.CreatedAt,
+	)
+	return err
+}
+
+// sqlPlaceholders returns "?, ?, ..." for n query arguments
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// CountPendingChatAttachments returns how many of the given attachments belong to the user
+// and have not been sent yet
+func CountPendingChatAttachments(userID int64, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := []interface{}{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM chat_attachments
+		 WHERE user_id = ? AND message_id IS NULL AND id IN (`+sqlPlaceholders(len(ids))+`)`,
+		args...,
+	).Scan(&count)
+	return count, err
+}
+
+// AttachChatAttachments links the user's pending attachments to a message. Either all of
+// them are linked or, when one was sent meanwhile, none is and ErrChatAttachmentNotFound is returned
+func AttachChatAttachments(messageID, userID int64, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{messageID, userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	result, err := tx.Exec(
+		`UPDATE chat_attachments SET message_id = ?
+		 WHERE user_id = ? AND message_id IS NULL AND id IN (`+sqlPlaceholders(len(ids))+`)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n != int64(len(ids)) {
+		return ErrChatAttachmentNotFound
+	}
+	return tx.Commit()
+}
+
+// DeletePendingChatAttachment deletes an uploaded attachment the user has not sent
+func DeletePendingChatAttachment(id string, userID int64) error {
+	result, err := db.Exec(
+		`DELETE FROM chat_attachments WHERE id = ? AND user_id = ? AND message_id IS NULL`,
+		id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrChatAttachmentNotFound
+	}
+	return nil
+}
+
+// loadChatAttachments fills in the attachments of the given messages, with their text
+func loadChatAttachments(messages []models.ChatMessage) error {
+	byID := make(map[int64]*models.ChatMessage)
+	args := make([]interface{}, 0)
+	for i := range messages {
+		if messages[i].Role == "user" {
+			byID[messages[i].ID] = &messages[i]
+			args = append(args, messages[i].ID)
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(
+		`SELECT message_id, id, filename, content_type, bytes, chars, truncated, text, created_at
+		 FROM chat_attachments
+		 WHERE message_id IN (`+sqlPlaceholders(len(args))+`)
+		 ORDER BY created_at ASC, id ASC`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int64
+		var a models.ChatAttachment
+		if err := rows.Scan(&messageID, &a.ID, &a.Filename, &a.ContentType, &a.Bytes, &a.Chars, &a.Truncated, &a.Text, &a.CreatedAt); err != nil {
+			return err
+		}
+		if msg := byID[messageID]; msg != nil {
+			msg.Attachments = append(msg.Attachments, a)
+		}
+	}
+	return rows.Err()
+}
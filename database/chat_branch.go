@@ -0,0 +1,251 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"Curry2API-go/models"
+)
+
+// branchPreviewLength is how many characters of a branch's first diverging message are previewed
+const branchPreviewLength = 80
+
+// ConversationBranch is one path through a conversation's message tree, from the first
+// message to a leaf
+type ConversationBranch struct {
+	LeafMessageID int64     `json:"leaf_message_id"`
+	ForkMessageID int64     `json:"fork_message_id"` // First message of the branch that its alternatives don't share
+	Preview       string    `json:"preview"`         // Start of the fork message
+	MessageCount  int       `json:"message_count"`
+	Active        bool      `json:"active"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// treeMessage is a stored message with whether a regenerated reply replaced it
+type treeMessage struct {
+	models.ChatMessage
+	superseded bool
+}
+
+// listConversationMessages loads every message of the conversation, across all branches, oldest first
+func listConversationMessages(conversationID int64) ([]treeMessage, error) {
+	rows, err := db.Query(
+		`SELECT `+chatMessageColumns+`, superseded_by IS NOT NULL
+		 FROM chat_messages
+		 WHERE conversation_id = ?
+		 ORDER BY created_at ASC, id ASC`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []treeMessage
+	for rows.Next() {
+		var msg treeMessage
+		if msg.ChatMessage, err = scanChatMessage(rows, &msg.superseded); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// messagePath returns the messages from the first message to leaf, following parent links
+func messagePath(all []treeMessage, leaf int64) []models.ChatMessage {
+	byID := make(map[int64]*models.ChatMessage, len(all))
+	for i := range all {
+		byID[all[i].ID] = &all[i].ChatMessage
+	}
+	path := make([]models.ChatMessage, 0)
+	for msg := byID[leaf]; msg != nil; {
+		path = append(path, *msg)
+		if msg.ParentMessageID == nil {
+			break
+		}
+		msg = byID[*msg.ParentMessageID]
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// lockConversationLeaf locks the conversation row and returns the leaf of its active branch
+// (0 when it has no messages). Conversations written before branching existed get their parent
+// links filled in from the linear history first
+func lockConversationLeaf(tx *sql.Tx, conversationID int64) (int64, error) {
+	var leaf sql.NullInt64
+	err := tx.QueryRow(
+		`SELECT current_message_id FROM chat_conversations WHERE id = ? FOR UPDATE`,
+		conversationID,
+	).Scan(&leaf)
+	if err == sql.ErrNoRows {
+		return 0, ErrConversationNotFound
+	}
+	if err != nil || leaf.Valid {
+		return leaf.Int64, err
+	}
+
+	rows, err := tx.Query(
+		`SELECT id, superseded_by IS NOT NULL FROM chat_messages
+		 WHERE conversation_id = ? ORDER BY created_at ASC, id ASC`,
+		conversationID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type linearMessage struct {
+		id         int64
+		superseded bool
+	}
+	var history []linearMessage
+	for rows.Next() {
+		var m linearMessage
+		if err := rows.Scan(&m.id, &m.superseded); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		history = append(history, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Each message follows the latest message still in the history when it was written;
+	// a regenerated-away reply and its replacement both follow the same user message
+	var last int64
+	for _, m := range history {
+		if last != 0 {
+			if _, err := tx.Exec(`UPDATE chat_messages SET parent_message_id = ? WHERE id = ?`, last, m.id); err != nil {
+				return 0, err
+			}
+		}
+		if !m.superseded {
+			last = m.id
+		}
+	}
+	if last != 0 {
+		if _, err := tx.Exec(`UPDATE chat_conversations SET current_message_id = ? WHERE id = ?`, last, conversationID); err != nil {
+			return 0, err
+		}
+	}
+	return last, nil
+}
+
+// ensureMessageTree builds the message tree of a conversation written before branching existed
+// and returns the leaf of its active branch
+func ensureMessageTree(conversationID int64) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	leaf, err := lockConversationLeaf(tx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	return leaf, tx.Commit()
+}
+
+// ListConversationBranches lists every branch of the conversation (one per leaf message),
+// oldest first, marking the active one
+func ListConversationBranches(conversationID int64) ([]ConversationBranch, error) {
+	current, err := ensureMessageTree(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := listConversationMessages(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Children per parent; 0 stands for the start of the conversation
+	children := make(map[int64]int, len(all))
+	for _, msg := range all {
+		children[parentOf(msg.ChatMessage)]++
+	}
+
+	branches := make([]ConversationBranch, 0)
+	for _, leaf := range all {
+		if children[leaf.ID] > 0 {
+			continue
+		}
+		path := messagePath(all, leaf.ID)
+		if len(path) == 0 {
+			continue
+		}
+		// The fork is where this branch most recently split from an alternative
+		fork := path[0]
+		for _, msg := range path[1:] {
+			if children[parentOf(msg)] > 1 {
+				fork = msg
+			}
+		}
+		branches = append(branches, ConversationBranch{
+			LeafMessageID: leaf.ID,
+			ForkMessageID: fork.ID,
+			Preview:       truncateRunes(fork.Content, branchPreviewLength),
+			MessageCount:  len(path),
+			Active:        leaf.ID == current,
+			UpdatedAt:     leaf.CreatedAt,
+		})
+	}
+	sort.SliceStable(branches, func(i, j int) bool { return branches[i].LeafMessageID < branches[j].LeafMessageID })
+	return branches, nil
+}
+
+// SwitchConversationBranch makes the branch through messageID active, continuing from it to
+// its most recent descendant, and returns the new leaf
+func SwitchConversationBranch(conversationID, messageID int64) (int64, error) {
+	if _, err := ensureMessageTree(conversationID); err != nil {
+		return 0, err
+	}
+	all, err := listConversationMessages(conversationID)
+	if err != nil {
+		return 0, err
+	}
+
+	latestChild := make(map[int64]int64, len(all))
+	found := false
+	for _, msg := range all {
+		if msg.ID == messageID {
+			found = true
+		}
+		// Messages are oldest first, so the last child seen is the most recent
+		latestChild[parentOf(msg.ChatMessage)] = msg.ID
+	}
+	if !found {
+		return 0, ErrMessageNotFound
+	}
+
+	leaf := messageID
+	for next, ok := latestChild[leaf]; ok; next, ok = latestChild[leaf] {
+		leaf = next
+	}
+	_, err = db.Exec(
+		`UPDATE chat_conversations SET current_message_id = ?, updated_at = ? WHERE id = ?`,
+		leaf, time.Now(), conversationID,
+	)
+	return leaf, err
+}
+
+// parentOf returns the parent message ID, 0 for the first message of a branch
+func parentOf(msg models.ChatMessage) int64 {
+	if msg.ParentMessageID == nil {
+		return 0
+	}
+	return *msg.ParentMessageID
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"Curry2API-go/models"
+)
+
+// MaxFeedbackCommentLength is the number of characters kept of a feedback comment, matching chat_message_feedback.comment
+const MaxFeedbackCommentLength = 1000
+
+// ErrFeedbackNotAllowed is returned when rating a message that is not one of the user's assistant replies
+var ErrFeedbackNotAllowed = errors.New("only assistant replies in your conversations can be rated")
+
+// feedbackRatings maps the API ratings to the values stored in chat_message_feedback.rating
+var feedbackRatings = map[string]int{"up": 1, "down": -1}
+
+// IsValidFeedbackRating reports whether rating is "up" or "down"
+func IsValidFeedbackRating(rating string) bool {
+	_, ok := feedbackRatings[rating]
+	return ok
+}
+
+// feedbackRatingName returns the API rating of a stored value
+func feedbackRatingName(value int) string {
+	if value > 0 {
+		return "up"
+	}
+	return "down"
+}
+
+// FeedbackTarget is the assistant reply a user rates, with the route that produced it
+type FeedbackTarget struct {
+	MessageID int64
+	Model     string // Empty for replies saved before the model was recorded
+	Provider  string // Empty for replies saved before the provider was recorded
+	ConvModel string // Model of the conversation, the fallback for older replies
+}
+
+// GetFeedbackTarget returns one of the user's assistant replies for rating
+func GetFeedbackTarget(messageID, userID int64) (*FeedbackTarget, error) {
+	var t FeedbackTarget
+	var model, provider sql.NullString
+	err := db.QueryRow(
+		`SELECT m.id, m.model, m.provider, c.model
+		 FROM chat_messages m
+		 JOIN chat_conversations c ON c.id = m.conversation_id
+		 WHERE m.id = ? AND c.user_id = ? AND m.role = 'assistant'`,
+		messageID, userID,
+	).Scan(&t.MessageID, &model, &provider, &t.ConvModel)
+	if err == sql.ErrNoRows {
+		return nil, ErrFeedbackNotAllowed
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Model = model.String
+	t.Provider = provider.String
+	return &t, nil
+}
+
+// SetMessageFeedback records or replaces the user's rating of a reply produced by model and provider
+func SetMessageFeedback(messageID, userID int64, rating, comment, model, provider string) (*models.ChatFeedback, error) {
+	now := time.Now()
+	_, err := db.Exec(
+		`INSERT INTO chat_message_feedback (message_id, user_id, rating, comment, model, provider, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE rating = VALUES(rating), comment = VALUES(comment), updated_at = VALUES(updated_at)`,
+		messageID, userID, feedbackRatings[rating], comment, model, provider, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ChatFeedback{Rating: rating, Comment: comment, UpdatedAt: now}, nil
+}
+
+// DeleteMessageFeedback removes the user's rating of a reply; removing a missing rating is not an error
+func DeleteMessageFeedback(messageID, userID int64) error {
+	_, err := db.Exec(`DELETE FROM chat_message_feedback WHERE message_id = ? AND user_id = ?`, messageID, userID)
+	return err
+}
+
+// loadChatFeedback fills in the ratings of the given assistant replies
+func loadChatFeedback(messages []models.ChatMessage) error {
+	byID := make(map[int64]*models.ChatMessage)
+	args := make([]interface{}, 0)
+	for i := range messages {
+		if messages[i].Role == "assistant" {
+			byID[messages[i].ID] = &messages[i]
+			args = append(args, messages[i].ID)
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(
+		`SELECT message_id, rating, comment, updated_at FROM chat_message_feedback
+		 WHERE message_id IN (`+sqlPlaceholders(len(args))+`)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int64
+		var rating int
+		var f models.ChatFeedback
+		if err := rows.Scan(&messageID, &rating, &f.Comment, &f.UpdatedAt); err != nil {
+			return err
+		}
+		f.Rating = feedbackRatingName(rating)
+		if msg := byID[messageID]; msg != nil {
+			msg.Feedback = &f
+		}
+	}
+	return rows.Err()
+}
+
+// FeedbackSummary aggregates the ratings of the replies one model produced through one provider
+type FeedbackSummary struct {
+	Model        string  `json:"model"`
+	Provider     string  `json:"provider"`
+	Total        int64   `json:"total"`
+	Up           int64   `json:"up"`
+	Down         int64   `json:"down"`
+	Comments     int64   `json:"comments"`     // Ratings with a comment
+	Satisfaction float64 `json:"satisfaction"` // Share of thumbs up, 0-1
+}
+
+// GetFeedbackSummary aggregates the ratings given since the cutoff by model and provider,
+// most rated first. model filters to one model when not empty
+func GetFeedbackSummary(since time.Time, model string) ([]FeedbackSummary, error) {
+	query := `SELECT model, provider, COUNT(*), SUM(rating > 0), SUM(rating < 0), SUM(comment <> '')
+		 FROM chat_message_feedback
+		 WHERE updated_at >= ?`
+	args := []interface{}{since}
+	if model != "" {
+		query += ` AND model = ?`
+		args = append(args, model)
+	}
+	query += ` GROUP BY model, provider ORDER BY COUNT(*) DESC, model, provider`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]FeedbackSummary, 0)
+	for rows.Next() {
+		var s FeedbackSummary
+		if err := rows.Scan(&s.Model, &s.Provider, &s.Total, &s.Up, &s.Down, &s.Comments); err != nil {
+			return nil, err
+		}
+		if s.Total > 0 {
+			s.Satisfaction = float64(s.Up) / float64(s.Total)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// FeedbackComment is a commented rating, for reviewing what users disliked
+type FeedbackComment struct {
+	MessageID int64     `json:"message_id"`
+	UserID    int64     `json:"user_id"`
+	Rating    string    `json:"rating"`
+	Comment   string    `json:"comment"`
+	Model     string    `json:"model"`
+	Provider  string    `json:"provider"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListFeedbackComments returns the newest commented ratings since the cutoff, optionally for
+// one model and one rating ("up" or "down")
+func ListFeedbackComments(since time.Time, model, rating string, limit int) ([]FeedbackComment, error) {
+	query := `SELECT message_id, user_id, rating, comment, model, provider, updated_at
+		 FROM chat_message_feedback
+		 WHERE updated_at >= ? AND comment <> ''`
+	args := []interface{}{since}
+	if model != "" {
+		query += ` AND model = ?`
+		args = append(args, model)
+	}
+	if value, ok := feedbackRatings[rating]; ok {
+		query += ` AND rating = ?`
+		args = append(args, value)
+	}
+	query += ` ORDER BY updated_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]FeedbackComment, 0)
+	for rows.Next() {
+		var c FeedbackComment
+		var value int
+		if err := rows.Scan(&c.MessageID, &c.UserID, &value, &c.Comment, &c.Model, &c.Provider, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		c.Rating = feedbackRatingName(value)
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Curry2API-go/models"
+)
+
+// Folder and tag limits
+const (
+	MaxChatFolderNameLength = 100 // Characters, matches chat_folders.name
+	MaxChatTagLength        = 50  // Characters, matches chat_conversation_tags.tag
+	MaxConversationTags     = 20  // Tags per conversation
+)
+
+// Folder errors
+var (
+	ErrChatFolderNotFound = errors.New("folder not found")
+	ErrChatFolderExists   = errors.New("folder name already exists")
+)
+
+// ChatFolder is a user's folder of conversations
+type ChatFolder struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	ConversationCount int       `json:"conversation_count"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// ChatTag is a tag in use on a user's conversations
+type ChatTag struct {
+	Tag               string `json:"tag"`
+	ConversationCount int    `json:"conversation_count"`
+}
+
+// NormalizeConversationTags trims tags, drops empty and duplicate ones (case-insensitively,
+// like the column collation) and sorts the rest
+func NormalizeConversationTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(tag), " ")
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxChatTagLength {
+			return nil, fmt.Errorf("tags must be at most %d characters", MaxChatTagLength)
+		}
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxConversationTags {
+		return nil, fmt.Errorf("a conversation can have at most %d tags", MaxConversationTags)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ListChatFolders lists the user's folders by name, with the number of conversations in each
+func ListChatFolders(userID int64) ([]ChatFolder, error) {
+	rows, err := db.Query(
+		`SELECT f.id, f.name, COUNT(c.id), f.created_at, f.updated_at
+		 FROM chat_folders f
+		 LEFT JOIN chat_conversations c ON c.folder_id = f.id
+		 WHERE f.user_id = ?
+		 GROUP BY f.id, f.name, f.created_at, f.updated_at
+		 ORDER BY f.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := make([]ChatFolder, 0)
+	for rows.Next() {
+		var f ChatFolder
+		if err := rows.Scan(&f.ID, &f.Name, &f.ConversationCount, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// CreateChatFolder creates an empty folder for the user
+func CreateChatFolder(userID int64, name string) (*ChatFolder, error) {
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO chat_folders (user_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		userID, name, now, now,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return nil, ErrChatFolderExists
+		}
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &ChatFolder{ID: id, Name: name, CreatedAt: now, UpdatedAt: now}, nil
+}
+
+// chatFolderBelongsToUser checks if a folder belongs to a user
+func chatFolderBelongsToUser(id, userID int64) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM chat_folders WHERE id = ? AND user_id = ?`,
+		id, userID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// RenameChatFolder renames one of the user's folders
+func RenameChatFolder(id, userID int64, name string) error {
+	result, err := db.Exec(
+		`UPDATE chat_folders SET name = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+		name, time.Now(), id, userID,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return ErrChatFolderExists
+		}
+		return err
+	}
+	// Renaming to the same name within the same second changes no rows
+	if n, _ := result.RowsAffected(); n == 0 {
+		exists, err := chatFolderBelongsToUser(id, userID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrChatFolderNotFound
+		}
+	}
+	return nil
+}
+
+// DeleteChatFolder deletes one of the user's folders; its conversations are kept, unfiled
+func DeleteChatFolder(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Unfile explicitly rather than through ON DELETE SET NULL so updated_at is preserved
+	if _, err := tx.Exec(
+		`UPDATE chat_conversations SET folder_id = NULL, updated_at = updated_at WHERE folder_id = ? AND user_id = ?`,
+		id, userID,
+	); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM chat_folders WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrChatFolderNotFound
+	}
+	return tx.Commit()
+}
+
+// MoveConversationToFolder files the user's conversation in a folder, or unfiles it when folderID
+// is nil. The conversation keeps its place in the recent list
+func MoveConversationToFolder(conversationID, userID int64, folderID *int64) error {
+	if folderID != nil {
+		exists, err := chatFolderBelongsToUser(*folderID, userID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrChatFolderNotFound
+		}
+	}
+
+	_, err := db.Exec(
+		`UPDATE chat_conversations SET folder_id = ?, updated_at = updated_at WHERE id = ? AND user_id = ?`,
+		folderID, conversationID, userID,
+	)
+	return err
+}
+
+// ListChatTags lists the tags on the user's conversations by name, with the number of
+// conversations carrying each
+func ListChatTags(userID int64) ([]ChatTag, error) {
+	rows, err := db.Query(
+		`SELECT tag, COUNT(*) FROM chat_conversation_tags WHERE user_id = ? GROUP BY tag ORDER BY tag`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]ChatTag, 0)
+	for rows.Next() {
+		var t ChatTag
+		if err := rows.Scan(&t.Tag, &t.ConversationCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// GetConversationTags returns the conversation's tags, sorted
+func GetConversationTags(conversationID int64) ([]string, error) {
+	rows, err := db.Query(
+		`SELECT tag FROM chat_conversation_tags WHERE conversation_id = ? ORDER BY tag`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SetConversationTags replaces the tags of the user's conversation with already normalized tags
+func SetConversationTags(conversationID, userID int64, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chat_conversation_tags WHERE conversation_id = ?`, conversationID); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, tag := range tags {
+		if _, err := tx.Exec(
+			`INSERT INTO chat_conversation_tags (conversation_id, user_id, tag, created_at) VALUES (?, ?, ?, ?)`,
+			conversationID, userID, tag, now,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// attachConversationTags loads the tags of a page of conversations in one query
+func attachConversationTags(conversations []models.Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]*models.Conversation, len(conversations))
+	placeholders := make([]string, len(conversations))
+	args := make([]interface{}, len(conversations))
+	for i := range conversations {
+		index[conversations[i].ID] = &conversations[i]
+		placeholders[i] = "?"
+		args[i] = conversations[i].ID
+	}
+
+	rows, err := db.Query(
+		`SELECT conversation_id, tag FROM chat_conversation_tags
+		 WHERE conversation_id IN (`+strings.Join(placeholders, ", ")+`)
+		 ORDER BY conversation_id, tag`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var convID int64
+		var tag string
+		if err := rows.Scan(&convID, &tag); err != nil {
+			return err
+		}
+		if conv := index[convID]; conv != nil {
+			conv.Tags = append(conv.Tags, tag)
+		}
+	}
+	return rows.Err()
+}
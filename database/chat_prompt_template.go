@@ -0,0 +1,185 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Prompt template limits
+const (
+	MaxPromptTemplateNameLength        = 100   // Characters, matches chat_prompt_templates.name
+	MaxPromptTemplateDescriptionLength = 255   // Characters, matches chat_prompt_templates.description
+	MaxSystemPromptLength              = 20000 // Characters of a system prompt or template content
+)
+
+// ErrPromptTemplateNotFound is returned for a template that does not exist or is not visible to the user
+var ErrPromptTemplateNotFound = errors.New("prompt template not found")
+
+// PromptTemplate is a reusable system prompt, owned by a user or published globally by an admin
+type PromptTemplate struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Content     string    `json:"content"`
+	Global      bool      `json:"global"` // Published by an admin for every user; read-only for users
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// promptTemplateColumns are the chat_prompt_templates columns read by scanPromptTemplate
+const promptTemplateColumns = `id, name, description, content, user_id IS NULL, created_at, updated_at`
+
+// scanPromptTemplate scans a row selected with promptTemplateColumns
+func scanPromptTemplate(row interface{ Scan(...interface{}) error }) (PromptTemplate, error) {
+	var t PromptTemplate
+	err := row.Scan(&t.ID, &t.Name, &t.Description, &t.Content, &t.Global, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// promptTemplateOwner returns the WHERE clause and argument selecting the owner's templates;
+// a nil owner selects the global ones
+func promptTemplateOwner(ownerID *int64) (string, []interface{}) {
+	if ownerID == nil {
+		return `user_id IS NULL`, nil
+	}
+	return `user_id = ?`, []interface{}{*ownerID}
+}
+
+// ListPromptTemplates lists the templates visible to the user, global ones first, then by name
+func ListPromptTemplates(userID int64) ([]PromptTemplate, error) {
+	return queryPromptTemplates(
+		`SELECT `+promptTemplateColumns+` FROM chat_prompt_templates
+		 WHERE user_id IS NULL OR user_id = ?
+		 ORDER BY user_id IS NULL DESC, name, id`,
+		userID,
+	)
+}
+
+// ListGlobalPromptTemplates lists the admin-published templates by name
+func ListGlobalPromptTemplates() ([]PromptTemplate, error) {
+	return queryPromptTemplates(
+		`SELECT ` + promptTemplateColumns + ` FROM chat_prompt_templates WHERE user_id IS NULL ORDER BY name, id`,
+	)
+}
+
+// queryPromptTemplates runs a query selecting promptTemplateColumns
+func queryPromptTemplates(query string, args ...interface{}) ([]PromptTemplate, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]PromptTemplate, 0)
+	for rows.Next() {
+		t, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// GetPromptTemplate returns a template visible to the user: one of their own or a global one
+func GetPromptTemplate(id, userID int64) (*PromptTemplate, error) {
+	t, err := scanPromptTemplate(db.QueryRow(
+		`SELECT `+promptTemplateColumns+` FROM chat_prompt_templates
+		 WHERE id = ? AND (user_id IS NULL OR user_id = ?)`,
+		id, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromptTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreatePromptTemplate creates a template owned by the user, or a global one when ownerID is nil
+func CreatePromptTemplate(ownerID *int64, name, description, content string) (*PromptTemplate, error) {
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO chat_prompt_templates (user_id, name, description, content, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		ownerID, name, description, content, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &PromptTemplate{
+		ID:          id,
+		Name:        name,
+		Description: description,
+		Content:     content,
+		Global:      ownerID == nil,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// UpdatePromptTemplate replaces one of the owner's templates (a global one when ownerID is nil).
+// Conversations already started from it keep the prompt they copied
+func UpdatePromptTemplate(id int64, ownerID *int64, name, description, content string) (*PromptTemplate, error) {
+	owner, args := promptTemplateOwner(ownerID)
+	if _, err := db.Exec(
+		`UPDATE chat_prompt_templates SET name = ?, description = ?, content = ?, updated_at = ?
+		 WHERE id = ? AND `+owner,
+		append([]interface{}{name, description, content, time.Now(), id}, args...)...,
+	); err != nil {
+		return nil, err
+	}
+
+	t, err := scanPromptTemplate(db.QueryRow(
+		`SELECT `+promptTemplateColumns+` FROM chat_prompt_templates WHERE id = ? AND `+owner,
+		append([]interface{}{id}, args...)...,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromptTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeletePromptTemplate deletes one of the owner's templates (a global one when ownerID is nil).
+// Conversations started from it keep their system prompt
+func DeletePromptTemplate(id int64, ownerID *int64) error {
+	owner, args := promptTemplateOwner(ownerID)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found int64
+	err = tx.QueryRow(
+		`SELECT id FROM chat_prompt_templates WHERE id = ? AND `+owner+` FOR UPDATE`,
+		append([]interface{}{id}, args...)...,
+	).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrPromptTemplateNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Unlink explicitly rather than through ON DELETE SET NULL so updated_at is preserved
+	if _, err := tx.Exec(
+		`UPDATE chat_conversations SET prompt_template_id = NULL, updated_at = updated_at WHERE prompt_template_id = ?`,
+		id,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_prompt_templates WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
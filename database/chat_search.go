@@ -0,0 +1,245 @@
+package database
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	chatSearchMaxTerms      = 8  // Words of a query that are matched, the rest are ignored
+	chatSearchMinTermLength = 2  // Shorter words are ignored, the ngram parser indexes 2-character tokens
+	chatSearchSnippets      = 3  // Matching messages returned per conversation
+	chatSearchSnippetRadius = 60 // Characters of context kept on each side of the first match
+)
+
+// chatFulltextUnavailable is set once a search fails because the FULLTEXT index is missing
+// (e.g. MariaDB without the ngram parser); searches then fall back to LIKE
+var chatFulltextUnavailable atomic.Bool
+
+// ChatSearchSnippet is a message matching a search, with the text around the first match
+type ChatSearchSnippet struct {
+	MessageID int64     `json:"message_id"`
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChatSearchResult is a conversation with messages matching a search
+type ChatSearchResult struct {
+	ConversationID int64               `json:"conversation_id"`
+	Title          string              `json:"title"`
+	Model          string              `json:"model"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	MatchCount     int                 `json:"match_count"`
+	Messages       []ChatSearchSnippet `json:"messages"` // Latest matching messages, at most chatSearchSnippets
+}
+
+// ChatSearchTerms splits a search query into the words that are matched
+func ChatSearchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.Trim(word, `"`)
+		if utf8.RuneCountInString(word) < chatSearchMinTermLength {
+			continue
+		}
+		terms = append(terms, word)
+		if len(terms) == chatSearchMaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// chatSearchPredicate builds the condition matching messages that contain every term,
+// with MATCH ... AGAINST when the FULLTEXT index is available and LIKE otherwise
+func chatSearchPredicate(terms []string, fulltext bool) (string, []interface{}) {
+	if fulltext {
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `+"` + strings.ReplaceAll(term, `"`, "") + `"`
+		}
+		return "MATCH(m.content) AGAINST (? IN BOOLEAN MODE)", []interface{}{strings.Join(quoted, " ")}
+	}
+	conditions := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for i, term := range terms {
+		conditions[i] = "m.content LIKE ?"
+		args[i] = "%" + escaper.Replace(term) + "%"
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// isMissingFulltextIndexError checks if MySQL could not use a FULLTEXT index for MATCH
+func isMissingFulltextIndexError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "1191") || strings.Contains(err.Error(), "Can't find FULLTEXT index"))
+}
+
+// SearchChatMessages finds the user's conversations with messages containing every term,
+// newest conversation first, and returns one page of them with the total count. Replies
+// replaced by regeneration are not searched
+func SearchChatMessages(userID int64, terms []string, page, limit int) ([]ChatSearchResult, int, error) {
+	results := make([]ChatSearchResult, 0)
+	if len(terms) == 0 {
+		return results, 0, nil
+	}
+	offset := (page - 1) * limit
+	if offset < 0 {
+		offset = 0
+	}
+
+	fulltext := !chatFulltextUnavailable.Load()
+	total, err := countChatSearchMatches(userID, terms, fulltext)
+	if fulltext && isMissingFulltextIndexError(err) {
+		dbLog.WithError(err).Warn("Chat message FULLTEXT index unavailable, searching with LIKE")
+		chatFulltextUnavailable.Store(true)
+		fulltext = false
+		total, err = countChatSearchMatches(userID, terms, fulltext)
+	}
+	if err != nil || total == 0 {
+		return results, total, err
+	}
+
+	predicate, args := chatSearchPredicate(terms, fulltext)
+	rows, err := db.Query(
+		`SELECT c.id, c.title, c.model, c.updated_at, COUNT(*)
+		 FROM chat_messages m JOIN chat_conversations c ON c.id = m.conversation_id
+		 WHERE c.user_id = ? AND m.role <> 'system' AND m.superseded_by IS NULL AND `+predicate+`
+		 GROUP BY c.id, c.title, c.model, c.updated_at
+		 ORDER BY c.updated_at DESC, c.id DESC
+		 LIMIT ? OFFSET ?`,
+		append(append([]interface{}{userID}, args...), limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[int64]int)
+	for rows.Next() {
+		var r ChatSearchResult
+		if err := rows.Scan(&r.ConversationID, &r.Title, &r.Model, &r.UpdatedAt, &r.MatchCount); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		r.Messages = []ChatSearchSnippet{}
+		byID[r.ConversationID] = len(results)
+		results = append(results, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(results) == 0 {
+		return results, total, nil
+	}
+
+	ids := make([]interface{}, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ConversationID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err = db.Query(
+		`SELECT m.id, m.conversation_id, m.role, m.content, m.created_at
+		 FROM chat_messages m
+		 WHERE m.conversation_id IN (`+placeholders+`) AND m.role <> 'system' AND m.superseded_by IS NULL AND `+predicate+`
+		 ORDER BY m.created_at DESC, m.id DESC`,
+		append(ids, args...)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s ChatSearchSnippet
+		var convID int64
+		var content string
+		if err := rows.Scan(&s.MessageID, &convID, &s.Role, &content, &s.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		r := &results[byID[convID]]
+		if len(r.Messages) < chatSearchSnippets {
+			s.Snippet = chatSearchSnippet(content, terms)
+			r.Messages = append(r.Messages, s)
+		}
+	}
+	return results, total, rows.Err()
+}
+
+// countChatSearchMatches counts the user's conversations with a matching message
+func countChatSearchMatches(userID int64, terms []string, fulltext bool) (int, error) {
+	predicate, args := chatSearchPredicate(terms, fulltext)
+	var total int
+	err := db.QueryRow(
+		`SELECT COUNT(DISTINCT m.conversation_id)
+		 FROM chat_messages m JOIN chat_conversations c ON c.id = m.conversation_id
+		 WHERE c.user_id = ? AND m.role <> 'system' AND m.superseded_by IS NULL AND `+predicate,
+		append([]interface{}{userID}, args...)...,
+	).Scan(&total)
+	return total, err
+}
+
+// chatSearchSnippet returns the text around the first case-insensitive match of any term,
+// or the start of content when no term is found verbatim (e.g. a FULLTEXT match on a
+// different form of the word)
+func chatSearchSnippet(content string, terms []string) string {
+	text := []rune(strings.Join(strings.Fields(content), " "))
+	lower := lowerRunes(text)
+
+	start, end := 0, 0
+	for _, term := range terms {
+		needle := lowerRunes([]rune(term))
+		if i := indexRunes(lower, needle); i >= 0 && (end == 0 || i < start) {
+			start, end = i, i+len(needle)
+		}
+	}
+
+	from, to := start-chatSearchSnippetRadius, end+chatSearchSnippetRadius
+	if end == 0 {
+		from, to = 0, 2*chatSearchSnippetRadius
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	snippet := string(text[from:to])
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// lowerRunes lowercases each rune, keeping positions aligned with the input
+func lowerRunes(s []rune) []rune {
+	lower := make([]rune, len(s))
+	for i, r := range s {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
+
+// indexRunes returns the index of the first occurrence of needle in s, or -1
+func indexRunes(s, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(s); i++ {
+		match := true
+		for j, r := range needle {
+			if s[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
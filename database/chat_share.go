@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"Curry2API-go/models"
+)
+
+// ErrEmptyConversation is returned when sharing a conversation without messages
+var ErrEmptyConversation = errors.New("conversation has no messages")
+
+// ChatShare is a public, read-only link to a conversation. It shows the branch that was
+// active when the link was created, up to LeafMessageID; later messages are not shared
+type ChatShare struct {
+	ID             int64      `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	UserID         int64      `json:"-"`
+	TokenID        string     `json:"token_id"` // ID of the signed token in the link
+	LeafMessageID  int64      `json:"leaf_message_id"`
+	ViewCount      int        `json:"view_count"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const chatShareColumns = `id, conversation_id, user_id, token_id, leaf_message_id, view_count, expires_at, revoked_at, created_at`
+
+// scanChatShare scans a row selected with chatShareColumns
+func scanChatShare(row interface{ Scan(...interface{}) error }) (*ChatShare, error) {
+	var s ChatShare
+	var revokedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.ConversationID, &s.UserID, &s.TokenID, &s.LeafMessageID,
+		&s.ViewCount, &s.ExpiresAt, &revokedAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+// CreateChatShare records a share of the conversation's active branch as it is now,
+// for the signed token tokenID
+func CreateChatShare(conversationID, userID int64, tokenID string, expiresAt time.Time) (*ChatShare, error) {
+	leaf, err := ensureMessageTree(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if leaf == 0 {
+		return nil, ErrEmptyConversation
+	}
+
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO chat_shares (conversation_id, user_id, token_id, leaf_message_id, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		conversationID, userID, tokenID, leaf, expiresAt, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &ChatShare{
+		ID:             id,
+		ConversationID: conversationID,
+		UserID:         userID,
+		TokenID:        tokenID,
+		LeafMessageID:  leaf,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now,
+	}, nil
+}
+
+// ListChatShares lists the conversation's share links, newest first
+func ListChatShares(conversationID int64) ([]ChatShare, error) {
+	rows, err := db.Query(
+		`SELECT `+chatShareColumns+` FROM chat_shares WHERE conversation_id = ? ORDER BY created_at DESC, id DESC`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]ChatShare, 0)
+	for rows.Next() {
+		s, err := scanChatShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *s)
+	}
+	return shares, rows.Err()
+}
+
+// GetChatShare retrieves one of the user's shares, or nil when it doesn't exist
+func GetChatShare(id, userID int64) (*ChatShare, error) {
+	s, err := scanChatShare(db.QueryRow(
+		`SELECT `+chatShareColumns+` FROM chat_shares WHERE id = ? AND user_id = ?`,
+		id, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// GetChatShareByTokenID retrieves the share a signed token was issued for, or nil
+func GetChatShareByTokenID(tokenID string) (*ChatShare, error) {
+	s, err := scanChatShare(db.QueryRow(
+		`SELECT `+chatShareColumns+` FROM chat_shares WHERE token_id = ?`,
+		tokenID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// RevokeChatShare marks a share revoked; its link stops working
+func RevokeChatShare(id int64) error {
+	_, err := db.Exec(
+		`UPDATE chat_shares SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		time.Now(), id,
+	)
+	return err
+}
+
+// RecordChatShareView counts one view of a shared conversation
+func RecordChatShareView(id int64) error {
+	_, err := db.Exec(`UPDATE chat_shares SET view_count = view_count + 1 WHERE id = ?`, id)
+	return err
+}
+
+// GetSharedMessages returns the messages of a shared branch, from the first message to leaf
+func GetSharedMessages(conversationID, leaf int64) ([]models.ChatMessage, error) {
+	all, err := listConversationMessages(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return messagePath(all, leaf), nil
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// SettingKeyCheckin 每日签到奖励配置（JSON）
+const SettingKeyCheckin = "checkin_rewards"
+
+// 签到奖励发放方式
+const (
+	CheckinRewardBalance  = "balance"   // 账户余额（USD）
+	CheckinRewardGameCoin = "game_coin" // 游戏币
+)
+
+// checkinDateLayout 签到日期以 UTC 日期字符串读写，避免连接时区导致日期偏移
+const checkinDateLayout = "2006-01-02"
+
+var (
+	ErrCheckinDisabled    = errors.New("check-in rewards are disabled")
+	ErrAlreadyCheckedIn   = errors.New("already checked in today")
+	ErrCheckinIPLimit     = errors.New("too many check-ins from this IP today")
+	ErrCheckinDeviceLimit = errors.New("too many check-ins from this device today")
+)
+
+// CheckinConfig 签到奖励配置：基础奖励 + 连续签到加成（有上限）+ 每满 7 天的周奖励
+type CheckinConfig struct {
+	Enabled            bool    `json:"enabled"`
+	RewardType         string  `json:"reward_type"`
+	BaseReward         float64 `json:"base_reward"`
+	StreakBonus        float64 `json:"streak_bonus"`           // 连续签到第 N 天额外奖励 (N-1)*StreakBonus
+	MaxStreakBonus     float64 `json:"max_streak_bonus"`       // 连续签到加成上限
+	WeeklyBonus        float64 `json:"weekly_bonus"`           // 连续签到第 7、14、21… 天的额外奖励
+	MaxPerIPPerDay     int     `json:"max_per_ip_per_day"`     // 同一 IP 每天可签到的账号数，0 不限制
+	MaxPerDevicePerDay int     `json:"max_per_device_per_day"` // 同一设备每天可签到的账号数，0 不限制
+}
+
+// DefaultCheckinConfig 默认配置（默认关闭）
+func DefaultCheckinConfig() *CheckinConfig {
+	return &CheckinConfig{
+		RewardType:         CheckinRewardBalance,
+		BaseReward:         0.1,
+		StreakBonus:        0.05,
+		MaxStreakBonus:     0.5,
+		WeeklyBonus:        1,
+		MaxPerIPPerDay:     3,
+		MaxPerDevicePerDay: 1,
+	}
+}
+
+// RewardFor 返回连续签到第 streak 天的奖励
+func (cfg *CheckinConfig) RewardFor(streak int) float64 {
+	if streak < 1 {
+		streak = 1
+	}
+	reward := cfg.BaseReward + math.Min(float64(streak-1)*cfg.StreakBonus, cfg.MaxStreakBonus)
+	if streak%7 == 0 {
+		reward += cfg.WeeklyBonus
+	}
+	return math.Round(reward*1e6) / 1e6
+}
+
+// GetCheckinConfig 获取签到奖励配置，未配置时返回默认配置
+func GetCheckinConfig() (*CheckinConfig, error) {
+	cfg := DefaultCheckinConfig()
+	if err := GetJSONSetting(SettingKeyCheckin, cfg); err != nil && err != ErrSettingNotFound {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SaveCheckinConfig 保存签到奖励配置
+func SaveCheckinConfig(cfg *CheckinConfig) error {
+	return SetJSONSetting(SettingKeyCheckin, cfg)
+}
+
+// Checkin 签到记录
+type Checkin struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	Date         string    `json:"checkin_date"` // UTC 日期，YYYY-MM-DD
+	Streak       int       `json:"streak"`
+	RewardType   string    `json:"reward_type"`
+	RewardAmount float64   `json:"reward_amount"`
+	IPAddress    string    `json:"-"`
+	DeviceID     string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CheckinDay 返回 now 所在的签到日（UTC）
+func CheckinDay(now time.Time) string {
+	return now.UTC().Format(checkinDateLayout)
+}
+
+// NextStreak 根据上一次签到计算本次的连续天数，断签后从 1 重新开始
+func NextStreak(last *Checkin, day string) int {
+	if last == nil {
+		return 1
+	}
+	lastDay, err := time.Parse(checkinDateLayout, last.Date)
+	if err != nil || lastDay.AddDate(0, 0, 1).Format(checkinDateLayout) != day {
+		return 1
+	}
+	return last.Streak + 1
+}
+
+// GetCheckinHistory 获取用户最近的签到记录，按日期倒序
+func GetCheckinHistory(userID int64, limit int) ([]*Checkin, error) {
+	rows, err := db.Query(
+		`SELECT id, user_id, DATE_FORMAT(checkin_date, '%Y-%m-%d'), streak, reward_type, reward_amount,
+		        COALESCE(ip_address, ''), COALESCE(device_id, ''), created_at
+		 FROM user_checkins WHERE user_id = ? ORDER BY checkin_date DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*Checkin{}
+	for rows.Next() {
+		c := &Checkin{}
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Date, &c.Streak, &c.RewardType, &c.RewardAmount,
+			&c.IPAddress, &c.DeviceID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+// GetLastCheckin 获取用户最近一次签到，从未签到时返回 nil
+func GetLastCheckin(userID int64) (*Checkin, error) {
+	history, err := GetCheckinHistory(userID, 1)
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+	return history[0], nil
+}
+
+// countCheckins 统计某天来自同一 IP 或设备的签到次数
+func countCheckins(day, column, value string) (int, error) {
+	var n int
+	err := db.QueryRow(
+		fmt.Sprintf(`SELECT COUNT(*) FROM user_checkins WHERE checkin_date = ? AND %s = ?`, column),
+		day, value,
+	).Scan(&n)
+	return n, err
+}
+
+// PerformCheckin 为用户完成今日签到并发放奖励。奖励通过 AddBalance / AddGameCoins
+// 写入标准交易流水；发放失败时撤销签到记录，用户可重试
+func PerformCheckin(userID int64, ip, deviceID string, cfg *CheckinConfig, now time.Time) (*Checkin, error) {
+	if !cfg.Enabled {
+		return nil, ErrCheckinDisabled
+	}
+	day := CheckinDay(now)
+
+	last, err := GetLastCheckin(userID)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && last.Date == day {
+		return nil, ErrAlreadyCheckedIn
+	}
+
+	// 防刷：限制同一 IP / 设备每天可签到的账号数
+	if cfg.MaxPerIPPerDay > 0 && ip != "" {
+		n, err := countCheckins(day, "ip_address", ip)
+		if err != nil {
+			return nil, err
+		}
+		if n >= cfg.MaxPerIPPerDay {
+			return nil, ErrCheckinIPLimit
+		}
+	}
+	if cfg.MaxPerDevicePerDay > 0 && deviceID != "" {
+		n, err := countCheckins(day, "device_id", deviceID)
+		if err != nil {
+			return nil, err
+		}
+		if n >= cfg.MaxPerDevicePerDay {
+			return nil, ErrCheckinDeviceLimit
+		}
+	}
+
+	checkin := &Checkin{
+		UserID:     userID,
+		Date:       day,
+		Streak:     NextStreak(last, day),
+		RewardType: cfg.RewardType,
+		IPAddress:  ip,
+		DeviceID:   deviceID,
+		CreatedAt:  now,
+	}
+	checkin.RewardAmount = cfg.RewardFor(checkin.Streak)
+	if checkin.RewardType == CheckinRewardGameCoin {
+		checkin.RewardAmount = roundToTwoDecimals(checkin.RewardAmount)
+	}
+
+	// 唯一索引 (user_id, checkin_date) 保证并发请求只有一次签到成功
+	result, err := db.Exec(
+		`INSERT INTO user_checkins (user_id, checkin_date, streak, reward_type, reward_amount, ip_address, device_id, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, day, checkin.Streak, checkin.RewardType, checkin.RewardAmount,
+		nullIfEmpty(ip), nullIfEmpty(deviceID), now,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return nil, ErrAlreadyCheckedIn
+		}
+		return nil, err
+	}
+	if checkin.ID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+
+	if err := grantCheckinReward(checkin); err != nil {
+		if _, delErr := db.Exec(`DELETE FROM user_checkins WHERE id = ?`, checkin.ID); delErr != nil {
+			return nil, fmt.Errorf("%w (and failed to undo check-in: %v)", err, delErr)
+		}
+		return nil, err
+	}
+	return checkin, nil
+}
+
+// grantCheckinReward 将签到奖励记入余额或游戏币
+func grantCheckinReward(c *Checkin) error {
+	if c.RewardAmount <= 0 {
+		return nil
+	}
+	description := fmt.Sprintf("Daily check-in reward (day %d streak)", c.Streak)
+
+	if c.RewardType == CheckinRewardGameCoin {
+		if _, err := GetOrCreateUserGameBalance(c.UserID); err != nil {
+			return err
+		}
+		_, err := AddGameCoins(c.UserID, c.RewardAmount, GameTypeCheckin, description)
+		return err
+	}
+
+	_, err := AddBalance(c.UserID, c.RewardAmount, description, nil, nil, TransactionTypeCheckinReward)
+	if err == ErrBalanceNotFound {
+		if _, err = CreateUserBalance(c.UserID); err != nil {
+			return err
+		}
+		_, err = AddBalance(c.UserID, c.RewardAmount, description, nil, nil, TransactionTypeCheckinReward)
+	}
+	return err
+}
+
+// nullIfEmpty 空字符串写入 NULL
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// SettingKeyFXRates 汇率设置键（JSON: {"CNY": 7.2, "EUR": 0.92}，以 1 USD 为基准）
+const SettingKeyFXRates = "fx_rates"
+
+// GetUserDisplayCurrency 获取用户的显示货币
+func GetUserDisplayCurrency(userID int64) (string, error) {
+	var currency string
+	err := db.QueryRow(
+		`SELECT display_currency FROM users WHERE id = ?`,
+		userID,
+	).Scan(&currency)
+
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return currency, nil
+}
+
+// UpdateUserDisplayCurrency 更新用户的显示货币
+func UpdateUserDisplayCurrency(userID int64, currency string) error {
+	_, err := db.Exec(
+		`UPDATE users SET display_currency = ? WHERE id = ?`,
+		currency, userID,
+	)
+	return err
+}
+
+// GetFXRates 获取管理员配置的汇率表
+func GetFXRates() (map[string]float64, error) {
+	rates := make(map[string]float64)
+	if err := GetJSONSetting(SettingKeyFXRates, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// SaveFXRates 保存汇率表
+func SaveFXRates(rates map[string]float64) error {
+	return SetJSONSetting(SettingKeyFXRates, rates)
+}
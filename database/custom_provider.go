@@ -0,0 +1,170 @@
+package database
+
+import (
+	"Curry2API-go/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCustomProviderNotFound = errors.New("custom provider not found")
+	ErrCustomProviderExists   = errors.New("custom provider name already exists")
+)
+
+// CustomProvider 管理员注册的 OpenAI 兼容上游；Models 为允许路由到该上游的模型
+type CustomProvider struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	BaseURL   string    `json:"base_url"`
+	APIKey    string    `json:"-"`
+	Models    []string  `json:"models"`
+	Priority  int       `json:"priority"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const customProviderColumns = `id, name, base_url, api_key, models, priority, is_active, created_at, updated_at`
+
+func scanCustomProvider(row interface{ Scan(...interface{}) error }) (*CustomProvider, error) {
+	p := &CustomProvider{}
+	var encryptedKey, modelsJSON string
+	if err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.BaseURL,
+		&encryptedKey,
+		&modelsJSON,
+		&p.Priority,
+		&p.IsActive,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	apiKey, err := utils.DecryptSensitiveData(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	p.APIKey = apiKey
+	if err := json.Unmarshal([]byte(modelsJSON), &p.Models); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// encodeCustomProvider 加密 API key 并序列化模型列表
+func encodeCustomProvider(p *CustomProvider) (string, string, error) {
+	encryptedKey, err := utils.EncryptSensitiveData(p.APIKey)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Models == nil {
+		p.Models = []string{}
+	}
+	modelsJSON, err := json.Marshal(p.Models)
+	if err != nil {
+		return "", "", err
+	}
+	return encryptedKey, string(modelsJSON), nil
+}
+
+// CreateCustomProvider 创建自定义上游
+func CreateCustomProvider(p *CustomProvider) error {
+	encryptedKey, modelsJSON, err := encodeCustomProvider(p)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO custom_providers (name, base_url, api_key, models, priority, is_active, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Name, p.BaseURL, encryptedKey, modelsJSON, p.Priority, p.IsActive, now, now,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return ErrCustomProviderExists
+		}
+		return err
+	}
+	p.ID, err = result.LastInsertId()
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	return err
+}
+
+// GetCustomProvider 根据ID获取自定义上游（含解密后的 API key）
+func GetCustomProvider(id int64) (*CustomProvider, error) {
+	p, err := scanCustomProvider(db.QueryRow(
+		`SELECT `+customProviderColumns+` FROM custom_providers WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrCustomProviderNotFound
+	}
+	return p, err
+}
+
+// ListCustomProviders 获取所有自定义上游，按优先级从高到低；activeOnly 为 true 时只返回启用的
+func ListCustomProviders(activeOnly bool) ([]*CustomProvider, error) {
+	query := `SELECT ` + customProviderColumns + ` FROM custom_providers`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	rows, err := db.Query(query + ` ORDER BY priority DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	providers := []*CustomProvider{}
+	for rows.Next() {
+		p, err := scanCustomProvider(rows)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, rows.Err()
+}
+
+// UpdateCustomProvider 更新自定义上游
+func UpdateCustomProvider(p *CustomProvider) error {
+	encryptedKey, modelsJSON, err := encodeCustomProvider(p)
+	if err != nil {
+		return err
+	}
+
+	p.UpdatedAt = time.Now()
+	result, err := db.Exec(
+		`UPDATE custom_providers SET name = ?, base_url = ?, api_key = ?, models = ?, priority = ?, is_active = ?, updated_at = ?
+		 WHERE id = ?`,
+		p.Name, p.BaseURL, encryptedKey, modelsJSON, p.Priority, p.IsActive, p.UpdatedAt, p.ID,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return ErrCustomProviderExists
+		}
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCustomProviderNotFound
+	}
+	return nil
+}
+
+// DeleteCustomProvider 删除自定义上游
+func DeleteCustomProvider(id int64) error {
+	result, err := db.Exec(`DELETE FROM custom_providers WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCustomProviderNotFound
+	}
+	return nil
+}
@@ -7,14 +7,34 @@ import (
 	"time"
 
 	"Curry2API-go/config"
+	"Curry2API-go/utils"
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/sirupsen/logrus"
 )
 
+// dbLog logs connection, schema migration and health monitoring events
+var dbLog = utils.SubsystemLogger(utils.LogSubsystemDB)
+
 var db *sql.DB
 
 // Init 初始化数据库连接
 func Init(cfg *config.Config) error {
+	if err := Connect(cfg); err != nil {
+		return err
+	}
+	
+	// Fix any tables with incompatible foreign key types before creating tables
+	fixIncompatibleTables()
+	
+	// 创建表
+	if err := createTables(); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+	
+	return nil
+}
+
+// Connect 仅建立数据库连接（不创建表、不执行迁移），供自检模式使用
+func Connect(cfg *config.Config) error {
 	var err error
 	
 	// 构建 MySQL DSN
@@ -41,16 +61,7 @@ func Init(cfg *config.Config) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 	
-	logrus.Info("Database connected successfully")
-	
-	// Fix any tables with incompatible foreign key types before creating tables
-	fixIncompatibleTables()
-	
-	// 创建表
-	if err := createTables(); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
-	}
-	
+	dbLog.Info("Database connected successfully")
 	return nil
 }
 
@@ -66,7 +77,7 @@ func fixIncompatibleTables() {
 	`).Scan(&usersIdType)
 	
 	if err == nil && !strings.Contains(strings.ToLower(usersIdType), "bigint") {
-		logrus.Infof("Users table has incompatible id type (%s), need to fix all dependent tables...", usersIdType)
+		dbLog.Infof("Users table has incompatible id type (%s), need to fix all dependent tables...", usersIdType)
 		
 		// Drop all tables that have foreign keys to users in reverse dependency order
 		tablesToDrop := []string{
@@ -95,7 +106,7 @@ func fixIncompatibleTables() {
 			_, _ = db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
 		}
 		
-		logrus.Info("All tables dropped for recreation with correct schema")
+		dbLog.Info("All tables dropped for recreation with correct schema")
 		return
 	}
 	
@@ -131,7 +142,7 @@ func fixIncompatibleTables() {
 		
 		// If column is not BIGINT, we need to recreate the table
 		if !strings.Contains(strings.ToLower(columnType), "bigint") {
-			logrus.Infof("Fixing table %s with incompatible %s type (%s)...", table.tableName, table.columnName, columnType)
+			dbLog.Infof("Fixing table %s with incompatible %s type (%s)...", table.tableName, table.columnName, columnType)
 			
 			// Drop child table first if exists
 			if table.childTable != "" {
@@ -140,7 +151,7 @@ func fixIncompatibleTables() {
 			// Drop the table
 			_, _ = db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table.tableName))
 			
-			logrus.Infof("Table %s dropped for recreation with correct schema", table.tableName)
+			dbLog.Infof("Table %s dropped for recreation with correct schema", table.tableName)
 		}
 	}
 }
@@ -153,9 +164,9 @@ func GetDB() (*sql.DB, error) {
 	return db, nil
 }
 
-// createTables 创建所有必要的表
-func createTables() error {
-	tables := []string{
+// tableDefinitions 返回所有表的建表语句
+func tableDefinitions() []string {
+	return []string{
 		// 用户表
 		`CREATE TABLE IF NOT EXISTS users (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -442,27 +453,430 @@ func createTables() error {
 			INDEX idx_conversation_created (conversation_id, created_at),
 			FOREIGN KEY (conversation_id) REFERENCES chat_conversations(id) ON DELETE CASCADE
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 模型定价表 (Model Pricing, USD per 1M tokens)
+		`CREATE TABLE IF NOT EXISTS model_pricing (
+			model VARCHAR(100) PRIMARY KEY,
+			provider VARCHAR(50) NOT NULL,
+			input_price DECIMAL(10, 4) NOT NULL COMMENT 'USD per 1M input tokens',
+			output_price DECIMAL(10, 4) NOT NULL COMMENT 'USD per 1M output tokens',
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 系统设置表 (System Settings)
+		`CREATE TABLE IF NOT EXISTS system_settings (
+			setting_key VARCHAR(100) PRIMARY KEY,
+			setting_value TEXT NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 延迟预算表 (Latency SLOs per endpoint/model)
+		`CREATE TABLE IF NOT EXISTS latency_slos (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			endpoint VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Route path, empty matches all',
+			model VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'Model name, empty matches all',
+			metric ENUM('ttft', 'total') NOT NULL DEFAULT 'ttft',
+			percentile DECIMAL(5, 2) NOT NULL DEFAULT 95.00,
+			threshold_ms INT NOT NULL,
+			consecutive_windows INT NOT NULL DEFAULT 3,
+			min_samples INT NOT NULL DEFAULT 10,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 延迟预算告警表 (Latency SLO Alerts)
+		`CREATE TABLE IF NOT EXISTS latency_alerts (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			slo_id BIGINT NOT NULL,
+			observed_ms INT NOT NULL,
+			threshold_ms INT NOT NULL,
+			windows INT NOT NULL,
+			samples INT NOT NULL,
+			message VARCHAR(500) NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			resolved_at DATETIME NULL,
+			INDEX idx_slo_created (slo_id, created_at DESC),
+			FOREIGN KEY (slo_id) REFERENCES latency_slos(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 审计日志表 (Audit Logs)
+		`CREATE TABLE IF NOT EXISTS audit_logs (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			actor_id BIGINT NULL COMMENT 'Admin user who performed the action',
+			action VARCHAR(64) NOT NULL,
+			target_type VARCHAR(32) NOT NULL,
+			target_id VARCHAR(64) NOT NULL,
+			details TEXT COMMENT 'JSON details of the action',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_action_created (action, created_at DESC),
+			INDEX idx_target (target_type, target_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 服务条款版本表 (Terms of Service Versions)
+		`CREATE TABLE IF NOT EXISTS tos_versions (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			version VARCHAR(32) NOT NULL UNIQUE,
+			title VARCHAR(255) NOT NULL,
+			content MEDIUMTEXT NOT NULL,
+			published_by BIGINT NULL,
+			published_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_published_at (published_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 服务条款接受记录表 (Terms of Service Acceptances)
+		`CREATE TABLE IF NOT EXISTS tos_acceptances (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			version VARCHAR(32) NOT NULL,
+			accepted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			ip_address VARCHAR(64),
+			user_agent VARCHAR(500),
+			UNIQUE KEY uk_user_version (user_id, version),
+			INDEX idx_version (version),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 管理员注册的 OpenAI 兼容上游（api_key 加密存储）
+		`CREATE TABLE IF NOT EXISTS custom_providers (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(32) NOT NULL,
+			base_url VARCHAR(500) NOT NULL,
+			api_key TEXT NOT NULL,
+			models TEXT NOT NULL,
+			priority INT NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY uk_custom_provider_name (name)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 已使用（一次性）或已吊销的签名令牌，过期后清理
+		`CREATE TABLE IF NOT EXISTS signed_tokens_spent (
+			jti VARCHAR(32) PRIMARY KEY,
+			purpose VARCHAR(32) NOT NULL,
+			reason VARCHAR(16) NOT NULL,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_expires_at (expires_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// JWT 模式下用户登录过的设备指纹，用于新设备登录提醒
+		`CREATE TABLE IF NOT EXISTS login_devices (
+			user_id BIGINT NOT NULL,
+			fingerprint VARCHAR(32) NOT NULL,
+			last_login_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, fingerprint),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 用户自设的消费上限（spend guard），放宽需等待冷却期
+		`CREATE TABLE IF NOT EXISTS user_spend_guards (
+			user_id BIGINT PRIMARY KEY,
+			daily_limit DECIMAL(12, 4) NULL,
+			weekly_limit DECIMAL(12, 4) NULL,
+			pending_daily_limit DECIMAL(12, 4) NULL,
+			pending_weekly_limit DECIMAL(12, 4) NULL,
+			pending_effective_at DATETIME NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 用户配置的任务完成通知 Webhook（每个用户一个，secret 用于签名）
+		`CREATE TABLE IF NOT EXISTS user_webhooks (
+			user_id BIGINT PRIMARY KEY,
+			url VARCHAR(500) NOT NULL,
+			secret VARCHAR(64) NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			email_fallback BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 用户 Webhook 投递队列（失败按退避重试）
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			event VARCHAR(64) NOT NULL,
+			payload MEDIUMTEXT NOT NULL,
+			status VARCHAR(16) NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_error VARCHAR(500) DEFAULT NULL,
+			delivered_at DATETIME NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_status_next_attempt (status, next_attempt_at),
+			INDEX idx_user_created (user_id, created_at),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+
+		// 每日签到记录表 (Daily Check-ins)
+		`CREATE TABLE IF NOT EXISTS user_checkins (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			checkin_date DATE NOT NULL COMMENT 'UTC day of the check-in',
+			streak INT NOT NULL DEFAULT 1 COMMENT 'Consecutive days including this one',
+			reward_type VARCHAR(20) NOT NULL COMMENT 'balance or game_coin',
+			reward_amount DECIMAL(10, 6) NOT NULL,
+			ip_address VARCHAR(64),
+			device_id VARCHAR(128),
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uk_checkins_user_date (user_id, checkin_date),
+			INDEX idx_checkins_date_ip (checkin_date, ip_address),
+			INDEX idx_checkins_date_device (checkin_date, device_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 管理员配置的请求路由规则，按 position 从小到大依次匹配
+		`CREATE TABLE IF NOT EXISTS routing_rules (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			position INT NOT NULL DEFAULT 0,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			conditions TEXT NOT NULL COMMENT 'JSON RoutingRuleConditions',
+			actions TEXT NOT NULL COMMENT 'JSON RoutingRuleActions',
+			stop BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Stop evaluating later rules after a match',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			INDEX idx_routing_rules_position (position)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 异步批处理任务（/v1/batches），按批汇总状态与用量
+		`CREATE TABLE IF NOT EXISTS batches (
+			id VARCHAR(40) PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			username VARCHAR(100) NOT NULL DEFAULT '',
+			api_key VARCHAR(255) NOT NULL COMMENT 'Key the batch is billed to',
+			token_name VARCHAR(100) NOT NULL DEFAULT '',
+			endpoint VARCHAR(64) NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			metadata TEXT COMMENT 'JSON metadata supplied by the client',
+			total_requests INT NOT NULL DEFAULT 0,
+			completed_requests INT NOT NULL DEFAULT 0,
+			failed_requests INT NOT NULL DEFAULT 0,
+			prompt_tokens BIGINT NOT NULL DEFAULT 0,
+			completion_tokens BIGINT NOT NULL DEFAULT 0,
+			total_tokens BIGINT NOT NULL DEFAULT 0,
+			cost DECIMAL(12, 6) NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			completed_at DATETIME NULL,
+			cancelled_at DATETIME NULL,
+			INDEX idx_batches_user_created (user_id, created_at DESC),
+			INDEX idx_batches_status (status),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 批处理中的单个请求（JSONL 的一行）及其结果
+		`CREATE TABLE IF NOT EXISTS batch_requests (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			batch_id VARCHAR(40) NOT NULL,
+			line INT NOT NULL,
+			custom_id VARCHAR(255) NOT NULL,
+			body MEDIUMTEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			status_code INT NOT NULL DEFAULT 0,
+			response MEDIUMTEXT NULL,
+			error TEXT NULL,
+			prompt_tokens INT NOT NULL DEFAULT 0,
+			completion_tokens INT NOT NULL DEFAULT 0,
+			cost DECIMAL(12, 6) NOT NULL DEFAULT 0,
+			completed_at DATETIME NULL,
+			UNIQUE KEY uk_batch_custom_id (batch_id, custom_id),
+			INDEX idx_batch_requests_status (batch_id, status),
+			FOREIGN KEY (batch_id) REFERENCES batches(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 用户上传的文件（/v1/files）元数据，内容保存在磁盘或 S3
+		`CREATE TABLE IF NOT EXISTS files (
+			id VARCHAR(40) PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			filename VARCHAR(255) NOT NULL,
+			purpose VARCHAR(32) NOT NULL,
+			bytes BIGINT NOT NULL DEFAULT 0,
+			content_type VARCHAR(100) NOT NULL DEFAULT '',
+			storage VARCHAR(16) NOT NULL COMMENT 'Storage backend holding the content: disk or s3',
+			storage_key VARCHAR(255) NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_files_user_created (user_id, created_at DESC),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 游戏币与账户余额互转失败的补偿记录，供管理员核对
+		`CREATE TABLE IF NOT EXISTS transfer_compensations (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			operation VARCHAR(20) NOT NULL COMMENT 'game_exchange or game_purchase',
+			idempotency_key VARCHAR(64) NULL,
+			game_coins_amount DECIMAL(10, 2) NOT NULL,
+			usd_amount DECIMAL(10, 6) NOT NULL,
+			status VARCHAR(20) NOT NULL COMMENT 'rolled_back, unknown, resolved',
+			error VARCHAR(500) NOT NULL DEFAULT '',
+			resolution_note VARCHAR(500) NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			resolved_at DATETIME NULL,
+			INDEX idx_transfer_compensations_status (status, id),
+			INDEX idx_transfer_compensations_user (user_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 匿名试用的临时密钥，绑定申请时的 IP；注册后记录转化的用户
+		`CREATE TABLE IF NOT EXISTS trial_keys (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			api_key VARCHAR(64) NOT NULL UNIQUE,
+			ip_address VARCHAR(45) NOT NULL,
+			requests INT NOT NULL DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			converted_user_id BIGINT NULL,
+			converted_at DATETIME NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_trial_keys_ip_created (ip_address, created_at),
+			INDEX idx_trial_keys_created (created_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 管理员为上游提供商设置的月度消费上限；alert_* 记录本月已发出的告警级别，避免重复告警
+		`CREATE TABLE IF NOT EXISTS provider_spend_caps (
+			provider VARCHAR(50) PRIMARY KEY,
+			monthly_limit DECIMAL(12, 2) NOT NULL COMMENT 'Estimated upstream spend ceiling per calendar month in USD',
+			alert_percent INT NOT NULL DEFAULT 80 COMMENT 'Warn once spend reaches this percentage of the limit',
+			allowlist_tags TEXT DEFAULT NULL COMMENT 'JSON array of API key tags that keep routing to a capped provider',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			alert_month CHAR(7) DEFAULT NULL COMMENT 'Month (YYYY-MM) alert_level applies to',
+			alert_level VARCHAR(16) DEFAULT NULL COMMENT 'warning or capped',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 提供商消费上限告警记录
+		`CREATE TABLE IF NOT EXISTS provider_spend_alerts (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			provider VARCHAR(50) NOT NULL,
+			month CHAR(7) NOT NULL,
+			level VARCHAR(16) NOT NULL COMMENT 'warning, capped or released',
+			spent DECIMAL(12, 4) NOT NULL,
+			monthly_limit DECIMAL(12, 2) NOT NULL,
+			message VARCHAR(500) NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_provider_spend_alerts_created (created_at DESC),
+			INDEX idx_provider_spend_alerts_provider (provider, created_at DESC)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 会话公开分享链接，分享时固定当前分支的最后一条消息
+		`CREATE TABLE IF NOT EXISTS chat_shares (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			conversation_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			token_id VARCHAR(32) NOT NULL COMMENT 'ID (jti) of the signed share token',
+			leaf_message_id BIGINT NOT NULL COMMENT 'Last message of the shared branch',
+			view_count INT NOT NULL DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			revoked_at DATETIME NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uk_chat_shares_token (token_id),
+			INDEX idx_chat_shares_conversation (conversation_id, created_at DESC),
+			FOREIGN KEY (conversation_id) REFERENCES chat_conversations(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 面向 API 调用方的变更日志，提示期内通过 X-CurryAPI-Notice 响应头提示
+		`CREATE TABLE IF NOT EXISTS api_changelog (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			title VARCHAR(200) NOT NULL,
+			description TEXT NOT NULL,
+			category VARCHAR(20) NOT NULL,
+			models TEXT DEFAULT NULL COMMENT 'JSON array of affected model patterns, NULL for every request',
+			effective_at DATETIME NULL COMMENT 'When the change takes effect',
+			notice_from DATETIME NOT NULL COMMENT 'Start of the response header notice',
+			notice_until DATETIME NULL COMMENT 'End of the response header notice, NULL for none',
+			published BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			INDEX idx_api_changelog_notice (published, notice_from)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 聊天会话文件夹
+		`CREATE TABLE IF NOT EXISTS chat_folders (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY uk_chat_folders_user_name (user_id, name),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 聊天会话标签
+		`CREATE TABLE IF NOT EXISTS chat_conversation_tags (
+			conversation_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			tag VARCHAR(50) NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (conversation_id, tag),
+			INDEX idx_chat_tags_user_tag (user_id, tag),
+			FOREIGN KEY (conversation_id) REFERENCES chat_conversations(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 系统提示词模板（user_id 为空表示管理员发布的全局模板）
+		`CREATE TABLE IF NOT EXISTS chat_prompt_templates (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT DEFAULT NULL,
+			name VARCHAR(100) NOT NULL,
+			description VARCHAR(255) NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_prompt_templates_user (user_id, name),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 聊天消息附件（仅保存提取出的文本；message_id 为空表示已上传但尚未发送）
+		`CREATE TABLE IF NOT EXISTS chat_attachments (
+			id VARCHAR(64) PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			message_id BIGINT DEFAULT NULL,
+			filename VARCHAR(255) NOT NULL,
+			content_type VARCHAR(100) NOT NULL DEFAULT '',
+			bytes BIGINT NOT NULL DEFAULT 0,
+			text MEDIUMTEXT NOT NULL,
+			chars INT NOT NULL DEFAULT 0,
+			truncated BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_chat_attachments_message (message_id),
+			INDEX idx_chat_attachments_user (user_id, created_at),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (message_id) REFERENCES chat_messages(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 聊天回复反馈（赞/踩与评论，记录回复所用的模型与提供商，用于路由分析）
+		`CREATE TABLE IF NOT EXISTS chat_message_feedback (
+			message_id BIGINT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			rating TINYINT NOT NULL COMMENT '1 for thumbs up, -1 for thumbs down',
+			comment VARCHAR(1000) NOT NULL DEFAULT '',
+			model VARCHAR(100) NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_chat_feedback_updated (updated_at),
+			INDEX idx_chat_feedback_user (user_id),
+			FOREIGN KEY (message_id) REFERENCES chat_messages(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+		// 用户两步验证（TOTP 密钥加密存储，备用码只保存 SHA-256 摘要）
+		`CREATE TABLE IF NOT EXISTS user_two_factor (
+			user_id BIGINT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			backup_codes TEXT NULL COMMENT 'JSON array of SHA-256 hashes of unused backup codes',
+			last_used_step BIGINT NOT NULL DEFAULT 0 COMMENT 'Last accepted TOTP time step, rejects code replay',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			enabled_at DATETIME NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
 	}
-	
-	for _, table := range tables {
+}
+
+// createTables 创建所有必要的表
+func createTables() error {
+	for _, table := range tableDefinitions() {
 		if _, err := db.Exec(table); err != nil {
 			return fmt.Errorf("failed to create table: %w", err)
 		}
 	}
 	
-	logrus.Info("All database tables created successfully")
+	dbLog.Info("All database tables created successfully")
 	
 	// Run migrations for existing tables
 	if err := runMigrations(); err != nil {
-		logrus.Warnf("Some migrations failed (may be expected if columns already exist): %v", err)
+		dbLog.Warnf("Some migrations failed (may be expected if columns already exist): %v", err)
 	}
 	
 	return nil
 }
 
-// runMigrations runs schema migrations for existing tables
-func runMigrations() error {
-	migrations := []string{
+// schemaMigrations returns the incremental migrations for existing tables (append only)
+func schemaMigrations() []string {
+	return []string{
 		// Add token_name column to api_keys if not exists
 		`ALTER TABLE api_keys ADD COLUMN token_name VARCHAR(255) COMMENT 'Optional descriptive name for the token' AFTER masked_key`,
 		// Add last_used_at column to api_keys if not exists
@@ -477,19 +891,111 @@ func runMigrations() error {
 		`ALTER TABLE api_keys ADD COLUMN allowed_models TEXT DEFAULT NULL COMMENT 'JSON array of allowed models, NULL means all models'`,
 		// Add wins column to user_game_balances for tracking win count
 		`ALTER TABLE user_game_balances ADD COLUMN wins INT NOT NULL DEFAULT 0 COMMENT 'Total wins' AFTER games_played`,
+		// Add display_currency column to users for balance display preference
+		`ALTER TABLE users ADD COLUMN display_currency VARCHAR(3) NOT NULL DEFAULT 'USD' COMMENT 'Display currency: USD, CNY, EUR'`,
+		// Add priority_trusted column to api_keys for honoring X-Priority header
+		`ALTER TABLE api_keys ADD COLUMN priority_trusted BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Whether X-Priority header is honored'`,
+		// Add tax fields to users for VAT invoicing
+		`ALTER TABLE users ADD COLUMN tax_country VARCHAR(2) DEFAULT NULL COMMENT 'ISO 3166-1 country code for VAT'`,
+		`ALTER TABLE users ADD COLUMN tax_id VARCHAR(64) DEFAULT NULL COMMENT 'VAT / tax identification number'`,
+		// Add tax columns to balance_transactions for recharge tax lines
+		`ALTER TABLE balance_transactions ADD COLUMN tax_rate DECIMAL(6, 4) NOT NULL DEFAULT 0 COMMENT 'VAT rate applied'`,
+		`ALTER TABLE balance_transactions ADD COLUMN tax_amount DECIMAL(10, 6) NOT NULL DEFAULT 0 COMMENT 'VAT amount in USD'`,
+		// Add per-key SSE chunk coalescing overrides to api_keys (NULL uses the server default)
+		`ALTER TABLE api_keys ADD COLUMN stream_flush_interval_ms INT DEFAULT NULL COMMENT 'Flush SSE output at most every N ms'`,
+		`ALTER TABLE api_keys ADD COLUMN stream_flush_bytes INT DEFAULT NULL COMMENT 'Flush SSE output once N bytes are buffered'`,
+		// Mark keys replaced by a rotation so they are not rotated again during the grace period
+		`ALTER TABLE api_keys ADD COLUMN rotated_at DATETIME DEFAULT NULL COMMENT 'When this key was replaced by a rotation'`,
+		// Add per-key HMAC request signing secret to api_keys (NULL means bearer-only)
+		`ALTER TABLE api_keys ADD COLUMN signing_secret VARCHAR(80) DEFAULT NULL COMMENT 'HMAC secret; when set, requests must be signed'`,
+		// Store code artifacts extracted from assistant replies alongside chat messages
+		`ALTER TABLE chat_messages ADD COLUMN artifacts MEDIUMTEXT DEFAULT NULL COMMENT 'JSON array of code artifacts' AFTER content`,
+		// Record which provider served each request for per-provider metrics
+		`ALTER TABLE usage_records ADD COLUMN provider VARCHAR(50) DEFAULT NULL COMMENT 'Provider that served the request' AFTER model,
+			ADD INDEX idx_provider_time (provider, request_time)`,
+		// Admin-assigned API key tags matched by routing rules
+		`ALTER TABLE api_keys ADD COLUMN tags TEXT DEFAULT NULL COMMENT 'JSON array of tags used by routing rules'`,
+		// Per-conversation spend ceiling for online chat
+		`ALTER TABLE chat_conversations ADD COLUMN max_cost DECIMAL(10,6) NULL COMMENT 'Spend ceiling in USD, NULL for none'`,
+		// Idempotency keys for game coin transfers; a repeated key returns the original transfer
+		`ALTER TABLE exchange_records ADD COLUMN idempotency_key VARCHAR(64) DEFAULT NULL COMMENT 'Client idempotency key',
+			ADD UNIQUE INDEX uk_exchange_records_idempotency (user_id, idempotency_key)`,
+		// Device fingerprint of each login session, shown in session management and new-login notices
+		`ALTER TABLE sessions ADD COLUMN browser VARCHAR(64) DEFAULT NULL COMMENT 'Browser parsed from the user agent',
+			ADD COLUMN os VARCHAR(32) DEFAULT NULL COMMENT 'Operating system parsed from the user agent',
+			ADD COLUMN device_type VARCHAR(16) DEFAULT NULL COMMENT 'desktop, mobile, tablet or bot',
+			ADD COLUMN location VARCHAR(64) DEFAULT NULL COMMENT 'Coarse location derived from the client IP',
+			ADD COLUMN fingerprint VARCHAR(16) DEFAULT NULL COMMENT 'Hash of the device fields, used to detect new devices'`,
+		// Sampling seed sent upstream, recorded for reproducibility
+		`ALTER TABLE usage_records ADD COLUMN seed BIGINT DEFAULT NULL COMMENT 'Sampling seed sent to the provider'`,
+		`ALTER TABLE chat_messages ADD COLUMN seed BIGINT DEFAULT NULL COMMENT 'Sampling seed the reply was generated with'`,
+		// Per-conversation deterministic mode: temperature 0 and a fixed seed
+		`ALTER TABLE chat_conversations ADD COLUMN deterministic BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Pin temperature 0 and a fixed seed',
+			ADD COLUMN seed BIGINT DEFAULT NULL COMMENT 'Seed for deterministic mode, NULL for the default'`,
+		// Assistant replies cut short by the stop-generation endpoint
+		`ALTER TABLE chat_messages ADD COLUMN stopped BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Generation was stopped by the user'`,
+		// Regenerated replies: the replaced reply stays for billing but is hidden from history and context
+		`ALTER TABLE chat_messages ADD COLUMN superseded_by BIGINT DEFAULT NULL COMMENT 'Regenerated reply that replaced this one'`,
+		// Conversation branching: messages form a tree, the conversation points at the leaf of the active branch
+		`ALTER TABLE chat_messages ADD COLUMN parent_message_id BIGINT DEFAULT NULL COMMENT 'Message this one follows, NULL for the first message of a branch',
+			ADD INDEX idx_parent_message (parent_message_id)`,
+		`ALTER TABLE chat_conversations ADD COLUMN current_message_id BIGINT DEFAULT NULL COMMENT 'Leaf of the active branch, NULL until the message tree is built'`,
+		// Full-text search across chat messages; the ngram parser also tokenizes Chinese text
+		`ALTER TABLE chat_messages ADD FULLTEXT INDEX ft_chat_messages_content (content) WITH PARSER ngram`,
+		// Detected prompt language, for language-specific policies and distribution reports
+		`ALTER TABLE usage_records ADD COLUMN language VARCHAR(8) DEFAULT NULL COMMENT 'Detected prompt language (ISO 639-1), NULL when not detected',
+			ADD INDEX idx_usage_language_time (language, request_time)`,
+		// Conversation folders; deleting a folder leaves its conversations unfiled
+		`ALTER TABLE chat_conversations ADD COLUMN folder_id BIGINT DEFAULT NULL COMMENT 'Folder the conversation is filed in, NULL for none',
+			ADD INDEX idx_conversation_folder (user_id, folder_id, updated_at),
+			ADD CONSTRAINT fk_conversation_folder FOREIGN KEY (folder_id) REFERENCES chat_folders(id) ON DELETE SET NULL`,
+		// Sudo mode: admins re-enter their password before dangerous operations
+		`ALTER TABLE sessions ADD COLUMN elevated_until DATETIME DEFAULT NULL COMMENT 'End of sudo mode for dangerous admin operations, NULL when not elevated'`,
+		// Chat archive: messages of long idle conversations are zstd-compressed into content_zstd
+		`ALTER TABLE chat_messages ADD COLUMN content_zstd MEDIUMBLOB DEFAULT NULL COMMENT 'zstd-compressed content and artifacts of an archived message, NULL when not archived'`,
+		`ALTER TABLE chat_conversations ADD COLUMN archived_at DATETIME DEFAULT NULL COMMENT 'Last time the conversation messages were archived, NULL when never',
+			ADD INDEX idx_conversation_archive (updated_at)`,
+		// System prompt templates: the template a conversation's system prompt was taken from
+		`ALTER TABLE chat_conversations ADD COLUMN prompt_template_id BIGINT DEFAULT NULL COMMENT 'System prompt template the conversation was started from, NULL for none',
+			ADD CONSTRAINT fk_conversation_prompt_template FOREIGN KEY (prompt_template_id) REFERENCES chat_prompt_templates(id) ON DELETE SET NULL`,
+		// Message feedback: the model and provider that produced each reply, for per-route ratings
+		`ALTER TABLE chat_messages ADD COLUMN model VARCHAR(100) DEFAULT NULL COMMENT 'Model that generated an assistant reply, NULL for user messages and older replies',
+			ADD COLUMN provider VARCHAR(50) DEFAULT NULL COMMENT 'Provider that served an assistant reply, NULL for user messages and older replies'`,
+		// Pinned conversations list first; archived ones are hidden from the list (unrelated to archived_at storage compression)
+		`ALTER TABLE chat_conversations ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Listed before unpinned conversations',
+			ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Hidden from the conversation list unless archived ones are requested',
+			ADD INDEX idx_conversation_list (user_id, archived, pinned, updated_at)`,
+		// Per-message token usage: the prompt/completion split behind tokens and cost
+		`ALTER TABLE chat_messages ADD COLUMN prompt_tokens INT NOT NULL DEFAULT 0 COMMENT 'Prompt tokens billed for an assistant reply, 0 for user messages and older replies' AFTER tokens,
+			ADD COLUMN completion_tokens INT NOT NULL DEFAULT 0 COMMENT 'Completion tokens billed for an assistant reply, 0 for user messages and older replies' AFTER prompt_tokens`,
+		// API key scopes limiting the endpoints a key may call
+		`ALTER TABLE api_keys ADD COLUMN scopes TEXT DEFAULT NULL COMMENT 'JSON array of scopes the key may use, NULL for every scope except admin-read'`,
+		// Per-key IP allowlist locking a key to its servers
+		`ALTER TABLE api_keys ADD COLUMN allowed_ips TEXT DEFAULT NULL COMMENT 'JSON array of CIDR ranges the key may be used from, NULL for any address'`,
+		// Wrong guesses per verification code, the code is burned after too many
+		`ALTER TABLE verification_codes ADD COLUMN attempts INT NOT NULL DEFAULT 0 COMMENT 'Wrong codes submitted, the code is marked used at MaxCodeAttempts',
+			ADD INDEX idx_ip_type_created (ip_address, code_type, created_at)`,
+		// JWT auth mode: access and refresh tokens issued before this time are rejected
+		`ALTER TABLE users ADD COLUMN tokens_valid_after DATETIME(3) NULL COMMENT 'JWTs issued before this time are revoked, NULL for none'`,
+		`ALTER TABLE users MODIFY COLUMN tokens_valid_after DATETIME(3) NULL COMMENT 'JWTs issued before this time are revoked, NULL for none'`,
+		// JWT auth mode: elevated (sudo) access tokens issued before this time are rejected
+		`ALTER TABLE users ADD COLUMN elevation_valid_after DATETIME(3) NULL COMMENT 'JWTs carrying sudo mode issued before this time are revoked, NULL for none'`,
 	}
-	
-	for _, migration := range migrations {
+}
+
+// runMigrations runs schema migrations for existing tables
+func runMigrations() error {
+	for _, migration := range schemaMigrations() {
 		_, err := db.Exec(migration)
 		if err != nil {
-			// Ignore "Duplicate column name" errors - column already exists
-			if !isDuplicateColumnError(err) {
-				logrus.Warnf("Migration warning: %v", err)
+			// Ignore "Duplicate column name" / "Duplicate key name" errors - already applied
+			if !isDuplicateColumnError(err) && !isDuplicateKeyNameError(err) {
+				dbLog.Warnf("Migration warning: %v", err)
 			}
 		}
 	}
 	
-	logrus.Info("Database migrations completed")
+	dbLog.Info("Database migrations completed")
 	return nil
 }
 
@@ -503,3 +1009,12 @@ func isDuplicateColumnError(err error) bool {
 	errStr := err.Error()
 	return strings.Contains(errStr, "Duplicate column name") || strings.Contains(errStr, "1060")
 }
+
+// isDuplicateKeyNameError checks if the error is a duplicate index name error
+func isDuplicateKeyNameError(err error) bool {
+	if err == nil {
+		return false
+	}
+	errStr := err.Error()
+	return strings.Contains(errStr, "Duplicate key name") || strings.Contains(errStr, "1061")
+}
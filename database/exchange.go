@@ -14,6 +14,7 @@ type ExchangeRecord struct {
 	ExchangeRate    float64   `json:"exchange_rate"`
 	Status          string    `json:"status"`
 	CreatedAt       time.Time `json:"created_at"`
+	Replayed        bool      `json:"-"` // Returned from an earlier request with the same idempotency key
 }
 
 // ExchangeRecordWithUser represents an exchange record with user info for admin view
@@ -30,15 +31,18 @@ type ExchangeStats struct {
 }
 
 // ExchangeGameCoins exchanges game coins for account balance (USD)
-// This is an atomic transaction that:
+// Both ledgers are updated in one transaction that:
 // 1. Validates the exchange amount
-// 2. Checks daily exchange limit
-// 3. Deducts game coins from user's game balance
-// 4. Adds USD to user's account balance
-// 5. Creates exchange record
-// 6. Creates transaction records for both game coins and account balance
+// 2. Locks the account balance, then the game balance (see lockTransferLedgersTx)
+// 3. Returns the earlier result if idempotencyKey was already used
+// 4. Checks daily exchange limit
+// 5. Deducts game coins from user's game balance
+// 6. Adds USD to user's account balance
+// 7. Creates exchange record
+// 8. Creates transaction records for both game coins and account balance
+// A transfer that fails after validation leaves a compensation record
 // Requirements: 2.1, 2.4, 2.5, 5.1
-func ExchangeGameCoins(userID int64, amount float64) (*ExchangeRecord, error) {
+func ExchangeGameCoins(userID int64, amount float64, idempotencyKey string) (*ExchangeRecord, error) {
 	// Validate amount
 	if amount <= 0 {
 		return nil, ErrInvalidAmount
@@ -50,43 +54,54 @@ func ExchangeGameCoins(userID int64, amount float64) (*ExchangeRecord, error) {
 	amount = roundToTwoDecimals(amount)
 	usdAmount := amount * ExchangeRate // 1:1 rate
 
-	// Start transaction
+	record, err := exchangeGameCoinsTx(userID, amount, usdAmount, idempotencyKey)
+	if err != nil {
+		return finishFailedTransfer(TransferGameExchange, userID, amount, usdAmount, idempotencyKey, err)
+	}
+	return record, nil
+}
+
+// exchangeGameCoinsTx runs the game coin → USD transfer in a single transaction
+func exchangeGameCoinsTx(userID int64, amount, usdAmount float64, idempotencyKey string) (*ExchangeRecord, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Check daily exchange limit
-	todayExchanged, err := getTodayExchangeAmountTx(tx, userID)
+	ledgers, err := lockTransferLedgersTx(tx, userID)
 	if err != nil {
 		return nil, err
 	}
-	if todayExchanged+amount > DailyExchangeLimit {
-		return nil, ErrDailyLimitExceeded
+	if !ledgers.gameExists {
+		return nil, ErrGameBalanceNotFound
 	}
 
-	// Get current game coin balance with lock
-	var currentGameBalance float64
-	err = tx.QueryRow(
-		`SELECT balance FROM user_game_balances WHERE user_id = ? FOR UPDATE`,
-		userID,
-	).Scan(&currentGameBalance)
-
-	if err == sql.ErrNoRows {
-		return nil, ErrGameBalanceNotFound
+	// Replay a transfer that already completed under the same key
+	if idempotencyKey != "" {
+		if existing, err := exchangeByIdempotencyKey(tx, userID, idempotencyKey); err != nil {
+			return nil, err
+		} else if existing != nil {
+			return replayExchange(existing, amount)
+		}
 	}
+
+	// Check daily exchange limit (the ledger locks serialize concurrent exchanges)
+	todayExchanged, err := getTodayExchangeAmountTx(tx, userID)
 	if err != nil {
 		return nil, err
 	}
+	if todayExchanged+amount > DailyExchangeLimit {
+		return nil, ErrDailyLimitExceeded
+	}
 
 	// Check sufficient game coins
-	if currentGameBalance < amount {
+	if ledgers.gameBalance < amount {
 		return nil, ErrInsufficientGameCoins
 	}
 
 	now := time.Now()
-	newGameBalance := roundToTwoDecimals(currentGameBalance - amount)
+	newGameBalance := roundToTwoDecimals(ledgers.gameBalance - amount)
 
 	// Deduct game coins
 	_, err = tx.Exec(
@@ -108,25 +123,10 @@ func ExchangeGameCoins(userID int64, amount float64) (*ExchangeRecord, error) {
 		return nil, err
 	}
 
-	// Get current account balance with lock
-	var currentAccountBalance float64
-	var accountStatus string
-	err = tx.QueryRow(
-		`SELECT balance, status FROM user_balances WHERE user_id = ? FOR UPDATE`,
-		userID,
-	).Scan(&currentAccountBalance, &accountStatus)
-
-	if err == sql.ErrNoRows {
-		return nil, ErrBalanceNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	newAccountBalance := currentAccountBalance + usdAmount
-	newStatus := accountStatus
+	newAccountBalance := ledgers.accountBalance + usdAmount
+	newStatus := ledgers.accountStatus
 	// If balance was exhausted and now positive, set to active
-	if accountStatus == BalanceStatusExhausted && newAccountBalance > 0 {
+	if ledgers.accountStatus == BalanceStatusExhausted && newAccountBalance > 0 {
 		newStatus = BalanceStatusActive
 	}
 
@@ -144,14 +144,14 @@ func ExchangeGameCoins(userID int64, amount float64) (*ExchangeRecord, error) {
 	_, err = tx.Exec(
 		`INSERT INTO balance_transactions (user_id, type, amount, balance_after, tokens, description, created_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		userID, "game_exchange", usdAmount, newAccountBalance, 0, "Exchange from game coins", now,
+		userID, TransferGameExchange, usdAmount, newAccountBalance, 0, "Exchange from game coins", now,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	// Re-enable tokens if status changed from exhausted to active
-	if accountStatus == BalanceStatusExhausted && newStatus == BalanceStatusActive {
+	if ledgers.accountStatus == BalanceStatusExhausted && newStatus == BalanceStatusActive {
 		_, err = tx.Exec(`UPDATE api_keys SET is_active = TRUE WHERE user_id = ?`, userID)
 		if err != nil {
 			return nil, err
@@ -159,23 +159,14 @@ func ExchangeGameCoins(userID int64, amount float64) (*ExchangeRecord, error) {
 	}
 
 	// Create exchange record
-	result, err := tx.Exec(
-		`INSERT INTO exchange_records (user_id, game_coins_amount, usd_amount, exchange_rate, status, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		userID, amount, usdAmount, ExchangeRate, "completed", now,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	exchangeID, err := result.LastInsertId()
+	exchangeID, err := insertExchangeRecordTx(tx, userID, amount, usdAmount, idempotencyKey, now)
 	if err != nil {
 		return nil, err
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		return nil, err
+		return nil, &transferCommitError{err: err}
 	}
 
 	return &ExchangeRecord{
@@ -189,7 +180,6 @@ func ExchangeGameCoins(userID int64, amount float64) (*ExchangeRecord, error) {
 	}, nil
 }
 
-
 // getTodayExchangeAmountTx gets today's total exchange amount within a transaction
 func getTodayExchangeAmountTx(tx *sql.Tx, userID int64) (float64, error) {
 	var total sql.NullFloat64
@@ -197,7 +187,7 @@ func getTodayExchangeAmountTx(tx *sql.Tx, userID int64) (float64, error) {
 
 	err := tx.QueryRow(
 		`SELECT SUM(game_coins_amount) FROM exchange_records 
-		 WHERE user_id = ? AND DATE(created_at) = ? AND status = 'completed'`,
+		 WHERE user_id = ? AND DATE(created_at) = ? AND status = 'completed' AND game_coins_amount > 0`,
 		userID, today,
 	).Scan(&total)
 
@@ -219,7 +209,7 @@ func GetTodayExchangeAmount(userID int64) (float64, error) {
 
 	err := db.QueryRow(
 		`SELECT SUM(game_coins_amount) FROM exchange_records 
-		 WHERE user_id = ? AND DATE(created_at) = ? AND status = 'completed'`,
+		 WHERE user_id = ? AND DATE(created_at) = ? AND status = 'completed' AND game_coins_amount > 0`,
 		userID, today,
 	).Scan(&total)
 
@@ -345,13 +335,16 @@ func GetExchangeStats() (*ExchangeStats, error) {
 }
 
 // ExchangeUSDToGameCoins exchanges account balance (USD) for game coins
-// This is an atomic transaction that:
+// Both ledgers are updated in one transaction that:
 // 1. Validates the exchange amount
-// 2. Deducts USD from user's account balance
-// 3. Adds game coins to user's game balance
-// 4. Creates exchange record
-// 5. Creates transaction records for both account balance and game coins
-func ExchangeUSDToGameCoins(userID int64, usdAmount float64) (*ExchangeRecord, error) {
+// 2. Locks the account balance, then the game balance (see lockTransferLedgersTx)
+// 3. Returns the earlier result if idempotencyKey was already used
+// 4. Deducts USD from user's account balance
+// 5. Adds game coins to user's game balance
+// 6. Creates exchange record
+// 7. Creates transaction records for both account balance and game coins
+// A transfer that fails after validation leaves a compensation record
+func ExchangeUSDToGameCoins(userID int64, usdAmount float64, idempotencyKey string) (*ExchangeRecord, error) {
 	// Validate amount
 	if usdAmount <= 0 {
 		return nil, ErrInvalidAmount
@@ -363,37 +356,43 @@ func ExchangeUSDToGameCoins(userID int64, usdAmount float64) (*ExchangeRecord, e
 	usdAmount = roundToTwoDecimals(usdAmount)
 	gameCoinsAmount := usdAmount * ExchangeRate // 1:1 rate
 
-	// Start transaction
+	record, err := purchaseGameCoinsTx(userID, usdAmount, gameCoinsAmount, idempotencyKey)
+	if err != nil {
+		return finishFailedTransfer(TransferGamePurchase, userID, gameCoinsAmount, usdAmount, idempotencyKey, err)
+	}
+	return record, nil
+}
+
+// purchaseGameCoinsTx runs the USD → game coin transfer in a single transaction
+func purchaseGameCoinsTx(userID int64, usdAmount, gameCoinsAmount float64, idempotencyKey string) (*ExchangeRecord, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	now := time.Now()
-
-	// Get current account balance with lock
-	var currentAccountBalance float64
-	var accountStatus string
-	err = tx.QueryRow(
-		`SELECT balance, status FROM user_balances WHERE user_id = ? FOR UPDATE`,
-		userID,
-	).Scan(&currentAccountBalance, &accountStatus)
-
-	if err == sql.ErrNoRows {
-		return nil, ErrBalanceNotFound
-	}
+	ledgers, err := lockTransferLedgersTx(tx, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Replay a transfer that already completed under the same key
+	if idempotencyKey != "" {
+		if existing, err := exchangeByIdempotencyKey(tx, userID, idempotencyKey); err != nil {
+			return nil, err
+		} else if existing != nil {
+			return replayExchange(existing, -gameCoinsAmount)
+		}
+	}
+
 	// Check sufficient account balance
-	if currentAccountBalance < usdAmount {
+	if ledgers.accountBalance < usdAmount {
 		return nil, ErrInsufficientBalance
 	}
 
-	newAccountBalance := roundToTwoDecimals(currentAccountBalance - usdAmount)
-	newStatus := accountStatus
+	now := time.Now()
+	newAccountBalance := roundToTwoDecimals(ledgers.accountBalance - usdAmount)
+	newStatus := ledgers.accountStatus
 	// If balance becomes zero or negative, set to exhausted
 	if newAccountBalance <= 0 {
 		newStatus = BalanceStatusExhausted
@@ -413,7 +412,7 @@ func ExchangeUSDToGameCoins(userID int64, usdAmount float64) (*ExchangeRecord, e
 	_, err = tx.Exec(
 		`INSERT INTO balance_transactions (user_id, type, amount, balance_after, tokens, description, created_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		userID, "game_purchase", -usdAmount, newAccountBalance, 0, "Purchase game coins", now,
+		userID, TransferGamePurchase, -usdAmount, newAccountBalance, 0, "Purchase game coins", now,
 	)
 	if err != nil {
 		return nil, err
@@ -427,40 +426,23 @@ func ExchangeUSDToGameCoins(userID int64, usdAmount float64) (*ExchangeRecord, e
 		}
 	}
 
-	// Get current game coin balance with lock
-	var currentGameBalance float64
-	err = tx.QueryRow(
-		`SELECT balance FROM user_game_balances WHERE user_id = ? FOR UPDATE`,
-		userID,
-	).Scan(&currentGameBalance)
-
-	if err == sql.ErrNoRows {
-		// Create game balance if not exists
-		_, err = tx.Exec(
-			`INSERT INTO user_game_balances (user_id, balance, total_won, total_lost, total_exchanged, games_played, created_at, updated_at)
-			 VALUES (?, ?, 0, 0, 0, 0, ?, ?)`,
-			userID, gameCoinsAmount, now, now,
-		)
-		if err != nil {
-			return nil, err
-		}
-		currentGameBalance = 0
-	} else if err != nil {
-		return nil, err
-	}
-
-	newGameBalance := roundToTwoDecimals(currentGameBalance + gameCoinsAmount)
-
-	// Add game coins (only if balance already existed)
-	if currentGameBalance > 0 || err == nil {
+	// Add game coins, creating the game balance if it does not exist yet
+	newGameBalance := roundToTwoDecimals(ledgers.gameBalance + gameCoinsAmount)
+	if ledgers.gameExists {
 		_, err = tx.Exec(
 			`UPDATE user_game_balances SET balance = ?, updated_at = ?
 			 WHERE user_id = ?`,
 			newGameBalance, now, userID,
 		)
-		if err != nil {
-			return nil, err
-		}
+	} else {
+		_, err = tx.Exec(
+			`INSERT INTO user_game_balances (user_id, balance, total_won, total_lost, total_exchanged, games_played, created_at, updated_at)
+			 VALUES (?, ?, 0, 0, 0, 0, ?, ?)`,
+			userID, newGameBalance, now, now,
+		)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// Create game coin transaction record (positive amount for purchase)
@@ -473,31 +455,22 @@ func ExchangeUSDToGameCoins(userID int64, usdAmount float64) (*ExchangeRecord, e
 		return nil, err
 	}
 
-	// Create exchange record (with negative game_coins_amount to indicate reverse direction)
-	result, err := tx.Exec(
-		`INSERT INTO exchange_records (user_id, game_coins_amount, usd_amount, exchange_rate, status, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		userID, -gameCoinsAmount, -usdAmount, ExchangeRate, "completed", now,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	exchangeID, err := result.LastInsertId()
+	// Create exchange record (with negative amounts to indicate reverse direction)
+	exchangeID, err := insertExchangeRecordTx(tx, userID, -gameCoinsAmount, -usdAmount, idempotencyKey, now)
 	if err != nil {
 		return nil, err
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		return nil, err
+		return nil, &transferCommitError{err: err}
 	}
 
 	return &ExchangeRecord{
 		ID:              exchangeID,
 		UserID:          userID,
-		GameCoinsAmount: gameCoinsAmount,  // Return positive for display
-		USDAmount:       usdAmount,        // Return positive for display
+		GameCoinsAmount: gameCoinsAmount, // Return positive for display
+		USDAmount:       usdAmount,       // Return positive for display
 		ExchangeRate:    ExchangeRate,
 		Status:          "completed",
 		CreatedAt:       now,
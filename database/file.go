@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// File 用户上传文件的元数据；内容由 Storage 指定的存储后端按 StorageKey 保存
+type File struct {
+	ID          string    `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Filename    string    `json:"filename"`
+	Purpose     string    `json:"purpose"`
+	Bytes       int64     `json:"bytes"`
+	ContentType string    `json:"content_type"`
+	Storage     string    `json:"-"`
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const fileColumns = `id, user_id, filename, purpose, bytes, content_type, storage, storage_key, created_at`
+
+func scanFile(row interface{ Scan(...interface{}) error }) (*File, error) {
+	f := &File{}
+	if err := row.Scan(
+		&f.ID,
+		&f.UserID,
+		&f.Filename,
+		&f.Purpose,
+		&f.Bytes,
+		&f.ContentType,
+		&f.Storage,
+		&f.StorageKey,
+		&f.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// CreateFile 保存文件元数据
+func CreateFile(f *File) error {
+	f.CreatedAt = time.Now()
+	_, err := db.Exec(
+		`INSERT INTO files (id, user_id, filename, purpose, bytes, content_type, storage, storage_key, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.ID, f.UserID, f.Filename, f.Purpose, f.Bytes, f.ContentType, f.Storage, f.StorageKey, f.CreatedAt,
+	)
+	return err
+}
+
+// GetFile 获取文件元数据
+func GetFile(id string) (*File, error) {
+	f, err := scanFile(db.QueryRow(`SELECT `+fileColumns+` FROM files WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrFileNotFound
+	}
+	return f, err
+}
+
+// ListFilesByUser 获取用户最近上传的文件，purpose 为空时不按用途过滤
+func ListFilesByUser(userID int64, purpose string, limit int) ([]*File, error) {
+	query := `SELECT ` + fileColumns + ` FROM files WHERE user_id = ?`
+	args := []interface{}{userID}
+	if purpose != "" {
+		query += ` AND purpose = ?`
+		args = append(args, purpose)
+	}
+	query += ` ORDER BY created_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]*File, 0)
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// DeleteFile 删除文件元数据
+func DeleteFile(id string) error {
+	result, err := db.Exec(`DELETE FROM files WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
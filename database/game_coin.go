@@ -29,9 +29,10 @@ const (
 
 // Game types
 const (
-	GameTypeWheel  = "wheel"
-	GameTypeCoin   = "coin"
-	GameTypeNumber = "number"
+	GameTypeWheel   = "wheel"
+	GameTypeCoin    = "coin"
+	GameTypeNumber  = "number"
+	GameTypeCheckin = "checkin" // Daily check-in rewards paid in game coins
 )
 
 // Errors for game coin system
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrDatabaseUnavailable = errors.New("database is temporarily unavailable")
+)
+
+var (
+	degraded       atomic.Bool
+	degradedSince  atomic.Int64
+	degradedWindow = 5 * time.Minute
+
+	recoverHooks   []func()
+	recoverHooksMu sync.Mutex
+)
+
+// IsConnectionError 判断错误是否由数据库连接不可用引起（而非 SQL 本身的错误）
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.Is(err, ErrDatabaseUnavailable) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"connection refused", "broken pipe", "bad connection", "i/o timeout", "server has gone away", "too many connections"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportError 上报数据库错误，连接类错误会让系统进入降级模式
+func ReportError(err error) {
+	if IsConnectionError(err) {
+		markDegraded(err)
+	}
+}
+
+func markDegraded(err error) {
+	if degraded.CompareAndSwap(false, true) {
+		degradedSince.Store(time.Now().UnixNano())
+		dbLog.WithError(err).Error("Database unavailable, entering degraded read-only mode")
+	}
+}
+
+// IsDegraded 数据库当前是否不可用
+func IsDegraded() bool {
+	return degraded.Load()
+}
+
+// DegradedSince 返回进入降级模式的时间，未降级时返回零值
+func DegradedSince() time.Time {
+	if !degraded.Load() {
+		return time.Time{}
+	}
+	return time.Unix(0, degradedSince.Load())
+}
+
+// WithinDegradedWindow 降级持续时间是否仍在允许使用缓存凭据的窗口内
+func WithinDegradedWindow() bool {
+	if !degraded.Load() {
+		return false
+	}
+	return time.Since(DegradedSince()) < degradedWindow
+}
+
+// OnRecover 注册数据库恢复后执行的回调（如重放计费日志）
+func OnRecover(fn func()) {
+	recoverHooksMu.Lock()
+	defer recoverHooksMu.Unlock()
+	recoverHooks = append(recoverHooks, fn)
+}
+
+// StartHealthMonitor 启动数据库健康检查，window 为降级模式下缓存凭据的有效期
+func StartHealthMonitor(interval, window time.Duration) {
+	if window > 0 {
+		degradedWindow = window
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := db.PingContext(ctx)
+			cancel()
+
+			if err != nil {
+				markDegraded(err)
+				continue
+			}
+
+			if degraded.CompareAndSwap(true, false) {
+				dbLog.Infof("Database connection restored after %s, leaving degraded mode",
+					time.Since(time.Unix(0, degradedSince.Load())).Round(time.Second))
+				runRecoverHooks()
+			}
+		}
+	}()
+}
+
+func runRecoverHooks() {
+	recoverHooksMu.Lock()
+	hooks := make([]func(), len(recoverHooks))
+	copy(hooks, recoverHooks)
+	recoverHooksMu.Unlock()
+
+	for _, hook := range hooks {
+		go hook()
+	}
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TransactionTypeImport marks balance history imported from another gateway
+const TransactionTypeImport = "import"
+
+// ImportUser 待导入的用户（含余额汇总）
+type ImportUser struct {
+	SourceID       int64
+	Username       string
+	Email          string
+	PasswordHash   string // bcrypt hash, copied as-is
+	Role           string
+	IsActive       bool
+	Balance        float64
+	TotalConsumed  float64
+	TotalRecharged float64
+}
+
+// ImportToken 待导入的 API 密钥
+type ImportToken struct {
+	SourceUserID  int64
+	Key           string
+	Name          string
+	IsActive      bool
+	QuotaLimit    *float64
+	QuotaUsed     float64
+	ExpiresAt     *time.Time
+	AllowedModels []string
+	CreatedAt     time.Time
+	LastUsedAt    *time.Time
+}
+
+// ImportTransaction 待导入的余额流水
+type ImportTransaction struct {
+	SourceUserID int64
+	Type         string
+	Amount       float64
+	BalanceAfter float64
+	Tokens       int
+	Description  string
+	APIToken     string
+	Model        string
+	CreatedAt    time.Time
+}
+
+// ImportUsage 待导入的调用记录
+type ImportUsage struct {
+	SourceUserID     int64
+	Username         string
+	APIToken         string
+	TokenName        string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	RequestTime      time.Time
+	DurationMs       int
+}
+
+// ImportData 一次导入的全部数据
+type ImportData struct {
+	Users        []*ImportUser
+	Tokens       []*ImportToken
+	Transactions []*ImportTransaction
+	Usage        []*ImportUsage
+}
+
+// ImportResult 导入结果统计
+type ImportResult struct {
+	DryRun               bool     `json:"dry_run"`
+	UsersCreated         int      `json:"users_created"`
+	UsersSkipped         int      `json:"users_skipped"`
+	TokensCreated        int      `json:"tokens_created"`
+	TokensSkipped        int      `json:"tokens_skipped"`
+	TransactionsImported int      `json:"transactions_imported"`
+	UsageImported        int      `json:"usage_imported"`
+	Warnings             []string `json:"warnings"`
+}
+
+// ImportGatewayData 在单个事务中写入导入数据；dryRun 为 true 时执行全部写入后回滚，
+// 用于在不修改数据的情况下校验导入文件
+func ImportGatewayData(data *ImportData, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun, Warnings: []string{}}
+	warn := func(format string, args ...interface{}) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	// 源用户ID -> 本系统用户ID；已存在的用户只映射，不导入余额和流水
+	userIDs := make(map[int64]int64)
+	usernames := make(map[int64]string)
+	created := make(map[int64]bool)
+
+	for _, u := range data.Users {
+		var existingID int64
+		err := tx.QueryRow(
+			`SELECT id FROM users WHERE username = ? OR email = ? LIMIT 1`,
+			u.Username, u.Email,
+		).Scan(&existingID)
+		if err == nil {
+			warn("user %q already exists, linking to existing account without importing balance", u.Username)
+			userIDs[u.SourceID] = existingID
+			usernames[u.SourceID] = u.Username
+			result.UsersSkipped++
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		res, err := tx.Exec(
+			`INSERT INTO users (username, email, password_hash, role, created_at, is_active)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			u.Username, u.Email, u.PasswordHash, u.Role, now, u.IsActive,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import user %q: %w", u.Username, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		referralCode, err := generateUniqueReferralCode()
+		if err != nil {
+			return nil, err
+		}
+		status := BalanceStatusActive
+		if u.Balance <= 0 {
+			status = BalanceStatusExhausted
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO user_balances (user_id, balance, status, referral_code, total_consumed, total_recharged, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, u.Balance, status, referralCode, u.TotalConsumed, u.TotalRecharged, now, now,
+		); err != nil {
+			return nil, fmt.Errorf("failed to import balance for %q: %w", u.Username, err)
+		}
+
+		userIDs[u.SourceID] = id
+		usernames[u.SourceID] = u.Username
+		created[u.SourceID] = true
+		result.UsersCreated++
+	}
+
+	for _, t := range data.Tokens {
+		userID, ok := userIDs[t.SourceUserID]
+		if !ok {
+			warn("token %q references unknown user %d, skipped", t.Name, t.SourceUserID)
+			result.TokensSkipped++
+			continue
+		}
+
+		var exists bool
+		if err := tx.QueryRow(
+			`SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_value = ?)`, t.Key,
+		).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			warn("token %q (%s) already exists, skipped", t.Name, maskKey(t.Key))
+			result.TokensSkipped++
+			continue
+		}
+
+		var allowedModelsJSON *string
+		if len(t.AllowedModels) > 0 {
+			encoded, _ := json.Marshal(t.AllowedModels)
+			models := string(encoded)
+			allowedModelsJSON = &models
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO api_keys (key_value, masked_key, token_name, user_id, created_at, usage_count, last_used_at, is_active, quota_limit, quota_used, expires_at, allowed_models)
+			 VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, ?)`,
+			t.Key, maskKey(t.Key), t.Name, userID, t.CreatedAt, t.LastUsedAt, t.IsActive,
+			t.QuotaLimit, t.QuotaUsed, t.ExpiresAt, allowedModelsJSON,
+		); err != nil {
+			return nil, fmt.Errorf("failed to import token %q: %w", t.Name, err)
+		}
+		result.TokensCreated++
+	}
+
+	for _, t := range data.Transactions {
+		if !created[t.SourceUserID] {
+			continue
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO balance_transactions (user_id, type, amount, balance_after, tokens, description, api_token, model, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			userIDs[t.SourceUserID], t.Type, t.Amount, t.BalanceAfter, t.Tokens, t.Description, t.APIToken, t.Model, t.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to import transaction: %w", err)
+		}
+		result.TransactionsImported++
+	}
+
+	for _, u := range data.Usage {
+		if !created[u.SourceUserID] {
+			continue
+		}
+		username := u.Username
+		if username == "" {
+			username = usernames[u.SourceUserID]
+		}
+		responseTime := u.RequestTime.Add(time.Duration(u.DurationMs) * time.Millisecond)
+		if _, err := tx.Exec(
+			`INSERT INTO usage_records (user_id, username, api_token, token_name, model, prompt_tokens, completion_tokens, total_tokens,
+			 cursor_session, status_code, error_message, request_time, response_time, duration_ms)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			userIDs[u.SourceUserID], username, usageRecordToken(u.APIToken), u.TokenName, u.Model,
+			u.PromptTokens, u.CompletionTokens, u.PromptTokens+u.CompletionTokens,
+			"imported", 200, "", u.RequestTime, responseTime, u.DurationMs,
+		); err != nil {
+			return nil, fmt.Errorf("failed to import usage record: %w", err)
+		}
+		result.UsageImported++
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
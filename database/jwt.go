@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SettingKeyJWTSecret 未配置 JWT_SECRET 时自动生成并保存的 JWT 签名密钥
+const SettingKeyJWTSecret = "jwt_signing_secret"
+
+// UserAuthState JWT 模式下校验令牌所需的用户状态
+type UserAuthState struct {
+	UserID           int64
+	Username         string
+	Role             string
+	IsActive         bool
+	TokensValidAfter time.Time // 早于该时间签发的令牌均已吊销，零值表示没有
+	// 早于该时间签发的带 sudo 截止时间的访问令牌均已吊销（退出 sudo 模式时设置），零值表示没有
+	ElevationValidAfter time.Time
+}
+
+// GetUserAuthState 查询用户的角色、启用状态与令牌吊销时间
+func GetUserAuthState(userID int64) (*UserAuthState, error) {
+	state := &UserAuthState{UserID: userID}
+	var validAfter, elevationValidAfter sql.NullTime
+	err := db.QueryRow(
+		`SELECT username, role, is_active, tokens_valid_after, elevation_valid_after FROM users WHERE id = ?`,
+		userID,
+	).Scan(&state.Username, &state.Role, &state.IsActive, &validAfter, &elevationValidAfter)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if validAfter.Valid {
+		state.TokensValidAfter = validAfter.Time
+	}
+	if elevationValidAfter.Valid {
+		state.ElevationValidAfter = elevationValidAfter.Time
+	}
+	return state, nil
+}
+
+// setUserTokenCutoff 把用户的 column 设为下一毫秒并等到该时刻再返回。令牌签发时间精确到毫秒，
+// 因此此前签发的令牌一律早于生效时间，返回后签发的令牌（如为当前设备换发的令牌）都不早于它
+func setUserTokenCutoff(column string, userID int64) (time.Time, error) {
+	validAfter := time.Now().Truncate(time.Millisecond).Add(time.Millisecond)
+	if _, err := db.Exec(`UPDATE users SET `+column+` = ? WHERE id = ?`, validAfter, userID); err != nil {
+		return validAfter, err
+	}
+	time.Sleep(time.Until(validAfter))
+	return validAfter, nil
+}
+
+// RevokeUserTokens 吊销用户此前签发的全部 JWT，返回生效时间
+func RevokeUserTokens(userID int64) (time.Time, error) {
+	return setUserTokenCutoff("tokens_valid_after", userID)
+}
+
+// RevokeUserElevation 吊销用户此前签发的全部带 sudo 截止时间的访问令牌（JWT 模式退出 sudo 模式），返回生效时间
+func RevokeUserElevation(userID int64) (time.Time, error) {
+	return setUserTokenCutoff("elevation_valid_after", userID)
+}
+
+// RecordLoginDevice 记录 JWT 模式下用户从该设备指纹登录的时间（该模式没有会话记录可供判断新设备）
+func RecordLoginDevice(userID int64, fingerprint string) error {
+	_, err := db.Exec(
+		`INSERT INTO login_devices (user_id, fingerprint, last_login_at) VALUES (?, ?, ?)
+		 ON DUPLICATE KEY UPDATE last_login_at = VALUES(last_login_at)`,
+		userID, fingerprint, time.Now(),
+	)
+	return err
+}
+
+// IsKnownLoginDevice 判断用户自 since 以来是否从相同指纹的设备登录过（JWT 模式）
+func IsKnownLoginDevice(userID int64, fingerprint string, since time.Time) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM login_devices WHERE user_id = ? AND fingerprint = ? AND last_login_at > ?`,
+		userID, fingerprint, since,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Latency SLO metrics
+const (
+	LatencyMetricTTFT  = "ttft"  // Time to first byte of the response
+	LatencyMetricTotal = "total" // Time until the response completes
+)
+
+var (
+	ErrLatencySLONotFound = errors.New("latency SLO not found")
+)
+
+// LatencySLO 延迟预算：在 endpoint/model 上的某个分位延迟不得超过阈值
+type LatencySLO struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	Endpoint           string    `json:"endpoint"` // 为空匹配所有端点
+	Model              string    `json:"model"`    // 为空匹配所有模型
+	Metric             string    `json:"metric"`
+	Percentile         float64   `json:"percentile"`
+	ThresholdMs        int       `json:"threshold_ms"`
+	ConsecutiveWindows int       `json:"consecutive_windows"`
+	MinSamples         int       `json:"min_samples"`
+	IsActive           bool      `json:"is_active"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// LatencyAlert 延迟预算告警记录
+type LatencyAlert struct {
+	ID          int64      `json:"id"`
+	SLOID       int64      `json:"slo_id"`
+	SLOName     string     `json:"slo_name"`
+	ObservedMs  int        `json:"observed_ms"`
+	ThresholdMs int        `json:"threshold_ms"`
+	Windows     int        `json:"windows"`
+	Samples     int        `json:"samples"`
+	Message     string     `json:"message"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+const latencySLOColumns = `id, name, endpoint, model, metric, percentile, threshold_ms, consecutive_windows, min_samples, is_active, created_at, updated_at`
+
+func scanLatencySLO(row interface{ Scan(...interface{}) error }) (*LatencySLO, error) {
+	slo := &LatencySLO{}
+	err := row.Scan(
+		&slo.ID,
+		&slo.Name,
+		&slo.Endpoint,
+		&slo.Model,
+		&slo.Metric,
+		&slo.Percentile,
+		&slo.ThresholdMs,
+		&slo.ConsecutiveWindows,
+		&slo.MinSamples,
+		&slo.IsActive,
+		&slo.CreatedAt,
+		&slo.UpdatedAt,
+	)
+	return slo, err
+}
+
+// CreateLatencySLO 创建延迟预算
+func CreateLatencySLO(slo *LatencySLO) error {
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO latency_slos (name, endpoint, model, metric, percentile, threshold_ms, consecutive_windows, min_samples, is_active, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		slo.Name, slo.Endpoint, slo.Model, slo.Metric, slo.Percentile, slo.ThresholdMs,
+		slo.ConsecutiveWindows, slo.MinSamples, slo.IsActive, now, now,
+	)
+	if err != nil {
+		return err
+	}
+	slo.ID, err = result.LastInsertId()
+	slo.CreatedAt = now
+	slo.UpdatedAt = now
+	return err
+}
+
+// GetLatencySLO 根据ID获取延迟预算
+func GetLatencySLO(id int64) (*LatencySLO, error) {
+	slo, err := scanLatencySLO(db.QueryRow(
+		`SELECT `+latencySLOColumns+` FROM latency_slos WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrLatencySLONotFound
+	}
+	return slo, err
+}
+
+// ListLatencySLOs 获取所有延迟预算；activeOnly 为 true 时只返回启用的
+func ListLatencySLOs(activeOnly bool) ([]*LatencySLO, error) {
+	query := `SELECT ` + latencySLOColumns + ` FROM latency_slos`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	rows, err := db.Query(query + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slos := []*LatencySLO{}
+	for rows.Next() {
+		slo, err := scanLatencySLO(rows)
+		if err != nil {
+			return nil, err
+		}
+		slos = append(slos, slo)
+	}
+	return slos, rows.Err()
+}
+
+// UpdateLatencySLO 更新延迟预算
+func UpdateLatencySLO(slo *LatencySLO) error {
+	result, err := db.Exec(
+		`UPDATE latency_slos SET name = ?, endpoint = ?, model = ?, metric = ?, percentile = ?, threshold_ms = ?,
+		 consecutive_windows = ?, min_samples = ?, is_active = ?, updated_at = ? WHERE id = ?`,
+		slo.Name, slo.Endpoint, slo.Model, slo.Metric, slo.Percentile, slo.ThresholdMs,
+		slo.ConsecutiveWindows, slo.MinSamples, slo.IsActive, time.Now(), slo.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLatencySLONotFound
+	}
+	return nil
+}
+
+// DeleteLatencySLO 删除延迟预算（告警记录级联删除）
+func DeleteLatencySLO(id int64) error {
+	result, err := db.Exec(`DELETE FROM latency_slos WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLatencySLONotFound
+	}
+	return nil
+}
+
+// CreateLatencyAlert 记录一次延迟预算告警
+func CreateLatencyAlert(alert *LatencyAlert) error {
+	alert.CreatedAt = time.Now()
+	result, err := db.Exec(
+		`INSERT INTO latency_alerts (slo_id, observed_ms, threshold_ms, windows, samples, message, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		alert.SLOID, alert.ObservedMs, alert.ThresholdMs, alert.Windows, alert.Samples, alert.Message, alert.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	alert.ID, err = result.LastInsertId()
+	return err
+}
+
+// ResolveLatencyAlerts 将某个延迟预算所有未恢复的告警标记为已恢复
+func ResolveLatencyAlerts(sloID int64) error {
+	_, err := db.Exec(
+		`UPDATE latency_alerts SET resolved_at = ? WHERE slo_id = ? AND resolved_at IS NULL`,
+		time.Now(), sloID,
+	)
+	return err
+}
+
+// ListLatencyAlerts 获取最近的告警；openOnly 为 true 时只返回未恢复的
+func ListLatencyAlerts(openOnly bool, limit int) ([]*LatencyAlert, error) {
+	query := `SELECT a.id, a.slo_id, s.name, a.observed_ms, a.threshold_ms, a.windows, a.samples, a.message, a.created_at, a.resolved_at
+		 FROM latency_alerts a JOIN latency_slos s ON s.id = a.slo_id`
+	if openOnly {
+		query += ` WHERE a.resolved_at IS NULL`
+	}
+	rows, err := db.Query(query+` ORDER BY a.created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*LatencyAlert{}
+	for rows.Next() {
+		alert := &LatencyAlert{}
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(
+			&alert.ID,
+			&alert.SLOID,
+			&alert.SLOName,
+			&alert.ObservedMs,
+			&alert.ThresholdMs,
+			&alert.Windows,
+			&alert.Samples,
+			&alert.Message,
+			&alert.CreatedAt,
+			&resolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,251 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Transfers between the account balance and the game coin ledger; also used as
+// the balance_transactions type of the account side
+const (
+	TransferGameExchange = "game_exchange" // game coins → account balance
+	TransferGamePurchase = "game_purchase" // account balance → game coins
+)
+
+// Compensation record statuses
+const (
+	CompensationRolledBack = "rolled_back" // The transaction was rolled back; neither ledger changed
+	CompensationUnknown    = "unknown"     // The commit failed and its outcome could not be verified
+	CompensationResolved   = "resolved"    // Reviewed by an admin
+)
+
+// MaxIdempotencyKeyLength is the longest idempotency key accepted for a transfer
+const MaxIdempotencyKeyLength = 64
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different transfer")
+	ErrCompensationNotFound = errors.New("compensation record not found")
+)
+
+// TransferCompensation records a game coin transfer that failed after validation,
+// so a failed or unverifiable transfer can be reviewed and reconciled
+type TransferCompensation struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	Operation       string     `json:"operation"`
+	IdempotencyKey  string     `json:"idempotency_key,omitempty"`
+	GameCoinsAmount float64    `json:"game_coins_amount"`
+	USDAmount       float64    `json:"usd_amount"`
+	Status          string     `json:"status"`
+	Error           string     `json:"error"`
+	ResolutionNote  string     `json:"resolution_note,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+// transferLedgers is the locked state of both ledgers for a transfer
+type transferLedgers struct {
+	accountBalance float64
+	accountStatus  string
+	gameBalance    float64
+	gameExists     bool
+}
+
+// transferCommitError wraps a failed commit, whose outcome is unknown to the caller
+type transferCommitError struct {
+	err error
+}
+
+func (e *transferCommitError) Error() string { return "commit transfer: " + e.err.Error() }
+func (e *transferCommitError) Unwrap() error { return e.err }
+
+// lockTransferLedgersTx locks the user's account balance and then the game balance.
+// Every transfer takes the locks in this order so opposite transfers cannot deadlock
+func lockTransferLedgersTx(tx *sql.Tx, userID int64) (*transferLedgers, error) {
+	ledgers := &transferLedgers{}
+	err := tx.QueryRow(
+		`SELECT balance, status FROM user_balances WHERE user_id = ? FOR UPDATE`,
+		userID,
+	).Scan(&ledgers.accountBalance, &ledgers.accountStatus)
+	if err == sql.ErrNoRows {
+		return nil, ErrBalanceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(
+		`SELECT balance FROM user_game_balances WHERE user_id = ? FOR UPDATE`,
+		userID,
+	).Scan(&ledgers.gameBalance)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	ledgers.gameExists = err == nil
+	return ledgers, nil
+}
+
+// exchangeByIdempotencyKey returns the exchange record stored under key, or nil
+func exchangeByIdempotencyKey(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, userID int64, key string) (*ExchangeRecord, error) {
+	record := &ExchangeRecord{UserID: userID}
+	err := q.QueryRow(
+		`SELECT id, game_coins_amount, usd_amount, exchange_rate, status, created_at
+		 FROM exchange_records WHERE user_id = ? AND idempotency_key = ?`,
+		userID, key,
+	).Scan(&record.ID, &record.GameCoinsAmount, &record.USDAmount, &record.ExchangeRate, &record.Status, &record.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// replayExchange returns an earlier transfer for a repeated idempotency key.
+// gameCoinsAmount is the signed amount the new request would have recorded
+func replayExchange(existing *ExchangeRecord, gameCoinsAmount float64) (*ExchangeRecord, error) {
+	if roundToTwoDecimals(existing.GameCoinsAmount) != roundToTwoDecimals(gameCoinsAmount) {
+		return nil, ErrIdempotencyKeyReused
+	}
+	// Purchases are stored with negative amounts; return positive amounts for display
+	if existing.GameCoinsAmount < 0 {
+		existing.GameCoinsAmount = -existing.GameCoinsAmount
+		existing.USDAmount = -existing.USDAmount
+	}
+	existing.Replayed = true
+	return existing, nil
+}
+
+// insertExchangeRecordTx creates the completed exchange record of a transfer
+func insertExchangeRecordTx(tx *sql.Tx, userID int64, gameCoinsAmount, usdAmount float64, idempotencyKey string, now time.Time) (int64, error) {
+	var key interface{}
+	if idempotencyKey != "" {
+		key = idempotencyKey
+	}
+	result, err := tx.Exec(
+		`INSERT INTO exchange_records (user_id, game_coins_amount, usd_amount, exchange_rate, status, idempotency_key, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, gameCoinsAmount, usdAmount, ExchangeRate, "completed", key, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// isTransferRejection reports whether err is a business rule rejection rather than a failure
+func isTransferRejection(err error) bool {
+	switch err {
+	case ErrInvalidAmount, ErrBelowMinimumExchange, ErrDailyLimitExceeded, ErrGameBalanceNotFound,
+		ErrInsufficientGameCoins, ErrBalanceNotFound, ErrInsufficientBalance, ErrIdempotencyKeyReused:
+		return true
+	}
+	return false
+}
+
+// finishFailedTransfer records a compensation entry for a transfer that failed.
+// When the commit itself failed, a transfer with an idempotency key is looked up
+// again: if it was committed after all, its record is returned instead of the error
+func finishFailedTransfer(operation string, userID int64, gameCoinsAmount, usdAmount float64, idempotencyKey string, err error) (*ExchangeRecord, error) {
+	if isTransferRejection(err) {
+		return nil, err
+	}
+
+	status := CompensationRolledBack
+	var commitErr *transferCommitError
+	if errors.As(err, &commitErr) {
+		status = CompensationUnknown
+		if idempotencyKey != "" {
+			existing, lookupErr := exchangeByIdempotencyKey(db, userID, idempotencyKey)
+			if lookupErr == nil && existing != nil {
+				dbLog.WithField("user_id", userID).Warn("Transfer commit reported an error but the transfer was committed")
+				return existing, nil
+			}
+			if lookupErr == nil {
+				status = CompensationRolledBack
+			}
+		}
+	}
+
+	var key interface{}
+	if idempotencyKey != "" {
+		key = idempotencyKey
+	}
+	errMsg := err.Error()
+	if len(errMsg) > 500 {
+		errMsg = errMsg[:500]
+	}
+	if _, insertErr := db.Exec(
+		`INSERT INTO transfer_compensations (user_id, operation, idempotency_key, game_coins_amount, usd_amount, status, error, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, operation, key, gameCoinsAmount, usdAmount, status, errMsg, time.Now(),
+	); insertErr != nil {
+		dbLog.WithError(insertErr).WithField("user_id", userID).Error("Failed to record transfer compensation")
+	}
+	dbLog.WithError(err).WithField("user_id", userID).WithField("operation", operation).
+		WithField("compensation_status", status).Error("Game coin transfer failed")
+	return nil, err
+}
+
+// ListTransferCompensations returns compensation records, newest first; status filters when not empty
+func ListTransferCompensations(status string, limit, offset int) ([]*TransferCompensation, int, error) {
+	where := ""
+	args := []interface{}{}
+	if status != "" {
+		where = ` WHERE status = ?`
+		args = append(args, status)
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM transfer_compensations`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(
+		`SELECT id, user_id, operation, idempotency_key, game_coins_amount, usd_amount, status, error,
+		        resolution_note, created_at, resolved_at
+		 FROM transfer_compensations`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	records := []*TransferCompensation{}
+	for rows.Next() {
+		record := &TransferCompensation{}
+		var key, note sql.NullString
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&record.ID, &record.UserID, &record.Operation, &key, &record.GameCoinsAmount,
+			&record.USDAmount, &record.Status, &record.Error, &note, &record.CreatedAt, &resolvedAt); err != nil {
+			return nil, 0, err
+		}
+		record.IdempotencyKey = key.String
+		record.ResolutionNote = note.String
+		if resolvedAt.Valid {
+			record.ResolvedAt = &resolvedAt.Time
+		}
+		records = append(records, record)
+	}
+	return records, total, rows.Err()
+}
+
+// ResolveTransferCompensation marks a compensation record as reviewed
+func ResolveTransferCompensation(id int64, note string) error {
+	result, err := db.Exec(
+		`UPDATE transfer_compensations SET status = ?, resolution_note = ?, resolved_at = ? WHERE id = ?`,
+		CompensationResolved, note, time.Now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrCompensationNotFound
+	}
+	return nil
+}
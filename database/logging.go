@@ -0,0 +1,21 @@
+package database
+
+// SettingKeyLogLevels 管理员在运行时调整的各子系统日志级别（JSON，子系统 -> 级别）
+const SettingKeyLogLevels = "log_levels"
+
+// GetLogLevels 获取保存的日志级别，未配置时返回 nil
+func GetLogLevels() (map[string]string, error) {
+	levels := make(map[string]string)
+	if err := GetJSONSetting(SettingKeyLogLevels, &levels); err != nil {
+		if err == ErrSettingNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return levels, nil
+}
+
+// SaveLogLevels 保存各子系统日志级别
+func SaveLogLevels(levels map[string]string) error {
+	return SetJSONSetting(SettingKeyLogLevels, levels)
+}
@@ -0,0 +1,30 @@
+package database
+
+// SettingKeyModerationRules 本地审核规则（JSON），无上游审核模型时 /v1/moderations 使用
+const SettingKeyModerationRules = "moderation_rules"
+
+// ModerationRule 本地审核规则：输入匹配 Pattern（正则，不区分大小写）时标记 Category，
+// Score 为该类别的分数（0-1，未设置时为 1）；设置 Languages 时仅对检测为其中任一语言的输入生效
+type ModerationRule struct {
+	Category  string   `json:"category"`
+	Pattern   string   `json:"pattern"`
+	Score     float64  `json:"score,omitempty"`
+	Languages []string `json:"languages,omitempty"` // ISO 639-1 语言代码，如 zh、en
+}
+
+// GetModerationRules 获取本地审核规则，未配置时返回空列表
+func GetModerationRules() ([]ModerationRule, error) {
+	rules := []ModerationRule{}
+	if err := GetJSONSetting(SettingKeyModerationRules, &rules); err != nil {
+		if err == ErrSettingNotFound {
+			return []ModerationRule{}, nil
+		}
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveModerationRules 保存本地审核规则
+func SaveModerationRules(rules []ModerationRule) error {
+	return SetJSONSetting(SettingKeyModerationRules, rules)
+}
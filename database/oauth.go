@@ -2,7 +2,9 @@ package database
 
 import (
 	"Curry2API-go/utils"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
@@ -463,7 +465,7 @@ func FindOrCreateUserFromOAuth(oauthInfo *OAuthUserInfo, provider string) (*User
 
 	// 3. 如果没有找到现有用户，创建新用户
 	if user == nil {
-		newUser, err := CreateUserFromOAuth(oauthInfo)
+		newUser, err := CreateUserFromOAuth(oauthInfo, provider)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create user from oauth: %w", err)
 		}
@@ -526,8 +528,23 @@ func generateUniqueUsername(oauthInfo *OAuthUserInfo) string {
 	return fmt.Sprintf("user_%d", time.Now().Unix())
 }
 
+// oauthPlaceholderEmailDomain 第三方账号不提供邮箱时占位邮箱的域名（.invalid 为保留顶级域，不会有真实收件箱）
+const oauthPlaceholderEmailDomain = "@oauth.invalid"
+
+// oauthPlaceholderEmail 返回不提供邮箱的第三方账号的占位邮箱：由提供商与第三方用户 ID 的哈希组成，
+// 只含 ASCII 字符且每个第三方账号唯一（昵称可能含空格、非 ASCII 字符或与他人重复，不能用于构造邮箱）
+func oauthPlaceholderEmail(provider, providerUserID string) string {
+	sum := sha256.Sum256([]byte(provider + ":" + providerUserID))
+	return provider + "_" + hex.EncodeToString(sum[:])[:24] + oauthPlaceholderEmailDomain
+}
+
+// IsOAuthPlaceholderEmail 判断邮箱是否为第三方登录创建的占位邮箱，占位邮箱不发送任何邮件
+func IsOAuthPlaceholderEmail(email string) bool {
+	return strings.HasSuffix(strings.ToLower(email), oauthPlaceholderEmailDomain)
+}
+
 // CreateUserFromOAuth 从OAuth信息创建新用户
-func CreateUserFromOAuth(oauthInfo *OAuthUserInfo) (*User, error) {
+func CreateUserFromOAuth(oauthInfo *OAuthUserInfo, provider string) (*User, error) {
 	// 生成唯一的用户名
 	username := generateUniqueUsername(oauthInfo)
 
@@ -556,7 +573,12 @@ func CreateUserFromOAuth(oauthInfo *OAuthUserInfo) (*User, error) {
 	// 创建用户（OAuth用户不需要密码）
 	// 使用随机密码哈希，因为OAuth用户不会使用密码登录
 	randomPassword := fmt.Sprintf("oauth_%s_%d", oauthInfo.ProviderUserID, time.Now().Unix())
-	user, err := CreateUser(username, oauthInfo.Email, randomPassword, "user")
+	// 微信、QQ 等不提供邮箱，users.email 不能为空且唯一，使用基于第三方账号的占位邮箱
+	email := oauthInfo.Email
+	if email == "" {
+		email = oauthPlaceholderEmail(provider, oauthInfo.ProviderUserID)
+	}
+	user, err := CreateUser(username, email, randomPassword, "user")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
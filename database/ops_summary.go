@@ -0,0 +1,169 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// SettingKeyOpsSummary 每日运营摘要推送配置（JSON）
+const SettingKeyOpsSummary = "ops_summary"
+
+// 支持的摘要推送渠道
+const (
+	OpsWebhookSlack    = "slack"
+	OpsWebhookFeishu   = "feishu"
+	OpsWebhookDingTalk = "dingtalk"
+)
+
+// OpsSummaryWebhook 摘要推送目标；Secret 为飞书/钉钉机器人的加签密钥（可选）
+type OpsSummaryWebhook struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Secret  string `json:"secret,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+// OpsSummaryConfig 摘要推送配置，Hour 为每日推送时间（UTC 小时）
+type OpsSummaryConfig struct {
+	Enabled  bool                `json:"enabled"`
+	Hour     int                 `json:"hour"`
+	Webhooks []OpsSummaryWebhook `json:"webhooks"`
+	LastSent *time.Time          `json:"last_sent,omitempty"`
+}
+
+// ErrorCount 错误信息及出现次数
+type ErrorCount struct {
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message"`
+	Count      int64  `json:"count"`
+}
+
+// FailingSession 连续失败或已失效的 Cursor session
+type FailingSession struct {
+	Email     string `json:"email"`
+	FailCount int    `json:"fail_count"`
+	IsValid   bool   `json:"is_valid"`
+}
+
+// OpsSummary 指定时间段的运营摘要
+type OpsSummary struct {
+	PeriodStart     time.Time        `json:"period_start"`
+	PeriodEnd       time.Time        `json:"period_end"`
+	TotalRequests   int              `json:"total_requests"`
+	FailedRequests  int64            `json:"failed_requests"`
+	TotalTokens     int64            `json:"total_tokens"`
+	ActiveUsers     int              `json:"active_users"`
+	NewUsers        int64            `json:"new_users"`
+	RevenueUSD      float64          `json:"revenue_usd"` // API 调用扣费合计
+	TopModels       []ModelStats     `json:"top_models"`
+	TopErrors       []ErrorCount     `json:"top_errors"`
+	FailingSessions []FailingSession `json:"failing_sessions"`
+}
+
+// GetOpsSummaryConfig 获取摘要推送配置，未配置时返回默认配置（关闭，UTC 1 点推送）
+func GetOpsSummaryConfig() (*OpsSummaryConfig, error) {
+	cfg := &OpsSummaryConfig{Hour: 1}
+	if err := GetJSONSetting(SettingKeyOpsSummary, cfg); err != nil {
+		if err == ErrSettingNotFound {
+			return &OpsSummaryConfig{Hour: 1, Webhooks: []OpsSummaryWebhook{}}, nil
+		}
+		return nil, err
+	}
+	if cfg.Webhooks == nil {
+		cfg.Webhooks = []OpsSummaryWebhook{}
+	}
+	return cfg, nil
+}
+
+// SaveOpsSummaryConfig 保存摘要推送配置
+func SaveOpsSummaryConfig(cfg *OpsSummaryConfig) error {
+	return SetJSONSetting(SettingKeyOpsSummary, cfg)
+}
+
+// GetOpsSummary 汇总 [start, end) 时间段的请求、Token、扣费、新用户、错误与 session 健康状况
+// 请求与模型统计复用管理后台的 GetAllUsageStats 聚合
+func GetOpsSummary(start, end time.Time) (*OpsSummary, error) {
+	last := end.Add(-time.Second)
+	usage, err := GetAllUsageStats(UsageFilter{StartDate: &start, EndDate: &last})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &OpsSummary{
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		TotalRequests:   usage.TotalRequests,
+		TotalTokens:     usage.TotalTokens,
+		ActiveUsers:     usage.TotalUsers,
+		TopModels:       usage.TopModels,
+		TopErrors:       []ErrorCount{},
+		FailingSessions: []FailingSession{},
+	}
+	if len(summary.TopModels) > 5 {
+		summary.TopModels = summary.TopModels[:5]
+	}
+
+	if err := db.QueryRow(
+		`SELECT COUNT(*) FROM usage_records WHERE request_time >= ? AND request_time < ? AND status_code >= 400`,
+		start, end,
+	).Scan(&summary.FailedRequests); err != nil {
+		return nil, fmt.Errorf("failed to count failed requests: %w", err)
+	}
+
+	if err := db.QueryRow(
+		`SELECT COUNT(*) FROM users WHERE created_at >= ? AND created_at < ?`,
+		start, end,
+	).Scan(&summary.NewUsers); err != nil {
+		return nil, fmt.Errorf("failed to count new users: %w", err)
+	}
+
+	if err := db.QueryRow(
+		`SELECT COALESCE(-SUM(amount), 0) FROM balance_transactions
+		 WHERE type = ? AND created_at >= ? AND created_at < ?`,
+		TransactionTypeAPIUsage, start, end,
+	).Scan(&summary.RevenueUSD); err != nil {
+		return nil, fmt.Errorf("failed to sum revenue: %w", err)
+	}
+
+	rows, err := db.Query(
+		`SELECT status_code, LEFT(COALESCE(error_message, ''), 200) AS message, COUNT(*) AS cnt
+		 FROM usage_records
+		 WHERE request_time >= ? AND request_time < ? AND status_code >= 400
+		 GROUP BY status_code, message ORDER BY cnt DESC LIMIT 5`,
+		start, end,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top errors: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e ErrorCount
+		if err := rows.Scan(&e.StatusCode, &e.Message, &e.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan error count: %w", err)
+		}
+		summary.TopErrors = append(summary.TopErrors, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessionRows, err := db.Query(
+		`SELECT email, fail_count, is_valid FROM cursor_sessions
+		 WHERE is_valid = FALSE OR fail_count > 0
+		 ORDER BY is_valid ASC, fail_count DESC LIMIT 10`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failing sessions: %w", err)
+	}
+	defer sessionRows.Close()
+	for sessionRows.Next() {
+		var s FailingSession
+		if err := sessionRows.Scan(&s.Email, &s.FailCount, &s.IsValid); err != nil {
+			return nil, fmt.Errorf("failed to scan failing session: %w", err)
+		}
+		summary.FailingSessions = append(summary.FailingSessions, s)
+	}
+	return summary, sessionRows.Err()
+}
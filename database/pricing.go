@@ -0,0 +1,65 @@
+package database
+
+import (
+	"time"
+)
+
+// ModelPricingRecord 模型定价记录（价格单位：USD / 1M tokens）
+type ModelPricingRecord struct {
+	Model       string    `json:"model"`
+	Provider    string    `json:"provider"`
+	InputPrice  float64   `json:"input_price"`
+	OutputPrice float64   `json:"output_price"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ListModelPricing 获取所有模型定价
+func ListModelPricing() ([]*ModelPricingRecord, error) {
+	rows, err := db.Query(
+		`SELECT model, provider, input_price, output_price, updated_at
+		 FROM model_pricing ORDER BY model`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*ModelPricingRecord
+	for rows.Next() {
+		record := &ModelPricingRecord{}
+		if err := rows.Scan(&record.Model, &record.Provider, &record.InputPrice, &record.OutputPrice, &record.UpdatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// SeedModelPricing 写入默认定价（已存在的模型保持不变）
+func SeedModelPricing(records []*ModelPricingRecord) error {
+	now := time.Now()
+	for _, record := range records {
+		_, err := db.Exec(
+			`INSERT IGNORE INTO model_pricing (model, provider, input_price, output_price, updated_at)
+			 VALUES (?, ?, ?, ?, ?)`,
+			record.Model, record.Provider, record.InputPrice, record.OutputPrice, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpsertModelPricing 创建或更新模型定价
+func UpsertModelPricing(record *ModelPricingRecord) error {
+	_, err := db.Exec(
+		`INSERT INTO model_pricing (model, provider, input_price, output_price, updated_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE provider = VALUES(provider), input_price = VALUES(input_price),
+		 output_price = VALUES(output_price), updated_at = VALUES(updated_at)`,
+		record.Model, record.Provider, record.InputPrice, record.OutputPrice, time.Now(),
+	)
+	return err
+}
@@ -0,0 +1,84 @@
+package database
+
+import "time"
+
+// ProviderUsageMetrics 某个时间窗口内一个提供商的请求量、错误率、延迟分位数与 token 用量（来自 usage_records）
+type ProviderUsageMetrics struct {
+	Provider         string  `json:"provider"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	ErrorRate        float64 `json:"error_rate"`
+	P50LatencyMs     int64   `json:"p50_latency_ms"`
+	P95LatencyMs     int64   `json:"p95_latency_ms"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+}
+
+// providerColumn 记录提供商之前写入的旧记录归为 unknown
+const providerColumn = `COALESCE(NULLIF(provider, ''), 'unknown')`
+
+// GetProviderUsageMetrics 按提供商汇总 since 之后的请求。状态码 >= 400 计为错误；
+// 延迟分位数只统计记录了耗时的请求（在线聊天记录的耗时为 0）
+func GetProviderUsageMetrics(since time.Time) ([]*ProviderUsageMetrics, error) {
+	rows, err := db.Query(
+		`SELECT `+providerColumn+` AS p, COUNT(*),
+		        COALESCE(SUM(status_code >= 400), 0),
+		        COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0)
+		 FROM usage_records WHERE request_time >= ?
+		 GROUP BY p ORDER BY p`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []*ProviderUsageMetrics{}
+	byProvider := make(map[string]*ProviderUsageMetrics)
+	for rows.Next() {
+		m := &ProviderUsageMetrics{}
+		if err := rows.Scan(&m.Provider, &m.Requests, &m.Errors, &m.PromptTokens, &m.CompletionTokens, &m.TotalTokens); err != nil {
+			return nil, err
+		}
+		if m.Requests > 0 {
+			m.ErrorRate = float64(m.Errors) / float64(m.Requests)
+		}
+		metrics = append(metrics, m)
+		byProvider[m.Provider] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 最近排名分位数：按耗时排序后取第 ceil(p*n) 条
+	latencyRows, err := db.Query(
+		`SELECT p,
+		        COALESCE(MIN(CASE WHEN rn >= CEIL(0.50 * cnt) THEN duration_ms END), 0),
+		        COALESCE(MIN(CASE WHEN rn >= CEIL(0.95 * cnt) THEN duration_ms END), 0)
+		 FROM (
+		     SELECT `+providerColumn+` AS p, duration_ms,
+		            ROW_NUMBER() OVER (PARTITION BY `+providerColumn+` ORDER BY duration_ms) AS rn,
+		            COUNT(*) OVER (PARTITION BY `+providerColumn+`) AS cnt
+		     FROM usage_records WHERE request_time >= ? AND duration_ms > 0
+		 ) ranked
+		 GROUP BY p`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer latencyRows.Close()
+
+	for latencyRows.Next() {
+		var provider string
+		var p50, p95 int64
+		if err := latencyRows.Scan(&provider, &p50, &p95); err != nil {
+			return nil, err
+		}
+		if m := byProvider[provider]; m != nil {
+			m.P50LatencyMs, m.P95LatencyMs = p50, p95
+		}
+	}
+	return metrics, latencyRows.Err()
+}
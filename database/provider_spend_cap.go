@@ -0,0 +1,225 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 提供商消费上限告警级别
+const (
+	ProviderSpendWarning  = "warning"  // 本月消费达到告警比例
+	ProviderSpendCapped   = "capped"   // 本月消费达到上限，停止路由
+	ProviderSpendReleased = "released" // 上限调高或新的月份开始，恢复路由
+)
+
+var (
+	ErrProviderSpendCapNotFound = errors.New("provider spend cap not found")
+)
+
+// ProviderSpendCap 上游提供商的月度消费上限（按 usage_records 与模型定价估算）
+type ProviderSpendCap struct {
+	Provider      string    `json:"provider"`
+	MonthlyLimit  float64   `json:"monthly_limit"`
+	AlertPercent  int       `json:"alert_percent"`
+	AllowlistTags []string  `json:"allowlist_tags"` // 带有其中任一标签的密钥在达到上限后仍可路由到该提供商
+	Enabled       bool      `json:"enabled"`
+	AlertMonth    string    `json:"alert_month,omitempty"`
+	AlertLevel    string    `json:"alert_level,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ProviderSpendAlert 提供商消费上限告警记录
+type ProviderSpendAlert struct {
+	ID           int64     `json:"id"`
+	Provider     string    `json:"provider"`
+	Month        string    `json:"month"`
+	Level        string    `json:"level"`
+	Spent        float64   `json:"spent"`
+	MonthlyLimit float64   `json:"monthly_limit"`
+	Message      string    `json:"message"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ProviderModelUsage 某个提供商某个模型在一段时间内的 token 用量，用于估算上游费用
+type ProviderModelUsage struct {
+	Provider         string
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+const providerSpendCapColumns = `provider, monthly_limit, alert_percent, allowlist_tags, enabled, alert_month, alert_level, created_at, updated_at`
+
+func scanProviderSpendCap(row interface{ Scan(...interface{}) error }) (*ProviderSpendCap, error) {
+	spendCap := &ProviderSpendCap{}
+	var tagsJSON, alertMonth, alertLevel sql.NullString
+	if err := row.Scan(
+		&spendCap.Provider,
+		&spendCap.MonthlyLimit,
+		&spendCap.AlertPercent,
+		&tagsJSON,
+		&spendCap.Enabled,
+		&alertMonth,
+		&alertLevel,
+		&spendCap.CreatedAt,
+		&spendCap.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	spendCap.AllowlistTags = []string{}
+	if tagsJSON.Valid && tagsJSON.String != "" {
+		if err := json.Unmarshal([]byte(tagsJSON.String), &spendCap.AllowlistTags); err != nil {
+			return nil, err
+		}
+	}
+	spendCap.AlertMonth = alertMonth.String
+	spendCap.AlertLevel = alertLevel.String
+	return spendCap, nil
+}
+
+// ListProviderSpendCaps 获取所有提供商消费上限
+func ListProviderSpendCaps() ([]*ProviderSpendCap, error) {
+	rows, err := db.Query(`SELECT ` + providerSpendCapColumns + ` FROM provider_spend_caps ORDER BY provider`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	caps := []*ProviderSpendCap{}
+	for rows.Next() {
+		spendCap, err := scanProviderSpendCap(rows)
+		if err != nil {
+			return nil, err
+		}
+		caps = append(caps, spendCap)
+	}
+	return caps, rows.Err()
+}
+
+// GetProviderSpendCap 获取某个提供商的消费上限
+func GetProviderSpendCap(provider string) (*ProviderSpendCap, error) {
+	spendCap, err := scanProviderSpendCap(db.QueryRow(
+		`SELECT `+providerSpendCapColumns+` FROM provider_spend_caps WHERE provider = ?`, provider,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrProviderSpendCapNotFound
+	}
+	return spendCap, err
+}
+
+// SaveProviderSpendCap 创建或更新提供商消费上限，已记录的告警状态保持不变
+func SaveProviderSpendCap(spendCap *ProviderSpendCap) error {
+	tags := spendCap.AllowlistTags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO provider_spend_caps (provider, monthly_limit, alert_percent, allowlist_tags, enabled)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE monthly_limit = VALUES(monthly_limit), alert_percent = VALUES(alert_percent),
+		 allowlist_tags = VALUES(allowlist_tags), enabled = VALUES(enabled)`,
+		spendCap.Provider, spendCap.MonthlyLimit, spendCap.AlertPercent, string(tagsJSON), spendCap.Enabled,
+	)
+	return err
+}
+
+// DeleteProviderSpendCap 删除提供商消费上限（告警记录保留）
+func DeleteProviderSpendCap(provider string) error {
+	result, err := db.Exec(`DELETE FROM provider_spend_caps WHERE provider = ?`, provider)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrProviderSpendCapNotFound
+	}
+	return nil
+}
+
+// SetProviderSpendAlertState 记录某个提供商本月已发出的告警级别，level 为空表示无告警
+func SetProviderSpendAlertState(provider, month, level string) error {
+	_, err := db.Exec(
+		`UPDATE provider_spend_caps SET alert_month = NULLIF(?, ''), alert_level = NULLIF(?, '') WHERE provider = ?`,
+		month, level, provider,
+	)
+	return err
+}
+
+// CreateProviderSpendAlert 记录一次提供商消费告警
+func CreateProviderSpendAlert(alert *ProviderSpendAlert) error {
+	alert.CreatedAt = time.Now()
+	result, err := db.Exec(
+		`INSERT INTO provider_spend_alerts (provider, month, level, spent, monthly_limit, message, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		alert.Provider, alert.Month, alert.Level, alert.Spent, alert.MonthlyLimit, alert.Message, alert.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	alert.ID, err = result.LastInsertId()
+	return err
+}
+
+// ListProviderSpendAlerts 获取最近的提供商消费告警；provider 为空时返回所有提供商的
+func ListProviderSpendAlerts(provider string, limit int) ([]*ProviderSpendAlert, error) {
+	query := `SELECT id, provider, month, level, spent, monthly_limit, message, created_at FROM provider_spend_alerts`
+	args := []interface{}{}
+	if provider != "" {
+		query += ` WHERE provider = ?`
+		args = append(args, provider)
+	}
+	rows, err := db.Query(query+` ORDER BY created_at DESC, id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*ProviderSpendAlert{}
+	for rows.Next() {
+		alert := &ProviderSpendAlert{}
+		if err := rows.Scan(
+			&alert.ID,
+			&alert.Provider,
+			&alert.Month,
+			&alert.Level,
+			&alert.Spent,
+			&alert.MonthlyLimit,
+			&alert.Message,
+			&alert.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// GetProviderModelUsage 按提供商和模型汇总 since 之后的 token 用量（未记录提供商的旧记录不计入）
+func GetProviderModelUsage(since time.Time) ([]*ProviderModelUsage, error) {
+	rows, err := db.Query(
+		`SELECT provider, model, COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0)
+		 FROM usage_records WHERE request_time >= ? AND provider IS NOT NULL AND provider <> ''
+		 GROUP BY provider, model`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []*ProviderModelUsage{}
+	for rows.Next() {
+		u := &ProviderModelUsage{}
+		if err := rows.Scan(&u.Provider, &u.Model, &u.PromptTokens, &u.CompletionTokens); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
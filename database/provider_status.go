@@ -0,0 +1,67 @@
+package database
+
+import "time"
+
+// SettingKeyProviderStatus 管理员维护的模型停用列表与提供商故障公告（JSON）
+const SettingKeyProviderStatus = "provider_status"
+
+// ProviderIncident 提供商故障记录，Model 为空时影响该提供商的全部模型
+type ProviderIncident struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model,omitempty"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProviderStatus 模型可用性的人工配置
+type ProviderStatus struct {
+	DisabledModels []string           `json:"disabled_models"`
+	Incidents      []ProviderIncident `json:"incidents"`
+}
+
+// GetProviderStatus 获取模型停用列表与故障记录，未配置时返回空配置
+func GetProviderStatus() (*ProviderStatus, error) {
+	status := &ProviderStatus{}
+	if err := GetJSONSetting(SettingKeyProviderStatus, status); err != nil {
+		if err == ErrSettingNotFound {
+			return &ProviderStatus{}, nil
+		}
+		return nil, err
+	}
+	return status, nil
+}
+
+// SaveProviderStatus 保存模型停用列表与故障记录
+func SaveProviderStatus(status *ProviderStatus) error {
+	return SetJSONSetting(SettingKeyProviderStatus, status)
+}
+
+// SettingKeyProviderBalancing 多个提供商可服务同一模型时的负载均衡策略与权重（JSON）
+const SettingKeyProviderBalancing = "provider_load_balancing"
+
+// ProviderBalancingConfig 负载均衡配置，Weights 以提供商名称为键，未列出的提供商权重为 1，0 表示不分配流量。
+// TTFTTargetMs 与 MaxErrorRate 为 ttft_sla 策略的首字延迟与错误率目标，0 表示不限制
+type ProviderBalancingConfig struct {
+	Strategy     string         `json:"strategy"`
+	Weights      map[string]int `json:"weights"`
+	TTFTTargetMs int            `json:"ttft_target_ms,omitempty"`
+	MaxErrorRate float64        `json:"max_error_rate,omitempty"`
+}
+
+// GetProviderBalancingConfig 获取负载均衡配置，未配置时返回 nil
+func GetProviderBalancingConfig() (*ProviderBalancingConfig, error) {
+	cfg := &ProviderBalancingConfig{}
+	if err := GetJSONSetting(SettingKeyProviderBalancing, cfg); err != nil {
+		if err == ErrSettingNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SaveProviderBalancingConfig 保存负载均衡配置
+func SaveProviderBalancingConfig(cfg *ProviderBalancingConfig) error {
+	return SetJSONSetting(SettingKeyProviderBalancing, cfg)
+}
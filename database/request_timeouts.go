@@ -0,0 +1,29 @@
+package database
+
+// SettingKeyRequestTimeouts 管理员配置的请求超时（全局默认、按模型覆盖及单请求覆盖的上下限，JSON）
+const SettingKeyRequestTimeouts = "request_timeouts"
+
+// RequestTimeoutConfig 请求超时配置，单位为秒。Models 以模型名称为键，以 * 结尾的键按前缀匹配
+type RequestTimeoutConfig struct {
+	Default int            `json:"default"`
+	Min     int            `json:"min"`
+	Max     int            `json:"max"`
+	Models  map[string]int `json:"models"`
+}
+
+// GetRequestTimeoutConfig 获取请求超时配置，未配置时返回 nil
+func GetRequestTimeoutConfig() (*RequestTimeoutConfig, error) {
+	cfg := &RequestTimeoutConfig{}
+	if err := GetJSONSetting(SettingKeyRequestTimeouts, cfg); err != nil {
+		if err == ErrSettingNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SaveRequestTimeoutConfig 保存请求超时配置
+func SaveRequestTimeoutConfig(cfg *RequestTimeoutConfig) error {
+	return SetJSONSetting(SettingKeyRequestTimeouts, cfg)
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrRoutingRuleNotFound = errors.New("routing rule not found")
+
+// RoutingRuleConditions 规则的匹配条件，所有非空条件都满足时规则命中；全部为空时匹配所有请求
+type RoutingRuleConditions struct {
+	UserIDs  []int64  `json:"user_ids,omitempty"`  // 请求所属用户
+	KeyTags  []string `json:"key_tags,omitempty"`  // API 密钥带有其中任一标签
+	Models   []string `json:"models,omitempty"`    // 模型名，支持 * 通配符
+	TimeFrom string   `json:"time_from,omitempty"` // 每日时间段开始（HH:MM，含）
+	TimeTo   string   `json:"time_to,omitempty"`   // 每日时间段结束（HH:MM，不含），早于开始时间表示跨午夜
+	Timezone string   `json:"timezone,omitempty"`  // 时间段所用时区，默认服务器本地时区
+	MinBytes int      `json:"min_bytes,omitempty"` // 请求体最小字节数
+	MaxBytes int      `json:"max_bytes,omitempty"` // 请求体最大字节数
+	// 提示词检测出的语言（ISO 639-1，如 zh、en），需启用 LANGUAGE_DETECTION_ENABLED
+	Languages []string `json:"languages,omitempty"`
+}
+
+// RoutingRuleActions 规则命中后执行的动作
+type RoutingRuleActions struct {
+	RouteProvider string `json:"route_provider,omitempty"` // 路由到指定提供商（cursor 表示强制走 Cursor）
+	SetPriority   string `json:"set_priority,omitempty"`   // 设置请求优先级（high/normal）
+	SystemPrompt  string `json:"system_prompt,omitempty"`  // 在请求前追加的系统提示词
+	Deny          bool   `json:"deny,omitempty"`           // 拒绝请求
+	DenyMessage   string `json:"deny_message,omitempty"`   // 拒绝时返回给客户端的消息
+}
+
+// RoutingRule 管理员配置的请求路由规则
+type RoutingRule struct {
+	ID         int64                 `json:"id"`
+	Name       string                `json:"name"`
+	Position   int                   `json:"position"`
+	Enabled    bool                  `json:"enabled"`
+	Conditions RoutingRuleConditions `json:"conditions"`
+	Actions    RoutingRuleActions    `json:"actions"`
+	Stop       bool                  `json:"stop"`
+	CreatedAt  time.Time             `json:"created_at"`
+	UpdatedAt  time.Time             `json:"updated_at"`
+}
+
+const routingRuleColumns = `id, name, position, enabled, conditions, actions, stop, created_at, updated_at`
+
+func scanRoutingRule(row interface{ Scan(...interface{}) error }) (*RoutingRule, error) {
+	r := &RoutingRule{}
+	var conditionsJSON, actionsJSON string
+	if err := row.Scan(
+		&r.ID,
+		&r.Name,
+		&r.Position,
+		&r.Enabled,
+		&conditionsJSON,
+		&actionsJSON,
+		&r.Stop,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(conditionsJSON), &r.Conditions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(actionsJSON), &r.Actions); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// encodeRoutingRule 序列化条件与动作
+func encodeRoutingRule(r *RoutingRule) (string, string, error) {
+	conditionsJSON, err := json.Marshal(r.Conditions)
+	if err != nil {
+		return "", "", err
+	}
+	actionsJSON, err := json.Marshal(r.Actions)
+	if err != nil {
+		return "", "", err
+	}
+	return string(conditionsJSON), string(actionsJSON), nil
+}
+
+// CreateRoutingRule 创建路由规则
+func CreateRoutingRule(r *RoutingRule) error {
+	conditionsJSON, actionsJSON, err := encodeRoutingRule(r)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO routing_rules (name, position, enabled, conditions, actions, stop, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Name, r.Position, r.Enabled, conditionsJSON, actionsJSON, r.Stop, now, now,
+	)
+	if err != nil {
+		return err
+	}
+	r.ID, err = result.LastInsertId()
+	r.CreatedAt = now
+	r.UpdatedAt = now
+	return err
+}
+
+// GetRoutingRule 根据ID获取路由规则
+func GetRoutingRule(id int64) (*RoutingRule, error) {
+	r, err := scanRoutingRule(db.QueryRow(
+		`SELECT `+routingRuleColumns+` FROM routing_rules WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrRoutingRuleNotFound
+	}
+	return r, err
+}
+
+// ListRoutingRules 获取所有路由规则，按匹配顺序（position、id）排列
+func ListRoutingRules() ([]*RoutingRule, error) {
+	rows, err := db.Query(`SELECT ` + routingRuleColumns + ` FROM routing_rules ORDER BY position, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*RoutingRule{}
+	for rows.Next() {
+		r, err := scanRoutingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateRoutingRule 更新路由规则
+func UpdateRoutingRule(r *RoutingRule) error {
+	conditionsJSON, actionsJSON, err := encodeRoutingRule(r)
+	if err != nil {
+		return err
+	}
+
+	r.UpdatedAt = time.Now()
+	result, err := db.Exec(
+		`UPDATE routing_rules SET name = ?, position = ?, enabled = ?, conditions = ?, actions = ?, stop = ?, updated_at = ?
+		 WHERE id = ?`,
+		r.Name, r.Position, r.Enabled, conditionsJSON, actionsJSON, r.Stop, r.UpdatedAt, r.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrRoutingRuleNotFound
+	}
+	return nil
+}
+
+// DeleteRoutingRule 删除路由规则
+func DeleteRoutingRule(id int64) error {
+	result, err := db.Exec(`DELETE FROM routing_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrRoutingRuleNotFound
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	createTableRe = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS\s+(\w+)`)
+	addColumnRe   = regexp.MustCompile(`(?i)ALTER TABLE\s+(\w+)\s+ADD COLUMN\s+(\w+)`)
+)
+
+// SchemaStatus 数据库结构检查结果
+type SchemaStatus struct {
+	Version        int      `json:"version"`         // Number of migrations applied
+	LatestVersion  int      `json:"latest_version"`  // Number of migrations known to this build
+	MissingTables  []string `json:"missing_tables"`  // Tables that do not exist
+	MissingColumns []string `json:"missing_columns"` // Migration columns that do not exist, as table.column
+}
+
+// UpToDate 数据库结构是否与当前版本一致
+func (s *SchemaStatus) UpToDate() bool {
+	return len(s.MissingTables) == 0 && len(s.MissingColumns) == 0
+}
+
+// CheckSchema 对比当前数据库与建表语句/迁移列表，找出缺失的表和列
+func CheckSchema() (*SchemaStatus, error) {
+	existing := make(map[string]bool)
+	rows, err := db.Query(
+		`SELECT TABLE_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE()`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		existing[strings.ToLower(table)] = true
+		existing[strings.ToLower(table+"."+column)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := &SchemaStatus{}
+	for _, stmt := range tableDefinitions() {
+		if m := createTableRe.FindStringSubmatch(stmt); m != nil && !existing[strings.ToLower(m[1])] {
+			status.MissingTables = append(status.MissingTables, m[1])
+		}
+	}
+
+	for _, stmt := range schemaMigrations() {
+		m := addColumnRe.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		status.LatestVersion++
+		if existing[strings.ToLower(m[1]+"."+m[2])] {
+			status.Version++
+		} else {
+			status.MissingColumns = append(status.MissingColumns, m[1]+"."+m[2])
+		}
+	}
+
+	return status, nil
+}
+
+// Ping 检查数据库连接
+func Ping() error {
+	if db == nil {
+		return ErrDatabaseUnavailable
+	}
+	return db.Ping()
+}
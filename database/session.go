@@ -1,7 +1,10 @@
 package database
 
 import (
+	"Curry2API-go/utils"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -24,44 +27,48 @@ type Session struct {
 	UserAgent string    `json:"user_agent"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// 登录设备指纹，旧会话为空
+	utils.DeviceInfo
+	Fingerprint string `json:"-"`
 }
 
-// CreateSession 创建新会话
-func CreateSession(userID int64, username, role, ipAddress, userAgent string, duration time.Duration) (*Session, error) {
+// CreateSession 创建新会话，device 为登录设备指纹
+func CreateSession(userID int64, username, role, ipAddress, userAgent string, device utils.DeviceInfo, duration time.Duration) (*Session, error) {
 	sessionID := uuid.New().String()
 	now := time.Now()
 	expiresAt := now.Add(duration)
+	fingerprint := device.Fingerprint()
 	
 	_, err := db.Exec(
-		`INSERT INTO sessions (id, user_id, username, role, ip_address, user_agent, created_at, expires_at) 
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		sessionID, userID, username, role, ipAddress, userAgent, now, expiresAt,
+		`INSERT INTO sessions (id, user_id, username, role, ip_address, user_agent, browser, os, device_type, location, fingerprint, created_at, expires_at) 
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, userID, username, role, ipAddress, userAgent,
+		device.Browser, device.OS, device.DeviceType, device.Location, fingerprint, now, expiresAt,
 	)
 	if err != nil {
 		return nil, err
 	}
 	
 	return &Session{
-		ID:        sessionID,
-		UserID:    userID,
-		Username:  username,
-		Role:      role,
-		IPAddress: ipAddress,
-		UserAgent: userAgent,
-		CreatedAt: now,
-		ExpiresAt: expiresAt,
+		ID:          sessionID,
+		UserID:      userID,
+		Username:    username,
+		Role:        role,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+		DeviceInfo:  device,
+		Fingerprint: fingerprint,
 	}, nil
 }
 
 // GetSession 获取会话
 func GetSession(sessionID string) (*Session, error) {
-	session := &Session{}
-	err := db.QueryRow(
-		`SELECT id, user_id, username, role, ip_address, user_agent, created_at, expires_at 
-		 FROM sessions WHERE id = ?`,
+	session, err := scanSession(db.QueryRow(
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`,
 		sessionID,
-	).Scan(&session.ID, &session.UserID, &session.Username, &session.Role, 
-		&session.IPAddress, &session.UserAgent, &session.CreatedAt, &session.ExpiresAt)
+	))
 	
 	if err == sql.ErrNoRows {
 		return nil, ErrSessionNotFound
@@ -139,16 +146,146 @@ func DeleteUserOldSessions(userID int64, keepCount int) error {
 	return nil
 }
 
-// CleanExpiredSessions 清理过期会话
-func CleanExpiredSessions() error {
-	result, err := db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now())
+// sessionColumns 会话查询的列，与 scanSession 对应
+const sessionColumns = `id, user_id, username, role, ip_address, user_agent, browser, os, device_type, location, fingerprint, created_at, expires_at`
+
+// scanSession 扫描一行会话记录，旧会话的设备字段为 NULL
+func scanSession(scanner interface{ Scan(dest ...interface{}) error }) (*Session, error) {
+	session := &Session{}
+	var ipAddress, userAgent, browser, os, deviceType, location, fingerprint sql.NullString
+	if err := scanner.Scan(&session.ID, &session.UserID, &session.Username, &session.Role, &ipAddress, &userAgent,
+		&browser, &os, &deviceType, &location, &fingerprint, &session.CreatedAt, &session.ExpiresAt); err != nil {
+		return nil, err
+	}
+	session.IPAddress = ipAddress.String
+	session.UserAgent = userAgent.String
+	session.Browser = browser.String
+	session.OS = os.String
+	session.DeviceType = deviceType.String
+	session.Location = location.String
+	session.Fingerprint = fingerprint.String
+	return session, nil
+}
+
+// ListUserSessions 获取用户未过期的会话，最新的在前
+func ListUserSessions(userID int64) ([]*Session, error) {
+	rows, err := db.Query(
+		`SELECT `+sessionColumns+` FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY created_at DESC`,
+		userID, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// sessionPublicIDHexLen 会话公开 ID 保留的 SHA-256 十六进制位数
+const sessionPublicIDHexLen = 32
+
+// SessionPublicID 返回会话的公开 ID（会话令牌 SHA-256 的前 32 位十六进制）。
+// 会话令牌本身就是登录凭证，会话列表和远程登出只使用公开 ID
+func SessionPublicID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])[:sessionPublicIDHexLen]
+}
+
+// FindUserSessionByPublicID 在用户自己的会话中查找公开 ID 对应的会话令牌
+func FindUserSessionByPublicID(userID int64, publicID string) (string, error) {
+	rows, err := db.Query(`SELECT id FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return "", err
+		}
+		if SessionPublicID(sessionID) == publicID {
+			return sessionID, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrSessionNotFound
+}
+
+// DeleteUserSession 删除用户自己的某个会话（用于远程登出其他设备）
+func DeleteUserSession(userID int64, sessionID string) error {
+	result, err := db.Exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, sessionID, userID)
 	if err != nil {
 		return err
 	}
-	
-	rows, _ := result.RowsAffected()
-	if rows > 0 {
-		logrus.Infof("Cleaned up %d expired sessions", rows)
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSessionNotFound
 	}
 	return nil
 }
+
+// DeleteOtherUserSessions 删除用户除 keepSessionID 外的全部会话（keepSessionID 为空时全部删除），返回删除数量
+func DeleteOtherUserSessions(userID int64, keepSessionID string) (int64, error) {
+	result, err := db.Exec(`DELETE FROM sessions WHERE user_id = ? AND id <> ?`, userID, keepSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// IsKnownDevice 判断用户是否有未过期的会话来自相同指纹的设备
+func IsKnownDevice(userID int64, fingerprint string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM sessions WHERE user_id = ? AND fingerprint = ? AND expires_at > ?`,
+		userID, fingerprint, time.Now(),
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ElevateSession 让会话进入 sudo 模式直到 until（重新验证密码后调用）
+func ElevateSession(sessionID string, until time.Time) error {
+	result, err := db.Exec(`UPDATE sessions SET elevated_until = ? WHERE id = ?`, until, sessionID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DropSessionElevation 提前退出会话的 sudo 模式
+func DropSessionElevation(sessionID string) error {
+	_, err := db.Exec(`UPDATE sessions SET elevated_until = NULL WHERE id = ?`, sessionID)
+	return err
+}
+
+// GetSessionElevation 返回会话 sudo 模式的结束时间，未进入或已过期时返回零值
+func GetSessionElevation(sessionID string) (time.Time, error) {
+	var until sql.NullTime
+	err := db.QueryRow(`SELECT elevated_until FROM sessions WHERE id = ?`, sessionID).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !until.Valid || !time.Now().Before(until.Time) {
+		return time.Time{}, nil
+	}
+	return until.Time, nil
+}
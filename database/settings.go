@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSettingNotFound = errors.New("setting not found")
+)
+
+// GetSetting 获取系统设置值
+func GetSetting(key string) (string, error) {
+	var value string
+	err := db.QueryRow(
+		`SELECT setting_value FROM system_settings WHERE setting_key = ?`,
+		key,
+	).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return "", ErrSettingNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// SetSetting 写入系统设置值（存在则覆盖）
+func SetSetting(key, value string) error {
+	_, err := db.Exec(
+		`INSERT INTO system_settings (setting_key, setting_value, updated_at)
+		 VALUES (?, ?, ?)
+		 ON DUPLICATE KEY UPDATE setting_value = VALUES(setting_value), updated_at = VALUES(updated_at)`,
+		key, value, time.Now(),
+	)
+	return err
+}
+
+// GetJSONSetting 读取 JSON 格式的系统设置并反序列化到 out
+func GetJSONSetting(key string, out interface{}) error {
+	value, err := GetSetting(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), out)
+}
+
+// SetJSONSetting 将 value 序列化为 JSON 后写入系统设置
+func SetJSONSetting(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return SetSetting(key, string(data))
+}
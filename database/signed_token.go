@@ -0,0 +1,48 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SettingKeyTokenSigningSecret 未配置 TOKEN_SIGNING_SECRET 时自动生成并保存的签名密钥
+const SettingKeyTokenSigningSecret = "token_signing_secret"
+
+// 签名令牌失效原因
+const (
+	SpentTokenUsed    = "used"    // 一次性令牌已被使用
+	SpentTokenRevoked = "revoked" // 令牌已被吊销
+)
+
+// MarkTokenSpent 记录令牌已使用或已吊销；令牌此前已记录时返回 false
+// 只需保存到令牌过期为止，过期后由 PurgeExpiredSpentTokens 清理
+func MarkTokenSpent(jti, purpose, reason string, expiresAt time.Time) (bool, error) {
+	result, err := db.Exec(
+		`INSERT IGNORE INTO signed_tokens_spent (jti, purpose, reason, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		jti, purpose, reason, expiresAt, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetTokenSpentReason 返回令牌的失效原因，未失效时返回空字符串
+func GetTokenSpentReason(jti string) (string, error) {
+	var reason string
+	err := db.QueryRow(`SELECT reason FROM signed_tokens_spent WHERE jti = ?`, jti).Scan(&reason)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return reason, err
+}
+
+// PurgeExpiredSpentTokens 删除已过期令牌的记录（过期令牌本身已无法通过校验）
+func PurgeExpiredSpentTokens() (int64, error) {
+	result, err := db.Exec(`DELETE FROM signed_tokens_spent WHERE expires_at < ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
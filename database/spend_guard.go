@@ -0,0 +1,168 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SpendGuardCooldown 放宽或取消消费上限前的等待时间；收紧立即生效
+const SpendGuardCooldown = 24 * time.Hour
+
+// SpendGuard 用户自设的每日/每周 API 消费上限（美元），nil 表示不限制
+// Pending* 非 nil 表示有待生效的放宽，值为 0 表示到期后取消该上限
+type SpendGuard struct {
+	UserID             int64      `json:"user_id"`
+	DailyLimit         *float64   `json:"daily_limit"`
+	WeeklyLimit        *float64   `json:"weekly_limit"`
+	PendingDailyLimit  *float64   `json:"pending_daily_limit,omitempty"`
+	PendingWeeklyLimit *float64   `json:"pending_weekly_limit,omitempty"`
+	PendingEffectiveAt *time.Time `json:"pending_effective_at,omitempty"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// IsActive 是否设置了任一上限
+func (g *SpendGuard) IsActive() bool {
+	return g.DailyLimit != nil || g.WeeklyLimit != nil
+}
+
+// HasPending 是否有待生效的放宽
+func (g *SpendGuard) HasPending() bool {
+	return g.PendingDailyLimit != nil || g.PendingWeeklyLimit != nil
+}
+
+// ChangeLimits 按冷却规则修改上限：新增或调低的上限立即生效并撤销该项的待生效放宽，
+// 调高或取消（nil）的上限在 SpendGuardCooldown 后生效
+func (g *SpendGuard) ChangeLimits(daily, weekly *float64, now time.Time) {
+	var dailyLoosened, weeklyLoosened bool
+	g.DailyLimit, g.PendingDailyLimit, dailyLoosened = changeSpendLimit(g.DailyLimit, g.PendingDailyLimit, daily)
+	g.WeeklyLimit, g.PendingWeeklyLimit, weeklyLoosened = changeSpendLimit(g.WeeklyLimit, g.PendingWeeklyLimit, weekly)
+
+	switch {
+	case !g.HasPending():
+		g.PendingEffectiveAt = nil
+	case dailyLoosened || weeklyLoosened:
+		// 每次新的放宽请求都重新计算冷却期
+		effectiveAt := now.Add(SpendGuardCooldown)
+		g.PendingEffectiveAt = &effectiveAt
+	}
+}
+
+// changeSpendLimit 计算单项上限的修改结果，返回 (当前值, 待生效值, 是否为放宽)
+func changeSpendLimit(current, pending, requested *float64) (*float64, *float64, bool) {
+	if requested != nil && (current == nil || *requested <= *current) {
+		return requested, nil, false
+	}
+	if requested == nil && current == nil {
+		return nil, nil, false
+	}
+	if requested == nil {
+		remove := 0.0
+		return current, &remove, true
+	}
+	if pending != nil && *pending == *requested {
+		return current, pending, false // 与已排队的放宽相同，不重置冷却期
+	}
+	return current, requested, true
+}
+
+// applyDue 冷却期已过时应用待生效的放宽，返回是否有变化
+func (g *SpendGuard) applyDue(now time.Time) bool {
+	if !g.HasPending() || g.PendingEffectiveAt == nil || now.Before(*g.PendingEffectiveAt) {
+		return false
+	}
+	if g.PendingDailyLimit != nil {
+		g.DailyLimit = nil
+		if *g.PendingDailyLimit > 0 {
+			g.DailyLimit = g.PendingDailyLimit
+		}
+	}
+	if g.PendingWeeklyLimit != nil {
+		g.WeeklyLimit = nil
+		if *g.PendingWeeklyLimit > 0 {
+			g.WeeklyLimit = g.PendingWeeklyLimit
+		}
+	}
+	g.PendingDailyLimit = nil
+	g.PendingWeeklyLimit = nil
+	g.PendingEffectiveAt = nil
+	return true
+}
+
+// GetSpendGuard 获取用户的消费上限，未设置时返回不限制的空配置
+// 冷却期已到的放宽会在读取时应用并保存
+func GetSpendGuard(userID int64) (*SpendGuard, error) {
+	g := &SpendGuard{UserID: userID}
+	var daily, weekly, pendingDaily, pendingWeekly sql.NullFloat64
+	var effectiveAt sql.NullTime
+	err := db.QueryRow(
+		`SELECT daily_limit, weekly_limit, pending_daily_limit, pending_weekly_limit, pending_effective_at, updated_at
+		 FROM user_spend_guards WHERE user_id = ?`,
+		userID,
+	).Scan(&daily, &weekly, &pendingDaily, &pendingWeekly, &effectiveAt, &g.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g.DailyLimit = nullFloatPtr(daily)
+	g.WeeklyLimit = nullFloatPtr(weekly)
+	g.PendingDailyLimit = nullFloatPtr(pendingDaily)
+	g.PendingWeeklyLimit = nullFloatPtr(pendingWeekly)
+	if effectiveAt.Valid {
+		g.PendingEffectiveAt = &effectiveAt.Time
+	}
+
+	if g.applyDue(time.Now()) {
+		if err := SaveSpendGuard(g); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// SaveSpendGuard 保存用户的消费上限
+func SaveSpendGuard(g *SpendGuard) error {
+	_, err := db.Exec(
+		`INSERT INTO user_spend_guards
+		 (user_id, daily_limit, weekly_limit, pending_daily_limit, pending_weekly_limit, pending_effective_at)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE daily_limit = VALUES(daily_limit), weekly_limit = VALUES(weekly_limit),
+		 pending_daily_limit = VALUES(pending_daily_limit), pending_weekly_limit = VALUES(pending_weekly_limit),
+		 pending_effective_at = VALUES(pending_effective_at)`,
+		g.UserID, g.DailyLimit, g.WeeklyLimit, g.PendingDailyLimit, g.PendingWeeklyLimit, g.PendingEffectiveAt,
+	)
+	if err == nil {
+		g.UpdatedAt = time.Now()
+	}
+	return err
+}
+
+// SpendGuardPeriods 返回当前计费日（UTC 零点）与计费周（周一 UTC 零点）的起点
+func SpendGuardPeriods(now time.Time) (dayStart, weekStart time.Time) {
+	now = now.UTC()
+	dayStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(dayStart.Weekday()) + 6) % 7 // Monday = 0
+	return dayStart, dayStart.AddDate(0, 0, -offset)
+}
+
+// GetUserAPISpend 统计用户自 since 起的 API 调用扣费金额（美元）
+func GetUserAPISpend(userID int64, since time.Time) (float64, error) {
+	var spent float64
+	err := db.QueryRow(
+		`SELECT COALESCE(-SUM(amount), 0) FROM balance_transactions
+		 WHERE user_id = ? AND type = ? AND created_at >= ?`,
+		userID, TransactionTypeAPIUsage, since,
+	).Scan(&spent)
+	return spent, err
+}
+
+// nullFloatPtr 将可空数值转换为指针
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	f := v.Float64
+	return &f
+}
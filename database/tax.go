@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SettingKeyTaxRates 税率设置键（JSON: {"DE": 0.19, "FR": 0.20}，按 ISO 3166-1 国家代码）
+const SettingKeyTaxRates = "tax_rates"
+
+// UserTaxInfo 用户税务信息
+type UserTaxInfo struct {
+	Country string `json:"country"`
+	TaxID   string `json:"tax_id"`
+}
+
+// GetUserTaxInfo 获取用户的税务信息
+func GetUserTaxInfo(userID int64) (*UserTaxInfo, error) {
+	var country, taxID sql.NullString
+	err := db.QueryRow(
+		`SELECT tax_country, tax_id FROM users WHERE id = ?`,
+		userID,
+	).Scan(&country, &taxID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserTaxInfo{
+		Country: country.String,
+		TaxID:   taxID.String,
+	}, nil
+}
+
+// UpdateUserTaxInfo 更新用户的税务信息
+func UpdateUserTaxInfo(userID int64, info *UserTaxInfo) error {
+	_, err := db.Exec(
+		`UPDATE users SET tax_country = ?, tax_id = ? WHERE id = ?`,
+		info.Country, info.TaxID, userID,
+	)
+	return err
+}
+
+// GetTaxRates 获取管理员配置的税率表
+func GetTaxRates() (map[string]float64, error) {
+	rates := make(map[string]float64)
+	if err := GetJSONSetting(SettingKeyTaxRates, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// SaveTaxRates 保存税率表
+func SaveTaxRates(rates map[string]float64) error {
+	return SetJSONSetting(SettingKeyTaxRates, rates)
+}
+
+// SetTransactionTax 记录交易的税率与税额
+func SetTransactionTax(transactionID int64, taxRate, taxAmount float64) error {
+	_, err := db.Exec(
+		`UPDATE balance_transactions SET tax_rate = ?, tax_amount = ? WHERE id = ?`,
+		taxRate, taxAmount, transactionID,
+	)
+	return err
+}
+
+// GetBalanceTransactionsInRange retrieves all transactions for a user in [start, end)
+// Used for generating statements, ordered oldest first
+func GetBalanceTransactionsInRange(userID int64, start, end time.Time) ([]*BalanceTransaction, error) {
+	rows, err := db.Query(
+		`SELECT id, user_id, type, amount, balance_after, tokens, description, related_user_id, admin_id, api_token, model,
+		        tax_rate, tax_amount, created_at
+		 FROM balance_transactions WHERE user_id = ? AND created_at >= ? AND created_at < ?
+		 ORDER BY created_at ASC`,
+		userID, start, end,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*BalanceTransaction
+	for rows.Next() {
+		tx := &BalanceTransaction{}
+		var relatedUserID, adminID sql.NullInt64
+		var apiToken, model sql.NullString
+		var taxRate, taxAmount sql.NullFloat64
+
+		err := rows.Scan(&tx.ID, &tx.UserID, &tx.Type, &tx.Amount, &tx.BalanceAfter, &tx.Tokens,
+			&tx.Description, &relatedUserID, &adminID, &apiToken, &model, &taxRate, &taxAmount, &tx.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if relatedUserID.Valid {
+			tx.RelatedUserID = &relatedUserID.Int64
+		}
+		if adminID.Valid {
+			tx.AdminID = &adminID.Int64
+		}
+		if apiToken.Valid {
+			tx.APIToken = apiToken.String
+		}
+		if model.Valid {
+			tx.Model = model.String
+		}
+		tx.TaxRate = taxRate.Float64
+		tx.TaxAmount = taxAmount.Float64
+
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, rows.Err()
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTermsNotFound      = errors.New("terms of service version not found")
+	ErrTermsVersionExists = errors.New("terms of service version already exists")
+)
+
+// TermsVersion 服务条款版本
+type TermsVersion struct {
+	ID          int64     `json:"id"`
+	Version     string    `json:"version"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content,omitempty"`
+	PublishedBy *int64    `json:"published_by,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// TermsAcceptance 用户接受服务条款的记录
+type TermsAcceptance struct {
+	UserID     int64     `json:"user_id"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// PublishTermsVersion 发布新的服务条款版本，发布后即成为当前版本
+func PublishTermsVersion(terms *TermsVersion) error {
+	terms.PublishedAt = time.Now()
+	result, err := db.Exec(
+		`INSERT INTO tos_versions (version, title, content, published_by, published_at) VALUES (?, ?, ?, ?, ?)`,
+		terms.Version, terms.Title, terms.Content, terms.PublishedBy, terms.PublishedAt,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return ErrTermsVersionExists
+		}
+		return err
+	}
+	terms.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetCurrentTermsVersion 获取最新发布的服务条款（含正文）
+func GetCurrentTermsVersion() (*TermsVersion, error) {
+	terms := &TermsVersion{}
+	var publishedBy sql.NullInt64
+	err := db.QueryRow(
+		`SELECT id, version, title, content, published_by, published_at
+		 FROM tos_versions ORDER BY published_at DESC, id DESC LIMIT 1`,
+	).Scan(&terms.ID, &terms.Version, &terms.Title, &terms.Content, &publishedBy, &terms.PublishedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTermsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if publishedBy.Valid {
+		terms.PublishedBy = &publishedBy.Int64
+	}
+	return terms, nil
+}
+
+// ListTermsVersions 列出所有服务条款版本（不含正文），最新的在前
+func ListTermsVersions() ([]*TermsVersion, error) {
+	rows, err := db.Query(
+		`SELECT id, version, title, published_by, published_at
+		 FROM tos_versions ORDER BY published_at DESC, id DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*TermsVersion{}
+	for rows.Next() {
+		terms := &TermsVersion{}
+		var publishedBy sql.NullInt64
+		if err := rows.Scan(&terms.ID, &terms.Version, &terms.Title, &publishedBy, &terms.PublishedAt); err != nil {
+			return nil, err
+		}
+		if publishedBy.Valid {
+			terms.PublishedBy = &publishedBy.Int64
+		}
+		versions = append(versions, terms)
+	}
+	return versions, rows.Err()
+}
+
+// RecordTermsAcceptance 记录用户接受某一版本（重复接受时保留首次记录）
+func RecordTermsAcceptance(acceptance *TermsAcceptance) error {
+	acceptance.AcceptedAt = time.Now()
+	_, err := db.Exec(
+		`INSERT IGNORE INTO tos_acceptances (user_id, version, accepted_at, ip_address, user_agent) VALUES (?, ?, ?, ?, ?)`,
+		acceptance.UserID, acceptance.Version, acceptance.AcceptedAt, acceptance.IPAddress, acceptance.UserAgent,
+	)
+	return err
+}
+
+// GetLatestTermsAcceptance 获取用户最近一次接受的条款记录，从未接受时返回 nil
+func GetLatestTermsAcceptance(userID int64) (*TermsAcceptance, error) {
+	acceptance := &TermsAcceptance{UserID: userID}
+	var ip, ua sql.NullString
+	err := db.QueryRow(
+		`SELECT version, accepted_at, ip_address, user_agent
+		 FROM tos_acceptances WHERE user_id = ? ORDER BY accepted_at DESC, id DESC LIMIT 1`,
+		userID,
+	).Scan(&acceptance.Version, &acceptance.AcceptedAt, &ip, &ua)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	acceptance.IPAddress = ip.String
+	acceptance.UserAgent = ua.String
+	return acceptance, nil
+}
+
+// HasAcceptedTerms 用户是否接受过指定版本
+func HasAcceptedTerms(userID int64, version string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM tos_acceptances WHERE user_id = ? AND version = ?)`,
+		userID, version,
+	).Scan(&exists)
+	return exists, err
+}
+
+// CountTermsAcceptances 统计接受指定版本的用户数
+func CountTermsAcceptances(version string) (int64, error) {
+	var count int64
+	err := db.QueryRow(`SELECT COUNT(*) FROM tos_acceptances WHERE version = ?`, version).Scan(&count)
+	return count, err
+}
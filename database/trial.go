@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SettingKeyAnonymousTrial 匿名试用配置（JSON）
+const SettingKeyAnonymousTrial = "anonymous_trial"
+
+// TrialKeyRetention 试用密钥过期后保留的时间，用于统计注册转化，之后由清理任务删除
+const TrialKeyRetention = 30 * 24 * time.Hour
+
+var ErrTrialKeyNotFound = errors.New("trial key not found")
+
+// TrialConfig 匿名试用配置：访客无需注册即可申请短期、严格限流的临时密钥试用免费模型
+type TrialConfig struct {
+	Enabled           bool     `json:"enabled"`
+	TTLMinutes        int      `json:"ttl_minutes"`         // 临时密钥有效期
+	RequestsPerMinute int      `json:"requests_per_minute"` // 每个临时密钥的每分钟请求数
+	MaxRequests       int      `json:"max_requests"`        // 每个临时密钥的总请求数
+	MaxKeysPerIPDay   int      `json:"max_keys_per_ip_day"` // 同一 IP 每天可申请的临时密钥数
+	Models            []string `json:"models,omitempty"`    // 可试用的模型，为空时使用全部免费模型
+}
+
+// DefaultTrialConfig 默认配置（默认关闭）
+func DefaultTrialConfig() *TrialConfig {
+	return &TrialConfig{
+		TTLMinutes:        30,
+		RequestsPerMinute: 3,
+		MaxRequests:       20,
+		MaxKeysPerIPDay:   3,
+	}
+}
+
+// GetTrialConfig 获取匿名试用配置，未配置时返回默认配置
+func GetTrialConfig() (*TrialConfig, error) {
+	cfg := DefaultTrialConfig()
+	if err := GetJSONSetting(SettingKeyAnonymousTrial, cfg); err != nil && err != ErrSettingNotFound {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SaveTrialConfig 保存匿名试用配置
+func SaveTrialConfig(cfg *TrialConfig) error {
+	return SetJSONSetting(SettingKeyAnonymousTrial, cfg)
+}
+
+// TrialKey 匿名试用的临时密钥
+type TrialKey struct {
+	ID              int64      `json:"id"`
+	Key             string     `json:"-"`
+	IPAddress       string     `json:"ip_address"`
+	Requests        int        `json:"requests"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	ConvertedUserID *int64     `json:"converted_user_id,omitempty"`
+	ConvertedAt     *time.Time `json:"converted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// CreateTrialKey 保存新签发的临时密钥
+func CreateTrialKey(key, ip string, expiresAt time.Time) (*TrialKey, error) {
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO trial_keys (api_key, ip_address, expires_at, created_at) VALUES (?, ?, ?, ?)`,
+		key, ip, expiresAt, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &TrialKey{ID: id, Key: key, IPAddress: ip, ExpiresAt: expiresAt, CreatedAt: now}, nil
+}
+
+// GetTrialKey 按密钥查询临时密钥
+func GetTrialKey(key string) (*TrialKey, error) {
+	var t TrialKey
+	var convertedUserID sql.NullInt64
+	var convertedAt sql.NullTime
+	err := db.QueryRow(
+		`SELECT id, api_key, ip_address, requests, expires_at, converted_user_id, converted_at, created_at
+		 FROM trial_keys WHERE api_key = ?`,
+		key,
+	).Scan(&t.ID, &t.Key, &t.IPAddress, &t.Requests, &t.ExpiresAt, &convertedUserID, &convertedAt, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTrialKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if convertedUserID.Valid {
+		t.ConvertedUserID = &convertedUserID.Int64
+	}
+	if convertedAt.Valid {
+		t.ConvertedAt = &convertedAt.Time
+	}
+	return &t, nil
+}
+
+// IncrementTrialKeyRequests 记录临时密钥的一次请求
+func IncrementTrialKeyRequests(key string) error {
+	_, err := db.Exec(`UPDATE trial_keys SET requests = requests + 1 WHERE api_key = ?`, key)
+	return err
+}
+
+// CountTrialKeysByIPSince 统计 IP 自 since 起申请的临时密钥数
+func CountTrialKeysByIPSince(ip string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM trial_keys WHERE ip_address = ? AND created_at >= ?`,
+		ip, since,
+	).Scan(&count)
+	return count, err
+}
+
+// MarkTrialConverted 将访客的临时密钥标记为已转化为注册用户：优先按注册时提交的密钥匹配，
+// 没有时按注册 IP 匹配保留期内最近一个未转化的临时密钥；没有匹配的试用记录时返回 false
+func MarkTrialConverted(key, ip string, userID int64) (bool, error) {
+	now := time.Now()
+	var result sql.Result
+	var err error
+	if key != "" {
+		result, err = db.Exec(
+			`UPDATE trial_keys SET converted_user_id = ?, converted_at = ?
+			 WHERE api_key = ? AND converted_user_id IS NULL`,
+			userID, now, key,
+		)
+	} else {
+		result, err = db.Exec(
+			`UPDATE trial_keys SET converted_user_id = ?, converted_at = ?
+			 WHERE ip_address = ? AND converted_user_id IS NULL AND created_at >= ?
+			 ORDER BY created_at DESC LIMIT 1`,
+			userID, now, ip, now.Add(-TrialKeyRetention),
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// TrialStats 匿名试用的签发与转化统计
+type TrialStats struct {
+	Since          time.Time `json:"since"`
+	KeysIssued     int64     `json:"keys_issued"`
+	UniqueIPs      int64     `json:"unique_ips"`
+	Requests       int64     `json:"requests"`
+	ActiveKeys     int64     `json:"active_keys"`
+	Conversions    int64     `json:"conversions"`
+	ConversionRate float64   `json:"conversion_rate"` // 转化的临时密钥占签发数的百分比
+}
+
+// GetTrialStats 统计 since 以来签发的临时密钥及其转化情况
+func GetTrialStats(since time.Time) (*TrialStats, error) {
+	stats := &TrialStats{Since: since}
+	err := db.QueryRow(
+		`SELECT COUNT(*), COUNT(DISTINCT ip_address), COALESCE(SUM(requests), 0),
+		        COALESCE(SUM(expires_at > ?), 0), COUNT(converted_user_id)
+		 FROM trial_keys WHERE created_at >= ?`,
+		time.Now(), since,
+	).Scan(&stats.KeysIssued, &stats.UniqueIPs, &stats.Requests, &stats.ActiveKeys, &stats.Conversions)
+	if err != nil {
+		return nil, err
+	}
+	if stats.KeysIssued > 0 {
+		stats.ConversionRate = float64(stats.Conversions*10000/stats.KeysIssued) / 100
+	}
+	return stats, nil
+}
+
+// PurgeExpiredTrialKeys 删除过期超过保留期的临时密钥
+func PurgeExpiredTrialKeys() (int64, error) {
+	result, err := db.Exec(`DELETE FROM trial_keys WHERE expires_at < ?`, time.Now().Add(-TrialKeyRetention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"Curry2API-go/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SettingKeyRequireAdminTwoFactor 是否要求管理员账号启用两步验证
+const SettingKeyRequireAdminTwoFactor = "require_admin_two_factor"
+
+var (
+	ErrTwoFactorNotFound       = errors.New("two-factor authentication is not set up")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+// UserTwoFactor 用户的两步验证配置，Secret 为解密后的 base32 密钥
+type UserTwoFactor struct {
+	UserID       int64      `json:"user_id"`
+	Secret       string     `json:"-"`
+	Enabled      bool       `json:"enabled"`
+	BackupCodes  []string   `json:"-"` // 未使用备用码的 SHA-256 摘要
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+}
+
+// GetUserTwoFactor 获取用户的两步验证配置，未设置时返回 ErrTwoFactorNotFound
+func GetUserTwoFactor(userID int64) (*UserTwoFactor, error) {
+	tf := &UserTwoFactor{UserID: userID}
+	var (
+		secret      string
+		backupCodes sql.NullString
+		enabledAt   sql.NullTime
+	)
+	err := db.QueryRow(
+		`SELECT secret, enabled, backup_codes, last_used_step, created_at, enabled_at
+		 FROM user_two_factor WHERE user_id = ?`,
+		userID,
+	).Scan(&secret, &tf.Enabled, &backupCodes, &tf.LastUsedStep, &tf.CreatedAt, &enabledAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTwoFactorNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tf.Secret, err = utils.DecryptSensitiveData(secret)
+	if err != nil {
+		return nil, err
+	}
+	if backupCodes.Valid && backupCodes.String != "" {
+		if err := json.Unmarshal([]byte(backupCodes.String), &tf.BackupCodes); err != nil {
+			return nil, err
+		}
+	}
+	if enabledAt.Valid {
+		tf.EnabledAt = &enabledAt.Time
+	}
+	return tf, nil
+}
+
+// IsTwoFactorEnabled 用户是否已启用两步验证
+func IsTwoFactorEnabled(userID int64) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(`SELECT enabled FROM user_two_factor WHERE user_id = ?`, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// SaveTwoFactorSecret 保存待确认的 TOTP 密钥（覆盖之前未完成的设置）；已启用时返回 ErrTwoFactorAlreadyEnabled
+func SaveTwoFactorSecret(userID int64, secret string) error {
+	encrypted, err := utils.EncryptSensitiveData(secret)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to encrypt two-factor secret, storing as plaintext")
+		encrypted = secret
+	}
+
+	result, err := db.Exec(
+		`INSERT INTO user_two_factor (user_id, secret, enabled, created_at) VALUES (?, ?, FALSE, ?)
+		 ON DUPLICATE KEY UPDATE
+			secret = IF(enabled, secret, VALUES(secret)),
+			backup_codes = IF(enabled, backup_codes, NULL),
+			last_used_step = IF(enabled, last_used_step, 0),
+			created_at = IF(enabled, created_at, VALUES(created_at))`,
+		userID, encrypted, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	// 新插入时影响 1 行，覆盖未启用的设置时影响 2 行，已启用时不变为 0 行
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTwoFactor 确认设置并启用两步验证，step 为确认时使用的 TOTP 时间步
+func EnableTwoFactor(userID int64, backupCodeHashes []string, step int64) error {
+	data, err := json.Marshal(backupCodeHashes)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(
+		`UPDATE user_two_factor SET enabled = TRUE, backup_codes = ?, last_used_step = ?, enabled_at = ?
+		 WHERE user_id = ? AND enabled = FALSE`,
+		string(data), step, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if enabled, err := IsTwoFactorEnabled(userID); err == nil && enabled {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		return ErrTwoFactorNotFound
+	}
+	return nil
+}
+
+// DisableTwoFactor 关闭两步验证并删除密钥与备用码
+func DisableTwoFactor(userID int64) error {
+	_, err := db.Exec(`DELETE FROM user_two_factor WHERE user_id = ?`, userID)
+	return err
+}
+
+// SetTwoFactorBackupCodes 替换用户的备用码（旧备用码全部失效）
+func SetTwoFactorBackupCodes(userID int64, backupCodeHashes []string) error {
+	data, err := json.Marshal(backupCodeHashes)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(
+		`UPDATE user_two_factor SET backup_codes = ? WHERE user_id = ? AND enabled = TRUE`,
+		string(data), userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTwoFactorNotFound
+	}
+	return nil
+}
+
+// MarkTwoFactorStep 记录已使用的 TOTP 时间步；step 不晚于上次使用的时间步（验证码重放）时返回 false
+func MarkTwoFactorStep(userID, step int64) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE user_two_factor SET last_used_step = ? WHERE user_id = ? AND enabled = TRUE AND last_used_step < ?`,
+		step, userID, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ConsumeTwoFactorBackupCode 使用一个备用码，备用码不存在或已被并发使用时返回 false
+func ConsumeTwoFactorBackupCode(userID int64, codeHash string) (bool, error) {
+	tf, err := GetUserTwoFactor(userID)
+	if err != nil {
+		if err == ErrTwoFactorNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	remaining := make([]string, 0, len(tf.BackupCodes))
+	found := false
+	for _, h := range tf.BackupCodes {
+		if !found && h == codeHash {
+			found = true
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	if !found {
+		return false, nil
+	}
+
+	before, err := json.Marshal(tf.BackupCodes)
+	if err != nil {
+		return false, err
+	}
+	after, err := json.Marshal(remaining)
+	if err != nil {
+		return false, err
+	}
+	// 以读取时的备用码列表为条件更新，避免同一备用码被并发使用两次
+	result, err := db.Exec(
+		`UPDATE user_two_factor SET backup_codes = ? WHERE user_id = ? AND enabled = TRUE AND backup_codes = ?`,
+		string(after), userID, string(before),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
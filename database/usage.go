@@ -18,6 +18,7 @@ type UsageRecord struct {
 	APIToken         string    `db:"api_token"`
 	TokenName        string    `db:"token_name"`
 	Model            string    `db:"model"`
+	Provider         string    `db:"provider"`
 	PromptTokens     int       `db:"prompt_tokens"`
 	CompletionTokens int       `db:"completion_tokens"`
 	TotalTokens      int       `db:"total_tokens"`
@@ -27,6 +28,8 @@ type UsageRecord struct {
 	RequestTime      time.Time `db:"request_time"`
 	ResponseTime     time.Time `db:"response_time"`
 	DurationMs       int       `db:"duration_ms"`
+	Seed             *int64    `db:"seed"`     // Sampling seed sent to the provider, nil when none
+	Language         string    `db:"language"` // Detected prompt language, empty when not detected
 	CreatedAt        time.Time `db:"created_at"`
 }
 
@@ -38,6 +41,8 @@ type UsageFilter struct {
 	Model     *string
 	Limit     int
 	Offset    int
+	// PrivacyOverride bypasses aggregation-only mode; callers must audit its use
+	PrivacyOverride bool
 }
 
 // UsageStats represents aggregated usage statistics
@@ -77,6 +82,9 @@ type AggregateStats struct {
 	TopUsers      []UserUsageSummary
 	TopModels     []ModelStats
 	UsageTrends   []DailyStats
+	// Suppressed is set when aggregation-only mode withheld the totals because
+	// fewer users than the minimum group size were active in the period
+	Suppressed bool
 }
 
 // UserUsageSummary represents a summary of a user's usage
@@ -99,14 +107,14 @@ func InsertUsageRecord(record *UsageRecord) error {
 			user_id, username, api_token, token_name, model,
 			prompt_tokens, completion_tokens, total_tokens,
 			cursor_session, status_code, error_message,
-			request_time, response_time, duration_ms
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			request_time, response_time, duration_ms, provider, seed, language
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	result, err := dbConn.Exec(query,
 		record.UserID,
 		record.Username,
-		record.APIToken,
+		usageRecordToken(record.APIToken),
 		record.TokenName,
 		record.Model,
 		record.PromptTokens,
@@ -118,6 +126,9 @@ func InsertUsageRecord(record *UsageRecord) error {
 		record.RequestTime,
 		record.ResponseTime,
 		record.DurationMs,
+		nullIfEmpty(record.Provider),
+		record.Seed,
+		nullIfEmpty(record.Language),
 	)
 
 	if err != nil {
@@ -161,8 +172,8 @@ func BatchInsertUsageRecords(records []*UsageRecord) error {
 			user_id, username, api_token, token_name, model,
 			prompt_tokens, completion_tokens, total_tokens,
 			cursor_session, status_code, error_message,
-			request_time, response_time, duration_ms
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			request_time, response_time, duration_ms, provider, seed, language
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	stmt, err := tx.Prepare(query)
@@ -175,7 +186,7 @@ func BatchInsertUsageRecords(records []*UsageRecord) error {
 		_, err := stmt.Exec(
 			record.UserID,
 			record.Username,
-			record.APIToken,
+			usageRecordToken(record.APIToken),
 			record.TokenName,
 			record.Model,
 			record.PromptTokens,
@@ -187,6 +198,9 @@ func BatchInsertUsageRecords(records []*UsageRecord) error {
 			record.RequestTime,
 			record.ResponseTime,
 			record.DurationMs,
+			nullIfEmpty(record.Provider),
+			record.Seed,
+			nullIfEmpty(record.Language),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert record in batch: %w", err)
@@ -297,9 +311,10 @@ func GetUsageRecordsByToken(token string, filter UsageFilter) ([]*UsageRecord, e
 			   cursor_session, status_code, error_message,
 			   request_time, response_time, duration_ms, created_at
 		FROM usage_records
-		WHERE api_token = ?
+		WHERE api_token IN (?, ?)
 	`
-	args := []interface{}{token}
+	// 历史记录迁移完成前可能仍是明文，同时匹配指纹与原值
+	args := []interface{}{APITokenFingerprint(token), token}
 
 	// Apply filters
 	if filter.StartDate != nil {
@@ -573,6 +588,13 @@ func GetAllUsageStats(filter UsageFilter) (*AggregateStats, error) {
 		return nil, fmt.Errorf("failed to get aggregate stats: %w", err)
 	}
 
+	// In aggregation-only mode every group must span at least minUsers users;
+	// per-user rankings are never returned
+	minUsers := filter.minGroupSize()
+	if minUsers > 0 && stats.TotalUsers < minUsers {
+		return &AggregateStats{Suppressed: true}, nil
+	}
+
 	// Get top users
 	topUsersQuery := `
 		SELECT 
@@ -596,24 +618,26 @@ func GetAllUsageStats(filter UsageFilter) (*AggregateStats, error) {
 
 	topUsersQuery += " GROUP BY user_id, username ORDER BY total_tokens DESC LIMIT 10"
 
-	rows, err := dbConn.Query(topUsersQuery, topUsersArgs...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get top users: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var userSummary UserUsageSummary
-		err := rows.Scan(
-			&userSummary.UserID,
-			&userSummary.Username,
-			&userSummary.Requests,
-			&userSummary.TotalTokens,
-		)
+	if minUsers == 0 {
+		rows, err := dbConn.Query(topUsersQuery, topUsersArgs...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan user summary: %w", err)
+			return nil, fmt.Errorf("failed to get top users: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userSummary UserUsageSummary
+			err := rows.Scan(
+				&userSummary.UserID,
+				&userSummary.Username,
+				&userSummary.Requests,
+				&userSummary.TotalTokens,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan user summary: %w", err)
+			}
+			stats.TopUsers = append(stats.TopUsers, userSummary)
 		}
-		stats.TopUsers = append(stats.TopUsers, userSummary)
 	}
 
 	// Get top models
@@ -638,9 +662,14 @@ func GetAllUsageStats(filter UsageFilter) (*AggregateStats, error) {
 		topModelsArgs = append(topModelsArgs, *filter.EndDate)
 	}
 
-	topModelsQuery += " GROUP BY model ORDER BY request_count DESC"
+	topModelsQuery += " GROUP BY model"
+	if minUsers > 0 {
+		topModelsQuery += " HAVING COUNT(DISTINCT user_id) >= ?"
+		topModelsArgs = append(topModelsArgs, minUsers)
+	}
+	topModelsQuery += " ORDER BY request_count DESC"
 
-	rows, err = dbConn.Query(topModelsQuery, topModelsArgs...)
+	rows, err := dbConn.Query(topModelsQuery, topModelsArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get top models: %w", err)
 	}
@@ -725,6 +754,21 @@ func GetModelUsageBreakdown(userID *int64, filter UsageFilter) (map[string]Model
 
 // GetDailyUsageTrends retrieves daily usage trends for the specified number of days
 func GetDailyUsageTrends(userID *int64, days int) ([]DailyStats, error) {
+	return queryDailyUsageTrends(userID, days, 0)
+}
+
+// GetAdminDailyUsageTrends retrieves daily usage trends for the admin views, optionally
+// for one user. In aggregation-only mode the user filter is refused and days with
+// fewer distinct users than the minimum group size are omitted
+func GetAdminDailyUsageTrends(filter UsageFilter, days int) ([]DailyStats, error) {
+	if filter.UserID != nil && !filter.PerUserAllowed() {
+		return nil, ErrAggregateOnly
+	}
+	return queryDailyUsageTrends(filter.UserID, days, filter.minGroupSize())
+}
+
+// queryDailyUsageTrends groups usage by day; minUsers > 0 drops days with fewer distinct users
+func queryDailyUsageTrends(userID *int64, days int, minUsers int) ([]DailyStats, error) {
 	dbConn, err := GetDB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database connection: %w", err)
@@ -747,7 +791,12 @@ func GetDailyUsageTrends(userID *int64, days int) ([]DailyStats, error) {
 		args = append(args, *userID)
 	}
 
-	query += " GROUP BY DATE(request_time) ORDER BY date ASC"
+	query += " GROUP BY DATE(request_time)"
+	if minUsers > 0 {
+		query += " HAVING COUNT(DISTINCT user_id) >= ?"
+		args = append(args, minUsers)
+	}
+	query += " ORDER BY date ASC"
 
 	rows, err := dbConn.Query(query, args...)
 	if err != nil {
@@ -807,7 +856,12 @@ func GetCursorSessionUsage(filter UsageFilter) ([]CursorSessionStats, error) {
 		args = append(args, *filter.EndDate)
 	}
 
-	query += " GROUP BY cursor_session ORDER BY requests DESC"
+	query += " GROUP BY cursor_session"
+	if minUsers := filter.minGroupSize(); minUsers > 0 {
+		query += " HAVING COUNT(DISTINCT user_id) >= ?"
+		args = append(args, minUsers)
+	}
+	query += " ORDER BY requests DESC"
 
 	rows, err := dbConn.Query(query, args...)
 	if err != nil {
@@ -832,9 +886,84 @@ func GetCursorSessionUsage(filter UsageFilter) ([]CursorSessionStats, error) {
 	return sessions, nil
 }
 
+// LanguageStats represents usage statistics for a detected prompt language
+type LanguageStats struct {
+	Language    string
+	Requests    int
+	TotalTokens int64
+	Users       int
+}
+
+// GetLanguageDistribution retrieves usage grouped by detected prompt language;
+// requests recorded without detection are grouped under "und"
+func GetLanguageDistribution(filter UsageFilter) ([]LanguageStats, error) {
+	dbConn, err := GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	query := `
+		SELECT 
+			COALESCE(language, 'und') as lang,
+			COUNT(*) as requests,
+			COALESCE(SUM(total_tokens), 0) as total_tokens,
+			COUNT(DISTINCT user_id) as users
+		FROM usage_records
+		WHERE 1=1
+	`
+	args := []interface{}{}
+
+	if filter.StartDate != nil {
+		query += " AND request_time >= ?"
+		args = append(args, *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query += " AND request_time <= ?"
+		args = append(args, *filter.EndDate)
+	}
+	if filter.Model != nil {
+		query += " AND model = ?"
+		args = append(args, *filter.Model)
+	}
+
+	query += " GROUP BY lang"
+	if minUsers := filter.minGroupSize(); minUsers > 0 {
+		query += " HAVING COUNT(DISTINCT user_id) >= ?"
+		args = append(args, minUsers)
+	}
+	query += " ORDER BY requests DESC"
+
+	rows, err := dbConn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get language distribution: %w", err)
+	}
+	defer rows.Close()
+
+	var languages []LanguageStats
+	for rows.Next() {
+		var stats LanguageStats
+		err := rows.Scan(
+			&stats.Language,
+			&stats.Requests,
+			&stats.TotalTokens,
+			&stats.Users,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan language stats: %w", err)
+		}
+		languages = append(languages, stats)
+	}
+
+	return languages, rows.Err()
+}
+
 // StreamUsageRecordsCSV streams usage records as CSV directly to the writer
 // This function processes records in chunks to avoid loading all data into memory
 func StreamUsageRecordsCSV(writer io.Writer, filter UsageFilter) error {
+	if !filter.PerUserAllowed() {
+		return ErrAggregateOnly
+	}
+
 	dbConn, err := GetDB()
 	if err != nil {
 		return fmt.Errorf("failed to get database connection: %w", err)
@@ -1281,3 +1410,149 @@ func CountUsageRecordsOlderThan(cutoffDate time.Time) (int64, error) {
 
 	return count, nil
 }
+
+// ModelTokenUsage is the successful token usage of one user on one model
+type ModelTokenUsage struct {
+	UserID           int64
+	Username         string
+	Model            string
+	Requests         int
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// GetModelTokenUsageSince sums the token usage of successful requests since the
+// given time, grouped by user and model
+func GetModelTokenUsageSince(since time.Time) ([]ModelTokenUsage, error) {
+	dbConn, err := GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	query := `
+		SELECT 
+			user_id,
+			MAX(username) as username,
+			model,
+			COUNT(*) as requests,
+			COALESCE(SUM(prompt_tokens), 0) as prompt_tokens,
+			COALESCE(SUM(completion_tokens), 0) as completion_tokens
+		FROM usage_records
+		WHERE request_time >= ? AND status_code >= 200 AND status_code < 300
+		GROUP BY user_id, model
+	`
+	rows, err := dbConn.Query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model token usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []ModelTokenUsage
+	for rows.Next() {
+		var u ModelTokenUsage
+		var username sql.NullString
+		if err := rows.Scan(&u.UserID, &username, &u.Model, &u.Requests, &u.PromptTokens, &u.CompletionTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan model token usage: %w", err)
+		}
+		u.Username = username.String
+		usage = append(usage, u)
+	}
+
+	return usage, rows.Err()
+}
+
+// KeyUsageSummary aggregates the usage_records of a single API key
+type KeyUsageSummary struct {
+	Requests         int64           `json:"requests"`
+	Errors           int64           `json:"errors"`
+	PromptTokens     int64           `json:"prompt_tokens"`
+	CompletionTokens int64           `json:"completion_tokens"`
+	TotalTokens      int64           `json:"total_tokens"`
+	AvgDurationMs    float64         `json:"avg_duration_ms"`
+	FirstSeenAt      *time.Time      `json:"first_seen_at,omitempty"`
+	LastSeenAt       *time.Time      `json:"last_seen_at,omitempty"`
+	ByModel          []KeyModelUsage `json:"by_model"`
+}
+
+// KeyModelUsage is the per-model part of a KeyUsageSummary
+type KeyModelUsage struct {
+	Model            string `json:"model"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+}
+
+// GetKeyUsageSummary summarizes the usage of an API key since the given time
+// (nil for all time), with the per-model breakdown ordered by request count
+func GetKeyUsageSummary(token string, since *time.Time) (*KeyUsageSummary, error) {
+	dbConn, err := GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	// 历史记录迁移完成前可能仍是明文，同时匹配指纹与原值
+	where := " WHERE api_token IN (?, ?)"
+	args := []interface{}{APITokenFingerprint(token), token}
+	if since != nil {
+		where += " AND request_time >= ?"
+		args = append(args, *since)
+	}
+
+	summary := &KeyUsageSummary{ByModel: []KeyModelUsage{}}
+	var firstSeen, lastSeen sql.NullTime
+	err = dbConn.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(status_code >= 400), 0),
+			COALESCE(SUM(prompt_tokens), 0),
+			COALESCE(SUM(completion_tokens), 0),
+			COALESCE(SUM(total_tokens), 0),
+			COALESCE(AVG(duration_ms), 0),
+			MIN(request_time),
+			MAX(request_time)
+		FROM usage_records`+where, args...).Scan(
+		&summary.Requests,
+		&summary.Errors,
+		&summary.PromptTokens,
+		&summary.CompletionTokens,
+		&summary.TotalTokens,
+		&summary.AvgDurationMs,
+		&firstSeen,
+		&lastSeen,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key usage summary: %w", err)
+	}
+	if firstSeen.Valid {
+		summary.FirstSeenAt = &firstSeen.Time
+	}
+	if lastSeen.Valid {
+		summary.LastSeenAt = &lastSeen.Time
+	}
+
+	rows, err := dbConn.Query(`
+		SELECT
+			model,
+			COUNT(*) as request_count,
+			COALESCE(SUM(prompt_tokens), 0),
+			COALESCE(SUM(completion_tokens), 0),
+			COALESCE(SUM(total_tokens), 0)
+		FROM usage_records`+where+`
+		GROUP BY model
+		ORDER BY request_count DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key model breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mu KeyModelUsage
+		if err := rows.Scan(&mu.Model, &mu.Requests, &mu.PromptTokens, &mu.CompletionTokens, &mu.TotalTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan key model breakdown: %w", err)
+		}
+		summary.ByModel = append(summary.ByModel, mu)
+	}
+
+	return summary, rows.Err()
+}
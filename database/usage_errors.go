@@ -0,0 +1,135 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// usageErrorReasonLength 错误原因按前若干个字符归并，避免同类错误因附带的 ID 等细节分散
+const usageErrorReasonLength = 200
+
+// UsageErrorGroup 按某一维度分组的失败请求数
+type UsageErrorGroup struct {
+	Key       string
+	TokenName string // Only set for groups by API key
+	Count     int
+	LastSeen  time.Time
+}
+
+// UsageErrorReason 一类失败原因（状态码 + 模型 + 错误信息）
+type UsageErrorReason struct {
+	StatusCode int
+	Model      string
+	Message    string
+	Count      int
+	LastSeen   time.Time
+}
+
+// UsageErrorSummary 用户在时间范围内的失败请求汇总
+type UsageErrorSummary struct {
+	TotalRequests  int
+	FailedRequests int
+	ByStatus       []UsageErrorGroup
+	ByModel        []UsageErrorGroup
+	ByKey          []UsageErrorGroup
+	TopReasons     []UsageErrorReason
+}
+
+// GetUserErrorSummary summarizes a user's failed requests (status >= 400) in the
+// filter's date range, grouped by status code, model and API key, with the most
+// frequent error reasons. filter.Limit bounds the number of reasons (default 20)
+func GetUserErrorSummary(userID int64, filter UsageFilter) (*UsageErrorSummary, error) {
+	dbConn, err := GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	where := " WHERE user_id = ?"
+	args := []interface{}{userID}
+	if filter.StartDate != nil {
+		where += " AND request_time >= ?"
+		args = append(args, *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		where += " AND request_time <= ?"
+		args = append(args, *filter.EndDate)
+	}
+	if filter.Model != nil {
+		where += " AND model = ?"
+		args = append(args, *filter.Model)
+	}
+
+	summary := &UsageErrorSummary{}
+	err = dbConn.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END), 0)
+		 FROM usage_records`+where,
+		args...,
+	).Scan(&summary.TotalRequests, &summary.FailedRequests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user requests: %w", err)
+	}
+	if summary.FailedRequests == 0 {
+		return summary, nil
+	}
+
+	failedWhere := where + " AND status_code >= 400"
+	groups := []struct {
+		column string
+		target *[]UsageErrorGroup
+	}{
+		{"CAST(status_code AS CHAR)", &summary.ByStatus},
+		{"model", &summary.ByModel},
+		{"api_token", &summary.ByKey},
+	}
+	for _, g := range groups {
+		rows, err := dbConn.Query(
+			`SELECT `+g.column+`, MAX(token_name), COUNT(*) AS failures, MAX(request_time)
+			 FROM usage_records`+failedWhere+`
+			 GROUP BY `+g.column+` ORDER BY failures DESC`,
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to group user errors: %w", err)
+		}
+		for rows.Next() {
+			var group UsageErrorGroup
+			var tokenName *string
+			if err := rows.Scan(&group.Key, &tokenName, &group.Count, &group.LastSeen); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan user error group: %w", err)
+			}
+			if g.target == &summary.ByKey && tokenName != nil {
+				group.TokenName = *tokenName
+			}
+			*g.target = append(*g.target, group)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := dbConn.Query(
+		fmt.Sprintf(`SELECT status_code, model, LEFT(COALESCE(error_message, ''), %d) AS reason,
+			COUNT(*) AS failures, MAX(request_time)
+			FROM usage_records%s
+			GROUP BY status_code, model, reason ORDER BY failures DESC LIMIT ?`, usageErrorReasonLength, failedWhere),
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user error reasons: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var reason UsageErrorReason
+		if err := rows.Scan(&reason.StatusCode, &reason.Model, &reason.Message, &reason.Count, &reason.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan user error reason: %w", err)
+		}
+		summary.TopReasons = append(summary.TopReasons, reason)
+	}
+	return summary, rows.Err()
+}
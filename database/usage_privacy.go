@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"sync"
+)
+
+// AuditActionUsagePrivacyOverride is recorded whenever an admin bypasses aggregation-only mode
+const AuditActionUsagePrivacyOverride = "usage.privacy_override"
+
+// ErrAggregateOnly is returned by admin usage queries that would expose per-user
+// data while aggregation-only mode is enabled and not overridden
+var ErrAggregateOnly = errors.New("per-user usage data is unavailable in aggregation-only mode")
+
+// UsagePrivacy 管理后台使用统计的隐私模式：启用 AggregateOnly 后，管理员查询只返回
+// 至少包含 MinGroupSize 个不同用户的聚合分组，不返回任何按用户的数据
+type UsagePrivacy struct {
+	AggregateOnly bool `json:"aggregate_only"`
+	MinGroupSize  int  `json:"min_group_size"`
+}
+
+var (
+	usagePrivacyMu sync.RWMutex
+	usagePrivacy   UsagePrivacy
+)
+
+// ConfigureUsagePrivacy sets the aggregation-only mode applied by admin usage queries
+func ConfigureUsagePrivacy(aggregateOnly bool, minGroupSize int) {
+	if minGroupSize < 1 {
+		minGroupSize = 1
+	}
+	usagePrivacyMu.Lock()
+	usagePrivacy = UsagePrivacy{AggregateOnly: aggregateOnly, MinGroupSize: minGroupSize}
+	usagePrivacyMu.Unlock()
+}
+
+// GetUsagePrivacy returns the current usage privacy mode
+func GetUsagePrivacy() UsagePrivacy {
+	usagePrivacyMu.RLock()
+	defer usagePrivacyMu.RUnlock()
+	return usagePrivacy
+}
+
+// PerUserAllowed reports whether the filter may read per-user usage data
+func (f UsageFilter) PerUserAllowed() bool {
+	return f.minGroupSize() == 0
+}
+
+// minGroupSize returns the minimum distinct users an aggregate group must contain
+// for the filter, or 0 when aggregation-only mode does not apply
+func (f UsageFilter) minGroupSize() int {
+	privacy := GetUsagePrivacy()
+	if !privacy.AggregateOnly || f.PrivacyOverride {
+		return 0
+	}
+	return privacy.MinGroupSize
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// UsageTokenChat 在线聊天写入 usage_records 的伪令牌，不是 API 密钥，保留原值
+const UsageTokenChat = "chat"
+
+// tokenFingerprintPrefix 标识 usage_records.api_token 中已替换为指纹的值
+const tokenFingerprintPrefix = "fp_"
+
+// tokenFingerprintHexLen 指纹保留的 SHA-256 十六进制位数
+const tokenFingerprintHexLen = 24
+
+// SettingKeyUsageTokenMigration 历史 usage_records 令牌指纹化迁移的进度（JSON）
+const SettingKeyUsageTokenMigration = "usage_token_migration"
+
+// APITokenFingerprint 返回 API 密钥的指纹（fp_ + SHA-256 前 24 位十六进制）。
+// usage_records 只保存指纹，按密钥统计时用同一函数计算后匹配
+func APITokenFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return tokenFingerprintPrefix + hex.EncodeToString(sum[:])[:tokenFingerprintHexLen]
+}
+
+// usageRecordToken 返回写入 usage_records 的令牌值：API 密钥替换为指纹，空值、伪令牌和已有指纹保持不变
+func usageRecordToken(token string) string {
+	if token == "" || token == UsageTokenChat || strings.HasPrefix(token, tokenFingerprintPrefix) {
+		return token
+	}
+	return APITokenFingerprint(token)
+}
+
+// UsageTokenMigrationState 迁移进度：按 id 递增分批处理到 MaxID（迁移开始时的最大 id，之后写入的记录已是指纹）
+type UsageTokenMigrationState struct {
+	LastID     int64      `json:"last_id"`
+	MaxID      int64      `json:"max_id"`
+	Migrated   int64      `json:"migrated"`
+	Done       bool       `json:"done"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// GetUsageTokenMigrationState 获取迁移进度，尚未开始时返回 nil
+func GetUsageTokenMigrationState() (*UsageTokenMigrationState, error) {
+	state := &UsageTokenMigrationState{}
+	if err := GetJSONSetting(SettingKeyUsageTokenMigration, state); err != nil {
+		if err == ErrSettingNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// SaveUsageTokenMigrationState 保存迁移进度
+func SaveUsageTokenMigrationState(state *UsageTokenMigrationState) error {
+	return SetJSONSetting(SettingKeyUsageTokenMigration, state)
+}
+
+// GetMaxUsageRecordID 返回 usage_records 当前最大 id，表为空时返回 0
+func GetMaxUsageRecordID() (int64, error) {
+	var maxID int64
+	err := db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM usage_records`).Scan(&maxID)
+	return maxID, err
+}
+
+// FingerprintUsageTokens 将 id 在 (afterID, upToID] 范围内仍为明文的 api_token 替换为指纹，
+// 返回更新的行数。MySQL 的 SHA2 与 APITokenFingerprint 结果一致
+func FingerprintUsageTokens(afterID, upToID int64) (int64, error) {
+	result, err := db.Exec(
+		`UPDATE usage_records
+		 SET api_token = CONCAT(?, LEFT(SHA2(api_token, 256), ?))
+		 WHERE id > ? AND id <= ? AND api_token <> '' AND api_token <> ? AND api_token NOT LIKE 'fp\_%'`,
+		tokenFingerprintPrefix, tokenFingerprintHexLen, afterID, upToID, UsageTokenChat,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
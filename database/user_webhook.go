@@ -0,0 +1,228 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Webhook 投递状态
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// webhookDeliveryLease 领取投递后的租约时长，进程在投递中退出时租约到期后由其他实例重新领取
+const webhookDeliveryLease = 2 * time.Minute
+
+// UserWebhook 用户配置的任务完成通知 Webhook
+type UserWebhook struct {
+	UserID        int64     `json:"user_id"`
+	URL           string    `json:"url"`
+	Secret        string    `json:"-"`
+	Enabled       bool      `json:"enabled"`
+	EmailFallback bool      `json:"email_fallback"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery 一次 Webhook 投递（含重试状态）
+type WebhookDelivery struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"user_id"`
+	Event         string     `json:"event"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// GetUserWebhook 获取用户的 Webhook 配置，未配置时返回 nil
+func GetUserWebhook(userID int64) (*UserWebhook, error) {
+	w := &UserWebhook{UserID: userID}
+	err := db.QueryRow(
+		`SELECT url, secret, enabled, email_fallback, created_at, updated_at
+		 FROM user_webhooks WHERE user_id = ?`,
+		userID,
+	).Scan(&w.URL, &w.Secret, &w.Enabled, &w.EmailFallback, &w.CreatedAt, &w.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SaveUserWebhook 创建或更新用户的 Webhook 配置
+func SaveUserWebhook(w *UserWebhook) error {
+	_, err := db.Exec(
+		`INSERT INTO user_webhooks (user_id, url, secret, enabled, email_fallback)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE url = VALUES(url), secret = VALUES(secret),
+		 enabled = VALUES(enabled), email_fallback = VALUES(email_fallback)`,
+		w.UserID, w.URL, w.Secret, w.Enabled, w.EmailFallback,
+	)
+	return err
+}
+
+// DeleteUserWebhook 删除用户的 Webhook 配置，尚未投递的通知一并取消
+func DeleteUserWebhook(userID int64) error {
+	if _, err := db.Exec("DELETE FROM user_webhooks WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, last_error = ? WHERE user_id = ? AND status = ?",
+		WebhookDeliveryFailed, "webhook removed", userID, WebhookDeliveryPending,
+	)
+	return err
+}
+
+// CreateWebhookDelivery 加入一条待投递的通知，立即可被领取
+func CreateWebhookDelivery(userID int64, event, payload string) (int64, error) {
+	result, err := db.Exec(
+		"INSERT INTO webhook_deliveries (user_id, event, payload, next_attempt_at) VALUES (?, ?, ?, ?)",
+		userID, event, payload, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ClaimDueWebhookDeliveries 领取最多 limit 条到期的待投递通知。
+// 领取时把 next_attempt_at 推迟一个租约时长，多实例部署时同一条通知不会被重复投递
+func ClaimDueWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	now := time.Now()
+	rows, err := db.Query(
+		`SELECT id, next_attempt_at FROM webhook_deliveries
+		 WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`,
+		WebhookDeliveryPending, now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type candidate struct {
+		id  int64
+		due time.Time
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var cand candidate
+		if err := rows.Scan(&cand.id, &cand.due); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, cand)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var claimed []*WebhookDelivery
+	for _, cand := range candidates {
+		// 条件更新：只有 next_attempt_at 未被其他实例改动时才算领取成功
+		result, err := db.Exec(
+			`UPDATE webhook_deliveries SET next_attempt_at = ?
+			 WHERE id = ? AND status = ? AND next_attempt_at = ?`,
+			now.Add(webhookDeliveryLease), cand.id, WebhookDeliveryPending, cand.due,
+		)
+		if err != nil {
+			return claimed, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		d, err := getWebhookDelivery(cand.id)
+		if err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, d)
+	}
+	return claimed, nil
+}
+
+// MarkWebhookDelivered 记录投递成功
+func MarkWebhookDelivered(id int64, attempts int) error {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, last_error = NULL, delivered_at = ? WHERE id = ?",
+		WebhookDeliveryDelivered, attempts, time.Now(), id,
+	)
+	return err
+}
+
+// MarkWebhookRetry 记录一次失败并安排下次重试
+func MarkWebhookRetry(id int64, attempts int, lastError string, nextAttemptAt time.Time) error {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		attempts, truncateWebhookError(lastError), nextAttemptAt, id,
+	)
+	return err
+}
+
+// MarkWebhookFailed 重试次数用尽，标记投递失败
+func MarkWebhookFailed(id int64, attempts int, lastError string) error {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, last_error = ? WHERE id = ?",
+		WebhookDeliveryFailed, attempts, truncateWebhookError(lastError), id,
+	)
+	return err
+}
+
+// ListWebhookDeliveries 按时间倒序列出用户最近的投递记录
+func ListWebhookDeliveries(userID int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.Query(
+		`SELECT id, user_id, event, payload, status, attempts, next_attempt_at, last_error, delivered_at, created_at
+		 FROM webhook_deliveries WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func getWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	return scanWebhookDelivery(db.QueryRow(
+		`SELECT id, user_id, event, payload, status, attempts, next_attempt_at, last_error, delivered_at, created_at
+		 FROM webhook_deliveries WHERE id = ?`,
+		id,
+	))
+}
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (*WebhookDelivery, error) {
+	d := &WebhookDelivery{}
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.UserID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &lastError, &deliveredAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	d.LastError = lastError.String
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, nil
+}
+
+// truncateWebhookError 截断错误信息以适配 last_error 列长度
+func truncateWebhookError(msg string) string {
+	if len(msg) > 500 {
+		return msg[:500]
+	}
+	return msg
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// vacuumBatchSize caps the rows removed by one DELETE so vacuuming never holds long table locks
+const vacuumBatchSize = 1000
+
+// deleteInBatches repeats query (which must end in LIMIT ?) until a batch removes
+// fewer than vacuumBatchSize rows, and returns the total removed
+func deleteInBatches(query string, args ...interface{}) (int64, error) {
+	args = append(args, vacuumBatchSize)
+	var total int64
+	for {
+		result, err := db.Exec(query, args...)
+		if err != nil {
+			return total, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += rows
+		if rows < vacuumBatchSize {
+			return total, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// VacuumExpiredSessions 删除已过期的登录会话，返回删除条数
+func VacuumExpiredSessions() (int64, error) {
+	n, err := deleteInBatches(`DELETE FROM sessions WHERE expires_at < ? LIMIT ?`, time.Now())
+	if err != nil {
+		return n, fmt.Errorf("failed to vacuum sessions: %w", err)
+	}
+	return n, nil
+}
+
+// VacuumVerificationCodes 删除已使用或已过期的验证码，返回删除条数。
+// 找回密码验证码在 PasswordResetIPWindow 内保留，按 IP 的申请频率限制依赖这些记录
+func VacuumVerificationCodes() (int64, error) {
+	now := time.Now()
+	n, err := deleteInBatches(
+		`DELETE FROM verification_codes WHERE (used = TRUE OR expires_at < ?) AND NOT (code_type = ? AND created_at >= ?) LIMIT ?`,
+		now, CodeTypePasswordReset, now.Add(-PasswordResetIPWindow),
+	)
+	if err != nil {
+		return n, fmt.Errorf("failed to vacuum verification codes: %w", err)
+	}
+	return n, nil
+}
+
+// VacuumExpiredOAuthStates 删除已过期的 OAuth state，返回删除条数
+func VacuumExpiredOAuthStates() (int64, error) {
+	n, err := deleteInBatches(`DELETE FROM oauth_states WHERE expires_at < ? LIMIT ?`, time.Now())
+	if err != nil {
+		return n, fmt.Errorf("failed to vacuum oauth states: %w", err)
+	}
+	return n, nil
+}
+
+// VacuumStaleChatAttachments 删除上传后一天内未随消息发送的聊天附件，返回删除条数
+func VacuumStaleChatAttachments() (int64, error) {
+	n, err := deleteInBatches(`DELETE FROM chat_attachments WHERE message_id IS NULL AND created_at < ? LIMIT ?`, time.Now().Add(-chatAttachmentPendingTTL))
+	if err != nil {
+		return n, fmt.Errorf("failed to vacuum chat attachments: %w", err)
+	}
+	return n, nil
+}
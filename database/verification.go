@@ -16,6 +16,15 @@ var (
 
 const VerificationExpiry = 10 * time.Minute
 
+// MaxCodeAttempts 单个验证码允许输错的次数，达到后验证码作废，防止暴力猜测
+const MaxCodeAttempts = 5
+
+// CodeTypePasswordReset 找回密码验证码
+const CodeTypePasswordReset = "password_reset"
+
+// PasswordResetIPWindow 按 IP 统计找回密码申请次数的时间窗口，窗口内的记录不会被清理任务删除
+const PasswordResetIPWindow = time.Hour
+
 // VerificationCode 验证码模型
 type VerificationCode struct {
 	ID        int64     `json:"id"`
@@ -84,16 +93,25 @@ func VerifyCode(email, code, codeType string) error {
 		return ErrCodeExpired
 	}
 	
-	// 验证码是否匹配
+	// 验证码是否匹配，输错达到上限后作废
 	if vc.Code != code {
+		if _, err := db.Exec(
+			`UPDATE verification_codes SET attempts = attempts + 1, used = (attempts >= ?) WHERE id = ?`,
+			MaxCodeAttempts, vc.ID,
+		); err != nil {
+			return err
+		}
 		return ErrCodeInvalid
 	}
 	
-	// 标记为已使用
-	_, err = db.Exec(`UPDATE verification_codes SET used = TRUE WHERE id = ?`, vc.ID)
+	// 标记为已使用（并发提交同一验证码时只有一个请求成功）
+	result, err := db.Exec(`UPDATE verification_codes SET used = TRUE WHERE id = ? AND used = FALSE`, vc.ID)
 	if err != nil {
 		return err
 	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCodeNotFound
+	}
 	
 	return nil
 }
@@ -128,8 +146,12 @@ func InvalidateOldCodes(email, codeType string) error {
 	return err
 }
 
-// CleanExpiredCodes 清理过期验证码
-func CleanExpiredCodes() error {
-	_, err := db.Exec(`DELETE FROM verification_codes WHERE expires_at < ?`, time.Now())
-	return err
+// CountRecentCodesByIP 统计某 IP 自 since 以来申请的指定类型验证码数量
+func CountRecentCodesByIP(ipAddress, codeType string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM verification_codes WHERE ip_address = ? AND code_type = ? AND created_at >= ?`,
+		ipAddress, codeType, since,
+	).Scan(&count)
+	return count, err
 }
@@ -1,5 +1,5 @@
 import apiClient from './client'
-import type { AdminKey, CursorSession, ManagedUser } from '@/types'
+import type { AdminKey, AdminKeyDetails, AdminKeyStatus, CursorSession, ManagedUser } from '@/types'
 
 export interface CreateKeyPayload {
   key: string
@@ -20,7 +20,20 @@ export interface CreateCursorSessionPayload {
   extra_cookies?: Record<string, string>
 }
 
-export const listKeys = () => apiClient.get<{ total: number; keys: AdminKey[] }>('/admin/keys')
+export interface ListKeysParams {
+  q?: string                     // Owner username, token name or key prefix
+  status?: AdminKeyStatus
+  sort?: 'created_at' | 'usage_count' | 'last_used_at' | 'quota_utilization'
+  order?: 'asc' | 'desc'
+  limit?: number
+  offset?: number
+}
+
+export const listKeys = (params?: ListKeysParams) =>
+  apiClient.get<{ total: number; keys: AdminKey[] }>('/admin/keys', { params })
+
+export const getKeyDetails = (key: string, days?: number) =>
+  apiClient.get<AdminKeyDetails>(`/admin/keys/${key}/details`, { params: days ? { days } : undefined })
 
 export const addKey = (payload: CreateKeyPayload) => apiClient.post('/admin/keys', payload)
 
@@ -1,5 +1,5 @@
 import client from './client'
-import type { LoginRequest, RegisterRequest, SendCodeRequest, User } from '@/types'
+import type { LoginRequest, RegisterRequest, SendCodeRequest, TwoFactorSetup, TwoFactorStatus, User } from '@/types'
 
 export const authApi = {
   // 登录
@@ -20,6 +20,18 @@ export const authApi = {
     return response.data
   },
 
+  // 发送找回密码验证码（邮箱未注册时同样返回成功）
+  async forgotPassword(email: string) {
+    const response = await client.post('/auth/forgot-password', { email })
+    return response.data
+  },
+
+  // 使用邮箱验证码重置密码，成功后所有已登录设备需重新登录
+  async resetPassword(email: string, code: string, newPassword: string) {
+    const response = await client.post('/auth/reset-password', { email, code, new_password: newPassword })
+    return response.data
+  },
+
   // 获取当前用户信息
   async getCurrentUser(): Promise<{ user: User }> {
     const response = await client.get('/auth/me')
@@ -30,5 +42,53 @@ export const authApi = {
   async logout() {
     const response = await client.post('/auth/logout')
     return response.data
+  },
+
+  // 重新输入密码进入 sudo 模式（10 分钟内可执行危险的管理操作）
+  async enterSudo(password: string): Promise<{ elevated: boolean; elevated_until: string }> {
+    const response = await client.post('/auth/sudo', { password })
+    return response.data
+  },
+
+  // 提前退出 sudo 模式
+  async exitSudo() {
+    const response = await client.delete('/auth/sudo')
+    return response.data
+  },
+
+  // 登录第二步：提交密码登录返回的挑战与两步验证码（或备用码）
+  async loginTwoFactor(challenge: string, code: string) {
+    const response = await client.post('/auth/login/2fa', { challenge, code })
+    return response.data
+  },
+
+  // 查询两步验证状态
+  async getTwoFactorStatus(): Promise<TwoFactorStatus> {
+    const response = await client.get('/auth/2fa')
+    return response.data
+  },
+
+  // 生成 TOTP 密钥，返回供验证器扫码的 otpauth:// URI
+  async setupTwoFactor(): Promise<TwoFactorSetup> {
+    const response = await client.post('/auth/2fa/setup')
+    return response.data
+  },
+
+  // 提交验证码启用两步验证，返回备用码（仅显示一次）
+  async enableTwoFactor(code: string): Promise<{ enabled: boolean; backup_codes: string[] }> {
+    const response = await client.post('/auth/2fa/enable', { code })
+    return response.data
+  },
+
+  // 验证密码与验证码后关闭两步验证
+  async disableTwoFactor(password: string, code: string) {
+    const response = await client.post('/auth/2fa/disable', { password, code })
+    return response.data
+  },
+
+  // 重新生成备用码，旧备用码全部失效
+  async regenerateBackupCodes(code: string): Promise<{ backup_codes: string[] }> {
+    const response = await client.post('/auth/2fa/backup-codes', { code })
+    return response.data
   }
 }
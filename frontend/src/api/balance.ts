@@ -10,6 +10,15 @@ export interface UserBalance {
   updated_at: string
 }
 
+/** Recharge guidance returned with 402 insufficient balance errors (error.balance) */
+export interface BalanceGuidance {
+  balance: number
+  estimated_cost: number
+  minimum_top_up: number
+  currency: string
+  recharge_url?: string
+}
+
 export interface BalanceTransaction {
   id: number
   type: string
@@ -9,6 +9,7 @@
  */
 
 import apiClient from './client'
+import type { BalanceGuidance } from './balance'
 
 // ============================================================================
 // Type Definitions
@@ -21,6 +22,22 @@ export interface Conversation {
   title: string
   model: string
   system_prompt?: string
+  /** Spend ceiling in USD; further messages are refused once total_cost reaches it */
+  max_cost?: number
+  total_cost?: number
+  /** Deterministic mode: replies use temperature 0 and a fixed seed */
+  deterministic?: boolean
+  /** Seed used in deterministic mode; the server default when omitted */
+  seed?: number
+  /** Folder the conversation is filed in; null when unfiled */
+  folder_id?: number | null
+  tags?: string[]
+  /** System prompt template the conversation's prompt was taken from */
+  prompt_template_id?: number
+  /** Listed before unpinned conversations */
+  pinned?: boolean
+  /** Hidden from the conversation list unless include_archived is set */
+  archived?: boolean
   created_at: string
   updated_at: string
 }
@@ -32,10 +49,87 @@ export interface Message {
   role: 'user' | 'assistant' | 'system'
   content: string
   tokens: number
+  /** Prompt tokens billed for an assistant reply; 0 for replies saved before the split was recorded */
+  prompt_tokens?: number
+  /** Completion tokens billed for an assistant reply */
+  completion_tokens?: number
   cost: number
+  artifacts?: Artifact[]
+  /** Sampling seed the reply was generated with */
+  seed?: number
+  /** Reply was cut short by stop generation */
+  stopped?: boolean
+  /** Message this one follows in its branch; absent for the first message */
+  parent_message_id?: number
+  /** Files attached to a user message */
+  attachments?: ChatAttachment[]
+  /** The user's rating of an assistant reply */
+  feedback?: ChatFeedback
   created_at: string
 }
 
+/** Rating of an assistant reply */
+export type FeedbackRating = 'up' | 'down'
+
+/** The user's feedback on an assistant reply */
+export interface ChatFeedback {
+  rating: FeedbackRating
+  comment?: string
+  updated_at: string
+}
+
+/** File attached to a chat message; only its extracted text is kept and sent to the model */
+export interface ChatAttachment {
+  id: string
+  filename: string
+  content_type: string
+  /** Size of the uploaded file */
+  bytes: number
+  /** Characters of extracted text sent to the model */
+  chars: number
+  /** Extracted text was cut to the server's limit */
+  truncated: boolean
+  created_at: string
+}
+
+/** One path through a conversation's message tree, ending at leaf_message_id */
+export interface ConversationBranch {
+  leaf_message_id: number
+  /** First message of the branch that its alternatives don't share */
+  fork_message_id: number
+  preview: string
+  message_count: number
+  active: boolean
+  updated_at: string
+}
+
+/** A message matching a search, with the text around the first match */
+export interface ChatSearchSnippet {
+  message_id: number
+  role: 'user' | 'assistant'
+  snippet: string
+  created_at: string
+}
+
+/** A conversation with messages matching a search */
+export interface ChatSearchResult {
+  conversation_id: number
+  title: string
+  model: string
+  updated_at: string
+  match_count: number
+  /** Latest matching messages, at most 3 */
+  messages: ChatSearchSnippet[]
+}
+
+/** Code artifact extracted from an assistant reply (```lang artifact=name) */
+export interface Artifact {
+  index: number
+  language?: string
+  filename?: string
+  content: string
+}
+
 /** Token usage info */
 export interface TokenUsage {
   prompt: number
@@ -44,12 +138,21 @@ export interface TokenUsage {
 
 /** SSE stream event types */
 export interface StreamEvent {
-  type: 'start' | 'content' | 'done' | 'error'
+  type: 'start' | 'content' | 'artifact' | 'done' | 'error'
   message_id?: number
   delta?: string
   tokens?: TokenUsage
   cost?: number
   error?: string
+  /** Machine-readable error code, e.g. conversation_cost_limit */
+  code?: string
+  artifact?: Artifact
+  /** Set on done events when generation was stopped */
+  stopped?: boolean
+  /** Set on done events of a regenerated reply: the reply it replaced */
+  replaces_message_id?: number
+  /** Set on start events: token required to resume the stream after a dropped connection */
+  resume_token?: string
 }
 
 /** Model info for selection */
@@ -72,12 +175,24 @@ export interface CreateConversationRequest {
   title?: string
   model: string
   system_prompt?: string
+  /** Start from a system prompt template instead of system_prompt */
+  template_id?: number
+  max_cost?: number
+  deterministic?: boolean
+  seed?: number
 }
 
 export interface UpdateConversationRequest {
   title?: string
   model?: string
+  /** Empty removes the system prompt */
   system_prompt?: string
+  /** Replace the system prompt with a template's content */
+  template_id?: number
+  /** 0 removes the spend ceiling */
+  max_cost?: number
+  deterministic?: boolean
+  seed?: number
 }
 
 export interface SendMessageRequest {
@@ -91,6 +206,45 @@ export interface ConversationListResponse {
   limit: number
 }
 
+/** Narrows the conversation list; omit both fields for all conversations */
+export interface ConversationFilter {
+  /** A folder ID, or 'none' for unfiled conversations */
+  folder_id?: number | 'none'
+  tag?: string
+  /** Also list archived conversations */
+  include_archived?: boolean
+}
+
+export interface ChatFolder {
+  id: number
+  name: string
+  conversation_count: number
+  created_at: string
+  updated_at: string
+}
+
+export interface ChatTag {
+  tag: string
+  conversation_count: number
+}
+
+export interface PromptTemplate {
+  id: number
+  name: string
+  description: string
+  content: string
+  /** Published by an admin for every user; read-only */
+  global: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface PromptTemplateRequest {
+  name: string
+  description?: string
+  content: string
+}
+
 export interface MessageListResponse {
   messages: Message[]
   total: number
@@ -98,10 +252,53 @@ export interface MessageListResponse {
   limit: number
 }
 
+export interface ChatSearchResponse {
+  results: ChatSearchResult[]
+  total: number
+  page: number
+  limit: number
+}
+
 export interface ModelsResponse {
   models: ChatModel[]
 }
 
+/** Public read-only link to a conversation, fixed at the branch active when it was created */
+export interface ChatShare {
+  id: number
+  conversation_id: number
+  token_id: string
+  leaf_message_id: number
+  view_count: number
+  expires_at: string
+  revoked_at?: string
+  created_at: string
+}
+
+export interface CreateShareResponse {
+  share: ChatShare
+  /** Only returned when the link is created */
+  token: string
+  /** Frontend path of the public share page */
+  url: string
+}
+
+/** Message as shown on a public share page */
+export interface SharedMessage {
+  role: 'user' | 'assistant'
+  content: string
+  artifacts?: Artifact[]
+  created_at: string
+}
+
+export interface SharedConversation {
+  title: string
+  model: string
+  shared_at: string
+  expires_at: string
+  messages: SharedMessage[]
+}
+
 // ============================================================================
 // Conversation CRUD API
 // Requirements: 1.1
@@ -125,11 +322,12 @@ export async function createConversation(data: CreateConversationRequest): Promi
  */
 export async function getConversations(
   page: number = 1,
-  limit: number = 20
+  limit: number = 20,
+  filter: ConversationFilter = {}
 ): Promise<ConversationListResponse> {
   const response = await apiClient.get<{ success: boolean; data: ConversationListResponse }>(
     '/api/chat/conversations',
-    { params: { page, limit } }
+    { params: { page, limit, ...filter } }
   )
   return response.data.data
 }
@@ -190,6 +388,310 @@ export async function getMessages(
   return response.data.data
 }
 
+/**
+ * Stop the reply being generated for a user message; the stream then ends with a
+ * done event marked stopped and the partial reply is saved
+ * 停止生成，已生成的部分会被保存
+ */
+export async function stopGeneration(conversationId: number, messageId: number): Promise<void> {
+  await apiClient.post(`/api/chat/conversations/${conversationId}/messages/${messageId}/cancel`)
+}
+
+/**
+ * List the branches of a conversation
+ * 获取会话分支列表
+ */
+export async function getBranches(conversationId: number): Promise<ConversationBranch[]> {
+  const response = await apiClient.get<{ success: boolean; data: { branches: ConversationBranch[] } }>(
+    `/api/chat/conversations/${conversationId}/branches`
+  )
+  return response.data.data.branches
+}
+
+/**
+ * Switch to the branch through a message and get its messages
+ * 切换当前分支
+ */
+export async function switchBranch(conversationId: number, messageId: number): Promise<Message[]> {
+  const response = await apiClient.put<{ success: boolean; data: { leaf_message_id: number; messages: Message[] } }>(
+    `/api/chat/conversations/${conversationId}/branch`,
+    { message_id: messageId }
+  )
+  return response.data.data.messages
+}
+
+/**
+ * Download the conversation's active branch as Markdown or JSON
+ * 导出会话
+ */
+export async function exportConversation(
+  conversationId: number,
+  format: 'markdown' | 'json' = 'markdown'
+): Promise<void> {
+  const response = await apiClient.get<Blob>(
+    `/api/chat/conversations/${conversationId}/export`,
+    { params: { format }, responseType: 'blob' }
+  )
+  const url = window.URL.createObjectURL(response.data)
+  const link = document.createElement('a')
+  link.href = url
+  link.download = `conversation-${conversationId}.${format === 'json' ? 'json' : 'md'}`
+  document.body.appendChild(link)
+  link.click()
+  document.body.removeChild(link)
+  window.URL.revokeObjectURL(url)
+}
+
+/**
+ * Search the current user's messages; every word (at least 2 characters) must match
+ * 全文搜索消息
+ */
+export async function searchConversations(
+  q: string,
+  page: number = 1,
+  limit: number = 20
+): Promise<ChatSearchResponse> {
+  const response = await apiClient.get<{ success: boolean; data: ChatSearchResponse }>(
+    '/api/chat/search',
+    { params: { q, page, limit } }
+  )
+  return response.data.data
+}
+
+/**
+ * List the user's folders
+ * 获取文件夹列表
+ */
+export async function getFolders(): Promise<ChatFolder[]> {
+  const response = await apiClient.get<{ success: boolean; data: { folders: ChatFolder[] } }>(
+    '/api/chat/folders'
+  )
+  return response.data.data.folders
+}
+
+/**
+ * Create a folder
+ * 创建文件夹
+ */
+export async function createFolder(name: string): Promise<ChatFolder> {
+  const response = await apiClient.post<{ success: boolean; data: ChatFolder }>(
+    '/api/chat/folders',
+    { name }
+  )
+  return response.data.data
+}
+
+/**
+ * Rename a folder
+ * 重命名文件夹
+ */
+export async function renameFolder(folderId: number, name: string): Promise<void> {
+  await apiClient.put(`/api/chat/folders/${folderId}`, { name })
+}
+
+/**
+ * Delete a folder; its conversations become unfiled
+ * 删除文件夹（会话移出，不删除）
+ */
+export async function deleteFolder(folderId: number): Promise<void> {
+  await apiClient.delete(`/api/chat/folders/${folderId}`)
+}
+
+/**
+ * Move a conversation into a folder, or out of any folder with null
+ * 移动会话到文件夹
+ */
+export async function moveConversation(conversationId: number, folderId: number | null): Promise<void> {
+  await apiClient.put(`/api/chat/conversations/${conversationId}/folder`, { folder_id: folderId })
+}
+
+/**
+ * Pin a conversation to the top of the list, or unpin it
+ * 置顶/取消置顶会话
+ */
+export async function pinConversation(conversationId: number, pinned: boolean): Promise<void> {
+  await apiClient.put(`/api/chat/conversations/${conversationId}/pin`, { pinned })
+}
+
+/**
+ * Archive a conversation, hiding it from the list, or restore it
+ * 归档/取消归档会话
+ */
+export async function archiveConversation(conversationId: number, archived: boolean): Promise<void> {
+  await apiClient.put(`/api/chat/conversations/${conversationId}/archive`, { archived })
+}
+
+/**
+ * Replace a conversation's tags; returns the tags as stored
+ * 设置会话标签
+ */
+export async function setConversationTags(conversationId: number, tags: string[]): Promise<string[]> {
+  const response = await apiClient.put<{ success: boolean; data: { tags: string[] } }>(
+    `/api/chat/conversations/${conversationId}/tags`,
+    { tags }
+  )
+  return response.data.data.tags
+}
+
+/**
+ * List the user's system prompt templates and the global ones
+ * 获取系统提示词模板
+ */
+export async function getPromptTemplates(): Promise<PromptTemplate[]> {
+  const response = await apiClient.get<{ success: boolean; data: { templates: PromptTemplate[] } }>(
+    '/api/chat/templates'
+  )
+  return response.data.data.templates
+}
+
+/**
+ * Save a system prompt template
+ * 创建系统提示词模板
+ */
+export async function createPromptTemplate(data: PromptTemplateRequest): Promise<PromptTemplate> {
+  const response = await apiClient.post<{ success: boolean; data: PromptTemplate }>(
+    '/api/chat/templates',
+    data
+  )
+  return response.data.data
+}
+
+/**
+ * Replace one of the user's templates
+ * 更新系统提示词模板
+ */
+export async function updatePromptTemplate(templateId: number, data: PromptTemplateRequest): Promise<PromptTemplate> {
+  const response = await apiClient.put<{ success: boolean; data: PromptTemplate }>(
+    `/api/chat/templates/${templateId}`,
+    data
+  )
+  return response.data.data
+}
+
+/**
+ * Delete one of the user's templates
+ * 删除系统提示词模板
+ */
+export async function deletePromptTemplate(templateId: number): Promise<void> {
+  await apiClient.delete(`/api/chat/templates/${templateId}`)
+}
+
+/**
+ * Generate a new title from the conversation's first exchange; returns the saved title
+ * 重新生成会话标题
+ */
+export async function regenerateTitle(conversationId: number): Promise<string> {
+  const response = await apiClient.post<{ success: boolean; data: { title: string } }>(
+    `/api/chat/conversations/${conversationId}/title`
+  )
+  return response.data.data.title
+}
+
+/**
+ * Upload a txt, md or pdf file and extract its text; pass the returned ID in attachment_ids
+ * when sending a message
+ * 上传消息附件
+ */
+export async function uploadAttachment(file: File): Promise<ChatAttachment> {
+  const form = new FormData()
+  form.append('file', file)
+  const response = await apiClient.post<{ success: boolean; data: ChatAttachment }>(
+    '/api/chat/attachments',
+    form,
+    // The client defaults to JSON; axios fills in the multipart boundary
+    { headers: { 'Content-Type': 'multipart/form-data' } }
+  )
+  return response.data.data
+}
+
+/**
+ * Delete an uploaded attachment that has not been sent
+ * 删除尚未发送的附件
+ */
+export async function deleteAttachment(attachmentId: string): Promise<void> {
+  await apiClient.delete(`/api/chat/attachments/${attachmentId}`)
+}
+
+/**
+ * Rate an assistant reply, replacing an earlier rating
+ * 对回复点赞或点踩
+ */
+export async function submitFeedback(
+  messageId: number,
+  rating: FeedbackRating,
+  comment: string = ''
+): Promise<ChatFeedback> {
+  const response = await apiClient.post<{ success: boolean; data: ChatFeedback }>(
+    `/api/chat/messages/${messageId}/feedback`,
+    { rating, comment }
+  )
+  return response.data.data
+}
+
+/**
+ * Withdraw the rating of an assistant reply
+ * 撤回反馈
+ */
+export async function deleteFeedback(messageId: number): Promise<void> {
+  await apiClient.delete(`/api/chat/messages/${messageId}/feedback`)
+}
+
+/**
+ * List the tags in use on the user's conversations
+ * 获取标签列表
+ */
+export async function getTags(): Promise<ChatTag[]> {
+  const response = await apiClient.get<{ success: boolean; data: { tags: ChatTag[] } }>(
+    '/api/chat/tags'
+  )
+  return response.data.data.tags
+}
+
+/**
+ * Create a public read-only link to the conversation's active branch
+ * 生成会话分享链接
+ */
+export async function shareConversation(
+  conversationId: number,
+  expiresInDays?: number
+): Promise<CreateShareResponse> {
+  const response = await apiClient.post<{ success: boolean; data: CreateShareResponse }>(
+    `/api/chat/conversations/${conversationId}/share`,
+    expiresInDays ? { expires_in_days: expiresInDays } : {}
+  )
+  return response.data.data
+}
+
+/**
+ * List the conversation's share links
+ * 获取会话的分享链接
+ */
+export async function getShares(conversationId: number): Promise<ChatShare[]> {
+  const response = await apiClient.get<{ success: boolean; data: { shares: ChatShare[] } }>(
+    `/api/chat/conversations/${conversationId}/shares`
+  )
+  return response.data.data.shares
+}
+
+/**
+ * Revoke a share link
+ * 吊销分享链接
+ */
+export async function revokeShare(shareId: number): Promise<void> {
+  await apiClient.delete(`/api/chat/shares/${shareId}`)
+}
+
+/**
+ * Load a shared conversation; no login required
+ * 通过分享链接获取会话
+ */
+export async function getSharedConversation(token: string): Promise<SharedConversation> {
+  const response = await apiClient.get<{ success: boolean; data: SharedConversation }>(
+    `/api/shared/${encodeURIComponent(token)}`
+  )
+  return response.data.data
+}
+
 // ============================================================================
 // SSE Streaming Client
 // Requirements: 2.2
@@ -198,8 +700,42 @@ export async function getMessages(
 export interface StreamCallbacks {
   onStart?: (messageId: number) => void
   onContent?: (delta: string) => void
-  onDone?: (tokens: TokenUsage, cost: number) => void
-  onError?: (error: string) => void
+  onArtifact?: (artifact: Artifact) => void
+  /** messageId is the saved assistant message (absent when nothing was saved) */
+  onDone?: (tokens: TokenUsage, cost: number, stopped?: boolean, messageId?: number) => void
+  onError?: (error: string, code?: string) => void
+}
+
+/**
+ * Dispatch a stream event (shared by the SSE and WebSocket transports)
+ * 将流式事件分发给回调
+ */
+function dispatchStreamEvent(event: StreamEvent, callbacks: StreamCallbacks): void {
+  switch (event.type) {
+    case 'start':
+      if (event.message_id) {
+        callbacks.onStart?.(event.message_id)
+      }
+      break
+    case 'content':
+      if (event.delta) {
+        callbacks.onContent?.(event.delta)
+      }
+      break
+    case 'artifact':
+      if (event.artifact) {
+        callbacks.onArtifact?.(event.artifact)
+      }
+      break
+    case 'done':
+      if (event.tokens) {
+        callbacks.onDone?.(event.tokens, event.cost || 0, event.stopped, event.message_id)
+      }
+      break
+    case 'error':
+      callbacks.onError?.(event.error || 'Unknown error', event.code)
+      break
+  }
 }
 
 /**
@@ -210,29 +746,219 @@ export interface StreamCallbacks {
  * @param content - Message content
  * @param model - Optional model to use (overrides conversation model)
  * @param callbacks - Event callbacks for streaming
+ * @param attachmentIds - Optional uploaded attachments to send with the message
  * @returns AbortController to cancel the stream
  */
 export function sendMessageStream(
   conversationId: number,
   content: string,
   model: string | undefined,
-  callbacks: StreamCallbacks
+  callbacks: StreamCallbacks,
+  attachmentIds: string[] = []
 ): AbortController {
-  const controller = new AbortController()
+  // Build request body with optional model and attachments
+  const requestBody: { content: string; model?: string; attachment_ids?: string[] } = { content }
+  if (model) {
+    requestBody.model = model
+  }
+  if (attachmentIds.length > 0) {
+    requestBody.attachment_ids = attachmentIds
+  }
   
-  // Build the URL with credentials
-  const baseUrl = import.meta.env.DEV
-    ? ''
-    : import.meta.env.VITE_API_BASE_URL || 'http://localhost:8002'
-  const url = `${baseUrl}/api/chat/conversations/${conversationId}/messages`
+  console.log('[Chat API] Sending message, content:', content, 'model:', model)
   
-  // Build request body with optional model
+  return postEventStream(conversationId, `/api/chat/conversations/${conversationId}/messages`, requestBody, callbacks)
+}
+
+/**
+ * Regenerate the latest assistant reply and receive the new one via SSE; the done event
+ * carries replaces_message_id once the old reply has been replaced
+ * 重新生成最后一条 AI 回复
+ *
+ * @param model - Optional model for the new reply (defaults to the conversation model)
+ * @returns AbortController to cancel the stream
+ */
+export function regenerateMessageStream(
+  conversationId: number,
+  messageId: number,
+  model: string | undefined,
+  callbacks: StreamCallbacks
+): AbortController {
+  const requestBody: { model?: string } = {}
+  if (model) {
+    requestBody.model = model
+  }
+  return postEventStream(
+    conversationId,
+    `/api/chat/conversations/${conversationId}/messages/${messageId}/regenerate`,
+    requestBody,
+    callbacks
+  )
+}
+
+/**
+ * Edit a user message, which starts a new branch from it, and receive the reply via SSE
+ * 编辑消息并从该处开启新分支
+ *
+ * @param model - Optional model for the reply (defaults to the conversation model)
+ * @returns AbortController to cancel the stream
+ */
+export function editMessageStream(
+  conversationId: number,
+  messageId: number,
+  content: string,
+  model: string | undefined,
+  callbacks: StreamCallbacks
+): AbortController {
   const requestBody: { content: string; model?: string } = { content }
   if (model) {
     requestBody.model = model
   }
+  return postEventStream(
+    conversationId,
+    `/api/chat/conversations/${conversationId}/messages/${messageId}/edit`,
+    requestBody,
+    callbacks
+  )
+}
+
+/** Reconnection attempts after a reply's connection drops without progress */
+const STREAM_RESUME_ATTEMPTS = 3
+
+/** Position in a reply's event stream, for resuming it after a dropped connection */
+interface StreamCursor {
+  /** User message the reply answers, from the start event */
+  messageId: number | null
+  /** Signed token for resuming the reply, from the start event */
+  resumeToken: string | null
+  /** ID of the last event received */
+  lastEventId: number
+  /** The final done or error event was received */
+  finished: boolean
+}
+
+/**
+ * Dispatch the SSE events of a response body, advancing the cursor
+ * 读取 SSE 响应并分发事件
+ */
+async function readEventStream(response: Response, callbacks: StreamCallbacks, cursor: StreamCursor): Promise<void> {
+  const reader = response.body?.getReader()
+  if (!reader) {
+    cursor.finished = true
+    callbacks.onError?.('Failed to read response stream')
+    return
+  }
   
-  console.log('[Chat API] Sending message to:', url, 'content:', content, 'model:', model)
+  const decoder = new TextDecoder()
+  let buffer = ''
+  let eventId: number | null = null
+  
+  while (true) {
+    const { done, value } = await reader.read()
+    
+    if (done) break
+    
+    buffer += decoder.decode(value, { stream: true })
+    
+    // Process complete SSE events
+    const lines = buffer.split('\n')
+    buffer = lines.pop() || '' // Keep incomplete line in buffer
+    
+    for (const line of lines) {
+      if (line.startsWith('id: ')) {
+        eventId = Number(line.slice(4).trim())
+      } else if (line.startsWith('data: ')) {
+        const data = line.slice(6).trim()
+        if (!data || data === '[DONE]') continue
+        
+        try {
+          const event: StreamEvent = JSON.parse(data)
+          
+          console.log('[Chat API] SSE event:', event.type, event)
+          
+          if (event.type === 'start' && event.message_id) {
+            cursor.messageId = event.message_id
+            cursor.resumeToken = event.resume_token ?? null
+          } else if (event.type === 'done' || event.type === 'error') {
+            cursor.finished = true
+          }
+          if (eventId !== null) {
+            cursor.lastEventId = eventId
+            eventId = null
+          }
+          dispatchStreamEvent(event, callbacks)
+        } catch (e) {
+          console.error('Failed to parse SSE event:', e, data)
+        }
+      }
+    }
+  }
+}
+
+/**
+ * Reconnect to a reply whose connection dropped and receive the events after the last one
+ * seen (Last-Event-ID). The reply keeps generating on the server meanwhile
+ * 断线后按 Last-Event-ID 续传回复
+ */
+async function resumeEventStream(
+  baseUrl: string,
+  conversationId: number,
+  callbacks: StreamCallbacks,
+  cursor: StreamCursor,
+  signal: AbortSignal
+): Promise<void> {
+  for (let attempt = 1; attempt <= STREAM_RESUME_ATTEMPTS && cursor.messageId !== null && cursor.resumeToken !== null; attempt++) {
+    await new Promise(resolve => setTimeout(resolve, 1000 * 2 ** (attempt - 1)))
+    if (signal.aborted) return
+    
+    console.log('[Chat API] Resuming stream after event', cursor.lastEventId)
+    const progress = cursor.lastEventId
+    try {
+      const response = await fetch(
+        `${baseUrl}/api/chat/conversations/${conversationId}/messages/${cursor.messageId}/stream`,
+        {
+          headers: {
+            'Accept': 'text/event-stream',
+            'Last-Event-ID': String(cursor.lastEventId),
+            'X-Resume-Token': cursor.resumeToken ?? ''
+          },
+          credentials: 'include',
+          signal
+        }
+      )
+      // 404/410: the reply finished a while ago or is no longer buffered
+      if (!response.ok) break
+      await readEventStream(response, callbacks, cursor)
+    } catch (error: any) {
+      if (error.name === 'AbortError') return
+    }
+    if (cursor.finished) return
+    if (cursor.lastEventId > progress) {
+      attempt = 0 // Dropped again after making progress: start over
+    }
+  }
+  callbacks.onError?.('连接已中断，回复可能已保存，请刷新对话查看', 'stream_interrupted')
+}
+
+/**
+ * POST a JSON body and dispatch the SSE events of the response, resuming the stream if
+ * the connection drops before the reply finishes
+ * 发送请求并分发 SSE 响应中的事件
+ */
+function postEventStream(
+  conversationId: number,
+  path: string,
+  requestBody: object,
+  callbacks: StreamCallbacks
+): AbortController {
+  const controller = new AbortController()
+  const cursor: StreamCursor = { messageId: null, resumeToken: null, lastEventId: 0, finished: false }
+  
+  // Build the URL with credentials
+  const baseUrl = import.meta.env.DEV
+    ? ''
+    : import.meta.env.VITE_API_BASE_URL || 'http://localhost:8002'
+  const url = `${baseUrl}${path}`
   
   // Start the fetch request
   fetch(url, {
@@ -253,11 +979,13 @@ export function sendMessageStream(
         // Requirements: 2.5, 6.2 - Display error message and balance warning
         let errorMessage = 'Failed to send message'
         let errorType = 'UNKNOWN_ERROR'
+        let balanceGuidance: BalanceGuidance | undefined
         
         try {
           const errorData = await response.json()
           errorMessage = errorData.error?.message || errorMessage
           errorType = errorData.error?.type || errorType
+          balanceGuidance = errorData.error?.balance
         } catch {
           // Ignore JSON parse errors
         }
@@ -290,7 +1018,9 @@ export function sendMessageStream(
               break
             case 402:
               // Requirements: 6.2 - Insufficient balance warning
-              errorMessage = '余额不足，请充值后再试'
+              errorMessage = balanceGuidance
+                ? `余额不足（当前 $${balanceGuidance.balance.toFixed(4)}，本次预计花费 $${balanceGuidance.estimated_cost.toFixed(4)}），请至少充值 $${balanceGuidance.minimum_top_up.toFixed(2)} 后再试`
+                : '余额不足，请充值后再试'
               errorType = 'INSUFFICIENT_BALANCE'
               break
             case 403:
@@ -325,62 +1055,15 @@ export function sendMessageStream(
         return
       }
       
-      // Read the SSE stream
-      const reader = response.body?.getReader()
-      if (!reader) {
-        callbacks.onError?.('Failed to read response stream')
-        return
+      // Read the SSE stream; a connection dropped after the reply started is resumed
+      try {
+        await readEventStream(response, callbacks, cursor)
+      } catch (error: any) {
+        if (error.name === 'AbortError' || cursor.messageId === null) throw error
+        console.warn('[Chat API] Stream connection lost:', error)
       }
-      
-      const decoder = new TextDecoder()
-      let buffer = ''
-      
-      while (true) {
-        const { done, value } = await reader.read()
-        
-        if (done) break
-        
-        buffer += decoder.decode(value, { stream: true })
-        
-        // Process complete SSE events
-        const lines = buffer.split('\n')
-        buffer = lines.pop() || '' // Keep incomplete line in buffer
-        
-        for (const line of lines) {
-          if (line.startsWith('data: ')) {
-            const data = line.slice(6).trim()
-            if (!data || data === '[DONE]') continue
-            
-            try {
-              const event: StreamEvent = JSON.parse(data)
-              
-              console.log('[Chat API] SSE event:', event.type, event)
-              
-              switch (event.type) {
-                case 'start':
-                  if (event.message_id) {
-                    callbacks.onStart?.(event.message_id)
-                  }
-                  break
-                case 'content':
-                  if (event.delta) {
-                    callbacks.onContent?.(event.delta)
-                  }
-                  break
-                case 'done':
-                  if (event.tokens) {
-                    callbacks.onDone?.(event.tokens, event.cost || 0)
-                  }
-                  break
-                case 'error':
-                  callbacks.onError?.(event.error || 'Unknown error')
-                  break
-              }
-            } catch (e) {
-              console.error('Failed to parse SSE event:', e, data)
-            }
-          }
-        }
+      if (!cursor.finished) {
+        await resumeEventStream(baseUrl, conversationId, callbacks, cursor, controller.signal)
       }
     })
     .catch((error) => {
@@ -394,6 +1077,118 @@ export function sendMessageStream(
   return controller
 }
 
+// ============================================================================
+// WebSocket Streaming Client
+// ============================================================================
+
+let chatSocket: WebSocket | null = null
+let chatSocketOpening: Promise<WebSocket> | null = null
+// Callbacks of the reply currently streaming over the socket (one at a time)
+let activeSocketCallbacks: StreamCallbacks | null = null
+
+/**
+ * Open (or reuse) the chat WebSocket; the session cookie authenticates it
+ * 打开或复用聊天 WebSocket 连接
+ */
+function openChatSocket(): Promise<WebSocket> {
+  if (chatSocket && chatSocket.readyState === WebSocket.OPEN) {
+    return Promise.resolve(chatSocket)
+  }
+  if (chatSocketOpening) {
+    return chatSocketOpening
+  }
+
+  const baseUrl = import.meta.env.DEV
+    ? window.location.origin
+    : import.meta.env.VITE_API_BASE_URL || window.location.origin
+  const url = `${baseUrl.replace(/^http/, 'ws')}/api/chat/ws`
+
+  chatSocketOpening = new Promise<WebSocket>((resolve, reject) => {
+    const socket = new WebSocket(url)
+    socket.onopen = () => {
+      chatSocket = socket
+      chatSocketOpening = null
+      resolve(socket)
+    }
+    socket.onerror = () => {
+      if (chatSocketOpening) {
+        chatSocketOpening = null
+        reject(new Error('Failed to open chat WebSocket'))
+      }
+    }
+    socket.onclose = () => {
+      if (chatSocket !== socket) return
+      chatSocket = null
+      // The connection dropped mid-reply
+      if (activeSocketCallbacks) {
+        const callbacks = activeSocketCallbacks
+        activeSocketCallbacks = null
+        callbacks.onError?.('连接已断开，请重试', 'connection_lost')
+      }
+    }
+    socket.onmessage = (message) => {
+      let event: StreamEvent
+      try {
+        event = JSON.parse(message.data)
+      } catch (e) {
+        console.error('Failed to parse WebSocket event:', e, message.data)
+        return
+      }
+      const callbacks = activeSocketCallbacks
+      if (!callbacks) return
+      if (event.type === 'done' || event.type === 'error') {
+        activeSocketCallbacks = null
+      }
+      dispatchStreamEvent(event, callbacks)
+    }
+  })
+  return chatSocketOpening
+}
+
+/**
+ * Send a message and receive the streaming response over WebSocket
+ * 通过 WebSocket 发送消息并接收流式响应；无法建立连接时回退到 SSE
+ *
+ * @returns AbortController whose abort() sends a cancel frame to stop generation
+ */
+export function sendMessageSocket(
+  conversationId: number,
+  content: string,
+  model: string | undefined,
+  callbacks: StreamCallbacks,
+  attachmentIds: string[] = []
+): AbortController {
+  const controller = new AbortController()
+
+  openChatSocket()
+    .then((socket) => {
+      if (controller.signal.aborted) return
+      activeSocketCallbacks = callbacks
+      controller.signal.addEventListener('abort', () => {
+        if (activeSocketCallbacks !== callbacks) return
+        activeSocketCallbacks = null
+        if (socket.readyState === WebSocket.OPEN) {
+          socket.send(JSON.stringify({ type: 'cancel' }))
+        }
+      })
+      socket.send(JSON.stringify({
+        type: 'send',
+        conversation_id: conversationId,
+        content,
+        model: model || undefined,
+        attachment_ids: attachmentIds.length > 0 ? attachmentIds : undefined
+      }))
+    })
+    .catch((error) => {
+      if (controller.signal.aborted) return
+      console.warn('[Chat API] WebSocket unavailable, falling back to SSE:', error)
+      const fallback = sendMessageStream(conversationId, content, model, callbacks, attachmentIds)
+      controller.signal.addEventListener('abort', () => fallback.abort())
+    })
+
+  return controller
+}
+
 // ============================================================================
 // Models API
 // Requirements: 3.1
@@ -421,10 +1216,40 @@ export const chatApi = {
   getConversation,
   updateConversation,
   deleteConversation,
+  exportConversation,
+  searchConversations,
+  shareConversation,
+  getShares,
+  revokeShare,
+  getSharedConversation,
+  getFolders,
+  createFolder,
+  renameFolder,
+  deleteFolder,
+  moveConversation,
+  pinConversation,
+  archiveConversation,
+  setConversationTags,
+  getTags,
+  regenerateTitle,
+  uploadAttachment,
+  deleteAttachment,
+  getPromptTemplates,
+  createPromptTemplate,
+  updatePromptTemplate,
+  deletePromptTemplate,
   
   // Messages
   getMessages,
+  submitFeedback,
+  deleteFeedback,
+  stopGeneration,
+  getBranches,
+  switchBranch,
   sendMessageStream,
+  sendMessageSocket,
+  regenerateMessageStream,
+  editMessageStream,
   
   // Models
   getChatModels
@@ -11,6 +11,30 @@ const client = axios.create({
   }
 })
 
+// Dangerous admin operations answer 403 sudo_required until the admin re-enters their
+// password; the layout registers a prompt so the request can be retried once after it
+let sudoPrompt: (() => Promise<boolean>) | null = null
+
+export function setSudoPrompt(prompt: (() => Promise<boolean>) | null) {
+  sudoPrompt = prompt
+}
+
+// In JWT auth mode access tokens expire after a few minutes and requests answer
+// 401 token_expired; requests failing together share one refresh before retrying
+let tokenRefresh: Promise<void> | null = null
+
+function refreshTokens(): Promise<void> {
+  if (!tokenRefresh) {
+    tokenRefresh = client
+      .post('/auth/token/refresh')
+      .then(() => undefined)
+      .finally(() => {
+        tokenRefresh = null
+      })
+  }
+  return tokenRefresh
+}
+
 // Request interceptor to add cache-busting timestamp
 client.interceptors.request.use(
   (config) => {
@@ -56,6 +80,9 @@ client.interceptors.response.use(
     // Handle different HTTP status codes
     switch (status) {
       case 401:
+        if (response.data?.error?.code === 'token_expired' && !config._tokenRefreshed) {
+          return refreshTokens().then(() => client({ ...config, _tokenRefreshed: true }))
+        }
         // 所有 401 错误都不自动重定向，让调用方或路由守卫处理
         console.warn('Unauthorized access:', config.url)
         return Promise.reject({
@@ -65,6 +92,33 @@ client.interceptors.response.use(
         })
       
       case 403:
+        if (response.data?.error?.code === 'sudo_required') {
+          if (sudoPrompt && !config._sudoRetried) {
+            return sudoPrompt().then((confirmed) => {
+              if (!confirmed) {
+                return Promise.reject({
+                  type: 'SUDO_REQUIRED',
+                  message: '已取消身份验证',
+                  originalError: error
+                })
+              }
+              return client({ ...config, _sudoRetried: true })
+            })
+          }
+          return Promise.reject({
+            type: 'SUDO_REQUIRED',
+            message: response.data.error.message,
+            originalError: error
+          })
+        }
+        if (response.data?.error?.code === 'two_factor_setup_required') {
+          // Admin account must enable 2FA in personal settings before using the admin panel
+          return Promise.reject({
+            type: 'TWO_FACTOR_SETUP_REQUIRED',
+            message: response.data.error.message,
+            originalError: error
+          })
+        }
         // Forbidden - no permission
         console.error('Forbidden: No permission to access this resource')
         return Promise.reject({
@@ -90,6 +144,7 @@ client.interceptors.response.use(
         return Promise.reject({
           type: 'INSUFFICIENT_BALANCE',
           message: response.data?.error?.message || '余额不足，请充值后再试',
+          balance: response.data?.error?.balance,
           originalError: error
         })
       
@@ -105,6 +105,7 @@ export interface ExchangeGameCoinsResponse {
   exchange_record: ExchangeRecord
   new_game_balance: number
   new_account_balance: number
+  replayed?: boolean // true when an earlier request with the same Idempotency-Key was returned
 }
 
 // Exchange History Response (paginated)
@@ -185,8 +186,10 @@ export const migrateLocalStorage = (data: MigrateLocalStorageRequest) =>
  * POST /api/game/exchange
  * Requirements: 2.1, 2.2, 2.3, 2.6, 2.7
  */
-export const exchangeGameCoins = (amount: number) =>
-  apiClient.post<ExchangeGameCoinsResponse>('/api/game/exchange', { amount })
+export const exchangeGameCoins = (amount: number, idempotencyKey?: string) =>
+  apiClient.post<ExchangeGameCoinsResponse>('/api/game/exchange', { amount }, {
+    headers: idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : undefined
+  })
 
 // Purchase Game Coins Response
 export interface PurchaseGameCoinsResponse {
@@ -194,14 +197,17 @@ export interface PurchaseGameCoinsResponse {
   purchase_record: ExchangeRecord
   new_game_balance: number
   new_account_balance: number
+  replayed?: boolean // true when an earlier request with the same Idempotency-Key was returned
 }
 
 /**
  * Purchase game coins with account balance (USD)
  * POST /api/game/purchase
  */
-export const purchaseGameCoins = (amount: number) =>
-  apiClient.post<PurchaseGameCoinsResponse>('/api/game/purchase', { amount })
+export const purchaseGameCoins = (amount: number, idempotencyKey?: string) =>
+  apiClient.post<PurchaseGameCoinsResponse>('/api/game/purchase', { amount }, {
+    headers: idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : undefined
+  })
 
 /**
  * Get paginated exchange history for current user
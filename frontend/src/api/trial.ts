@@ -0,0 +1,50 @@
+import client from './client'
+
+// 匿名试用：访客无需注册即可获取绑定 IP 的临时密钥，在 Playground 试用免费模型
+
+export interface TrialInfo {
+  enabled: boolean
+  ttl_minutes?: number
+  requests_per_minute?: number
+  max_requests?: number
+  models?: string[]
+}
+
+export interface TrialKey {
+  key: string
+  expires_at: string
+  requests_per_minute: number
+  max_requests: number
+  models: string[]
+}
+
+// 注册时随请求提交，用于统计试用转化
+export const TRIAL_KEY_STORAGE = 'curry_trial_key'
+
+export const trialApi = {
+  // 获取试用开关与限制
+  async getInfo(): Promise<TrialInfo> {
+    const response = await client.get('/api/trial')
+    return response.data
+  },
+
+  // 申请临时密钥（需通过人机验证）
+  async issueKey(turnstileToken: string): Promise<TrialKey> {
+    const response = await client.post('/api/trial/key', { turnstile_token: turnstileToken })
+    return response.data
+  }
+}
+
+export function getStoredTrialKey(): TrialKey | null {
+  const raw = localStorage.getItem(TRIAL_KEY_STORAGE)
+  if (!raw) return null
+  try {
+    return JSON.parse(raw) as TrialKey
+  } catch {
+    return null
+  }
+}
+
+export function storeTrialKey(key: TrialKey) {
+  localStorage.setItem(TRIAL_KEY_STORAGE, JSON.stringify(key))
+}
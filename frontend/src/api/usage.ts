@@ -58,3 +58,59 @@ export async function getUsageTrends(params?: {
   const response = await client.get('/api/usage/trends', { params })
   return response.data
 }
+
+/**
+ * Failed requests grouped by one dimension (status code, model or API key)
+ */
+export interface UsageErrorGroup {
+  count: number
+  last_seen: string
+  status_code?: number
+  error_type?: string
+  model?: string
+  masked_key?: string
+  token_name?: string
+}
+
+/**
+ * A recurring failure: status code, model and error message
+ */
+export interface UsageErrorReason {
+  status_code: number
+  error_type: string
+  model: string
+  message: string
+  count: number
+  last_seen: string
+}
+
+/**
+ * Response from usage errors endpoint
+ */
+export interface UsageErrorsResponse {
+  start_date: string
+  end_date: string
+  total_requests: number
+  failed_requests: number
+  error_rate: number
+  by_status: UsageErrorGroup[]
+  by_error_type: { error_type: string; count: number }[]
+  by_model: UsageErrorGroup[]
+  by_key: UsageErrorGroup[]
+  top_reasons: UsageErrorReason[]
+}
+
+/**
+ * Get a summary of the authenticated user's failed requests
+ * @param params Date range (defaults to the last 7 days), model and number of reasons
+ * @returns Failed requests grouped by status, error type, model and key
+ */
+export async function getUsageErrors(params?: {
+  start_date?: string
+  end_date?: string
+  model?: string
+  limit?: number
+}): Promise<UsageErrorsResponse> {
+  const response = await client.get('/api/usage/errors', { params })
+  return response.data
+}
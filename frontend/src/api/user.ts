@@ -7,3 +7,26 @@ export const updateUsername = (username: string) =>
 
 export const updatePassword = (oldPassword: string, newPassword: string) =>
   apiClient.put('/profile/password', { old_password: oldPassword, new_password: newPassword })
+
+export interface LoginSession {
+  id: string
+  browser: string
+  os: string
+  device_type: string
+  location: string
+  ip_address: string
+  user_agent: string
+  created_at: string
+  expires_at: string
+  current: boolean
+}
+
+export const getSessions = () =>
+  apiClient.get<{ sessions: LoginSession[] }>('/profile/sessions')
+
+export const revokeSession = (id: string) =>
+  apiClient.delete(`/profile/sessions/${id}`)
+
+// 退出所有其他设备，includeCurrent 为 true 时同时退出当前设备
+export const revokeAllSessions = (includeCurrent = false) =>
+  apiClient.delete<{ revoked: number }>('/profile/sessions', { params: { include_current: includeCurrent } })
@@ -10,6 +10,16 @@ const router = createRouter({
       component: () => import('@/views/Login.vue'),
       meta: { requiresGuest: true }
     },
+    {
+      path: '/playground',
+      name: 'Playground',
+      component: () => import('@/views/Playground.vue')
+    },
+    {
+      path: '/share/:token',
+      name: 'SharedConversation',
+      component: () => import('@/views/SharedConversation.vue')
+    },
     {
       path: '/',
       component: () => import('@/layouts/MainLayout.vue'),
@@ -13,13 +13,26 @@ import { ref, computed } from 'vue'
 import {
   chatApi,
   type Conversation,
+  type ConversationFilter,
   type Message,
+  type ChatAttachment,
+  type ChatFeedback,
+  type FeedbackRating,
+  type ConversationBranch,
   type ChatModel,
   type TokenUsage,
   type CreateConversationRequest,
   type UpdateConversationRequest
 } from '@/api/chat'
 
+// Replies stream over WebSocket by default; set VITE_CHAT_TRANSPORT=sse to force SSE
+const sendMessageWithTransport = import.meta.env.VITE_CHAT_TRANSPORT === 'sse'
+  ? chatApi.sendMessageStream
+  : chatApi.sendMessageSocket
+
+// Title of a conversation the server has not named yet
+const DEFAULT_CONVERSATION_TITLE = '新对话'
+
 // ============================================================================
 // State Interface
 // ============================================================================
@@ -66,6 +79,8 @@ export const useChatStore = defineStore('chat', () => {
   const conversationsTotal = ref(0)
   const conversationsPage = ref(1)
   const conversationsLoading = ref(false)
+  /** Folder or tag the conversation list is narrowed to */
+  const conversationFilter = ref<ConversationFilter>({})
   
   // ---------------------------------------------------------------------------
   // Message State
@@ -74,6 +89,8 @@ export const useChatStore = defineStore('chat', () => {
   const messagesTotal = ref(0)
   const messagesPage = ref(1)
   const messagesLoading = ref(false)
+  /** Branches of the current conversation, from editing messages and regenerating replies */
+  const branches = ref<ConversationBranch[]>([])
   
   // ---------------------------------------------------------------------------
   // Streaming State
@@ -131,7 +148,7 @@ export const useChatStore = defineStore('chat', () => {
     error.value = null
     
     try {
-      const response = await chatApi.getConversations(page, limit)
+      const response = await chatApi.getConversations(page, limit, conversationFilter.value)
       
       // Ensure conversations is always an array (defensive check for null/undefined)
       const loadedConversations = response.conversations || []
@@ -158,6 +175,188 @@ export const useChatStore = defineStore('chat', () => {
     }
   }
   
+  /**
+   * Narrow the conversation list to a folder or tag and reload it
+   * 按文件夹或标签筛选会话列表
+   */
+  async function setConversationFilter(filter: ConversationFilter): Promise<void> {
+    conversationFilter.value = filter
+    await loadConversations(1)
+  }
+  
+  /**
+   * Whether a conversation still belongs in the filtered list
+   */
+  function matchesConversationFilter(conv: Conversation): boolean {
+    const filter = conversationFilter.value
+    if (filter.folder_id === 'none' && conv.folder_id) return false
+    if (typeof filter.folder_id === 'number' && conv.folder_id !== filter.folder_id) return false
+    if (filter.tag && !(conv.tags ?? []).some(t => t.toLowerCase() === filter.tag!.toLowerCase())) return false
+    if (conv.archived && !filter.include_archived) return false
+    return true
+  }
+  
+  /**
+   * Apply a folder or tag change to the loaded conversations, dropping the conversation
+   * from the list when it no longer matches the filter
+   */
+  function patchConversation(id: number, patch: Partial<Conversation>): void {
+    const index = conversations.value.findIndex(c => c.id === id)
+    if (index !== -1) {
+      const updated = { ...conversations.value[index]!, ...patch }
+      if (matchesConversationFilter(updated)) {
+        conversations.value[index] = updated
+      } else {
+        conversations.value.splice(index, 1)
+        conversationsTotal.value--
+      }
+    }
+    if (currentConversation.value?.id === id) {
+      currentConversation.value = { ...currentConversation.value, ...patch }
+    }
+  }
+  
+  /**
+   * Move a conversation into a folder, or out of any folder with null
+   * 移动会话到文件夹
+   */
+  async function moveConversation(id: number, folderId: number | null): Promise<boolean> {
+    error.value = null
+    
+    try {
+      await chatApi.moveConversation(id, folderId)
+      patchConversation(id, { folder_id: folderId })
+      return true
+    } catch (err: unknown) {
+      const errorMessage = err instanceof Error ? err.message : 'Failed to move conversation'
+      error.value = errorMessage
+      console.error('Failed to move conversation:', err)
+      return false
+    }
+  }
+  
+  /**
+   * Pin a conversation to the top of the list, or unpin it
+   * 置顶/取消置顶会话
+   */
+  async function pinConversation(id: number, pinned: boolean): Promise<boolean> {
+    error.value = null
+    
+    try {
+      await chatApi.pinConversation(id, pinned)
+      patchConversation(id, { pinned })
+      // Keep the server's order: pinned first, then most recently updated
+      conversations.value.sort((a, b) =>
+        Number(!!b.pinned) - Number(!!a.pinned) || b.updated_at.localeCompare(a.updated_at)
+      )
+      return true
+    } catch (err: unknown) {
+      const errorMessage = err instanceof Error ? err.message : 'Failed to pin conversation'
+      error.value = errorMessage
+      console.error('Failed to pin conversation:', err)
+      return false
+    }
+  }
+  
+  /**
+   * Archive a conversation, which also unpins it, or restore it
+   * 归档/取消归档会话
+   */
+  async function archiveConversation(id: number, archived: boolean): Promise<boolean> {
+    error.value = null
+    
+    try {
+      await chatApi.archiveConversation(id, archived)
+      patchConversation(id, archived ? { archived, pinned: false } : { archived })
+      return true
+    } catch (err: unknown) {
+      const errorMessage = err instanceof Error ? err.message : 'Failed to archive conversation'
+      error.value = errorMessage
+      console.error('Failed to archive conversation:', err)
+      return false
+    }
+  }
+  
+  /**
+   * Replace a conversation's tags
+   * 设置会话标签
+   */
+  async function setConversationTags(id: number, tags: string[]): Promise<boolean> {
+    error.value = null
+    
+    try {
+      const saved = await chatApi.setConversationTags(id, tags)
+      patchConversation(id, { tags: saved })
+      return true
+    } catch (err: unknown) {
+      const errorMessage = err instanceof Error ? err.message : 'Failed to update tags'
+      error.value = errorMessage
+      console.error('Failed to update tags:', err)
+      return false
+    }
+  }
+  
+  /**
+   * Rate an assistant reply, or withdraw the rating when rating is null
+   * 对回复点赞/点踩，rating 为 null 时撤回
+   */
+  async function rateMessage(messageId: number, rating: FeedbackRating | null, comment: string = ''): Promise<boolean> {
+    try {
+      let feedback: ChatFeedback | undefined
+      if (rating) {
+        feedback = await chatApi.submitFeedback(messageId, rating, comment)
+      } else {
+        await chatApi.deleteFeedback(messageId)
+      }
+      const msg = messages.value.find((m) => m.id === messageId)
+      if (msg) {
+        msg.feedback = feedback
+      }
+      return true
+    } catch (err: unknown) {
+      console.error('Failed to save feedback:', err)
+      return false
+    }
+  }
+  
+  /**
+   * Generate a new title from the conversation's first exchange
+   * 重新生成会话标题
+   */
+  async function regenerateTitle(id: number): Promise<boolean> {
+    error.value = null
+    
+    try {
+      const title = await chatApi.regenerateTitle(id)
+      patchConversation(id, { title })
+      return true
+    } catch (err: unknown) {
+      const errorMessage = err instanceof Error ? err.message : 'Failed to generate title'
+      error.value = errorMessage
+      console.error('Failed to generate title:', err)
+      return false
+    }
+  }
+  
+  /**
+   * Pick up the title the server generates in the background after the first exchange
+   * 首轮对话后获取服务端自动生成的标题
+   */
+  function refreshGeneratedTitle(id: number, attempts: number = 3): void {
+    setTimeout(async () => {
+      try {
+        const conv = await chatApi.getConversation(id)
+        if (conv.title !== DEFAULT_CONVERSATION_TITLE) {
+          patchConversation(id, { title: conv.title })
+        } else if (attempts > 1) {
+          refreshGeneratedTitle(id, attempts - 1)
+        }
+      } catch (err: unknown) {
+        console.error('Failed to refresh conversation title:', err)
+      }
+    }, 3000)
+  }
+  
   /**
    * Load more conversations (next page)
    * 加载更多会话
@@ -177,7 +376,7 @@ export const useChatStore = defineStore('chat', () => {
     
     try {
       const conversation = await chatApi.createConversation({
-        title: data?.title || '新对话',
+        title: data?.title || DEFAULT_CONVERSATION_TITLE,
         model: data?.model || selectedModel.value,
         system_prompt: data?.system_prompt
       })
@@ -190,6 +389,7 @@ export const useChatStore = defineStore('chat', () => {
       currentConversation.value = conversation
       messages.value = []
       messagesTotal.value = 0
+      branches.value = []
       
       return conversation
     } catch (err: unknown) {
@@ -223,6 +423,7 @@ export const useChatStore = defineStore('chat', () => {
       
       // Load messages for this conversation
       await loadMessages(id)
+      await loadBranches()
     } catch (err: unknown) {
       const errorMessage = err instanceof Error ? err.message : 'Failed to load conversation'
       error.value = errorMessage
@@ -244,15 +445,19 @@ export const useChatStore = defineStore('chat', () => {
       
       // Only update if we got a valid response
       if (updated) {
+        // An empty system prompt is omitted from the response
+        const changes = { ...updated, system_prompt: updated.system_prompt ?? '', prompt_template_id: updated.prompt_template_id }
+        
         // Update in list
+        // Tags are not part of the response, keep the loaded ones
         const index = conversations.value.findIndex(c => c.id === id)
         if (index !== -1) {
-          conversations.value[index] = updated
+          conversations.value[index] = { ...conversations.value[index], ...changes }
         }
         
         // Update current if it's the same
         if (currentConversation.value?.id === id) {
-          currentConversation.value = updated
+          currentConversation.value = { ...currentConversation.value, ...changes }
         }
       }
       
@@ -289,6 +494,7 @@ export const useChatStore = defineStore('chat', () => {
         currentConversation.value = null
         messages.value = []
         messagesTotal.value = 0
+        branches.value = []
       }
       
       return true
@@ -355,7 +561,7 @@ export const useChatStore = defineStore('chat', () => {
    * 发送消息并处理流式响应
    * Requirements: 2.5 - Error handling with retry support
    */
-  async function sendMessage(content: string): Promise<boolean> {
+  async function sendMessage(content: string, attachments: ChatAttachment[] = []): Promise<boolean> {
     if (!currentConversation.value || isStreaming.value || !content.trim()) {
       return false
     }
@@ -377,6 +583,7 @@ export const useChatStore = defineStore('chat', () => {
       content: content.trim(),
       tokens: 0,
       cost: 0,
+      attachments: attachments.length > 0 ? attachments : undefined,
       created_at: new Date().toISOString()
     }
     messages.value.push(userMessage)
@@ -386,7 +593,7 @@ export const useChatStore = defineStore('chat', () => {
     
     try {
       // Start streaming with selected model
-      streamController.value = chatApi.sendMessageStream(
+      streamController.value = sendMessageWithTransport(
         currentConversation.value.id,
         content.trim(),
         selectedModel.value, // Pass the selected model
@@ -400,72 +607,18 @@ export const useChatStore = defineStore('chat', () => {
           onContent: (delta) => {
             streamingContent.value += delta
           },
-          onDone: (tokens: TokenUsage, cost: number) => {
-            console.log('[Chat] Stream done, tokens:', tokens, 'cost:', cost)
-            // Add assistant message to the list
-            const assistantMessage: Message = {
-              id: streamingMessageId.value || Date.now(),
-              conversation_id: currentConversation.value!.id,
-              role: 'assistant',
-              content: streamingContent.value,
-              tokens: tokens.prompt + tokens.completion,
-              cost: cost,
-              created_at: new Date().toISOString()
-            }
-            messages.value.push(assistantMessage)
-            messagesTotal.value++
-            console.log('[Chat] Assistant message added to list:', assistantMessage)
-            
-            // Update conversation's updated_at and move to top
-            if (currentConversation.value) {
-              currentConversation.value.updated_at = new Date().toISOString()
-              
-              // Move to top of list
-              const index = conversations.value.findIndex(c => c.id === currentConversation.value!.id)
-              if (index > 0) {
-                const [conv] = conversations.value.splice(index, 1)
-                if (conv) {
-                  conversations.value.unshift(conv)
-                }
-              }
-            }
-            
-            // Reset streaming state and clear last failed message
-            isStreaming.value = false
-            streamingContent.value = ''
-            streamingMessageId.value = null
-            streamController.value = null
-            lastFailedMessage.value = null
+          onDone: (tokens: TokenUsage, cost: number, stopped?: boolean, messageId?: number) => {
+            console.log('[Chat] Stream done, tokens:', tokens, 'cost:', cost, 'stopped:', stopped)
+            finishReply(tokens, cost, stopped, messageId)
           },
-          onError: (errorMsg) => {
+          onError: (errorMsg, code) => {
             console.error('[Chat] Stream error:', errorMsg)
             // Requirements: 2.5, 2.6, 10.1-10.5 - Store error for display and retry
-            error.value = errorMsg
-            // Detect error type from message for provider-specific errors
-            if (errorMsg.includes('余额不足') || errorMsg.includes('balance')) {
-              errorType.value = 'INSUFFICIENT_BALANCE'
-            } else if (errorMsg.includes('超时') || errorMsg.includes('timeout')) {
-              errorType.value = 'SERVICE_TIMEOUT'
-            } else if (errorMsg.includes('服务提供商未配置') || errorMsg.includes('PROVIDER_NOT_AVAILABLE')) {
-              errorType.value = 'PROVIDER_NOT_AVAILABLE'
-            } else if (errorMsg.includes('API 密钥无效') || errorMsg.includes('INVALID_API_KEY')) {
-              errorType.value = 'INVALID_API_KEY'
-            } else if (errorMsg.includes('请求过于频繁') || errorMsg.includes('RATE_LIMITED')) {
-              errorType.value = 'RATE_LIMITED'
-            } else if (errorMsg.includes('消息内容过长') || errorMsg.includes('CONTEXT_TOO_LONG')) {
-              errorType.value = 'CONTEXT_TOO_LONG'
-            } else if (errorMsg.includes('不可用') || errorMsg.includes('unavailable') || errorMsg.includes('PROVIDER_ERROR')) {
-              errorType.value = 'PROVIDER_ERROR'
-            } else {
-              errorType.value = 'UNKNOWN_ERROR'
-            }
-            isStreaming.value = false
-            streamingContent.value = ''
-            streamingMessageId.value = null
-            streamController.value = null
+            failReply(errorMsg, code)
             // Keep lastFailedMessage for retry
           }
-        }
+        },
+        attachments.map((attachment) => attachment.id)
       )
       
       return true
@@ -482,6 +635,267 @@ export const useChatStore = defineStore('chat', () => {
     }
   }
   
+  /**
+   * Add the finished reply to the list and reset the streaming state
+   * 回复完成：加入消息列表并重置流式状态
+   */
+  function finishReply(tokens: TokenUsage, cost: number, stopped?: boolean, messageId?: number): void {
+    // Add assistant message to the list
+    const assistantMessage: Message = {
+      id: messageId || Date.now(),
+      conversation_id: currentConversation.value!.id,
+      role: 'assistant',
+      content: streamingContent.value,
+      tokens: tokens.prompt + tokens.completion,
+      prompt_tokens: tokens.prompt,
+      completion_tokens: tokens.completion,
+      cost: cost,
+      stopped,
+      created_at: new Date().toISOString()
+    }
+    messages.value.push(assistantMessage)
+    messagesTotal.value++
+    console.log('[Chat] Assistant message added to list:', assistantMessage)
+    
+    // Update conversation's updated_at and move to top
+    if (currentConversation.value) {
+      currentConversation.value.updated_at = new Date().toISOString()
+      
+      // The server names the conversation after its first reply
+      if (currentConversation.value.title === DEFAULT_CONVERSATION_TITLE) {
+        refreshGeneratedTitle(currentConversation.value.id)
+      }
+      
+      // Move to top of list
+      const index = conversations.value.findIndex(c => c.id === currentConversation.value!.id)
+      if (index > 0) {
+        const [conv] = conversations.value.splice(index, 1)
+        if (conv) {
+          conversations.value.unshift(conv)
+        }
+      }
+    }
+    
+    // Reset streaming state and clear last failed message
+    isStreaming.value = false
+    streamingContent.value = ''
+    streamingMessageId.value = null
+    streamController.value = null
+    lastFailedMessage.value = null
+  }
+  
+  /**
+   * Record a stream error for display and reset the streaming state
+   * 记录流式错误并重置流式状态
+   */
+  function failReply(errorMsg: string, code?: string): void {
+    error.value = errorMsg
+    // Detect error type from message for provider-specific errors
+    if (code === 'conversation_cost_limit') {
+      errorType.value = 'CONVERSATION_COST_LIMIT'
+    } else if (errorMsg.includes('余额不足') || errorMsg.includes('balance')) {
+      errorType.value = 'INSUFFICIENT_BALANCE'
+    } else if (errorMsg.includes('超时') || errorMsg.includes('timeout')) {
+      errorType.value = 'SERVICE_TIMEOUT'
+    } else if (errorMsg.includes('服务提供商未配置') || errorMsg.includes('PROVIDER_NOT_AVAILABLE')) {
+      errorType.value = 'PROVIDER_NOT_AVAILABLE'
+    } else if (errorMsg.includes('API 密钥无效') || errorMsg.includes('INVALID_API_KEY')) {
+      errorType.value = 'INVALID_API_KEY'
+    } else if (errorMsg.includes('请求过于频繁') || errorMsg.includes('RATE_LIMITED')) {
+      errorType.value = 'RATE_LIMITED'
+    } else if (errorMsg.includes('消息内容过长') || errorMsg.includes('CONTEXT_TOO_LONG')) {
+      errorType.value = 'CONTEXT_TOO_LONG'
+    } else if (errorMsg.includes('不可用') || errorMsg.includes('unavailable') || errorMsg.includes('PROVIDER_ERROR')) {
+      errorType.value = 'PROVIDER_ERROR'
+    } else {
+      errorType.value = 'UNKNOWN_ERROR'
+    }
+    isStreaming.value = false
+    streamingContent.value = ''
+    streamingMessageId.value = null
+    streamController.value = null
+  }
+  
+  /**
+   * Replace the latest assistant reply with a newly generated one (streamed via SSE),
+   * using the selected model. The old reply is restored if regeneration fails
+   * 重新生成最后一条 AI 回复
+   */
+  async function regenerateMessage(messageId: number): Promise<boolean> {
+    const last = messages.value[messages.value.length - 1]
+    if (!currentConversation.value || isStreaming.value || !last || last.id !== messageId || last.role !== 'assistant') {
+      return false
+    }
+    
+    error.value = null
+    errorType.value = null
+    isStreaming.value = true
+    streamingContent.value = ''
+    streamingMessageId.value = null
+    
+    // Hide the old reply while the new one streams
+    const previous = messages.value.pop()!
+    messagesTotal.value--
+    const restore = () => {
+      if (!messages.value.some(m => m.id === previous.id)) {
+        messages.value.push(previous)
+        messagesTotal.value++
+      }
+    }
+    
+    streamController.value = chatApi.regenerateMessageStream(
+      currentConversation.value.id,
+      messageId,
+      selectedModel.value,
+      {
+        onStart: (userMessageId) => {
+          streamingMessageId.value = userMessageId
+        },
+        onContent: (delta) => {
+          streamingContent.value += delta
+        },
+        onDone: (tokens: TokenUsage, cost: number, stopped?: boolean, newMessageId?: number) => {
+          if (!newMessageId) {
+            // Nothing was saved (stopped before any content), the old reply stays
+            restore()
+            isStreaming.value = false
+            streamingContent.value = ''
+            streamingMessageId.value = null
+            streamController.value = null
+            return
+          }
+          finishReply(tokens, cost, stopped, newMessageId)
+          loadBranches()
+        },
+        onError: (errorMsg, code) => {
+          console.error('[Chat] Regenerate error:', errorMsg)
+          restore()
+          failReply(errorMsg, code)
+        }
+      }
+    )
+    return true
+  }
+  
+  /**
+   * Edit a user message: the edited copy starts a new branch from the messages before it,
+   * and the reply streams in via SSE. The original branch stays available in branches
+   * 编辑消息并从该处开启新分支
+   */
+  async function editMessage(messageId: number, content: string): Promise<boolean> {
+    const index = messages.value.findIndex(m => m.id === messageId)
+    const original = messages.value[index]
+    if (!currentConversation.value || isStreaming.value || !content.trim() || !original || original.role !== 'user') {
+      return false
+    }
+    const conversationId = currentConversation.value.id
+    
+    error.value = null
+    errorType.value = null
+    isStreaming.value = true
+    streamingContent.value = ''
+    streamingMessageId.value = null
+    
+    // The new branch replaces the edited message and everything after it
+    const editedMessage: Message = {
+      ...original,
+      id: -(Date.now()),
+      content: content.trim(),
+      parent_message_id: index > 0 ? messages.value[index - 1]!.id : undefined,
+      created_at: new Date().toISOString()
+    }
+    messagesTotal.value -= messages.value.length - index - 1
+    messages.value.splice(index, messages.value.length - index, editedMessage)
+    
+    streamController.value = chatApi.editMessageStream(
+      conversationId,
+      messageId,
+      content.trim(),
+      selectedModel.value,
+      {
+        onStart: (userMessageId) => {
+          streamingMessageId.value = userMessageId
+          editedMessage.id = userMessageId
+          messages.value.splice(messages.value.length - 1, 1, { ...editedMessage })
+        },
+        onContent: (delta) => {
+          streamingContent.value += delta
+        },
+        onDone: (tokens: TokenUsage, cost: number, stopped?: boolean, replyId?: number) => {
+          finishReply(tokens, cost, stopped, replyId)
+          loadBranches()
+        },
+        onError: (errorMsg, code) => {
+          console.error('[Chat] Edit error:', errorMsg)
+          // The server may or may not have saved the edit; show whichever branch is active
+          loadMessages(conversationId).finally(() => failReply(errorMsg, code))
+        }
+      }
+    )
+    return true
+  }
+  
+  /**
+   * Load the branches of the current conversation
+   * 加载当前会话的分支列表
+   */
+  async function loadBranches(): Promise<void> {
+    const conversationId = currentConversation.value?.id
+    if (!conversationId) {
+      branches.value = []
+      return
+    }
+    try {
+      const list = await chatApi.getBranches(conversationId)
+      if (currentConversation.value?.id === conversationId) {
+        branches.value = list
+      }
+    } catch (err) {
+      console.warn('[Chat] Failed to load branches:', err)
+    }
+  }
+  
+  /**
+   * Switch the current conversation to the branch through a message
+   * 切换到指定分支
+   */
+  async function switchBranch(messageId: number): Promise<boolean> {
+    if (!currentConversation.value || isStreaming.value) {
+      return false
+    }
+    try {
+      const branchMessages = await chatApi.switchBranch(currentConversation.value.id, messageId)
+      messages.value = branchMessages
+      messagesTotal.value = branchMessages.length
+      messagesPage.value = 1
+      await loadBranches()
+      return true
+    } catch (err: unknown) {
+      error.value = err instanceof Error ? err.message : 'Failed to switch branch'
+      errorType.value = 'UNKNOWN_ERROR'
+      return false
+    }
+  }
+  
+  /**
+   * Stop generation on the server, which saves the partial reply and ends the stream
+   * with a stopped done event; falls back to cancelling locally before the reply starts
+   * 停止生成：服务端保存已生成的部分并结束流
+   */
+  async function stopGeneration(): Promise<void> {
+    const conversationId = currentConversation.value?.id
+    const messageId = streamingMessageId.value
+    if (conversationId && messageId) {
+      try {
+        await chatApi.stopGeneration(conversationId, messageId)
+        return
+      } catch (err) {
+        console.warn('[Chat] Stop generation failed, cancelling locally:', err)
+      }
+    }
+    cancelStream()
+  }
+  
   /**
    * Cancel ongoing stream
    * 取消正在进行的流
@@ -647,10 +1061,12 @@ export const useChatStore = defineStore('chat', () => {
     currentConversation.value = null
     conversationsTotal.value = 0
     conversationsPage.value = 1
+    conversationFilter.value = {}
     
     messages.value = []
     messagesTotal.value = 0
     messagesPage.value = 1
+    branches.value = []
     
     error.value = null
   }
@@ -666,12 +1082,14 @@ export const useChatStore = defineStore('chat', () => {
     conversationsTotal,
     conversationsPage,
     conversationsLoading,
+    conversationFilter,
     
     // Message state
     messages,
     messagesTotal,
     messagesPage,
     messagesLoading,
+    branches,
     
     // Streaming state
     isStreaming,
@@ -704,13 +1122,25 @@ export const useChatStore = defineStore('chat', () => {
     selectConversation,
     updateConversation,
     deleteConversation,
+    setConversationFilter,
+    pinConversation,
+    archiveConversation,
+    moveConversation,
+    setConversationTags,
+    regenerateTitle,
     
     // Message actions
     loadMessages,
     loadMoreMessages,
     sendMessage,
+    rateMessage,
     cancelStream,
+    stopGeneration,
     retryLastMessage,
+    regenerateMessage,
+    editMessage,
+    loadBranches,
+    switchBranch,
     
     // Model actions
     loadModels,
@@ -723,4 +1153,4 @@ export const useChatStore = defineStore('chat', () => {
 })
 
 // Re-export types for convenience
-export type { Conversation, Message, ChatModel, TokenUsage } from '@/api/chat'
+export type { Conversation, Message, ConversationBranch, ChatModel, TokenUsage } from '@/api/chat'
@@ -12,6 +12,18 @@ export interface LoginRequest {
   password: string
 }
 
+export interface TwoFactorStatus {
+  enabled: boolean
+  setup_required: boolean        // Admin account must enable 2FA before using the admin panel
+  enabled_at?: string
+  backup_codes_remaining?: number
+}
+
+export interface TwoFactorSetup {
+  secret: string
+  otpauth_uri: string
+}
+
 export interface RegisterRequest {
   username: string
   email: string
@@ -19,6 +31,7 @@ export interface RegisterRequest {
   code: string
   turnstile_token?: string
   referral_code?: string  // Optional referral code for bonus
+  trial_key?: string      // Anonymous trial key used before signing up, for conversion tracking
 }
 
 export interface SendCodeRequest {
@@ -30,6 +43,8 @@ export interface ApiResponse<T = any> {
   data?: T
   user?: User
   session_id?: string
+  two_factor_required?: boolean  // Password accepted, submit a 2FA code with the challenge
+  challenge?: string
   error?: {
     code: string
     message: string
@@ -58,6 +73,56 @@ export interface AdminKey {
   quota_used: number             // Quota used in USD
   expires_at?: string | null     // Expiration time, null means never expires
   allowed_models?: string[]      // Allowed models, empty means all models
+  // Key directory enrichment
+  status?: AdminKeyStatus
+  quota_utilization?: number     // Percent of quota_limit used, absent when unlimited
+}
+
+export type AdminKeyStatus = 'active' | 'disabled' | 'expired' | 'quota_exhausted'
+
+export interface KeyModelUsage {
+  model: string
+  requests: number
+  prompt_tokens: number
+  completion_tokens: number
+  total_tokens: number
+}
+
+export interface AdminKeyDetails {
+  key: AdminKey
+  status: AdminKeyStatus
+  usage: {
+    requests: number
+    errors: number
+    prompt_tokens: number
+    completion_tokens: number
+    total_tokens: number
+    avg_duration_ms: number
+    first_seen_at?: string
+    last_seen_at?: string
+    by_model: KeyModelUsage[]
+  }
+  quota: {
+    limit?: number | null
+    used: number
+    utilization?: number | null
+    remaining?: number
+    owner_balance?: number
+    owner_balance_status?: string
+  }
+  restrictions: {
+    is_active: boolean
+    expires_at?: string | null
+    allowed_models?: string[] | null
+    signing_enabled: boolean
+    priority_trusted: boolean
+    tags?: string[] | null
+    /** Endpoints the key may call; empty means every scope except admin-read */
+    scopes?: string[] | null
+    /** CIDR ranges the key may be used from; empty means any address */
+    allowed_ips?: string[] | null
+    stream_flush: { interval_ms: number; bytes: number }
+  }
 }
 
 export interface ManagedUser {
@@ -19,6 +19,7 @@ export default defineConfig({
         target: 'http://localhost:8002',
         changeOrigin: true,
         secure: false,
+        ws: true, // 聊天 WebSocket（/api/chat/ws）
       },
       '/v1': {
         target: 'http://localhost:8002',
@@ -4,6 +4,7 @@ import (
 	"Curry2API-go/database"
 	"Curry2API-go/middleware"
 	"Curry2API-go/models"
+	"Curry2API-go/services"
 	"net/http"
 	"strconv"
 	"strings"
@@ -22,6 +23,63 @@ func maskKey(key string) string {
 	return key[:4] + strings.Repeat("*", keyLen-8) + key[keyLen-4:]
 }
 
+// needsTwoFactorSetup 判断管理员是否需要先启用两步验证（测试中可替换）
+var needsTwoFactorSetup = middleware.NeedsTwoFactorSetup
+
+// adminTwoFactorGate 要求管理员启用两步验证时，未启用的管理员需先在个人设置中完成设置；
+// 不放行时已写入错误响应
+func adminTwoFactorGate(c *gin.Context, userID int64, role string) bool {
+	needsSetup, err := needsTwoFactorSetup(userID, role)
+	if err != nil {
+		logrus.WithError(err).Error("AdminAuth: failed to check two-factor status")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"two_factor_check_failed",
+		))
+		c.Abort()
+		return false
+	}
+	if needsSetup {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+			"管理员账号必须先启用两步验证，请在个人设置中完成设置",
+			"permission_error",
+			"two_factor_setup_required",
+		))
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// requireAdminRole 要求当前用户为管理员：AdminAuth 放行所有登录用户，修改系统配置或账号数据的接口需自行检查；
+// 不满足时已写入 403 响应
+func requireAdminRole(c *gin.Context) bool {
+	role, roleExists := c.Get("role")
+	if roleName, _ := role.(string); !roleExists || roleName != "admin" {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+			"Admin privileges required",
+			"authorization_error",
+			"admin_required",
+		))
+		return false
+	}
+	return true
+}
+
+// adminReadKeyAuth 以 admin-read 密钥所属用户的身份放行只读管理接口，
+// 与会话和 JWT 登录一样受管理员两步验证要求约束；不放行时已写入错误响应
+func adminReadKeyAuth(c *gin.Context, token string, user *database.User) bool {
+	if !adminTwoFactorGate(c, user.ID, user.Role) {
+		return false
+	}
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("api_key", token)
+	return true
+}
+
 // AdminAuth 管理员认证中间件（支持会话认证和 Bearer token）
 func AdminAuth() gin.HandlerFunc {
 	km := middleware.GetKeyManager()
@@ -39,6 +97,9 @@ func AdminAuth() gin.HandlerFunc {
 			if err == nil {
 				// 任何登录用户都可以访问（不再限制管理员）
 				logrus.Debugf("AdminAuth: User role=%s", session.Role)
+				if !adminTwoFactorGate(c, session.UserID, session.Role) {
+					return
+				}
 				c.Set("user_id", session.UserID)
 				c.Set("username", session.Username)
 				c.Set("role", session.Role)
@@ -48,6 +109,15 @@ func AdminAuth() gin.HandlerFunc {
 			}
 		}
 
+		// JWT 模式: 访问令牌（cookie 或 Bearer 头）
+		if middleware.AuthenticateTokenRequest(c) {
+			if !adminTwoFactorGate(c, c.GetInt64("user_id"), c.GetString("role")) {
+				return
+			}
+			c.Next()
+			return
+		}
+
 		// 方式2: 尝试 Bearer token 认证
 		authHeader := c.GetHeader("Authorization")
 		if strings.HasPrefix(authHeader, "Bearer ") {
@@ -59,6 +129,24 @@ func AdminAuth() gin.HandlerFunc {
 				c.Next()
 				return
 			}
+
+			// 方式3: 带 admin-read 作用域的用户密钥，以所属用户身份调用只读接口
+			if c.Request.Method == http.MethodGet && km.IsValidKey(token) && km.HasScope(token, middleware.ScopeAdminRead) &&
+				km.CheckTokenExpiration(token) == nil && km.IsIPAllowed(token, c.ClientIP()) {
+				// 与 API 请求一样，启用签名的密钥须通过签名校验
+				if !km.CheckRequestSignature(c, token) {
+					return
+				}
+				if userID := km.GetUserIDForKey(token); userID != nil {
+					if user, err := database.GetUserByID(*userID); err == nil && user != nil {
+						if !adminReadKeyAuth(c, token, user) {
+							return
+						}
+						c.Next()
+						return
+					}
+				}
+			}
 		}
 
 		// 两种认证方式都失败
@@ -72,11 +160,17 @@ func AdminAuth() gin.HandlerFunc {
 	}
 }
 
-// ListKeysHandler 列出当前用户的密钥
+// ListKeysHandler 列出当前用户的密钥（支持搜索、状态筛选、排序与分页）
 // @Summary 列出当前用户的API密钥
 // @Tags Admin
 // @Security BearerAuth
 // @Produce json
+// @Param q query string false "按所属用户名、令牌名称或密钥前缀搜索"
+// @Param status query string false "active / disabled / expired / quota_exhausted"
+// @Param sort query string false "created_at（默认）/ usage_count / last_used_at / quota_utilization"
+// @Param order query string false "asc / desc（默认）"
+// @Param limit query int false "每页数量，不传返回全部"
+// @Param offset query int false "偏移量"
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/keys [get]
 func ListKeysHandler(c *gin.Context) {
@@ -114,9 +208,14 @@ func ListKeysHandler(c *gin.Context) {
 		logrus.Debugf("ListKeysHandler: Regular user %d, returning %d keys", userIDInt, len(keys))
 	}
 
+	total, entries, ok := buildKeyDirectory(c, keys)
+	if !ok {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"total": len(keys),
-		"keys":  keys,
+		"total": total,
+		"keys":  entries,
 	})
 }
 
@@ -127,6 +226,7 @@ type AddKeyRequest struct {
 	QuotaLimit    *float64  `json:"quota_limit,omitempty"`    // Quota limit in USD, nil means unlimited
 	ExpiresAt     *string   `json:"expires_at,omitempty"`     // ISO date string, nil means never expires
 	AllowedModels []string  `json:"allowed_models,omitempty"` // Allowed models, nil/empty means all models
+	PriorityTrusted bool    `json:"priority_trusted,omitempty"` // Whether X-Priority header is honored
 }
 
 // AddKeyHandler 添加新密钥
@@ -183,6 +283,7 @@ func AddKeyHandler(c *gin.Context) {
 		QuotaLimit:    req.QuotaLimit,
 		ExpiresAt:     expiresAt,
 		AllowedModels: req.AllowedModels,
+		PriorityTrusted: req.PriorityTrusted,
 	}
 
 	userIDInt := userID.(int64)
@@ -222,6 +323,7 @@ func AddKeyHandler(c *gin.Context) {
 		"quota_limit":    req.QuotaLimit,
 		"expires_at":     req.ExpiresAt,
 		"allowed_models": req.AllowedModels,
+		"priority_trusted": req.PriorityTrusted,
 	})
 }
 
@@ -378,6 +480,364 @@ func UpdateKeyNameHandler(c *gin.Context) {
 	})
 }
 
+// UpdateKeyPriorityRequest 更新密钥优先级信任请求
+type UpdateKeyPriorityRequest struct {
+	Trusted *bool `json:"trusted" binding:"required"`
+}
+
+// UpdateKeyPriorityHandler 设置密钥是否允许使用 X-Priority 请求头
+// @Summary 设置API密钥优先级信任
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key path string true "要更新的密钥"
+// @Param request body UpdateKeyPriorityRequest true "是否信任"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/keys/{key}/priority [put]
+func UpdateKeyPriorityHandler(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	key := c.Param("key")
+
+	var req UpdateKeyPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse := models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	km := middleware.GetKeyManager()
+	if err := km.SetPriorityTrusted(key, *req.Trusted); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			errorResponse := models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			)
+			c.JSON(statusCode, errorResponse)
+			return
+		}
+		errorResponse := models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_priority_failed",
+		)
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "密钥优先级设置已更新",
+		"key":              maskKey(key),
+		"priority_trusted": *req.Trusted,
+	})
+}
+
+// UpdateKeyTagsRequest 更新密钥标签请求
+type UpdateKeyTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// UpdateKeyTagsHandler 设置密钥标签，供路由规则按标签匹配
+// @Summary 设置API密钥标签
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key path string true "要更新的密钥"
+// @Param request body UpdateKeyTagsRequest true "标签列表，空列表表示清除"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/keys/{key}/tags [put]
+func UpdateKeyTagsHandler(c *gin.Context) {
+	key := c.Param("key")
+
+	var req UpdateKeyTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse := models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	// 去除空白与重复标签
+	tags := make([]string, 0, len(req.Tags))
+	seen := make(map[string]bool, len(req.Tags))
+	for _, tag := range req.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > 64 {
+			errorResponse := models.NewErrorResponse(
+				"标签长度不能超过64个字符",
+				"validation_error",
+				"invalid_tag",
+			)
+			c.JSON(http.StatusBadRequest, errorResponse)
+			return
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	km := middleware.GetKeyManager()
+	if err := km.SetKeyTags(key, tags); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			errorResponse := models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			)
+			c.JSON(statusCode, errorResponse)
+			return
+		}
+		errorResponse := models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_tags_failed",
+		)
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥标签已更新",
+		"key":     maskKey(key),
+		"tags":    tags,
+	})
+}
+
+// UpdateKeyScopesRequest 更新密钥作用域请求
+type UpdateKeyScopesRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+// UpdateKeyScopesHandler 设置密钥作用域，限定密钥可调用的端点
+// @Summary 设置API密钥作用域
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key path string true "要更新的密钥"
+// @Param request body UpdateKeyScopesRequest true "作用域列表（chat、embeddings、models、images、audio、moderations、batches、files、admin-read），空列表表示不限制（admin-read 除外）"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/keys/{key}/scopes [put]
+func UpdateKeyScopesHandler(c *gin.Context) {
+	key := c.Param("key")
+
+	var req UpdateKeyScopesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse := models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	scopes, err := middleware.NormalizeScopes(req.Scopes)
+	if err != nil {
+		errorResponse := models.NewErrorResponse(
+			"无效的作用域："+err.Error(),
+			"validation_error",
+			"invalid_scope",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	km := middleware.GetKeyManager()
+	if err := km.SetKeyScopes(key, scopes); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			errorResponse := models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			)
+			c.JSON(statusCode, errorResponse)
+			return
+		}
+		errorResponse := models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_scopes_failed",
+		)
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥作用域已更新",
+		"key":     maskKey(key),
+		"scopes":  scopes,
+	})
+}
+
+// UpdateKeySigningRequest 更新密钥请求签名设置请求
+type UpdateKeySigningRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"` // true 生成（或轮换）签名密钥，false 关闭签名
+}
+
+// UpdateKeySigningHandler 启用/关闭密钥的 HMAC 请求签名
+// @Summary 设置密钥请求签名
+// @Description 启用后该密钥的请求须携带 X-Signature-Timestamp 与 X-Signature 头（签名覆盖时间戳、方法、路径与请求体），签名密钥仅在响应中返回一次
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param key path string true "API密钥"
+// @Param request body UpdateKeySigningRequest true "是否启用签名"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/keys/{key}/signing [put]
+func UpdateKeySigningHandler(c *gin.Context) {
+	key := c.Param("key")
+
+	var req UpdateKeySigningRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse := models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	km := middleware.GetKeyManager()
+	var secret string
+	var err error
+	if *req.Enabled {
+		secret, err = km.EnableSigning(key)
+	} else {
+		err = km.DisableSigning(key)
+	}
+	if err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			errorResponse := models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			)
+			c.JSON(statusCode, errorResponse)
+			return
+		}
+		errorResponse := models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_signing_failed",
+		)
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	response := gin.H{
+		"message":         "密钥签名设置已更新",
+		"key":             maskKey(key),
+		"signing_enabled": *req.Enabled,
+	}
+	if secret != "" {
+		response["signing_secret"] = secret
+		response["signature_headers"] = []string{middleware.SignatureTimestampHeader, middleware.SignatureHeader}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateKeyStreamingRequest 更新密钥 SSE 合并策略请求（null 表示使用服务器默认值）
+type UpdateKeyStreamingRequest struct {
+	FlushIntervalMs *int `json:"flush_interval_ms"`
+	FlushBytes      *int `json:"flush_bytes"`
+}
+
+// UpdateKeyStreamingHandler 设置密钥的 SSE 输出合并策略
+// @Summary 设置API密钥流式输出合并策略
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key path string true "要更新的密钥"
+// @Param request body UpdateKeyStreamingRequest true "合并间隔与字节阈值"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/keys/{key}/streaming [put]
+func UpdateKeyStreamingHandler(c *gin.Context) {
+	key := c.Param("key")
+
+	var req UpdateKeyStreamingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse := models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	if (req.FlushIntervalMs != nil && (*req.FlushIntervalMs < 0 || *req.FlushIntervalMs > 10000)) ||
+		(req.FlushBytes != nil && (*req.FlushBytes < 0 || *req.FlushBytes > 1<<20)) {
+		errorResponse := models.NewErrorResponse(
+			"flush_interval_ms 必须在 0-10000 之间，flush_bytes 必须在 0-1048576 之间",
+			"validation_error",
+			"invalid_streaming_settings",
+		)
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	km := middleware.GetKeyManager()
+	if err := km.SetStreamFlush(key, req.FlushIntervalMs, req.FlushBytes); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			errorResponse := models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			)
+			c.JSON(statusCode, errorResponse)
+			return
+		}
+		errorResponse := models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_streaming_failed",
+		)
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "密钥流式输出设置已更新",
+		"key":       maskKey(key),
+		"effective": km.ResolveStreamFlush(key),
+	})
+}
+
 // ============================================
 // Admin Balance Management Handlers
 // ============================================
@@ -490,10 +950,19 @@ func AdjustUserBalanceHandler(c *gin.Context) {
 		return
 	}
 
+	// Positive adjustments are recharges: record VAT on top of the credited amount
+	taxRate, taxAmount := services.CalculateTax(req.UserID, req.Amount)
+	if taxAmount > 0 {
+		if err := database.SetTransactionTax(transaction.ID, taxRate, taxAmount); err != nil {
+			logrus.WithError(err).WithField("transaction_id", transaction.ID).Warn("Failed to record recharge tax")
+		}
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"user_id":       req.UserID,
 		"admin_id":      adminID,
 		"amount":        req.Amount,
+		"tax_amount":    taxAmount,
 		"reason":        req.Reason,
 		"balance_after": transaction.BalanceAfter,
 	}).Info("Admin adjusted user balance")
@@ -502,6 +971,9 @@ func AdjustUserBalanceHandler(c *gin.Context) {
 		"message":       "Balance adjusted successfully",
 		"user_id":       req.UserID,
 		"amount":        req.Amount,
+		"tax_rate":      taxRate,
+		"tax_amount":    taxAmount,
+		"gross_amount":  req.Amount + taxAmount,
 		"balance_after": transaction.BalanceAfter,
 		"transaction_id": transaction.ID,
 	})
@@ -173,3 +173,79 @@ func AdminGetExchangeStatsHandler(c *gin.Context) {
 		"total_usd":   stats.TotalUSD,
 	})
 }
+
+// AdminListTransferCompensationsHandler lists failed game coin transfers for reconciliation
+// GET /api/admin/exchanges/compensations
+// Query params: status (rolled_back, unknown, resolved; optional), limit (default 20, max 100), offset (default 0)
+func AdminListTransferCompensationsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	records, total, err := database.ListTransferCompensations(c.Query("status"), limit, offset)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list transfer compensations")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to retrieve compensation records",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"records": records,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// AdminResolveTransferCompensationHandler marks a compensation record as reviewed
+// POST /api/admin/exchanges/compensations/:id/resolve
+func AdminResolveTransferCompensationHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid compensation ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return
+	}
+	var req struct {
+		Note string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	if err := database.ResolveTransferCompensation(id, req.Note); err != nil {
+		if err == database.ErrCompensationNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Compensation record not found",
+				"not_found",
+				"compensation_not_found",
+			))
+			return
+		}
+		logrus.WithError(err).WithField("compensation_id", id).Error("Failed to resolve transfer compensation")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to resolve compensation record",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Curry2API-go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminReadKeyAuth_RequiresAdminTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	original := needsTwoFactorSetup
+	defer func() { needsTwoFactorSetup = original }()
+
+	tests := []struct {
+		name       string
+		user       *database.User
+		twoFactor  bool
+		wantAllow  bool
+		wantStatus int
+	}{
+		{"admin without 2FA", &database.User{ID: 1, Username: "root", Role: "admin"}, false, false, http.StatusForbidden},
+		{"admin with 2FA", &database.User{ID: 1, Username: "root", Role: "admin"}, true, true, http.StatusOK},
+		{"regular user", &database.User{ID: 2, Username: "alice", Role: "user"}, false, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsTwoFactorSetup = func(userID int64, role string) (bool, error) {
+				return role == "admin" && !tt.twoFactor, nil
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+
+			allowed := adminReadKeyAuth(c, "sk-read", tt.user)
+			if allowed != tt.wantAllow {
+				t.Fatalf("adminReadKeyAuth() = %v, want %v", allowed, tt.wantAllow)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !allowed {
+				if !c.IsAborted() {
+					t.Error("Expected the request to be aborted")
+				}
+				if _, exists := c.Get("user_id"); exists {
+					t.Error("Expected user_id not to be set on a rejected request")
+				}
+				return
+			}
+			if c.GetInt64("user_id") != tt.user.ID || c.GetString("role") != tt.user.Role || c.GetString("api_key") != "sk-read" {
+				t.Errorf("Unexpected context: user_id=%d role=%q api_key=%q", c.GetInt64("user_id"), c.GetString("role"), c.GetString("api_key"))
+			}
+		})
+	}
+}
+
+// adminOnlyRequest runs handler as an AdminAuth-authenticated user with the given role
+func adminOnlyRequest(handler gin.HandlerFunc, method, path, role string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, path, strings.NewReader(`{}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", int64(2))
+	c.Set("username", "alice")
+	c.Set("role", role)
+	handler(c)
+	return w
+}
+
+func TestAdminOnlyHandlers_RejectNonAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	cases := []struct {
+		name    string
+		handler gin.HandlerFunc
+		method  string
+		path    string
+	}{
+		{"import gateway", AdminImportGatewayHandler, http.MethodPost, "/admin/import/gateway?dry_run=false"},
+		{"create provider", h.AdminCreateCustomProvider, http.MethodPost, "/admin/providers"},
+		{"update provider", h.AdminUpdateCustomProvider, http.MethodPut, "/admin/providers/1"},
+		{"delete provider", h.AdminDeleteCustomProvider, http.MethodDelete, "/admin/providers/1"},
+		{"update key priority", UpdateKeyPriorityHandler, http.MethodPut, "/admin/keys/sk-test/priority"},
+		{"update model pricing", AdminUpdateModelPricingHandler, http.MethodPut, "/admin/pricing/gpt-4o"},
+		{"update checkin config", UpdateCheckinConfigHandler, http.MethodPut, "/admin/checkin/config"},
+		{"update fx rates", AdminUpdateFXRatesHandler, http.MethodPut, "/admin/fx-rates"},
+		{"update tax rates", AdminUpdateTaxRatesHandler, http.MethodPut, "/admin/tax-rates"},
+		{"create routing rule", AdminCreateRoutingRule, http.MethodPost, "/admin/routing-rules"},
+		{"update routing rule", AdminUpdateRoutingRule, http.MethodPut, "/admin/routing-rules/1"},
+		{"delete routing rule", AdminDeleteRoutingRule, http.MethodDelete, "/admin/routing-rules/1"},
+		{"update trial config", UpdateTrialConfigHandler, http.MethodPut, "/admin/trial/config"},
+		{"import config", h.AdminImportConfig, http.MethodPost, "/admin/config/import?dry_run=false"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := adminOnlyRequest(tc.handler, tc.method, tc.path, "user")
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+			if !strings.Contains(w.Body.String(), "admin_required") {
+				t.Errorf("body = %s, want admin_required", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCustomProviderRequest_RejectsInternalBaseURL(t *testing.T) {
+	for _, baseURL := range []string{
+		"http://127.0.0.1:11434/v1",
+		"http://localhost:8000/v1",
+		"http://10.0.0.5/v1",
+		"http://192.168.1.10/v1",
+		"http://169.254.169.254/latest",
+		"http://[::1]:8080/v1",
+	} {
+		req := CustomProviderRequest{Name: "internal", BaseURL: baseURL, Models: []string{"m"}}
+		if msg := req.validate(context.Background()); msg == "" {
+			t.Errorf("validate(%q) accepted an internal host", baseURL)
+		}
+	}
+
+	req := CustomProviderRequest{Name: "public", BaseURL: "https://93.184.216.34/v1/", Models: []string{"m"}}
+	if msg := req.validate(context.Background()); msg != "" {
+		t.Errorf("validate(public IP) = %q, want accepted", msg)
+	}
+}
+
+func TestEnterSudoHandler_LocksAfterRepeatedFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const userID = int64(42)
+	defer resetReauthFailures(userID)
+
+	for i := 1; i < maxReauthAttempts; i++ {
+		if remaining := recordReauthFailure(userID); remaining != maxReauthAttempts-i {
+			t.Fatalf("attempt %d: remaining = %d, want %d", i, remaining, maxReauthAttempts-i)
+		}
+	}
+	recordReauthFailure(userID)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/sudo", strings.NewReader(`{"password":"guess"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", userID)
+	c.Set("session_id", "0123456789abcdef")
+	c.Set("role", "admin")
+	EnterSudoHandler(c)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header missing")
+	}
+
+	resetReauthFailures(userID)
+	if !checkReauthLock(c, userID) {
+		t.Error("user still locked after reset")
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ChangelogEntryRequest 创建/更新 API 变更日志条目请求
+type ChangelogEntryRequest struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Category    string     `json:"category" binding:"required"`
+	Models      []string   `json:"models"`
+	EffectiveAt *time.Time `json:"effective_at"`
+	NoticeFrom  *time.Time `json:"notice_from"` // 默认立即开始提示
+	NoticeUntil *time.Time `json:"notice_until"`
+	Published   *bool      `json:"published"` // 默认发布
+}
+
+// toEntry 转换为变更日志条目并校验，返回错误信息
+func (r *ChangelogEntryRequest) toEntry() (*database.ChangelogEntry, string) {
+	entry := &database.ChangelogEntry{
+		Title:       strings.TrimSpace(r.Title),
+		Description: strings.TrimSpace(r.Description),
+		Category:    strings.ToLower(strings.TrimSpace(r.Category)),
+		EffectiveAt: r.EffectiveAt,
+		NoticeFrom:  time.Now(),
+		NoticeUntil: r.NoticeUntil,
+		Published:   r.Published == nil || *r.Published,
+	}
+	if r.NoticeFrom != nil {
+		entry.NoticeFrom = *r.NoticeFrom
+	}
+	for _, model := range r.Models {
+		entry.Models = append(entry.Models, strings.TrimSpace(model))
+	}
+	if err := middleware.ValidateChangelogEntry(entry); err != nil {
+		return nil, err.Error()
+	}
+	return entry, ""
+}
+
+// reloadAPINotices 重新加载响应头提示的变更日志，失败只记录日志（下次修改时重试）
+func reloadAPINotices() {
+	if err := middleware.ReloadAPINotices(); err != nil {
+		logrus.WithError(err).Error("Failed to reload API changelog notices")
+	}
+}
+
+// ListChangelogHandler 列出已发布的 API 变更日志，无需登录
+// GET /api/changelog
+func ListChangelogHandler(c *gin.Context) {
+	entries, err := database.ListChangelogEntries(true)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list API changelog")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_changelog_failed",
+		))
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetChangelogEntryHandler 获取一条已发布的 API 变更日志，X-CurryAPI-Notice 响应头中的 ID 指向这里
+// GET /api/changelog/:id
+func GetChangelogEntryHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	entry, err := database.GetChangelogEntry(id)
+	if err == database.ErrChangelogEntryNotFound || (err == nil && !entry.Published) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("变更日志不存在", "not_found", "changelog_entry_not_found"))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get API changelog entry")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_changelog_entry_failed",
+		))
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// AdminListChangelog 列出所有 API 变更日志（含未发布的）
+// GET /admin/changelog
+func AdminListChangelog(c *gin.Context) {
+	entries, err := database.ListChangelogEntries(false)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list API changelog")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_changelog_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// AdminCreateChangelogEntry 创建 API 变更日志条目，立即生效
+// POST /admin/changelog
+func AdminCreateChangelogEntry(c *gin.Context) {
+	var req ChangelogEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("请求格式错误", "validation_error", "invalid_request"))
+		return
+	}
+	entry, msg := req.toEntry()
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.CreateChangelogEntry(entry); err != nil {
+		logrus.WithError(err).Error("Failed to create API changelog entry")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"create_changelog_entry_failed",
+		))
+		return
+	}
+
+	reloadAPINotices()
+	c.JSON(http.StatusCreated, entry)
+}
+
+// AdminUpdateChangelogEntry 更新 API 变更日志条目，立即生效
+// PUT /admin/changelog/:id
+func AdminUpdateChangelogEntry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	var req ChangelogEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("请求格式错误", "validation_error", "invalid_request"))
+		return
+	}
+
+	existing, err := database.GetChangelogEntry(id)
+	if err == database.ErrChangelogEntryNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("变更日志不存在", "not_found", "changelog_entry_not_found"))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get API changelog entry")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_changelog_entry_failed",
+		))
+		return
+	}
+
+	// 未提供 notice_from 时保留原来的提示开始时间
+	if req.NoticeFrom == nil {
+		req.NoticeFrom = &existing.NoticeFrom
+	}
+	entry, msg := req.toEntry()
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	entry.ID = id
+	entry.CreatedAt = existing.CreatedAt
+	if err := database.UpdateChangelogEntry(entry); err != nil {
+		logrus.WithError(err).Error("Failed to update API changelog entry")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_changelog_entry_failed",
+		))
+		return
+	}
+
+	reloadAPINotices()
+	c.JSON(http.StatusOK, entry)
+}
+
+// AdminDeleteChangelogEntry 删除 API 变更日志条目，立即生效
+// DELETE /admin/changelog/:id
+func AdminDeleteChangelogEntry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	if err := database.DeleteChangelogEntry(id); err != nil {
+		if err == database.ErrChangelogEntryNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("变更日志不存在", "not_found", "changelog_entry_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to delete API changelog entry")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"delete_changelog_entry_failed",
+		))
+		return
+	}
+
+	reloadAPINotices()
+	c.JSON(http.StatusOK, gin.H{"message": "变更日志已删除"})
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxTranscriptionFileSize OpenAI audio/transcriptions 允许的最大文件大小
+	maxTranscriptionFileSize = 25 << 20
+	// transcriptionFormMemory 解析上传表单时留在内存中的上限，超出部分写入临时文件
+	transcriptionFormMemory = 4 << 20
+)
+
+// transcriptionContentTypes 各 response_format 对应的 Content-Type，上游未返回时使用
+var transcriptionContentTypes = map[string]string{
+	"json":         "application/json",
+	"verbose_json": "application/json",
+	"text":         "text/plain; charset=utf-8",
+	"srt":          "text/plain; charset=utf-8",
+	"vtt":          "text/vtt; charset=utf-8",
+}
+
+// AudioTranscriptions 处理 OpenAI 兼容的语音转文字请求（multipart 上传），路由到支持转写的提供商（OpenAI/自定义上游）
+// 音频从临时文件流式转发给上游；按音频秒数计费，秒数（向上取整）记为 prompt_tokens 写入 usage_records
+// POST /v1/audio/transcriptions
+func (h *Handler) AudioTranscriptions(c *gin.Context) {
+	requestStartTime := time.Now()
+
+	// 表单字段与文件头之外留出 1MB
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTranscriptionFileSize+1<<20)
+	if err := c.Request.ParseMultipartForm(transcriptionFormMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+				"file must be at most 25 MB",
+				"invalid_request_error",
+				"file_too_large",
+			))
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: multipart/form-data with file and model is required",
+			"invalid_request_error",
+			"invalid_form",
+		))
+		return
+	}
+	defer c.Request.MultipartForm.RemoveAll()
+
+	file, header, err := c.Request.FormFile("file")
+	model := c.Request.FormValue("model")
+	if err != nil || model == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: file and model are required",
+			"invalid_request_error",
+			"invalid_form",
+		))
+		return
+	}
+	defer file.Close()
+	if header.Size > maxTranscriptionFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			"file must be at most 25 MB",
+			"invalid_request_error",
+			"file_too_large",
+		))
+		return
+	}
+
+	responseFormat := c.Request.FormValue("response_format")
+	if responseFormat == "" {
+		responseFormat = "json"
+	}
+	if _, ok := transcriptionContentTypes[responseFormat]; !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"response_format must be one of json, text, srt, verbose_json, vtt",
+			"invalid_request_error",
+			"invalid_response_format",
+		))
+		return
+	}
+	temperature := c.Request.FormValue("temperature")
+	if temperature != "" {
+		if t, err := strconv.ParseFloat(temperature, 64); err != nil || t < 0 || t > 1 {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"temperature must be between 0 and 1",
+				"invalid_request_error",
+				"invalid_temperature",
+			))
+			return
+		}
+	}
+
+	// Check token model access restriction
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		if err := middleware.GetKeyManager().CheckTokenModelAccess(apiKey, model); err == middleware.ErrModelNotAllowed {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"Model not allowed - this token does not have access to model: "+model,
+				"forbidden",
+				"model_not_allowed",
+			))
+			return
+		}
+	}
+
+	client, providerName, ok := h.providerRouter.GetTranscriptionProvider(model, requestKeyTags(c))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"No provider available for transcription model: "+model,
+			"invalid_request_error",
+			"model_not_found",
+		))
+		return
+	}
+
+	usageInfo, err := utils.ExtractUsageFromContext(c)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to extract usage context info")
+	}
+	c.Set("request_start_time", requestStartTime)
+	c.Set("request_model", model)
+	if usageInfo != nil {
+		c.Set("usage_info", usageInfo)
+	}
+	c.Set("request_provider", providerName)
+
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), providerName, priority, nil)
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(providerName, priority),
+			models.NewErrorResponse(
+				"Provider is busy, please retry later",
+				"server_overloaded",
+				"provider_queue_timeout",
+			),
+			models.NewErrorResponse(
+				"Provider rate limit reached, please retry later",
+				"rate_limited",
+				"provider_rate_limited",
+			))
+		return
+	}
+	defer releaseSlot()
+
+	// 纯文本结果不含时长，改为向上游请求 json，再取出 text 返回
+	upstreamFormat := responseFormat
+	if upstreamFormat == "text" {
+		upstreamFormat = "json"
+	}
+
+	started := time.Now()
+	resp, err := client.CreateTranscription(c.Request.Context(), &models.TranscriptionRequest{
+		File:                   file,
+		FileName:               header.Filename,
+		Model:                  model,
+		Language:               c.Request.FormValue("language"),
+		Prompt:                 c.Request.FormValue("prompt"),
+		ResponseFormat:         upstreamFormat,
+		Temperature:            temperature,
+		TimestampGranularities: c.Request.MultipartForm.Value["timestamp_granularities[]"],
+	})
+	h.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, model, "")
+		services.LogProviderError(providerErr)
+		c.JSON(providerErr.HTTPStatus(), models.NewErrorResponse(
+			providerErr.GetUserFriendlyMessage(),
+			"provider_error",
+			string(providerErr.Code),
+		))
+		return
+	}
+
+	// 按音频秒数计费；上游未返回时长（如只返回 token 用量的模型）时按文件大小估算
+	seconds := services.TranscriptionSeconds(upstreamFormat, resp.Body)
+	if seconds <= 0 {
+		seconds = services.EstimateAudioSeconds(header.Size)
+		logrus.WithFields(logrus.Fields{
+			"model":    model,
+			"provider": providerName,
+			"seconds":  seconds,
+		}).Debug("Transcription response has no duration, estimated from file size")
+	}
+	billedSeconds := int(math.Ceil(seconds))
+	c.Set("request_cost", services.TranscriptionCost(model, seconds))
+	c.Set("cursor_session", providerName+"-direct")
+	trackUsageFromContext(c, &models.Usage{
+		PromptTokens: billedSeconds,
+		TotalTokens:  billedSeconds,
+	}, http.StatusOK, "")
+
+	if responseFormat == "text" {
+		var transcript struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(resp.Body, &transcript); err == nil {
+			c.Data(http.StatusOK, transcriptionContentTypes["text"], []byte(transcript.Text+"\n"))
+			return
+		}
+	}
+
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = transcriptionContentTypes[responseFormat]
+	}
+	c.Data(http.StatusOK, contentType, resp.Body)
+}
+
+// maxSpeechInputChars OpenAI audio/speech 单次请求允许的最大输入字符数
+const maxSpeechInputChars = 4096
+
+// speechContentTypes 各 response_format 对应的 Content-Type，上游未返回时使用
+var speechContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/pcm",
+}
+
+// AudioSpeech 处理 OpenAI 兼容的文本转语音请求，路由到支持 TTS 的提供商（OpenAI/自定义上游）
+// 直接返回音频字节；按输入字符数计费，字符数记为 prompt_tokens 写入 usage_records
+// POST /v1/audio/speech
+func (h *Handler) AudioSpeech(c *gin.Context) {
+	requestStartTime := time.Now()
+
+	var request models.SpeechRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: model, input and voice are required",
+			"invalid_request_error",
+			"invalid_json",
+		))
+		return
+	}
+	chars := utf8.RuneCountInString(request.Input)
+	if chars > maxSpeechInputChars {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"input must be at most 4096 characters",
+			"invalid_request_error",
+			"input_too_long",
+		))
+		return
+	}
+	if request.ResponseFormat == "" {
+		request.ResponseFormat = "mp3"
+	}
+	if _, ok := speechContentTypes[request.ResponseFormat]; !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"response_format must be one of mp3, opus, aac, flac, wav, pcm",
+			"invalid_request_error",
+			"invalid_response_format",
+		))
+		return
+	}
+	if request.Speed != nil && (*request.Speed < 0.25 || *request.Speed > 4.0) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"speed must be between 0.25 and 4.0",
+			"invalid_request_error",
+			"invalid_speed",
+		))
+		return
+	}
+
+	// Check token model access restriction
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		if err := middleware.GetKeyManager().CheckTokenModelAccess(apiKey, request.Model); err == middleware.ErrModelNotAllowed {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"Model not allowed - this token does not have access to model: "+request.Model,
+				"forbidden",
+				"model_not_allowed",
+			))
+			return
+		}
+	}
+
+	client, providerName, ok := h.providerRouter.GetSpeechProvider(request.Model, requestKeyTags(c))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"No provider available for speech model: "+request.Model,
+			"invalid_request_error",
+			"model_not_found",
+		))
+		return
+	}
+
+	usageInfo, err := utils.ExtractUsageFromContext(c)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to extract usage context info")
+	}
+	c.Set("request_start_time", requestStartTime)
+	c.Set("request_model", request.Model)
+	if usageInfo != nil {
+		c.Set("usage_info", usageInfo)
+	}
+	c.Set("request_provider", providerName)
+
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), providerName, priority, nil)
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(providerName, priority),
+			models.NewErrorResponse(
+				"Provider is busy, please retry later",
+				"server_overloaded",
+				"provider_queue_timeout",
+			),
+			models.NewErrorResponse(
+				"Provider rate limit reached, please retry later",
+				"rate_limited",
+				"provider_rate_limited",
+			))
+		return
+	}
+	defer releaseSlot()
+
+	started := time.Now()
+	resp, err := client.CreateSpeech(c.Request.Context(), &request)
+	h.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, request.Model, "")
+		services.LogProviderError(providerErr)
+		c.JSON(providerErr.HTTPStatus(), models.NewErrorResponse(
+			providerErr.GetUserFriendlyMessage(),
+			"provider_error",
+			string(providerErr.Code),
+		))
+		return
+	}
+
+	// 按输入字符数计费
+	c.Set("request_cost", services.SpeechCost(request.Model, request.Input))
+	c.Set("cursor_session", providerName+"-direct")
+	trackUsageFromContext(c, &models.Usage{
+		PromptTokens: chars,
+		TotalTokens:  chars,
+	}, http.StatusOK, "")
+
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = speechContentTypes[request.ResponseFormat]
+	}
+	c.Data(http.StatusOK, contentType, resp.Audio)
+}
@@ -3,8 +3,10 @@ package handlers
 import (
 	"Curry2API-go/config"
 	"Curry2API-go/database"
+	"Curry2API-go/middleware"
 	"Curry2API-go/models"
 	"Curry2API-go/services"
+	"Curry2API-go/utils"
 	"fmt"
 	"net/http"
 	"os"
@@ -44,6 +46,7 @@ type RegisterRequest struct {
 	Code           string `json:"code" binding:"required,len=6"`
 	TurnstileToken string `json:"turnstile_token" binding:"required"`
 	ReferralCode   string `json:"referral_code,omitempty"` // Optional referral code
+	TrialKey       string `json:"trial_key,omitempty"`     // 注册前使用的匿名试用密钥，用于统计转化
 }
 
 // LoginRequest 登入請求
@@ -106,6 +109,7 @@ func RegisterHandler(c *gin.Context) {
 	}
 
 	logrus.Infof("User registered: %s (ID: %d)", user.Username, user.ID)
+	recordTrialConversion(c, req.TrialKey, user.ID)
 
 	// Create user balance record with initial balance of $50
 	// Requirements: 1.1, 4.1
@@ -212,24 +216,105 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	// 清理用户的旧会话（保留最新的3个）
-	if err := database.DeleteUserOldSessions(user.ID, 2); err != nil {
-		logrus.Warnf("Failed to clean old sessions for user %d: %v", user.ID, err)
-	}
-
-	session, err := database.CreateSession(
-		user.ID,
-		user.Username,
-		user.Role,
-		c.ClientIP(),
-		c.GetHeader("User-Agent"),
-		sessionDuration,
-	)
+	// 已启用两步验证：密码正确后先签发一次性挑战，提交验证码后才创建会话
+	twoFactorEnabled, err := database.IsTwoFactorEnabled(user.ID)
 	if err != nil {
-		logrus.Errorf("Failed to create session: %v", err)
+		logrus.Errorf("Failed to check two-factor for user %d: %v", user.ID, err)
 		writeServerError(c)
 		return
 	}
+	if twoFactorEnabled {
+		challenge, claims, ok := issueTwoFactorChallenge(user.ID)
+		if !ok {
+			writeError(c, http.StatusServiceUnavailable, "two_factor_unavailable", "两步验证暂不可用，请稍后重试")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "请输入两步验证码",
+			"two_factor_required": true,
+			"challenge":           challenge,
+			"expires_at":          claims.Expiry(),
+		})
+		return
+	}
+
+	completeLogin(c, user)
+}
+
+// loginCredentials 一次登录建立的凭据：会话模式下为 Session，JWT 模式下为 Tokens
+type loginCredentials struct {
+	Session *database.Session
+	Tokens  *middleware.TokenPair
+}
+
+// establishLogin 为已通过验证的用户建立登录并写入 cookie：会话模式创建会话，JWT 模式签发令牌。
+// 两种模式都会在新设备登录时发送提醒邮件并更新最后登录时间。密码、两步验证与第三方登录共用，失败时由调用方响应
+func establishLogin(c *gin.Context, user *database.User) (*loginCredentials, error) {
+	// 采集设备指纹，并在清理旧会话前判断是否为新设备登录
+	device := utils.DeviceFromRequest(c)
+	newDevice := isNewDeviceLogin(user, device)
+
+	creds := &loginCredentials{}
+	if middleware.JWTEnabled() {
+		pair, err := middleware.IssueTokenPair(user.ID, user.Username, user.Role)
+		if err != nil {
+			return nil, err
+		}
+		if err := database.RecordLoginDevice(user.ID, device.Fingerprint()); err != nil {
+			logrus.Warnf("Failed to record login device for user %d: %v", user.ID, err)
+		}
+		middleware.SetTokenCookies(c, pair)
+		creds.Tokens = pair
+	} else {
+		// 清理用户的旧会话（保留最新的3个）
+		if err := database.DeleteUserOldSessions(user.ID, 2); err != nil {
+			logrus.Warnf("Failed to clean old sessions for user %d: %v", user.ID, err)
+		}
+
+		session, err := database.CreateSession(
+			user.ID,
+			user.Username,
+			user.Role,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+			device,
+			sessionDuration,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// 设置 session cookie
+		isProduction := os.Getenv("DEBUG") != "true"
+		domain := os.Getenv("COOKIE_DOMAIN") // 例如: ".kesug.icu" 或留空
+		
+		// 使用 SameSite=Lax 而不是 Strict，避免跨站点问题
+		// Lax 允许顶级导航（如从外部链接点击进入）携带 cookie
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(
+			"session_id",           // name
+			session.ID,             // value
+			int(sessionDuration.Seconds()), // maxAge
+			"/",                    // path
+			domain,                 // domain - 从环境变量读取
+			isProduction,           // secure
+			true,                   // httpOnly
+		)
+		
+		logrus.WithFields(logrus.Fields{
+			"user_id":    user.ID,
+			"username":   user.Username,
+			"session_id": session.ID[:8] + "...",
+			"ip_address": c.ClientIP(),
+			"domain":     domain,
+			"secure":     isProduction,
+		}).Info("Session cookie set")
+		creds.Session = session
+	}
+
+	if newDevice {
+		notifyNewDeviceLogin(user, device, c.ClientIP(), time.Now())
+	}
 
 	go func(id int64) {
 		if err := database.UpdateLastLogin(id); err != nil {
@@ -237,54 +322,66 @@ func LoginHandler(c *gin.Context) {
 		}
 	}(user.ID)
 
-	logrus.Infof("User logged in: %s (Session: %s)", user.Username, session.ID)
+	return creds, nil
+}
 
-	// 设置 session cookie
-	isProduction := os.Getenv("DEBUG") != "true"
-	domain := os.Getenv("COOKIE_DOMAIN") // 例如: ".kesug.icu" 或留空
-	
-	// 使用 SameSite=Lax 而不是 Strict，避免跨站点问题
-	// Lax 允许顶级导航（如从外部链接点击进入）携带 cookie
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		"session_id",           // name
-		session.ID,             // value
-		int(sessionDuration.Seconds()), // maxAge
-		"/",                    // path
-		domain,                 // domain - 从环境变量读取
-		isProduction,           // secure
-		true,                   // httpOnly
-	)
-	
-	logrus.WithFields(logrus.Fields{
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"session_id": session.ID[:8] + "...",
-		"ip_address": c.ClientIP(),
-		"domain":     domain,
-		"secure":     isProduction,
-	}).Info("Session cookie set")
+// completeLogin 建立登录并返回登录成功响应（密码登录与两步验证登录共用）；
+// 会话模式返回 session_id，JWT 模式返回令牌
+func completeLogin(c *gin.Context, user *database.User) {
+	creds, err := establishLogin(c, user)
+	if err != nil {
+		logrus.Errorf("Failed to complete login for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":    "登录成功",
-		"session_id": session.ID,
+	resp := gin.H{
+		"message": "登录成功",
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
 			"email":    user.Email,
 			"role":     user.Role,
 		},
-	})
+		"terms": termsStatus(user.ID),
+	}
+	if creds.Tokens != nil {
+		resp["access_token"] = creds.Tokens.AccessToken
+		resp["refresh_token"] = creds.Tokens.RefreshToken
+		resp["token_type"] = creds.Tokens.TokenType
+		resp["expires_in"] = creds.Tokens.ExpiresIn
+		logrus.Infof("User logged in with tokens: %s", user.Username)
+	} else {
+		resp["session_id"] = creds.Session.ID
+		logrus.Infof("User logged in: %s (Session: %s)", user.Username, creds.Session.ID)
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // LogoutHandler 登出
 func LogoutHandler(c *gin.Context) {
+	// JWT 模式：吊销本次登录轮换出的全部令牌
+	if middleware.JWTEnabled() {
+		if family := middleware.TokenFamilyFromRequest(c); family != "" {
+			if err := middleware.RevokeTokenFamily(family); err != nil {
+				logrus.Errorf("Failed to revoke tokens on logout: %v", err)
+				writeServerError(c)
+				return
+			}
+			middleware.ClearTokenCookies(c)
+			logrus.Infof("User logged out (Token family: %s...)", family[:8])
+			c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
+			return
+		}
+	}
+
 	sessionID, err := c.Cookie("session_id")
 	if err != nil || sessionID == "" {
 		writeError(c, http.StatusUnauthorized, "no_session", "未登录")
 		return
 	}
 
+	middleware.ForgetSession(sessionID)
 	if err := database.DeleteSession(sessionID); err != nil {
 		logrus.Warnf("Failed to delete session %s: %v", sessionID, err)
 	}
@@ -360,6 +457,8 @@ func GetCurrentUserHandler(c *gin.Context) {
 			"created_at": user.CreatedAt,
 			"last_login": user.LastLogin,
 		},
+		"terms":      termsStatus(user.ID),
+		"two_factor": twoFactorStatus(user),
 	})
 }
 
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshTokenRequest 非浏览器客户端在请求体中提交刷新令牌，浏览器使用 refresh_token cookie
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenHandler 用刷新令牌换取新的访问令牌与刷新令牌，旧刷新令牌随即失效
+// POST /auth/token/refresh
+func RefreshTokenHandler(c *gin.Context) {
+	if !middleware.JWTEnabled() {
+		writeError(c, http.StatusBadRequest, "jwt_disabled", "未启用 JWT 认证模式")
+		return
+	}
+
+	refreshToken, _ := c.Cookie(middleware.RefreshTokenCookie)
+	if refreshToken == "" {
+		var req RefreshTokenRequest
+		_ = c.ShouldBindJSON(&req)
+		refreshToken = req.RefreshToken
+	}
+	if refreshToken == "" {
+		writeError(c, http.StatusUnauthorized, "invalid_refresh_token", "未登录")
+		return
+	}
+
+	pair, state, err := middleware.RotateRefreshToken(refreshToken, middleware.AccessTokenFromRequest(c))
+	if err != nil {
+		switch err {
+		case middleware.ErrJWTInvalid, middleware.ErrJWTExpired, middleware.ErrJWTRevoked:
+			middleware.ClearTokenCookies(c)
+			writeError(c, http.StatusUnauthorized, "invalid_refresh_token", "登录已过期，请重新登录")
+		case middleware.ErrJWTReplayed:
+			middleware.ClearTokenCookies(c)
+			writeError(c, http.StatusUnauthorized, "refresh_token_reused", "登录凭据已被使用，为安全起见已退出登录，请重新登录")
+		default:
+			logrus.Errorf("Failed to refresh tokens: %v", err)
+			writeServerError(c)
+		}
+		return
+	}
+
+	middleware.SetTokenCookies(c, pair)
+	logrus.WithField("user_id", state.UserID).Debug("Tokens refreshed")
+
+	c.JSON(http.StatusOK, pair)
+}
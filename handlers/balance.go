@@ -3,6 +3,7 @@ package handlers
 import (
 	"Curry2API-go/database"
 	"Curry2API-go/models"
+	"Curry2API-go/services"
 	"net/http"
 	"strconv"
 
@@ -62,15 +63,23 @@ func GetBalanceHandler(c *gin.Context) {
 		}
 	}
 
+	// Amounts are stored in USD; display values are converted to the user's currency
+	currency, rate := services.GetUserDisplayCurrency(userID)
+
 	// Return balance information
 	c.JSON(http.StatusOK, gin.H{
-		"balance":         balance.Balance,
-		"status":          balance.Status,
-		"referral_code":   balance.ReferralCode,
-		"total_consumed":  balance.TotalConsumed,
-		"total_recharged": balance.TotalRecharged,
-		"created_at":      balance.CreatedAt,
-		"updated_at":      balance.UpdatedAt,
+		"balance":                 balance.Balance,
+		"status":                  balance.Status,
+		"referral_code":           balance.ReferralCode,
+		"total_consumed":          balance.TotalConsumed,
+		"total_recharged":         balance.TotalRecharged,
+		"created_at":              balance.CreatedAt,
+		"updated_at":              balance.UpdatedAt,
+		"display_currency":        currency,
+		"fx_rate":                 rate,
+		"display_balance":         balance.Balance * rate,
+		"display_total_consumed":  balance.TotalConsumed * rate,
+		"display_total_recharged": balance.TotalRecharged * rate,
 	})
 }
 
@@ -133,17 +142,21 @@ func GetTransactionsHandler(c *gin.Context) {
 		return
 	}
 
+	currency, rate := services.GetUserDisplayCurrency(userID)
+
 	// Format transactions for response
 	formattedTransactions := make([]gin.H, 0, len(transactions))
 	for _, tx := range transactions {
 		txData := gin.H{
-			"id":            tx.ID,
-			"type":          tx.Type,
-			"amount":        tx.Amount,
-			"balance_after": tx.BalanceAfter,
-			"tokens":        tx.Tokens,
-			"description":   tx.Description,
-			"created_at":    tx.CreatedAt,
+			"id":                    tx.ID,
+			"type":                  tx.Type,
+			"amount":                tx.Amount,
+			"balance_after":         tx.BalanceAfter,
+			"display_amount":        tx.Amount * rate,
+			"display_balance_after": tx.BalanceAfter * rate,
+			"tokens":                tx.Tokens,
+			"description":           tx.Description,
+			"created_at":            tx.CreatedAt,
 		}
 
 		// Include optional fields if present
@@ -165,10 +178,12 @@ func GetTransactionsHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"transactions": formattedTransactions,
-		"total":        total,
-		"limit":        limit,
-		"offset":       offset,
+		"transactions":     formattedTransactions,
+		"total":            total,
+		"limit":            limit,
+		"offset":           offset,
+		"display_currency": currency,
+		"fx_rate":          rate,
 	})
 }
 
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// batchMaxInputBytes 批处理输入（JSONL）的最大字节数
+const batchMaxInputBytes = 64 << 20
+
+// CreateBatchRequest 创建批处理请求；也可直接以 application/jsonl 请求体上传输入，endpoint 通过查询参数指定
+type CreateBatchRequest struct {
+	Endpoint         string            `json:"endpoint"`
+	Input            string            `json:"input"`         // JSONL，每行 {"custom_id","method","url","body"}
+	InputFileID      string            `json:"input_file_id"` // 或引用 purpose 为 batch 的已上传文件
+	Metadata         map[string]string `json:"metadata"`
+	CompletionWindow string            `json:"completion_window"` // 仅为兼容 OpenAI 客户端，忽略
+}
+
+// batchView 以 OpenAI batch 对象的格式返回批处理状态与用量汇总
+func batchView(b *database.Batch) gin.H {
+	view := gin.H{
+		"id":         b.ID,
+		"object":     "batch",
+		"endpoint":   b.Endpoint,
+		"status":     b.Status,
+		"created_at": b.CreatedAt.Unix(),
+		"request_counts": gin.H{
+			"total":     b.Total,
+			"completed": b.Completed,
+			"failed":    b.Failed,
+		},
+		"usage": gin.H{
+			"prompt_tokens":     b.PromptTokens,
+			"completion_tokens": b.CompletionTokens,
+			"total_tokens":      b.TotalTokens,
+			"cost":              b.Cost,
+		},
+		"metadata": b.Metadata,
+	}
+	if b.CompletedAt != nil {
+		view["completed_at"] = b.CompletedAt.Unix()
+	}
+	if b.CancelledAt != nil {
+		view["cancelled_at"] = b.CancelledAt.Unix()
+	}
+	return view
+}
+
+// batchProcessorOrError 返回批处理服务，未启用时写入 503
+func batchProcessorOrError(c *gin.Context) *services.BatchProcessor {
+	processor := services.GetBatchProcessor()
+	if processor == nil {
+		c.JSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+			"Batch API is not enabled",
+			"service_unavailable",
+			"batches_disabled",
+		))
+	}
+	return processor
+}
+
+// keyOwner 返回调用方密钥的用量信息；批处理、文件等按用户归属的资源需要归属于用户的密钥
+func keyOwner(c *gin.Context, resource, code string) *utils.UsageContextInfo {
+	usageInfo, err := utils.ExtractUsageFromContext(c)
+	if err != nil || usageInfo.UserID == 0 {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+			resource+" require an API key that belongs to a user",
+			"forbidden",
+			code,
+		))
+		return nil
+	}
+	return usageInfo
+}
+
+// batchOwner 返回批处理所属用户的密钥用量信息
+func batchOwner(c *gin.Context) *utils.UsageContextInfo {
+	return keyOwner(c, "Batches", "batch_owner_required")
+}
+
+// loadOwnedBatch 获取调用方自己的批处理，不存在或不属于调用方时返回 404
+func loadOwnedBatch(c *gin.Context, userID int64) *database.Batch {
+	batch, err := database.GetBatch(c.Param("id"))
+	if errors.Is(err, database.ErrBatchNotFound) || (err == nil && batch.UserID != userID) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Batch not found",
+			"not_found",
+			"batch_not_found",
+		))
+		return nil
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get batch")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"batch_query_failed",
+		))
+		return nil
+	}
+	return batch
+}
+
+// readBatchInputFile 读取调用方上传的批处理输入文件（purpose 须为 batch）
+func readBatchInputFile(c *gin.Context, fileID string, userID int64) ([]byte, bool) {
+	service := fileServiceOrError(c)
+	if service == nil {
+		return nil, false
+	}
+	file := loadOwnedFile(c, fileID, userID)
+	if file == nil {
+		return nil, false
+	}
+	if file.Purpose != services.FilePurposeBatch {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"File "+file.ID+" was uploaded with purpose '"+file.Purpose+"', batch input requires purpose 'batch'",
+			"invalid_request_error",
+			"invalid_input_file",
+		))
+		return nil, false
+	}
+
+	input, err := service.ReadAll(c.Request.Context(), file, batchMaxInputBytes)
+	switch {
+	case errors.Is(err, services.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			"Batch input is too large",
+			"invalid_request_error",
+			"input_too_large",
+		))
+		return nil, false
+	case err != nil:
+		logrus.WithError(err).WithField("file_id", file.ID).Error("Failed to read batch input file")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_read_failed",
+		))
+		return nil, false
+	}
+	return input, true
+}
+
+// CreateBatch 接收 JSONL 格式的请求列表（内联或 input_file_id 引用的文件），校验后由后台工作池异步处理
+// POST /v1/batches
+func (h *Handler) CreateBatch(c *gin.Context) {
+	processor := batchProcessorOrError(c)
+	if processor == nil {
+		return
+	}
+	usageInfo := batchOwner(c)
+	if usageInfo == nil {
+		return
+	}
+
+	var request CreateBatchRequest
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, batchMaxInputBytes)
+	contentType := c.ContentType()
+	if contentType == "application/jsonl" || contentType == "application/x-ndjson" {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+				"Batch input is too large",
+				"invalid_request_error",
+				"input_too_large",
+			))
+			return
+		}
+		request.Endpoint = c.Query("endpoint")
+		request.Input = string(data)
+	} else if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format",
+			"invalid_request_error",
+			"invalid_json",
+		))
+		return
+	}
+	if request.Endpoint == "" {
+		request.Endpoint = services.BatchEndpointChatCompletions
+	}
+	if request.InputFileID != "" {
+		if request.Input != "" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Provide either input or input_file_id, not both",
+				"invalid_request_error",
+				"invalid_batch_input",
+			))
+			return
+		}
+		input, ok := readBatchInputFile(c, request.InputFileID, usageInfo.UserID)
+		if !ok {
+			return
+		}
+		request.Input = string(input)
+	}
+
+	lines, err := processor.ParseInput(request.Endpoint, []byte(request.Input))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"invalid_request_error",
+			"invalid_batch_input",
+		))
+		return
+	}
+
+	// Check token model access restriction for every model in the batch
+	checked := make(map[string]bool)
+	for _, line := range lines {
+		if checked[line.Model] {
+			continue
+		}
+		checked[line.Model] = true
+		if err := middleware.GetKeyManager().CheckTokenModelAccess(usageInfo.APIToken, line.Model); err == middleware.ErrModelNotAllowed {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"Model not allowed - this token does not have access to model: "+line.Model,
+				"forbidden",
+				"model_not_allowed",
+			))
+			return
+		}
+	}
+
+	batch := &database.Batch{
+		UserID:    usageInfo.UserID,
+		Username:  usageInfo.Username,
+		APIKey:    usageInfo.APIToken,
+		TokenName: usageInfo.TokenName,
+		Endpoint:  request.Endpoint,
+		Metadata:  request.Metadata,
+	}
+	if err := processor.Create(batch, lines); err != nil {
+		logrus.WithError(err).Error("Failed to create batch")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"batch_create_failed",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"batch_id": batch.ID,
+		"user_id":  batch.UserID,
+		"endpoint": batch.Endpoint,
+		"requests": batch.Total,
+	}).Info("Batch created")
+	c.JSON(http.StatusOK, batchView(batch))
+}
+
+// ListBatches 列出调用方最近的批处理
+// GET /v1/batches?limit=20
+func (h *Handler) ListBatches(c *gin.Context) {
+	usageInfo := batchOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	batches, err := database.ListBatchesByUser(usageInfo.UserID, limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list batches")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"batch_query_failed",
+		))
+		return
+	}
+
+	data := make([]gin.H, 0, len(batches))
+	for _, b := range batches {
+		data = append(data, batchView(b))
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// GetBatch 返回批处理的状态、请求计数与用量汇总
+// GET /v1/batches/:id
+func (h *Handler) GetBatch(c *gin.Context) {
+	usageInfo := batchOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	if batch := loadOwnedBatch(c, usageInfo.UserID); batch != nil {
+		c.JSON(http.StatusOK, batchView(batch))
+	}
+}
+
+// GetBatchResults 以 JSONL 返回批处理中每个请求的结果（按输入顺序，未处理的请求 response 与 error 均为 null）
+// GET /v1/batches/:id/results
+func (h *Handler) GetBatchResults(c *gin.Context) {
+	usageInfo := batchOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	batch := loadOwnedBatch(c, usageInfo.UserID)
+	if batch == nil {
+		return
+	}
+
+	requests, err := database.ListBatchRequests(batch.ID, false)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list batch requests")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"batch_query_failed",
+		))
+		return
+	}
+
+	c.Header("Content-Type", "application/jsonl")
+	c.Header("Content-Disposition", "attachment; filename=\""+batch.ID+"_results.jsonl\"")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for _, r := range requests {
+		if err := encoder.Encode(services.NewBatchResultLine(r)); err != nil {
+			logrus.WithError(err).WithField("batch_id", batch.ID).Warn("Failed to write batch results")
+			return
+		}
+	}
+}
+
+// CancelBatch 取消批处理：尚未开始的请求标记为 cancelled，正在处理的请求完成后结束
+// POST /v1/batches/:id/cancel
+func (h *Handler) CancelBatch(c *gin.Context) {
+	processor := batchProcessorOrError(c)
+	if processor == nil {
+		return
+	}
+	usageInfo := batchOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	batch := loadOwnedBatch(c, usageInfo.UserID)
+	if batch == nil {
+		return
+	}
+
+	changed, err := processor.Cancel(batch.ID)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to cancel batch")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"batch_cancel_failed",
+		))
+		return
+	}
+	if !changed && batch.Status != database.BatchStatusCancelling && batch.Status != database.BatchStatusCancelled {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Batch is already "+batch.Status,
+			"invalid_request_error",
+			"batch_not_cancellable",
+		))
+		return
+	}
+
+	if updated, err := database.GetBatch(batch.ID); err == nil {
+		batch = updated
+	}
+	c.JSON(http.StatusOK, batchView(batch))
+}
+
+// DeductBatchUsage 扣除批处理中成功请求的费用，供批处理服务回调
+func DeductBatchUsage(userID int64, tokens int, apiToken, model string) {
+	deductBalanceForUsage(userID, tokens, apiToken, model)
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,8 +13,10 @@ import (
 
 	"Curry2API-go/config"
 	"Curry2API-go/database"
+	"Curry2API-go/middleware"
 	"Curry2API-go/models"
 	"Curry2API-go/services"
+	"Curry2API-go/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -25,6 +28,7 @@ type ChatHandler struct {
 	chatService    *services.ChatService
 	providerRouter *services.ProviderRouter
 	config         *config.Config
+	streams        replayStreams // Replies streamed over SSE, resumable with Last-Event-ID
 }
 
 // NewChatHandler creates a new ChatHandler instance
@@ -54,21 +58,44 @@ func (h *ChatHandler) SetProviderRouter(router *services.ProviderRouter) {
 
 // CreateConversationRequest represents the request body for creating a conversation
 type CreateConversationRequest struct {
-	Title        string `json:"title"`
-	Model        string `json:"model" binding:"required"`
-	SystemPrompt string `json:"system_prompt,omitempty"`
+	Title         string   `json:"title"`
+	Model         string   `json:"model" binding:"required"`
+	SystemPrompt  string   `json:"system_prompt,omitempty"`
+	TemplateID    *int64   `json:"template_id,omitempty"`   // Start from a system prompt template instead of system_prompt
+	MaxCost       *float64 `json:"max_cost,omitempty"`      // Optional spend ceiling in USD
+	Deterministic bool     `json:"deterministic,omitempty"` // Pin temperature 0 and a fixed seed
+	Seed          *int64   `json:"seed,omitempty"`          // Seed for deterministic mode; omitted uses the default
 }
 
 // UpdateConversationRequest represents the request body for updating a conversation
 type UpdateConversationRequest struct {
-	Title string `json:"title"`
-	Model string `json:"model"`
+	Title         string   `json:"title"`
+	Model         string   `json:"model"`
+	SystemPrompt  *string  `json:"system_prompt"` // Replaces the system prompt; empty removes it, omitted keeps it
+	TemplateID    *int64   `json:"template_id"`   // Replaces the system prompt with a template's content
+	MaxCost       *float64 `json:"max_cost"`      // Spend ceiling in USD; 0 removes it, omitted keeps it
+	Deterministic *bool    `json:"deterministic"` // Toggle deterministic mode; omitted keeps it
+	Seed          *int64   `json:"seed"`          // Seed for deterministic mode; omitted keeps it
+}
+
+// validMaxCost reports whether a requested spend ceiling is usable, sending a 400 response if not
+func validMaxCost(c *gin.Context, maxCost *float64) bool {
+	if maxCost != nil && *maxCost < 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"max_cost must not be negative",
+			"validation_error",
+			"invalid_max_cost",
+		))
+		return false
+	}
+	return true
 }
 
 // SendMessageRequest represents the request body for sending a message
 type SendMessageRequest struct {
-	Content string `json:"content" binding:"required"`
-	Model   string `json:"model,omitempty"` // Optional: override conversation model
+	Content       string   `json:"content" binding:"required"`
+	Model         string   `json:"model,omitempty"`          // Optional: override conversation model
+	AttachmentIDs []string `json:"attachment_ids,omitempty"` // Optional: IDs from POST /api/chat/attachments
 }
 
 // CreateConversation creates a new chat conversation
@@ -100,10 +127,18 @@ func (h *ChatHandler) CreateConversation(c *gin.Context) {
 		return
 	}
 
+	if !validMaxCost(c, req.MaxCost) {
+		return
+	}
+	systemPrompt, ok := resolveSystemPrompt(c, userID, req.SystemPrompt, req.TemplateID)
+	if !ok {
+		return
+	}
+
 	// Set default title if not provided
 	title := req.Title
 	if title == "" {
-		title = "新对话"
+		title = services.DefaultConversationTitle
 	}
 
 	// Create conversation in database
@@ -118,6 +153,47 @@ func (h *ChatHandler) CreateConversation(c *gin.Context) {
 		return
 	}
 
+	if req.MaxCost != nil && *req.MaxCost > 0 {
+		if err := database.SetConversationMaxCost(conv.ID, userID, req.MaxCost); err != nil {
+			logrus.WithError(err).WithField("conversation_id", conv.ID).Error("Failed to set conversation max cost")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to create conversation",
+				"internal_error",
+				"database_error",
+			))
+			return
+		}
+		conv.MaxCost = req.MaxCost
+	}
+
+	if systemPrompt != "" {
+		if err := database.SetConversationSystemPrompt(conv.ID, userID, systemPrompt, req.TemplateID); err != nil {
+			logrus.WithError(err).WithField("conversation_id", conv.ID).Error("Failed to set conversation system prompt")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to create conversation",
+				"internal_error",
+				"database_error",
+			))
+			return
+		}
+		conv.SystemPrompt = systemPrompt
+		conv.PromptTemplateID = req.TemplateID
+	}
+
+	if req.Deterministic {
+		if err := database.SetConversationDeterministic(conv.ID, userID, true, req.Seed); err != nil {
+			logrus.WithError(err).WithField("conversation_id", conv.ID).Error("Failed to enable deterministic mode")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to create conversation",
+				"internal_error",
+				"database_error",
+			))
+			return
+		}
+		conv.Deterministic = true
+		conv.Seed = req.Seed
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    conv,
@@ -126,7 +202,9 @@ func (h *ChatHandler) CreateConversation(c *gin.Context) {
 
 // GetConversations retrieves paginated conversations for the current user
 // GET /api/chat/conversations
-// Query params: page (default 1), limit (default 20, max 100)
+// Query params: page (default 1), limit (default 20, max 100),
+// folder_id (a folder ID, or "none" for unfiled conversations), tag,
+// include_archived (true to also list archived conversations). Pinned conversations come first
 // Requirements: 1.2, 7.3
 func (h *ChatHandler) GetConversations(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
@@ -154,8 +232,26 @@ func (h *ChatHandler) GetConversations(c *gin.Context) {
 		}
 	}
 
+	var filter database.ConversationFilter
+	if folder := c.Query("folder_id"); folder == "none" {
+		filter.Unfiled = true
+	} else if folder != "" {
+		folderID, err := strconv.ParseInt(folder, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid folder ID",
+				"validation_error",
+				"invalid_folder_id",
+			))
+			return
+		}
+		filter.FolderID = &folderID
+	}
+	filter.Tag = strings.Join(strings.Fields(c.Query("tag")), " ")
+	filter.IncludeArchived = c.Query("include_archived") == "true"
+
 	// Get conversations from database
-	conversations, total, err := database.GetConversations(userID, page, limit)
+	conversations, total, err := database.GetConversations(userID, filter, page, limit)
 	if err != nil {
 		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get conversations")
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
@@ -199,6 +295,9 @@ func (h *ChatHandler) GetConversation(c *gin.Context) {
 
 	// Get conversation from database
 	conv, err := database.GetConversation(convID, userID)
+	if err == nil {
+		conv.Tags, err = database.GetConversationTags(convID)
+	}
 	if err != nil {
 		if err == database.ErrConversationNotFound {
 			c.JSON(http.StatusNotFound, models.NewErrorResponse(
@@ -226,7 +325,7 @@ func (h *ChatHandler) GetConversation(c *gin.Context) {
 	})
 }
 
-// UpdateConversation updates a conversation's title and/or model
+// UpdateConversation updates a conversation's title, model, system prompt and/or settings
 // PUT /api/chat/conversations/:id
 // Requirements: 1.5
 func (h *ChatHandler) UpdateConversation(c *gin.Context) {
@@ -255,6 +354,9 @@ func (h *ChatHandler) UpdateConversation(c *gin.Context) {
 		))
 		return
 	}
+	if !validMaxCost(c, req.MaxCost) {
+		return
+	}
 
 	// Get existing conversation to preserve unchanged fields
 	existingConv, err := database.GetConversation(convID, userID)
@@ -300,8 +402,41 @@ func (h *ChatHandler) UpdateConversation(c *gin.Context) {
 		}
 	}
 
+	var systemPrompt string
+	if req.SystemPrompt != nil || req.TemplateID != nil {
+		prompt := ""
+		if req.SystemPrompt != nil {
+			prompt = *req.SystemPrompt
+		}
+		resolved, ok := resolveSystemPrompt(c, userID, prompt, req.TemplateID)
+		if !ok {
+			return
+		}
+		systemPrompt = resolved
+	}
+
 	// Update conversation in database
 	err = database.UpdateConversation(convID, userID, title, model)
+	if err == nil && (req.SystemPrompt != nil || req.TemplateID != nil) {
+		err = database.SetConversationSystemPrompt(convID, userID, systemPrompt, req.TemplateID)
+	}
+	if err == nil && req.MaxCost != nil {
+		maxCost := req.MaxCost
+		if *maxCost == 0 {
+			maxCost = nil
+		}
+		err = database.SetConversationMaxCost(convID, userID, maxCost)
+	}
+	if err == nil && (req.Deterministic != nil || req.Seed != nil) {
+		deterministic, seed := existingConv.Deterministic, existingConv.Seed
+		if req.Deterministic != nil {
+			deterministic = *req.Deterministic
+		}
+		if req.Seed != nil {
+			seed = req.Seed
+		}
+		err = database.SetConversationDeterministic(convID, userID, deterministic, seed)
+	}
 	if err != nil {
 		if err == database.ErrConversationNotFound {
 			c.JSON(http.StatusNotFound, models.NewErrorResponse(
@@ -551,62 +686,150 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Send message using chat service
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
-	defer cancel()
+	// Send message using chat service, with the timeout configured for the model
+	// (the conversation's model when none is given) or requested via X-Request-Timeout.
+	// The reply outlives a dropped connection so the client can resume it
+	requestedTimeout, ok := middleware.RequestedTimeout(c)
+	if !ok {
+		return
+	}
+	timeout := chatReplyTimeout(userID, convID, req.Model, requestedTimeout)
+	c.Header(middleware.RequestTimeoutHeader, strconv.Itoa(int(timeout/time.Second)))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), timeout)
 
 	response, err := h.chatService.SendMessage(ctx, services.SendMessageRequest{
 		ConversationID: convID,
 		UserID:         userID,
 		Content:        req.Content,
 		Model:          req.Model,
+		AttachmentIDs:  req.AttachmentIDs,
+	})
+	if err != nil {
+		cancel()
+		h.handleSendMessageError(c, err, userID, convID, middleware.EstimateRequestTokens(req.Model, req.Content, 0))
+		return
+	}
+
+	h.streamResumableReply(c, ctx, cancel, userID, convID, req, response)
+}
+
+// RegenerateMessageRequest represents the optional body of a regenerate request
+type RegenerateMessageRequest struct {
+	Model string `json:"model"` // Optional: model for the new reply, defaults to the conversation's
+}
+
+// RegenerateMessage replaces the conversation's latest assistant reply with a new one, streamed
+// via SSE like SendMessage. The old reply is hidden once the new one is saved (its cost stays
+// in the conversation total) and the new reply is billed and recorded as usual; the "done"
+// event carries replaces_message_id
+// POST /api/chat/conversations/:id/messages/:msgId/regenerate
+func (h *ChatHandler) RegenerateMessage(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid conversation ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return
+	}
+	msgID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid message ID",
+			"validation_error",
+			"invalid_message_id",
+		))
+		return
+	}
+
+	var req RegenerateMessageRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid request format: "+err.Error(),
+				"validation_error",
+				"invalid_request",
+			))
+			return
+		}
+	}
+	if req.Model != "" && !h.config.IsValidModel(req.Model) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid model specified: "+req.Model,
+			"validation_error",
+			"invalid_model",
+		))
+		return
+	}
+
+	requestedTimeout, ok := middleware.RequestedTimeout(c)
+	if !ok {
+		return
+	}
+	timeout := chatReplyTimeout(userID, convID, req.Model, requestedTimeout)
+	c.Header(middleware.RequestTimeoutHeader, strconv.Itoa(int(timeout/time.Second)))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), timeout)
+
+	response, err := h.chatService.RegenerateMessage(ctx, services.RegenerateRequest{
+		ConversationID: convID,
+		UserID:         userID,
+		MessageID:      msgID,
+		Model:          req.Model,
 	})
 	if err != nil {
-		h.handleSendMessageError(c, err, userID, convID)
+		cancel()
+		h.handleSendMessageError(c, err, userID, convID, 0)
 		return
 	}
 
-	// Set up SSE response headers
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("X-Accel-Buffering", "no")
+	// The user message's content is what the prompt is counted from when the provider reports no usage
+	reply := SendMessageRequest{Content: response.UserMessage.Content, Model: req.Model}
+	h.streamResumableReply(c, ctx, cancel, userID, convID, reply, response)
+}
 
+// chatReplyTimeout resolves the timeout for a reply from the requested model, falling back
+// to the conversation's model when none is given
+func chatReplyTimeout(userID, convID int64, model string, requested int) time.Duration {
+	if model == "" {
+		if conv, err := database.GetConversation(convID, userID); err == nil {
+			model = conv.Model
+		}
+	}
+	return middleware.ResolveRequestTimeout(model, requested)
+}
+
+// streamReply relays the AI response to the client through emit, then saves the assistant
+// message, deducts balance and records usage. Shared by the SSE and WebSocket transports.
+// Requirements: 2.1, 2.2, 2.4, 2.5
+func (h *ChatHandler) streamReply(ctx context.Context, emit func(models.ChatStreamEvent), userID, convID int64, req SendMessageRequest, response *services.SendMessageResponse) {
 	// Send start event with user message ID
 	startEvent := models.ChatStreamEvent{
 		Type:      "start",
 		MessageID: response.UserMessage.ID,
 	}
-	sendSSEEvent(c, startEvent)
+	emit(startEvent)
 
 	// Stream AI response
 	var fullContent strings.Builder
 	var totalPromptTokens, totalCompletionTokens int
+	var artifacts services.ArtifactScanner
 
+stream:
 	for event := range response.StreamChan {
 		select {
 		case <-ctx.Done():
+			if response.Stopped() {
+				break stream
+			}
 			// Context cancelled or timeout, send error event
 			// Requirements: 2.5 - Handle stream errors gracefully
-			var errorMsg string
-			if ctx.Err() == context.DeadlineExceeded {
-				errorMsg = "Request timed out. Please try again."
-				logrus.WithFields(logrus.Fields{
-					"user_id":         userID,
-					"conversation_id": convID,
-				}).Warn("Chat stream timeout")
-			} else {
-				errorMsg = "Request was cancelled"
-				logrus.WithFields(logrus.Fields{
-					"user_id":         userID,
-					"conversation_id": convID,
-				}).Info("Chat stream cancelled by client")
-			}
-			errorEvent := models.ChatStreamEvent{
-				Type:  "error",
-				Error: errorMsg,
-			}
-			sendSSEEvent(c, errorEvent)
+			emit(interruptedEvent(ctx, userID, convID))
 			return
 		default:
 			// Process unified StreamEvent format
@@ -623,7 +846,8 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 					Type:  "content",
 					Delta: event.Content,
 				}
-				sendSSEEvent(c, contentEvent)
+				emit(contentEvent)
+				sendArtifactEvents(emit, artifacts.Write(event.Content))
 			case "usage":
 				// Token usage information (Requirements: 9.1)
 				if event.Tokens != nil {
@@ -631,6 +855,10 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 					totalCompletionTokens = event.Tokens.CompletionTokens
 				}
 			case "error":
+				// Providers may report the cancelled request after the user stopped generation
+				if response.Stopped() {
+					break stream
+				}
 				// Error event
 				logrus.WithFields(logrus.Fields{
 					"user_id":         userID,
@@ -641,7 +869,7 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 					Type:  "error",
 					Error: event.Error,
 				}
-				sendSSEEvent(c, errorEvent)
+				emit(errorEvent)
 				return
 			case "done":
 				// Done event - will be handled after loop
@@ -650,6 +878,15 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		}
 	}
 
+	// The provider stream also ends early when the request times out or the client goes away
+	stopped := response.Stopped()
+	if !stopped && ctx.Err() != nil {
+		emit(interruptedEvent(ctx, userID, convID))
+		return
+	}
+
+	sendArtifactEvents(emit, artifacts.Flush())
+
 	// Get conversation to retrieve model info for billing
 	conv, convErr := database.GetConversation(convID, userID)
 	model := ""
@@ -661,6 +898,12 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		model = req.Model
 	}
 
+	// 上游未返回用量时按 tokenizer 计算
+	if totalPromptTokens == 0 && totalCompletionTokens == 0 {
+		totalPromptTokens = utils.CountTokens(model, req.Content)
+		totalCompletionTokens = utils.CountTokens(model, fullContent.String())
+	}
+
 	// Save assistant message to database (Requirements: 2.4)
 	totalTokens := totalPromptTokens + totalCompletionTokens
 	// Calculate cost using pricing service (Requirements: 9.3)
@@ -670,7 +913,17 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		cost = calculateCost(totalPromptTokens, totalCompletionTokens)
 	}
 
-	assistantMsg, err := h.chatService.SaveAssistantMessage(convID, fullContent.String(), totalTokens, cost)
+	// A reply stopped before any content arrived is not saved. It follows the user message it
+	// answers, even if the user has since switched to another branch
+	var assistantMsg *models.ChatMessage
+	var parentID *int64
+	if response.UserMessage != nil {
+		parentID = &response.UserMessage.ID
+	}
+	var err error
+	if !stopped || fullContent.Len() > 0 {
+		assistantMsg, err = h.chatService.SaveAssistantMessage(convID, fullContent.String(), totalPromptTokens, totalCompletionTokens, cost, response.Seed, stopped, parentID, model, response.Provider)
+	}
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"conversation_id": convID,
@@ -678,6 +931,24 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		// Still send done event even if save fails
 	}
 
+	// Name the conversation from its first exchange
+	if assistantMsg != nil && convErr == nil && conv.Title == services.DefaultConversationTitle {
+		h.chatService.AutoTitle(convID, userID)
+	}
+
+	// A regenerated reply replaces the previous one only once it has been saved
+	replaced := int64(0)
+	if assistantMsg != nil && response.Replaces != 0 {
+		if err := database.SupersedeMessage(response.Replaces, assistantMsg.ID); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"conversation_id": convID,
+				"message_id":      response.Replaces,
+			}).Error("Failed to supersede regenerated message")
+		} else {
+			replaced = response.Replaces
+		}
+	}
+
 	// Deduct balance after AI response (Requirements: 6.1)
 	if totalTokens > 0 {
 		_, deductErr := database.DeductBalance(userID, totalTokens, "chat", model)
@@ -716,9 +987,10 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		usageRecord := &database.UsageRecord{
 			UserID:           userID,
 			Username:         username,
-			APIToken:         "chat",
+			APIToken:         database.UsageTokenChat,
 			TokenName:        fmt.Sprintf("Online Chat (%s)", provider),
 			Model:            model,
+			Provider:         provider,
 			PromptTokens:     totalPromptTokens,
 			CompletionTokens: totalCompletionTokens,
 			TotalTokens:      totalTokens,
@@ -728,6 +1000,7 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 			RequestTime:      now,
 			ResponseTime:     now,
 			DurationMs:       0,
+			Seed:             response.Seed,
 		}
 
 		if insertErr := database.InsertUsageRecord(usageRecord); insertErr != nil {
@@ -755,12 +1028,79 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 			Prompt:     totalPromptTokens,
 			Completion: totalCompletionTokens,
 		},
-		Cost: cost,
+		Cost:     cost,
+		Stopped:  stopped,
+		Replaces: replaced,
 	}
 	if assistantMsg != nil {
 		doneEvent.MessageID = assistantMsg.ID
 	}
-	sendSSEEvent(c, doneEvent)
+	emit(doneEvent)
+}
+
+// interruptedEvent builds the "error" event for a reply whose request timed out or was
+// abandoned by the client
+func interruptedEvent(ctx context.Context, userID, convID int64) models.ChatStreamEvent {
+	logFields := logrus.Fields{
+		"user_id":         userID,
+		"conversation_id": convID,
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		logrus.WithFields(logFields).Warn("Chat stream timeout")
+		return models.ChatStreamEvent{Type: "error", Error: "Request timed out. Please try again.", Code: "request_timeout"}
+	}
+	logrus.WithFields(logFields).Info("Chat stream cancelled by client")
+	return models.ChatStreamEvent{Type: "error", Error: "Request was cancelled", Code: "request_cancelled"}
+}
+
+// StopGeneration stops the reply being generated for a user message. The provider stream is
+// cancelled, and the request streaming the reply saves what was generated so far marked as
+// stopped and finishes with a "done" event carrying stopped=true
+// POST /api/chat/conversations/:id/messages/:msgId/cancel
+func (h *ChatHandler) StopGeneration(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid conversation ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return
+	}
+	msgID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid message ID",
+			"validation_error",
+			"invalid_message_id",
+		))
+		return
+	}
+
+	if err := h.chatService.StopGeneration(userID, convID, msgID); err != nil {
+		// Already finished, never started or owned by another user
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"No reply is being generated for this message",
+			"not_found",
+			"generation_not_found",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"conversation_id": convID,
+		"message_id":      msgID,
+	}).Info("Chat generation stopped by user")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Generation stopped",
+	})
 }
 
 // ModelResponse represents a model in the API response
@@ -772,6 +1112,35 @@ type ModelResponse struct {
 	InputPrice    float64 `json:"input_price"`
 	OutputPrice   float64 `json:"output_price"`
 	IsAvailable   bool    `json:"is_available"`
+	// 不可用时的原因（provider_down / quota_exhausted / admin_disabled），供前端展示状态横幅
+	UnavailableReason string `json:"unavailable_reason,omitempty"`
+	IncidentID        string `json:"incident_id,omitempty"`
+	StatusMessage     string `json:"status_message,omitempty"`
+}
+
+// newModelResponse builds the API representation of a model, applying the availability snapshot
+func newModelResponse(model models.ModelInfo, availability *services.ModelAvailability) ModelResponse {
+	resp := ModelResponse{
+		ID:            model.ID,
+		Name:          model.Name,
+		Provider:      model.Provider,
+		ContextWindow: model.ContextWindow,
+		InputPrice:    model.InputPrice,
+		OutputPrice:   model.OutputPrice,
+		IsAvailable:   model.IsAvailable, // Requirements: 11.3, 11.5
+	}
+	if reason := availability.Check(model); reason != nil {
+		resp.IsAvailable = false
+		resp.UnavailableReason = reason.Reason
+		resp.IncidentID = reason.IncidentID
+		resp.StatusMessage = reason.Message
+	}
+	return resp
+}
+
+// quotaEnabled reports whether Cursor session quotas are enforced
+func (h *ChatHandler) quotaEnabled() bool {
+	return h.config != nil && h.config.Quota.Enabled
 }
 
 // ProviderModelsResponse represents models grouped by provider
@@ -799,21 +1168,14 @@ func (h *ChatHandler) GetModels(c *gin.Context) {
 func (h *ChatHandler) getModelsFromProviderRouter(c *gin.Context) {
 	// Get all models from provider router
 	allModels := h.providerRouter.GetAllModels()
+	availability := services.SnapshotModelAvailability(h.quotaEnabled())
 
 	// Group models by provider (Requirements: 11.4)
 	providerModels := make(map[string][]ModelResponse)
 	providerOrder := []string{} // Track order of providers
 
 	for _, model := range allModels {
-		modelResp := ModelResponse{
-			ID:            model.ID,
-			Name:          model.Name,
-			Provider:      model.Provider,
-			ContextWindow: model.ContextWindow,
-			InputPrice:    model.InputPrice,
-			OutputPrice:   model.OutputPrice,
-			IsAvailable:   model.IsAvailable, // Requirements: 11.3, 11.5
-		}
+		modelResp := newModelResponse(model, availability)
 
 		if _, exists := providerModels[model.Provider]; !exists {
 			providerOrder = append(providerOrder, model.Provider)
@@ -833,15 +1195,7 @@ func (h *ChatHandler) getModelsFromProviderRouter(c *gin.Context) {
 	// Also return flat list for backward compatibility
 	flatModels := make([]ModelResponse, 0, len(allModels))
 	for _, model := range allModels {
-		flatModels = append(flatModels, ModelResponse{
-			ID:            model.ID,
-			Name:          model.Name,
-			Provider:      model.Provider,
-			ContextWindow: model.ContextWindow,
-			InputPrice:    model.InputPrice,
-			OutputPrice:   model.OutputPrice,
-			IsAvailable:   model.IsAvailable,
-		})
+		flatModels = append(flatModels, newModelResponse(model, availability))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -857,6 +1211,7 @@ func (h *ChatHandler) getModelsFromProviderRouter(c *gin.Context) {
 func (h *ChatHandler) getModelsFromConfig(c *gin.Context) {
 	modelNames := h.config.GetModels()
 	modelList := make([]gin.H, 0, len(modelNames))
+	availability := services.SnapshotModelAvailability(h.quotaEnabled())
 
 	for _, modelID := range modelNames {
 		// Get model configuration info
@@ -883,6 +1238,16 @@ func (h *ChatHandler) getModelsFromConfig(c *gin.Context) {
 			modelInfo["output_price"] = pricing.OutputPrice
 		}
 
+		// Legacy models are served through Cursor sessions
+		if reason := availability.Check(models.ModelInfo{ID: modelID, Provider: "cursor", IsAvailable: true}); reason != nil {
+			modelInfo["is_available"] = false
+			modelInfo["unavailable_reason"] = reason.Reason
+			if reason.IncidentID != "" {
+				modelInfo["incident_id"] = reason.IncidentID
+				modelInfo["status_message"] = reason.Message
+			}
+		}
+
 		modelList = append(modelList, modelInfo)
 	}
 
@@ -898,7 +1263,42 @@ func (h *ChatHandler) getModelsFromConfig(c *gin.Context) {
 
 // handleSendMessageError handles errors from SendMessage and returns appropriate HTTP responses
 // Requirements: 2.5, 10.1-10.5 - Display error message and allow retry
-func (h *ChatHandler) handleSendMessageError(c *gin.Context, err error, userID, convID int64) {
+func (h *ChatHandler) handleSendMessageError(c *gin.Context, err error, userID, convID int64, estimatedTokens int) {
+	// The spend ceiling is reported as an SSE error event so the chat UI can show it inline
+	if event, ok := costLimitEvent(err, userID, convID); ok {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		sendSSEEvent(c, event)
+		return
+	}
+
+	c.JSON(sendMessageErrorResponse(c, err, userID, convID, estimatedTokens))
+}
+
+// costLimitEvent builds the "error" stream event for a conversation that reached its spending limit
+func costLimitEvent(err error, userID, convID int64) (models.ChatStreamEvent, bool) {
+	var limitErr *services.ConversationCostLimitError
+	if !errors.As(err, &limitErr) {
+		return models.ChatStreamEvent{}, false
+	}
+	logrus.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"conversation_id": convID,
+		"error":           err.Error(),
+	}).Info("Conversation cost limit reached")
+	return models.ChatStreamEvent{
+		Type: "error",
+		Error: fmt.Sprintf("This conversation has reached its spending limit of $%.4f (spent $%.4f). Raise the limit or start a new conversation.",
+			limitErr.Limit, limitErr.Spent),
+		Code: "conversation_cost_limit",
+		Cost: limitErr.Spent,
+	}, true
+}
+
+// sendMessageErrorResponse maps a SendMessage error to its HTTP status and error body
+func sendMessageErrorResponse(c *gin.Context, err error, userID, convID int64, estimatedTokens int) (int, *models.ErrorResponse) {
 	logFields := logrus.Fields{
 		"user_id":         userID,
 		"conversation_id": convID,
@@ -908,123 +1308,163 @@ func (h *ChatHandler) handleSendMessageError(c *gin.Context, err error, userID,
 	switch {
 	case err == services.ErrConversationNotFound:
 		logrus.WithFields(logFields).Warn("Conversation not found")
-		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+		return http.StatusNotFound, models.NewErrorResponse(
 			"Conversation not found",
 			"not_found",
 			"conversation_not_found",
-		))
+		)
+
+	case err == services.ErrAttachmentNotFound, err == services.ErrTooManyAttachments:
+		logrus.WithFields(logFields).Info("Invalid message attachments")
+		return http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"validation_error",
+			"invalid_attachments",
+		)
+
+	case err == services.ErrMessageNotFound:
+		logrus.WithFields(logFields).Warn("Message not found")
+		return http.StatusNotFound, models.NewErrorResponse(
+			"Message not found",
+			"not_found",
+			"message_not_found",
+		)
+
+	case err == services.ErrMessageNotRegenerable:
+		logrus.WithFields(logFields).Info("Message cannot be regenerated")
+		return http.StatusConflict, models.NewErrorResponse(
+			"Only the latest assistant reply can be regenerated",
+			"validation_error",
+			"message_not_regenerable",
+		)
+
+	case err == services.ErrMessageNotEditable:
+		logrus.WithFields(logFields).Info("Message cannot be edited")
+		return http.StatusConflict, models.NewErrorResponse(
+			"Only your messages on the current branch can be edited",
+			"validation_error",
+			"message_not_editable",
+		)
+
+	case err == services.ErrGenerationInProgress:
+		logrus.WithFields(logFields).Info("Reply already being generated")
+		return http.StatusConflict, models.NewErrorResponse(
+			"A reply is already being generated for this message",
+			"validation_error",
+			"generation_in_progress",
+		)
 
 	case err == services.ErrEmptyMessage:
 		logrus.WithFields(logFields).Warn("Empty message content")
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+		return http.StatusBadRequest, models.NewErrorResponse(
 			"Message content cannot be empty",
 			"validation_error",
 			"empty_content",
-		))
+		)
 
 	case err == services.ErrInsufficientBalance:
 		// Requirements: 6.2 - Return 402 error if insufficient balance
 		logrus.WithFields(logFields).Info("Insufficient balance for chat")
-		c.JSON(http.StatusPaymentRequired, models.NewErrorResponse(
+		return http.StatusPaymentRequired, models.NewInsufficientBalanceResponse(
 			"Insufficient balance. Please recharge your account to continue.",
-			"payment_required",
 			"insufficient_balance",
-		))
+			middleware.NewBalanceGuidance(c, userID, estimatedTokens),
+		)
 
 	case err == services.ErrAIServiceUnavailable:
 		logrus.WithFields(logFields).Error("AI service unavailable")
-		c.JSON(http.StatusBadGateway, models.NewErrorResponse(
+		return http.StatusBadGateway, models.NewErrorResponse(
 			"AI service is temporarily unavailable. Please try again later.",
 			"service_unavailable",
 			"ai_service_unavailable",
-		))
+		)
 
 	case err == services.ErrAIServiceTimeout:
 		logrus.WithFields(logFields).Error("AI service timeout")
-		c.JSON(http.StatusGatewayTimeout, models.NewErrorResponse(
+		return http.StatusGatewayTimeout, models.NewErrorResponse(
 			"AI service request timed out. Please try again.",
 			"timeout",
 			"ai_service_timeout",
-		))
+		)
 
 	case err == services.ErrInvalidModel:
 		logrus.WithFields(logFields).Warn("Invalid model specified")
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+		return http.StatusBadRequest, models.NewErrorResponse(
 			"Invalid model specified",
 			"validation_error",
 			"invalid_model",
-		))
+		)
 
 	case err == services.ErrUnauthorized:
 		logrus.WithFields(logFields).Warn("Unauthorized access to conversation")
-		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+		return http.StatusForbidden, models.NewErrorResponse(
 			"You do not have access to this conversation",
 			"forbidden",
 			"unauthorized_access",
-		))
+		)
 
 	// Provider-specific errors (Requirements: 10.1-10.5)
 	case err == services.ErrProviderNotAvailable:
 		logrus.WithFields(logFields).Warn("Provider not available")
-		c.JSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+		return http.StatusServiceUnavailable, models.NewErrorResponse(
 			"The selected AI provider is not available. Please configure the API key or choose a different model.",
 			"provider_not_available",
 			"PROVIDER_NOT_AVAILABLE",
-		))
+		)
 
 	case err == services.ErrInvalidAPIKey:
 		// Requirements: 10.1 - Handle 401 errors
 		logrus.WithFields(logFields).Error("Invalid API key")
-		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(
+		return http.StatusUnauthorized, models.NewErrorResponse(
 			"API key is invalid or expired. Please contact administrator.",
 			"invalid_api_key",
 			"INVALID_API_KEY",
-		))
+		)
 
 	case err == services.ErrRateLimited:
 		// Requirements: 10.2 - Handle 429 errors
 		logrus.WithFields(logFields).Warn("Rate limited by provider")
-		c.JSON(http.StatusTooManyRequests, models.NewErrorResponse(
+		return http.StatusTooManyRequests, models.NewErrorResponse(
 			"Rate limit exceeded, please try again later.",
 			"rate_limited",
 			"RATE_LIMITED",
-		))
+		)
 
 	case err == services.ErrProviderError:
 		// Requirements: 10.3 - Handle 500-599 errors
 		logrus.WithFields(logFields).Error("Provider error")
-		c.JSON(http.StatusBadGateway, models.NewErrorResponse(
+		return http.StatusBadGateway, models.NewErrorResponse(
 			"AI service temporarily unavailable. Please try again later.",
 			"provider_error",
 			"PROVIDER_ERROR",
-		))
+		)
 
 	case err == services.ErrTimeout:
 		// Requirements: 10.4 - Handle timeout errors
 		logrus.WithFields(logFields).Error("Provider timeout")
-		c.JSON(http.StatusGatewayTimeout, models.NewErrorResponse(
+		return http.StatusGatewayTimeout, models.NewErrorResponse(
 			"Request timed out. Please try again.",
 			"timeout",
 			"TIMEOUT",
-		))
+		)
 
 	case err == services.ErrContextTooLong:
 		// Requirements: 10.5 - Handle context length errors
 		logrus.WithFields(logFields).Warn("Context too long")
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+		return http.StatusBadRequest, models.NewErrorResponse(
 			"Message too long for this model. Please reduce the conversation length.",
 			"context_too_long",
 			"CONTEXT_TOO_LONG",
-		))
+		)
 
 	default:
 		// Generic error - log full details for debugging
 		logrus.WithError(err).WithFields(logFields).Error("Failed to send message")
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+		return http.StatusInternalServerError, models.NewErrorResponse(
 			"Failed to send message. Please try again.",
 			"internal_error",
 			"ai_service_error",
-		))
+		)
 	}
 }
 
@@ -1040,6 +1480,16 @@ func sendSSEEvent(c *gin.Context, event models.ChatStreamEvent) {
 	c.Writer.(http.Flusher).Flush()
 }
 
+// sendArtifactEvents sends an "artifact" event for each completed code artifact
+func sendArtifactEvents(emit func(models.ChatStreamEvent), artifacts []models.ChatArtifact) {
+	for i := range artifacts {
+		emit(models.ChatStreamEvent{
+			Type:     "artifact",
+			Artifact: &artifacts[i],
+		})
+	}
+}
+
 // calculateCost calculates the cost based on token usage
 // This is a simplified calculation - in production, use model-specific pricing
 func calculateCost(promptTokens, completionTokens int) float64 {
@@ -1054,6 +1504,9 @@ func streamResponseFromChannel(c *gin.Context, streamChan <-chan interface{}, co
 	var fullContent strings.Builder
 	var totalPromptTokens, totalCompletionTokens int
 
+	var artifacts services.ArtifactScanner
+	emit := func(event models.ChatStreamEvent) { sendSSEEvent(c, event) }
+
 	// Create a buffered writer for SSE
 	writer := bufio.NewWriter(c.Writer)
 	defer writer.Flush()
@@ -1070,6 +1523,7 @@ func streamResponseFromChannel(c *gin.Context, streamChan <-chan interface{}, co
 			fmt.Fprintf(writer, "data: %s\n\n", data)
 			writer.Flush()
 			c.Writer.(http.Flusher).Flush()
+			sendArtifactEvents(emit, artifacts.Write(v))
 
 		case map[string]interface{}:
 			if errMsg, ok := v["error"].(string); ok {
@@ -1094,11 +1548,12 @@ func streamResponseFromChannel(c *gin.Context, streamChan <-chan interface{}, co
 		}
 	}
 
+	sendArtifactEvents(emit, artifacts.Flush())
+
 	// Save assistant message
-	totalTokens := totalPromptTokens + totalCompletionTokens
 	cost := calculateCost(totalPromptTokens, totalCompletionTokens)
 
-	assistantMsg, err := chatService.SaveAssistantMessage(convID, fullContent.String(), totalTokens, cost)
+	assistantMsg, err := chatService.SaveAssistantMessage(convID, fullContent.String(), totalPromptTokens, totalCompletionTokens, cost, nil, false, nil, "", "cursor")
 	if err != nil {
 		logrus.WithError(err).Error("Failed to save assistant message")
 	}
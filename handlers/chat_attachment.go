@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UploadAttachment extracts the text of a txt, md or pdf file uploaded as multipart field
+// "file". The returned attachment ID is passed in attachment_ids when sending a message;
+// attachments never sent are deleted after a day
+// POST /api/chat/attachments
+func (h *ChatHandler) UploadAttachment(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	maxBytes := h.config.ChatAttachmentMaxBytes
+	tooLarge := func() {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			"File exceeds the maximum size of "+strconv.FormatInt(maxBytes, 10)+" bytes",
+			"validation_error",
+			"attachment_too_large",
+		))
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+fileMultipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			tooLarge()
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Missing file: upload the content as multipart/form-data field 'file'",
+			"validation_error",
+			"missing_file",
+		))
+		return
+	}
+	if header.Size > maxBytes {
+		tooLarge()
+		return
+	}
+
+	filename := filepath.Base(header.Filename)
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	if !services.IsSupportedAttachment(filename) {
+		c.JSON(http.StatusUnsupportedMediaType, models.NewErrorResponse(
+			services.ErrUnsupportedAttachment.Error(),
+			"validation_error",
+			"unsupported_attachment",
+		))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to open uploaded attachment")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to read attachment",
+			"internal_error",
+			"attachment_upload_failed",
+		))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read uploaded attachment")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to read attachment",
+			"internal_error",
+			"attachment_upload_failed",
+		))
+		return
+	}
+
+	attachment, err := services.ExtractAttachment(filename, data, h.config.ChatAttachmentMaxChars)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse(
+			"Could not read "+filename+": "+err.Error(),
+			"validation_error",
+			"attachment_unreadable",
+		))
+		return
+	}
+	if err := database.CreateChatAttachment(userID, attachment); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to save chat attachment")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to save attachment",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    attachment,
+	})
+}
+
+// DeleteAttachment deletes an uploaded attachment that has not been sent yet
+// DELETE /api/chat/attachments/:attachmentId
+func (h *ChatHandler) DeleteAttachment(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	err = database.DeletePendingChatAttachment(c.Param("attachmentId"), userID)
+	if err == database.ErrChatAttachmentNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Attachment not found or already sent",
+			"not_found",
+			"attachment_not_found",
+		))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to delete chat attachment")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to delete attachment",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Attachment deleted",
+	})
+}
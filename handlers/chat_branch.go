@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// EditMessageRequest represents the body of an edit request
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+	Model   string `json:"model"` // Optional: model for the reply, defaults to the conversation's
+}
+
+// SwitchBranchRequest selects the branch to continue the conversation on
+type SwitchBranchRequest struct {
+	MessageID int64 `json:"message_id" binding:"required"` // Any message of the branch, usually its leaf
+}
+
+// EditMessage saves an edited copy of a user message as a new branch of the conversation and
+// streams the reply via SSE like SendMessage. The original message and its replies are kept
+// and can be switched back to with SwitchBranch
+// POST /api/chat/conversations/:id/messages/:msgId/edit
+func (h *ChatHandler) EditMessage(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, msgID, ok := parseConversationMessageIDs(c)
+	if !ok {
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Message content cannot be empty",
+			"validation_error",
+			"empty_content",
+		))
+		return
+	}
+	if req.Model != "" && !h.config.IsValidModel(req.Model) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid model specified: "+req.Model,
+			"validation_error",
+			"invalid_model",
+		))
+		return
+	}
+
+	requestedTimeout, ok := middleware.RequestedTimeout(c)
+	if !ok {
+		return
+	}
+	timeout := chatReplyTimeout(userID, convID, req.Model, requestedTimeout)
+	c.Header(middleware.RequestTimeoutHeader, strconv.Itoa(int(timeout/time.Second)))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), timeout)
+
+	response, err := h.chatService.EditMessage(ctx, services.EditMessageRequest{
+		ConversationID: convID,
+		UserID:         userID,
+		MessageID:      msgID,
+		Content:        req.Content,
+		Model:          req.Model,
+	})
+	if err != nil {
+		cancel()
+		h.handleSendMessageError(c, err, userID, convID, middleware.EstimateRequestTokens(req.Model, req.Content, 0))
+		return
+	}
+
+	reply := SendMessageRequest{Content: req.Content, Model: req.Model}
+	h.streamResumableReply(c, ctx, cancel, userID, convID, reply, response)
+}
+
+// GetBranches lists the branches of a conversation, one per leaf message, with the active one marked
+// GET /api/chat/conversations/:id/branches
+func (h *ChatHandler) GetBranches(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	branches, err := database.ListConversationBranches(convID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to list conversation branches")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to retrieve branches",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"branches": branches,
+		},
+	})
+}
+
+// SwitchBranch makes the branch through the given message the active one, following its most
+// recent replies to the end, and returns the branch's messages
+// PUT /api/chat/conversations/:id/branch
+func (h *ChatHandler) SwitchBranch(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	var req SwitchBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	leafID, err := database.SwitchConversationBranch(convID, req.MessageID)
+	if errors.Is(err, database.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Message not found",
+			"not_found",
+			"message_not_found",
+		))
+		return
+	}
+	var messages []models.ChatMessage
+	if err == nil {
+		messages, err = database.GetAllMessages(convID)
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+			"message_id":      req.MessageID,
+		}).Error("Failed to switch conversation branch")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to switch branch",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"leaf_message_id": leafID,
+			"messages":        messages,
+		},
+	})
+}
+
+// parseConversationMessageIDs parses the :id and :msgId path parameters, writing a 400
+// response when either is invalid
+func parseConversationMessageIDs(c *gin.Context) (int64, int64, bool) {
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid conversation ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return 0, 0, false
+	}
+	msgID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid message ID",
+			"validation_error",
+			"invalid_message_id",
+		))
+		return 0, 0, false
+	}
+	return convID, msgID, true
+}
+
+// parseOwnedConversationID parses the :id path parameter and checks the conversation belongs
+// to the user, writing the error response when it doesn't
+func parseOwnedConversationID(c *gin.Context, userID int64) (int64, bool) {
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid conversation ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return 0, false
+	}
+
+	belongs, err := database.ConversationBelongsToUser(convID, userID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to verify conversation ownership")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to verify conversation ownership",
+			"internal_error",
+			"database_error",
+		))
+		return 0, false
+	}
+	if !belongs {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Conversation not found",
+			"not_found",
+			"conversation_not_found",
+		))
+		return 0, false
+	}
+	return convID, true
+}
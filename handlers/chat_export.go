@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Conversation export formats
+const (
+	exportFormatMarkdown = "markdown"
+	exportFormatJSON     = "json"
+)
+
+// exportChunkSize is how much export output is buffered before it is sent
+const exportChunkSize = 32 * 1024
+
+// exportRoleLabels are the headings of each message role in Markdown exports
+var exportRoleLabels = map[string]string{
+	"user":      "User",
+	"assistant": "Assistant",
+	"system":    "System",
+}
+
+// ExportConversation streams the conversation's active branch as a Markdown or JSON
+// download, with each message's role, timestamp, tokens and cost
+// GET /api/chat/conversations/:id/export
+// Query params: format (markdown or json, default markdown)
+func (h *ChatHandler) ExportConversation(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", exportFormatMarkdown))
+	if format == "md" {
+		format = exportFormatMarkdown
+	}
+	if format != exportFormatMarkdown && format != exportFormatJSON {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid format, must be markdown or json",
+			"validation_error",
+			"invalid_format",
+		))
+		return
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+	conv, err := database.GetConversation(convID, userID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to get conversation for export")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to export conversation",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	messages, err := database.GetAllMessages(convID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to get messages for export")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to export conversation",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	exportedAt := time.Now().UTC()
+	filename := fmt.Sprintf("conversation-%d.md", conv.ID)
+	contentType := "text/markdown; charset=utf-8"
+	if format == exportFormatJSON {
+		filename = fmt.Sprintf("conversation-%d.json", conv.ID)
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	w := bufio.NewWriter(c.Writer)
+	if format == exportFormatJSON {
+		err = writeConversationJSON(w, conv, messages, exportedAt)
+	} else {
+		err = writeConversationMarkdown(w, conv, messages, exportedAt)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// Headers are already sent, the client gets a truncated file
+		logrus.WithError(err).WithField("conversation_id", convID).Warn("Failed to write conversation export")
+	}
+}
+
+// writeConversationJSON writes {"conversation", "exported_at", "messages"}, one message at a time
+func writeConversationJSON(w *bufio.Writer, conv *models.Conversation, messages []models.ChatMessage, exportedAt time.Time) error {
+	header, err := json.Marshal(conv)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, `{"conversation":%s,"exported_at":%q,"messages":[`, header, exportedAt.Format(time.RFC3339))
+	for i, msg := range messages {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString("\n  ")
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if err := flushExportChunk(w); err != nil {
+			return err
+		}
+	}
+	_, err = w.WriteString("\n]}\n")
+	return err
+}
+
+// writeConversationMarkdown writes a title, a summary list and one section per message
+func writeConversationMarkdown(w *bufio.Writer, conv *models.Conversation, messages []models.ChatMessage, exportedAt time.Time) error {
+	const timeLayout = "2006-01-02 15:04:05 UTC"
+
+	fmt.Fprintf(w, "# %s\n\n", conv.Title)
+	fmt.Fprintf(w, "- Model: %s\n", conv.Model)
+	fmt.Fprintf(w, "- Created: %s\n", conv.CreatedAt.UTC().Format(timeLayout))
+	fmt.Fprintf(w, "- Messages: %d\n", len(messages))
+	fmt.Fprintf(w, "- Total cost: $%.6f\n", conv.TotalCost)
+	fmt.Fprintf(w, "- Exported: %s\n", exportedAt.Format(timeLayout))
+	if conv.SystemPrompt != "" {
+		fmt.Fprintf(w, "\n## System prompt\n\n%s\n", conv.SystemPrompt)
+	}
+
+	for _, msg := range messages {
+		label := exportRoleLabels[msg.Role]
+		if label == "" {
+			label = msg.Role
+		}
+		fmt.Fprintf(w, "\n---\n\n### %s · %s\n\n", label, msg.CreatedAt.UTC().Format(timeLayout))
+		details := fmt.Sprintf("tokens: %d · cost: $%.6f", msg.Tokens, msg.Cost)
+		if msg.Stopped {
+			details += " · stopped"
+		}
+		fmt.Fprintf(w, "_%s_\n\n%s\n", details, strings.TrimRight(msg.Content, "\n"))
+		if err := flushExportChunk(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushExportChunk sends buffered output once it exceeds exportChunkSize, so long
+// conversations reach the client as they are written
+func flushExportChunk(w *bufio.Writer) error {
+	if w.Buffered() < exportChunkSize {
+		return nil
+	}
+	return w.Flush()
+}
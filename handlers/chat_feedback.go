@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// MessageFeedbackRequest represents the body of a feedback request
+type MessageFeedbackRequest struct {
+	Rating  string `json:"rating" binding:"required"` // "up" or "down"
+	Comment string `json:"comment"`
+}
+
+// parseFeedbackMessageID parses the :id route parameter of a feedback request, or sends an error
+func parseFeedbackMessageID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid message ID",
+			"validation_error",
+			"invalid_message_id",
+		))
+		return 0, false
+	}
+	return id, true
+}
+
+// SubmitFeedback rates one of the user's assistant replies with a thumbs up or down and an
+// optional comment, replacing an earlier rating. The model and provider that produced the
+// reply are stored with the rating for the admin analytics
+// POST /api/chat/messages/:id/feedback
+func (h *ChatHandler) SubmitFeedback(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+	msgID, ok := parseFeedbackMessageID(c)
+	if !ok {
+		return
+	}
+
+	var req MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if !database.IsValidFeedbackRating(req.Rating) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			`Rating must be "up" or "down"`,
+			"validation_error",
+			"invalid_rating",
+		))
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if utf8.RuneCountInString(req.Comment) > database.MaxFeedbackCommentLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Comment must be at most "+strconv.Itoa(database.MaxFeedbackCommentLength)+" characters",
+			"validation_error",
+			"invalid_comment",
+		))
+		return
+	}
+
+	target, err := database.GetFeedbackTarget(msgID, userID)
+	if err == database.ErrFeedbackNotAllowed {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Message not found",
+			"not_found",
+			"message_not_found",
+		))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to look up rated message")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to save feedback",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	// Replies saved before models were recorded are attributed to the conversation's model
+	model, provider := target.Model, target.Provider
+	if model == "" {
+		model = target.ConvModel
+	}
+	if provider == "" {
+		provider = services.GetProviderFromModel(model)
+	}
+
+	feedback, err := database.SetMessageFeedback(msgID, userID, req.Rating, req.Comment, model, provider)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to save message feedback")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to save feedback",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    feedback,
+	})
+}
+
+// DeleteFeedback withdraws the user's rating of a reply
+// DELETE /api/chat/messages/:id/feedback
+func (h *ChatHandler) DeleteFeedback(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+	msgID, ok := parseFeedbackMessageID(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteMessageFeedback(msgID, userID); err != nil {
+		logrus.WithError(err).Error("Failed to delete message feedback")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to delete feedback",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Feedback deleted",
+	})
+}
+
+// AdminGetFeedbackStats 按模型与提供商汇总聊天回复的赞/踩反馈，并附最近的评论，用于调整路由
+// 查询参数：days（统计天数，默认 30，最多 365）、model（仅统计指定模型）、rating（评论筛选 up/down）
+// GET /admin/chat/feedback
+func AdminGetFeedbackStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("days 须为 1-365 的整数", "validation_error", "invalid_days"))
+		return
+	}
+	rating := c.Query("rating")
+	if rating != "" && !database.IsValidFeedbackRating(rating) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("rating 须为 up 或 down", "validation_error", "invalid_rating"))
+		return
+	}
+	model := c.Query("model")
+	since := time.Now().AddDate(0, 0, -days)
+
+	summary, err := database.GetFeedbackSummary(since, model)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to aggregate message feedback")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "feedback_stats_failed"))
+		return
+	}
+	comments, err := database.ListFeedbackComments(since, model, rating, 50)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list feedback comments")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "feedback_stats_failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":     days,
+		"summary":  summary,
+		"comments": comments,
+	})
+}
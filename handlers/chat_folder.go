@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ChatFolderRequest represents the body of a create or rename folder request
+type ChatFolderRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MoveConversationRequest represents the body of a move request
+type MoveConversationRequest struct {
+	FolderID *int64 `json:"folder_id"` // Target folder, null to unfile the conversation
+}
+
+// PinConversationRequest represents the body of a pin request
+type PinConversationRequest struct {
+	Pinned *bool `json:"pinned" binding:"required"`
+}
+
+// ArchiveConversationRequest represents the body of an archive request
+type ArchiveConversationRequest struct {
+	Archived *bool `json:"archived" binding:"required"`
+}
+
+// SetConversationTagsRequest represents the body of a set tags request
+type SetConversationTagsRequest struct {
+	Tags []string `json:"tags"` // Replaces all of the conversation's tags; empty removes them
+}
+
+// bindChatFolderName binds a folder request and returns the trimmed name, or sends an error
+func bindChatFolderName(c *gin.Context) (string, bool) {
+	var req ChatFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > database.MaxChatFolderNameLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			fmt.Sprintf("Folder name must be 1-%d characters", database.MaxChatFolderNameLength),
+			"validation_error",
+			"invalid_folder_name",
+		))
+		return "", false
+	}
+	return name, true
+}
+
+// parseChatFolderID parses the :folderId route parameter, or sends an error
+func parseChatFolderID(c *gin.Context) (int64, bool) {
+	folderID, err := strconv.ParseInt(c.Param("folderId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid folder ID",
+			"validation_error",
+			"invalid_folder_id",
+		))
+		return 0, false
+	}
+	return folderID, true
+}
+
+// writeChatFolderError sends the response for a failed folder operation
+func writeChatFolderError(c *gin.Context, err error, userID int64, action string) {
+	switch err {
+	case database.ErrChatFolderNotFound:
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Folder not found",
+			"not_found",
+			"folder_not_found",
+		))
+	case database.ErrChatFolderExists:
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"A folder with this name already exists",
+			"validation_error",
+			"folder_exists",
+		))
+	default:
+		logrus.WithError(err).WithField("user_id", userID).Errorf("Failed to %s", action)
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to "+action,
+			"internal_error",
+			"database_error",
+		))
+	}
+}
+
+// GetFolders lists the current user's folders with their conversation counts
+// GET /api/chat/folders
+func (h *ChatHandler) GetFolders(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	folders, err := database.ListChatFolders(userID)
+	if err != nil {
+		writeChatFolderError(c, err, userID, "retrieve folders")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"folders": folders,
+		},
+	})
+}
+
+// CreateFolder creates an empty folder
+// POST /api/chat/folders
+func (h *ChatHandler) CreateFolder(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	name, ok := bindChatFolderName(c)
+	if !ok {
+		return
+	}
+
+	folder, err := database.CreateChatFolder(userID, name)
+	if err != nil {
+		writeChatFolderError(c, err, userID, "create folder")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    folder,
+	})
+}
+
+// RenameFolder renames a folder
+// PUT /api/chat/folders/:folderId
+func (h *ChatHandler) RenameFolder(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	folderID, ok := parseChatFolderID(c)
+	if !ok {
+		return
+	}
+	name, ok := bindChatFolderName(c)
+	if !ok {
+		return
+	}
+
+	if err := database.RenameChatFolder(folderID, userID, name); err != nil {
+		writeChatFolderError(c, err, userID, "rename folder")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Folder renamed",
+	})
+}
+
+// DeleteFolder deletes a folder; its conversations are kept and become unfiled
+// DELETE /api/chat/folders/:folderId
+func (h *ChatHandler) DeleteFolder(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	folderID, ok := parseChatFolderID(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteChatFolder(folderID, userID); err != nil {
+		writeChatFolderError(c, err, userID, "delete folder")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Folder deleted",
+	})
+}
+
+// MoveConversation files a conversation in a folder, or unfiles it with a null folder_id
+// PUT /api/chat/conversations/:id/folder
+func (h *ChatHandler) MoveConversation(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	var req MoveConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	if err := database.MoveConversationToFolder(convID, userID, req.FolderID); err != nil {
+		writeChatFolderError(c, err, userID, "move conversation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"folder_id": req.FolderID,
+		},
+	})
+}
+
+// SetTags replaces a conversation's tags
+// PUT /api/chat/conversations/:id/tags
+func (h *ChatHandler) SetTags(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	var req SetConversationTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	tags, err := database.NormalizeConversationTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"validation_error",
+			"invalid_tags",
+		))
+		return
+	}
+
+	if err := database.SetConversationTags(convID, userID, tags); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to set conversation tags")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to update tags",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"tags": tags,
+		},
+	})
+}
+
+// PinConversation pins a conversation to the top of the list, or unpins it
+// PUT /api/chat/conversations/:id/pin
+func (h *ChatHandler) PinConversation(c *gin.Context) {
+	var req PinConversationRequest
+	h.setConversationFlag(c, &req, "pinned", func(convID, userID int64) (bool, error) {
+		return *req.Pinned, database.SetConversationPinned(convID, userID, *req.Pinned)
+	})
+}
+
+// ArchiveConversation hides a conversation from the list (it stays reachable by ID, by
+// search and with include_archived), or restores it. Archiving also unpins the conversation
+// PUT /api/chat/conversations/:id/archive
+func (h *ChatHandler) ArchiveConversation(c *gin.Context) {
+	var req ArchiveConversationRequest
+	h.setConversationFlag(c, &req, "archived", func(convID, userID int64) (bool, error) {
+		return *req.Archived, database.SetConversationArchived(convID, userID, *req.Archived)
+	})
+}
+
+// setConversationFlag binds req for one of the user's conversations and applies set, which
+// returns the flag's new value
+func (h *ChatHandler) setConversationFlag(c *gin.Context, req interface{}, flag string, set func(convID, userID int64) (bool, error)) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	value, err := set(convID, userID)
+	if err == database.ErrConversationNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Conversation not found",
+			"not_found",
+			"conversation_not_found",
+		))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Errorf("Failed to set conversation %s flag", flag)
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to update conversation",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			flag: value,
+		},
+	})
+}
+
+// GetTags lists the tags in use on the current user's conversations with their counts
+// GET /api/chat/tags
+func (h *ChatHandler) GetTags(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	tags, err := database.ListChatTags(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to list conversation tags")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to retrieve tags",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"tags": tags,
+		},
+	})
+}
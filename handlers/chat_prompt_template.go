@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// PromptTemplateRequest represents the body of a create or update template request
+type PromptTemplateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Content     string `json:"content" binding:"required"`
+}
+
+// validate trims the request and returns an error message, or "" when it is valid
+func (r *PromptTemplateRequest) validate() string {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Content = strings.TrimSpace(r.Content)
+	switch {
+	case r.Name == "" || utf8.RuneCountInString(r.Name) > database.MaxPromptTemplateNameLength:
+		return fmt.Sprintf("Template name must be 1-%d characters", database.MaxPromptTemplateNameLength)
+	case utf8.RuneCountInString(r.Description) > database.MaxPromptTemplateDescriptionLength:
+		return fmt.Sprintf("Template description must be at most %d characters", database.MaxPromptTemplateDescriptionLength)
+	case r.Content == "":
+		return "Template content cannot be empty"
+	case utf8.RuneCountInString(r.Content) > database.MaxSystemPromptLength:
+		return fmt.Sprintf("Template content must be at most %d characters", database.MaxSystemPromptLength)
+	}
+	return ""
+}
+
+// bindPromptTemplate binds and validates a template request, or sends an error
+func bindPromptTemplate(c *gin.Context) (*PromptTemplateRequest, bool) {
+	var req PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return nil, false
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_template"))
+		return nil, false
+	}
+	return &req, true
+}
+
+// parsePromptTemplateID parses the :templateId route parameter, or sends an error
+func parsePromptTemplateID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("templateId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid template ID",
+			"validation_error",
+			"invalid_template_id",
+		))
+		return 0, false
+	}
+	return id, true
+}
+
+// writePromptTemplateError sends the response for a failed template operation
+func writePromptTemplateError(c *gin.Context, err error, action string) {
+	if err == database.ErrPromptTemplateNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Template not found",
+			"not_found",
+			"template_not_found",
+		))
+		return
+	}
+	logrus.WithError(err).Errorf("Failed to %s", action)
+	c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+		"Failed to "+action,
+		"internal_error",
+		"database_error",
+	))
+}
+
+// validSystemPrompt reports whether a requested system prompt fits, sending a 400 response if not
+func validSystemPrompt(c *gin.Context, prompt string) bool {
+	if utf8.RuneCountInString(prompt) > database.MaxSystemPromptLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			fmt.Sprintf("System prompt must be at most %d characters", database.MaxSystemPromptLength),
+			"validation_error",
+			"invalid_system_prompt",
+		))
+		return false
+	}
+	return true
+}
+
+// resolveSystemPrompt returns the system prompt a conversation request asks for: the content
+// of a template visible to the user, or the prompt given directly. ok is false when an error
+// response was sent
+func resolveSystemPrompt(c *gin.Context, userID int64, prompt string, templateID *int64) (string, bool) {
+	if templateID == nil {
+		return strings.TrimSpace(prompt), validSystemPrompt(c, prompt)
+	}
+	if prompt != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Specify either system_prompt or template_id, not both",
+			"validation_error",
+			"invalid_system_prompt",
+		))
+		return "", false
+	}
+	template, err := database.GetPromptTemplate(*templateID, userID)
+	if err != nil {
+		writePromptTemplateError(c, err, "retrieve template")
+		return "", false
+	}
+	return template.Content, true
+}
+
+// GetPromptTemplates lists the user's templates and the global ones
+// GET /api/chat/templates
+func (h *ChatHandler) GetPromptTemplates(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	templates, err := database.ListPromptTemplates(userID)
+	if err != nil {
+		writePromptTemplateError(c, err, "retrieve templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"templates": templates,
+		},
+	})
+}
+
+// CreatePromptTemplate saves a system prompt template for the user
+// POST /api/chat/templates
+func (h *ChatHandler) CreatePromptTemplate(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	req, ok := bindPromptTemplate(c)
+	if !ok {
+		return
+	}
+
+	template, err := database.CreatePromptTemplate(&userID, req.Name, req.Description, req.Content)
+	if err != nil {
+		writePromptTemplateError(c, err, "create template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// UpdatePromptTemplate replaces one of the user's templates; global templates are read-only
+// PUT /api/chat/templates/:templateId
+func (h *ChatHandler) UpdatePromptTemplate(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindPromptTemplate(c)
+	if !ok {
+		return
+	}
+
+	template, err := database.UpdatePromptTemplate(id, &userID, req.Name, req.Description, req.Content)
+	if err != nil {
+		writePromptTemplateError(c, err, "update template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// DeletePromptTemplate deletes one of the user's templates
+// DELETE /api/chat/templates/:templateId
+func (h *ChatHandler) DeletePromptTemplate(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeletePromptTemplate(id, &userID); err != nil {
+		writePromptTemplateError(c, err, "delete template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Template deleted",
+	})
+}
+
+// AdminListPromptTemplates 列出全局系统提示词模板
+// GET /admin/chat/templates
+func AdminListPromptTemplates(c *gin.Context) {
+	templates, err := database.ListGlobalPromptTemplates()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list global prompt templates")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "list_templates_failed"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// AdminCreatePromptTemplate 发布全局系统提示词模板，所有用户可见
+// POST /admin/chat/templates
+func AdminCreatePromptTemplate(c *gin.Context) {
+	req, ok := bindPromptTemplate(c)
+	if !ok {
+		return
+	}
+
+	template, err := database.CreatePromptTemplate(nil, req.Name, req.Description, req.Content)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create global prompt template")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "create_template_failed"))
+		return
+	}
+	logrus.Infof("Global prompt template %d (%s) published by %s", template.ID, template.Name, c.GetString("username"))
+	c.JSON(http.StatusCreated, gin.H{"template": template})
+}
+
+// AdminUpdatePromptTemplate 更新全局系统提示词模板，已使用该模板的对话保留原提示词
+// PUT /admin/chat/templates/:templateId
+func AdminUpdatePromptTemplate(c *gin.Context) {
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindPromptTemplate(c)
+	if !ok {
+		return
+	}
+
+	template, err := database.UpdatePromptTemplate(id, nil, req.Name, req.Description, req.Content)
+	if err == database.ErrPromptTemplateNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("模板不存在", "not_found", "template_not_found"))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to update global prompt template")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "update_template_failed"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": template})
+}
+
+// AdminDeletePromptTemplate 删除全局系统提示词模板
+// DELETE /admin/chat/templates/:templateId
+func AdminDeletePromptTemplate(c *gin.Context) {
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	err := database.DeletePromptTemplate(id, nil)
+	if err == database.ErrPromptTemplateNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("模板不存在", "not_found", "template_not_found"))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to delete global prompt template")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "delete_template_failed"))
+		return
+	}
+	logrus.Infof("Global prompt template %d deleted by %s", id, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"message": "模板已删除"})
+}
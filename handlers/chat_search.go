@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSearchQueryLength is the longest search query accepted, in characters
+const maxSearchQueryLength = 200
+
+// SearchConversations searches the current user's chat messages and returns the matching
+// conversations, newest first, each with snippets of its latest matching messages.
+// Every word of the query (at least 2 characters) must appear in a message
+// GET /api/chat/search
+// Query params: q (required), page (default 1), limit (default 20, max 50)
+func (h *ChatHandler) SearchConversations(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Search query is too long",
+			"validation_error",
+			"query_too_long",
+		))
+		return
+	}
+	terms := database.ChatSearchTerms(query)
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Search query must contain a word of at least 2 characters",
+			"validation_error",
+			"invalid_query",
+		))
+		return
+	}
+
+	page := 1
+	if parsed, err := strconv.Atoi(c.Query("page")); err == nil && parsed > 0 {
+		page = parsed
+	}
+	limit := 20
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 {
+		limit = parsed
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	results, total, err := database.SearchChatMessages(userID, terms, page, limit)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to search chat messages")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to search conversations",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"results": results,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+		},
+	})
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Share link lifetime, in days
+const (
+	defaultShareDays = 30
+	maxShareDays     = 365
+)
+
+// ShareConversationRequest represents the body of a share request
+type ShareConversationRequest struct {
+	ExpiresInDays int `json:"expires_in_days"` // Optional: link lifetime, 1-365 days, default 30
+}
+
+// SharedMessage is a message as shown on a public share page, without billing details
+type SharedMessage struct {
+	Role      string                `json:"role"`
+	Content   string                `json:"content"`
+	Artifacts []models.ChatArtifact `json:"artifacts,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+// ShareConversation creates a public read-only link to the conversation's active branch as
+// it is now. The token is only returned here; the link can be revoked with RevokeShare
+// POST /api/chat/conversations/:id/share
+func (h *ChatHandler) ShareConversation(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		signedTokensUnavailable(c)
+		return
+	}
+
+	var req ShareConversationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid request format: "+err.Error(),
+				"validation_error",
+				"invalid_request",
+			))
+			return
+		}
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultShareDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxShareDays {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"expires_in_days must be between 1 and 365",
+			"validation_error",
+			"invalid_expiry",
+		))
+		return
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, claims, err := tokens.Issue(services.TokenPurposeConversationShare, strconv.FormatInt(convID, 10), userID, ttl, false)
+	if err != nil {
+		logrus.WithError(err).WithField("conversation_id", convID).Error("Failed to issue share token")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to share conversation",
+			"internal_error",
+			"share_failed",
+		))
+		return
+	}
+	share, err := database.CreateChatShare(convID, userID, claims.ID, claims.Expiry())
+	if err == database.ErrEmptyConversation {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Cannot share a conversation without messages",
+			"validation_error",
+			"empty_conversation",
+		))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to create conversation share")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to share conversation",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"share": share,
+			"token": token,
+			"url":   "/share/" + url.PathEscape(token),
+		},
+	})
+}
+
+// GetShares lists the conversation's share links, including expired and revoked ones
+// GET /api/chat/conversations/:id/shares
+func (h *ChatHandler) GetShares(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	shares, err := database.ListChatShares(convID)
+	if err != nil {
+		logrus.WithError(err).WithField("conversation_id", convID).Error("Failed to list conversation shares")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to retrieve shares",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"shares": shares,
+		},
+	})
+}
+
+// RevokeShare stops a share link from working
+// DELETE /api/chat/shares/:shareId
+func (h *ChatHandler) RevokeShare(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	shareID, err := strconv.ParseInt(c.Param("shareId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid share ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return
+	}
+
+	share, err := database.GetChatShare(shareID, userID)
+	if err != nil {
+		logrus.WithError(err).WithField("share_id", shareID).Error("Failed to get conversation share")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to revoke share",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	if share == nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Share not found",
+			"not_found",
+			"share_not_found",
+		))
+		return
+	}
+
+	if share.RevokedAt == nil {
+		if tokens := services.GetSignedTokenService(); tokens != nil {
+			err = tokens.RevokeID(share.TokenID, services.TokenPurposeConversationShare, share.ExpiresAt)
+		}
+		if err == nil {
+			err = database.RevokeChatShare(share.ID)
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("share_id", shareID).Error("Failed to revoke conversation share")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to revoke share",
+				"internal_error",
+				"database_error",
+			))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Share revoked",
+	})
+}
+
+// GetSharedConversation returns the transcript behind a share link, without requiring login.
+// Only user and assistant messages are shown; the system prompt, costs and seeds are not
+// GET /api/shared/:token
+func GetSharedConversation(c *gin.Context) {
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		signedTokensUnavailable(c)
+		return
+	}
+
+	claims, err := tokens.Verify(c.Param("token"), services.TokenPurposeConversationShare)
+	if err != nil {
+		writeSignedTokenError(c, err)
+		return
+	}
+
+	share, err := database.GetChatShareByTokenID(claims.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("token_id", claims.ID).Error("Failed to get conversation share")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to load shared conversation",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	// The share row is removed with its conversation
+	if share == nil || share.RevokedAt != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Shared conversation not found",
+			"not_found",
+			"share_not_found",
+		))
+		return
+	}
+
+	conv, err := database.GetConversation(share.ConversationID, share.UserID)
+	var messages []models.ChatMessage
+	if err == nil {
+		messages, err = database.GetSharedMessages(share.ConversationID, share.LeafMessageID)
+	}
+	if err == database.ErrConversationNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"Shared conversation not found",
+			"not_found",
+			"share_not_found",
+		))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("share_id", share.ID).Error("Failed to load shared conversation")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to load shared conversation",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	shared := make([]SharedMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		shared = append(shared, SharedMessage{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			Artifacts: msg.Artifacts,
+			CreatedAt: msg.CreatedAt,
+		})
+	}
+	if err := database.RecordChatShareView(share.ID); err != nil {
+		logrus.WithError(err).WithField("share_id", share.ID).Warn("Failed to record share view")
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"title":      conv.Title,
+			"model":      conv.Model,
+			"shared_at":  share.CreatedAt,
+			"expires_at": share.ExpiresAt,
+			"messages":   shared,
+		},
+	})
+}
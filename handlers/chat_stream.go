@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// replayBufferSize is the number of recent events kept per reply for reconnecting clients
+	replayBufferSize = 2048
+	// replayRetention is how long a finished reply stays resumable, so a client that dropped
+	// just before the end still receives the "done" event
+	replayRetention = 2 * time.Minute
+	// resumeTokenTTL is how long the resume token handed out with a reply stays valid. It
+	// outlives any reply; the buffered stream itself is dropped replayRetention after it ends
+	resumeTokenTTL = time.Hour
+)
+
+// resumeTokenSubject binds a resume token to the reply to one user message
+func resumeTokenSubject(conversationID, messageID int64) string {
+	return fmt.Sprintf("%d:%d", conversationID, messageID)
+}
+
+// replayEvent is a stream event with its SSE event ID
+type replayEvent struct {
+	id    int64
+	event models.ChatStreamEvent
+}
+
+// replayStream buffers the recent events of a reply streamed over SSE. Events get IDs
+// counting up from 1, so a client can resume after the last event it received
+type replayStream struct {
+	userID         int64
+	conversationID int64
+
+	mu      sync.Mutex
+	events  []replayEvent // Most recent events, oldest first
+	nextID  int64
+	done    bool
+	changed chan struct{} // Closed and replaced whenever an event is added or the stream ends
+}
+
+// emit appends an event for the clients following the stream
+func (s *replayStream) emit(event models.ChatStreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	if len(s.events) == replayBufferSize {
+		s.events = append(s.events[:0], s.events[1:]...)
+	}
+	s.events = append(s.events, replayEvent{id: s.nextID, event: event})
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// finish marks the end of the stream
+func (s *replayStream) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// since returns the events after lastID, whether the stream has ended and a channel closed on
+// the next change. ok is false when events after lastID were already dropped from the buffer
+func (s *replayStream) since(lastID int64) (events []replayEvent, done bool, changed <-chan struct{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) > 0 && lastID < s.events[0].id-1 {
+		return nil, false, nil, false
+	}
+	for i := range s.events {
+		if s.events[i].id > lastID {
+			events = append(events, s.events[i:]...)
+			break
+		}
+	}
+	return events, s.done, s.changed, true
+}
+
+// replayStreams holds the resumable replies, keyed by the user message they answer
+type replayStreams struct {
+	mu      sync.Mutex
+	streams map[int64]*replayStream
+}
+
+// open registers a new stream for the reply to a user message, replacing a finished one
+// left from an earlier reply to the same message
+func (r *replayStreams) open(messageID, userID, conversationID int64) *replayStream {
+	stream := &replayStream{userID: userID, conversationID: conversationID, changed: make(chan struct{})}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams == nil {
+		r.streams = make(map[int64]*replayStream)
+	}
+	r.streams[messageID] = stream
+	return stream
+}
+
+// close ends a stream and forgets it once the retention period is over
+func (r *replayStreams) close(messageID int64, stream *replayStream) {
+	stream.finish()
+	time.AfterFunc(replayRetention, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.streams[messageID] == stream {
+			delete(r.streams, messageID)
+		}
+	})
+}
+
+// get returns the user's stream for the reply to a message in a conversation
+func (r *replayStreams) get(messageID, userID, conversationID int64) (*replayStream, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stream, ok := r.streams[messageID]
+	if !ok || stream.userID != userID || stream.conversationID != conversationID {
+		return nil, false
+	}
+	return stream, true
+}
+
+// streamResumableReply generates the reply in the background, detached from the request, and
+// relays it to the client as SSE events with IDs. The "start" event carries a signed resume
+// token; a dropped client can pick the stream up again from ResumeStream with that token and
+// Last-Event-ID, and the reply is saved and billed either way.
+// ctx must not be tied to the request; cancel releases it once the reply is done
+func (h *ChatHandler) streamResumableReply(c *gin.Context, ctx context.Context, cancel context.CancelFunc, userID, convID int64, req SendMessageRequest, response *services.SendMessageResponse) {
+	messageID := response.UserMessage.ID
+	var resumeToken string
+	if tokens := services.GetSignedTokenService(); tokens != nil {
+		token, _, err := tokens.Issue(services.TokenPurposeStreamResume, resumeTokenSubject(convID, messageID), userID, resumeTokenTTL, false)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to issue stream resume token")
+		}
+		resumeToken = token
+	}
+
+	stream := h.streams.open(messageID, userID, convID)
+	emit := func(event models.ChatStreamEvent) {
+		if event.Type == "start" {
+			event.ResumeToken = resumeToken
+		}
+		stream.emit(event)
+	}
+	go func() {
+		defer cancel()
+		defer h.streams.close(messageID, stream)
+		h.streamReply(ctx, emit, userID, convID, req, response)
+	}()
+
+	relayReplayStream(c, stream, 0)
+}
+
+// relayReplayStream writes the stream's events after lastID to the client until the stream
+// ends or the client goes away
+func relayReplayStream(c *gin.Context, stream *replayStream, lastID int64) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// Keep idle connections alive through proxies while the model is thinking
+	defer utils.BeginSSEKeepAlive(c, utils.SSEPingComment)()
+
+	for {
+		events, done, changed, ok := stream.since(lastID)
+		if !ok {
+			// The client fell further behind than the buffer reaches while connected
+			sendSSEEvent(c, models.ChatStreamEvent{
+				Type:  "error",
+				Error: "The stream fell too far behind; reload the conversation to get the reply",
+				Code:  "stream_expired",
+			})
+			return
+		}
+		for _, e := range events {
+			data, err := json.Marshal(e.event)
+			if err != nil {
+				logrus.WithError(err).Error("Failed to marshal SSE event")
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\ndata: %s\n\n", e.id, data)
+			lastID = e.id
+		}
+		if len(events) > 0 {
+			c.Writer.(http.Flusher).Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// lastEventID reads the ID of the last event the client received from the Last-Event-ID
+// header, or the last_event_id query parameter for clients that cannot set headers
+func lastEventID(c *gin.Context) (int64, bool) {
+	value := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return id, err == nil && id >= 0
+}
+
+// ResumeStream reconnects to the reply being streamed for a user message, sending the events
+// after Last-Event-ID and then following the stream to its end. The resume token from the
+// "start" event is required, in the X-Resume-Token header or the resume_token query parameter.
+// Replies stay resumable for two minutes after they finish; later, the saved reply is read
+// from the message list
+// GET /api/chat/conversations/:id/messages/:msgId/stream
+func (h *ChatHandler) ResumeStream(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid conversation ID",
+			"validation_error",
+			"invalid_id",
+		))
+		return
+	}
+	msgID, err := strconv.ParseInt(c.Param("msgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid message ID",
+			"validation_error",
+			"invalid_message_id",
+		))
+		return
+	}
+
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		signedTokensUnavailable(c)
+		return
+	}
+	resumeToken := c.GetHeader("X-Resume-Token")
+	if resumeToken == "" {
+		resumeToken = c.Query("resume_token")
+	}
+	claims, err := tokens.Verify(resumeToken, services.TokenPurposeStreamResume)
+	if err != nil {
+		writeSignedTokenError(c, err)
+		return
+	}
+	if claims.UserID != userID || claims.Subject != resumeTokenSubject(convID, msgID) {
+		writeSignedTokenError(c, services.ErrSignedTokenInvalid)
+		return
+	}
+
+	lastID, ok := lastEventID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid Last-Event-ID",
+			"validation_error",
+			"invalid_last_event_id",
+		))
+		return
+	}
+
+	stream, found := h.streams.get(msgID, userID, convID)
+	if !found {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"No resumable reply for this message",
+			"not_found",
+			"stream_not_found",
+		))
+		return
+	}
+	if _, _, _, ok := stream.since(lastID); !ok {
+		c.JSON(http.StatusGone, models.NewErrorResponse(
+			"The events after Last-Event-ID are no longer buffered; reload the conversation to get the reply",
+			"not_found",
+			"stream_expired",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"conversation_id": convID,
+		"message_id":      msgID,
+		"last_event_id":   lastID,
+	}).Info("Chat stream resumed")
+	relayReplayStream(c, stream, lastID)
+}
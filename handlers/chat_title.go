@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RegenerateTitle generates a new title for a conversation from its first exchange and saves it
+// POST /api/chat/conversations/:id/title
+func (h *ChatHandler) RegenerateTitle(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	convID, ok := parseOwnedConversationID(c, userID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), chatReplyTimeout(userID, convID, "", 0))
+	defer cancel()
+
+	title, err := h.chatService.GenerateTitle(ctx, convID, userID)
+	if err != nil {
+		if err == services.ErrNothingToTitle {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Send a message before generating a title",
+				"validation_error",
+				"nothing_to_title",
+			))
+			return
+		}
+		if event, ok := costLimitEvent(err, userID, convID); ok {
+			c.JSON(http.StatusPaymentRequired, models.NewErrorResponse(event.Error, "cost_limit", event.Code))
+			return
+		}
+		c.JSON(sendMessageErrorResponse(c, err, userID, convID, 0))
+		return
+	}
+
+	conv, err := database.GetConversation(convID, userID)
+	if err == nil {
+		err = database.SetGeneratedConversationTitle(convID, userID, title, conv.Title)
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":         userID,
+			"conversation_id": convID,
+		}).Error("Failed to save generated conversation title")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to update title",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"title": title,
+		},
+	})
+}
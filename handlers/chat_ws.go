@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	chatWSWriteWait  = 10 * time.Second   // Time allowed to write a frame to the client
+	chatWSPongWait   = 60 * time.Second   // Time allowed between pongs before the connection is dropped
+	chatWSPingPeriod = chatWSPongWait / 2 // Interval of server pings, must be below chatWSPongWait
+	chatWSMaxFrame   = 1 << 20            // Largest client frame accepted, in bytes
+)
+
+// Client frame types sent over /api/chat/ws
+const (
+	chatWSFrameSend   = "send"
+	chatWSFrameCancel = "cancel"
+)
+
+var chatWSUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     checkChatWSOrigin,
+}
+
+// checkChatWSOrigin accepts same-origin connections and the origins allowed by CORS
+func checkChatWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || middleware.IsAllowedOrigin(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// chatWSClientFrame is a frame sent by the client: "send" starts a reply, "cancel" stops the current one
+type chatWSClientFrame struct {
+	Type           string   `json:"type"`
+	ConversationID int64    `json:"conversation_id,omitempty"`
+	Content        string   `json:"content,omitempty"`
+	Model          string   `json:"model,omitempty"`
+	AttachmentIDs  []string `json:"attachment_ids,omitempty"`
+}
+
+// chatWSConn serializes writes to the WebSocket connection
+type chatWSConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// send writes a stream event as a JSON text frame
+func (w *chatWSConn) send(event models.ChatStreamEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(chatWSWriteWait))
+	if err := w.conn.WriteJSON(event); err != nil {
+		logrus.WithError(err).WithField("type", event.Type).Debug("Failed to write chat WebSocket frame")
+	}
+}
+
+// ChatWebSocket streams AI replies over a WebSocket as an alternative to SSE.
+// The client sends {"type":"send","conversation_id","content","model","attachment_ids"} to start a reply and
+// {"type":"cancel"} to stop it (the partial reply is saved, as with the stop endpoint); the
+// server answers with the same start, content, artifact, done and error events as the SSE
+// endpoint. One reply is generated at a time per connection.
+// GET /api/chat/ws
+func (h *ChatHandler) ChatWebSocket(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	conn, err := chatWSUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written the HTTP error response
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to upgrade chat WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	ws := &chatWSConn{conn: conn}
+	conn.SetReadLimit(chatWSMaxFrame)
+	conn.SetReadDeadline(time.Now().Add(chatWSPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(chatWSPongWait))
+	})
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	var (
+		genMu     sync.Mutex
+		cancelGen context.CancelFunc // Cancels the reply in progress, nil when idle
+		replyConv int64              // Conversation of the reply in progress
+		replyMsg  int64              // User message the reply answers, known once "start" is sent
+		wg        sync.WaitGroup
+	)
+	// Stop any reply in progress and wait for it before the connection is closed
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	go func() {
+		ticker := time.NewTicker(chatWSPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(chatWSWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var frame chatWSClientFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.WithError(err).WithField("user_id", userID).Debug("Chat WebSocket closed")
+			}
+			return
+		}
+
+		switch frame.Type {
+		case chatWSFrameSend:
+			genMu.Lock()
+			if cancelGen != nil {
+				genMu.Unlock()
+				ws.send(models.ChatStreamEvent{
+					Type:  "error",
+					Error: "A reply is already being generated. Cancel it or wait for it to finish.",
+					Code:  "generation_in_progress",
+				})
+				continue
+			}
+			genCtx, genCancel := context.WithCancel(ctx)
+			cancelGen, replyConv, replyMsg = genCancel, frame.ConversationID, 0
+			genMu.Unlock()
+
+			emit := func(event models.ChatStreamEvent) {
+				if event.Type == "start" {
+					genMu.Lock()
+					replyMsg = event.MessageID
+					genMu.Unlock()
+				}
+				ws.send(event)
+			}
+			wg.Add(1)
+			go func(frame chatWSClientFrame) {
+				defer wg.Done()
+				defer func() {
+					genMu.Lock()
+					cancelGen, replyConv, replyMsg = nil, 0, 0
+					genMu.Unlock()
+					genCancel()
+				}()
+				h.replyOverWebSocket(genCtx, c, emit, userID, frame)
+			}(frame)
+
+		case chatWSFrameCancel:
+			// Stop generation once the reply has started so the partial reply is saved,
+			// otherwise abandon the request
+			genMu.Lock()
+			if cancelGen != nil && (replyMsg == 0 || h.chatService.StopGeneration(userID, replyConv, replyMsg) != nil) {
+				cancelGen()
+			}
+			genMu.Unlock()
+
+		default:
+			ws.send(models.ChatStreamEvent{
+				Type:  "error",
+				Error: "Unknown frame type: " + frame.Type,
+				Code:  "invalid_frame",
+			})
+		}
+	}
+}
+
+// replyOverWebSocket validates a "send" frame and streams the reply to the connection
+func (h *ChatHandler) replyOverWebSocket(ctx context.Context, c *gin.Context, emit func(models.ChatStreamEvent), userID int64, frame chatWSClientFrame) {
+	convID := frame.ConversationID
+	req := SendMessageRequest{Content: frame.Content, Model: frame.Model, AttachmentIDs: frame.AttachmentIDs}
+
+	switch {
+	case convID <= 0:
+		emit(models.ChatStreamEvent{Type: "error", Error: "Invalid conversation ID", Code: "invalid_id"})
+		return
+	case strings.TrimSpace(req.Content) == "":
+		emit(models.ChatStreamEvent{Type: "error", Error: "Message content cannot be empty", Code: "empty_content"})
+		return
+	case req.Model != "" && !h.config.IsValidModel(req.Model):
+		emit(models.ChatStreamEvent{Type: "error", Error: "Invalid model specified: " + req.Model, Code: "invalid_model"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, chatReplyTimeout(userID, convID, req.Model, 0))
+	defer cancel()
+
+	response, err := h.chatService.SendMessage(ctx, services.SendMessageRequest{
+		ConversationID: convID,
+		UserID:         userID,
+		Content:        req.Content,
+		Model:          req.Model,
+		AttachmentIDs:  req.AttachmentIDs,
+	})
+	if err != nil {
+		if event, ok := costLimitEvent(err, userID, convID); ok {
+			emit(event)
+			return
+		}
+		_, resp := sendMessageErrorResponse(c, err, userID, convID, middleware.EstimateRequestTokens(req.Model, req.Content, 0))
+		emit(models.ChatStreamEvent{Type: "error", Error: resp.Error.Message, Code: resp.Error.Code})
+		return
+	}
+
+	h.streamReply(ctx, emit, userID, convID, req, response)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// checkinHistoryDays 签到状态中返回的最近签到记录数
+const checkinHistoryDays = 7
+
+// GetCheckinStatusHandler returns today's check-in state, the current streak and the next reward
+// GET /api/checkin
+func GetCheckinStatusHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	cfg, err := database.GetCheckinConfig()
+	var history []*database.Checkin
+	if err == nil {
+		history, err = database.GetCheckinHistory(userID, checkinHistoryDays)
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get check-in status")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"获取签到状态失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	today := database.CheckinDay(time.Now())
+	var last *database.Checkin
+	if len(history) > 0 {
+		last = history[0]
+	}
+	checkedIn := last != nil && last.Date == today
+
+	// 今日已签到时连续天数即最近一次签到；否则只有昨天签到过才延续
+	streak := 0
+	nextStreak := database.NextStreak(last, today)
+	if checkedIn {
+		streak = last.Streak
+		nextStreak = last.Streak + 1
+	} else if nextStreak > 1 {
+		streak = nextStreak - 1
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":          cfg.Enabled,
+		"reward_type":      cfg.RewardType,
+		"checked_in_today": checkedIn,
+		"streak":           streak,
+		"next_reward":      cfg.RewardFor(nextStreak),
+		"history":          history,
+	})
+}
+
+// CheckinHandler performs today's check-in and grants the reward.
+// The optional X-Device-ID header identifies the device for anti-abuse limits
+// POST /api/checkin
+func CheckinHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	cfg, err := database.GetCheckinConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get check-in config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"签到失败",
+			"internal_error",
+			"checkin_failed",
+		))
+		return
+	}
+
+	deviceID := strings.TrimSpace(c.GetHeader("X-Device-ID"))
+	if len(deviceID) > 128 {
+		deviceID = deviceID[:128]
+	}
+
+	checkin, err := database.PerformCheckin(userID, c.ClientIP(), deviceID, cfg, time.Now())
+	switch err {
+	case nil:
+	case database.ErrCheckinDisabled:
+		c.JSON(http.StatusForbidden, models.NewErrorResponse("签到活动未开启", "authorization_error", "checkin_disabled"))
+		return
+	case database.ErrAlreadyCheckedIn:
+		c.JSON(http.StatusConflict, models.NewErrorResponse("今天已经签到过了", "validation_error", "already_checked_in"))
+		return
+	case database.ErrCheckinIPLimit, database.ErrCheckinDeviceLimit:
+		logrus.WithFields(logrus.Fields{
+			"user_id":   userID,
+			"ip":        c.ClientIP(),
+			"device_id": deviceID,
+		}).Warn("Check-in rejected by anti-abuse limit")
+		c.JSON(http.StatusTooManyRequests, models.NewErrorResponse(
+			"该网络或设备今日签到次数已达上限",
+			"rate_limited",
+			"checkin_limit_exceeded",
+		))
+		return
+	default:
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to perform check-in")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"签到失败",
+			"internal_error",
+			"checkin_failed",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"streak":  checkin.Streak,
+		"reward":  checkin.RewardAmount,
+		"type":    checkin.RewardType,
+	}).Info("User checked in")
+
+	c.JSON(http.StatusOK, gin.H{
+		"checkin":     checkin,
+		"next_reward": cfg.RewardFor(checkin.Streak + 1),
+	})
+}
+
+// GetCheckinConfigHandler 获取签到奖励配置
+// GET /admin/checkin/config
+func GetCheckinConfigHandler(c *gin.Context) {
+	cfg, err := database.GetCheckinConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get check-in config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_checkin_config_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+// UpdateCheckinConfigHandler 更新签到奖励配置
+// PUT /admin/checkin/config
+func UpdateCheckinConfigHandler(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	var cfg database.CheckinConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	var msg string
+	switch {
+	case cfg.RewardType != database.CheckinRewardBalance && cfg.RewardType != database.CheckinRewardGameCoin:
+		msg = "reward_type must be balance or game_coin"
+	case cfg.BaseReward < 0 || cfg.StreakBonus < 0 || cfg.MaxStreakBonus < 0 || cfg.WeeklyBonus < 0:
+		msg = "reward amounts must not be negative"
+	case cfg.MaxPerIPPerDay < 0 || cfg.MaxPerDevicePerDay < 0:
+		msg = "per-day limits must not be negative (0 means unlimited)"
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.SaveCheckinConfig(&cfg); err != nil {
+		logrus.WithError(err).Error("Failed to save check-in config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_checkin_config_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
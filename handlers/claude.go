@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"Curry2API-go/middleware"
 	"Curry2API-go/models"
 	"Curry2API-go/services"
+	"Curry2API-go/services/providers"
 	"Curry2API-go/utils"
 	"net/http"
 
@@ -24,6 +26,7 @@ type ClaudeHandler struct {
 	cursorService     *services.CursorService
 	openRouterService *services.OpenRouterService
 	toolExecutor      *services.ToolExecutor
+	providerRouter    *services.ProviderRouter
 }
 
 // NewClaudeHandler 创建新的Claude处理器
@@ -40,6 +43,54 @@ func NewClaudeHandler(cfg *config.Config) *ClaudeHandler {
 	}
 }
 
+// SetProviderRouter 设置多提供商路由，配置为直连的 Anthropic 将原样处理 claude-* 请求
+func (h *ClaudeHandler) SetProviderRouter(router *services.ProviderRouter) {
+	h.providerRouter = router
+}
+
+// nativeMessagesClient 返回可直接处理原生 Messages 请求的直连提供商；
+// route 为路由规则指定的提供商，cursor 表示不直连，指定的提供商不可用或不支持原生 Messages 时按默认规则选择；
+// keyTags 为请求密钥的标签，用于判断已达消费上限的提供商是否放行
+func (h *ClaudeHandler) nativeMessagesClient(model, route string, keyTags []string) (providers.NativeMessagesClient, bool) {
+	if route == "cursor" {
+		return nil, false
+	}
+	if route != "" {
+		if provider, ok := h.providerRouter.GetRoutedProvider(route, keyTags); ok {
+			if client, ok := provider.(providers.NativeMessagesClient); ok {
+				return client, true
+			}
+		}
+		logrus.WithField("provider", route).Warn("Routing rule provider cannot serve Messages requests, using default routing")
+	}
+	provider, ok := h.providerRouter.GetDirectProvider(model, keyTags)
+	if !ok {
+		return nil, false
+	}
+	client, ok := provider.(providers.NativeMessagesClient)
+	return client, ok
+}
+
+// visionProvider 返回可处理图片内容的直连提供商（透传 image_url 内容部分）；
+// Cursor 与 OpenRouter 免费模型服务只支持文本，图片请求不能交给它们
+func (h *ClaudeHandler) visionProvider(model, route string, keyTags []string) (providers.ProviderClient, bool) {
+	supportsVision := func(provider providers.ProviderClient) bool {
+		client, ok := provider.(providers.VisionClient)
+		return ok && client.SupportsVision()
+	}
+	if route != "" && route != "cursor" {
+		if provider, ok := h.providerRouter.GetRoutedProvider(route, keyTags); ok && supportsVision(provider) {
+			return provider, true
+		}
+		logrus.WithField("provider", route).Warn("Routing rule provider cannot serve image content, using default routing")
+	}
+	provider, ok := h.providerRouter.GetDirectProvider(model, keyTags)
+	if !ok || !supportsVision(provider) {
+		return nil, false
+	}
+	return provider, true
+}
+
 // ClaudeMessages 处理Claude Messages API请求
 // POST /v1/messages
 func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
@@ -72,6 +123,9 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 		return
 	}
 
+	// 请求体未指定 stream 时按 Accept 请求头协商响应格式
+	negotiateClaudeStream(c, bodyBytes, &request)
+
 	// 如果未提供max_tokens，设置默认值（在验证之前）
 	if request.MaxTokens == 0 {
 		request.MaxTokens = 4096 // 默认值
@@ -134,8 +188,19 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 		request.MaxTokens = *validatedMaxTokens
 	}
 
+	// 直连 Anthropic 时原样转发请求（原生支持工具调用），无需注入工具提示
+	nativeClient, isNative := h.nativeMessagesClient(request.Model, c.GetString("route_provider"), requestKeyTags(c))
+
+	// anthropic-beta 只对直连 Anthropic 生效（原样转发），转换后的请求不支持测试版功能
+	if beta := c.GetHeader("anthropic-beta"); beta != "" && !isNative {
+		logrus.WithFields(logrus.Fields{
+			"model":          request.Model,
+			"anthropic_beta": beta,
+		}).Debug("Ignoring anthropic-beta header for non-Anthropic route")
+	}
+
 	// 检查是否包含工具调用
-	hasToolUse := h.toolExecutor.HasToolUse(&request)
+	hasToolUse := !isNative && h.toolExecutor.HasToolUse(&request)
 	if hasToolUse {
 		logrus.WithFields(logrus.Fields{
 			"model":      request.Model,
@@ -202,6 +267,48 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 		c.Set("has_tool_use", true)
 	}
 
+	// 图片内容只能交给支持视觉输入的直连提供商，没有可用提供商时明确报错而不是丢弃图片
+	var visionProvider providers.ProviderClient
+	if !isNative && openAIRequest.HasImageContent() {
+		var ok bool
+		visionProvider, ok = h.visionProvider(request.Model, c.GetString("route_provider"), requestKeyTags(c))
+		if !ok {
+			c.JSON(http.StatusBadRequest, models.NewClaudeInvalidRequestError(
+				fmt.Sprintf("Image content is not supported for model %s: no vision-capable provider is configured", request.Model)))
+			return
+		}
+	}
+
+	// 获取提供商并发槽位（受信任密钥的高优先级请求优先）
+	provider := "cursor"
+	if visionProvider != nil {
+		provider = visionProvider.GetProviderName()
+	} else if services.IsOpenRouterModel(request.Model) {
+		provider = "openrouter"
+	} else if isNative {
+		provider = "anthropic"
+	}
+	c.Set("request_provider", provider)
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), provider, priority, middleware.QueueFeedback(c, request.Stream))
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(provider, priority),
+			models.NewClaudeAPIError("Provider is busy, please retry later"),
+			models.NewClaudeRateLimitError("Provider rate limit reached, please retry later"))
+		return
+	}
+	defer releaseSlot()
+
+	if isNative {
+		h.claudeMessagesDirect(c, nativeClient, bodyBytes, originalModel, &request)
+		return
+	}
+
+	if visionProvider != nil {
+		h.claudeMessagesVision(c, visionProvider, openAIRequest)
+		return
+	}
+
 	// 检查是否为 OpenRouter 免费模型
 	if services.IsOpenRouterModel(request.Model) {
 		logrus.WithField("model", request.Model).Info("Using OpenRouter service for free model")
@@ -216,6 +323,7 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 		
 		// 设置 OpenRouter 标识
 		c.Set("cursor_session", "openrouter-free-model")
+		chatGenerator = utils.WithUsageFallback(c.Request.Context(), chatGenerator, openAIRequest)
 		
 		// 根据是否流式返回不同响应
 		if request.Stream {
@@ -227,7 +335,7 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 	}
 
 	// 调用Cursor服务（原有逻辑）
-	chatGenerator, session, err := h.cursorService.ChatCompletion(c.Request.Context(), openAIRequest)
+	chatGenerator, session, err := h.cursorService.ChatCompletion(middleware.ConversationContext(c), openAIRequest)
 	if err != nil {
 		h.handleCursorError(c, err)
 		return
@@ -235,6 +343,7 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 
 	// 设置 session 信息
 	h.setSessionInfo(c, session)
+	chatGenerator = utils.WithUsageFallback(c.Request.Context(), chatGenerator, openAIRequest)
 
 	// 根据是否流式返回不同响应
 	if request.Stream {
@@ -244,6 +353,119 @@ func (h *ClaudeHandler) ClaudeMessages(c *gin.Context) {
 	}
 }
 
+// negotiateClaudeStream 请求体未指定 stream 时按 Accept 协商：只接受 text/event-stream 的客户端按流式返回。
+// 请求体中显式的 stream 优先，stream=false 的请求始终返回 JSON
+func negotiateClaudeStream(c *gin.Context, body []byte, request *models.ClaudeMessageRequest) {
+	if request.Stream {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return
+	}
+	if _, ok := fields["stream"]; ok {
+		return
+	}
+	if middleware.AcceptsOnlyEventStream(c) {
+		request.Stream = true
+	}
+}
+
+// claudeMessagesDirect 将原始请求体转发到 Anthropic Messages API 并原样返回响应
+// 仅覆盖 model（上游需要 Anthropic 的模型标识）与校验后的 max_tokens
+func (h *ClaudeHandler) claudeMessagesDirect(c *gin.Context, client providers.NativeMessagesClient, bodyBytes []byte, originalModel string, request *models.ClaudeMessageRequest) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewClaudeInvalidRequestError("Invalid request format: "+err.Error()))
+		return
+	}
+
+	// 客户端传入的完整 Anthropic 标识（如 claude-sonnet-4-5-20250929）直接使用，自定义别名使用标准化后的名称
+	upstreamModel := request.Model
+	if strings.HasPrefix(strings.ToLower(originalModel), "claude-") {
+		upstreamModel = originalModel
+	}
+	payload["model"], _ = json.Marshal(upstreamModel)
+	payload["max_tokens"], _ = json.Marshal(request.MaxTokens)
+	if request.Stream {
+		payload["stream"] = json.RawMessage("true") // 可能由 Accept 协商得出
+	}
+	body, _ := json.Marshal(payload)
+
+	started := time.Now()
+	resp, err := client.Messages(c.Request.Context(), body, c.GetHeader("anthropic-beta"))
+	h.providerRouter.RecordProviderResult("anthropic", err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, "anthropic", request.Model, "")
+		services.LogProviderError(providerErr)
+
+		status := providerErr.HTTPStatus()
+		switch status {
+		case http.StatusBadRequest:
+			c.JSON(status, models.NewClaudeInvalidRequestError(providerErr.Message))
+		case http.StatusTooManyRequests:
+			c.JSON(status, models.NewClaudeRateLimitError(providerErr.GetUserFriendlyMessage()))
+		default:
+			c.JSON(status, models.NewClaudeAPIError(providerErr.GetUserFriendlyMessage()))
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	c.Set("cursor_session", "anthropic-direct")
+	if request.Stream {
+		utils.RelayClaudeStream(c, resp.Body)
+	} else {
+		utils.RelayClaudeResponse(c, resp.Body)
+	}
+}
+
+// claudeMessagesVision 通过支持视觉输入的直连提供商处理含图片的请求，响应转换为 Claude 格式
+func (h *ClaudeHandler) claudeMessagesVision(c *gin.Context, provider providers.ProviderClient, openAIRequest *models.ChatCompletionRequest) {
+	chatRequest := &models.ChatRequest{
+		Model:       openAIRequest.Model,
+		Messages:    openAIRequest.Messages,
+		Stream:      true,
+		Temperature: openAIRequest.Temperature,
+	}
+	if openAIRequest.MaxTokens != nil {
+		chatRequest.MaxTokens = *openAIRequest.MaxTokens
+	}
+
+	providerName := provider.GetProviderName()
+	logrus.WithFields(logrus.Fields{
+		"model":    openAIRequest.Model,
+		"provider": providerName,
+	}).Info("Routing Claude request with image content to vision-capable provider")
+
+	started := time.Now()
+	events, err := provider.ChatCompletion(c.Request.Context(), chatRequest)
+	h.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, openAIRequest.Model, "")
+		services.LogProviderError(providerErr)
+
+		status := providerErr.HTTPStatus()
+		switch status {
+		case http.StatusBadRequest:
+			c.JSON(status, models.NewClaudeInvalidRequestError(providerErr.Message))
+		case http.StatusTooManyRequests:
+			c.JSON(status, models.NewClaudeRateLimitError(providerErr.GetUserFriendlyMessage()))
+		default:
+			c.JSON(status, models.NewClaudeAPIError(providerErr.GetUserFriendlyMessage()))
+		}
+		return
+	}
+
+	c.Set("cursor_session", providerName+"-direct")
+	chatGenerator := utils.WithUsageFallback(c.Request.Context(), services.StreamEventsToChunks(events), openAIRequest)
+	if openAIRequest.Stream {
+		utils.SafeClaudeStreamWrapper(utils.StreamClaudeCompletion, c, chatGenerator)
+	} else {
+		utils.NonStreamClaudeCompletion(c, chatGenerator)
+	}
+}
+
 // handleCursorError 处理 Cursor 服务错误
 func (h *ClaudeHandler) handleCursorError(c *gin.Context, err error) {
 	logrus.WithError(err).Error("Failed to create Claude chat completion")
@@ -287,7 +509,7 @@ func (h *ClaudeHandler) setSessionInfo(c *gin.Context, session *middleware.Curso
 
 // CountTokens 处理 Claude count_tokens API 请求
 // POST /v1/messages/count_tokens
-// 这是一个简化实现，返回估算的 token 数量
+// 请求按与 Messages 相同的方式转换后用 tokenizer 计算（Claude 分词器未公开，结果为近似值）
 func (h *ClaudeHandler) CountTokens(c *gin.Context) {
 	var request models.ClaudeMessageRequest
 	
@@ -298,57 +520,12 @@ func (h *ClaudeHandler) CountTokens(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, errorResp)
 		return
 	}
-	
-	// 估算 token 数量（简单实现：每 4 个字符约 1 个 token）
-	totalChars := 0
-	
-	// 计算系统提示的字符数
-	if request.System != nil {
-		switch sys := request.System.(type) {
-		case string:
-			totalChars += len(sys)
-		case []interface{}:
-			for _, item := range sys {
-				if block, ok := item.(map[string]interface{}); ok {
-					if text, exists := block["text"].(string); exists {
-						totalChars += len(text)
-					}
-				}
-			}
-		}
-	}
-	
-	// 计算消息的字符数
-	for _, msg := range request.Messages {
-		switch content := msg.Content.(type) {
-		case string:
-			totalChars += len(content)
-		case []interface{}:
-			for _, item := range content {
-				if block, ok := item.(map[string]interface{}); ok {
-					if text, exists := block["text"].(string); exists {
-						totalChars += len(text)
-					}
-				}
-			}
-		}
-	}
-	
-	// 计算工具定义的字符数
-	for _, tool := range request.Tools {
-		totalChars += len(tool.Name) + len(tool.Description)
-	}
-	
-	// 估算 token 数量（每 4 个字符约 1 个 token，中文每 2 个字符约 1 个 token）
-	// 这里使用保守估计
-	estimatedTokens := (totalChars + 3) / 4
-	if estimatedTokens < 1 {
-		estimatedTokens = 1
-	}
+
+	inputTokens := utils.CountRequestTokens(request.ToOpenAIRequest())
 	
 	// 返回 token 计数响应
 	response := map[string]interface{}{
-		"input_tokens": estimatedTokens,
+		"input_tokens": inputTokens,
 	}
 	
 	c.JSON(http.StatusOK, response)
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// AdminExportConfig exports models, aliases, pricing, FX/tax rates, rate limits,
+// feature flags and email templates. Secrets are never included.
+// GET /admin/config/export?format=json|yaml
+func (h *Handler) AdminExportConfig(c *gin.Context) {
+	bundle := services.ExportConfigBundle(h.config)
+
+	if c.DefaultQuery("format", "json") != "yaml" {
+		c.Header("Content-Disposition", `attachment; filename="curry2api-config.json"`)
+		c.JSON(http.StatusOK, bundle)
+		return
+	}
+
+	out, err := bundleToYAML(bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to encode config bundle",
+			"internal_error",
+			"export_failed",
+		))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="curry2api-config.yaml"`)
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", out)
+}
+
+// AdminImportConfig validates and applies a config bundle exported by AdminExportConfig
+// POST /admin/config/import
+// Query params:
+//   - dry_run: validate only, nothing is changed (default true)
+//   - format: json or yaml (default detected from Content-Type)
+func (h *Handler) AdminImportConfig(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Failed to read request body",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" && strings.Contains(c.ContentType(), "yaml") {
+		format = "yaml"
+	}
+
+	var bundle services.ConfigBundle
+	if format == "yaml" {
+		err = yamlToBundle(body, &bundle)
+	} else {
+		err = json.Unmarshal(body, &bundle)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid config bundle: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	result, err := services.ImportConfigBundle(h.config, &bundle, dryRun)
+	if err != nil {
+		logrus.WithError(err).Warn("Config bundle import failed")
+		c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse(
+			err.Error(),
+			"validation_error",
+			"import_failed",
+		))
+		return
+	}
+
+	if !dryRun {
+		logrus.WithFields(logrus.Fields{
+			"applied":          result.Applied,
+			"restart_required": result.RestartRequired,
+		}).Info("Config bundle imported")
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// bundleToYAML encodes via JSON first so the YAML keys match the JSON field names
+func bundleToYAML(bundle *services.ConfigBundle) ([]byte, error) {
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// yamlToBundle decodes YAML into a generic value and converts it through JSON
+func yamlToBundle(data []byte, bundle *services.ConfigBundle) error {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, bundle)
+}
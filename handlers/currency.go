@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateDisplayCurrencyRequest 更新显示货币请求
+type UpdateDisplayCurrencyRequest struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// UpdateFXRatesRequest 更新汇率请求
+type UpdateFXRatesRequest struct {
+	Rates map[string]float64 `json:"rates" binding:"required"`
+}
+
+// GetCurrencySettingsHandler returns the user's display currency and current FX rates
+// GET /api/balance/currency
+func GetCurrencySettingsHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	currency, rate := services.GetUserDisplayCurrency(userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"display_currency": currency,
+		"fx_rate":          rate,
+		"base_currency":    services.BaseCurrency,
+		"supported":        services.SupportedCurrencies(),
+		"rates":            services.GetFXRates(),
+	})
+}
+
+// UpdateDisplayCurrencyHandler updates the user's display currency
+// PUT /profile/currency
+func UpdateDisplayCurrencyHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	var req UpdateDisplayCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求参数无效",
+			"invalid_request",
+			"invalid_parameters",
+		))
+		return
+	}
+
+	currency, err := services.NormalizeCurrency(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"不支持的货币: "+req.Currency,
+			"invalid_request",
+			"unsupported_currency",
+		))
+		return
+	}
+
+	if err := database.UpdateUserDisplayCurrency(userID, currency); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to update display currency")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"更新显示货币失败",
+			"internal_error",
+			"update_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "显示货币更新成功",
+		"display_currency": currency,
+	})
+}
+
+// AdminGetFXRatesHandler returns the effective FX rates
+// GET /admin/fx-rates
+func AdminGetFXRatesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency": services.BaseCurrency,
+		"supported":     services.SupportedCurrencies(),
+		"rates":         services.GetFXRates(),
+	})
+}
+
+// AdminUpdateFXRatesHandler updates the admin-configured FX rates
+// PUT /admin/fx-rates
+func AdminUpdateFXRatesHandler(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	var req UpdateFXRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	if err := services.UpdateFXRates(req.Rates); err != nil {
+		if err == services.ErrUnsupportedCurrency {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Unsupported currency in rates",
+				"validation_error",
+				"unsupported_currency",
+			))
+			return
+		}
+		logrus.WithError(err).Error("Failed to update FX rates")
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"validation_error",
+			"invalid_fx_rates",
+		))
+		return
+	}
+
+	logrus.WithField("rates", req.Rates).Info("FX rates updated by admin")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "FX rates updated successfully",
+		"rates":   services.GetFXRates(),
+	})
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CustomProviderRequest 创建/更新自定义上游请求；更新时 api_key 为空表示保留原密钥
+type CustomProviderRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	BaseURL  string   `json:"base_url" binding:"required"`
+	APIKey   string   `json:"api_key"`
+	Models   []string `json:"models" binding:"required"`
+	Priority int      `json:"priority"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// validate 校验请求并规范化名称、地址与模型列表，返回错误信息；地址不能指向回环、内网或链路本地主机
+func (r *CustomProviderRequest) validate(ctx context.Context) string {
+	r.Name = strings.ToLower(strings.TrimSpace(r.Name))
+	if msg := services.ValidateCustomProviderName(r.Name); msg != "" {
+		return msg
+	}
+
+	r.BaseURL = strings.TrimSuffix(strings.TrimSpace(r.BaseURL), "/")
+	u, err := url.Parse(r.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "base_url must be an http(s) URL"
+	}
+	if msg := services.ValidateCustomProviderHost(ctx, u.Hostname()); msg != "" {
+		return msg
+	}
+
+	seen := make(map[string]bool, len(r.Models))
+	allowed := make([]string, 0, len(r.Models))
+	for _, m := range r.Models {
+		m = strings.TrimSpace(m)
+		if m != "" && !seen[m] {
+			seen[m] = true
+			allowed = append(allowed, m)
+		}
+	}
+	if len(allowed) == 0 {
+		return "models must list at least one model"
+	}
+	r.Models = allowed
+	return ""
+}
+
+// customProviderView 返回给管理后台的上游信息，API key 脱敏
+func customProviderView(p *database.CustomProvider) gin.H {
+	maskedKey := "********"
+	if len(p.APIKey) > 8 {
+		maskedKey = middleware.MaskKey(p.APIKey)
+	}
+	return gin.H{
+		"id":         p.ID,
+		"name":       p.Name,
+		"base_url":   p.BaseURL,
+		"api_key":    maskedKey,
+		"models":     p.Models,
+		"priority":   p.Priority,
+		"is_active":  p.IsActive,
+		"created_at": p.CreatedAt,
+		"updated_at": p.UpdatedAt,
+	}
+}
+
+// reloadCustomProviders 热加载自定义上游，失败只记录日志（下次修改时重试）
+func (h *Handler) reloadCustomProviders() {
+	if h.providerRouter == nil {
+		return
+	}
+	if err := h.providerRouter.ReloadCustomProviders(); err != nil {
+		logrus.WithError(err).Error("Failed to reload custom providers")
+	}
+}
+
+// validateCustomProviderCredentials 启用上游前用其凭据调用一次上游（列出模型或最小补全），
+// 上游不可达或拒绝凭据时写入包含上游错误详情的响应并返回 false
+func validateCustomProviderCredentials(c *gin.Context, p *database.CustomProvider) bool {
+	err := services.ValidateCustomProviderCredentials(c.Request.Context(), p.Name, p.BaseURL, p.APIKey, p.Models)
+	if err == nil {
+		return true
+	}
+	logrus.WithError(err).WithField("provider", p.Name).Warn("Custom provider credential validation failed")
+	c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse(
+		"credential validation failed, provider not enabled: "+err.Error(),
+		"validation_error",
+		"provider_validation_failed",
+	))
+	return false
+}
+
+// AdminListCustomProviders 列出自定义 OpenAI 兼容上游
+// GET /admin/providers
+func (h *Handler) AdminListCustomProviders(c *gin.Context) {
+	list, err := database.ListCustomProviders(false)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list custom providers")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_providers_failed",
+		))
+		return
+	}
+
+	views := make([]gin.H, 0, len(list))
+	for _, p := range list {
+		views = append(views, customProviderView(p))
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": views})
+}
+
+// AdminCreateCustomProvider 注册自定义 OpenAI 兼容上游，立即生效；启用前校验凭据，校验失败时拒绝注册
+// POST /admin/providers
+func (h *Handler) AdminCreateCustomProvider(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	var req CustomProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"name、base_url 和 models 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if msg := req.validate(c.Request.Context()); msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+	if req.APIKey == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"api_key is required",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	p := &database.CustomProvider{
+		Name:     req.Name,
+		BaseURL:  req.BaseURL,
+		APIKey:   req.APIKey,
+		Models:   req.Models,
+		Priority: req.Priority,
+		IsActive: req.IsActive == nil || *req.IsActive,
+	}
+	if p.IsActive && !validateCustomProviderCredentials(c, p) {
+		return
+	}
+	if err := database.CreateCustomProvider(p); err != nil {
+		if err == database.ErrCustomProviderExists {
+			c.JSON(http.StatusConflict, models.NewErrorResponse(
+				"上游名称已存在",
+				"validation_error",
+				"provider_exists",
+			))
+			return
+		}
+		logrus.WithError(err).Error("Failed to create custom provider")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"create_provider_failed",
+		))
+		return
+	}
+
+	h.reloadCustomProviders()
+	c.JSON(http.StatusCreated, customProviderView(p))
+}
+
+// AdminUpdateCustomProvider 更新自定义上游，立即生效；启用上游或更换凭据时重新校验，校验失败时拒绝更新
+// PUT /admin/providers/:id
+func (h *Handler) AdminUpdateCustomProvider(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	var req CustomProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"name、base_url 和 models 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if msg := req.validate(c.Request.Context()); msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	p, err := database.GetCustomProvider(id)
+	if err == database.ErrCustomProviderNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("上游不存在", "not_found", "provider_not_found"))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get custom provider")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_provider_failed",
+		))
+		return
+	}
+
+	// 启用上游、更换密钥或地址时重新校验凭据
+	revalidate := req.APIKey != "" || req.BaseURL != p.BaseURL || !p.IsActive
+	p.Name = req.Name
+	p.BaseURL = req.BaseURL
+	p.Models = req.Models
+	p.Priority = req.Priority
+	if req.APIKey != "" {
+		p.APIKey = req.APIKey
+	}
+	if req.IsActive != nil {
+		p.IsActive = *req.IsActive
+	}
+	if p.IsActive && revalidate && !validateCustomProviderCredentials(c, p) {
+		return
+	}
+
+	if err := database.UpdateCustomProvider(p); err != nil {
+		switch err {
+		case database.ErrCustomProviderExists:
+			c.JSON(http.StatusConflict, models.NewErrorResponse(
+				"上游名称已存在",
+				"validation_error",
+				"provider_exists",
+			))
+		case database.ErrCustomProviderNotFound:
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("上游不存在", "not_found", "provider_not_found"))
+		default:
+			logrus.WithError(err).Error("Failed to update custom provider")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"服务器内部错误",
+				"internal_error",
+				"update_provider_failed",
+			))
+		}
+		return
+	}
+
+	h.reloadCustomProviders()
+	c.JSON(http.StatusOK, customProviderView(p))
+}
+
+// AdminDeleteCustomProvider 删除自定义上游，立即生效
+// DELETE /admin/providers/:id
+func (h *Handler) AdminDeleteCustomProvider(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	if err := database.DeleteCustomProvider(id); err != nil {
+		if err == database.ErrCustomProviderNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("上游不存在", "not_found", "provider_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to delete custom provider")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"delete_provider_failed",
+		))
+		return
+	}
+
+	h.reloadCustomProviders()
+	c.JSON(http.StatusOK, gin.H{"message": "provider deleted"})
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"Curry2API-go/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminDoctor runs the configuration self-test and returns a structured pass/fail report
+// GET /admin/doctor
+func (h *Handler) AdminDoctor(c *gin.Context) {
+	report := services.RunDoctor(h.config)
+
+	status := http.StatusOK
+	if !report.Passed {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Embeddings 处理 OpenAI 兼容的向量请求，路由到支持 embeddings 的提供商（OpenAI/OpenRouter/自定义上游）
+// 输入较多时分批请求上游；用量写入 usage_records 并按 token 扣费
+// POST /v1/embeddings
+func (h *Handler) Embeddings(c *gin.Context) {
+	requestStartTime := time.Now()
+
+	var request models.EmbeddingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format",
+			"invalid_request_error",
+			"invalid_json",
+		))
+		return
+	}
+
+	inputs := request.EmbeddingInputs()
+	if len(inputs) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"input must be a non-empty string, array of strings, or array of token arrays",
+			"invalid_request_error",
+			"invalid_input",
+		))
+		return
+	}
+	switch request.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"encoding_format must be float or base64",
+			"invalid_request_error",
+			"invalid_encoding_format",
+		))
+		return
+	}
+
+	// Check token model access restriction
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		if err := middleware.GetKeyManager().CheckTokenModelAccess(apiKey, request.Model); err == middleware.ErrModelNotAllowed {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"Model not allowed - this token does not have access to model: "+request.Model,
+				"forbidden",
+				"model_not_allowed",
+			))
+			return
+		}
+	}
+
+	client, providerName, ok := h.providerRouter.GetEmbeddingsProvider(request.Model, requestKeyTags(c))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"No provider available for embedding model: "+request.Model,
+			"invalid_request_error",
+			"model_not_found",
+		))
+		return
+	}
+
+	usageInfo, err := utils.ExtractUsageFromContext(c)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to extract usage context info")
+	}
+	c.Set("request_start_time", requestStartTime)
+	c.Set("request_model", request.Model)
+	if usageInfo != nil {
+		c.Set("usage_info", usageInfo)
+	}
+	c.Set("request_provider", providerName)
+
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), providerName, priority, nil)
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(providerName, priority),
+			models.NewErrorResponse(
+				"Provider is busy, please retry later",
+				"server_overloaded",
+				"provider_queue_timeout",
+			),
+			models.NewErrorResponse(
+				"Provider rate limit reached, please retry later",
+				"rate_limited",
+				"provider_rate_limited",
+			))
+		return
+	}
+	defer releaseSlot()
+
+	resp, err := h.providerRouter.CreateEmbeddings(c.Request.Context(), client, providerName, &request, inputs)
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, request.Model, "")
+		services.LogProviderError(providerErr)
+		c.JSON(providerErr.HTTPStatus(), models.NewErrorResponse(
+			providerErr.GetUserFriendlyMessage(),
+			"provider_error",
+			string(providerErr.Code),
+		))
+		return
+	}
+
+	c.Set("cursor_session", providerName+"-direct")
+	trackUsageFromContext(c, &models.Usage{
+		PromptTokens: resp.Usage.PromptTokens,
+		TotalTokens:  resp.Usage.TotalTokens,
+	}, http.StatusOK, "")
+
+	c.JSON(http.StatusOK, resp)
+}
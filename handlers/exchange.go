@@ -5,6 +5,7 @@ import (
 	"Curry2API-go/models"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -15,6 +16,21 @@ type ExchangeGameCoinsRequest struct {
 	Amount float64 `json:"amount" binding:"required,gt=0"`
 }
 
+// transferIdempotencyKey reads the optional Idempotency-Key header; a retried
+// request with the same key returns the original transfer instead of repeating it
+func transferIdempotencyKey(c *gin.Context) (string, bool) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if len(key) > database.MaxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Idempotency-Key must be at most 64 characters",
+			"validation_error",
+			"invalid_idempotency_key",
+		))
+		return "", false
+	}
+	return key, true
+}
+
 // ExchangeGameCoinsHandler exchanges game coins for account balance (USD)
 // POST /api/game/exchange
 // Requirements: 2.1, 2.2, 2.3, 2.6, 2.7
@@ -54,6 +70,11 @@ func ExchangeGameCoinsHandler(c *gin.Context) {
 		return
 	}
 
+	idempotencyKey, ok := transferIdempotencyKey(c)
+	if !ok {
+		return
+	}
+
 	// Ensure user has a game balance record
 	_, err = database.GetOrCreateUserGameBalance(userID)
 	if err != nil {
@@ -68,7 +89,7 @@ func ExchangeGameCoinsHandler(c *gin.Context) {
 
 
 	// Execute exchange
-	exchangeRecord, err := database.ExchangeGameCoins(userID, req.Amount)
+	exchangeRecord, err := database.ExchangeGameCoins(userID, req.Amount, idempotencyKey)
 	if err != nil {
 		switch err {
 		case database.ErrInsufficientGameCoins:
@@ -113,6 +134,13 @@ func ExchangeGameCoinsHandler(c *gin.Context) {
 				"account_balance_not_found",
 			))
 			return
+		case database.ErrIdempotencyKeyReused:
+			c.JSON(http.StatusConflict, models.NewErrorResponse(
+				"Idempotency-Key was already used for a different transfer",
+				"validation_error",
+				"idempotency_key_reused",
+			))
+			return
 		default:
 			logrus.WithError(err).WithField("user_id", userID).Error("Failed to exchange game coins")
 			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
@@ -148,6 +176,7 @@ func ExchangeGameCoinsHandler(c *gin.Context) {
 		},
 		"new_game_balance":    newGameBalance,
 		"new_account_balance": newAccountBalance,
+		"replayed":            exchangeRecord.Replayed,
 	})
 }
 
@@ -257,8 +286,13 @@ func PurchaseGameCoinsHandler(c *gin.Context) {
 		return
 	}
 
+	idempotencyKey, ok := transferIdempotencyKey(c)
+	if !ok {
+		return
+	}
+
 	// Execute purchase
-	exchangeRecord, err := database.ExchangeUSDToGameCoins(userID, req.Amount)
+	exchangeRecord, err := database.ExchangeUSDToGameCoins(userID, req.Amount, idempotencyKey)
 	if err != nil {
 		switch err {
 		case database.ErrInsufficientBalance:
@@ -289,6 +323,13 @@ func PurchaseGameCoinsHandler(c *gin.Context) {
 				"account_balance_not_found",
 			))
 			return
+		case database.ErrIdempotencyKeyReused:
+			c.JSON(http.StatusConflict, models.NewErrorResponse(
+				"Idempotency-Key was already used for a different transfer",
+				"validation_error",
+				"idempotency_key_reused",
+			))
+			return
 		default:
 			logrus.WithError(err).WithField("user_id", userID).Error("Failed to purchase game coins")
 			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
@@ -324,6 +365,7 @@ func PurchaseGameCoinsHandler(c *gin.Context) {
 		},
 		"new_game_balance":    newGameBalance,
 		"new_account_balance": newAccountBalance,
+		"replayed":            exchangeRecord.Replayed,
 	})
 }
 
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// fileMultipartOverhead 上传请求体中除文件内容外的 multipart 开销上限
+const fileMultipartOverhead = 1 << 20
+
+// fileView 以 OpenAI file 对象的格式返回文件元数据
+func fileView(f *database.File) gin.H {
+	return gin.H{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt.Unix(),
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+	}
+}
+
+// fileServiceOrError 返回文件服务，未启用时写入 503
+func fileServiceOrError(c *gin.Context) *services.FileService {
+	service := services.GetFileService()
+	if service == nil {
+		c.JSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+			"Files API is not enabled",
+			"service_unavailable",
+			"files_disabled",
+		))
+	}
+	return service
+}
+
+// fileOwner 返回文件所属用户的密钥用量信息
+func fileOwner(c *gin.Context) *utils.UsageContextInfo {
+	return keyOwner(c, "Files", "file_owner_required")
+}
+
+// loadOwnedFile 获取调用方自己的文件，不存在或不属于调用方时返回 404
+func loadOwnedFile(c *gin.Context, id string, userID int64) *database.File {
+	file, err := database.GetFile(id)
+	if errors.Is(err, database.ErrFileNotFound) || (err == nil && file.UserID != userID) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"No such file: "+id,
+			"not_found",
+			"file_not_found",
+		))
+		return nil
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get file")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_query_failed",
+		))
+		return nil
+	}
+	return file
+}
+
+// UploadFile 以 multipart/form-data 上传文件（字段 file 与 purpose）
+// POST /v1/files
+func (h *Handler) UploadFile(c *gin.Context) {
+	service := fileServiceOrError(c)
+	if service == nil {
+		return
+	}
+	usageInfo := fileOwner(c)
+	if usageInfo == nil {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxBytes()+fileMultipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+				"File exceeds the maximum size of "+strconv.FormatInt(service.MaxBytes(), 10)+" bytes",
+				"invalid_request_error",
+				"file_too_large",
+			))
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Missing file: upload the content as multipart/form-data field 'file'",
+			"invalid_request_error",
+			"missing_file",
+		))
+		return
+	}
+	purpose := c.PostForm("purpose")
+	if !services.IsValidFilePurpose(purpose) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid purpose '"+purpose+"': expected one of batch, user_data, vision, assistants",
+			"invalid_request_error",
+			"invalid_purpose",
+		))
+		return
+	}
+	if header.Size > service.MaxBytes() {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			"File exceeds the maximum size of "+strconv.FormatInt(service.MaxBytes(), 10)+" bytes",
+			"invalid_request_error",
+			"file_too_large",
+		))
+		return
+	}
+
+	filename := filepath.Base(header.Filename)
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		if guessed := mime.TypeByExtension(filepath.Ext(filename)); guessed != "" {
+			contentType = guessed
+		}
+	}
+
+	content, err := header.Open()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to open uploaded file")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_upload_failed",
+		))
+		return
+	}
+	defer content.Close()
+
+	file, err := service.Upload(c.Request.Context(), usageInfo.UserID, filename, purpose, contentType, content, header.Size)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", usageInfo.UserID).Error("Failed to upload file")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_upload_failed",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_id": file.ID,
+		"user_id": file.UserID,
+		"purpose": file.Purpose,
+		"bytes":   file.Bytes,
+	}).Info("File uploaded")
+	c.JSON(http.StatusOK, fileView(file))
+}
+
+// ListFiles 列出调用方最近上传的文件
+// GET /v1/files?purpose=batch&limit=100
+func (h *Handler) ListFiles(c *gin.Context) {
+	usageInfo := fileOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	files, err := database.ListFilesByUser(usageInfo.UserID, c.Query("purpose"), limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list files")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_query_failed",
+		))
+		return
+	}
+
+	data := make([]gin.H, 0, len(files))
+	for _, f := range files {
+		data = append(data, fileView(f))
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// GetFile 返回文件元数据
+// GET /v1/files/:id
+func (h *Handler) GetFile(c *gin.Context) {
+	usageInfo := fileOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	if file := loadOwnedFile(c, c.Param("id"), usageInfo.UserID); file != nil {
+		c.JSON(http.StatusOK, fileView(file))
+	}
+}
+
+// GetFileContent 下载文件内容
+// GET /v1/files/:id/content
+func (h *Handler) GetFileContent(c *gin.Context) {
+	service := fileServiceOrError(c)
+	if service == nil {
+		return
+	}
+	usageInfo := fileOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	file := loadOwnedFile(c, c.Param("id"), usageInfo.UserID)
+	if file == nil {
+		return
+	}
+
+	content, err := service.Open(c.Request.Context(), file)
+	if err != nil {
+		logrus.WithError(err).WithField("file_id", file.ID).Error("Failed to open file content")
+		if errors.Is(err, services.ErrStoredFileMissing) {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"Content of file "+file.ID+" is no longer available",
+				"not_found",
+				"file_content_missing",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_read_failed",
+		))
+		return
+	}
+	defer content.Close()
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename})
+	if disposition == "" {
+		disposition = `attachment; filename="` + file.ID + `"`
+	}
+	c.Header("Content-Disposition", disposition)
+	c.DataFromReader(http.StatusOK, file.Bytes, contentType, content, nil)
+}
+
+// DeleteFile 删除文件元数据与内容
+// DELETE /v1/files/:id
+func (h *Handler) DeleteFile(c *gin.Context) {
+	service := fileServiceOrError(c)
+	if service == nil {
+		return
+	}
+	usageInfo := fileOwner(c)
+	if usageInfo == nil {
+		return
+	}
+	file := loadOwnedFile(c, c.Param("id"), usageInfo.UserID)
+	if file == nil {
+		return
+	}
+
+	if err := service.Delete(c.Request.Context(), file); err != nil && !errors.Is(err, database.ErrFileNotFound) {
+		logrus.WithError(err).WithField("file_id", file.ID).Error("Failed to delete file")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Internal server error",
+			"internal_error",
+			"file_delete_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": file.ID, "object": "file", "deleted": true})
+}
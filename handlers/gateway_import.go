@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminImportGatewayHandler imports users, tokens and usage history exported from one-api/new-api
+// POST /admin/import/gateway
+// Query params:
+//   - format: one-api or new-api (default one-api)
+//   - dry_run: validate only, nothing is written (default true)
+//   - quota_per_unit: source quota worth 1 USD (default 500000)
+func AdminImportGatewayHandler(c *gin.Context) {
+	// 导入会创建管理员账号并写入余额与令牌，仅限管理员
+	if !requireAdminRole(c) {
+		return
+	}
+
+	format := c.DefaultQuery("format", services.ImportFormatOneAPI)
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+
+	quotaPerUnit := float64(services.DefaultQuotaPerUnit)
+	if v := c.Query("quota_per_unit"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"quota_per_unit must be a positive number",
+				"validation_error",
+				"invalid_quota_per_unit",
+			))
+			return
+		}
+		quotaPerUnit = parsed
+	}
+
+	var export services.GatewayExport
+	if err := c.ShouldBindJSON(&export); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid export file: "+err.Error(),
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	result, err := services.ImportGatewayExport(format, &export, quotaPerUnit, dryRun)
+	if err != nil {
+		if err == services.ErrUnsupportedImportFormat {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				err.Error(),
+				"validation_error",
+				"unsupported_format",
+			))
+			return
+		}
+		logrus.WithError(err).Error("Gateway import failed")
+		c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse(
+			"Import failed, no data was written: "+err.Error(),
+			"import_error",
+			"import_failed",
+		))
+		return
+	}
+
+	if !dryRun {
+		if err := middleware.GetKeyManager().ReloadKeys(); err != nil {
+			logrus.WithError(err).Warn("Failed to reload keys after import")
+		}
+		logrus.WithFields(logrus.Fields{
+			"format":        format,
+			"users":         result.UsersCreated,
+			"tokens":        result.TokensCreated,
+			"transactions":  result.TransactionsImported,
+			"usage_records": result.UsageImported,
+		}).Info("Gateway data imported")
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GeminiGenerateContent 处理 Google Gemini API 请求，使基于 Google SDK 的工具无需修改即可接入
+// 请求转换为 Chat Completions 消息后复用相同的模型校验、路由与用量统计；
+// functionDeclarations 通过工具提示实现，输出中的 <tool_call> 块转换为 functionCall 部分
+// POST /v1beta/models/{model}:generateContent
+// POST /v1beta/models/{model}:streamGenerateContent（alt=sse 时输出 SSE，否则输出流式 JSON 数组）
+func (h *Handler) GeminiGenerateContent(c *gin.Context) {
+	// GeminiRequest 中间件已校验路径
+	model, method, _ := middleware.ParseGeminiAction(c.Param("action"))
+	stream := method == middleware.GeminiStreamGenerateContent
+
+	var request models.GeminiGenerateContentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logrus.WithError(err).Error("Failed to bind Gemini request")
+		c.JSON(http.StatusBadRequest, models.NewGeminiError(
+			http.StatusBadRequest,
+			"Invalid JSON payload received",
+			"INVALID_ARGUMENT",
+		))
+		return
+	}
+
+	chatRequest, err := request.ToChatCompletionRequest(model, stream)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewGeminiError(http.StatusBadRequest, err.Error(), "INVALID_ARGUMENT"))
+		return
+	}
+	if len(chatRequest.Messages) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewGeminiError(
+			http.StatusBadRequest,
+			"contents is not specified",
+			"INVALID_ARGUMENT",
+		))
+		return
+	}
+
+	if toolPrompt := services.NewToolExecutor().BuildFunctionToolPrompt(chatRequest.Tools, chatRequest.ToolChoice); toolPrompt != "" {
+		if chatRequest.Messages[0].Role == "system" {
+			chatRequest.Messages[0].Content = chatRequest.Messages[0].GetStringContent() + toolPrompt
+		} else {
+			chatRequest.Messages = append([]models.Message{{Role: "system", Content: toolPrompt}}, chatRequest.Messages...)
+		}
+		c.Set("has_tool_use", true)
+	}
+
+	chatGenerator, release := h.startChatCompletion(c, chatRequest)
+	if chatGenerator == nil {
+		return
+	}
+	defer release()
+
+	if stream {
+		sse := c.Query("alt") == "sse"
+		utils.SafeStreamWrapper(func(c *gin.Context, generator <-chan interface{}) {
+			utils.StreamGemini(c, generator, model, sse)
+		}, c, chatGenerator)
+	} else {
+		utils.NonStreamGemini(c, chatGenerator, model)
+	}
+}
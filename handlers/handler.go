@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"Curry2API-go/config"
+	"Curry2API-go/database"
 	"Curry2API-go/middleware"
 	"Curry2API-go/models"
 	"Curry2API-go/services"
+	"Curry2API-go/services/providers"
 	"Curry2API-go/utils"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,8 +22,9 @@ import (
 
 // Handler 处理器结构
 type Handler struct {
-	config        *config.Config
-	cursorService *services.CursorService
+	config         *config.Config
+	cursorService  *services.CursorService
+	providerRouter *services.ProviderRouter
 }
 
 // NewHandler 创建新的处理器
@@ -32,9 +37,17 @@ func NewHandler(cfg *config.Config) *Handler {
 	}
 }
 
-// ListModels 列出可用模型
+// SetProviderRouter 设置多提供商路由，用于将配置为直连的模型绕过 Cursor 处理
+func (h *Handler) SetProviderRouter(router *services.ProviderRouter) {
+	h.providerRouter = router
+}
+
+// ListModels 列出可用模型，设置了模型白名单的密钥只列出白名单内的模型
 func (h *Handler) ListModels(c *gin.Context) {
 	modelNames := h.config.GetModels()
+	if info, ok := middleware.GetKeyManager().GetKeyInfo(c.GetString("api_key")); ok && len(info.AllowedModels) > 0 {
+		modelNames = allowedModelNames(modelNames, info.AllowedModels)
+	}
 	modelList := make([]models.Model, 0, len(modelNames))
 
 	for _, modelID := range modelNames {
@@ -67,9 +80,6 @@ func (h *Handler) ListModels(c *gin.Context) {
 
 // ChatCompletions 处理聊天完成请求
 func (h *Handler) ChatCompletions(c *gin.Context) {
-	// Capture request start time for usage tracking
-	requestStartTime := time.Now()
-	
 	// 读取原始请求体用于调试
 	bodyBytes, _ := c.GetRawData()
 	bodyStr := string(bodyBytes)
@@ -95,21 +105,25 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 		return
 	}
 
-	// 如果使用 instructions 字段（Codex CLI），转换为 messages 格式
-	if request.Instructions != "" && len(request.Messages) == 0 {
-		logrus.Debug("Converting instructions to messages format for Codex CLI")
-		request.Messages = []models.Message{
-			{
-				Role:    "user",
-				Content: request.Instructions,
-			},
-		}
-		// Codex CLI 的流式响应格式不兼容，暂时禁用流式
-		if request.Stream {
-			logrus.Debug("Disabling stream for Codex CLI (format incompatibility)")
-			request.Stream = false
-		}
+	chatGenerator, release := h.startChatCompletion(c, &request)
+	if chatGenerator == nil {
+		return
+	}
+	defer release()
+
+	// 根据是否流式返回不同响应
+	if request.Stream {
+		utils.SafeStreamWrapper(utils.StreamChatCompletion, c, chatGenerator)
+	} else {
+		utils.NonStreamChatCompletion(c, chatGenerator)
 	}
+}
+
+// startChatCompletion 校验模型与访问权限、设置用量统计上下文、获取提供商槽位并启动生成
+// 出错时已写入错误响应并返回 nil；成功时调用方在输出结束后调用 release 释放槽位
+func (h *Handler) startChatCompletion(c *gin.Context, request *models.ChatCompletionRequest) (<-chan interface{}, func()) {
+	// Capture request start time for usage tracking
+	requestStartTime := time.Now()
 
 	// 验证模型
 	if !h.config.IsValidModel(request.Model) {
@@ -118,7 +132,7 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 			"invalid_request_error",
 			"model_not_found",
 		))
-		return
+		return nil, nil
 	}
 
 	// Check token model access restriction
@@ -137,7 +151,7 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 					"forbidden",
 					"model_not_allowed",
 				))
-				return
+				return nil, nil
 			}
 		}
 	}
@@ -161,7 +175,7 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 			"invalid_request_error",
 			"missing_messages",
 		))
-		return
+		return nil, nil
 	}
 
 	// 验证并调整max_tokens参数
@@ -177,6 +191,9 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 	// Store usage info and request details in context for downstream handlers
 	c.Set("request_start_time", requestStartTime)
 	c.Set("request_model", request.Model)
+	if request.Seed != nil {
+		c.Set("request_seed", *request.Seed)
+	}
 	if usageInfo != nil {
 		c.Set("usage_info", usageInfo)
 	}
@@ -184,12 +201,70 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 	// Set the tracking function in context
 	c.Set("track_usage_func", utils.UsageTrackingFunc(trackUsageFromContext))
 
+	// 配置为直连的提供商（如 OPENAI_DIRECT=true）直接处理其模型，不经过 Cursor session
+	// 工具调用目前只有 Cursor 路径支持，带 tools 的请求仍走 Cursor
+	// 路由规则指定的提供商优先；指定 cursor 或提供商不可用时按默认规则选择
+	var directProvider providers.ProviderClient
+	keyTags := requestKeyTags(c)
+	if len(request.Tools) == 0 {
+		if route := c.GetString("route_provider"); route != "" {
+			if route != "cursor" {
+				if provider, ok := h.providerRouter.GetRoutedProvider(route, keyTags); ok {
+					directProvider = provider
+				} else {
+					logrus.WithField("provider", route).Warn("Routing rule provider unavailable, using default routing")
+					directProvider, _ = h.providerRouter.GetDirectProvider(request.Model, keyTags)
+				}
+			}
+		} else {
+			directProvider, _ = h.providerRouter.GetDirectProvider(request.Model, keyTags)
+		}
+	}
+	providerName := "cursor"
+	if directProvider != nil {
+		providerName = directProvider.GetProviderName()
+	}
+	c.Set("request_provider", providerName)
+
+	// 获取实际处理请求的提供商的 RPM/TPM 限额与并发槽位（受信任密钥的高优先级请求优先）
+	// 流式请求排队时推送 queue_status 事件；超时返回按队列估算的 Retry-After，限额已满返回 429
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), providerName, priority, middleware.QueueFeedback(c, request.Stream))
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(providerName, priority),
+			models.NewErrorResponse(
+				"Provider is busy, please retry later",
+				"server_overloaded",
+				"provider_queue_timeout",
+			),
+			models.NewErrorResponse(
+				"Provider rate limit reached, please retry later",
+				"rate_limited",
+				"provider_rate_limited",
+			))
+		return nil, nil
+	}
+
+	if directProvider != nil {
+		chatGenerator := h.chatCompletionDirect(c, directProvider, request)
+		if chatGenerator == nil {
+			releaseSlot()
+			return nil, nil
+		}
+		return utils.WithUsageFallback(c.Request.Context(), chatGenerator, request), releaseSlot
+	}
+
+	if len(request.VendorExtra()) > 0 {
+		logrus.WithField("model", request.Model).Debug("extra_body ignored for Cursor-routed request")
+	}
+
 	// 调用Cursor服务
-	chatGenerator, session, err := h.cursorService.ChatCompletion(c.Request.Context(), &request)
+	chatGenerator, session, err := h.cursorService.ChatCompletion(middleware.ConversationContext(c), request)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to create chat completion")
 		middleware.HandleError(c, err)
-		return
+		releaseSlot()
+		return nil, nil
 	}
 
 	// 设置 cursor_session 到上下文中，用于使用统计
@@ -202,12 +277,56 @@ func (h *Handler) ChatCompletions(c *gin.Context) {
 		logrus.Debug("Using x-is-human fallback method")
 	}
 
-	// 根据是否流式返回不同响应
-	if request.Stream {
-		utils.SafeStreamWrapper(utils.StreamChatCompletion, c, chatGenerator)
-	} else {
-		utils.NonStreamChatCompletion(c, chatGenerator)
+	// 上游未返回用量时按 tokenizer 计算，避免漏计费
+	return utils.WithUsageFallback(c.Request.Context(), chatGenerator, request), releaseSlot
+}
+
+// requestKeyTags 返回请求所用 API 密钥的标签，达到消费上限的提供商只放行白名单标签的密钥
+func requestKeyTags(c *gin.Context) []string {
+	return middleware.GetKeyManager().GetKeyTags(c.GetString("api_key"))
+}
+
+// chatCompletionDirect 通过原生提供商启动生成，输出与用量统计复用与 Cursor 路径相同的处理；出错时写入错误响应并返回 nil
+func (h *Handler) chatCompletionDirect(c *gin.Context, provider providers.ProviderClient, request *models.ChatCompletionRequest) <-chan interface{} {
+	chatRequest := &models.ChatRequest{
+		Model:       request.Model,
+		Messages:    request.Messages,
+		Stream:      true,
+		Temperature: request.Temperature,
+		Seed:        request.Seed,
+	}
+	if request.MaxTokens != nil {
+		chatRequest.MaxTokens = *request.MaxTokens
+	}
+
+	// extra_body/vendor_params 原样透传给上游，只接受该提供商白名单内的参数
+	providerName := provider.GetProviderName()
+	extra := request.VendorExtra()
+	if disallowed := providers.DisallowedExtraParams(providerName, extra); len(disallowed) > 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			fmt.Sprintf("extra_body parameters not supported by %s: %s", providerName, strings.Join(disallowed, ", ")),
+			"invalid_request_error",
+			"unsupported_extra_body",
+		))
+		return nil
 	}
+	chatRequest.ExtraBody = extra
+	started := time.Now()
+	events, err := provider.ChatCompletion(c.Request.Context(), chatRequest)
+	h.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, request.Model, "")
+		services.LogProviderError(providerErr)
+		c.JSON(providerErr.HTTPStatus(), models.NewErrorResponse(
+			providerErr.GetUserFriendlyMessage(),
+			"provider_error",
+			string(providerErr.Code),
+		))
+		return nil
+	}
+
+	c.Set("cursor_session", providerName+"-direct")
+	return services.StreamEventsToChunks(events)
 }
 
 // ServeDocs 服务API文档页面
@@ -331,6 +450,17 @@ func (h *Handler) ServeDocs(c *gin.Context) {
 
 // Health 健康检查
 func (h *Handler) Health(c *gin.Context) {
+	if database.IsDegraded() {
+		c.JSON(http.StatusOK, gin.H{
+			"status":         "degraded",
+			"degraded_since": database.DegradedSince().Unix(),
+			"serving_cached": database.WithinDegradedWindow(),
+			"timestamp":      time.Now().Unix(),
+			"version":        "go-1.0.0",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "ok",
 		"timestamp": time.Now().Unix(),
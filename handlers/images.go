@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultImageModel 请求未指定模型时使用的图片模型（与 OpenAI 默认一致）
+const defaultImageModel = "dall-e-2"
+
+// ImageGenerations 处理 OpenAI 兼容的图片生成请求，路由到支持图片生成的提供商（OpenAI/自定义上游）
+// 按实际生成的图片张数计费；gpt-image 模型只返回 base64，请求 url 格式时以 data URL 返回
+// POST /v1/images/generations
+func (h *Handler) ImageGenerations(c *gin.Context) {
+	requestStartTime := time.Now()
+
+	var request models.ImageGenerationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: prompt is required",
+			"invalid_request_error",
+			"invalid_json",
+		))
+		return
+	}
+	if request.Model == "" {
+		request.Model = defaultImageModel
+	}
+	if request.N == 0 {
+		request.N = 1
+	}
+	if request.N < 1 || request.N > 10 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"n must be between 1 and 10",
+			"invalid_request_error",
+			"invalid_n",
+		))
+		return
+	}
+	responseFormat := request.ResponseFormat
+	switch responseFormat {
+	case "":
+		responseFormat = "url"
+	case "url", "b64_json":
+	default:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"response_format must be url or b64_json",
+			"invalid_request_error",
+			"invalid_response_format",
+		))
+		return
+	}
+	// gpt-image 模型不接受 response_format，总是返回 base64
+	if services.IsGPTImageModel(request.Model) {
+		request.ResponseFormat = ""
+	}
+
+	// Check token model access restriction
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		if err := middleware.GetKeyManager().CheckTokenModelAccess(apiKey, request.Model); err == middleware.ErrModelNotAllowed {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"Model not allowed - this token does not have access to model: "+request.Model,
+				"forbidden",
+				"model_not_allowed",
+			))
+			return
+		}
+	}
+
+	client, providerName, ok := h.providerRouter.GetImageProvider(request.Model, requestKeyTags(c))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"No provider available for image model: "+request.Model,
+			"invalid_request_error",
+			"model_not_found",
+		))
+		return
+	}
+
+	usageInfo, err := utils.ExtractUsageFromContext(c)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to extract usage context info")
+	}
+	c.Set("request_start_time", requestStartTime)
+	c.Set("request_model", request.Model)
+	if usageInfo != nil {
+		c.Set("usage_info", usageInfo)
+	}
+	c.Set("request_provider", providerName)
+
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), providerName, priority, nil)
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(providerName, priority),
+			models.NewErrorResponse(
+				"Provider is busy, please retry later",
+				"server_overloaded",
+				"provider_queue_timeout",
+			),
+			models.NewErrorResponse(
+				"Provider rate limit reached, please retry later",
+				"rate_limited",
+				"provider_rate_limited",
+			))
+		return
+	}
+	defer releaseSlot()
+
+	started := time.Now()
+	resp, err := client.GenerateImages(c.Request.Context(), &request)
+	h.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, request.Model, "")
+		services.LogProviderError(providerErr)
+		c.JSON(providerErr.HTTPStatus(), models.NewErrorResponse(
+			providerErr.GetUserFriendlyMessage(),
+			"provider_error",
+			string(providerErr.Code),
+		))
+		return
+	}
+
+	// 按客户端请求的格式返回：base64 结果在请求 url 时转为 data URL
+	if responseFormat == "url" {
+		mimeType := "image/png"
+		if format := strings.ToLower(request.OutputFormat); format == "jpeg" || format == "webp" {
+			mimeType = "image/" + format
+		}
+		for i := range resp.Data {
+			if resp.Data[i].URL == "" && resp.Data[i].B64JSON != "" {
+				resp.Data[i].URL = "data:" + mimeType + ";base64," + resp.Data[i].B64JSON
+				resp.Data[i].B64JSON = ""
+			}
+		}
+	}
+	if resp.Created == 0 {
+		resp.Created = time.Now().Unix()
+	}
+
+	// 按实际返回的图片张数计费
+	cost := services.ImagePrice(request.Model, request.Size, request.Quality) * float64(len(resp.Data))
+	c.Set("request_cost", cost)
+	c.Set("cursor_session", providerName+"-direct")
+	trackUsageFromContext(c, services.ImageUsageTokens(resp.Usage), http.StatusOK, "")
+
+	c.JSON(http.StatusOK, resp)
+}
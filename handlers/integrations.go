@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// integrationKey 用户可用于客户端配置的密钥摘要（不含完整密钥）
+type integrationKey struct {
+	MaskedKey string   `json:"masked_key"`
+	TokenName string   `json:"token_name,omitempty"`
+	Models    []string `json:"models"`
+}
+
+// publicBaseURL 返回客户端访问网关使用的根地址：优先使用配置的 PUBLIC_BASE_URL，否则按请求（含反向代理头）推断
+func (h *Handler) publicBaseURL(c *gin.Context) string {
+	if h.config.PublicBaseURL != "" {
+		return h.config.PublicBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
+// usableIntegrationKeys 返回用户处于启用状态且未过期的密钥
+func usableIntegrationKeys(userID int64) []*middleware.KeyInfo {
+	now := time.Now()
+	var result []*middleware.KeyInfo
+	for _, key := range middleware.GetKeyManager().ListKeysByUser(userID) {
+		if !key.IsActive || (key.ExpiresAt != nil && key.ExpiresAt.Before(now)) {
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+// integrationModels 返回密钥可调用的模型：有模型白名单时为白名单，否则为网关配置的全部模型
+func (h *Handler) integrationModels(key *middleware.KeyInfo) []string {
+	if len(key.AllowedModels) > 0 {
+		return key.AllowedModels
+	}
+	return h.config.GetModels()
+}
+
+// ListIntegrations 返回支持的客户端工具及当前用户可用于配置的密钥
+// GET /api/integrations
+func (h *Handler) ListIntegrations(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	keys := make([]integrationKey, 0)
+	for _, key := range usableIntegrationKeys(userID) {
+		keys = append(keys, integrationKey{
+			MaskedKey: key.MaskedKey,
+			TokenName: key.TokenName,
+			Models:    h.integrationModels(key),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_url":     h.publicBaseURL(c),
+		"integrations": services.Integrations,
+		"keys":         keys,
+	})
+}
+
+// GetIntegrationConfig 生成指定客户端工具的可直接粘贴的配置（地址、密钥、模型映射）
+// 查询参数 key 按脱敏密钥或令牌名称选择密钥，默认使用第一个可用密钥
+// GET /api/integrations/:id
+func (h *Handler) GetIntegrationConfig(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	keys := usableIntegrationKeys(userID)
+	if len(keys) == 0 {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"没有可用的 API 密钥，请先创建密钥",
+			"not_found",
+			"no_api_key",
+		))
+		return
+	}
+
+	key := keys[0]
+	if selector := c.Query("key"); selector != "" {
+		key = nil
+		for _, candidate := range keys {
+			if candidate.MaskedKey == selector || candidate.TokenName == selector {
+				key = candidate
+				break
+			}
+		}
+		if key == nil {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"指定的密钥不存在或不可用",
+				"not_found",
+				"key_not_found",
+			))
+			return
+		}
+	}
+
+	cfg, err := services.BuildIntegrationConfig(c.Param("id"), h.publicBaseURL(c), key.Key, h.integrationModels(key))
+	if errors.Is(err, services.ErrUnknownIntegration) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"不支持的集成: "+c.Param("id"),
+			"not_found",
+			"integration_not_found",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"masked_key": key.MaskedKey,
+		"token_name": key.TokenName,
+		"config":     cfg,
+	})
+}
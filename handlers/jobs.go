@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"Curry2API-go/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminListJobsHandler 获取后台任务（如历史数据迁移）的进度
+// GET /admin/jobs
+func AdminListJobsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": services.ListJobs()})
+}
+
+// AdminRunVacuumHandler 立即清理过期会话、已用/过期验证码与过期 OAuth state，返回各表删除条数
+// POST /admin/jobs/vacuum
+func AdminRunVacuumHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"counts": services.RunVacuum()})
+}
+
+// AdminRunChatArchiveHandler 立即压缩归档长期未活动对话的消息，返回归档的对话数、消息数与节省的字节数
+// POST /admin/jobs/chat-archive
+func AdminRunChatArchiveHandler(c *gin.Context) {
+	archiver := services.GetChatArchiveService()
+	if !archiver.Enabled() {
+		writeError(c, http.StatusBadRequest, "chat_archive_disabled", "未启用对话归档，请设置 CHAT_ARCHIVE_DAYS")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"counts": archiver.Run()})
+}
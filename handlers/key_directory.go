@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 密钥状态（由启用状态、过期时间与额度推导）
+const (
+	keyStatusActive         = "active"
+	keyStatusDisabled       = "disabled"
+	keyStatusExpired        = "expired"
+	keyStatusQuotaExhausted = "quota_exhausted"
+)
+
+// keyDirectoryEntry 密钥目录条目：密钥信息加上推导出的状态与额度使用率
+type keyDirectoryEntry struct {
+	*middleware.KeyInfo
+	Status           string   `json:"status"`
+	QuotaUtilization *float64 `json:"quota_utilization,omitempty"` // 已用额度占额度上限的百分比，无上限时为空
+}
+
+func newKeyDirectoryEntry(info *middleware.KeyInfo, now time.Time) keyDirectoryEntry {
+	entry := keyDirectoryEntry{KeyInfo: info, Status: keyStatusActive}
+	if info.QuotaLimit != nil && *info.QuotaLimit > 0 {
+		utilization := info.QuotaUsed / *info.QuotaLimit * 100
+		entry.QuotaUtilization = &utilization
+	}
+	switch {
+	case !info.IsActive:
+		entry.Status = keyStatusDisabled
+	case info.ExpiresAt != nil && now.After(*info.ExpiresAt):
+		entry.Status = keyStatusExpired
+	case info.QuotaLimit != nil && info.QuotaUsed >= *info.QuotaLimit:
+		entry.Status = keyStatusQuotaExhausted
+	}
+	return entry
+}
+
+// keyDirectorySorters 可排序的列，均按升序比较
+var keyDirectorySorters = map[string]func(a, b keyDirectoryEntry) bool{
+	"created_at": func(a, b keyDirectoryEntry) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"usage_count": func(a, b keyDirectoryEntry) bool {
+		return a.UsageCount < b.UsageCount
+	},
+	"last_used_at": func(a, b keyDirectoryEntry) bool {
+		// 从未使用的密钥排在最前
+		if a.LastUsedAt == nil || b.LastUsedAt == nil {
+			return a.LastUsedAt == nil && b.LastUsedAt != nil
+		}
+		return a.LastUsedAt.Before(*b.LastUsedAt)
+	},
+	"quota_utilization": func(a, b keyDirectoryEntry) bool {
+		// 无额度上限的密钥排在最前
+		if a.QuotaUtilization == nil || b.QuotaUtilization == nil {
+			return a.QuotaUtilization == nil && b.QuotaUtilization != nil
+		}
+		return *a.QuotaUtilization < *b.QuotaUtilization
+	},
+}
+
+// matchesKeySearch 按所属用户名、令牌名称（包含，不区分大小写）或密钥前缀匹配
+func matchesKeySearch(info *middleware.KeyInfo, query string) bool {
+	if strings.HasPrefix(info.Key, query) {
+		return true
+	}
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(info.Username), query) ||
+		strings.Contains(strings.ToLower(info.TokenName), query)
+}
+
+// buildKeyDirectory 按查询参数搜索（q）、筛选状态（status）、排序（sort、order）并分页（limit、offset）。
+// 未指定 limit 时返回全部匹配的密钥；返回匹配总数与当前页条目，参数无效时写入错误响应并返回 false
+func buildKeyDirectory(c *gin.Context, keys []*middleware.KeyInfo) (int, []keyDirectoryEntry, bool) {
+	sortBy := c.DefaultQuery("sort", "created_at")
+	less, ok := keyDirectorySorters[sortBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"无效的排序字段，可选 created_at、usage_count、last_used_at、quota_utilization",
+			"validation_error",
+			"invalid_sort",
+		))
+		return 0, nil, false
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"order 只能为 asc 或 desc",
+			"validation_error",
+			"invalid_order",
+		))
+		return 0, nil, false
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	status := c.Query("status")
+	now := time.Now()
+	entries := make([]keyDirectoryEntry, 0, len(keys))
+	for _, info := range keys {
+		if query != "" && !matchesKeySearch(info, query) {
+			continue
+		}
+		entry := newKeyDirectoryEntry(info, now)
+		if status != "" && entry.Status != status {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if order == "asc" {
+			return less(entries[i], entries[j])
+		}
+		return less(entries[j], entries[i])
+	})
+
+	total := len(entries)
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	entries = entries[offset:]
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return total, entries, true
+}
+
+// GetKeyDetailsHandler 密钥详情：用量统计（usage_records）、额度状态与访问限制
+// 普通用户只能查看自己的密钥；?days=N 只统计最近 N 天的用量
+// GET /admin/keys/:key/details
+func GetKeyDetailsHandler(c *gin.Context) {
+	key := c.Param("key")
+	info, exists := middleware.GetKeyManager().GetKeyInfo(key)
+	if !exists || !canManageKey(c, info) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"validation_error",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return
+	}
+
+	var since *time.Time
+	if daysStr := c.Query("days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 1 {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"days 必须为正整数",
+				"validation_error",
+				"invalid_days",
+			))
+			return
+		}
+		t := time.Now().AddDate(0, 0, -days)
+		since = &t
+	}
+
+	usage, err := database.GetKeyUsageSummary(key, since)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get key usage summary")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_key_usage_failed",
+		))
+		return
+	}
+
+	entry := newKeyDirectoryEntry(info, time.Now())
+	quota := gin.H{
+		"limit":       info.QuotaLimit,
+		"used":        info.QuotaUsed,
+		"utilization": entry.QuotaUtilization,
+	}
+	if info.QuotaLimit != nil {
+		quota["remaining"] = *info.QuotaLimit - info.QuotaUsed
+	}
+	// 所属用户的余额同样限制密钥能否调用
+	if info.UserID != nil {
+		if balance, err := database.GetUserBalance(*info.UserID); err == nil {
+			quota["owner_balance"] = balance.Balance
+			quota["owner_balance_status"] = balance.Status
+		} else if err != database.ErrBalanceNotFound {
+			logrus.WithError(err).Warn("Failed to get key owner balance")
+		}
+	}
+
+	flush := middleware.GetKeyManager().ResolveStreamFlush(key)
+	c.JSON(http.StatusOK, gin.H{
+		"key":    entry,
+		"status": entry.Status,
+		"usage":  usage,
+		"quota":  quota,
+		"restrictions": gin.H{
+			"is_active":        info.IsActive,
+			"expires_at":       info.ExpiresAt,
+			"allowed_models":   info.AllowedModels,
+			"signing_enabled":  info.SigningEnabled,
+			"priority_trusted": info.PriorityTrusted,
+			"tags":             info.Tags,
+			"scopes":           info.Scopes,
+			"allowed_ips":      info.AllowedIPs,
+			"stream_flush":     flush,
+		},
+	})
+}
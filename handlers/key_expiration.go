@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxKeyExtendDays 单次延长过期时间的最大天数
+const maxKeyExtendDays = 3650
+
+// canManageKey 管理员可管理全部密钥，普通用户只能管理自己的密钥
+func canManageKey(c *gin.Context, info *middleware.KeyInfo) bool {
+	if c.GetString("role") == "admin" {
+		return true
+	}
+	userID, _ := c.Get("user_id")
+	return info.UserID != nil && userID == *info.UserID
+}
+
+// UpdateKeyExpirationRequest 设置或延长密钥过期时间，expires_at 与 extend_days 二选一
+type UpdateKeyExpirationRequest struct {
+	ExpiresAt  *string `json:"expires_at"`  // RFC3339 时间，空字符串表示永不过期
+	ExtendDays int     `json:"extend_days"` // 在当前过期时间（已过期则从现在起）基础上延长的天数
+}
+
+// UpdateKeyExpirationHandler 设置或延长密钥过期时间，已过期的密钥延长后即可恢复使用
+// 普通用户只能修改自己的密钥
+// PUT /admin/keys/:key/expiration
+func UpdateKeyExpirationHandler(c *gin.Context) {
+	key := c.Param("key")
+	km := middleware.GetKeyManager()
+	info, exists := km.GetKeyInfo(key)
+	if !exists || !canManageKey(c, info) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"validation_error",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return
+	}
+
+	var req UpdateKeyExpirationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.ExpiresAt == nil) == (req.ExtendDays == 0) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求须且只能包含 expires_at 或 extend_days 之一",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	switch {
+	case req.ExpiresAt != nil && *req.ExpiresAt != "":
+		parsed, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil || !parsed.After(now) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"expires_at 必须为晚于当前时间的 RFC3339 时间",
+				"validation_error",
+				"invalid_expires_at",
+			))
+			return
+		}
+		expiresAt = &parsed
+	case req.ExtendDays != 0:
+		if req.ExtendDays < 1 || req.ExtendDays > maxKeyExtendDays {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"extend_days 必须为 1-"+strconv.Itoa(maxKeyExtendDays)+" 的整数",
+				"validation_error",
+				"invalid_extend_days",
+			))
+			return
+		}
+		if info.ExpiresAt == nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"该密钥永不过期，无需延长",
+				"validation_error",
+				"key_never_expires",
+			))
+			return
+		}
+		base := *info.ExpiresAt
+		if base.Before(now) {
+			base = now
+		}
+		extended := base.AddDate(0, 0, req.ExtendDays)
+		expiresAt = &extended
+	}
+
+	if err := km.SetKeyExpiration(key, expiresAt); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			c.JSON(statusCode, models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_expiration_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "密钥过期时间已更新",
+		"key":        maskKey(key),
+		"expires_at": expiresAt,
+	})
+}
+
+// ListExpiringKeysHandler 列出 days 天内（默认 30，最多 365）将要过期及已过期的密钥，按过期时间升序
+// 管理员查看全部密钥，普通用户只查看自己的密钥
+// GET /admin/keys/expiring
+func ListExpiringKeysHandler(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"days 须为 1-365 的整数",
+			"validation_error",
+			"invalid_days",
+		))
+		return
+	}
+
+	km := middleware.GetKeyManager()
+	var keys []*middleware.KeyInfo
+	if c.GetString("role") == "admin" {
+		keys = km.ListKeys()
+	} else {
+		userID, _ := c.Get("user_id")
+		id, _ := userID.(int64)
+		keys = km.ListKeysByUser(id)
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, days)
+	entries := make([]keyDirectoryEntry, 0)
+	for _, info := range keys {
+		if info.ExpiresAt != nil && info.ExpiresAt.Before(cutoff) {
+			entries = append(entries, newKeyDirectoryEntry(info, now))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ExpiresAt.Before(*entries[j].ExpiresAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":  days,
+		"total": len(entries),
+		"keys":  entries,
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateKeyAllowedIPsRequest 更新密钥 IP 白名单请求
+type UpdateKeyAllowedIPsRequest struct {
+	AllowedIPs []string `json:"allowed_ips"` // IP 或 CIDR，空列表表示不限制
+}
+
+// UpdateKeyAllowedIPsHandler 设置密钥 IP 白名单，从白名单外的地址调用返回 403 ip_not_allowed
+// 普通用户只能修改自己的密钥
+// PUT /admin/keys/:key/allowed-ips
+func UpdateKeyAllowedIPsHandler(c *gin.Context) {
+	key := c.Param("key")
+	km := middleware.GetKeyManager()
+	info, exists := km.GetKeyInfo(key)
+	if !exists || !canManageKey(c, info) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"validation_error",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return
+	}
+
+	var req UpdateKeyAllowedIPsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	allowedIPs, err := middleware.NormalizeAllowedIPs(req.AllowedIPs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"无效的 IP 白名单："+err.Error(),
+			"validation_error",
+			"invalid_allowed_ips",
+		))
+		return
+	}
+
+	if err := km.SetKeyAllowedIPs(key, allowedIPs); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			c.JSON(statusCode, models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_allowed_ips_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "密钥 IP 白名单已更新",
+		"key":         maskKey(key),
+		"allowed_ips": allowedIPs,
+		"client_ip":   c.ClientIP(), // 便于确认当前地址是否在白名单内
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allowedModelNames 返回 modelNames 中位于白名单内的模型，保持原有顺序
+func allowedModelNames(modelNames, allowedModels []string) []string {
+	allowed := make(map[string]bool, len(allowedModels))
+	for _, model := range allowedModels {
+		allowed[model] = true
+	}
+	result := make([]string, 0, len(allowedModels))
+	for _, model := range modelNames {
+		if allowed[model] {
+			result = append(result, model)
+		}
+	}
+	return result
+}
+
+// UpdateKeyModelsRequest 更新密钥模型白名单请求
+type UpdateKeyModelsRequest struct {
+	AllowedModels []string `json:"allowed_models"` // 空列表表示可调用全部模型
+}
+
+// UpdateKeyModels 设置密钥的模型白名单，调用白名单外的模型返回 403 model_not_allowed
+// 普通用户只能修改自己的密钥
+// PUT /admin/keys/:key/models
+func (h *Handler) UpdateKeyModels(c *gin.Context) {
+	key := c.Param("key")
+	km := middleware.GetKeyManager()
+	info, exists := km.GetKeyInfo(key)
+	if !exists || !canManageKey(c, info) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"validation_error",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return
+	}
+
+	var req UpdateKeyModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"无效的请求格式",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	// 去除空白与重复项，只接受网关已配置的模型
+	allowedModels := make([]string, 0, len(req.AllowedModels))
+	seen := make(map[string]bool, len(req.AllowedModels))
+	for _, model := range req.AllowedModels {
+		model = strings.TrimSpace(model)
+		if model == "" || seen[model] {
+			continue
+		}
+		if !h.config.IsValidModel(model) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"未知的模型："+model,
+				"validation_error",
+				"model_not_found",
+			))
+			return
+		}
+		seen[model] = true
+		allowedModels = append(allowedModels, model)
+	}
+
+	if err := km.SetKeyAllowedModels(key, allowedModels); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			c.JSON(statusCode, models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"update_key_models_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "密钥模型白名单已更新",
+		"key":            maskKey(key),
+		"allowed_models": allowedModels,
+	})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// keyQuotaResponse 密钥额度：上限、已用、剩余与使用率
+func keyQuotaResponse(key string, limit *float64, used float64) gin.H {
+	quota := gin.H{
+		"key":      maskKey(key),
+		"limit":    limit,
+		"used":     used,
+		"exceeded": limit != nil && used >= *limit,
+	}
+	if limit != nil {
+		quota["remaining"] = max(*limit-used, 0)
+		if *limit > 0 {
+			quota["utilization"] = used / *limit * 100
+		}
+	}
+	return quota
+}
+
+// GetKeyQuotaHandler 查看密钥额度（美元）：上限、已用与剩余，已用额度达到上限后密钥调用返回 402 token_quota_exceeded
+// 普通用户只能查看自己的密钥
+// GET /admin/keys/:key/quota
+func GetKeyQuotaHandler(c *gin.Context) {
+	key := c.Param("key")
+	km := middleware.GetKeyManager()
+	info, exists := km.GetKeyInfo(key)
+	if !exists || !canManageKey(c, info) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"validation_error",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return
+	}
+
+	limit, used, err := km.GetKeyQuota(key)
+	if err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			))
+			return
+		}
+		logrus.WithError(err).Error("Failed to get key quota")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_key_quota_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, keyQuotaResponse(key, limit, used))
+}
+
+// ResetKeyQuotaHandler 将密钥已用额度清零，超出额度的密钥随即恢复可用（仅管理员）
+// POST /admin/keys/:key/quota/reset
+func ResetKeyQuotaHandler(c *gin.Context) {
+	if c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+			"仅管理员可重置密钥额度",
+			"permission_error",
+			"admin_only",
+		))
+		return
+	}
+
+	key := c.Param("key")
+	km := middleware.GetKeyManager()
+	if err := km.ResetKeyQuota(key); err != nil {
+		if keyErr, ok := err.(*middleware.KeyError); ok {
+			statusCode := http.StatusBadRequest
+			if keyErr.Code == "key_not_found" {
+				statusCode = http.StatusNotFound
+			}
+			c.JSON(statusCode, models.NewErrorResponse(
+				keyErr.Message,
+				"validation_error",
+				keyErr.Code,
+			))
+			return
+		}
+		logrus.WithError(err).Error("Failed to reset key quota")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			err.Error(),
+			"internal_error",
+			"reset_key_quota_failed",
+		))
+		return
+	}
+
+	var limit *float64
+	if info, exists := km.GetKeyInfo(key); exists {
+		limit = info.QuotaLimit
+	}
+	response := keyQuotaResponse(key, limit, 0)
+	response["message"] = "密钥额度已重置"
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RotateKeysRequest 批量轮换密钥请求
+type RotateKeysRequest struct {
+	UserID             int64  `json:"user_id" binding:"required"`
+	GracePeriodSeconds int    `json:"grace_period_seconds"` // 旧密钥继续可用的时长，0 表示立即失效
+	Reason             string `json:"reason"`
+}
+
+// maxRotationGrace 宽限期上限，避免泄露的密钥长期可用
+const maxRotationGrace = 7 * 24 * time.Hour
+
+// AdminRotateKeys 轮换用户的全部可用密钥（疑似泄露时使用），新密钥仅在响应中返回一次
+// POST /admin/keys/rotate
+func (h *Handler) AdminRotateKeys(c *gin.Context) {
+	var req RotateKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserID <= 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"user_id 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	grace := time.Duration(req.GracePeriodSeconds) * time.Second
+	if grace < 0 || grace > maxRotationGrace {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"grace_period_seconds 必须在 0 到 604800 之间",
+			"validation_error",
+			"invalid_grace_period",
+		))
+		return
+	}
+
+	var actorID *int64
+	if id, ok := c.Get("user_id"); ok {
+		if v, ok := id.(int64); ok && v > 0 {
+			actorID = &v
+		}
+	}
+
+	result, err := services.RotateUserKeys(h.config, actorID, req.UserID, grace, req.Reason)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("用户不存在", "not_found", "user_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to rotate API keys")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"rotate_keys_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RotateKeyRequest 轮换单个密钥请求
+type RotateKeyRequest struct {
+	GracePeriodSeconds int    `json:"grace_period_seconds"` // 旧密钥继续可用的时长，0 表示立即失效
+	Reason             string `json:"reason"`
+}
+
+// rotateKey 轮换单个密钥，新密钥仅在响应中返回一次；ownOnly 时只能轮换当前用户自己的密钥
+func rotateKey(c *gin.Context, ownOnly bool) {
+	key := c.Param("key")
+	info, exists := middleware.GetKeyManager().GetKeyInfo(key)
+	if exists && ownOnly {
+		userID, _ := c.Get("user_id")
+		exists = info.UserID != nil && userID == *info.UserID
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"validation_error",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return
+	}
+
+	// 请求体可省略，此时旧密钥立即失效
+	var req RotateKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"无效的请求格式",
+				"validation_error",
+				"invalid_request",
+			))
+			return
+		}
+	}
+
+	grace := time.Duration(req.GracePeriodSeconds) * time.Second
+	if grace < 0 || grace > maxRotationGrace {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"grace_period_seconds 必须在 0 到 604800 之间",
+			"validation_error",
+			"invalid_grace_period",
+		))
+		return
+	}
+
+	var actorID *int64
+	if id, ok := c.Get("user_id"); ok {
+		if v, ok := id.(int64); ok && v > 0 {
+			actorID = &v
+		}
+	}
+
+	result, err := services.RotateKey(actorID, key, grace, req.Reason)
+	if err != nil {
+		if err == database.ErrKeyNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"密钥不存在或已被轮换",
+				"not_found",
+				"key_not_found",
+			))
+			return
+		}
+		logrus.WithError(err).Error("Failed to rotate API key")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"rotate_key_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// AdminRotateKeyHandler 轮换任意单个密钥（疑似泄露时使用），旧密钥可在宽限期内继续使用
+// POST /admin/keys/:key/rotate
+func AdminRotateKeyHandler(c *gin.Context) {
+	rotateKey(c, false)
+}
+
+// RotateOwnKeyHandler 用户轮换自己的密钥，旧密钥可在宽限期内继续使用，便于逐步替换客户端配置
+// POST /profile/keys/:key/rotate
+func RotateOwnKeyHandler(c *gin.Context) {
+	rotateKey(c, true)
+}
+
+// ListAuditLogsHandler 获取审计记录
+// GET /admin/audit-logs?action=api_keys.rotate&limit=50
+func ListAuditLogsHandler(c *gin.Context) {
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	logs, err := database.ListAuditLogs(c.Query("action"), limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list audit logs")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_audit_logs_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logs": logs})
+}
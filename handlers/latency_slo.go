@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// LatencySLORequest 创建/更新延迟预算请求
+type LatencySLORequest struct {
+	Name               string  `json:"name" binding:"required"`
+	Endpoint           string  `json:"endpoint"`
+	Model              string  `json:"model"`
+	Metric             string  `json:"metric"`
+	Percentile         float64 `json:"percentile"`
+	ThresholdMs        int     `json:"threshold_ms" binding:"required"`
+	ConsecutiveWindows int     `json:"consecutive_windows"`
+	MinSamples         int     `json:"min_samples"`
+	IsActive           *bool   `json:"is_active"`
+}
+
+// toSLO 校验请求并填充默认值
+func (r *LatencySLORequest) toSLO() (*database.LatencySLO, string) {
+	slo := &database.LatencySLO{
+		Name:               r.Name,
+		Endpoint:           r.Endpoint,
+		Model:              r.Model,
+		Metric:             r.Metric,
+		Percentile:         r.Percentile,
+		ThresholdMs:        r.ThresholdMs,
+		ConsecutiveWindows: r.ConsecutiveWindows,
+		MinSamples:         r.MinSamples,
+		IsActive:           r.IsActive == nil || *r.IsActive,
+	}
+	if slo.Metric == "" {
+		slo.Metric = database.LatencyMetricTTFT
+	}
+	if slo.Percentile == 0 {
+		slo.Percentile = 95
+	}
+	if slo.ConsecutiveWindows == 0 {
+		slo.ConsecutiveWindows = 3
+	}
+	if slo.MinSamples == 0 {
+		slo.MinSamples = 10
+	}
+
+	switch {
+	case slo.Metric != database.LatencyMetricTTFT && slo.Metric != database.LatencyMetricTotal:
+		return nil, "metric must be ttft or total"
+	case slo.Percentile <= 0 || slo.Percentile > 100:
+		return nil, "percentile must be between 0 and 100"
+	case slo.ThresholdMs <= 0:
+		return nil, "threshold_ms must be positive"
+	case slo.ConsecutiveWindows < 1 || slo.MinSamples < 1:
+		return nil, "consecutive_windows and min_samples must be positive"
+	}
+	return slo, ""
+}
+
+// ListLatencySLOsHandler 列出延迟预算及其实时评估状态
+// GET /admin/slos
+func ListLatencySLOsHandler(c *gin.Context) {
+	slos, err := database.ListLatencySLOs(false)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list latency SLOs")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_slos_failed",
+		))
+		return
+	}
+
+	status := map[int64]*services.LatencySLOStatus{}
+	if monitor := services.GetLatencySLOMonitor(); monitor != nil {
+		status = monitor.Status()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"slos":   slos,
+		"status": status,
+	})
+}
+
+// CreateLatencySLOHandler 创建延迟预算
+// POST /admin/slos
+func CreateLatencySLOHandler(c *gin.Context) {
+	var req LatencySLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"name 和 threshold_ms 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	slo, msg := req.toSLO()
+	if slo == nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.CreateLatencySLO(slo); err != nil {
+		logrus.WithError(err).Error("Failed to create latency SLO")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"create_slo_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusCreated, slo)
+}
+
+// UpdateLatencySLOHandler 更新延迟预算
+// PUT /admin/slos/:id
+func UpdateLatencySLOHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	var req LatencySLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"name 和 threshold_ms 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	slo, msg := req.toSLO()
+	if slo == nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+	slo.ID = id
+
+	if err := database.UpdateLatencySLO(slo); err != nil {
+		if err == database.ErrLatencySLONotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("延迟预算不存在", "not_found", "slo_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to update latency SLO")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_slo_failed",
+		))
+		return
+	}
+
+	updated, err := database.GetLatencySLO(id)
+	if err != nil {
+		updated = slo
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteLatencySLOHandler 删除延迟预算
+// DELETE /admin/slos/:id
+func DeleteLatencySLOHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	if err := database.DeleteLatencySLO(id); err != nil {
+		if err == database.ErrLatencySLONotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("延迟预算不存在", "not_found", "slo_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to delete latency SLO")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"delete_slo_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "延迟预算已删除"})
+}
+
+// ListLatencyAlertsHandler 获取延迟预算告警
+// GET /admin/slos/alerts?open=true&limit=50
+func ListLatencyAlertsHandler(c *gin.Context) {
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	alerts, err := database.ListLatencyAlerts(c.Query("open") == "true", limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list latency alerts")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_alerts_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
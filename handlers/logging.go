@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateLogLevelsRequest 调整日志级别请求；Reset 为 true 时清除运行时覆盖，恢复 LOG_LEVEL/LOG_LEVELS 配置
+type UpdateLogLevelsRequest struct {
+	Levels map[string]string `json:"levels"`
+	Reset  bool              `json:"reset"`
+}
+
+// ApplyLogLevels 按 LOG_LEVEL、LOG_LEVELS 及管理员保存的覆盖设置各子系统日志级别
+func ApplyLogLevels(cfg *config.Config) {
+	base, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		base = logrus.InfoLevel
+	}
+	utils.SetAllLogLevels(base)
+
+	if overrides, err := utils.ParseLogLevels(cfg.LogLevels); err != nil {
+		logrus.WithError(err).Warn("Ignoring invalid LOG_LEVELS")
+	} else {
+		setLogLevels(overrides)
+	}
+
+	stored, err := database.GetLogLevels()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load stored log levels")
+		return
+	}
+	if overrides, err := utils.ValidateLogLevels(stored); err != nil {
+		logrus.WithError(err).Warn("Ignoring invalid stored log levels")
+	} else {
+		setLogLevels(overrides)
+	}
+}
+
+func setLogLevels(levels map[string]logrus.Level) {
+	for name, level := range levels {
+		utils.SetLogLevel(name, level)
+	}
+}
+
+// AdminGetLogLevels 获取各子系统当前日志级别
+// GET /admin/logging
+func (h *Handler) AdminGetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"levels":     utils.LogLevels(),
+		"subsystems": utils.LogSubsystems,
+	})
+}
+
+// AdminUpdateLogLevels 在运行时调整子系统日志级别，立即生效并在重启后保留
+// PUT /admin/logging
+func (h *Handler) AdminUpdateLogLevels(c *gin.Context) {
+	var req UpdateLogLevelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	levels, err := utils.ValidateLogLevels(req.Levels)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), "validation_error", "invalid_request"))
+		return
+	}
+
+	stored := make(map[string]string)
+	if !req.Reset {
+		if stored, err = database.GetLogLevels(); err == nil && stored == nil {
+			stored = make(map[string]string)
+		}
+	}
+	if err == nil {
+		for name, level := range levels {
+			stored[name] = level.String()
+		}
+		err = database.SaveLogLevels(stored)
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to save log levels")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_log_levels_failed",
+		))
+		return
+	}
+
+	if req.Reset {
+		ApplyLogLevels(h.config)
+	} else {
+		setLogLevels(levels)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"levels": req.Levels,
+		"reset":  req.Reset,
+	}).Info("Log levels updated by admin")
+	c.JSON(http.StatusOK, gin.H{
+		"levels":     utils.LogLevels(),
+		"subsystems": utils.LogSubsystems,
+	})
+}
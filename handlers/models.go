@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"Curry2API-go/services"
 	"net/http"
 	"strings"
 
@@ -831,9 +832,19 @@ func GetModelMarketplaceHandler(c *gin.Context) {
 		endpointTypes = append(endpointTypes, endpointType)
 	}
 
+	var userID int64
+	if id, ok := c.Get("user_id"); ok {
+		userID, _ = id.(int64)
+	}
+	currency, rate := services.GetUserDisplayCurrency(userID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"models": models,
 		"total":  len(models),
+		"currency": gin.H{
+			"code":    currency,
+			"fx_rate": rate,
+		},
 		"filters": gin.H{
 			"providers":      providers,
 			"tags":           tags,
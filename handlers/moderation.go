@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Moderations 处理 OpenAI 兼容的内容审核请求：有上游审核模型（OpenAI/自定义上游）时转发，
+// 否则使用管理员配置的本地规则引擎。审核不扣费，仅记录用量
+// POST /v1/moderations
+func (h *Handler) Moderations(c *gin.Context) {
+	requestStartTime := time.Now()
+
+	var request models.ModerationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: input is required",
+			"invalid_request_error",
+			"invalid_json",
+		))
+		return
+	}
+	switch input := request.Input.(type) {
+	case string:
+	case []interface{}:
+		if len(input) == 0 {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"input must not be empty",
+				"invalid_request_error",
+				"invalid_input",
+			))
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"input must be a string, an array of strings, or an array of multimodal inputs",
+			"invalid_request_error",
+			"invalid_input",
+		))
+		return
+	}
+	if request.Model == "" {
+		request.Model = services.DefaultModerationModel
+	}
+
+	// Check token model access restriction
+	if apiKey := c.GetString("api_key"); apiKey != "" {
+		if err := middleware.GetKeyManager().CheckTokenModelAccess(apiKey, request.Model); err == middleware.ErrModelNotAllowed {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"Model not allowed - this token does not have access to model: "+request.Model,
+				"forbidden",
+				"model_not_allowed",
+			))
+			return
+		}
+	}
+
+	usageInfo, err := utils.ExtractUsageFromContext(c)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to extract usage context info")
+	}
+	c.Set("request_start_time", requestStartTime)
+	c.Set("request_model", request.Model)
+	if usageInfo != nil {
+		c.Set("usage_info", usageInfo)
+	}
+	c.Set("request_cost", 0.0)
+
+	client, providerName, ok := h.providerRouter.GetModerationProvider(request.Model, requestKeyTags(c))
+	if !ok {
+		texts := request.ModerationTexts()
+		if len(texts) == 0 {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"The local moderation rules only support text input",
+				"invalid_request_error",
+				"unsupported_input",
+			))
+			return
+		}
+		resp := services.ModerateLocally(texts)
+		c.Set("request_provider", "local")
+		c.Set("cursor_session", "local-moderation")
+		trackUsageFromContext(c, &models.Usage{}, http.StatusOK, "")
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.Set("request_provider", providerName)
+
+	priority := middleware.GetRequestPriority(c)
+	releaseSlot, err := middleware.AcquireProviderSlotWithProgress(c.Request.Context(), providerName, priority, nil)
+	if err != nil {
+		middleware.WriteProviderSlotError(c, err, middleware.EstimateProviderWait(providerName, priority),
+			models.NewErrorResponse(
+				"Provider is busy, please retry later",
+				"server_overloaded",
+				"provider_queue_timeout",
+			),
+			models.NewErrorResponse(
+				"Provider rate limit reached, please retry later",
+				"rate_limited",
+				"provider_rate_limited",
+			))
+		return
+	}
+	defer releaseSlot()
+
+	started := time.Now()
+	resp, err := client.Moderations(c.Request.Context(), &request)
+	h.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+	if err != nil {
+		providerErr := services.WrapError(err, providerName, request.Model, "")
+		services.LogProviderError(providerErr)
+		c.JSON(providerErr.HTTPStatus(), models.NewErrorResponse(
+			providerErr.GetUserFriendlyMessage(),
+			"provider_error",
+			string(providerErr.Code),
+		))
+		return
+	}
+
+	c.Set("cursor_session", providerName+"-direct")
+	trackUsageFromContext(c, &models.Usage{}, http.StatusOK, "")
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// AdminGetModerationRulesHandler 获取本地审核规则
+// GET /admin/moderation/rules
+func AdminGetModerationRulesHandler(c *gin.Context) {
+	rules, err := database.GetModerationRules()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get moderation rules")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"moderation_rules_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rules":      rules,
+		"categories": services.ModerationCategories,
+	})
+}
+
+// AdminUpdateModerationRulesHandler 替换本地审核规则，保存前校验类别与正则
+// PUT /admin/moderation/rules
+func AdminUpdateModerationRulesHandler(c *gin.Context) {
+	var req struct {
+		Rules []database.ModerationRule `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if req.Rules == nil {
+		req.Rules = []database.ModerationRule{}
+	}
+
+	if err := services.ValidateModerationRules(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"validation_error",
+			"invalid_moderation_rule",
+		))
+		return
+	}
+	if err := services.SaveModerationRules(req.Rules); err != nil {
+		logrus.WithError(err).Error("Failed to save moderation rules")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"moderation_rules_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": req.Rules})
+}
@@ -4,7 +4,6 @@ import (
 	"Curry2API-go/database"
 	"Curry2API-go/services"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -22,6 +21,12 @@ func NewOAuthHandler(oauthService *services.OAuthService) *OAuthHandler {
 	}
 }
 
+// ListOAuthProviders 列出已配置的第三方登录提供商，登录页据此显示按钮
+// GET /api/auth/providers
+func (h *OAuthHandler) ListOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oauthService.Providers()})
+}
+
 // InitiateOAuthLogin 发起OAuth登录
 // GET /api/auth/:provider/login
 func (h *OAuthHandler) InitiateOAuthLogin(c *gin.Context) {
@@ -36,7 +41,7 @@ func (h *OAuthHandler) InitiateOAuthLogin(c *gin.Context) {
 	}).Info("OAuth login attempt initiated")
 
 	// 验证provider
-	if provider != "google" && provider != "github" {
+	if !services.IsSupportedProvider(provider) {
 		logrus.WithFields(logrus.Fields{
 			"provider":  provider,
 			"client_ip": clientIP,
@@ -226,6 +231,21 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 		"email_verified":  userInfo.EmailVerified,
 	}).Info("OAuth user info retrieved successfully")
 
+	// 未验证的邮箱不能用来关联已有账号，否则任何能在 IdP 填写邮箱的人都可以登录该账号
+	if !userInfo.EmailVerified && userInfo.Email != "" {
+		if _, err := database.GetOAuthAccountByProvider(provider, userInfo.ProviderUserID); err != nil {
+			if _, err := database.GetUserByEmail(userInfo.Email); err == nil {
+				logrus.WithFields(logrus.Fields{
+					"provider":        provider,
+					"client_ip":       clientIP,
+					"provider_userid": userInfo.ProviderUserID,
+				}).Warn("OAuth login with unverified email matching an existing account")
+				c.Redirect(http.StatusFound, "/login?error=email_conflict&message=该邮箱已被其他账号使用")
+				return
+			}
+		}
+	}
+
 	// 创建或关联用户账号
 	oauthUserInfo := &database.OAuthUserInfo{
 		ProviderUserID: userInfo.ProviderUserID,
@@ -268,80 +288,46 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 		}).Warn("Failed to update OAuth account token")
 	}
 
-	// 清理用户的旧会话（保留最新的3个）
-	if err := database.DeleteUserOldSessions(user.ID, 2); err != nil {
+	// 已启用两步验证：带着一次性挑战回到登录页输入验证码，验证通过后才创建会话
+	twoFactorEnabled, err := database.IsTwoFactorEnabled(user.ID)
+	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"provider": provider,
 			"user_id":  user.ID,
 			"error":    err.Error(),
-		}).Warn("Failed to clean old sessions")
+		}).Error("Failed to check two-factor status")
+		c.Redirect(http.StatusFound, "/login?error=session_failed&message=会话创建失败")
+		return
+	}
+	if twoFactorEnabled {
+		challenge, _, ok := issueTwoFactorChallenge(user.ID)
+		if !ok {
+			c.Redirect(http.StatusFound, "/login?error=two_factor_unavailable&message=两步验证暂不可用")
+			return
+		}
+		c.Redirect(http.StatusFound, "/login?two_factor="+challenge)
+		return
 	}
 
-	// 创建会话
-	session, err := database.CreateSession(
-		user.ID,
-		user.Username,
-		user.Role,
-		c.ClientIP(),
-		c.GetHeader("User-Agent"),
-		sessionDuration,
-	)
-	if err != nil {
+	if _, err := establishLogin(c, user); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"provider":  provider,
 			"client_ip": clientIP,
 			"user_id":   user.ID,
 			"error":     err.Error(),
-		}).Error("Failed to create session")
+		}).Error("Failed to complete OAuth login")
 		c.Redirect(http.StatusFound, "/login?error=session_failed&message=会话创建失败")
 		return
 	}
 
-	// 更新最后登录时间
-	go func(id int64) {
-		if err := database.UpdateLastLogin(id); err != nil {
-			logrus.WithFields(logrus.Fields{
-				"user_id": id,
-				"error":   err.Error(),
-			}).Warn("Failed to update last login")
-		}
-	}(user.ID)
-
 	// 记录成功的OAuth登录
 	logrus.WithFields(logrus.Fields{
-		"provider":   provider,
-		"client_ip":  clientIP,
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"session_id": session.ID,
+		"provider":  provider,
+		"client_ip": clientIP,
+		"user_id":   user.ID,
+		"username":  user.Username,
 	}).Info("OAuth login successful")
 
-	// 设置 session cookie
-	isProduction := os.Getenv("DEBUG") != "true"
-	domain := os.Getenv("COOKIE_DOMAIN") // 例如: ".kesug.icu" 或留空
-	
-	// 使用 SameSite=Lax 而不是 Strict，避免跨站点问题
-	// Lax 允许顶级导航（如从外部链接点击进入）携带 cookie
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		"session_id",           // name
-		session.ID,             // value
-		int(sessionDuration.Seconds()), // maxAge
-		"/",                    // path
-		domain,                 // domain - 从环境变量读取
-		isProduction,           // secure
-		true,                   // httpOnly
-	)
-	
-	logrus.WithFields(logrus.Fields{
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"session_id": session.ID[:8] + "...",
-		"ip_address": c.ClientIP(),
-		"domain":     domain,
-		"secure":     isProduction,
-	}).Info("OAuth session cookie set")
-
 	// 重定向到控制台
 	c.Redirect(http.StatusFound, "/dashboard")
 }
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// opsSecretMask 返回给前端的加签密钥掩码，提交时原样带回表示保留原密钥
+const opsSecretMask = "********"
+
+// OpsSummaryConfigRequest 更新摘要推送配置请求
+type OpsSummaryConfigRequest struct {
+	Enabled  bool                         `json:"enabled"`
+	Hour     int                          `json:"hour"`
+	Webhooks []database.OpsSummaryWebhook `json:"webhooks"`
+}
+
+// maskOpsSummaryConfig 隐藏加签密钥
+func maskOpsSummaryConfig(cfg *database.OpsSummaryConfig) *database.OpsSummaryConfig {
+	masked := *cfg
+	masked.Webhooks = make([]database.OpsSummaryWebhook, len(cfg.Webhooks))
+	for i, wh := range cfg.Webhooks {
+		if wh.Secret != "" {
+			wh.Secret = opsSecretMask
+		}
+		masked.Webhooks[i] = wh
+	}
+	return &masked
+}
+
+// parseOpsSummaryDate 解析 ?date=YYYY-MM-DD（UTC），默认前一天
+func parseOpsSummaryDate(c *gin.Context) (time.Time, bool) {
+	if raw := c.Query("date"); raw != "" {
+		day, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return day, true
+	}
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1), true
+}
+
+// GetOpsSummaryConfigHandler 获取每日运营摘要推送配置
+// GET /admin/ops-summary/config
+func GetOpsSummaryConfigHandler(c *gin.Context) {
+	cfg, err := database.GetOpsSummaryConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get ops summary config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_ops_summary_config_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, maskOpsSummaryConfig(cfg))
+}
+
+// UpdateOpsSummaryConfigHandler 更新每日运营摘要推送配置
+// PUT /admin/ops-summary/config
+func UpdateOpsSummaryConfigHandler(c *gin.Context) {
+	var req OpsSummaryConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if req.Hour < 0 || req.Hour > 23 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"hour must be between 0 and 23",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	current, err := database.GetOpsSummaryConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get ops summary config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_ops_summary_config_failed",
+		))
+		return
+	}
+	secrets := make(map[string]string, len(current.Webhooks))
+	for _, wh := range current.Webhooks {
+		secrets[wh.ID] = wh.Secret
+	}
+
+	webhooks := make([]database.OpsSummaryWebhook, 0, len(req.Webhooks))
+	for _, wh := range req.Webhooks {
+		switch wh.Type {
+		case database.OpsWebhookSlack, database.OpsWebhookFeishu, database.OpsWebhookDingTalk:
+		default:
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"webhook type must be slack, feishu or dingtalk",
+				"validation_error",
+				"invalid_request",
+			))
+			return
+		}
+		if u, err := url.Parse(wh.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"webhook url must be an http(s) URL",
+				"validation_error",
+				"invalid_request",
+			))
+			return
+		}
+		if wh.ID == "" {
+			b := make([]byte, 6)
+			rand.Read(b)
+			wh.ID = "wh_" + hex.EncodeToString(b)
+		}
+		if wh.Secret == opsSecretMask {
+			wh.Secret = secrets[wh.ID]
+		}
+		wh.Name = strings.TrimSpace(wh.Name)
+		if wh.Name == "" {
+			wh.Name = wh.Type
+		}
+		webhooks = append(webhooks, wh)
+	}
+
+	cfg := &database.OpsSummaryConfig{
+		Enabled:  req.Enabled,
+		Hour:     req.Hour,
+		Webhooks: webhooks,
+		LastSent: current.LastSent,
+	}
+	if err := database.SaveOpsSummaryConfig(cfg); err != nil {
+		logrus.WithError(err).Error("Failed to save ops summary config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_ops_summary_config_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, maskOpsSummaryConfig(cfg))
+}
+
+// PreviewOpsSummaryHandler 预览指定日期（UTC，默认昨天）的运营摘要
+// GET /admin/ops-summary/preview?date=YYYY-MM-DD
+func PreviewOpsSummaryHandler(c *gin.Context) {
+	day, ok := parseOpsSummaryDate(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"date must be YYYY-MM-DD",
+			"validation_error",
+			"invalid_date",
+		))
+		return
+	}
+
+	summary, err := database.GetOpsSummary(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to build ops summary")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"ops_summary_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"summary": summary,
+		"text":    strings.Join(services.RenderOpsSummary(summary), "\n"),
+	})
+}
+
+// SendOpsSummaryHandler 立即推送指定日期（UTC，默认昨天）的运营摘要
+// POST /admin/ops-summary/send?date=YYYY-MM-DD
+func SendOpsSummaryHandler(c *gin.Context) {
+	day, ok := parseOpsSummaryDate(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"date must be YYYY-MM-DD",
+			"validation_error",
+			"invalid_date",
+		))
+		return
+	}
+
+	results, err := services.GetOpsSummaryReporter().Send(day)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to send ops summary")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"ops_summary_send_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
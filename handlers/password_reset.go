@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// passwordResetCooldown 同一邮箱两次申请找回密码验证码的最短间隔
+	passwordResetCooldown = 60 * time.Second
+	// passwordResetIPLimit 同一 IP 每小时最多申请的找回密码验证码数量
+	passwordResetIPLimit = 5
+)
+
+// ForgotPasswordRequest 申请找回密码验证码
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 使用邮箱验证码设置新密码
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	Code        string `json:"code" binding:"required,len=6"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// ForgotPasswordHandler 向已注册邮箱发送找回密码验证码
+// 无论邮箱是否注册都返回相同的响应，避免被用来探测邮箱
+// POST /auth/forgot-password
+func ForgotPasswordHandler(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请输入有效的邮箱地址")
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+	response := gin.H{
+		"message":    "如果该邮箱已注册，您将收到一封包含验证码的邮件",
+		"expires_in": int(database.VerificationExpiry.Seconds()),
+	}
+
+	// 检查 IP 申请频率（每小时最多 passwordResetIPLimit 次）
+	count, err := database.CountRecentCodesByIP(c.ClientIP(), database.CodeTypePasswordReset, time.Now().Add(-database.PasswordResetIPWindow))
+	if err != nil {
+		logrus.Errorf("Failed to count password reset codes: %v", err)
+		writeServerError(c)
+		return
+	}
+	if count >= passwordResetIPLimit {
+		c.Header("Retry-After", strconv.Itoa(int(database.PasswordResetIPWindow.Seconds())))
+		writeError(c, http.StatusTooManyRequests, "too_frequent", "申请过于频繁，请稍后重试")
+		return
+	}
+
+	user, err := database.GetUserByEmail(email)
+	if err != nil {
+		if err != database.ErrUserNotFound {
+			logrus.Errorf("Failed to query user for password reset: %v", err)
+			writeServerError(c)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	// 第三方登录创建的占位邮箱收不到验证码，按未注册处理
+	if !user.IsActive || database.IsOAuthPlaceholderEmail(user.Email) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// 检查发送频率限制（60秒内只能发送一次）
+	lastSentTime, err := database.GetRecentCodeSentTime(user.Email, database.CodeTypePasswordReset)
+	if err != nil {
+		logrus.Errorf("Failed to check last sent time: %v", err)
+		writeServerError(c)
+		return
+	}
+	if !lastSentTime.IsZero() && time.Since(lastSentTime) < passwordResetCooldown {
+		remainingSeconds := int((passwordResetCooldown - time.Since(lastSentTime)).Seconds())
+		writeError(c, http.StatusTooManyRequests, "too_frequent",
+			fmt.Sprintf("发送过于频繁，请在 %d 秒后重试", remainingSeconds))
+		return
+	}
+
+	if err := database.InvalidateOldCodes(user.Email, database.CodeTypePasswordReset); err != nil {
+		logrus.Warnf("Failed to invalidate old codes: %v", err)
+	}
+	verificationCode, err := database.CreateVerificationCode(user.Email, database.CodeTypePasswordReset, c.ClientIP())
+	if err != nil {
+		logrus.Errorf("Failed to create verification code: %v", err)
+		writeServerError(c)
+		return
+	}
+
+	if err := emailService.SendPasswordResetCode(user.Email, verificationCode.Code); err != nil {
+		logrus.Errorf("Failed to send password reset email: %v", err)
+		writeError(c, http.StatusInternalServerError, "email_send_failed", "验证码发送失败，请稍后重试")
+		return
+	}
+
+	logrus.Infof("Password reset code sent to user %d", user.ID)
+	if os.Getenv("DEBUG") == "true" {
+		logrus.Warnf("🔑 DEBUG: Password reset code for %s is: %s (expires in 10 minutes)", user.Email, verificationCode.Code)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPasswordHandler 校验找回密码验证码后设置新密码，并注销该用户的全部登录会话
+// POST /auth/reset-password
+func ResetPasswordHandler(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请填写邮箱、6 位验证码与新密码（至少 6 个字符）")
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+
+	// 验证码输错 database.MaxCodeAttempts 次后作废，需重新申请
+	if err := database.VerifyCode(email, req.Code, database.CodeTypePasswordReset); err != nil {
+		switch err {
+		case database.ErrCodeNotFound:
+			writeError(c, http.StatusBadRequest, "code_not_found", "验证码不存在或已过期")
+		case database.ErrCodeExpired:
+			writeError(c, http.StatusBadRequest, "code_expired", "验证码已过期")
+		case database.ErrCodeInvalid:
+			writeError(c, http.StatusBadRequest, "code_invalid", "验证码错误")
+		default:
+			logrus.Errorf("Failed to verify code: %v", err)
+			writeServerError(c)
+		}
+		return
+	}
+
+	user, err := database.GetUserByEmail(email)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			writeError(c, http.StatusBadRequest, "code_not_found", "验证码不存在或已过期")
+			return
+		}
+		logrus.Errorf("Failed to query user for password reset: %v", err)
+		writeServerError(c)
+		return
+	}
+
+	if err := database.UpdateUserPassword(user.ID, req.NewPassword); err != nil {
+		logrus.Errorf("Failed to reset password for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
+
+	// 密码可能已泄露，注销所有已登录的设备
+	if err := database.DeleteUserSessions(user.ID); err != nil {
+		logrus.Errorf("Failed to revoke sessions after password reset for user %d: %v", user.ID, err)
+	}
+	middleware.ForgetUserSessions(user.ID, "")
+	if _, err := database.RevokeUserTokens(user.ID); err != nil {
+		logrus.Errorf("Failed to revoke tokens after password reset for user %d: %v", user.ID, err)
+	}
+	middleware.ForgetUserTokens(user.ID)
+	if err := database.CreateAuditLog(&user.ID, database.AuditActionPasswordReset, "user", strconv.FormatInt(user.ID, 10), gin.H{"ip": c.ClientIP()}); err != nil {
+		logrus.WithError(err).Error("Failed to audit password reset")
+	}
+	logrus.Infof("User %s reset password via email code", user.Username)
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请使用新密码登录"})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateModelPricingRequest 更新模型定价请求
+type UpdateModelPricingRequest struct {
+	Provider    string  `json:"provider" binding:"required"`
+	InputPrice  float64 `json:"input_price" binding:"gte=0"`
+	OutputPrice float64 `json:"output_price" binding:"gte=0"`
+}
+
+// GetPublicPricingHandler returns the model pricing table and a marketplace summary
+// GET /api/public/pricing (no authentication)
+// Prices come from the same table used for billing, so they never drift
+func GetPublicPricingHandler(c *gin.Context) {
+	allPricing := services.GetAllPricing()
+
+	pricing := make([]services.ModelPricing, 0, len(allPricing))
+	for _, p := range allPricing {
+		pricing = append(pricing, p)
+	}
+	sort.Slice(pricing, func(i, j int) bool {
+		if pricing[i].Provider != pricing[j].Provider {
+			return pricing[i].Provider < pricing[j].Provider
+		}
+		return pricing[i].Model < pricing[j].Model
+	})
+
+	marketplace := GetModelMarketplace()
+	byProvider := make(map[string]int)
+	for _, m := range marketplace {
+		byProvider[m.Provider]++
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"currency":   services.BaseCurrency,
+		"unit":       "per_1m_tokens",
+		"updated_at": services.GetPricingUpdatedAt(),
+		"pricing":    pricing,
+		"marketplace": gin.H{
+			"total_models": len(marketplace),
+			"by_provider":  byProvider,
+		},
+	})
+}
+
+// AdminUpdateModelPricingHandler creates or updates the price of a model
+// PUT /admin/pricing/:model
+func AdminUpdateModelPricingHandler(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	model := strings.TrimSpace(c.Param("model"))
+	if model == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Model is required",
+			"validation_error",
+			"missing_model",
+		))
+		return
+	}
+
+	var req UpdateModelPricingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	pricing := services.ModelPricing{
+		Model:       model,
+		Provider:    req.Provider,
+		InputPrice:  req.InputPrice,
+		OutputPrice: req.OutputPrice,
+	}
+	if err := services.UpdateModelPricing(pricing); err != nil {
+		logrus.WithError(err).WithField("model", model).Error("Failed to update model pricing")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to update model pricing",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model":        model,
+		"input_price":  req.InputPrice,
+		"output_price": req.OutputPrice,
+	}).Info("Model pricing updated by admin")
+
+	c.JSON(http.StatusOK, pricing)
+}
+
+// SimulatePricingRequest 定价模拟请求：prices 为拟调整的模型价格，days 为回溯天数（默认 30）
+type SimulatePricingRequest struct {
+	Days   int                   `json:"days"`
+	Prices []SimulatedModelPrice `json:"prices" binding:"required"`
+}
+
+// SimulatedModelPrice 拟调整的单个模型价格（每 1M tokens）
+type SimulatedModelPrice struct {
+	Model       string  `json:"model" binding:"required"`
+	InputPrice  float64 `json:"input_price" binding:"gte=0"`
+	OutputPrice float64 `json:"output_price" binding:"gte=0"`
+}
+
+// AdminSimulatePricingHandler recomputes recent spend per user and model under proposed
+// prices and returns the deltas; the live pricing table and balances are not touched
+// POST /admin/pricing/simulate
+func AdminSimulatePricingHandler(c *gin.Context) {
+	var req SimulatePricingRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Prices) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format: prices must list at least one model",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if req.Days == 0 {
+		req.Days = 30
+	}
+	if req.Days < 1 || req.Days > 365 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Days must be between 1 and 365",
+			"validation_error",
+			"invalid_days",
+		))
+		return
+	}
+
+	proposed := make([]services.ModelPricing, 0, len(req.Prices))
+	for _, p := range req.Prices {
+		model := strings.TrimSpace(p.Model)
+		if model == "" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Model is required",
+				"validation_error",
+				"missing_model",
+			))
+			return
+		}
+		pricing := services.ModelPricing{
+			Model:       model,
+			Provider:    services.GetProviderFromModel(model),
+			InputPrice:  p.InputPrice,
+			OutputPrice: p.OutputPrice,
+		}
+		if current := services.GetModelPricing(model); current != nil {
+			pricing.Provider = current.Provider
+		}
+		proposed = append(proposed, pricing)
+	}
+
+	// 聚合模式下不返回按用户的明细，除非管理员按审计流程绕过
+	filter := database.UsageFilter{}
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+
+	simulation, err := services.SimulatePricing(proposed, req.Days, filter.PerUserAllowed())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to simulate model pricing")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to simulate model pricing",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, simulation)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ProviderSpendCapRequest 设置提供商月度消费上限请求
+type ProviderSpendCapRequest struct {
+	MonthlyLimit  float64  `json:"monthly_limit" binding:"required"`
+	AlertPercent  int      `json:"alert_percent"`
+	AllowlistTags []string `json:"allowlist_tags"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+// reevaluateProviderSpend 上限变更后立即重新评估，调高或删除上限时路由马上恢复
+func reevaluateProviderSpend() {
+	if monitor := services.GetProviderSpendMonitor(); monitor != nil {
+		go monitor.Evaluate()
+	}
+}
+
+// ListProviderSpendCapsHandler 列出提供商消费上限及当月估算消费
+// GET /admin/provider-spend-caps
+func ListProviderSpendCapsHandler(c *gin.Context) {
+	caps, err := database.ListProviderSpendCaps()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list provider spend caps")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_spend_caps_failed",
+		))
+		return
+	}
+
+	status := map[string]*services.ProviderSpendStatus{}
+	if monitor := services.GetProviderSpendMonitor(); monitor != nil {
+		status = monitor.Status()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"caps":   caps,
+		"status": status,
+	})
+}
+
+// SaveProviderSpendCapHandler 创建或更新提供商消费上限
+// PUT /admin/provider-spend-caps/:provider
+func SaveProviderSpendCapHandler(c *gin.Context) {
+	provider := c.Param("provider")
+	if msg := services.ValidateProviderSpendCapName(provider); msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_provider"))
+		return
+	}
+
+	var req ProviderSpendCapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"monthly_limit 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if req.AlertPercent == 0 {
+		req.AlertPercent = 80
+	}
+	switch {
+	case req.MonthlyLimit <= 0:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("monthly_limit must be positive", "validation_error", "invalid_request"))
+		return
+	case req.AlertPercent < 1 || req.AlertPercent > 100:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("alert_percent must be between 1 and 100", "validation_error", "invalid_request"))
+		return
+	}
+
+	tags := []string{}
+	for _, tag := range req.AllowlistTags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	spendCap := &database.ProviderSpendCap{
+		Provider:      provider,
+		MonthlyLimit:  req.MonthlyLimit,
+		AlertPercent:  req.AlertPercent,
+		AllowlistTags: tags,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+	}
+	if err := database.SaveProviderSpendCap(spendCap); err != nil {
+		logrus.WithError(err).WithField("provider", provider).Error("Failed to save provider spend cap")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"save_spend_cap_failed",
+		))
+		return
+	}
+	reevaluateProviderSpend()
+
+	saved, err := database.GetProviderSpendCap(provider)
+	if err != nil {
+		saved = spendCap
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// DeleteProviderSpendCapHandler 删除提供商消费上限，该提供商恢复正常路由
+// DELETE /admin/provider-spend-caps/:provider
+func DeleteProviderSpendCapHandler(c *gin.Context) {
+	provider := c.Param("provider")
+	if err := database.DeleteProviderSpendCap(provider); err != nil {
+		if err == database.ErrProviderSpendCapNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("消费上限不存在", "not_found", "spend_cap_not_found"))
+			return
+		}
+		logrus.WithError(err).WithField("provider", provider).Error("Failed to delete provider spend cap")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"delete_spend_cap_failed",
+		))
+		return
+	}
+	reevaluateProviderSpend()
+	c.JSON(http.StatusOK, gin.H{"message": "消费上限已删除"})
+}
+
+// ListProviderSpendAlertsHandler 获取提供商消费告警记录
+// GET /admin/provider-spend-caps/alerts?provider=openai&limit=50
+func ListProviderSpendAlertsHandler(c *gin.Context) {
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	alerts, err := database.ListProviderSpendAlerts(c.Query("provider"), limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list provider spend alerts")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_alerts_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
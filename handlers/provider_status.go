@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateDisabledModelsRequest 更新停用模型列表请求
+type UpdateDisabledModelsRequest struct {
+	Models []string `json:"models"`
+}
+
+// OpenIncidentRequest 创建提供商故障记录请求
+type OpenIncidentRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	Model    string `json:"model"`
+	Message  string `json:"message"`
+}
+
+// AdminGetProviderStatusHandler 获取停用模型列表与当前故障记录
+// GET /admin/provider-status
+func AdminGetProviderStatusHandler(c *gin.Context) {
+	status, err := database.GetProviderStatus()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load provider status")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_provider_status_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// AdminUpdateDisabledModelsHandler 替换停用模型列表（模型列表中显示为 admin_disabled）
+// PUT /admin/provider-status/disabled-models
+func AdminUpdateDisabledModelsHandler(c *gin.Context) {
+	var req UpdateDisabledModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	disabled, err := services.SetDisabledModels(req.Models)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to update disabled models")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_disabled_models_failed",
+		))
+		return
+	}
+
+	logrus.WithField("models", disabled).Info("Disabled models updated by admin")
+	c.JSON(http.StatusOK, gin.H{"disabled_models": disabled})
+}
+
+// AdminOpenIncidentHandler 登记提供商故障（模型列表中显示为 provider_down 并附带 incident_id）
+// POST /admin/provider-status/incidents
+func AdminOpenIncidentHandler(c *gin.Context) {
+	var req OpenIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Provider) == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"provider 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	incident, err := services.OpenIncident(strings.TrimSpace(req.Provider), strings.TrimSpace(req.Model), req.Message)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to open provider incident")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"open_incident_failed",
+		))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"incident_id": incident.ID,
+		"provider":    incident.Provider,
+		"model":       incident.Model,
+	}).Warn("Provider incident opened by admin")
+	c.JSON(http.StatusCreated, incident)
+}
+
+// AdminResolveIncidentHandler 解除提供商故障
+// DELETE /admin/provider-status/incidents/:id
+func AdminResolveIncidentHandler(c *gin.Context) {
+	id := c.Param("id")
+	if err := services.ResolveIncident(id); err != nil {
+		if err == services.ErrIncidentNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("故障记录不存在", "not_found", "incident_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to resolve provider incident")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"resolve_incident_failed",
+		))
+		return
+	}
+
+	logrus.WithField("incident_id", id).Info("Provider incident resolved by admin")
+	c.JSON(http.StatusOK, gin.H{"message": "故障已解除"})
+}
+
+// AdminGetProviderHealth 获取各提供商的健康探测与熔断状态
+// GET /admin/provider-status/health
+func (h *Handler) AdminGetProviderHealth(c *gin.Context) {
+	if h.providerRouter == nil {
+		c.JSON(http.StatusOK, gin.H{"providers": []services.ProviderHealthStatus{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": h.providerRouter.GetProviderHealth()})
+}
+
+// AdminGetProviderBalancing 获取负载均衡策略、提供商权重及各模型的流量分布；
+// ttft_sla 策略下另含各提供商的滚动首字延迟、错误率与各模型当前路由的决策原因
+// GET /admin/provider-balancing
+func (h *Handler) AdminGetProviderBalancing(c *gin.Context) {
+	if h.providerRouter == nil {
+		c.JSON(http.StatusOK, services.ProviderBalancingStatus{
+			Strategy:  services.BalancePriority,
+			Weights:   map[string]int{},
+			Providers: []services.ProviderTrafficStats{},
+			Models:    []services.ModelTrafficStats{},
+			Routes:    []services.SLARouteDecision{},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, h.providerRouter.GetLoadBalancingStatus())
+}
+
+// AdminUpdateProviderBalancing 更新负载均衡策略与提供商权重，立即生效
+// PUT /admin/provider-balancing
+func (h *Handler) AdminUpdateProviderBalancing(c *gin.Context) {
+	var cfg database.ProviderBalancingConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = services.BalancePriority
+	}
+	if err := services.ValidateBalancingConfig(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.SaveProviderBalancingConfig(&cfg); err != nil {
+		logrus.WithError(err).Error("Failed to save provider load balancing config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_provider_balancing_failed",
+		))
+		return
+	}
+	if h.providerRouter != nil {
+		h.providerRouter.SetLoadBalancing(&cfg)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"strategy":       cfg.Strategy,
+		"weights":        cfg.Weights,
+		"ttft_target_ms": cfg.TTFTTargetMs,
+		"max_error_rate": cfg.MaxErrorRate,
+	}).Info("Provider load balancing updated by admin")
+	c.JSON(http.StatusOK, cfg)
+}
+
+// providerMetricsWindows 提供商指标可选的统计窗口
+var providerMetricsWindows = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// AdminGetProviderMetrics 获取各提供商在统计窗口内的请求数、错误率、p50/p95 延迟与 token 用量
+// GET /admin/providers/metrics?window=1h（可选 5m、15m、1h、6h、24h、7d、30d）
+func (h *Handler) AdminGetProviderMetrics(c *gin.Context) {
+	window := c.DefaultQuery("window", "1h")
+	duration, ok := providerMetricsWindows[window]
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"window must be one of 5m, 15m, 1h, 6h, 24h, 7d, 30d",
+			"validation_error",
+			"invalid_window",
+		))
+		return
+	}
+
+	since := time.Now().Add(-duration)
+	metrics, err := h.providerRouter.GetProviderMetrics(since)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get provider metrics")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_provider_metrics_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window":    window,
+		"since":     since,
+		"providers": metrics,
+	})
+}
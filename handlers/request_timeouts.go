@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminGetRequestTimeouts 获取当前生效的请求超时配置（秒）
+// GET /admin/request-timeouts
+func (h *Handler) AdminGetRequestTimeouts(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.GetRequestTimeoutConfig())
+}
+
+// AdminUpdateRequestTimeouts 更新全局默认超时、按模型超时及单请求覆盖的上下限，立即生效
+// PUT /admin/request-timeouts
+func (h *Handler) AdminUpdateRequestTimeouts(c *gin.Context) {
+	var cfg database.RequestTimeoutConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+	if cfg.Models == nil {
+		cfg.Models = map[string]int{}
+	}
+	if err := middleware.ValidateRequestTimeoutConfig(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.SaveRequestTimeoutConfig(&cfg); err != nil {
+		logrus.WithError(err).Error("Failed to save request timeout config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_request_timeouts_failed",
+		))
+		return
+	}
+	middleware.ConfigureRequestTimeouts(&cfg)
+
+	logrus.WithFields(logrus.Fields{
+		"default": cfg.Default,
+		"min":     cfg.Min,
+		"max":     cfg.Max,
+		"models":  cfg.Models,
+	}).Info("Request timeouts updated by admin")
+	c.JSON(http.StatusOK, middleware.GetRequestTimeoutConfig())
+}
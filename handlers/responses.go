@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"Curry2API-go/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Responses 处理 OpenAI Responses API 请求（Codex CLI 及新版 SDK 使用）
+// 输入项转换为 Chat Completions 消息后复用相同的模型校验、路由与用量统计，
+// 输出按 Responses API 的 response 对象 / SSE 事件格式返回；function 工具通过工具提示实现
+// POST /v1/responses
+func (h *Handler) Responses(c *gin.Context) {
+	var request models.ResponsesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logrus.WithError(err).Error("Failed to bind Responses request")
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format",
+			"invalid_request_error",
+			"invalid_json",
+		))
+		return
+	}
+	if request.PreviousResponseID != "" {
+		// 响应不做存储，无法续接之前的对话；客户端需在 input 中携带完整历史
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"previous_response_id is not supported: responses are not stored, send the full conversation in input",
+			"invalid_request_error",
+			"unsupported_parameter",
+		))
+		return
+	}
+
+	chatRequest, err := request.ToChatCompletionRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"invalid_request_error",
+			"invalid_input",
+		))
+		return
+	}
+	if len(chatRequest.Messages) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Input cannot be empty",
+			"invalid_request_error",
+			"missing_input",
+		))
+		return
+	}
+
+	// 与 Claude Messages 一致：将工具定义注入系统提示，输出中的 <tool_call> 块转换为 function_call 输出项
+	if toolPrompt := services.NewToolExecutor().BuildFunctionToolPrompt(chatRequest.Tools, chatRequest.ToolChoice); toolPrompt != "" {
+		if chatRequest.Messages[0].Role == "system" {
+			chatRequest.Messages[0].Content = chatRequest.Messages[0].GetStringContent() + toolPrompt
+		} else {
+			chatRequest.Messages = append([]models.Message{{Role: "system", Content: toolPrompt}}, chatRequest.Messages...)
+		}
+		c.Set("has_tool_use", true)
+	}
+
+	chatGenerator, release := h.startChatCompletion(c, chatRequest)
+	if chatGenerator == nil {
+		return
+	}
+	defer release()
+
+	response := utils.NewResponseForRequest(&request)
+	if request.Stream {
+		utils.SafeStreamWrapper(func(c *gin.Context, generator <-chan interface{}) {
+			utils.StreamResponse(c, generator, response)
+		}, c, chatGenerator)
+	} else {
+		utils.NonStreamResponse(c, chatGenerator, response)
+	}
+}
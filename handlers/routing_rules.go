@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RoutingRuleRequest 创建/更新路由规则请求
+type RoutingRuleRequest struct {
+	Name       string                         `json:"name" binding:"required"`
+	Position   int                            `json:"position"`
+	Enabled    *bool                          `json:"enabled"`
+	Conditions database.RoutingRuleConditions `json:"conditions"`
+	Actions    database.RoutingRuleActions    `json:"actions"`
+	Stop       bool                           `json:"stop"`
+}
+
+// toRule 转换为路由规则并校验，返回错误信息
+func (r *RoutingRuleRequest) toRule() (*database.RoutingRule, string) {
+	rule := &database.RoutingRule{
+		Name:       strings.TrimSpace(r.Name),
+		Position:   r.Position,
+		Enabled:    r.Enabled == nil || *r.Enabled,
+		Conditions: r.Conditions,
+		Actions:    r.Actions,
+		Stop:       r.Stop,
+	}
+	rule.Actions.RouteProvider = strings.ToLower(strings.TrimSpace(rule.Actions.RouteProvider))
+	rule.Actions.SetPriority = strings.ToLower(strings.TrimSpace(rule.Actions.SetPriority))
+	if err := middleware.ValidateRoutingRule(rule); err != nil {
+		return nil, err.Error()
+	}
+	return rule, ""
+}
+
+// reloadRoutingRules 重新加载生效的路由规则，失败只记录日志（下次修改时重试）
+func reloadRoutingRules() {
+	if err := middleware.ReloadRoutingRules(); err != nil {
+		logrus.WithError(err).Error("Failed to reload routing rules")
+	}
+}
+
+// AdminListRoutingRules 按匹配顺序列出路由规则
+// GET /admin/routing-rules
+func AdminListRoutingRules(c *gin.Context) {
+	rules, err := database.ListRoutingRules()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list routing rules")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"list_routing_rules_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// AdminCreateRoutingRule 创建路由规则，立即生效
+// POST /admin/routing-rules
+func AdminCreateRoutingRule(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	var req RoutingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("请求格式错误", "validation_error", "invalid_request"))
+		return
+	}
+	rule, msg := req.toRule()
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.CreateRoutingRule(rule); err != nil {
+		logrus.WithError(err).Error("Failed to create routing rule")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"create_routing_rule_failed",
+		))
+		return
+	}
+
+	reloadRoutingRules()
+	c.JSON(http.StatusCreated, rule)
+}
+
+// AdminUpdateRoutingRule 更新路由规则，立即生效
+// PUT /admin/routing-rules/:id
+func AdminUpdateRoutingRule(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	var req RoutingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("请求格式错误", "validation_error", "invalid_request"))
+		return
+	}
+	rule, msg := req.toRule()
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	existing, err := database.GetRoutingRule(id)
+	if err == database.ErrRoutingRuleNotFound {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("规则不存在", "not_found", "routing_rule_not_found"))
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get routing rule")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_routing_rule_failed",
+		))
+		return
+	}
+
+	rule.ID = id
+	rule.CreatedAt = existing.CreatedAt
+	if err := database.UpdateRoutingRule(rule); err != nil {
+		logrus.WithError(err).Error("Failed to update routing rule")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_routing_rule_failed",
+		))
+		return
+	}
+
+	reloadRoutingRules()
+	c.JSON(http.StatusOK, rule)
+}
+
+// AdminDeleteRoutingRule 删除路由规则，立即生效
+// DELETE /admin/routing-rules/:id
+func AdminDeleteRoutingRule(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的ID", "validation_error", "invalid_id"))
+		return
+	}
+
+	if err := database.DeleteRoutingRule(id); err != nil {
+		if err == database.ErrRoutingRuleNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("规则不存在", "not_found", "routing_rule_not_found"))
+			return
+		}
+		logrus.WithError(err).Error("Failed to delete routing rule")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"delete_routing_rule_failed",
+		))
+		return
+	}
+
+	reloadRoutingRules()
+	c.JSON(http.StatusOK, gin.H{"message": "规则已删除"})
+}
+
+// EvaluateRoutingRulesRequest 路由规则试运行请求；key 不为空时使用该密钥的用户与标签，
+// rules 不为空时按给定的（未保存的）规则试运行，否则使用当前生效的规则
+type EvaluateRoutingRulesRequest struct {
+	UserID       *int64               `json:"user_id"`
+	Key          string               `json:"key"`
+	KeyTags      []string             `json:"key_tags"`
+	Model        string               `json:"model"`
+	RequestBytes int                  `json:"request_bytes"`
+	Time         *time.Time           `json:"time"`
+	Rules        []RoutingRuleRequest `json:"rules"`
+}
+
+// AdminEvaluateRoutingRules 试运行路由规则，返回命中的规则与最终决策，不会修改任何状态
+// POST /admin/routing-rules/evaluate
+func AdminEvaluateRoutingRules(c *gin.Context) {
+	var req EvaluateRoutingRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("请求格式错误", "validation_error", "invalid_request"))
+		return
+	}
+
+	routingReq := middleware.RoutingRequest{
+		UserID:       req.UserID,
+		KeyTags:      req.KeyTags,
+		Model:        req.Model,
+		RequestBytes: req.RequestBytes,
+		Time:         time.Now(),
+	}
+	if req.Time != nil {
+		routingReq.Time = *req.Time
+	}
+	if req.Key != "" {
+		km := middleware.GetKeyManager()
+		if !km.IsValidKey(req.Key) {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("密钥不存在", "validation_error", "key_not_found"))
+			return
+		}
+		if routingReq.UserID == nil {
+			routingReq.UserID = km.GetUserIDForKey(req.Key)
+		}
+		if routingReq.KeyTags == nil {
+			routingReq.KeyTags = km.GetKeyTags(req.Key)
+		}
+	}
+
+	rules := middleware.CurrentRoutingRules()
+	if req.Rules != nil {
+		rules = make([]*database.RoutingRule, 0, len(req.Rules))
+		for i := range req.Rules {
+			rule, msg := req.Rules[i].toRule()
+			if msg != "" {
+				c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+					"rules["+strconv.Itoa(i)+"]: "+msg,
+					"validation_error",
+					"invalid_request",
+				))
+				return
+			}
+			rule.ID = int64(i + 1)
+			rules = append(rules, rule)
+		}
+		sort.SliceStable(rules, func(i, j int) bool { return rules[i].Position < rules[j].Position })
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"request":  routingReq,
+		"decision": middleware.EvaluateRoutingRules(rules, routingReq),
+	})
+}
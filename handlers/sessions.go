@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// isNewDeviceLogin 在建立登录前判断本次登录是否来自新设备：用户此前登录过，且没有相同设备指纹的有效会话
+// （JWT 模式下为刷新令牌有效期内没有从相同设备登录过）。首次登录不提醒
+func isNewDeviceLogin(user *database.User, device utils.DeviceInfo) bool {
+	if user.LastLogin == nil || user.Email == "" || database.IsOAuthPlaceholderEmail(user.Email) {
+		return false
+	}
+	var known bool
+	var err error
+	if middleware.JWTEnabled() {
+		known, err = database.IsKnownLoginDevice(user.ID, device.Fingerprint(), time.Now().Add(-middleware.RefreshTokenTTL()))
+	} else {
+		known, err = database.IsKnownDevice(user.ID, device.Fingerprint())
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to check login device")
+		return false
+	}
+	return !known
+}
+
+// notifyNewDeviceLogin 异步发送新设备登录提醒邮件
+func notifyNewDeviceLogin(user *database.User, device utils.DeviceInfo, ipAddress string, at time.Time) {
+	if emailService == nil {
+		return
+	}
+	go func() {
+		if err := emailService.SendNewLoginNotice(user.Email, user.Username, device,
+			utils.MaskIP(ipAddress), at); err != nil {
+			logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to send new login notice")
+		}
+	}()
+}
+
+// ListSessionsHandler 列出当前用户的登录会话及设备信息
+// GET /profile/sessions
+func ListSessionsHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	sessions, err := database.ListUserSessions(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to list sessions")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"获取登录设备失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	currentPublicID := ""
+	if currentID, _ := c.Cookie("session_id"); currentID != "" {
+		currentPublicID = database.SessionPublicID(currentID)
+	}
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		publicID := database.SessionPublicID(session.ID)
+		result = append(result, gin.H{
+			"id":          publicID,
+			"browser":     session.Browser,
+			"os":          session.OS,
+			"device_type": session.DeviceType,
+			"location":    session.Location,
+			"ip_address":  utils.MaskIP(session.IPAddress),
+			"user_agent":  session.UserAgent,
+			"created_at":  session.CreatedAt,
+			"expires_at":  session.ExpiresAt,
+			"current":     publicID == currentPublicID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeSessionHandler 退出当前用户在其他设备上的会话
+// DELETE /profile/sessions/:id
+func RevokeSessionHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	publicID := c.Param("id")
+	if currentID, _ := c.Cookie("session_id"); currentID != "" && publicID == database.SessionPublicID(currentID) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"不能退出当前会话，请使用登出",
+			"validation_error",
+			"current_session",
+		))
+		return
+	}
+
+	sessionID, err := database.FindUserSessionByPublicID(userID, publicID)
+	if err == nil {
+		err = database.DeleteUserSession(userID, sessionID)
+	}
+	if err != nil {
+		if err == database.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse(
+				"会话不存在",
+				"not_found",
+				"session_not_found",
+			))
+			return
+		}
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to revoke session")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"退出会话失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	middleware.ForgetSession(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出该设备"})
+}
+
+// RevokeAllSessionsHandler 退出当前用户在所有其他设备上的会话；include_current=true 时同时退出当前会话
+// DELETE /profile/sessions
+func RevokeAllSessionsHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	keepID, _ := c.Cookie("session_id")
+	includeCurrent := c.Query("include_current") == "true"
+	if includeCurrent {
+		keepID = ""
+	}
+
+	revoked, err := database.DeleteOtherUserSessions(userID, keepID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to revoke all sessions")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"退出会话失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	middleware.ForgetUserSessions(userID, keepID)
+
+	resp := gin.H{
+		"message": "已退出所有其他设备",
+		"revoked": revoked,
+	}
+
+	// JWT 模式：吊销此前签发的全部令牌，保留当前设备时为其签发新令牌
+	if middleware.JWTEnabled() {
+		if _, err := database.RevokeUserTokens(userID); err != nil {
+			logrus.WithError(err).WithField("user_id", userID).Error("Failed to revoke tokens")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"退出会话失败",
+				"internal_error",
+				"database_error",
+			))
+			return
+		}
+		middleware.ForgetUserTokens(userID)
+		if includeCurrent {
+			middleware.ClearTokenCookies(c)
+		} else if c.GetString("token_family") != "" {
+			pair, err := middleware.IssueTokenPair(userID, c.GetString("username"), c.GetString("role"))
+			if err != nil {
+				logrus.WithError(err).WithField("user_id", userID).Error("Failed to reissue tokens")
+				middleware.ClearTokenCookies(c)
+			} else {
+				middleware.SetTokenCookies(c, pair)
+				resp["access_token"] = pair.AccessToken
+				resp["refresh_token"] = pair.RefreshToken
+			}
+		}
+	}
+
+	if includeCurrent {
+		c.SetCookie("session_id", "", -1, "/", os.Getenv("COOKIE_DOMAIN"), false, true)
+	}
+	logrus.WithField("user_id", userID).Infof("Revoked %d sessions (include current: %v)", revoked, includeCurrent)
+
+	c.JSON(http.StatusOK, resp)
+}
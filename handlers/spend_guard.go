@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateSpendGuardRequest 更新消费上限请求，null 表示取消该项上限
+type UpdateSpendGuardRequest struct {
+	DailyLimit  *float64 `json:"daily_limit"`
+	WeeklyLimit *float64 `json:"weekly_limit"`
+}
+
+// spendGuardResponse 返回上限配置及本日/本周已用金额
+func spendGuardResponse(c *gin.Context, guard *database.SpendGuard) {
+	dayStart, weekStart := database.SpendGuardPeriods(time.Now())
+
+	dailySpent, err := database.GetUserAPISpend(guard.UserID, dayStart)
+	var weeklySpent float64
+	if err == nil {
+		weeklySpent, err = database.GetUserAPISpend(guard.UserID, weekStart)
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", guard.UserID).Error("Failed to get user spend")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"获取消费上限失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"guard":           guard,
+		"daily_spent":     dailySpent,
+		"weekly_spent":    weeklySpent,
+		"daily_reset_at":  dayStart.AddDate(0, 0, 1),
+		"weekly_reset_at": weekStart.AddDate(0, 0, 7),
+		"cooldown_hours":  int(database.SpendGuardCooldown / time.Hour),
+	})
+}
+
+// GetSpendGuardHandler returns the current user's self-imposed spend guard and spend so far
+// GET /profile/spend-guard
+func GetSpendGuardHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	guard, err := database.GetSpendGuard(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get spend guard")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"获取消费上限失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	spendGuardResponse(c, guard)
+}
+
+// UpdateSpendGuardHandler sets the current user's daily/weekly spend guard.
+// Tighter limits apply immediately; raising or removing a limit takes effect
+// after database.SpendGuardCooldown
+// PUT /profile/spend-guard
+func UpdateSpendGuardHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	var req UpdateSpendGuardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求参数无效",
+			"invalid_request",
+			"invalid_parameters",
+		))
+		return
+	}
+	if (req.DailyLimit != nil && *req.DailyLimit <= 0) || (req.WeeklyLimit != nil && *req.WeeklyLimit <= 0) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"消费上限必须大于 0，取消上限请传 null",
+			"invalid_request",
+			"invalid_limit",
+		))
+		return
+	}
+
+	guard, err := database.GetSpendGuard(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get spend guard")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"更新消费上限失败",
+			"internal_error",
+			"update_failed",
+		))
+		return
+	}
+
+	guard.ChangeLimits(req.DailyLimit, req.WeeklyLimit, time.Now())
+	if err := database.SaveSpendGuard(guard); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to save spend guard")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"更新消费上限失败",
+			"internal_error",
+			"update_failed",
+		))
+		return
+	}
+	middleware.InvalidateSpendGuard(userID)
+
+	spendGuardResponse(c, guard)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SudoRequest 进入 sudo 模式的请求；启用了两步验证的账号还需提交验证码或备用码
+type SudoRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"`
+}
+
+// sudoSession 返回当前管理员会话的用户 ID 与会话 ID（JWT 模式下为登录标识），失败时已写入错误响应
+func sudoSession(c *gin.Context) (int64, string, bool) {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(int64)
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		sessionID = c.GetString("token_family")
+	}
+	if id <= 0 || sessionID == "" {
+		writeError(c, http.StatusBadRequest, "sudo_not_applicable", "管理员令牌无需进入 sudo 模式")
+		return 0, "", false
+	}
+	if c.GetString("role") != "admin" {
+		writeError(c, http.StatusForbidden, "admin_only", "仅限管理员访问")
+		return 0, "", false
+	}
+	return id, sessionID, true
+}
+
+// EnterSudoHandler 重新输入密码（启用两步验证时还需验证码），让当前会话在 10 分钟内可以执行危险的管理操作
+// POST /auth/sudo
+func EnterSudoHandler(c *gin.Context) {
+	userID, sessionID, ok := sudoSession(c)
+	if !ok {
+		return
+	}
+
+	var req SudoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请输入密码")
+		return
+	}
+
+	if !checkReauthLock(c, userID) {
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		logrus.Errorf("Failed to query user %d for sudo: %v", userID, err)
+		writeServerError(c)
+		return
+	}
+
+	details := gin.H{"ip": c.ClientIP()}
+	if !database.ValidatePassword(user, req.Password) {
+		recordReauthFailure(userID)
+		if err := database.CreateAuditLog(&userID, database.AuditActionSudoDenied, "session", sessionID[:8], details); err != nil {
+			logrus.WithError(err).Error("Failed to audit denied sudo attempt")
+		}
+		writeError(c, http.StatusUnauthorized, "invalid_credentials", "密码错误")
+		return
+	}
+
+	twoFactorEnabled, err := database.IsTwoFactorEnabled(userID)
+	if err != nil {
+		logrus.Errorf("Failed to check two-factor status of user %d for sudo: %v", userID, err)
+		writeServerError(c)
+		return
+	}
+	if twoFactorEnabled {
+		if req.Code == "" {
+			writeError(c, http.StatusUnauthorized, "two_factor_required", "请输入两步验证码或备用码")
+			return
+		}
+		method, err := services.VerifyTwoFactorCode(userID, req.Code)
+		switch err {
+		case nil:
+			details["two_factor"] = method
+		case services.ErrTwoFactorCodeInvalid:
+			recordReauthFailure(userID)
+			details["reason"] = "invalid_code"
+			if err := database.CreateAuditLog(&userID, database.AuditActionSudoDenied, "session", sessionID[:8], details); err != nil {
+				logrus.WithError(err).Error("Failed to audit denied sudo attempt")
+			}
+			writeError(c, http.StatusUnauthorized, "invalid_code", "验证码错误")
+			return
+		default:
+			logrus.Errorf("Failed to verify two-factor code of user %d for sudo: %v", userID, err)
+			writeServerError(c)
+			return
+		}
+	}
+
+	resetReauthFailures(userID)
+
+	until := time.Now().Add(middleware.SudoDuration)
+	resp := gin.H{
+		"elevated":       true,
+		"elevated_until": until,
+	}
+	if c.GetString("token_family") != "" {
+		// JWT 模式下签发带 sudo 截止时间的新访问令牌
+		token, _, err := middleware.IssueAccessToken(userID, user.Username, user.Role, sessionID, until)
+		if err != nil {
+			logrus.Errorf("Failed to issue elevated access token for user %d: %v", userID, err)
+			writeServerError(c)
+			return
+		}
+		middleware.SetAccessTokenCookie(c, token)
+		resp["access_token"] = token
+	} else if err := database.ElevateSession(sessionID, until); err != nil {
+		logrus.Errorf("Failed to elevate session for user %d: %v", userID, err)
+		writeServerError(c)
+		return
+	}
+	details["elevated_until"] = until
+	if err := database.CreateAuditLog(&userID, database.AuditActionSudoGrant, "session", sessionID[:8], details); err != nil {
+		logrus.WithError(err).Error("Failed to audit sudo grant")
+	}
+	logrus.Infof("User %s entered sudo mode until %s", user.Username, until.Format(time.RFC3339))
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetSudoStatusHandler 查询当前会话是否处于 sudo 模式
+// GET /auth/sudo
+func GetSudoStatusHandler(c *gin.Context) {
+	_, sessionID, ok := sudoSession(c)
+	if !ok {
+		return
+	}
+
+	var until time.Time
+	if c.GetString("token_family") != "" {
+		until = c.GetTime("elevated_until")
+	} else {
+		var err error
+		until, err = database.GetSessionElevation(sessionID)
+		if err != nil {
+			logrus.Errorf("Failed to get sudo status: %v", err)
+			writeServerError(c)
+			return
+		}
+	}
+
+	resp := gin.H{"elevated": !until.IsZero()}
+	if !until.IsZero() {
+		resp["elevated_until"] = until
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ExitSudoHandler 提前退出 sudo 模式
+// DELETE /auth/sudo
+func ExitSudoHandler(c *gin.Context) {
+	userID, sessionID, ok := sudoSession(c)
+	if !ok {
+		return
+	}
+
+	resp := gin.H{"elevated": false}
+	if c.GetString("token_family") != "" {
+		// JWT 模式下吊销此前签发的带 sudo 截止时间的访问令牌（该用户所有登录），再换发不带截止时间的访问令牌
+		if _, err := database.RevokeUserElevation(userID); err != nil {
+			logrus.Errorf("Failed to revoke sudo mode for user %d: %v", userID, err)
+			writeServerError(c)
+			return
+		}
+		middleware.ForgetUserTokens(userID)
+		token, _, err := middleware.IssueAccessToken(userID, c.GetString("username"), c.GetString("role"), sessionID, time.Time{})
+		if err != nil {
+			logrus.Errorf("Failed to issue access token for user %d: %v", userID, err)
+			writeServerError(c)
+			return
+		}
+		middleware.SetAccessTokenCookie(c, token)
+		resp["access_token"] = token
+	} else if err := database.DropSessionElevation(sessionID); err != nil {
+		logrus.Errorf("Failed to drop sudo mode: %v", err)
+		writeServerError(c)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
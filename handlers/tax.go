@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateTaxInfoRequest 更新税务信息请求
+type UpdateTaxInfoRequest struct {
+	Country string `json:"country"`
+	TaxID   string `json:"tax_id" binding:"max=64"`
+}
+
+// UpdateTaxRatesRequest 更新税率请求
+type UpdateTaxRatesRequest struct {
+	Rates map[string]float64 `json:"rates" binding:"required"`
+}
+
+// GetTaxInfoHandler returns the current user's tax information
+// GET /profile/tax-info
+func GetTaxInfoHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	info, err := database.GetUserTaxInfo(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get tax info")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"获取税务信息失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"country":  info.Country,
+		"tax_id":   info.TaxID,
+		"tax_rate": services.GetTaxRates()[info.Country],
+	})
+}
+
+// UpdateTaxInfoHandler updates the current user's tax country and tax ID
+// PUT /profile/tax-info
+func UpdateTaxInfoHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	var req UpdateTaxInfoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求参数无效",
+			"invalid_request",
+			"invalid_parameters",
+		))
+		return
+	}
+
+	country, err := services.NormalizeCountry(req.Country)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"无效的国家代码，请使用 ISO 3166-1 两位代码",
+			"invalid_request",
+			"invalid_country",
+		))
+		return
+	}
+
+	info := &database.UserTaxInfo{
+		Country: country,
+		TaxID:   strings.TrimSpace(req.TaxID),
+	}
+	if err := database.UpdateUserTaxInfo(userID, info); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to update tax info")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"更新税务信息失败",
+			"internal_error",
+			"update_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "税务信息更新成功",
+		"country": info.Country,
+		"tax_id":  info.TaxID,
+	})
+}
+
+// GetStatementHandler generates a monthly statement with tax lines for recharges
+// GET /api/balance/statement?month=YYYY-MM (default current month)
+func GetStatementHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	month := c.DefaultQuery("month", time.Now().Format("2006-01"))
+	start, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid month format, expected YYYY-MM",
+			"validation_error",
+			"invalid_month",
+		))
+		return
+	}
+	end := start.AddDate(0, 1, 0)
+
+	transactions, err := database.GetBalanceTransactionsInRange(userID, start, end)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get statement transactions")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to generate statement",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	taxInfo, err := database.GetUserTaxInfo(userID)
+	if err != nil {
+		taxInfo = &database.UserTaxInfo{}
+	}
+
+	var totalCredits, totalDebits, totalTax float64
+	lines := make([]gin.H, 0, len(transactions))
+	for _, tx := range transactions {
+		line := gin.H{
+			"id":            tx.ID,
+			"date":          tx.CreatedAt,
+			"type":          tx.Type,
+			"description":   tx.Description,
+			"amount":        tx.Amount,
+			"balance_after": tx.BalanceAfter,
+		}
+		if tx.Amount > 0 {
+			totalCredits += tx.Amount
+			if tx.TaxAmount > 0 {
+				line["net_amount"] = tx.Amount
+				line["tax_rate"] = tx.TaxRate
+				line["tax_amount"] = tx.TaxAmount
+				line["gross_amount"] = tx.Amount + tx.TaxAmount
+				totalTax += tx.TaxAmount
+			}
+		} else {
+			totalDebits += -tx.Amount
+		}
+		lines = append(lines, line)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":    month,
+		"currency": services.BaseCurrency,
+		"customer": gin.H{
+			"user_id": userID,
+			"country": taxInfo.Country,
+			"tax_id":  taxInfo.TaxID,
+		},
+		"lines": lines,
+		"totals": gin.H{
+			"credits":       totalCredits,
+			"debits":        totalDebits,
+			"tax":           totalTax,
+			"gross_charged": totalCredits + totalTax,
+		},
+	})
+}
+
+// AdminGetTaxRatesHandler returns the configured tax rates per country
+// GET /admin/tax-rates
+func AdminGetTaxRatesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"rates": services.GetTaxRates(),
+	})
+}
+
+// AdminUpdateTaxRatesHandler replaces the configured tax rates per country
+// PUT /admin/tax-rates
+func AdminUpdateTaxRatesHandler(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	var req UpdateTaxRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid request format",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	if err := services.UpdateTaxRates(req.Rates); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			err.Error(),
+			"validation_error",
+			"invalid_tax_rates",
+		))
+		return
+	}
+
+	logrus.WithField("rates", req.Rates).Info("Tax rates updated by admin")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tax rates updated successfully",
+		"rates":   services.GetTaxRates(),
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AcceptTermsRequest 接受服务条款请求，version 必须为当前版本
+type AcceptTermsRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// PublishTermsRequest 发布服务条款请求
+type PublishTermsRequest struct {
+	Version string `json:"version" binding:"required"`
+	Title   string `json:"title" binding:"required"`
+	Content string `json:"content" binding:"required"`
+}
+
+// termsStatus 用户的服务条款接受状态（登录与 /auth/me 响应中返回，前端据此提示重新接受）
+func termsStatus(userID int64) gin.H {
+	current := middleware.CurrentTermsVersion()
+	status := gin.H{
+		"current_version":     current,
+		"accepted_version":    nil,
+		"acceptance_required": false,
+	}
+	if current == "" {
+		return status
+	}
+
+	acceptance, err := database.GetLatestTermsAcceptance(userID)
+	if err != nil {
+		logrus.Warnf("Failed to load terms acceptance for user %d: %v", userID, err)
+		return status
+	}
+	if acceptance != nil {
+		status["accepted_version"] = acceptance.Version
+	}
+	accepted, err := middleware.HasAcceptedCurrentTerms(userID)
+	if err != nil {
+		logrus.Warnf("Failed to check terms acceptance for user %d: %v", userID, err)
+		return status
+	}
+	status["acceptance_required"] = !accepted
+	return status
+}
+
+// GetTermsHandler 获取当前服务条款
+// GET /api/terms
+func GetTermsHandler(c *gin.Context) {
+	current, err := database.GetCurrentTermsVersion()
+	if err != nil {
+		if err == database.ErrTermsNotFound {
+			writeError(c, http.StatusNotFound, "terms_not_found", "尚未发布服务条款")
+			return
+		}
+		logrus.Errorf("Failed to get current terms: %v", err)
+		writeServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"terms": current})
+}
+
+// AcceptTermsHandler 当前用户接受服务条款
+// POST /api/terms/accept
+func AcceptTermsHandler(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	id, _ := userID.(int64)
+	if !ok || id <= 0 {
+		writeError(c, http.StatusUnauthorized, "unauthorized", "未登录")
+		return
+	}
+
+	var req AcceptTermsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请求参数无效")
+		return
+	}
+
+	current := middleware.CurrentTermsVersion()
+	if current == "" {
+		writeError(c, http.StatusNotFound, "terms_not_found", "尚未发布服务条款")
+		return
+	}
+	if req.Version != current {
+		writeError(c, http.StatusConflict, "terms_version_mismatch", "服务条款已更新，请阅读最新版本后再接受")
+		return
+	}
+
+	acceptance := &database.TermsAcceptance{
+		UserID:    id,
+		Version:   current,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	if err := database.RecordTermsAcceptance(acceptance); err != nil {
+		logrus.Errorf("Failed to record terms acceptance for user %d: %v", id, err)
+		writeServerError(c)
+		return
+	}
+	middleware.RememberTermsAcceptance(id, current)
+
+	logrus.Infof("User %d accepted terms of service %s", id, current)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已接受服务条款",
+		"terms":   termsStatus(id),
+	})
+}
+
+// ListTermsVersionsHandler 列出全部服务条款版本及接受人数（管理员）
+// GET /admin/terms
+func ListTermsVersionsHandler(c *gin.Context) {
+	versions, err := database.ListTermsVersions()
+	if err != nil {
+		logrus.Errorf("Failed to list terms versions: %v", err)
+		writeServerError(c)
+		return
+	}
+
+	items := make([]gin.H, 0, len(versions))
+	for _, v := range versions {
+		accepted, err := database.CountTermsAcceptances(v.Version)
+		if err != nil {
+			logrus.Warnf("Failed to count acceptances for terms %s: %v", v.Version, err)
+		}
+		items = append(items, gin.H{
+			"id":             v.ID,
+			"version":        v.Version,
+			"title":          v.Title,
+			"published_by":   v.PublishedBy,
+			"published_at":   v.PublishedAt,
+			"accepted_count": accepted,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_version": middleware.CurrentTermsVersion(),
+		"versions":        items,
+	})
+}
+
+// PublishTermsHandler 发布新版服务条款，发布后所有用户需重新接受（管理员）
+// POST /admin/terms
+func PublishTermsHandler(c *gin.Context) {
+	var req PublishTermsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "version、title、content 不能为空")
+		return
+	}
+	req.Version = strings.TrimSpace(req.Version)
+	if req.Version == "" || len(req.Version) > 32 {
+		writeError(c, http.StatusBadRequest, "invalid_version", "version 长度必须在 1 到 32 之间")
+		return
+	}
+
+	terms := &database.TermsVersion{
+		Version: req.Version,
+		Title:   strings.TrimSpace(req.Title),
+		Content: req.Content,
+	}
+	if id, ok := c.Get("user_id"); ok {
+		if v, ok := id.(int64); ok && v > 0 {
+			terms.PublishedBy = &v
+		}
+	}
+
+	if err := database.PublishTermsVersion(terms); err != nil {
+		if err == database.ErrTermsVersionExists {
+			writeError(c, http.StatusConflict, "terms_version_exists", "该版本号已存在")
+			return
+		}
+		logrus.Errorf("Failed to publish terms %s: %v", req.Version, err)
+		writeServerError(c)
+		return
+	}
+	middleware.SetCurrentTermsVersion(terms.Version)
+
+	if err := database.CreateAuditLog(terms.PublishedBy, database.AuditActionTermsPublish, "terms", terms.Version, gin.H{
+		"title": terms.Title,
+	}); err != nil {
+		logrus.Warnf("Failed to write audit log for terms %s: %v", terms.Version, err)
+	}
+
+	logrus.Infof("Terms of service %s published", terms.Version)
+	c.JSON(http.StatusCreated, gin.H{"terms": terms})
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// trialKeyRandomLength 临时密钥前缀之后的随机部分长度
+const trialKeyRandomLength = 40
+
+// IssueTrialKeyRequest 申请匿名试用密钥的请求
+type IssueTrialKeyRequest struct {
+	TurnstileToken string `json:"turnstile_token" binding:"required"`
+}
+
+// recordTrialConversion 注册成功后将访客此前使用的临时密钥标记为已转化
+func recordTrialConversion(c *gin.Context, trialKey string, userID int64) {
+	if trialKey != "" && !middleware.IsTrialKey(trialKey) {
+		trialKey = ""
+	}
+	converted, err := database.MarkTrialConverted(trialKey, c.ClientIP(), userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to record trial conversion")
+		return
+	}
+	if converted {
+		logrus.WithField("user_id", userID).Info("Anonymous trial converted to signup")
+	}
+}
+
+// GetTrialInfoHandler 返回匿名试用是否开启及其限制，供 Playground 页面展示
+// GET /api/trial
+func GetTrialInfoHandler(c *gin.Context) {
+	cfg, err := database.GetTrialConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get trial config")
+		writeServerError(c)
+		return
+	}
+	if !cfg.Enabled {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":             true,
+		"ttl_minutes":         cfg.TTLMinutes,
+		"requests_per_minute": cfg.RequestsPerMinute,
+		"max_requests":        cfg.MaxRequests,
+		"models":              middleware.TrialModels(cfg),
+	})
+}
+
+// IssueTrialKeyHandler 为未注册访客签发绑定 IP 的临时密钥（需通过人机验证）
+// POST /api/trial/key
+func IssueTrialKeyHandler(c *gin.Context) {
+	var req IssueTrialKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请求参数无效: "+err.Error())
+		return
+	}
+
+	cfg, err := database.GetTrialConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get trial config")
+		writeServerError(c)
+		return
+	}
+	if !cfg.Enabled {
+		writeError(c, http.StatusForbidden, "trial_disabled", "匿名试用未开启")
+		return
+	}
+
+	if turnstileService == nil {
+		logrus.Error("Turnstile service not initialized")
+		writeError(c, http.StatusInternalServerError, "service_error", "验证服务未初始化")
+		return
+	}
+	ip := c.ClientIP()
+	if success, err := turnstileService.VerifyToken(req.TurnstileToken, ip); err != nil || !success {
+		logrus.Warnf("Turnstile verification failed for IP %s: %v", ip, err)
+		writeError(c, http.StatusBadRequest, "captcha_failed", "人机验证失败，请重试")
+		return
+	}
+
+	if cfg.MaxKeysPerIPDay > 0 {
+		count, err := database.CountTrialKeysByIPSince(ip, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to count trial keys")
+			writeServerError(c)
+			return
+		}
+		if count >= cfg.MaxKeysPerIPDay {
+			writeError(c, http.StatusTooManyRequests, "trial_ip_limit", "今日试用次数已用完，注册账号即可继续使用")
+			return
+		}
+	}
+
+	key := middleware.TrialKeyPrefix + utils.GenerateRandomString(trialKeyRandomLength)
+	trial, err := database.CreateTrialKey(key, ip, time.Now().Add(time.Duration(cfg.TTLMinutes)*time.Minute))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create trial key")
+		writeServerError(c)
+		return
+	}
+	logrus.WithField("ip", ip).Info("Issued anonymous trial key")
+
+	c.JSON(http.StatusOK, gin.H{
+		"key":                 key,
+		"expires_at":          trial.ExpiresAt,
+		"requests_per_minute": cfg.RequestsPerMinute,
+		"max_requests":        cfg.MaxRequests,
+		"models":              middleware.TrialModels(cfg),
+	})
+}
+
+// GetTrialConfigHandler 获取匿名试用配置
+// GET /admin/trial/config
+func GetTrialConfigHandler(c *gin.Context) {
+	cfg, err := database.GetTrialConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get trial config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_trial_config_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+// UpdateTrialConfigHandler 更新匿名试用配置
+// PUT /admin/trial/config
+func UpdateTrialConfigHandler(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	var cfg database.TrialConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	var msg string
+	switch {
+	case cfg.TTLMinutes < 1 || cfg.TTLMinutes > 24*60:
+		msg = "ttl_minutes must be between 1 and 1440"
+	case cfg.RequestsPerMinute < 1:
+		msg = "requests_per_minute must be at least 1"
+	case cfg.MaxRequests < 0 || cfg.MaxKeysPerIPDay < 0:
+		msg = "limits must not be negative (0 means unlimited)"
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, "validation_error", "invalid_request"))
+		return
+	}
+
+	if err := database.SaveTrialConfig(&cfg); err != nil {
+		logrus.WithError(err).Error("Failed to save trial config")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"update_trial_config_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+// GetTrialStatsHandler 统计最近 days 天（默认 30，最多为保留期）签发的临时密钥及注册转化
+// GET /admin/trial/stats
+func GetTrialStatsHandler(c *gin.Context) {
+	maxDays := int(database.TrialKeyRetention / (24 * time.Hour))
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxDays {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"days must be between 1 and "+strconv.Itoa(maxDays),
+			"validation_error",
+			"invalid_days",
+		))
+		return
+	}
+
+	stats, err := database.GetTrialStats(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get trial stats")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"get_trial_stats_failed",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/services"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// twoFactorChallengeTTL 密码验证通过后输入两步验证码的时限
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// maxTwoFactorAttempts 每个登录挑战允许输错验证码的次数，用完后挑战作废，需要重新输入密码
+const maxTwoFactorAttempts = 5
+
+// twoFactorFailures 登录挑战的验证码错误次数，按挑战 ID 记录，挑战过期后清理
+var twoFactorFailures = struct {
+	sync.Mutex
+	count   map[string]int
+	expires map[string]time.Time
+}{count: make(map[string]int), expires: make(map[string]time.Time)}
+
+// recordTwoFactorFailure 记录一次验证码错误，返回该挑战剩余的尝试次数
+func recordTwoFactorFailure(claims *services.SignedTokenClaims) int {
+	twoFactorFailures.Lock()
+	defer twoFactorFailures.Unlock()
+
+	now := time.Now()
+	for id, exp := range twoFactorFailures.expires {
+		if now.After(exp) {
+			delete(twoFactorFailures.count, id)
+			delete(twoFactorFailures.expires, id)
+		}
+	}
+	twoFactorFailures.count[claims.ID]++
+	twoFactorFailures.expires[claims.ID] = claims.Expiry()
+	return maxTwoFactorAttempts - twoFactorFailures.count[claims.ID]
+}
+
+// maxReauthAttempts 已登录用户重新验证身份（进入 sudo、关闭两步验证）时允许连续输错密码或验证码的次数，
+// 用完后锁定 reauthLockout，期间不再校验密码与验证码
+const maxReauthAttempts = 5
+
+// reauthLockout 重新验证身份的失败计数窗口与锁定时长
+const reauthLockout = 15 * time.Minute
+
+// reauthFailures 按用户记录重新验证身份的连续失败次数与锁定截止时间
+var reauthFailures = struct {
+	sync.Mutex
+	count       map[int64]int
+	since       map[int64]time.Time
+	lockedUntil map[int64]time.Time
+}{count: make(map[int64]int), since: make(map[int64]time.Time), lockedUntil: make(map[int64]time.Time)}
+
+// checkReauthLock 用户因连续输错被锁定时写入 429 响应并返回 false
+func checkReauthLock(c *gin.Context, userID int64) bool {
+	reauthFailures.Lock()
+	until := reauthFailures.lockedUntil[userID]
+	reauthFailures.Unlock()
+
+	wait := time.Until(until)
+	if wait <= 0 {
+		return true
+	}
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	writeError(c, http.StatusTooManyRequests, "too_many_attempts", "密码或验证码错误次数过多，请稍后重试")
+	return false
+}
+
+// recordReauthFailure 记录一次密码或验证码错误，达到上限时锁定该用户，返回剩余的尝试次数
+func recordReauthFailure(userID int64) int {
+	reauthFailures.Lock()
+	defer reauthFailures.Unlock()
+
+	now := time.Now()
+	if now.Sub(reauthFailures.since[userID]) > reauthLockout {
+		reauthFailures.count[userID] = 0
+		reauthFailures.since[userID] = now
+	}
+	reauthFailures.count[userID]++
+	remaining := maxReauthAttempts - reauthFailures.count[userID]
+	if remaining <= 0 {
+		reauthFailures.lockedUntil[userID] = now.Add(reauthLockout)
+		delete(reauthFailures.count, userID)
+		delete(reauthFailures.since, userID)
+	}
+	return remaining
+}
+
+// resetReauthFailures 验证通过后清除用户的失败计数
+func resetReauthFailures(userID int64) {
+	reauthFailures.Lock()
+	defer reauthFailures.Unlock()
+	delete(reauthFailures.count, userID)
+	delete(reauthFailures.since, userID)
+	delete(reauthFailures.lockedUntil, userID)
+}
+
+// TwoFactorLoginRequest 登录第二步：提交密码验证后返回的挑战与验证码（或备用码）
+type TwoFactorLoginRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest 提交验证器中的验证码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest 关闭两步验证需同时验证密码与验证码（或备用码）
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// UpdateTwoFactorPolicyRequest 修改管理员两步验证要求
+type UpdateTwoFactorPolicyRequest struct {
+	RequireForAdmins *bool `json:"require_for_admins" binding:"required"`
+}
+
+// issueTwoFactorChallenge 为已通过密码验证的用户签发一次性登录挑战，签名令牌服务不可用时返回 false
+func issueTwoFactorChallenge(userID int64) (string, *services.SignedTokenClaims, bool) {
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		return "", nil, false
+	}
+	token, claims, err := tokens.Issue(services.TokenPurposeTwoFactorLogin, "", userID, twoFactorChallengeTTL, true)
+	if err != nil {
+		logrus.Errorf("Failed to issue two-factor challenge for user %d: %v", userID, err)
+		return "", nil, false
+	}
+	return token, claims, true
+}
+
+// twoFactorStatus 用户的两步验证状态（/auth/me 响应中返回，前端据此提示管理员完成设置）
+func twoFactorStatus(user *database.User) gin.H {
+	status := gin.H{
+		"enabled":        false,
+		"setup_required": false,
+	}
+	enabled, err := database.IsTwoFactorEnabled(user.ID)
+	if err != nil {
+		logrus.Warnf("Failed to load two-factor status for user %d: %v", user.ID, err)
+		return status
+	}
+	status["enabled"] = enabled
+	status["setup_required"] = !enabled && user.Role == "admin" && middleware.AdminTwoFactorRequired()
+	return status
+}
+
+// twoFactorUser 返回当前会话用户，失败时已写入错误响应
+func twoFactorUser(c *gin.Context) (*database.User, bool) {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(int64)
+	if id <= 0 {
+		writeError(c, http.StatusUnauthorized, "unauthorized", "未登录")
+		return nil, false
+	}
+	user, err := database.GetUserByID(id)
+	if err != nil {
+		logrus.Errorf("Failed to query user %d for two-factor: %v", id, err)
+		writeServerError(c)
+		return nil, false
+	}
+	return user, true
+}
+
+// auditTwoFactor 写入两步验证相关的审计记录
+func auditTwoFactor(c *gin.Context, userID int64, action string, details gin.H) {
+	if details == nil {
+		details = gin.H{}
+	}
+	details["ip"] = c.ClientIP()
+	if err := database.CreateAuditLog(&userID, action, "user", strconv.FormatInt(userID, 10), details); err != nil {
+		logrus.WithError(err).Errorf("Failed to audit %s", action)
+	}
+}
+
+// LoginTwoFactorHandler 登录第二步：校验验证码或备用码后创建会话
+// POST /auth/login/2fa
+func LoginTwoFactorHandler(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请输入验证码")
+		return
+	}
+
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		writeError(c, http.StatusServiceUnavailable, "two_factor_unavailable", "两步验证暂不可用，请稍后重试")
+		return
+	}
+	claims, err := tokens.Verify(req.Challenge, services.TokenPurposeTwoFactorLogin)
+	if err != nil {
+		writeError(c, http.StatusUnauthorized, "invalid_challenge", "登录已过期，请重新输入密码")
+		return
+	}
+
+	method, err := services.VerifyTwoFactorCode(claims.UserID, req.Code)
+	switch err {
+	case nil:
+	case services.ErrTwoFactorCodeInvalid:
+		if remaining := recordTwoFactorFailure(claims); remaining <= 0 {
+			if err := tokens.RevokeID(claims.ID, claims.Purpose, claims.Expiry()); err != nil {
+				logrus.WithError(err).Warn("Failed to revoke two-factor challenge")
+			}
+			writeError(c, http.StatusUnauthorized, "too_many_attempts", "验证码错误次数过多，请重新登录")
+			return
+		}
+		writeError(c, http.StatusUnauthorized, "invalid_code", "验证码错误")
+		return
+	case services.ErrTwoFactorNotEnabled:
+		// 签发挑战后两步验证已被关闭，要求重新登录
+		writeError(c, http.StatusUnauthorized, "invalid_challenge", "登录已过期，请重新输入密码")
+		return
+	default:
+		logrus.Errorf("Failed to verify two-factor code for user %d: %v", claims.UserID, err)
+		writeServerError(c)
+		return
+	}
+
+	// 挑战只能使用一次，并发提交时只有一个请求能创建会话
+	if _, err := tokens.Consume(req.Challenge, services.TokenPurposeTwoFactorLogin); err != nil {
+		writeError(c, http.StatusUnauthorized, "invalid_challenge", "登录已过期，请重新输入密码")
+		return
+	}
+
+	user, err := database.GetUserByID(claims.UserID)
+	if err != nil {
+		logrus.Errorf("Failed to query user %d after two-factor: %v", claims.UserID, err)
+		writeServerError(c)
+		return
+	}
+	if !user.IsActive {
+		writeError(c, http.StatusForbidden, "account_disabled", "您的账号存在问题，请联系管理员")
+		return
+	}
+
+	if method == "backup_code" {
+		auditTwoFactor(c, user.ID, database.AuditActionTwoFactorBackupLogin, nil)
+	}
+	completeLogin(c, user)
+}
+
+// GetTwoFactorStatusHandler 查询当前用户的两步验证状态与剩余备用码数量
+// GET /auth/2fa
+func GetTwoFactorStatusHandler(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	resp := twoFactorStatus(user)
+	tf, err := database.GetUserTwoFactor(user.ID)
+	if err != nil && err != database.ErrTwoFactorNotFound {
+		logrus.Errorf("Failed to load two-factor for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
+	if tf != nil && tf.Enabled {
+		resp["enabled_at"] = tf.EnabledAt
+		resp["backup_codes_remaining"] = len(tf.BackupCodes)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetupTwoFactorHandler 生成新的 TOTP 密钥，返回密钥与 otpauth:// URI 供验证器扫码
+// 需再调用 POST /auth/2fa/enable 提交验证码后才会启用
+// POST /auth/2fa/setup
+func SetupTwoFactorHandler(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	setup, err := services.BeginTwoFactorSetup(user)
+	if err != nil {
+		if err == database.ErrTwoFactorAlreadyEnabled {
+			writeError(c, http.StatusConflict, "two_factor_already_enabled", "已启用两步验证，如需更换验证器请先关闭")
+			return
+		}
+		logrus.Errorf("Failed to start two-factor setup for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// EnableTwoFactorHandler 提交验证器中的验证码确认设置，启用两步验证并返回备用码（仅显示一次）
+// POST /auth/2fa/enable
+func EnableTwoFactorHandler(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请输入验证码")
+		return
+	}
+
+	codes, err := services.ConfirmTwoFactorSetup(user.ID, req.Code)
+	switch err {
+	case nil:
+	case services.ErrTwoFactorCodeInvalid:
+		writeError(c, http.StatusBadRequest, "invalid_code", "验证码错误，请确认验证器时间准确")
+		return
+	case database.ErrTwoFactorNotFound:
+		writeError(c, http.StatusBadRequest, "two_factor_not_setup", "请先生成两步验证密钥")
+		return
+	case database.ErrTwoFactorAlreadyEnabled:
+		writeError(c, http.StatusConflict, "two_factor_already_enabled", "已启用两步验证")
+		return
+	default:
+		logrus.Errorf("Failed to enable two-factor for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
+
+	auditTwoFactor(c, user.ID, database.AuditActionTwoFactorEnable, nil)
+	logrus.Infof("User %s enabled two-factor authentication", user.Username)
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":      true,
+		"backup_codes": codes,
+	})
+}
+
+// DisableTwoFactorHandler 验证密码与验证码后关闭两步验证；要求管理员启用两步验证时管理员无法关闭
+// POST /auth/2fa/disable
+func DisableTwoFactorHandler(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请输入密码与验证码")
+		return
+	}
+	if user.Role == "admin" && middleware.AdminTwoFactorRequired() {
+		writeError(c, http.StatusForbidden, "two_factor_required", "系统要求管理员启用两步验证，无法关闭")
+		return
+	}
+	if !checkReauthLock(c, user.ID) {
+		return
+	}
+	if !database.ValidatePassword(user, req.Password) {
+		recordReauthFailure(user.ID)
+		writeError(c, http.StatusUnauthorized, "invalid_credentials", "密码错误")
+		return
+	}
+
+	if _, err := services.VerifyTwoFactorCode(user.ID, req.Code); err != nil {
+		switch err {
+		case services.ErrTwoFactorCodeInvalid:
+			recordReauthFailure(user.ID)
+			writeError(c, http.StatusUnauthorized, "invalid_code", "验证码错误")
+		case services.ErrTwoFactorNotEnabled:
+			writeError(c, http.StatusBadRequest, "two_factor_not_enabled", "尚未启用两步验证")
+		default:
+			logrus.Errorf("Failed to verify two-factor code for user %d: %v", user.ID, err)
+			writeServerError(c)
+		}
+		return
+	}
+
+	resetReauthFailures(user.ID)
+
+	if err := database.DisableTwoFactor(user.ID); err != nil {
+		logrus.Errorf("Failed to disable two-factor for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
+	auditTwoFactor(c, user.ID, database.AuditActionTwoFactorDisable, nil)
+	logrus.Infof("User %s disabled two-factor authentication", user.Username)
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// RegenerateBackupCodesHandler 验证验证码后重新生成备用码，旧备用码全部失效
+// POST /auth/2fa/backup-codes
+func RegenerateBackupCodesHandler(c *gin.Context) {
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "请输入验证码")
+		return
+	}
+
+	if _, err := services.VerifyTwoFactorCode(user.ID, req.Code); err != nil {
+		switch err {
+		case services.ErrTwoFactorCodeInvalid:
+			writeError(c, http.StatusUnauthorized, "invalid_code", "验证码错误")
+		case services.ErrTwoFactorNotEnabled:
+			writeError(c, http.StatusBadRequest, "two_factor_not_enabled", "尚未启用两步验证")
+		default:
+			logrus.Errorf("Failed to verify two-factor code for user %d: %v", user.ID, err)
+			writeServerError(c)
+		}
+		return
+	}
+
+	codes, err := services.RegenerateBackupCodes(user.ID)
+	if err != nil {
+		logrus.Errorf("Failed to regenerate backup codes for user %d: %v", user.ID, err)
+		writeServerError(c)
+		return
+	}
+	auditTwoFactor(c, user.ID, database.AuditActionTwoFactorBackupCodes, nil)
+	c.JSON(http.StatusOK, gin.H{"backup_codes": codes})
+}
+
+// GetTwoFactorPolicyHandler 获取管理员两步验证要求
+// GET /admin/security/two-factor
+func GetTwoFactorPolicyHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"require_for_admins": middleware.AdminTwoFactorRequired()})
+}
+
+// UpdateTwoFactorPolicyHandler 开启或关闭管理员两步验证要求。开启后未启用两步验证的管理员
+// 会话无法访问管理接口，需先在个人设置中完成设置；为避免把自己锁在外面，开启前当前管理员须已启用
+// PUT /admin/security/two-factor
+func UpdateTwoFactorPolicyHandler(c *gin.Context) {
+	var req UpdateTwoFactorPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request", "require_for_admins 不能为空")
+		return
+	}
+
+	var actorID *int64
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(int64); ok && id > 0 {
+			actorID = &id
+		}
+	}
+	if *req.RequireForAdmins && actorID != nil {
+		enabled, err := database.IsTwoFactorEnabled(*actorID)
+		if err != nil {
+			logrus.Errorf("Failed to check two-factor for user %d: %v", *actorID, err)
+			writeServerError(c)
+			return
+		}
+		if !enabled {
+			writeError(c, http.StatusBadRequest, "two_factor_not_enabled", "请先为自己的账号启用两步验证")
+			return
+		}
+	}
+
+	if err := middleware.SetAdminTwoFactorRequired(*req.RequireForAdmins); err != nil {
+		logrus.Errorf("Failed to save admin two-factor policy: %v", err)
+		writeServerError(c)
+		return
+	}
+	details := gin.H{"require_for_admins": *req.RequireForAdmins}
+	if err := database.CreateAuditLog(actorID, database.AuditActionTwoFactorPolicy, "setting", database.SettingKeyRequireAdminTwoFactor, details); err != nil {
+		logrus.WithError(err).Error("Failed to audit two-factor policy change")
+	}
+	c.JSON(http.StatusOK, gin.H{"require_for_admins": *req.RequireForAdmins})
+}
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"Curry2API-go/database"
+	"Curry2API-go/middleware"
 	"Curry2API-go/models"
 	"Curry2API-go/services"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -375,6 +378,10 @@ func GetAdminUsageStats(c *gin.Context) {
 		filter.Model = &model
 	}
 
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+
 	// Get aggregate statistics from database
 	stats, err := database.GetAllUsageStats(filter)
 	if err != nil {
@@ -394,6 +401,8 @@ func GetAdminUsageStats(c *gin.Context) {
 		"total_tokens":   stats.TotalTokens,
 		"top_users":      formatTopUsers(stats.TopUsers),
 		"top_models":     formatTopModels(stats.TopModels),
+		"aggregate_only": !filter.PerUserAllowed(),
+		"suppressed":     stats.Suppressed,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -467,8 +476,17 @@ func GetAdminUsageTrends(c *gin.Context) {
 		}
 	}
 
+	filter := database.UsageFilter{UserID: userID}
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+
 	// Get daily usage trends from database
-	trends, err := database.GetDailyUsageTrends(userID, days)
+	trends, err := database.GetAdminDailyUsageTrends(filter, days)
+	if errors.Is(err, database.ErrAggregateOnly) {
+		writeAggregateOnlyError(c)
+		return
+	}
 	if err != nil {
 		logrus.WithError(err).Error("Failed to get usage trends")
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
@@ -497,10 +515,11 @@ func GetAdminUsageTrends(c *gin.Context) {
 
 	// Format response for chart display
 	response := gin.H{
-		"view":        view,
-		"days":        days,
-		"trends":      formattedTrends,
-		"growth_rate": growthRate,
+		"view":           view,
+		"days":           days,
+		"trends":         formattedTrends,
+		"growth_rate":    growthRate,
+		"aggregate_only": !filter.PerUserAllowed(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -677,6 +696,10 @@ func GetAdminCursorSessionUsage(c *gin.Context) {
 		filter.EndDate = &endDate
 	}
 
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+
 	// Get Cursor session usage from database
 	sessions, err := database.GetCursorSessionUsage(filter)
 	if err != nil {
@@ -700,8 +723,95 @@ func GetAdminCursorSessionUsage(c *gin.Context) {
 	}
 
 	response := gin.H{
-		"sessions": formattedSessions,
-		"total":    len(formattedSessions),
+		"sessions":       formattedSessions,
+		"total":          len(formattedSessions),
+		"aggregate_only": !filter.PerUserAllowed(),
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetAdminLanguageDistribution retrieves usage grouped by detected prompt language
+func GetAdminLanguageDistribution(c *gin.Context) {
+	// Parse query parameters for filtering
+	filter := database.UsageFilter{}
+
+	// Parse start_date
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		startDate, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid start_date format. Expected YYYY-MM-DD",
+				"invalid_request_error",
+				"invalid_date_format",
+			))
+			return
+		}
+		filter.StartDate = &startDate
+	}
+
+	// Parse end_date
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		endDate, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid end_date format. Expected YYYY-MM-DD",
+				"invalid_request_error",
+				"invalid_date_format",
+			))
+			return
+		}
+		// Set to end of day
+		endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+		filter.EndDate = &endDate
+	}
+
+	// Parse model filter
+	if model := c.Query("model"); model != "" {
+		filter.Model = &model
+	}
+
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+
+	languages, err := database.GetLanguageDistribution(filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get language distribution")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to retrieve language distribution",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	var totalRequests int
+	for _, lang := range languages {
+		totalRequests += lang.Requests
+	}
+
+	// Format response; share is each language's fraction of the listed requests
+	formattedLanguages := make([]gin.H, 0, len(languages))
+	for _, lang := range languages {
+		share := 0.0
+		if totalRequests > 0 {
+			share = float64(lang.Requests) / float64(totalRequests)
+		}
+		formattedLanguages = append(formattedLanguages, gin.H{
+			"language":     lang.Language,
+			"requests":     lang.Requests,
+			"total_tokens": lang.TotalTokens,
+			"users":        lang.Users,
+			"share":        share,
+		})
+	}
+
+	response := gin.H{
+		"languages":      formattedLanguages,
+		"total_requests": totalRequests,
+		"detection":      middleware.LanguageDetectionEnabled(),
+		"aggregate_only": !filter.PerUserAllowed(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -755,6 +865,15 @@ func ExportUsageData(c *gin.Context) {
 		filter.Model = &model
 	}
 
+	// Raw records are per-user data, unavailable in aggregation-only mode unless overridden
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+	if !filter.PerUserAllowed() {
+		writeAggregateOnlyError(c)
+		return
+	}
+
 	// Set appropriate CSV headers
 	filename := fmt.Sprintf("usage_export_%s.csv", time.Now().Format("2006-01-02_15-04-05"))
 	c.Header("Content-Type", "text/csv")
@@ -912,3 +1031,148 @@ func GetCleanupStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// usageExportLinkTTL 使用数据导出下载链接的有效期
+const usageExportLinkTTL = 10 * time.Minute
+
+// CreateUsageExportLink 生成一次性的使用数据导出下载链接（10 分钟内有效），
+// 供浏览器直接下载而无需携带管理员凭据；查询参数与 /admin/usage/export 相同
+// POST /admin/usage/export-link
+func CreateUsageExportLink(c *gin.Context) {
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		signedTokensUnavailable(c)
+		return
+	}
+
+	// 聚合模式下链接签发时即完成绕过审计，下载时不再重复校验
+	var filter database.UsageFilter
+	if !applyUsagePrivacyOverride(c, &filter) {
+		return
+	}
+	if !filter.PerUserAllowed() {
+		writeAggregateOnlyError(c)
+		return
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"start_date", "end_date", "user_id", "model", "privacy_override"} {
+		if v := c.Query(key); v != "" {
+			query.Set(key, v)
+		}
+	}
+
+	var adminID int64
+	if id, ok := c.Get("user_id"); ok {
+		if v, ok := id.(int64); ok && v > 0 {
+			adminID = v
+		}
+	}
+	token, claims, err := tokens.Issue(services.TokenPurposeUsageExport, query.Encode(), adminID, usageExportLinkTTL, true)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to issue usage export link")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"export_link_failed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        "/api/downloads/usage-export?token=" + url.QueryEscape(token),
+		"token_id":   claims.ID,
+		"expires_at": claims.Expiry(),
+	})
+}
+
+// DownloadUsageExport 通过一次性下载链接导出使用数据 CSV
+// GET /api/downloads/usage-export?token=...
+func DownloadUsageExport(c *gin.Context) {
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		signedTokensUnavailable(c)
+		return
+	}
+
+	claims, err := tokens.Consume(c.Query("token"), services.TokenPurposeUsageExport)
+	if err != nil {
+		writeSignedTokenError(c, err)
+		return
+	}
+
+	// 按签发时记录的筛选条件导出，忽略链接上的其他参数
+	c.Request.URL.RawQuery = claims.Subject
+	if c.Query("privacy_override") == "true" {
+		c.Set(usagePrivacyAuditedKey, true)
+	}
+	ExportUsageData(c)
+}
+
+// RevokeSignedTokenRequest 吊销签名令牌请求
+type RevokeSignedTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RevokeSignedTokenHandler 在到期前吊销任意用途的签名令牌（分享链接、下载链接、续传令牌）
+// POST /admin/signed-tokens/revoke
+func RevokeSignedTokenHandler(c *gin.Context) {
+	tokens := services.GetSignedTokenService()
+	if tokens == nil {
+		signedTokensUnavailable(c)
+		return
+	}
+
+	var req RevokeSignedTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"token 不能为空",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	claims, err := tokens.Revoke(req.Token)
+	if err != nil {
+		writeSignedTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "token revoked",
+		"token_id": claims.ID,
+		"purpose":  claims.Purpose,
+	})
+}
+
+// signedTokensUnavailable 签名令牌服务未启动时的响应
+func signedTokensUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+		"Signed links are not available",
+		"service_unavailable",
+		"signed_tokens_unavailable",
+	))
+}
+
+// writeSignedTokenError 将签名令牌校验错误转换为响应
+func writeSignedTokenError(c *gin.Context, err error) {
+	status, code := http.StatusUnauthorized, "invalid_token"
+	switch err {
+	case services.ErrSignedTokenExpired:
+		code = "token_expired"
+	case services.ErrSignedTokenRevoked:
+		code = "token_revoked"
+	case services.ErrSignedTokenReplayed:
+		code = "token_already_used"
+	case services.ErrSignedTokenInvalid, services.ErrSignedTokenPurpose:
+	default:
+		logrus.WithError(err).Error("Failed to verify signed token")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"token_verification_failed",
+		))
+		return
+	}
+	c.JSON(status, models.NewErrorResponse(err.Error(), "authentication_error", code))
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// usageErrorType 将失败请求的状态码归类为便于排查的错误类型
+func usageErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication"
+	case status == http.StatusPaymentRequired:
+		return "insufficient_balance"
+	case status == http.StatusForbidden:
+		return "permission"
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable:
+		return "upstream_unavailable"
+	case status >= 500:
+		return "server_error"
+	default:
+		return "invalid_request"
+	}
+}
+
+// GetUserUsageErrors 汇总当前用户在时间范围内的失败请求：按状态码、错误类型、模型、密钥分组，并列出最常见的错误原因
+// 默认统计最近 7 天；查询参数 start_date/end_date（YYYY-MM-DD）、model、limit（错误原因条数，默认 20，最大 100）
+// GET /api/usage/errors
+func GetUserUsageErrors(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	now := time.Now()
+	startDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -6)
+	endDate := now
+	if s := c.Query("start_date"); s != "" {
+		if startDate, err = time.ParseInLocation("2006-01-02", s, now.Location()); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid start_date format. Expected YYYY-MM-DD",
+				"invalid_request_error",
+				"invalid_date_format",
+			))
+			return
+		}
+	}
+	if s := c.Query("end_date"); s != "" {
+		if endDate, err = time.ParseInLocation("2006-01-02", s, now.Location()); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Invalid end_date format. Expected YYYY-MM-DD",
+				"invalid_request_error",
+				"invalid_date_format",
+			))
+			return
+		}
+		endDate = endDate.Add(24*time.Hour - time.Second)
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"end_date must not be before start_date",
+			"invalid_request_error",
+			"invalid_date_range",
+		))
+		return
+	}
+
+	filter := database.UsageFilter{StartDate: &startDate, EndDate: &endDate, Limit: 20}
+	if model := c.Query("model"); model != "" {
+		filter.Model = &model
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = min(limit, 100)
+	}
+
+	summary, err := database.GetUserErrorSummary(userID, filter)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get user error summary")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to retrieve error statistics",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+
+	errorRate := 0.0
+	if summary.TotalRequests > 0 {
+		errorRate = float64(summary.FailedRequests) / float64(summary.TotalRequests)
+	}
+
+	byStatus := make([]gin.H, 0, len(summary.ByStatus))
+	typeCounts := make(map[string]int)
+	for _, g := range summary.ByStatus {
+		status, _ := strconv.Atoi(g.Key)
+		errorType := usageErrorType(status)
+		typeCounts[errorType] += g.Count
+		byStatus = append(byStatus, gin.H{
+			"status_code": status,
+			"error_type":  errorType,
+			"count":       g.Count,
+			"last_seen":   g.LastSeen.Format(time.RFC3339),
+		})
+	}
+	byType := make([]gin.H, 0, len(typeCounts))
+	for errorType, count := range typeCounts {
+		byType = append(byType, gin.H{"error_type": errorType, "count": count})
+	}
+	sort.Slice(byType, func(i, j int) bool {
+		return byType[i]["count"].(int) > byType[j]["count"].(int)
+	})
+
+	byModel := make([]gin.H, 0, len(summary.ByModel))
+	for _, g := range summary.ByModel {
+		byModel = append(byModel, gin.H{
+			"model":     g.Key,
+			"count":     g.Count,
+			"last_seen": g.LastSeen.Format(time.RFC3339),
+		})
+	}
+
+	// usage_records 只保存密钥指纹，按用户当前的密钥还原为脱敏密钥
+	maskedKeys := make(map[string]string)
+	for _, key := range middleware.GetKeyManager().ListKeysByUser(userID) {
+		maskedKeys[database.APITokenFingerprint(key.Key)] = key.MaskedKey
+	}
+	byKey := make([]gin.H, 0, len(summary.ByKey))
+	for _, g := range summary.ByKey {
+		entry := gin.H{
+			"token_name": g.TokenName,
+			"count":      g.Count,
+			"last_seen":  g.LastSeen.Format(time.RFC3339),
+		}
+		switch {
+		case g.Key == database.UsageTokenChat:
+			entry["masked_key"] = "web-chat"
+		case maskedKeys[g.Key] != "":
+			entry["masked_key"] = maskedKeys[g.Key]
+		default:
+			entry["masked_key"] = "deleted"
+		}
+		byKey = append(byKey, entry)
+	}
+
+	reasons := make([]gin.H, 0, len(summary.TopReasons))
+	for _, r := range summary.TopReasons {
+		reasons = append(reasons, gin.H{
+			"status_code": r.StatusCode,
+			"error_type":  usageErrorType(r.StatusCode),
+			"model":       r.Model,
+			"message":     r.Message,
+			"count":       r.Count,
+			"last_seen":   r.LastSeen.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date":      startDate.Format("2006-01-02"),
+		"end_date":        endDate.Format("2006-01-02"),
+		"total_requests":  summary.TotalRequests,
+		"failed_requests": summary.FailedRequests,
+		"error_rate":      errorRate,
+		"by_status":       byStatus,
+		"by_error_type":   byType,
+		"by_model":        byModel,
+		"by_key":          byKey,
+		"top_reasons":     reasons,
+	})
+}
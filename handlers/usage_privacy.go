@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// usagePrivacyAuditedKey 上下文标记：本次请求的聚合模式绕过已在签发下载链接时审计
+const usagePrivacyAuditedKey = "usage_privacy_override_audited"
+
+// applyUsagePrivacyOverride 处理 privacy_override=true 查询参数：仅管理员可用且必须提供 reason，
+// 每次绕过聚合模式都写入审计日志。返回 false 时已写入错误响应
+func applyUsagePrivacyOverride(c *gin.Context, filter *database.UsageFilter) bool {
+	if c.Query("privacy_override") != "true" || !database.GetUsagePrivacy().AggregateOnly {
+		return true
+	}
+	if c.GetBool(usagePrivacyAuditedKey) {
+		filter.PrivacyOverride = true
+		return true
+	}
+
+	if c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+			"仅管理员可绕过聚合模式查看按用户的使用数据",
+			"permission_error",
+			"privacy_override_forbidden",
+		))
+		return false
+	}
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"绕过聚合模式需要提供 reason",
+			"validation_error",
+			"reason_required",
+		))
+		return false
+	}
+
+	var actorID *int64
+	if id, ok := c.Get("user_id"); ok {
+		if v, ok := id.(int64); ok && v > 0 {
+			actorID = &v
+		}
+	}
+	if err := database.CreateAuditLog(actorID, database.AuditActionUsagePrivacyOverride, "usage", c.FullPath(), gin.H{
+		"reason": reason,
+		"query":  c.Request.URL.RawQuery,
+	}); err != nil {
+		// 未能留下审计记录时不放行
+		logrus.WithError(err).Error("Failed to audit usage privacy override")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"服务器内部错误",
+			"internal_error",
+			"audit_failed",
+		))
+		return false
+	}
+	logrus.WithFields(logrus.Fields{
+		"path":   c.FullPath(),
+		"reason": reason,
+	}).Warn("Admin bypassed usage aggregation-only mode")
+
+	filter.PrivacyOverride = true
+	return true
+}
+
+// writeAggregateOnlyError 聚合模式下拒绝按用户的使用数据查询
+func writeAggregateOnlyError(c *gin.Context) {
+	c.JSON(http.StatusForbidden, models.NewErrorResponse(
+		"聚合模式下不提供按用户的使用数据",
+		"permission_error",
+		"aggregate_only",
+	))
+}
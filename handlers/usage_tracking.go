@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"Curry2API-go/database"
+	"Curry2API-go/middleware"
 	"Curry2API-go/models"
 	"Curry2API-go/services"
 	"Curry2API-go/utils"
@@ -12,43 +13,51 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// billingLog logs usage tracking and balance deduction after each request
+var billingLog = utils.SubsystemLogger(utils.LogSubsystemBilling)
+
 // trackUsageFromContext extracts context info and tracks usage
 // This function handles both successful and failed requests
 // It is safe to call even if tracking fails - errors are logged but don't affect the response
 func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int, errorMsg string) {
+	// 实际消耗的 token 计入处理该请求的提供商的 TPM 窗口
+	if usage != nil {
+		middleware.RecordProviderTokens(c.GetString("request_provider"), usage.TotalTokens)
+	}
+
 	// Extract request start time
 	requestStartTime, exists := c.Get("request_start_time")
 	if !exists {
-		logrus.Debug("request_start_time not found in context, skipping usage tracking")
+		billingLog.Debug("request_start_time not found in context, skipping usage tracking")
 		return
 	}
 	startTime, ok := requestStartTime.(time.Time)
 	if !ok {
-		logrus.Debug("invalid request_start_time type in context")
+		billingLog.Debug("invalid request_start_time type in context")
 		return
 	}
 	
 	// Extract model
 	requestModel, exists := c.Get("request_model")
 	if !exists {
-		logrus.Debug("request_model not found in context")
+		billingLog.Debug("request_model not found in context")
 		return
 	}
 	model, ok := requestModel.(string)
 	if !ok {
-		logrus.Debug("invalid request_model type in context")
+		billingLog.Debug("invalid request_model type in context")
 		return
 	}
 	
 	// Extract usage info
 	usageInfoRaw, exists := c.Get("usage_info")
 	if !exists {
-		logrus.Debug("usage_info not found in context, skipping usage tracking")
+		billingLog.Debug("usage_info not found in context, skipping usage tracking")
 		return
 	}
 	usageInfo, ok := usageInfoRaw.(*utils.UsageContextInfo)
 	if !ok {
-		logrus.Debug("invalid usage_info type in context")
+		billingLog.Debug("invalid usage_info type in context")
 		return
 	}
 	
@@ -69,12 +78,20 @@ func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int,
 	if sessionRaw, exists := c.Get("cursor_session"); exists {
 		if session, ok := sessionRaw.(string); ok {
 			cursorSession = session
-			logrus.WithField("cursor_session", cursorSession).Debug("Got cursor_session from context")
+			billingLog.WithField("cursor_session", cursorSession).Debug("Got cursor_session from context")
 		}
 	} else {
-		logrus.Debug("cursor_session not found in context")
+		billingLog.Debug("cursor_session not found in context")
 	}
 	
+	// 请求携带的采样种子一并记录，便于复现
+	var seed *int64
+	if value, exists := c.Get("request_seed"); exists {
+		if s, ok := value.(int64); ok {
+			seed = &s
+		}
+	}
+
 	// Track usage with the usage tracker service
 	tracker := services.GetUsageTracker()
 	record := &services.UsageRecord{
@@ -83,6 +100,7 @@ func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int,
 		APIToken:         usageInfo.APIToken,
 		TokenName:        usageInfo.TokenName,
 		Model:            model,
+		Provider:         c.GetString("request_provider"),
 		PromptTokens:     promptTokens,
 		CompletionTokens: completionTokens,
 		TotalTokens:      totalTokens,
@@ -92,17 +110,19 @@ func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int,
 		RequestTime:      startTime,
 		ResponseTime:     responseTime,
 		Duration:         duration,
+		Seed:             seed,
+		Language:         c.GetString("request_language"),
 	}
 	
 	if err := tracker.TrackUsage(record); err != nil {
-		logrus.WithError(err).Warn("Failed to track usage")
+		billingLog.WithError(err).Warn("Failed to track usage")
 	}
 	
 	// Update Cursor Session usage count and token quota asynchronously
 	if cursorSession != "" && cursorSession != "x-is-human-fallback" {
 		go func() {
 			success := statusCode >= 200 && statusCode < 300
-			logrus.WithFields(logrus.Fields{
+			billingLog.WithFields(logrus.Fields{
 				"cursor_session": cursorSession,
 				"status_code":    statusCode,
 				"success":        success,
@@ -111,18 +131,18 @@ func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int,
 			
 			// Update usage count (success/fail tracking)
 			if err := database.UpdateCursorSessionUsage(cursorSession, success); err != nil {
-				logrus.WithError(err).WithField("cursor_session", cursorSession).Warn("Failed to update cursor session usage count")
+				billingLog.WithError(err).WithField("cursor_session", cursorSession).Warn("Failed to update cursor session usage count")
 			}
 			
 			// Update daily token usage for successful requests
 			if success && totalTokens > 0 {
 				if err := database.UpdateSessionQuotaUsage(cursorSession, int64(totalTokens)); err != nil {
-					logrus.WithError(err).WithFields(logrus.Fields{
+					billingLog.WithError(err).WithFields(logrus.Fields{
 						"cursor_session": cursorSession,
 						"tokens":         totalTokens,
 					}).Warn("Failed to update cursor session daily_token_used")
 				} else {
-					logrus.WithFields(logrus.Fields{
+					billingLog.WithFields(logrus.Fields{
 						"cursor_session": cursorSession,
 						"tokens":         totalTokens,
 					}).Debug("Cursor session daily_token_used updated")
@@ -136,15 +156,22 @@ func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int,
 	if statusCode >= 200 && statusCode < 300 {
 		go func() {
 			if err := database.UpdateAPIKeyLastUsed(usageInfo.APIToken, responseTime); err != nil {
-				logrus.WithError(err).Debug("Failed to update API key last_used_at")
+				billingLog.WithError(err).Debug("Failed to update API key last_used_at")
 			}
 		}()
 	}
 
 	// Deduct balance for successful API calls with token usage
 	// Requirements: 2.2, 11.1, 11.2
-	if statusCode >= 200 && statusCode < 300 && totalTokens > 0 {
-		go deductBalanceForUsage(usageInfo.UserID, totalTokens, usageInfo.APIToken, model)
+	// 本地模型等未计费的提供商只记录用量，不扣费；按次计价的请求（如图片生成）通过 request_cost 指定费用
+	if statusCode >= 200 && statusCode < 300 && services.IsBillableModel(model) {
+		if cost, ok := c.Get("request_cost"); ok {
+			if amount, ok := cost.(float64); ok && amount > 0 {
+				go deductBalanceForCost(usageInfo.UserID, totalTokens, amount, usageInfo.APIToken, model)
+			}
+		} else if totalTokens > 0 {
+			go deductBalanceForUsage(usageInfo.UserID, totalTokens, usageInfo.APIToken, model)
+		}
 	}
 }
 
@@ -154,9 +181,18 @@ func trackUsageFromContext(c *gin.Context, usage *models.Usage, statusCode int,
 // Requirements: 12.2 - Update token quota_used after API call
 func deductBalanceForUsage(userID int64, tokens int, apiToken, model string) {
 	// Calculate cost: $1 = 1,000,000 tokens
-	cost := database.CalculateCost(tokens)
+	deductBalanceForCost(userID, tokens, database.CalculateCost(tokens), apiToken, model)
+}
+
+// deductBalanceForCost deducts a precomputed cost and updates the token's quota_used
+func deductBalanceForCost(userID int64, tokens int, cost float64, apiToken, model string) {
+	// Journal the billing event to disk while the database is down
+	if database.IsDegraded() {
+		journalBillingEvent(userID, tokens, apiToken, model, cost)
+		return
+	}
 
-	logrus.WithFields(logrus.Fields{
+	billingLog.WithFields(logrus.Fields{
 		"user_id":   userID,
 		"tokens":    tokens,
 		"cost":      cost,
@@ -167,30 +203,36 @@ func deductBalanceForUsage(userID int64, tokens int, apiToken, model string) {
 	// Update token quota_used
 	// Requirements: 12.2 - Track token's consumed amount separately
 	if err := database.UpdateTokenQuotaUsed(apiToken, cost); err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
+		billingLog.WithError(err).WithFields(logrus.Fields{
 			"api_token": apiToken,
 			"cost":      cost,
 		}).Warn("Failed to update token quota_used")
 	} else {
-		logrus.WithFields(logrus.Fields{
+		middleware.GetKeyManager().AddKeyQuotaUsed(apiToken, cost)
+		billingLog.WithFields(logrus.Fields{
 			"api_token": apiToken,
 			"cost":      cost,
 		}).Debug("Token quota_used updated")
 	}
 
 	// Deduct balance and create transaction record
-	transaction, err := database.DeductBalance(userID, tokens, apiToken, model)
+	transaction, err := database.DeductBalanceCost(userID, cost, tokens, apiToken, model)
 	if err != nil {
 		// Log error but don't fail - balance deduction failure shouldn't affect API response
 		if errors.Is(err, database.ErrBalanceNotFound) {
 			// User doesn't have a balance record yet - this is expected for users
 			// created before the balance system was implemented
-			logrus.WithFields(logrus.Fields{
+			billingLog.WithFields(logrus.Fields{
 				"user_id": userID,
 			}).Debug("User has no balance record, skipping balance deduction")
 			return
 		}
-		logrus.WithError(err).WithFields(logrus.Fields{
+		if database.IsConnectionError(err) {
+			database.ReportError(err)
+			journalBillingEvent(userID, tokens, apiToken, model, cost)
+			return
+		}
+		billingLog.WithError(err).WithFields(logrus.Fields{
 			"user_id": userID,
 			"tokens":  tokens,
 			"cost":    cost,
@@ -198,7 +240,7 @@ func deductBalanceForUsage(userID int64, tokens int, apiToken, model string) {
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{
+	billingLog.WithFields(logrus.Fields{
 		"user_id":       userID,
 		"tokens":        tokens,
 		"cost":          cost,
@@ -206,3 +248,54 @@ func deductBalanceForUsage(userID int64, tokens int, apiToken, model string) {
 		"transaction_id": transaction.ID,
 	}).Info("Balance deducted for API usage")
 }
+
+// journalBillingEvent writes a billing event to the on-disk journal for later replay
+func journalBillingEvent(userID int64, tokens int, apiToken, model string, cost float64) {
+	entry := &database.BillingJournalEntry{
+		UserID:    userID,
+		Tokens:    tokens,
+		APIToken:  apiToken,
+		Model:     model,
+		Cost:      cost,
+		CreatedAt: time.Now(),
+	}
+	if err := database.AppendBillingJournal(entry); err != nil {
+		billingLog.WithError(err).WithFields(logrus.Fields{
+			"user_id": userID,
+			"tokens":  tokens,
+			"cost":    cost,
+		}).Error("Failed to journal billing event, usage will not be charged")
+		return
+	}
+	billingLog.WithFields(logrus.Fields{
+		"user_id": userID,
+		"tokens":  tokens,
+		"cost":    cost,
+	}).Info("Database unavailable, billing event journaled for replay")
+}
+
+// ReplayBillingJournal applies billing events journaled while the database was down
+// It is registered as a database recovery hook and also run once at startup
+func ReplayBillingJournal() {
+	replayed, err := database.ReplayBillingJournal(func(entry *database.BillingJournalEntry) error {
+		if _, err := database.DeductBalanceCost(entry.UserID, entry.Cost, entry.Tokens, entry.APIToken, entry.Model); err != nil {
+			if errors.Is(err, database.ErrBalanceNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := database.UpdateTokenQuotaUsed(entry.APIToken, entry.Cost); err != nil {
+			billingLog.WithError(err).Warn("Failed to update token quota_used during billing replay")
+		} else {
+			middleware.GetKeyManager().AddKeyQuotaUsed(entry.APIToken, entry.Cost)
+		}
+		return nil
+	})
+	if err != nil {
+		billingLog.WithError(err).Error("Failed to replay billing journal")
+		return
+	}
+	if replayed > 0 {
+		billingLog.Infof("Replayed %d journaled billing events", replayed)
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxKeysPerUser 每个用户可自助创建的密钥数量上限
+const maxKeysPerUser = 50
+
+// CreateOwnKeyRequest 用户创建密钥请求，密钥由服务端生成
+type CreateOwnKeyRequest struct {
+	TokenName     string   `json:"token_name"`
+	QuotaLimit    *float64 `json:"quota_limit"`    // 额度上限（美元），不传表示不限
+	ExpiresAt     *string  `json:"expires_at"`     // RFC3339 时间，不传表示永不过期
+	AllowedModels []string `json:"allowed_models"` // 模型白名单，不传表示可调用全部模型
+}
+
+// ownKey 返回当前会话用户自己的密钥，不存在或属于其他用户时写入 404 并返回 false（管理员也只能操作自己的密钥）
+func ownKey(c *gin.Context) (string, *middleware.KeyInfo, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return "", nil, false // Error response already sent
+	}
+	key := c.Param("key")
+	info, exists := middleware.GetKeyManager().GetKeyInfo(key)
+	if !exists || info.UserID == nil || *info.UserID != userID {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			middleware.ErrKeyNotFound.Message,
+			"not_found",
+			middleware.ErrKeyNotFound.Code,
+		))
+		return "", nil, false
+	}
+	return key, info, true
+}
+
+// writeOwnKeyError 将 KeyManager 的错误写入响应
+func writeOwnKeyError(c *gin.Context, err error, code string) {
+	if keyErr, ok := err.(*middleware.KeyError); ok {
+		statusCode := http.StatusBadRequest
+		if keyErr.Code == "key_not_found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, models.NewErrorResponse(keyErr.Message, "validation_error", keyErr.Code))
+		return
+	}
+	logrus.WithError(err).Error("Failed to manage user API key")
+	c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", code))
+}
+
+// ListOwnKeys 列出当前用户自己的密钥，支持与 /admin/keys 相同的搜索、筛选、排序与分页参数
+// GET /api/keys
+func (h *Handler) ListOwnKeys(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	total, entries, ok := buildKeyDirectory(c, middleware.GetKeyManager().ListKeysByUser(userID))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": total,
+		"keys":  entries,
+	})
+}
+
+// CreateOwnKey 为当前用户生成新密钥，可设置名称、额度上限、过期时间与模型白名单
+// POST /api/keys
+func (h *Handler) CreateOwnKey(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	var req CreateOwnKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的请求格式", "validation_error", "invalid_request"))
+		return
+	}
+	req.TokenName = strings.TrimSpace(req.TokenName)
+	if len(req.TokenName) > 255 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("名称长度不能超过255个字符", "validation_error", "name_too_long"))
+		return
+	}
+	if req.QuotaLimit != nil && *req.QuotaLimit <= 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("quota_limit 必须大于 0", "validation_error", "invalid_quota_limit"))
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil || !parsed.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"expires_at 必须为晚于当前时间的 RFC3339 时间",
+				"validation_error",
+				"invalid_expires_at",
+			))
+			return
+		}
+		expiresAt = &parsed
+	}
+	for _, model := range req.AllowedModels {
+		if !h.config.IsValidModel(model) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("未知的模型："+model, "validation_error", "model_not_found"))
+			return
+		}
+	}
+
+	km := middleware.GetKeyManager()
+	if len(km.ListKeysByUser(userID)) >= maxKeysPerUser {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"密钥数量已达上限，请先删除不再使用的密钥",
+			"validation_error",
+			"key_limit_reached",
+		))
+		return
+	}
+
+	key, err := services.GenerateAPIKey()
+	if err == nil {
+		err = database.AddAPIKeyWithOptions(key, &userID, req.TokenName, &database.APIKeyOptions{
+			QuotaLimit:    req.QuotaLimit,
+			ExpiresAt:     expiresAt,
+			AllowedModels: req.AllowedModels,
+		})
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create user API key")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("服务器内部错误", "internal_error", "create_key_failed"))
+		return
+	}
+	if err := km.ReloadKeys(); err != nil {
+		logrus.WithError(err).Warn("Failed to reload keys after creating user key")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"key":     maskKey(key),
+	}).Info("User created API key")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "密钥创建成功",
+		"key":            key,
+		"masked_key":     maskKey(key),
+		"token_name":     req.TokenName,
+		"quota_limit":    req.QuotaLimit,
+		"expires_at":     expiresAt,
+		"allowed_models": req.AllowedModels,
+	})
+}
+
+// RenameOwnKey 修改自己密钥的名称
+// PUT /api/keys/:key/name
+func (h *Handler) RenameOwnKey(c *gin.Context) {
+	key, _, ok := ownKey(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateKeyNameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("无效的请求格式", "validation_error", "invalid_request"))
+		return
+	}
+	if len(req.Name) > 255 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("名称长度不能超过255个字符", "validation_error", "name_too_long"))
+		return
+	}
+
+	if err := middleware.GetKeyManager().UpdateKeyName(key, req.Name); err != nil {
+		writeOwnKeyError(c, err, "update_key_name_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥名称更新成功",
+		"key":     maskKey(key),
+		"name":    req.Name,
+	})
+}
+
+// ToggleOwnKey 启用或禁用自己的密钥
+// PUT /api/keys/:key/toggle
+func (h *Handler) ToggleOwnKey(c *gin.Context) {
+	key, info, ok := ownKey(c)
+	if !ok {
+		return
+	}
+
+	if err := middleware.GetKeyManager().ToggleKeyStatus(key); err != nil {
+		writeOwnKeyError(c, err, "toggle_key_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "密钥状态切换成功",
+		"key":       maskKey(key),
+		"is_active": !info.IsActive,
+	})
+}
+
+// DeleteOwnKey 删除自己的密钥
+// DELETE /api/keys/:key
+func (h *Handler) DeleteOwnKey(c *gin.Context) {
+	key, _, ok := ownKey(c)
+	if !ok {
+		return
+	}
+
+	if err := middleware.GetKeyManager().RemoveKey(key); err != nil {
+		writeOwnKeyError(c, err, "remove_key_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥删除成功",
+		"key":     maskKey(key),
+	})
+}
+
+// UpdateOwnKeyAllowedIPs 设置自己密钥的 IP 白名单，请求与响应同 /admin/keys/:key/allowed-ips
+// PUT /api/keys/:key/allowed-ips
+func (h *Handler) UpdateOwnKeyAllowedIPs(c *gin.Context) {
+	if _, _, ok := ownKey(c); !ok {
+		return
+	}
+	UpdateKeyAllowedIPsHandler(c)
+}
+
+// GetOwnKeyUsage 查看自己密钥的用量、额度与访问限制，响应与 /admin/keys/:key/details 相同，?days=N 只统计最近 N 天
+// GET /api/keys/:key/usage
+func (h *Handler) GetOwnKeyUsage(c *gin.Context) {
+	if _, _, ok := ownKey(c); !ok {
+		return
+	}
+	GetKeyDetailsHandler(c)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateUserWebhookRequest 设置任务完成通知 Webhook；未提供的字段保持不变
+type UpdateUserWebhookRequest struct {
+	URL           *string `json:"url"`
+	Enabled       *bool   `json:"enabled"`
+	EmailFallback *bool   `json:"email_fallback"`
+	RotateSecret  bool    `json:"rotate_secret"` // 重新生成签名密钥
+}
+
+// userWebhookLoadFailed 写入读取 Webhook 配置失败的响应
+func userWebhookLoadFailed(c *gin.Context, userID int64, err error) {
+	logrus.WithError(err).WithField("user_id", userID).Error("Failed to get user webhook")
+	c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+		"获取 Webhook 配置失败",
+		"internal_error",
+		"database_error",
+	))
+}
+
+// GetUserWebhookHandler returns the current user's job notification webhook (the secret is
+// only shown when it is created or rotated)
+// GET /profile/webhook
+func GetUserWebhookHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	webhook, err := database.GetUserWebhook(userID)
+	if err != nil {
+		userWebhookLoadFailed(c, userID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+// UpdateUserWebhookHandler creates or updates the current user's job notification webhook.
+// A signing secret is generated on creation and when rotate_secret is set, and returned once
+// PUT /profile/webhook
+func UpdateUserWebhookHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	var req UpdateUserWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"请求格式错误",
+			"validation_error",
+			"invalid_request",
+		))
+		return
+	}
+
+	webhook, err := database.GetUserWebhook(userID)
+	if err != nil {
+		userWebhookLoadFailed(c, userID, err)
+		return
+	}
+	if webhook == nil {
+		if req.URL == nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"请提供 Webhook 地址",
+				"validation_error",
+				"missing_url",
+			))
+			return
+		}
+		webhook = &database.UserWebhook{UserID: userID, Enabled: true, EmailFallback: true}
+		req.RotateSecret = true
+	}
+
+	if req.URL != nil {
+		url := strings.TrimSpace(*req.URL)
+		if len(url) > 500 || services.ValidateUserWebhookURL(url) != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Webhook 地址必须是 http 或 https 开头的完整 URL，且不能包含账号密码",
+				"validation_error",
+				"invalid_url",
+			))
+			return
+		}
+		webhook.URL = url
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	if req.EmailFallback != nil {
+		webhook.EmailFallback = *req.EmailFallback
+	}
+
+	response := gin.H{}
+	if req.RotateSecret {
+		secret, err := services.NewUserWebhookSecret()
+		if err != nil {
+			logrus.WithError(err).Error("Failed to generate webhook secret")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"生成签名密钥失败",
+				"internal_error",
+				"secret_generation_failed",
+			))
+			return
+		}
+		webhook.Secret = secret
+		response["secret"] = secret
+	}
+
+	if err := database.SaveUserWebhook(webhook); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to save user webhook")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"保存 Webhook 配置失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	if webhook, err = database.GetUserWebhook(userID); err != nil {
+		userWebhookLoadFailed(c, userID, err)
+		return
+	}
+	response["webhook"] = webhook
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteUserWebhookHandler removes the current user's webhook; notifications go back to email
+// DELETE /profile/webhook
+func DeleteUserWebhookHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	if err := database.DeleteUserWebhook(userID); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to delete user webhook")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"删除 Webhook 配置失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook 已删除"})
+}
+
+// TestUserWebhookHandler sends a signed webhook.test event to the current user's webhook and
+// reports the outcome
+// POST /profile/webhook/test
+func TestUserWebhookHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	notifier := services.GetUserWebhookNotifier()
+	if notifier == nil {
+		c.JSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+			"Webhook 通知服务未启用",
+			"service_unavailable",
+			"webhooks_disabled",
+		))
+		return
+	}
+	webhook, err := database.GetUserWebhook(userID)
+	if err != nil {
+		userWebhookLoadFailed(c, userID, err)
+		return
+	}
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(
+			"尚未配置 Webhook",
+			"not_found",
+			"webhook_not_found",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, notifier.SendTest(webhook))
+}
+
+// ListWebhookDeliveriesHandler lists the current user's recent webhook deliveries (?limit=N, at most 100)
+// GET /profile/webhook/deliveries
+func ListWebhookDeliveriesHandler(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return // Error response already sent
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	deliveries, err := database.ListWebhookDeliveries(userID, limit)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to list webhook deliveries")
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"获取投递记录失败",
+			"internal_error",
+			"database_error",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
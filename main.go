@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"Curry2API-go/config"
 	"Curry2API-go/database"
 	"Curry2API-go/handlers"
@@ -20,12 +22,20 @@ import (
 )
 
 func main() {
+	checkMode := flag.Bool("check", false, "validate configuration and exit (non-zero exit code on failure)")
+	flag.Parse()
+
 	// 加载配置
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		logrus.Fatalf("Failed to load config: %v", err)
 	}
 
+	// 自检模式：输出配置检查报告后退出，不启动服务
+	if *checkMode {
+		os.Exit(runConfigCheck(cfg))
+	}
+
 	// 初始化数据库
 	if err := database.Init(cfg); err != nil {
 		logrus.Fatalf("Failed to initialize database: %v", err)
@@ -36,14 +46,26 @@ func main() {
 	}
 	defer db.Close()
 
+	// 数据库健康检查：连接中断时进入只读降级模式，恢复后重放计费日志
+	database.SetBillingJournalPath(cfg.BillingJournalPath)
+	database.OnRecover(handlers.ReplayBillingJournal)
+	database.StartHealthMonitor(
+		time.Duration(cfg.DBHealthCheckInterval)*time.Second,
+		time.Duration(cfg.DBDegradedWindow)*time.Second,
+	)
+	handlers.ReplayBillingJournal()
+
 	// 环境变量迁移（仅首次）
 	if err := database.MigrateFromEnv(); err != nil {
 		logrus.Warnf("Failed to migrate from env: %v", err)
 	}
 
-	// 设置日志级别
+	// 应用通过配置包导入的设置（模型列表、别名、限流等）
+	services.ApplyStoredConfig(cfg)
+
+	// 设置日志级别：LOG_LEVEL 为默认级别，LOG_LEVELS 与 /admin/logging 按子系统覆盖
+	handlers.ApplyLogLevels(cfg)
 	if cfg.Debug {
-		logrus.SetLevel(logrus.DebugLevel)
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
@@ -51,13 +73,19 @@ func main() {
 
 	// 创建路由器
 	router := gin.New()
+	// 只信任配置的反向代理转发的客户端 IP，避免伪造 X-Forwarded-For 绕过密钥 IP 白名单、
+	// 试用密钥的 IP 绑定与按 IP 限流；未配置时不信任任何代理（gin 默认信任所有来源）
+	if err := router.SetTrustedProxies(cfg.GetTrustedProxies()); err != nil {
+		logrus.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// 添加中间件
-	router.Use(gin.Logger())
+	router.Use(middleware.AccessLog())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 	router.Use(middleware.ErrorHandler())
 	router.Use(middleware.RateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst))
+	router.Use(middleware.DegradedGuard())
 	
 	// 添加缓存控制中间件（防止API响应被缓存）
 	router.Use(func(c *gin.Context) {
@@ -108,6 +136,13 @@ func main() {
 		logrus.Fatalf("Failed to initialize data crypto: %v", err)
 	}
 
+	// 应用声明式种子文件（管理员、自定义上游、模型别名、定价、功能开关；仅创建缺失项）
+	if cfg.SeedFile != "" {
+		if _, err := services.ApplySeedFile(cfg, cfg.SeedFile, cfg.SeedForce); err != nil {
+			logrus.Fatalf("Failed to apply seed file: %v", err)
+		}
+	}
+
 	// 初始化 OAuth 服务
 	oauthConfig, err := services.LoadOAuthConfig()
 	if err != nil {
@@ -142,6 +177,62 @@ func main() {
 	}
 	cleanupService := services.InitUsageCleanupService(cleanupConfig)
 	cleanupService.Start()
+
+	// 隐私模式：管理后台使用统计只返回满足最小人数的聚合数据
+	database.ConfigureUsagePrivacy(cfg.UsageTracking.AggregateOnly, cfg.UsageTracking.MinGroupSize)
+
+	// 定期清理过期会话、已用/过期验证码与过期 OAuth state（结果见 /admin/jobs）
+	vacuumService := services.NewVacuumService(time.Duration(cfg.VacuumInterval) * time.Second)
+	vacuumService.Start()
+
+	// 长期未活动对话的消息内容压缩归档（打开对话时透明解压，结果见 /admin/jobs）
+	chatArchiveService := services.InitChatArchiveService(time.Duration(cfg.ChatArchiveDays) * 24 * time.Hour)
+	chatArchiveService.Start()
+
+	// 历史 usage_records 中的明文 API 密钥分批替换为指纹（可断点续跑，进度见 /admin/jobs）
+	services.StartUsageTokenMigration()
+
+	// 延迟预算监控：按窗口评估 p95 TTFT 等指标，连续超标时告警
+	latencyMonitor := services.InitLatencySLOMonitor(
+		time.Duration(cfg.LatencySLO.WindowSeconds)*time.Second,
+		cfg.LatencySLO.WebhookURL,
+	)
+	latencyMonitor.Start()
+
+	// 提供商月度消费上限：按用量与上游价格估算当月消费，接近上限时告警，达到上限后停止路由
+	spendMonitor := services.InitProviderSpendMonitor(
+		time.Duration(cfg.ProviderSpend.CheckInterval)*time.Second,
+		cfg.ProviderSpend.WebhookURL,
+	)
+	spendMonitor.Start()
+
+	// 签名令牌服务：下载链接、分享链接与流续传令牌的签发、校验与吊销
+	signedTokens, err := services.InitSignedTokenService(cfg.TokenSigningSecret)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to initialize signed token service, signed links disabled")
+	} else {
+		signedTokens.Start()
+	}
+
+	// JWT 认证模式：控制台登录改用访问令牌 + 刷新令牌，多实例部署无需会话粘滞
+	if cfg.AuthMode == middleware.AuthModeJWT {
+		if err := middleware.InitJWTAuth(cfg.JWTSecret,
+			time.Duration(cfg.JWTAccessTTL)*time.Second,
+			time.Duration(cfg.JWTRefreshTTL)*time.Second,
+		); err != nil {
+			logrus.Fatalf("Failed to initialize JWT auth: %v", err)
+		}
+		logrus.Info("Auth mode: jwt")
+	}
+
+	// 每日运营摘要：按管理员配置推送到 Slack / 飞书 / 钉钉
+	opsSummaryReporter := services.InitOpsSummaryReporter()
+	opsSummaryReporter.Start()
+
+	// 匿名试用：配置未指定模型时可试用全部免费模型；定期清理过期的临时密钥
+	middleware.SetTrialDefaultModels(services.GetOpenRouterFreeModels())
+	trialKeyCleaner := services.NewTrialKeyCleaner()
+	trialKeyCleaner.Start()
 	var oauthService *services.OAuthService
 	var oauthHandler *handlers.OAuthHandler
 	if oauthConfig != nil {
@@ -156,11 +247,15 @@ func main() {
 		oauthService = services.NewOAuthService(oauthConfig)
 		oauthHandler = handlers.NewOAuthHandler(oauthService)
 		
-		// 启动定期清理过期state的任务
-		oauthService.StartStateCleanupTask()
+		// 过期 state 由 vacuum 任务定期清理
 		logrus.Info("OAuth service initialized successfully")
 	}
 
+	// 加载模型定价（计费与公开价格页共用同一数据源）
+	if err := services.LoadPricingFromDB(); err != nil {
+		logrus.Warnf("Failed to load model pricing from database, using built-in defaults: %v", err)
+	}
+
 	// 创建处理器
 	handler := handlers.NewHandler(cfg)
 
@@ -174,6 +269,29 @@ func main() {
 	// Register Cursor provider as fallback
 	cursorProvider := services.NewCursorProvider(cursorService)
 	providerRouter.RegisterProvider("cursor", cursorProvider)
+	handler.SetProviderRouter(providerRouter)
+
+	// 定期从 OpenRouter 同步免费模型列表（未配置 OPENROUTER_API_KEY 时不启动）
+	providerRouter.StartOpenRouterModelSync(time.Duration(cfg.Providers.OpenRouter.ModelSyncInterval) * time.Second)
+
+	// 加载管理员注册的自定义上游
+	if err := providerRouter.ReloadCustomProviders(); err != nil {
+		logrus.WithError(err).Warn("Failed to load custom providers")
+	}
+
+	// 本地 Ollama 模型：定期刷新已安装模型列表，默认只记录用量不扣费
+	providerRouter.StartOllamaModelSync(time.Duration(cfg.Providers.Ollama.ModelSyncInterval) * time.Second)
+	services.SetProviderBilling("ollama", cfg.Providers.Ollama.Billing)
+
+	// 同一模型有多个直连提供商时按管理员配置的策略分配流量（默认按优先级）
+	if balancing, err := database.GetProviderBalancingConfig(); err != nil {
+		logrus.WithError(err).Warn("Failed to load provider load balancing config")
+	} else if balancing != nil {
+		providerRouter.SetLoadBalancing(balancing)
+	}
+
+	// 提供商健康探测：连续失败的直连提供商熔断一段时间，请求回落到 Cursor
+	providerRouter.StartHealthProbe(time.Duration(cfg.ProviderHealth.ProbeInterval) * time.Second)
 	
 	// Log available providers on startup
 	availableProviders := providerRouter.GetAvailableProviders()
@@ -186,8 +304,24 @@ func main() {
 	chatService := services.NewChatServiceWithRouter(cursorService, providerRouter, cfg)
 	chatHandler := handlers.NewChatHandlerWithRouter(chatService, providerRouter, cfg)
 
+	// 文件（/v1/files）：元数据存 MySQL，内容存磁盘或 S3
+	if _, err := services.InitFileService(cfg); err != nil {
+		logrus.Fatalf("Failed to initialize file storage: %v", err)
+	}
+
+	// 任务完成通知：批处理完成后向用户的 Webhook 投递签名通知（失败按退避重试），未配置时发邮件
+	webhookNotifier := services.InitUserWebhookNotifier(cfg)
+	webhookNotifier.Start()
+
+	// 批处理（/v1/batches）：后台工作池经多提供商路由处理请求，启动时恢复未完成的批处理
+	var batchProcessor *services.BatchProcessor
+	if cfg.BatchWorkers > 0 {
+		batchProcessor = services.InitBatchProcessor(cfg, providerRouter, cfg.BatchWorkers, cfg.BatchMaxRequests, handlers.DeductBatchUsage)
+		batchProcessor.Start()
+	}
+
 	// 注册路由
-	setupRoutes(router, handler, cfg, oauthHandler, chatHandler)
+	setupRoutes(router, handler, cfg, oauthHandler, chatHandler, providerRouter)
 
 	// 创建HTTP服务器
 	server := &http.Server{
@@ -211,6 +345,19 @@ func main() {
 
 	// 停止清理服务
 	cleanupService.Stop()
+	vacuumService.Stop()
+	chatArchiveService.Stop()
+	latencyMonitor.Stop()
+	spendMonitor.Stop()
+	opsSummaryReporter.Stop()
+	trialKeyCleaner.Stop()
+	if signedTokens != nil {
+		signedTokens.Stop()
+	}
+	if batchProcessor != nil {
+		batchProcessor.Stop()
+	}
+	webhookNotifier.Stop()
 
 	// 给服务器5秒时间完成处理正在进行的请求
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -222,7 +369,7 @@ func main() {
 	logrus.Info("Server exited")
 }
 
-func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Config, oauthHandler *handlers.OAuthHandler, chatHandler *handlers.ChatHandler) {
+func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Config, oauthHandler *handlers.OAuthHandler, chatHandler *handlers.ChatHandler, providerRouter *services.ProviderRouter) {
 	// 健康检查（公开访问）
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -231,6 +378,23 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		})
 	})
 
+	// 公开定价（无需认证）
+	router.GET("/api/public/pricing", handlers.GetPublicPricingHandler)
+
+	// API 变更日志（无需认证），X-CurryAPI-Notice 响应头中的 ID 指向这里
+	router.GET("/api/changelog", handlers.ListChangelogHandler)
+	router.GET("/api/changelog/:id", handlers.GetChangelogEntryHandler)
+
+	// 服务条款：公开查看，登录用户接受
+	router.GET("/api/downloads/usage-export", handlers.DownloadUsageExport) // 通过一次性签名链接下载使用数据
+	router.GET("/api/shared/:token", handlers.GetSharedConversation)        // 通过分享链接查看会话（无需登录）
+	router.GET("/api/terms", handlers.GetTermsHandler)                                             // 获取当前服务条款
+	router.POST("/api/terms/accept", middleware.SessionAuth(), handlers.AcceptTermsHandler) // 接受当前服务条款
+
+	// 匿名试用：访客通过人机验证后获取绑定 IP 的临时密钥，在 Playground 试用免费模型
+	router.GET("/api/trial", handlers.GetTrialInfoHandler)       // 获取试用开关与限制
+	router.POST("/api/trial/key", handlers.IssueTrialKeyHandler) // 申请临时密钥
+
 	// 认证路由组（公开访问）
 	auth := router.Group("/auth")
 	{
@@ -238,7 +402,19 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		auth.POST("/register", handlers.RegisterHandler)               // 用户注册（需要验证码）
 		auth.POST("/login", handlers.LoginHandler)                     // 用户登录
 		auth.POST("/logout", handlers.LogoutHandler)                   // 用户登出
+		auth.POST("/forgot-password", handlers.ForgotPasswordHandler)  // 发送找回密码验证码（按邮箱与 IP 限频）
+		auth.POST("/reset-password", handlers.ResetPasswordHandler)    // 验证码重置密码并注销全部会话
 		auth.GET("/me", middleware.SessionAuth(), handlers.GetCurrentUserHandler) // 获取当前用户信息
+		auth.POST("/sudo", middleware.SessionAuth(), handlers.EnterSudoHandler)     // 重新输入密码进入 sudo 模式（10 分钟）
+		auth.GET("/sudo", middleware.SessionAuth(), handlers.GetSudoStatusHandler)  // 查询 sudo 模式状态
+		auth.DELETE("/sudo", middleware.SessionAuth(), handlers.ExitSudoHandler)    // 提前退出 sudo 模式
+		auth.POST("/login/2fa", handlers.LoginTwoFactorHandler)                                         // 登录第二步：提交两步验证码或备用码
+		auth.GET("/2fa", middleware.SessionAuth(), handlers.GetTwoFactorStatusHandler)                  // 查询两步验证状态
+		auth.POST("/2fa/setup", middleware.SessionAuth(), handlers.SetupTwoFactorHandler)               // 生成 TOTP 密钥与 otpauth:// URI
+		auth.POST("/2fa/enable", middleware.SessionAuth(), handlers.EnableTwoFactorHandler)             // 提交验证码启用两步验证，返回备用码
+		auth.POST("/2fa/disable", middleware.SessionAuth(), handlers.DisableTwoFactorHandler)           // 验证密码与验证码后关闭两步验证
+		auth.POST("/2fa/backup-codes", middleware.SessionAuth(), handlers.RegenerateBackupCodesHandler) // 重新生成备用码
+		auth.POST("/token/refresh", handlers.RefreshTokenHandler)                                       // JWT 模式：轮换刷新令牌，换取新的访问令牌
 	}
 	
 	// OAuth 路由组（公开访问）
@@ -247,6 +423,7 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		{
 			oauthGroup := api.Group("/auth")
 			{
+				oauthGroup.GET("/providers", oauthHandler.ListOAuthProviders)          // 已配置的第三方登录提供商
 				oauthGroup.GET("/:provider/login", oauthHandler.InitiateOAuthLogin)    // 发起OAuth登录
 				oauthGroup.GET("/:provider/callback", oauthHandler.OAuthCallback)      // OAuth回调
 			}
@@ -258,6 +435,20 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 	{
 		profile.PUT("/username", handlers.UpdateUsernameHandler) // 更新用户名
 		profile.PUT("/password", handlers.UpdatePasswordHandler) // 更新密码
+		profile.PUT("/currency", handlers.UpdateDisplayCurrencyHandler) // 更新显示货币
+		profile.GET("/tax-info", handlers.GetTaxInfoHandler)            // 获取税务信息
+		profile.PUT("/tax-info", handlers.UpdateTaxInfoHandler)         // 更新税务信息
+		profile.GET("/spend-guard", handlers.GetSpendGuardHandler)      // 获取自设消费上限及本日/本周消费
+		profile.PUT("/spend-guard", handlers.UpdateSpendGuardHandler)   // 设置消费上限（放宽需等待冷却期）
+		profile.GET("/sessions", handlers.ListSessionsHandler)          // 获取登录设备（会话）列表
+		profile.DELETE("/sessions/:id", handlers.RevokeSessionHandler)  // 退出其他设备上的会话
+		profile.DELETE("/sessions", handlers.RevokeAllSessionsHandler)  // 退出所有其他设备（include_current=true 时包括当前设备）
+		profile.GET("/webhook", handlers.GetUserWebhookHandler)          // 获取任务完成通知 Webhook
+		profile.PUT("/webhook", handlers.UpdateUserWebhookHandler)       // 设置 Webhook（创建或轮换时返回签名密钥）
+		profile.POST("/keys/:key/rotate", handlers.RotateOwnKeyHandler)  // 轮换自己的密钥（可设宽限期）
+		profile.DELETE("/webhook", handlers.DeleteUserWebhookHandler)    // 删除 Webhook，改为邮件通知
+		profile.POST("/webhook/test", handlers.TestUserWebhookHandler)   // 发送测试通知
+		profile.GET("/webhook/deliveries", handlers.ListWebhookDeliveriesHandler) // 最近的投递记录
 	}
 
 	// API文档页面（需要会话认证）
@@ -265,24 +456,139 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 
 	// 创建 Claude Handler 实例
 	claudeHandler := handlers.NewClaudeHandler(cfg)
+	claudeHandler.SetProviderRouter(providerRouter)
+
+	// QoS 调度：全局并发上限 + 提供商并发上限与 RPM/TPM 限额（受信任密钥的 X-Priority: high 优先获得槽位）
+	queueTimeout := time.Duration(cfg.QoS.QueueTimeout) * time.Second
+	middleware.ConfigureProviderLimits(cfg.QoS.ProviderLimits, queueTimeout)
+	middleware.ConfigureProviderRateLimits(cfg.QoS.ProviderRPM, cfg.QoS.ProviderTPM, time.Duration(cfg.QoS.RateLimitWait)*time.Second)
+	qos := middleware.QoS(cfg.QoS.MaxConcurrent, queueTimeout)
+
+	// 同一会话（X-Conversation-ID）的连续请求复用同一个 Cursor session
+	middleware.ConfigureSessionAffinity(time.Duration(cfg.SessionAffinityTTL) * time.Second)
+
+	// SSE 输出合并默认值（可按密钥覆盖）
+	middleware.ConfigureStreamFlush(cfg.StreamFlushIntervalMs, cfg.StreamFlushBytes)
+
+	// 流式响应空闲时的心跳间隔
+	utils.ConfigureSSEKeepAlive(time.Duration(cfg.SSEKeepAliveInterval) * time.Second)
+
+	// 模型请求超时：环境变量提供默认值，管理员保存的配置优先
+	timeouts := &database.RequestTimeoutConfig{
+		Default: cfg.RequestTimeout,
+		Min:     cfg.RequestTimeoutMin,
+		Max:     cfg.RequestTimeoutMax,
+		Models:  middleware.ParseModelTimeouts(cfg.ModelTimeouts),
+	}
+	if stored, err := database.GetRequestTimeoutConfig(); err != nil {
+		logrus.WithError(err).Warn("Failed to load request timeout config")
+	} else if stored != nil {
+		timeouts = stored
+	}
+	if err := middleware.ValidateRequestTimeoutConfig(timeouts); err != nil {
+		logrus.WithError(err).Warn("Invalid request timeout config, using defaults")
+	} else {
+		middleware.ConfigureRequestTimeouts(timeouts)
+	}
+
+	// 相同的非流式请求（模型、消息与参数均相同）直接返回缓存响应，配置 Redis 时多实例共享
+	if cfg.ResponseCache.Enabled {
+		if err := middleware.ConfigureResponseCache(
+			time.Duration(cfg.ResponseCache.TTL)*time.Second,
+			cfg.ResponseCache.MaxEntries,
+			cfg.ResponseCache.MaxBytes,
+			cfg.ResponseCache.RedisURL,
+		); err != nil {
+			logrus.WithError(err).Warn("Failed to connect response cache to Redis, caching in memory only")
+		}
+	}
+	responseCache := middleware.ResponseCache()
+
+	// 余额不足（402）响应中的充值链接
+	middleware.ConfigureRechargeURL(cfg.RechargeURL, cfg.PublicBaseURL)
+
+	// 服务条款：加载当前版本，可选要求密钥所属用户接受后才能调用 API
+	middleware.ConfigureTermsEnforcement(cfg.TOSEnforceAPI)
+	middleware.LoadCurrentTermsVersion()
+
+	// 两步验证：加载是否要求管理员账号启用两步验证
+	middleware.LoadTwoFactorPolicy()
+
+	// 提示词语言检测：记录到用量，供路由规则与本地审核规则按语言匹配
+	middleware.ConfigureLanguageDetection(cfg.LanguageDetection)
+
+	// 记录首字节时间与总耗时，供延迟预算评估
+	latency := middleware.LatencyRecorder()
+
+	// 按模型（或 X-Request-Timeout 请求头）设置请求截止时间，传递到排队与提供商调用
+	timeout := middleware.RequestTimeout()
+
+	// 本地审核规则：/v1/moderations 无上游审核模型时使用
+	if err := services.LoadModerationRules(); err != nil {
+		logrus.WithError(err).Warn("Failed to load moderation rules")
+	}
+
+	// 管理员配置的路由规则：按用户、密钥标签、模型、时间段与请求大小匹配，放在 QoS 之前以便设置优先级
+	if err := middleware.ReloadRoutingRules(); err != nil {
+		logrus.WithError(err).Warn("Failed to load routing rules")
+	}
+
+	// API 变更日志：提示期内的条目通过 X-CurryAPI-Notice 响应头提示调用方
+	if err := middleware.ReloadAPINotices(); err != nil {
+		logrus.WithError(err).Warn("Failed to load API changelog notices")
+	}
 
 	// API v1路由组
-	v1 := router.Group("/v1")
+	v1 := router.Group("/v1", middleware.APINotices())
 	{
 		// 模型列表
 		v1.GET("/models", middleware.AuthRequired(), handler.ListModels)
 
 		// OpenAI 聊天完成端点
-		v1.POST("/chat/completions", middleware.AuthRequired(), handler.ChatCompletions)
+		v1.POST("/chat/completions", latency, middleware.AuthRequired(), timeout, middleware.LanguageDetection(), middleware.RoutingRules(false), responseCache, qos, handler.ChatCompletions)
 
 		// Claude Messages API 端点
-		v1.POST("/messages", middleware.AuthRequired(), claudeHandler.ClaudeMessages)
+		v1.POST("/messages", latency, middleware.AuthRequired(), timeout, middleware.LanguageDetection(), middleware.RoutingRules(true), responseCache, qos, claudeHandler.ClaudeMessages)
 		v1.POST("/messages/count_tokens", middleware.AuthRequired(), claudeHandler.CountTokens)
+
+		// OpenAI 向量端点（路由到支持 embeddings 的提供商）
+		v1.POST("/embeddings", latency, middleware.AuthRequired(), timeout, responseCache, qos, handler.Embeddings)
+
+		// OpenAI 图片生成端点（DALL·E / gpt-image，按张计费）
+		v1.POST("/images/generations", latency, middleware.AuthRequired(), timeout, qos, handler.ImageGenerations)
+
+		// OpenAI 语音转文字端点（Whisper 兼容的 multipart 上传，按音频秒数计费）
+		v1.POST("/audio/transcriptions", latency, middleware.AuthRequired(), timeout, qos, handler.AudioTranscriptions)
+
+		// OpenAI 文本转语音端点（按输入字符计费）
+		v1.POST("/audio/speech", latency, middleware.AuthRequired(), timeout, qos, handler.AudioSpeech)
+
+		// OpenAI 内容审核端点（无上游审核模型时使用本地规则，不计费）
+		v1.POST("/moderations", latency, middleware.AuthRequired(), timeout, qos, handler.Moderations)
+
+		// 批处理端点：提交 JSONL 请求列表异步处理，查询状态/用量汇总与结果
+		v1.POST("/batches", middleware.AuthRequired(), handler.CreateBatch)
+		v1.GET("/batches", middleware.AuthRequired(), handler.ListBatches)
+		v1.GET("/batches/:id", middleware.AuthRequired(), handler.GetBatch)
+		v1.GET("/batches/:id/results", middleware.AuthRequired(), handler.GetBatchResults)
+		v1.POST("/batches/:id/cancel", middleware.AuthRequired(), handler.CancelBatch)
+
+		// 文件端点：上传/列出/查看/下载/删除调用方自己的文件（批处理可通过 input_file_id 引用）
+		v1.POST("/files", middleware.AuthRequired(), handler.UploadFile)
+		v1.GET("/files", middleware.AuthRequired(), handler.ListFiles)
+		v1.GET("/files/:id", middleware.AuthRequired(), handler.GetFile)
+		v1.GET("/files/:id/content", middleware.AuthRequired(), handler.GetFileContent)
+		v1.DELETE("/files/:id", middleware.AuthRequired(), handler.DeleteFile)
 		
-		// Anthropic Responses API 端点（Codex CLI 使用）
-		// Codex CLI 使用 OpenAI 格式，所以使用 ChatCompletions 处理器
-		// 使用可选认证，允许没有 Authorization 头的请求
-		v1.POST("/responses", middleware.OptionalAuth("sk-test-demo-2024"), handler.ChatCompletions)
+		// OpenAI Responses API 端点（Codex CLI 及新版 SDK 使用）
+		v1.POST("/responses", latency, middleware.AuthRequired(), timeout, middleware.LanguageDetection(), middleware.RoutingRules(false), responseCache, qos, handler.Responses)
+	}
+
+	// Google Gemini API 路由组（Google SDK 可直接指向本服务，密钥通过 x-goog-api-key 或 key 参数传递）
+	v1beta := router.Group("/v1beta", middleware.APINotices())
+	{
+		// generateContent / streamGenerateContent，模型在路径中：/v1beta/models/{model}:{method}
+		v1beta.POST("/models/*action", middleware.GeminiRequest(), latency, middleware.AuthRequired(), timeout, middleware.LanguageDetection(), middleware.RoutingRules(false), responseCache, qos, handler.GeminiGenerateContent)
 	}
 
 	// 用户公告路由组（需要会话认证）
@@ -299,6 +605,7 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		usage.GET("/stats", handlers.GetUserUsageStats)     // 获取用户使用统计
 		usage.GET("/recent", handlers.GetUserRecentCalls)   // 获取最近的API调用
 		usage.GET("/trends", handlers.GetUserUsageTrends)   // 获取用户使用趋势
+		usage.GET("/errors", handlers.GetUserUsageErrors)   // 失败请求分析（按状态码、错误类型、模型、密钥）
 	}
 
 	// 用户余额路由组（需要会话认证）
@@ -306,6 +613,8 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 	{
 		balance.GET("", handlers.GetBalanceHandler)                // 获取当前余额
 		balance.GET("/transactions", handlers.GetTransactionsHandler) // 获取交易记录
+		balance.GET("/currency", handlers.GetCurrencySettingsHandler)  // 获取显示货币与汇率
+		balance.GET("/statement", handlers.GetStatementHandler)        // 获取月度对账单（含税费明细）
 	}
 
 	// 用户邀请路由组（需要会话认证）
@@ -316,12 +625,39 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		referral.GET("/list", handlers.GetReferralListHandler)   // 获取邀请列表
 	}
 
+	// 每日签到路由组（需要会话认证）
+	checkin := router.Group("/api/checkin", middleware.SessionAuth())
+	{
+		checkin.GET("", handlers.GetCheckinStatusHandler) // 获取签到状态、连续天数与下次奖励
+		checkin.POST("", handlers.CheckinHandler)         // 今日签到并领取奖励
+	}
+
 	// 模型广场路由组（需要会话认证）
 	models := router.Group("/api/models", middleware.SessionAuth())
 	{
 		models.GET("/marketplace", handlers.GetModelMarketplaceHandler) // 获取模型广场数据
 	}
 
+	// 客户端集成路由组（需要会话认证）：为 Claude Code / Codex CLI / Continue / Cline 生成接入配置
+	integrations := router.Group("/api/integrations", middleware.SessionAuth())
+	{
+		integrations.GET("", handler.ListIntegrations)          // 获取支持的客户端及可用密钥
+		integrations.GET("/:id", handler.GetIntegrationConfig) // 生成指定客户端的配置片段
+	}
+
+	// 用户自助密钥管理路由组（需要会话认证）：只能管理自己的密钥
+	keys := router.Group("/api/keys", middleware.SessionAuth())
+	{
+		keys.GET("", handler.ListOwnKeys)                             // 列出自己的密钥
+		keys.POST("", handler.CreateOwnKey)                           // 生成新密钥
+		keys.PUT("/:key/name", handler.RenameOwnKey)                  // 修改密钥名称
+		keys.PUT("/:key/toggle", handler.ToggleOwnKey)                // 启用/禁用密钥
+		keys.DELETE("/:key", handler.DeleteOwnKey)                    // 删除密钥
+		keys.GET("/:key/usage", handler.GetOwnKeyUsage)               // 查看密钥用量与额度
+		keys.POST("/:key/rotate", handlers.RotateOwnKeyHandler)       // 轮换密钥（同 /profile/keys/:key/rotate）
+		keys.PUT("/:key/allowed-ips", handler.UpdateOwnKeyAllowedIPs) // 设置密钥 IP 白名单
+	}
+
 	// 聊天路由组（需要会话认证）
 	// Requirements: 1.1, 2.1, 3.1
 	chat := router.Group("/api/chat", middleware.SessionAuth())
@@ -333,7 +669,38 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		chat.PUT("/conversations/:id", chatHandler.UpdateConversation)        // 更新会话
 		chat.DELETE("/conversations/:id", chatHandler.DeleteConversation)     // 删除会话
 		chat.GET("/conversations/:id/messages", chatHandler.GetMessages)      // 获取消息列表
+		chat.GET("/conversations/:id/export", chatHandler.ExportConversation) // 导出会话（Markdown/JSON）
+		chat.POST("/conversations/:id/share", chatHandler.ShareConversation)  // 生成公开分享链接
+		chat.GET("/conversations/:id/shares", chatHandler.GetShares)          // 获取会话的分享链接
+		chat.DELETE("/shares/:shareId", chatHandler.RevokeShare)              // 吊销分享链接
+		chat.PUT("/conversations/:id/folder", chatHandler.MoveConversation)   // 移动会话到文件夹
+		chat.PUT("/conversations/:id/tags", chatHandler.SetTags)              // 设置会话标签
+		chat.PUT("/conversations/:id/pin", chatHandler.PinConversation)       // 置顶/取消置顶会话
+		chat.PUT("/conversations/:id/archive", chatHandler.ArchiveConversation) // 归档/取消归档会话（归档后默认不在列表中显示）
+		chat.POST("/conversations/:id/title", chatHandler.RegenerateTitle)    // 根据首轮对话重新生成标题
 		chat.POST("/conversations/:id/messages", chatHandler.SendMessage)     // 发送消息(SSE)
+		chat.POST("/conversations/:id/messages/:msgId/cancel", chatHandler.StopGeneration) // 停止生成并保存已生成的部分
+		chat.GET("/conversations/:id/messages/:msgId/stream", chatHandler.ResumeStream)     // 断线后按 Last-Event-ID 续传回复
+		chat.POST("/conversations/:id/messages/:msgId/regenerate", chatHandler.RegenerateMessage) // 重新生成最后一条回复（SSE）
+		chat.POST("/conversations/:id/messages/:msgId/edit", chatHandler.EditMessage)             // 编辑消息并从该处开启新分支（SSE）
+		chat.GET("/conversations/:id/branches", chatHandler.GetBranches)                          // 获取会话分支列表
+		chat.PUT("/conversations/:id/branch", chatHandler.SwitchBranch)                           // 切换当前分支
+		chat.GET("/ws", chatHandler.ChatWebSocket)                            // 发送消息(WebSocket)
+		chat.GET("/search", chatHandler.SearchConversations)                  // 全文搜索消息
+		chat.POST("/attachments", chatHandler.UploadAttachment)               // 上传消息附件并提取文本（txt/md/pdf）
+		chat.DELETE("/attachments/:attachmentId", chatHandler.DeleteAttachment) // 删除尚未发送的附件
+		chat.POST("/messages/:id/feedback", chatHandler.SubmitFeedback)       // 对回复点赞/点踩并评论
+		chat.DELETE("/messages/:id/feedback", chatHandler.DeleteFeedback)     // 撤回反馈
+		// 文件夹与标签
+		chat.GET("/folders", chatHandler.GetFolders)                          // 获取文件夹列表
+		chat.POST("/folders", chatHandler.CreateFolder)                       // 创建文件夹
+		chat.PUT("/folders/:folderId", chatHandler.RenameFolder)              // 重命名文件夹
+		chat.DELETE("/folders/:folderId", chatHandler.DeleteFolder)           // 删除文件夹（会话移出）
+		chat.GET("/tags", chatHandler.GetTags)                                // 获取标签列表
+		chat.GET("/templates", chatHandler.GetPromptTemplates)                // 获取系统提示词模板（含全局模板）
+		chat.POST("/templates", chatHandler.CreatePromptTemplate)             // 创建系统提示词模板
+		chat.PUT("/templates/:templateId", chatHandler.UpdatePromptTemplate)  // 更新自己的模板
+		chat.DELETE("/templates/:templateId", chatHandler.DeletePromptTemplate) // 删除自己的模板
 		// 模型列表
 		chat.GET("/models", chatHandler.GetModels)                            // 获取可用模型列表
 	}
@@ -366,10 +733,24 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 	admin.Use(handlers.AdminAuth())
 	{
 		// 密钥管理
-		admin.GET("/keys", handlers.ListKeysHandler)                 // 列出所有密钥
+		admin.GET("/keys", handlers.ListKeysHandler)                 // 列出所有密钥（搜索、筛选、排序、分页）
+		admin.GET("/keys/expiring", handlers.ListExpiringKeysHandler) // 即将过期与已过期的密钥
+		admin.GET("/keys/:key/details", handlers.GetKeyDetailsHandler) // 密钥详情：用量、额度与访问限制
 		admin.POST("/keys", handlers.AddKeyHandler)                  // 添加新密钥
 		admin.PUT("/keys/:key/toggle", handlers.ToggleKeyStatusHandler) // 切换密钥状态
 		admin.PUT("/keys/:key/name", handlers.UpdateKeyNameHandler)  // 更新密钥名称
+		admin.PUT("/keys/:key/priority", handlers.UpdateKeyPriorityHandler) // 设置密钥优先级信任
+		admin.PUT("/keys/:key/streaming", handlers.UpdateKeyStreamingHandler) // 设置密钥 SSE 合并策略
+		admin.PUT("/keys/:key/signing", handlers.UpdateKeySigningHandler) // 启用/关闭密钥 HMAC 请求签名
+		admin.PUT("/keys/:key/tags", handlers.UpdateKeyTagsHandler) // 设置密钥标签（路由规则匹配）
+		admin.PUT("/keys/:key/scopes", handlers.UpdateKeyScopesHandler) // 设置密钥作用域（限定可调用的端点）
+		admin.PUT("/keys/:key/expiration", handlers.UpdateKeyExpirationHandler) // 设置或延长密钥过期时间
+		admin.PUT("/keys/:key/models", handler.UpdateKeyModels) // 设置密钥模型白名单
+		admin.PUT("/keys/:key/allowed-ips", handlers.UpdateKeyAllowedIPsHandler) // 设置密钥 IP 白名单
+		admin.GET("/keys/:key/quota", handlers.GetKeyQuotaHandler) // 查看密钥额度
+		admin.POST("/keys/:key/quota/reset", handlers.ResetKeyQuotaHandler) // 重置密钥已用额度（仅管理员）
+		admin.POST("/keys/rotate", middleware.RequireSudo(), handler.AdminRotateKeys) // 批量轮换用户密钥（可设宽限期，需 sudo）
+		admin.POST("/keys/:key/rotate", middleware.RequireSudo(), handlers.AdminRotateKeyHandler) // 轮换单个密钥（可设宽限期，需 sudo）
 		admin.DELETE("/keys/:key", handlers.RemoveKeyHandler)        // 删除密钥
 
 		// Cursor Session 管理
@@ -392,12 +773,95 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 			quota.POST("/reset", handler.ResetQuotas)        // 手动重置配额
 		}
 
+		// 配置自检
+		admin.GET("/doctor", handler.AdminDoctor) // 运行配置自检
+
+		// 从 one-api/new-api 导入数据
+		admin.POST("/import/gateway", middleware.RequireSudo(), handlers.AdminImportGatewayHandler) // 导入用户、令牌与使用记录（默认 dry-run，需 sudo）
+
+		// 延迟预算与告警
+		admin.GET("/slos", handlers.ListLatencySLOsHandler)          // 列出延迟预算及实时状态
+		admin.POST("/slos", handlers.CreateLatencySLOHandler)        // 创建延迟预算
+		admin.GET("/slos/alerts", handlers.ListLatencyAlertsHandler) // 获取告警记录
+		admin.PUT("/slos/:id", handlers.UpdateLatencySLOHandler)     // 更新延迟预算
+		admin.DELETE("/slos/:id", handlers.DeleteLatencySLOHandler)  // 删除延迟预算
+
+		// 提供商月度消费上限
+		admin.GET("/provider-spend-caps", handlers.ListProviderSpendCapsHandler)               // 列出消费上限及当月消费
+		admin.GET("/provider-spend-caps/alerts", handlers.ListProviderSpendAlertsHandler)      // 获取消费告警记录
+		admin.PUT("/provider-spend-caps/:provider", handlers.SaveProviderSpendCapHandler)      // 设置提供商消费上限
+		admin.DELETE("/provider-spend-caps/:provider", handlers.DeleteProviderSpendCapHandler) // 删除提供商消费上限
+
+		// 自定义 OpenAI 兼容上游（修改后热加载）
+		admin.GET("/providers", handler.AdminListCustomProviders)         // 列出自定义上游
+		admin.POST("/providers", handler.AdminCreateCustomProvider)       // 注册自定义上游
+		admin.PUT("/providers/:id", handler.AdminUpdateCustomProvider)    // 更新自定义上游
+		admin.DELETE("/providers/:id", handler.AdminDeleteCustomProvider) // 删除自定义上游
+		admin.GET("/providers/metrics", handler.AdminGetProviderMetrics)  // 各提供商请求量、错误率、延迟与 token 指标
+
+		// 请求路由规则（按顺序匹配：路由到提供商、设置优先级、追加系统提示词、拒绝请求）
+		admin.GET("/routing-rules", handlers.AdminListRoutingRules)              // 列出路由规则
+		admin.POST("/routing-rules", handlers.AdminCreateRoutingRule)            // 创建路由规则
+		admin.POST("/routing-rules/evaluate", handlers.AdminEvaluateRoutingRules) // 试运行路由规则
+		admin.PUT("/routing-rules/:id", handlers.AdminUpdateRoutingRule)         // 更新路由规则
+		admin.DELETE("/routing-rules/:id", handlers.AdminDeleteRoutingRule)      // 删除路由规则
+		admin.GET("/changelog", handlers.AdminListChangelog)                     // 列出 API 变更日志
+		admin.POST("/changelog", handlers.AdminCreateChangelogEntry)             // 创建 API 变更日志条目
+		admin.PUT("/changelog/:id", handlers.AdminUpdateChangelogEntry)          // 更新 API 变更日志条目
+		admin.DELETE("/changelog/:id", handlers.AdminDeleteChangelogEntry)       // 删除 API 变更日志条目
+		admin.GET("/chat/templates", handlers.AdminListPromptTemplates)          // 列出全局系统提示词模板
+		admin.GET("/chat/feedback", handlers.AdminGetFeedbackStats)              // 按模型与提供商汇总回复反馈
+		admin.POST("/chat/templates", middleware.AdminOnly(), handlers.AdminCreatePromptTemplate)                 // 发布全局系统提示词模板（所有用户可见，仅限管理员）
+		admin.PUT("/chat/templates/:templateId", middleware.AdminOnly(), handlers.AdminUpdatePromptTemplate)    // 更新全局模板
+		admin.DELETE("/chat/templates/:templateId", middleware.AdminOnly(), handlers.AdminDeletePromptTemplate) // 删除全局模板
+
+		// 每日运营摘要推送
+		admin.GET("/ops-summary/config", handlers.GetOpsSummaryConfigHandler)    // 获取摘要推送配置
+		admin.PUT("/ops-summary/config", handlers.UpdateOpsSummaryConfigHandler) // 更新摘要推送配置（Webhook 列表）
+		admin.GET("/ops-summary/preview", handlers.PreviewOpsSummaryHandler)     // 预览指定日期的运营摘要
+		admin.GET("/jobs", handlers.AdminListJobsHandler)                        // 后台任务进度
+		admin.POST("/jobs/vacuum", middleware.RequireSudo(), handlers.AdminRunVacuumHandler) // 立即执行过期数据清理（需 sudo）
+		admin.POST("/jobs/chat-archive", handlers.AdminRunChatArchiveHandler)                 // 立即归档长期未活动的对话消息
+		admin.GET("/moderation/rules", handlers.AdminGetModerationRulesHandler)    // 获取本地审核规则
+		admin.PUT("/moderation/rules", handlers.AdminUpdateModerationRulesHandler) // 替换本地审核规则
+		admin.POST("/ops-summary/send", handlers.SendOpsSummaryHandler)          // 立即推送运营摘要
+		admin.GET("/checkin/config", handlers.GetCheckinConfigHandler)           // 获取签到奖励配置
+		admin.PUT("/checkin/config", handlers.UpdateCheckinConfigHandler)        // 更新签到奖励配置（奖励、连签加成、防刷限制）
+		admin.GET("/trial/config", handlers.GetTrialConfigHandler)               // 获取匿名试用配置
+		admin.PUT("/trial/config", handlers.UpdateTrialConfigHandler)            // 更新匿名试用配置（有效期、限流、可试用模型）
+		admin.GET("/trial/stats", handlers.GetTrialStatsHandler)                 // 匿名试用签发与注册转化统计
+
+		// 审计日志
+		admin.GET("/audit-logs", handlers.ListAuditLogsHandler) // 获取审计记录
+		admin.GET("/security/two-factor", middleware.AdminOnly(), handlers.GetTwoFactorPolicyHandler)      // 获取管理员两步验证要求
+		admin.PUT("/security/two-factor", middleware.RequireSudo(), handlers.UpdateTwoFactorPolicyHandler) // 要求管理员启用两步验证（需 sudo）
+		admin.POST("/signed-tokens/revoke", handlers.RevokeSignedTokenHandler) // 吊销签名链接/令牌
+		admin.GET("/terms", handlers.ListTermsVersionsHandler)  // 获取服务条款版本列表
+		admin.POST("/terms", handlers.PublishTermsHandler)      // 发布新版服务条款（需重新接受）
+
+		// 实例配置导出/导入
+		admin.GET("/config/export", handler.AdminExportConfig)  // 导出配置包（JSON/YAML）
+		admin.POST("/config/import", middleware.RequireSudo(), handler.AdminImportConfig) // 导入配置包（默认 dry-run，需 sudo）
+
+		// 模型可用性：停用模型与提供商故障
+		admin.GET("/provider-status", handlers.AdminGetProviderStatusHandler)                       // 获取停用模型与故障记录
+		admin.GET("/provider-status/health", handler.AdminGetProviderHealth)                         // 获取提供商健康与熔断状态
+		admin.PUT("/provider-status/disabled-models", handlers.AdminUpdateDisabledModelsHandler)    // 更新停用模型列表
+		admin.POST("/provider-status/incidents", handlers.AdminOpenIncidentHandler)                 // 登记提供商故障
+		admin.DELETE("/provider-status/incidents/:id", handlers.AdminResolveIncidentHandler)        // 解除提供商故障
+		admin.GET("/provider-balancing", handler.AdminGetProviderBalancing)                          // 获取负载均衡配置与流量分布
+		admin.PUT("/provider-balancing", handler.AdminUpdateProviderBalancing)                       // 更新负载均衡策略与提供商权重
+		admin.GET("/request-timeouts", handler.AdminGetRequestTimeouts)                              // 获取请求超时配置
+		admin.PUT("/request-timeouts", handler.AdminUpdateRequestTimeouts)                           // 更新全局/按模型超时与单请求覆盖上下限
+		admin.GET("/logging", handler.AdminGetLogLevels)                                             // 获取各子系统日志级别
+		admin.PUT("/logging", handler.AdminUpdateLogLevels)                                          // 运行时调整子系统日志级别
+
 		// 用户管理
 		admin.GET("/users", handlers.ListUsersHandler)                    // 列出所有用户
 		admin.GET("/users/:id", handlers.GetUserHandler)                  // 获取用户信息
 		admin.PUT("/users/:id/role", handlers.UpdateUserRoleHandler)      // 更新用户角色
 		admin.PUT("/users/:id/status", handlers.ToggleUserStatusHandler)  // 启用/禁用用户
-		admin.DELETE("/users/:id", handlers.DeleteUserHandler)            // 删除用户
+		admin.DELETE("/users/:id", middleware.RequireSudo(), handlers.DeleteUserHandler) // 删除用户（需 sudo）
 
 		// 公告管理
 		admin.POST("/announcements", handlers.CreateAnnouncementHandler)       // 创建公告
@@ -407,28 +871,44 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		// 使用统计管理
 		adminUsage := admin.Group("/usage")
 		{
-			adminUsage.GET("/stats", handlers.GetAdminUsageStats)           // 获取系统级使用统计
-			adminUsage.GET("/trends", handlers.GetAdminUsageTrends)         // 获取使用趋势
-			adminUsage.GET("/sessions", handlers.GetAdminCursorSessionUsage) // 获取Cursor会话使用统计
-			adminUsage.GET("/export", handlers.ExportUsageData)             // 导出使用数据为CSV
-			adminUsage.GET("/retention", handlers.GetRetentionConfig)       // 获取数据保留配置
-			adminUsage.PUT("/retention", handlers.UpdateRetentionConfig)    // 更新数据保留期限
-			adminUsage.POST("/cleanup", handlers.TriggerCleanupNow)         // 手动触发清理
-			adminUsage.GET("/cleanup/stats", handlers.GetCleanupStats)      // 获取清理统计
+			adminUsage.GET("/stats", handlers.GetAdminUsageStats)               // 获取系统级使用统计
+			adminUsage.GET("/trends", handlers.GetAdminUsageTrends)             // 获取使用趋势
+			adminUsage.GET("/sessions", handlers.GetAdminCursorSessionUsage)    // 获取Cursor会话使用统计
+			adminUsage.GET("/languages", handlers.GetAdminLanguageDistribution) // 获取提示词语言分布
+			adminUsage.GET("/export", handlers.ExportUsageData)                 // 导出使用数据为CSV
+			adminUsage.POST("/export-link", handlers.CreateUsageExportLink)     // 生成一次性CSV下载链接
+			adminUsage.GET("/retention", handlers.GetRetentionConfig)           // 获取数据保留配置
+			adminUsage.PUT("/retention", handlers.UpdateRetentionConfig)        // 更新数据保留期限
+			adminUsage.POST("/cleanup", middleware.RequireSudo(), handlers.TriggerCleanupNow) // 手动触发清理（需 sudo）
+			adminUsage.GET("/cleanup/stats", handlers.GetCleanupStats)          // 获取清理统计
 		}
 
 		// 余额管理
 		adminBalance := admin.Group("/balance")
 		{
-			adminBalance.POST("/adjust", handlers.AdjustUserBalanceHandler)  // 调整用户余额
+			adminBalance.POST("/adjust", middleware.RequireSudo(), handlers.AdjustUserBalanceHandler) // 调整用户余额（需 sudo）
 			adminBalance.GET("/users", handlers.GetAllUserBalancesHandler)   // 获取所有用户余额
 		}
 
+		// 汇率管理
+		admin.GET("/fx-rates", handlers.AdminGetFXRatesHandler)    // 获取汇率配置
+		admin.PUT("/fx-rates", handlers.AdminUpdateFXRatesHandler) // 更新汇率配置
+
+		// 模型定价管理
+		admin.PUT("/pricing/:model", handlers.AdminUpdateModelPricingHandler) // 更新模型定价
+		admin.POST("/pricing/simulate", handlers.AdminSimulatePricingHandler) // 模拟调价对近期消费的影响
+
+		// 税率管理
+		admin.GET("/tax-rates", handlers.AdminGetTaxRatesHandler)    // 获取各国税率
+		admin.PUT("/tax-rates", handlers.AdminUpdateTaxRatesHandler) // 更新各国税率
+
 		// 兑换记录管理
 		adminExchange := admin.Group("/exchanges")
 		{
-			adminExchange.GET("", handlers.AdminGetAllExchangesHandler)       // 获取所有兑换记录
-			adminExchange.GET("/stats", handlers.AdminGetExchangeStatsHandler) // 获取兑换统计
+			adminExchange.GET("", handlers.AdminGetAllExchangesHandler)                                        // 获取所有兑换记录
+			adminExchange.GET("/stats", handlers.AdminGetExchangeStatsHandler)                                 // 获取兑换统计
+			adminExchange.GET("/compensations", handlers.AdminListTransferCompensationsHandler)                // 获取互转失败补偿记录
+			adminExchange.POST("/compensations/:id/resolve", handlers.AdminResolveTransferCompensationHandler) // 标记补偿记录已处理
 		}
 	}
 
@@ -506,3 +986,19 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler, cfg *config.Conf
 		c.File("./dist/index.html")
 	})
 }
+
+// runConfigCheck 运行配置自检并打印 JSON 报告，返回进程退出码
+func runConfigCheck(cfg *config.Config) int {
+	if err := database.Connect(cfg); err != nil {
+		logrus.Errorf("Database connection failed: %v", err)
+	}
+
+	report := services.RunDoctor(cfg)
+	output, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(output))
+
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"Curry2API-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLog 输出 gin 访问日志；access 子系统级别高于 info 时不记录
+func AccessLog() gin.HandlerFunc {
+	logger := gin.Logger()
+	return func(c *gin.Context) {
+		if !utils.LogEnabled(utils.LogSubsystemAccess, logrus.InfoLevel) {
+			c.Next()
+			return
+		}
+		logger(c)
+	}
+}
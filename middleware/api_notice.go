@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// APINoticeHeader 提示调用方网关有行为变化的响应头，值为逗号分隔的变更日志条目 ID（最新的在前），
+// 详情见 GET /api/changelog/:id
+const APINoticeHeader = "X-CurryAPI-Notice"
+
+var (
+	apiNotices   []*database.ChangelogEntry
+	apiNoticesMu sync.RWMutex
+)
+
+// ReloadAPINotices 从数据库重新加载已发布的变更日志，管理员修改后调用
+func ReloadAPINotices() error {
+	entries, err := database.ListChangelogEntries(true)
+	if err != nil {
+		return err
+	}
+	// 已结束提示的条目不再需要
+	now := time.Now()
+	active := make([]*database.ChangelogEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.NoticeUntil == nil || now.Before(*e.NoticeUntil) {
+			active = append(active, e)
+		}
+	}
+	apiNoticesMu.Lock()
+	apiNotices = active
+	apiNoticesMu.Unlock()
+	logrus.Infof("Loaded %d API changelog notices", len(active))
+	return nil
+}
+
+// ValidateChangelogEntry 检查变更日志条目是否有效
+func ValidateChangelogEntry(e *database.ChangelogEntry) error {
+	if strings.TrimSpace(e.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if len(e.Title) > 200 {
+		return fmt.Errorf("title must be at most 200 characters")
+	}
+	switch e.Category {
+	case database.ChangelogCategoryBehavior, database.ChangelogCategoryRateLimit,
+		database.ChangelogCategoryDeprecation, database.ChangelogCategoryRemoval:
+	default:
+		return fmt.Errorf("category must be one of %s, %s, %s, %s",
+			database.ChangelogCategoryBehavior, database.ChangelogCategoryRateLimit,
+			database.ChangelogCategoryDeprecation, database.ChangelogCategoryRemoval)
+	}
+	for _, model := range e.Models {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("models must not contain empty patterns")
+		}
+	}
+	if e.NoticeUntil != nil && !e.NoticeUntil.After(e.NoticeFrom) {
+		return fmt.Errorf("notice_until must be after notice_from")
+	}
+	return nil
+}
+
+// activeNotices 返回 now 时应提示的条目，最新的在前
+func activeNotices(now time.Time) []*database.ChangelogEntry {
+	apiNoticesMu.RLock()
+	defer apiNoticesMu.RUnlock()
+	var active []*database.ChangelogEntry
+	for _, e := range apiNotices {
+		if e.NoticeActive(now) {
+			active = append(active, e)
+		}
+	}
+	return active
+}
+
+// noticeMatchesModel 条目是否适用于请求的模型；限定了模型的条目在模型未知时不提示
+func noticeMatchesModel(e *database.ChangelogEntry, model string) bool {
+	if len(e.Models) == 0 {
+		return true
+	}
+	if model == "" {
+		return false
+	}
+	for _, pattern := range e.Models {
+		if matchModelPattern(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestModel 读取请求的模型：Gemini 接口在路径中，其他接口在 JSON 请求体的 model 字段。
+// multipart 上传（如 /v1/audio/transcriptions）不读取请求体以免缓冲整个文件，模型由处理器自行校验
+func requestModel(c *gin.Context) string {
+	if action := c.Param("action"); action != "" {
+		if model, _, ok := ParseGeminiAction(action); ok {
+			return model
+		}
+	}
+	if c.Request.Body == nil || c.Request.Method == "GET" || strings.HasPrefix(c.ContentType(), "multipart/") {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(body, &req)
+	return req.Model
+}
+
+// APINotices 在 API 响应中设置 X-CurryAPI-Notice 头，列出提示期内适用于本次请求的变更日志条目，
+// 让调用方无需阅读公告也能注意到网关的行为变化。只有存在限定模型的条目时才读取请求体
+func APINotices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		notices := activeNotices(time.Now())
+		if len(notices) == 0 {
+			c.Next()
+			return
+		}
+
+		model, modelRead := "", false
+		ids := make([]string, 0, len(notices))
+		for _, e := range notices {
+			if len(e.Models) > 0 && !modelRead {
+				model, modelRead = requestModel(c), true
+			}
+			if noticeMatchesModel(e, model) {
+				ids = append(ids, strconv.FormatInt(e.ID, 10))
+			}
+		}
+		if len(ids) > 0 {
+			c.Header(APINoticeHeader, strings.Join(ids, ", "))
+		}
+		c.Next()
+	}
+}
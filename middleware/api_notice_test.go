@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unreadableBody fails the test if the request body is read
+type unreadableBody struct{ t *testing.T }
+
+func (b unreadableBody) Read([]byte) (int, error) {
+	b.t.Error("multipart request body was read")
+	return 0, errors.New("unexpected read")
+}
+
+func (b unreadableBody) Close() error { return nil }
+
+func TestRequestModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if model := requestModel(c); model != "gpt-4o" {
+		t.Errorf("requestModel(json) = %q, want gpt-4o", model)
+	}
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", nil)
+	c.Request.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	c.Request.Body = unreadableBody{t}
+	if model := requestModel(c); model != "" {
+		t.Errorf("requestModel(multipart) = %q, want empty", model)
+	}
+}
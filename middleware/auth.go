@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"Curry2API-go/models"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -54,6 +56,12 @@ func AuthRequired() gin.HandlerFunc {
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
+		// 匿名试用临时密钥不属于任何用户，单独校验有效期、IP 绑定与限流
+		if IsTrialKey(token) {
+			trialAuth(c, token)
+			return
+		}
+
 		// 使用密钥管理器验证密钥
 		if !km.IsValidKey(token) {
 			errorResponse := models.NewErrorResponse(
@@ -66,14 +74,82 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// Check token expiration before anything else about the key, naming the expiry time
+		// Requirements: 13.3
+		if err := km.CheckTokenExpiration(token); err == ErrTokenExpired {
+			message := "Token expired - this token has passed its expiration date"
+			if info, ok := km.GetKeyInfo(token); ok && info.ExpiresAt != nil {
+				message = "Token expired - this token expired at " + info.ExpiresAt.UTC().Format(time.RFC3339) +
+					"; extend its expiration or create a new key"
+			}
+			errorResponse := models.NewErrorResponse(
+				message,
+				"authentication_error",
+				"token_expired",
+			)
+			c.JSON(http.StatusUnauthorized, errorResponse)
+			c.Abort()
+			return
+		}
+
+		// 设置了 IP 白名单的密钥只能从白名单内的地址调用
+		if clientIP := c.ClientIP(); !km.IsIPAllowed(token, clientIP) {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"This API key is not allowed to be used from IP address "+clientIP,
+				"permission_error",
+				"ip_not_allowed",
+			))
+			c.Abort()
+			return
+		}
+
+		// 启用请求签名的密钥须通过 HMAC 签名校验（签名覆盖原始请求体）
+		if !km.CheckRequestSignature(c, token) {
+			return
+		}
+
+		// 设置了作用域的密钥只能调用作用域内的端点
+		if scope := RouteScope(c.FullPath()); !km.HasScope(token, scope) {
+			message := "This API key is not allowed to call this endpoint - it needs the \"" + scope + "\" scope"
+			if scope == "" {
+				message = "This API key is not allowed to call this endpoint - only keys without scopes can"
+			}
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				message,
+				"permission_error",
+				"insufficient_scope",
+			))
+			c.Abort()
+			return
+		}
+
+		// 设置了模型白名单的密钥只能调用白名单内的模型；未设置白名单时不读取请求体
+		if modelScopes[RouteScope(c.FullPath())] && km.HasModelRestriction(token) {
+			if model := requestModel(c); model != "" && km.CheckTokenModelAccess(token, model) == ErrModelNotAllowed {
+				c.JSON(http.StatusForbidden, models.NewErrorResponse(
+					"Model not allowed - this token does not have access to model: "+model,
+					"forbidden",
+					"model_not_allowed",
+				))
+				c.Abort()
+				return
+			}
+		}
+
 		// Check balance status after token validation
 		// Requirements: 3.2
 		if err := km.CheckBalanceStatus(token); err != nil {
 			if err == ErrBalanceExhausted {
-				errorResponse := models.NewErrorResponse(
+				// 附带充值指引：当前余额、本次请求的预估费用与最低充值额
+				var guidance *models.BalanceGuidance
+				if userID := km.GetUserIDForKey(token); userID != nil {
+					body, _ := io.ReadAll(io.LimitReader(c.Request.Body, balanceEstimateMaxBytes))
+					guidance = NewBalanceGuidance(c, *userID, estimateBodyTokens(body))
+				}
+				errorResponse := models.NewInsufficientBalanceResponse(
 					"Insufficient balance - your account balance is exhausted",
-					"payment_required",
 					"balance_exhausted",
+					guidance,
 				)
 				c.JSON(http.StatusPaymentRequired, errorResponse)
 				c.Abort()
@@ -96,19 +172,28 @@ func AuthRequired() gin.HandlerFunc {
 			}
 		}
 
-		// Check token expiration
-		// Requirements: 13.3
-		if err := km.CheckTokenExpiration(token); err != nil {
-			if err == ErrTokenExpired {
-				errorResponse := models.NewErrorResponse(
-					"Token expired - this token has passed its expiration date",
-					"authentication_error",
-					"token_expired",
-				)
-				c.JSON(http.StatusUnauthorized, errorResponse)
-				c.Abort()
-				return
-			}
+		// 用户自设的每日/每周消费上限（spend guard）
+		if err := km.CheckSpendGuard(token); err != nil {
+			errorResponse := models.NewErrorResponse(
+				"Spend guard reached - "+err.Error(),
+				"payment_required",
+				"spend_guard_exceeded",
+			)
+			c.JSON(http.StatusPaymentRequired, errorResponse)
+			c.Abort()
+			return
+		}
+
+		// 启用强制时，密钥所属用户须已接受当前服务条款
+		if err := km.CheckTermsAccepted(token); err == ErrTermsNotAccepted {
+			errorResponse := models.NewErrorResponse(
+				"Terms of service not accepted - accept the current terms of service to continue using the API",
+				"permission_error",
+				"terms_not_accepted",
+			)
+			c.JSON(http.StatusForbidden, errorResponse)
+			c.Abort()
+			return
 		}
 
 		// 认证通过，记录使用次数
@@ -116,6 +201,12 @@ func AuthRequired() gin.HandlerFunc {
 
 		// 将使用的密钥存入上下文（用于日志和管理）
 		c.Set("api_key", token)
+
+		// 解析请求优先级（仅受信任密钥的 X-Priority 生效）
+		c.Set("request_priority", km.ResolvePriority(token, c.GetHeader("X-Priority")))
+
+		// 解析 SSE 输出合并策略（密钥覆盖值优先）
+		c.Set("stream_flush", km.ResolveStreamFlush(token))
 		
 		// 获取密钥关联的用户信息并存入上下文（用于使用跟踪）
 		km.mu.RLock()
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// rechargePath 前端中展示余额与充值入口的页面
+const rechargePath = "/dashboard"
+
+// balanceEstimateMaxBytes 估算被拒请求费用时最多读取的请求体字节数
+const balanceEstimateMaxBytes = 1 << 20
+
+var (
+	rechargeURLMu sync.RWMutex
+	rechargeURL   string
+)
+
+// ConfigureRechargeURL 设置 402 响应中的充值链接；url 为空时使用 publicBaseURL 下的充值页面，两者均为空时按请求地址推断
+func ConfigureRechargeURL(url, publicBaseURL string) {
+	if url == "" && publicBaseURL != "" {
+		url = publicBaseURL + rechargePath
+	}
+	rechargeURLMu.Lock()
+	defer rechargeURLMu.Unlock()
+	rechargeURL = url
+}
+
+// RechargeURL 返回充值页面地址：优先使用配置值，否则按请求（含反向代理头）推断
+func RechargeURL(c *gin.Context) string {
+	rechargeURLMu.RLock()
+	configured := rechargeURL
+	rechargeURLMu.RUnlock()
+	if configured != "" {
+		return configured
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host + rechargePath
+}
+
+// EstimateRequestTokens 估算请求的 token 用量：prompt 按模型的 tokenizer 计算，
+// completion 取 maxTokens，未指定时按经验比例估算
+func EstimateRequestTokens(model, prompt string, maxTokens int) int {
+	promptTokens := utils.CountTokens(model, prompt)
+	if maxTokens <= 0 {
+		maxTokens = utils.EstimateResponseTokens(promptTokens, 0)
+	}
+	return promptTokens + maxTokens
+}
+
+// estimateBodyTokens 按 /v1 请求体估算 token 用量：Chat 格式的请求按消息计算，其他格式按整个请求体计算；
+// 读取 max_tokens / max_completion_tokens 作为输出上限
+func estimateBodyTokens(body []byte) int {
+	var request struct {
+		Model               string           `json:"model"`
+		Messages            []models.Message `json:"messages"`
+		MaxTokens           int              `json:"max_tokens"`
+		MaxCompletionTokens int              `json:"max_completion_tokens"`
+	}
+	_ = json.Unmarshal(body, &request)
+	maxTokens := request.MaxCompletionTokens
+	if maxTokens <= 0 {
+		maxTokens = request.MaxTokens
+	}
+	if len(request.Messages) == 0 {
+		return EstimateRequestTokens(request.Model, string(body), maxTokens)
+	}
+	promptTokens := utils.CountMessageTokens(request.Model, request.Messages, nil)
+	if maxTokens <= 0 {
+		maxTokens = utils.EstimateResponseTokens(promptTokens, 0)
+	}
+	return promptTokens + maxTokens
+}
+
+// NewBalanceGuidance 计算用户的充值指引：当前余额、被拒请求的预估费用及覆盖该费用所需的最低充值额（向上取整到分）
+func NewBalanceGuidance(c *gin.Context, userID int64, estimatedTokens int) *models.BalanceGuidance {
+	guidance := &models.BalanceGuidance{
+		EstimatedCost: database.CalculateCost(estimatedTokens),
+		Currency:      "USD",
+		RechargeURL:   RechargeURL(c),
+	}
+	if balance, err := database.GetUserBalance(userID); err == nil {
+		guidance.Balance = balance.Balance
+	} else if err != database.ErrBalanceNotFound {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to get balance for recharge guidance")
+	}
+
+	shortfall := guidance.EstimatedCost - guidance.Balance
+	guidance.MinimumTopUp = math.Max(math.Ceil(shortfall*100)/100, 0.01)
+	return guidance
+}
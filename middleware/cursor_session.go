@@ -521,6 +521,7 @@ func (csm *CursorSessionManager) GetStats() map[string]interface{} {
 		"total_usage":     totalUsage,
 		"current_index":   csm.currentIndex,
 		"fallback_active": len(csm.validSessions) == 0,
+		"affinity_count":  affinityCount(),
 	}
 }
 
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// degradedRetryAfterSec 数据库不可用时建议客户端的重试间隔
+const degradedRetryAfterSec = 30
+
+// DegradedGuard 数据库降级模式中间件
+// 数据库不可用时：/v1 请求在降级窗口内依靠缓存凭据继续服务，超出窗口后返回 503；
+// 其余依赖写库的请求（非 GET/HEAD/OPTIONS）直接返回 503 并附带重试提示
+func DegradedGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !database.IsDegraded() {
+			c.Next()
+			return
+		}
+
+		if strings.HasPrefix(c.Request.URL.Path, "/v1") {
+			if database.WithinDegradedWindow() {
+				c.Header("X-Degraded-Mode", "true")
+				c.Next()
+				return
+			}
+			abortDegraded(c)
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Header("X-Degraded-Mode", "true")
+			c.Next()
+		default:
+			abortDegraded(c)
+		}
+	}
+}
+
+func abortDegraded(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(degradedRetryAfterSec))
+	c.JSON(http.StatusServiceUnavailable, models.NewErrorResponse(
+		"服务处于只读降级模式，数据库暂时不可用，请稍后重试",
+		"service_unavailable",
+		"database_unavailable",
+	))
+	c.Abort()
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"Curry2API-go/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gemini API 支持的方法
+const (
+	GeminiGenerateContent       = "generateContent"
+	GeminiStreamGenerateContent = "streamGenerateContent"
+)
+
+// ParseGeminiAction 解析 /v1beta/models/*action 通配段，如 "/gemini-pro:streamGenerateContent"
+func ParseGeminiAction(action string) (model, method string, ok bool) {
+	action = strings.TrimPrefix(action, "/")
+	idx := strings.LastIndex(action, ":")
+	if idx <= 0 || idx == len(action)-1 {
+		return "", "", false
+	}
+	return action[:idx], action[idx+1:], true
+}
+
+// GeminiRequest Gemini API 前置中间件，需放在 AuthRequired 之前：
+// Google SDK 通过 x-goog-api-key 请求头或 key 查询参数传递密钥，未设置 Authorization 时转换为 Bearer 认证；
+// 模型在 URL 路径而非请求体中，解析后记录为 request_model 供超时与路由规则匹配
+func GeminiRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		model, method, ok := ParseGeminiAction(c.Param("action"))
+		if !ok || (method != GeminiGenerateContent && method != GeminiStreamGenerateContent) {
+			c.JSON(http.StatusNotFound, models.NewGeminiError(
+				http.StatusNotFound,
+				"Method not found: "+strings.TrimPrefix(c.Param("action"), "/"),
+				"NOT_FOUND",
+			))
+			c.Abort()
+			return
+		}
+		c.Set("request_model", model)
+
+		if c.GetHeader("Authorization") == "" {
+			key := c.GetHeader("x-goog-api-key")
+			if key == "" {
+				key = c.Query("key")
+			}
+			if key != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			}
+		}
+		c.Next()
+	}
+}
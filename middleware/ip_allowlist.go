@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"Curry2API-go/database"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxAllowedIPs 每个密钥 IP 白名单的条目上限
+const maxAllowedIPs = 100
+
+// NormalizeAllowedIPs 校验 IP 白名单并统一为 CIDR 格式：单个地址转换为 /32（IPv6 为 /128），
+// 去除空白与重复项，空列表表示不限制
+func NormalizeAllowedIPs(entries []string) ([]string, error) {
+	result := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var prefix netip.Prefix
+		if strings.Contains(entry, "/") {
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			prefix = p.Masked()
+		} else {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		cidr := prefix.String()
+		if seen[cidr] {
+			continue
+		}
+		seen[cidr] = true
+		result = append(result, cidr)
+	}
+	if len(result) > maxAllowedIPs {
+		return nil, fmt.Errorf("at most %d entries are allowed", maxAllowedIPs)
+	}
+	return result, nil
+}
+
+// ipAllowed 判断 ip 是否位于白名单内，空白名单允许任意地址
+func ipAllowed(allowedIPs []string, ip string) bool {
+	if len(allowedIPs) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, cidr := range allowedIPs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIPAllowed 判断密钥是否允许从 ip 调用
+func (km *KeyManager) IsIPAllowed(key, ip string) bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	info, exists := km.keys[key]
+	if !exists {
+		return false
+	}
+	return ipAllowed(info.AllowedIPs, ip)
+}
+
+// SetKeyAllowedIPs 设置密钥 IP 白名单（已规范化的 CIDR 列表），空列表表示不限制
+func (km *KeyManager) SetKeyAllowedIPs(key string, allowedIPs []string) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyAllowedIPs(key, allowedIPs); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key allowed IPs in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.AllowedIPs = allowedIPs
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key IP allowlist: %s (allowed_ips: %v)", maskKey(key), allowedIPs)
+	return nil
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"Curry2API-go/database"
+)
+
+func TestCheckUserState_RevocationWithinSameSecond(t *testing.T) {
+	second := time.Unix(1760000000, 0)
+	state := &database.UserAuthState{IsActive: true, TokensValidAfter: second.Add(500 * time.Millisecond)}
+
+	tests := []struct {
+		name     string
+		issuedAt float64
+		wantErr  error
+	}{
+		{"issued earlier in the same second", 1760000000.2, ErrJWTRevoked},
+		{"issued exactly at the cutoff", 1760000000.5, nil},
+		{"issued later in the same second", 1760000000.8, nil},
+		{"whole-second token from before", 1760000000, ErrJWTRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &JWTClaims{IssuedAt: tt.issuedAt}
+			if err := checkUserState(state, claims); err != tt.wantErr {
+				t.Errorf("checkUserState() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTClaims_IssuedAtRoundTrip(t *testing.T) {
+	claims, err := signToken(42, tokenTypeAccess, "family", time.Minute)
+	if err != nil {
+		t.Fatalf("signToken() error = %v", err)
+	}
+	data, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded JWTClaims
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !decoded.Issued().Equal(claims.Issued()) {
+		t.Errorf("Issued() after round trip = %v, want %v", decoded.Issued(), claims.Issued())
+	}
+	if drift := time.Since(decoded.Issued()); drift < 0 || drift > time.Second {
+		t.Errorf("Issued() = %v, not close to now", decoded.Issued())
+	}
+}
+
+func TestCheckUserState_ElevationCutoff(t *testing.T) {
+	cutoff := time.Unix(1760000000, 500*int64(time.Millisecond))
+	state := &database.UserAuthState{IsActive: true, ElevationValidAfter: cutoff}
+
+	tests := []struct {
+		name    string
+		claims  *JWTClaims
+		wantErr error
+	}{
+		{"elevated token from before exit", &JWTClaims{IssuedAt: 1760000000.2, ElevatedUntil: 1760000600}, ErrJWTRevoked},
+		{"plain token from before exit", &JWTClaims{IssuedAt: 1760000000.2}, nil},
+		{"elevated token after exit", &JWTClaims{IssuedAt: 1760000000.7, ElevatedUntil: 1760000600}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkUserState(state, tt.claims); err != tt.wantErr {
+				t.Errorf("checkUserState() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
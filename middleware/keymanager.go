@@ -156,6 +156,14 @@ func (km *KeyManager) loadKeysFromDB() error {
 			QuotaUsed:     k.QuotaUsed,
 			ExpiresAt:     k.ExpiresAt,
 			AllowedModels: k.AllowedModels,
+			PriorityTrusted: k.PriorityTrusted,
+			StreamFlushIntervalMs: k.StreamFlushIntervalMs,
+			StreamFlushBytes: k.StreamFlushBytes,
+			SigningSecret: k.SigningSecret,
+			SigningEnabled: k.SigningEnabled,
+			Tags:          k.Tags,
+			Scopes:        k.Scopes,
+			AllowedIPs:    k.AllowedIPs,
 		}
 	}
 
@@ -205,9 +213,18 @@ func (km *KeyManager) IsValidKey(key string) bool {
 		return false
 	}
 	
+	// 数据库不可用时，在降级窗口内使用缓存的密钥状态
+	if database.IsDegraded() {
+		return km.cachedKeyActive(key)
+	}
+	
 	// 检查数据库中的实时状态（包括用户状态）
 	isActive, err := database.IsKeyActiveWithUser(key)
 	if err != nil {
+		if database.IsConnectionError(err) {
+			database.ReportError(err)
+			return km.cachedKeyActive(key)
+		}
 		logrus.Warnf("Failed to check key status: %v", err)
 		return false
 	}
@@ -215,11 +232,31 @@ func (km *KeyManager) IsValidKey(key string) bool {
 	return isActive
 }
 
+// cachedKeyActive 降级模式下根据内存缓存判断密钥是否可用
+func (km *KeyManager) cachedKeyActive(key string) bool {
+	if !database.WithinDegradedWindow() {
+		return false
+	}
+
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	info, exists := km.keys[key]
+	if !exists || !info.IsActive {
+		return false
+	}
+	if info.ExpiresAt != nil && time.Now().After(*info.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
 // IncrementUsage 增加密钥使用次数
 func (km *KeyManager) IncrementUsage(key string) {
 	km.mu.Lock()
 	if info, exists := km.keys[key]; exists {
 		info.UsageCount++
+		now := time.Now()
+		info.LastUsedAt = &now
 		km.mu.Unlock()
 
 		// 异步更新数据库，减少请求阻塞
@@ -345,11 +382,31 @@ func (km *KeyManager) ListKeys() []*KeyInfo {
 			QuotaUsed:     info.QuotaUsed,
 			ExpiresAt:     info.ExpiresAt,
 			AllowedModels: info.AllowedModels,
+			PriorityTrusted: info.PriorityTrusted,
+			StreamFlushIntervalMs: info.StreamFlushIntervalMs,
+			StreamFlushBytes: info.StreamFlushBytes,
+			SigningEnabled: info.SigningEnabled,
+			Tags:          info.Tags,
+			Scopes:        info.Scopes,
+			AllowedIPs:    info.AllowedIPs,
 		})
 	}
 	return result
 }
 
+// GetKeyInfo 获取单个密钥信息的副本
+func (km *KeyManager) GetKeyInfo(key string) (*KeyInfo, bool) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	info, exists := km.keys[key]
+	if !exists {
+		return nil, false
+	}
+	copied := *info
+	return &copied, true
+}
+
 // ToggleKeyStatus 切换密钥的启用/禁用状态
 func (km *KeyManager) ToggleKeyStatus(key string) error {
 	km.mu.Lock()
@@ -405,6 +462,13 @@ func (km *KeyManager) ListKeysByUser(userID int64) []*KeyInfo {
 				QuotaUsed:     info.QuotaUsed,
 				ExpiresAt:     info.ExpiresAt,
 				AllowedModels: info.AllowedModels,
+				PriorityTrusted: info.PriorityTrusted,
+				StreamFlushIntervalMs: info.StreamFlushIntervalMs,
+				StreamFlushBytes: info.StreamFlushBytes,
+				SigningEnabled: info.SigningEnabled,
+				Tags:          info.Tags,
+				Scopes:        info.Scopes,
+				AllowedIPs:    info.AllowedIPs,
 			})
 		}
 	}
@@ -440,6 +504,221 @@ func (km *KeyManager) UpdateKeyName(key, name string) error {
 	return nil
 }
 
+// SetPriorityTrusted 设置密钥是否允许使用 X-Priority 请求头
+func (km *KeyManager) SetPriorityTrusted(key string, trusted bool) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyPriorityTrusted(key, trusted); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key priority in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.PriorityTrusted = trusted
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key priority trust: %s (trusted: %v)", maskKey(key), trusted)
+	return nil
+}
+
+// SetKeyExpiration 设置密钥过期时间，nil 表示永不过期
+func (km *KeyManager) SetKeyExpiration(key string, expiresAt *time.Time) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyExpiration(key, expiresAt); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key expiration in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.ExpiresAt = expiresAt
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key expiration: %s (expires_at: %v)", maskKey(key), expiresAt)
+	return nil
+}
+
+// GetKeyQuota 返回密钥的额度上限（nil 表示不限）与已用额度，并以数据库中的值刷新缓存；
+// 数据库不可用时返回缓存中的值
+func (km *KeyManager) GetKeyQuota(key string) (*float64, float64, error) {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return nil, 0, ErrKeyNotFound
+	}
+
+	if database.IsDegraded() {
+		km.mu.RLock()
+		defer km.mu.RUnlock()
+		return info.QuotaLimit, info.QuotaUsed, nil
+	}
+
+	limit, used, err := database.GetTokenQuotaInfo(key)
+	if err != nil {
+		if err == database.ErrKeyNotFound {
+			return nil, 0, ErrKeyNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to get key quota from database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.QuotaLimit = limit
+	info.QuotaUsed = used
+	km.mu.Unlock()
+	return limit, used, nil
+}
+
+// AddKeyQuotaUsed 在缓存中累加密钥的已用额度，数据库中的 quota_used 由计费流程更新
+func (km *KeyManager) AddKeyQuotaUsed(key string, amount float64) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	if info, exists := km.keys[key]; exists {
+		info.QuotaUsed += amount
+	}
+}
+
+// ResetKeyQuota 将密钥的已用额度清零，超出额度的密钥随即恢复可用
+func (km *KeyManager) ResetKeyQuota(key string) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.ResetAPIKeyQuotaUsed(key); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to reset key quota in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.QuotaUsed = 0
+	km.mu.Unlock()
+
+	logrus.Infof("Reset API key quota usage: %s", maskKey(key))
+	return nil
+}
+
+// SetKeyTags 设置密钥标签（供路由规则匹配），空列表表示清除
+func (km *KeyManager) SetKeyTags(key string, tags []string) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyTags(key, tags); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key tags in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.Tags = tags
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key tags: %s (tags: %v)", maskKey(key), tags)
+	return nil
+}
+
+// GetKeyTags 返回密钥的标签
+func (km *KeyManager) GetKeyTags(key string) []string {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	if info, exists := km.keys[key]; exists {
+		return info.Tags
+	}
+	return nil
+}
+
+// ResolvePriority 根据密钥信任配置解析请求优先级
+// 只有受信任的密钥发送 X-Priority: high 时才会被提升，其余请求一律为 normal
+func (km *KeyManager) ResolvePriority(key, header string) string {
+	if !strings.EqualFold(strings.TrimSpace(header), PriorityHigh) {
+		return PriorityNormal
+	}
+
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	trusted := exists && info.PriorityTrusted
+	km.mu.RUnlock()
+
+	if !trusted {
+		logrus.Debugf("Ignoring X-Priority header from untrusted key %s", maskKey(key))
+		return PriorityNormal
+	}
+	return PriorityHigh
+}
+
+// defaultStreamFlush 服务器默认的 SSE 合并策略
+var defaultStreamFlush models.StreamFlushSettings
+
+// ConfigureStreamFlush 设置服务器默认的 SSE 合并策略（0 表示每个事件立即刷新）
+func ConfigureStreamFlush(intervalMs, bytes int) {
+	defaultStreamFlush = models.StreamFlushSettings{IntervalMs: intervalMs, Bytes: bytes}
+}
+
+// SetStreamFlush 设置密钥的 SSE 合并策略覆盖值，nil 表示使用服务器默认值
+func (km *KeyManager) SetStreamFlush(key string, intervalMs, bytes *int) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyStreamFlush(key, intervalMs, bytes); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key streaming settings in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.StreamFlushIntervalMs = intervalMs
+	info.StreamFlushBytes = bytes
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key streaming settings: %s", maskKey(key))
+	return nil
+}
+
+// ResolveStreamFlush 解析密钥生效的 SSE 合并策略（密钥覆盖值优先于服务器默认值）
+func (km *KeyManager) ResolveStreamFlush(key string) models.StreamFlushSettings {
+	settings := defaultStreamFlush
+
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	if info, exists := km.keys[key]; exists {
+		if info.StreamFlushIntervalMs != nil {
+			settings.IntervalMs = *info.StreamFlushIntervalMs
+		}
+		if info.StreamFlushBytes != nil {
+			settings.Bytes = *info.StreamFlushBytes
+		}
+	}
+	return settings
+}
+
 // ============================================
 // Balance and Token Validation Functions
 // Requirements: 3.2, 12.4, 13.3, 14.3
@@ -463,6 +742,11 @@ func (km *KeyManager) CheckBalanceStatus(key string) error {
 		return nil
 	}
 	
+	// Skip the live balance check while the database is down
+	if database.IsDegraded() {
+		return nil
+	}
+	
 	// Check user's balance status from database
 	balance, err := database.GetUserBalance(*keyInfo.UserID)
 	if err != nil {
@@ -488,6 +772,10 @@ func (km *KeyManager) CheckBalanceStatus(key string) error {
 // Returns nil if quota is OK or unlimited, ErrTokenQuotaExceeded if quota is exceeded
 // Requirements: 12.4
 func (km *KeyManager) CheckTokenQuota(key string) error {
+	if database.IsDegraded() {
+		return nil // Quota usage is reconciled when the billing journal is replayed
+	}
+
 	canUse, err := database.CheckTokenQuota(key)
 	if err != nil {
 		if err == database.ErrKeyNotFound {
@@ -512,6 +800,10 @@ func (km *KeyManager) CheckTokenQuota(key string) error {
 // Returns nil if token is valid or has no expiration, ErrTokenExpired if expired
 // Requirements: 13.3
 func (km *KeyManager) CheckTokenExpiration(key string) error {
+	if database.IsDegraded() {
+		return nil // Expiration already checked against the cache in IsValidKey
+	}
+
 	canUse, err := database.CheckTokenExpiration(key)
 	if err != nil {
 		if err == database.ErrKeyNotFound {
@@ -536,6 +828,25 @@ func (km *KeyManager) CheckTokenExpiration(key string) error {
 // Returns nil if model is allowed or no restrictions, ErrModelNotAllowed if not allowed
 // Requirements: 14.3
 func (km *KeyManager) CheckTokenModelAccess(key, model string) error {
+	// Fall back to the cached allow-list while the database is down
+	if database.IsDegraded() {
+		km.mu.RLock()
+		defer km.mu.RUnlock()
+		info, exists := km.keys[key]
+		if !exists {
+			return ErrKeyNotFound
+		}
+		if len(info.AllowedModels) == 0 {
+			return nil
+		}
+		for _, allowed := range info.AllowedModels {
+			if allowed == model {
+				return nil
+			}
+		}
+		return ErrModelNotAllowed
+	}
+
 	canUse, err := database.CheckTokenModelAccess(key, model)
 	if err != nil {
 		if err == database.ErrKeyNotFound {
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"Curry2API-go/utils"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+var languageDetectionEnabled atomic.Bool
+
+// ConfigureLanguageDetection 设置是否检测提示词语言（记录到用量，并供路由规则匹配）
+func ConfigureLanguageDetection(enabled bool) {
+	languageDetectionEnabled.Store(enabled)
+}
+
+// LanguageDetectionEnabled 是否启用提示词语言检测
+func LanguageDetectionEnabled() bool {
+	return languageDetectionEnabled.Load()
+}
+
+// DetectPromptLanguage 检测请求体中最后一条用户消息的语言，未启用检测或无法提取文本时返回空字符串。
+// 支持 OpenAI Chat（messages）、Anthropic Messages、Responses（input）、Gemini（contents）与补全（prompt）格式
+func DetectPromptLanguage(body []byte) string {
+	if !LanguageDetectionEnabled() {
+		return ""
+	}
+	text := extractPromptText(body)
+	if text == "" {
+		return ""
+	}
+	lang, _ := utils.DetectLanguage(text)
+	return lang
+}
+
+// LanguageDetection 提示词语言检测中间件，需放在 AuthRequired 之后、RoutingRules 之前：
+// 检测结果记录为 request_language，供路由规则匹配与用量记录
+func LanguageDetection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !LanguageDetectionEnabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err == nil {
+			if lang := DetectPromptLanguage(body); lang != "" {
+				c.Set("request_language", lang)
+			}
+		}
+		c.Next()
+	}
+}
+
+// promptPart 消息内容块，兼容 OpenAI/Anthropic 的 text 与 Gemini 的 parts
+type promptPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// promptMessage 各格式消息的公共字段
+type promptMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	Parts   []promptPart    `json:"parts"`
+}
+
+// extractPromptText 提取请求体中最后一条用户消息的文本
+func extractPromptText(body []byte) string {
+	var req struct {
+		Messages []promptMessage `json:"messages"`
+		Contents []promptMessage `json:"contents"`
+		Input    json.RawMessage `json:"input"`
+		Prompt   json.RawMessage `json:"prompt"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+
+	if len(req.Contents) > 0 {
+		for i := len(req.Contents) - 1; i >= 0; i-- {
+			msg := req.Contents[i]
+			if msg.Role != "" && msg.Role != "user" {
+				continue
+			}
+			texts := make([]string, 0, len(msg.Parts))
+			for _, part := range msg.Parts {
+				texts = append(texts, part.Text)
+			}
+			return strings.Join(texts, "\n")
+		}
+		return ""
+	}
+
+	messages := req.Messages
+	if len(messages) == 0 && len(req.Input) > 0 {
+		// Responses 接口的 input 可以是字符串或消息列表
+		if text := rawText(req.Input); text != "" {
+			return text
+		}
+		json.Unmarshal(req.Input, &messages)
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return rawText(messages[i].Content)
+		}
+	}
+	return rawText(req.Prompt)
+}
+
+// rawText 提取字符串内容，或内容块列表中的文本块
+func rawText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var parts []promptPart
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" || part.Type == "input_text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLatencySamplesPerKey 每个 endpoint/model 在一个窗口内最多保留的样本数
+const maxLatencySamplesPerKey = 5000
+
+// LatencySample 单个请求的延迟样本
+type LatencySample struct {
+	TTFT  time.Duration // 首字节时间
+	Total time.Duration // 完整响应时间
+}
+
+// LatencyKey 延迟样本的分组键
+type LatencyKey struct {
+	Endpoint string
+	Model    string
+}
+
+var (
+	latencyMu      sync.Mutex
+	latencySamples = make(map[LatencyKey][]LatencySample)
+)
+
+// RecordLatency 记录一个延迟样本到当前窗口
+func RecordLatency(endpoint, model string, sample LatencySample) {
+	key := LatencyKey{Endpoint: endpoint, Model: model}
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	if len(latencySamples[key]) < maxLatencySamplesPerKey {
+		latencySamples[key] = append(latencySamples[key], sample)
+	}
+}
+
+// TakeLatencySamples 取出当前窗口的全部样本并开始新窗口
+func TakeLatencySamples() map[LatencyKey][]LatencySample {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	samples := latencySamples
+	latencySamples = make(map[LatencyKey][]LatencySample)
+	return samples
+}
+
+// latencyWriter 记录第一次写入响应体的时间
+type latencyWriter struct {
+	gin.ResponseWriter
+	firstWrite time.Time
+}
+
+func (w *latencyWriter) markFirstWrite() {
+	if w.firstWrite.IsZero() {
+		w.firstWrite = time.Now()
+	}
+}
+
+func (w *latencyWriter) Write(data []byte) (int, error) {
+	w.markFirstWrite()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *latencyWriter) WriteString(s string) (int, error) {
+	w.markFirstWrite()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// LatencyRecorder 记录成功请求的首字节时间与总耗时，供延迟预算评估使用
+// 模型取自处理器设置的 request_model
+func LatencyRecorder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		writer := &latencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if c.Writer.Status() >= 400 || writer.firstWrite.IsZero() {
+			return
+		}
+		model, _ := c.Get("request_model")
+		modelName, _ := model.(string)
+		RecordLatency(c.FullPath(), modelName, LatencySample{
+			TTFT:  writer.firstWrite.Sub(start),
+			Total: time.Since(start),
+		})
+	}
+}
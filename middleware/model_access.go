@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+
+	"Curry2API-go/database"
+
+	"github.com/sirupsen/logrus"
+)
+
+// modelScopes 请求中带有模型（请求体的 model 字段或 Gemini 路径）的端点所属的作用域
+var modelScopes = map[string]bool{
+	ScopeChat:        true,
+	ScopeEmbeddings:  true,
+	ScopeImages:      true,
+	ScopeAudio:       true,
+	ScopeModerations: true,
+}
+
+// HasModelRestriction 判断密钥是否设置了模型白名单
+func (km *KeyManager) HasModelRestriction(key string) bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	info, exists := km.keys[key]
+	return exists && len(info.AllowedModels) > 0
+}
+
+// SetKeyAllowedModels 设置密钥的模型白名单，空列表表示可调用全部模型
+func (km *KeyManager) SetKeyAllowedModels(key string, allowedModels []string) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyAllowedModels(key, allowedModels); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key allowed models in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.AllowedModels = allowedModels
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key allowed models: %s (models: %v)", maskKey(key), allowedModels)
+	return nil
+}
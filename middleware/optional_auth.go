@@ -27,6 +27,7 @@ func OptionalAuth(defaultKey string) gin.HandlerFunc {
 			if defaultKey != "" && km.IsValidKey(defaultKey) {
 				km.IncrementUsage(defaultKey)
 				c.Set("api_key", defaultKey)
+				c.Set("stream_flush", km.ResolveStreamFlush(defaultKey))
 				c.Next()
 				return
 			}
@@ -46,11 +47,13 @@ func OptionalAuth(defaultKey string) gin.HandlerFunc {
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
-		if km.IsValidKey(token) {
+		if km.IsValidKey(token) && !km.RequiresSignature(token) {
 			km.IncrementUsage(token)
 			c.Set("api_key", token)
+			c.Set("stream_flush", km.ResolveStreamFlush(token))
 			logrus.Debug("Authorization successful with provided token")
 		} else {
+			// 启用请求签名的密钥须使用 AuthRequired 路由，这里按无效令牌处理
 			logrus.Debug("Provided token is invalid, proceeding anyway")
 		}
 
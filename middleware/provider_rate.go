@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rateWindow 提供商 RPM/TPM 限额的统计窗口
+const rateWindow = time.Minute
+
+// ProviderRateLimitError 提供商 RPM/TPM 限额已满且在最长排队时间内无法放行
+type ProviderRateLimitError struct {
+	Provider   string
+	Limit      string // "rpm" 或 "tpm"
+	RetryAfter time.Duration
+}
+
+func (e *ProviderRateLimitError) Error() string {
+	return fmt.Sprintf("%s %s limit reached, retry after %s", e.Provider, e.Limit, e.RetryAfter)
+}
+
+// RetryAfterSeconds 向上取整到秒，至少 1 秒，用于 Retry-After 头
+func (e *ProviderRateLimitError) RetryAfterSeconds() int {
+	secs := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
+// tokenSample 一次请求结束时计入的 token 数
+type tokenSample struct {
+	at     time.Time
+	tokens int
+}
+
+// ProviderRateLimiter 单个提供商的滑动窗口限额：RPM 按请求开始计数，
+// TPM 按请求结束后的实际 token 用量计数（窗口已满时后续请求等待旧用量过期）
+type ProviderRateLimiter struct {
+	mu       sync.Mutex
+	rpm      int // 0 表示不限制
+	tpm      int // 0 表示不限制
+	requests []time.Time
+	tokens   []tokenSample
+	used     int // 窗口内的 token 总数
+}
+
+// NewProviderRateLimiter 创建限额器，rpm/tpm <= 0 表示不限制
+func NewProviderRateLimiter(rpm, tpm int) *ProviderRateLimiter {
+	return &ProviderRateLimiter{rpm: rpm, tpm: tpm}
+}
+
+// pruneLocked 丢弃窗口外的记录，调用方须持有锁
+func (l *ProviderRateLimiter) pruneLocked(now time.Time) {
+	cutoff := now.Add(-rateWindow)
+	i := 0
+	for i < len(l.requests) && !l.requests[i].After(cutoff) {
+		i++
+	}
+	l.requests = l.requests[i:]
+
+	i = 0
+	for i < len(l.tokens) && !l.tokens[i].at.After(cutoff) {
+		l.used -= l.tokens[i].tokens
+		i++
+	}
+	l.tokens = l.tokens[i:]
+}
+
+// reserve 窗口有余量时占用一次请求并返回 0；否则返回需要等待的时长和触发的限额
+func (l *ProviderRateLimiter) reserve(now time.Time) (time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pruneLocked(now)
+
+	if l.rpm > 0 && len(l.requests) >= l.rpm {
+		return l.requests[len(l.requests)-l.rpm].Add(rateWindow).Sub(now), "rpm"
+	}
+	if l.tpm > 0 && l.used >= l.tpm {
+		// 等到足够多的旧用量过期，窗口内用量低于上限
+		excess := l.used - l.tpm
+		for _, sample := range l.tokens {
+			excess -= sample.tokens
+			if excess < 0 {
+				return sample.at.Add(rateWindow).Sub(now), "tpm"
+			}
+		}
+	}
+	l.requests = append(l.requests, now)
+	return 0, ""
+}
+
+// Wait 等待限额放行，最长等待 maxWait；超过时返回 ProviderRateLimitError
+func (l *ProviderRateLimiter) Wait(ctx context.Context, provider string, maxWait time.Duration) error {
+	if l == nil || (l.rpm <= 0 && l.tpm <= 0) {
+		return nil
+	}
+	deadline := time.Now().Add(maxWait)
+	for {
+		now := time.Now()
+		wait, limit := l.reserve(now)
+		if wait <= 0 {
+			return nil
+		}
+		if now.Add(wait).After(deadline) {
+			return &ProviderRateLimitError{Provider: provider, Limit: limit, RetryAfter: wait}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// RecordTokens 计入一次请求的实际 token 用量
+func (l *ProviderRateLimiter) RecordTokens(tokens int) {
+	if l == nil || l.tpm <= 0 || tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.pruneLocked(now)
+	l.tokens = append(l.tokens, tokenSample{at: now, tokens: tokens})
+	l.used += tokens
+}
+
+var (
+	providerRateLimiters   = make(map[string]*ProviderRateLimiter)
+	providerRateLimitersMu sync.RWMutex
+	providerRateMaxWait    = 5 * time.Second
+)
+
+// ConfigureProviderRateLimits 设置各提供商的 RPM/TPM 限额
+// rpmSpec、tpmSpec 格式为 "cursor=60,openai=500"，未列出的提供商不限制；
+// 限额已满时请求最多排队 maxWait，仍无法放行则返回 429
+func ConfigureProviderRateLimits(rpmSpec, tpmSpec string, maxWait time.Duration) {
+	rpm := parseProviderLimits(rpmSpec, "RPM")
+	tpm := parseProviderLimits(tpmSpec, "TPM")
+
+	providerRateLimitersMu.Lock()
+	defer providerRateLimitersMu.Unlock()
+
+	if maxWait >= 0 {
+		providerRateMaxWait = maxWait
+	}
+	for name := range rpm {
+		providerRateLimiters[name] = NewProviderRateLimiter(rpm[name], tpm[name])
+	}
+	for name := range tpm {
+		if _, ok := rpm[name]; !ok {
+			providerRateLimiters[name] = NewProviderRateLimiter(0, tpm[name])
+		}
+	}
+	for name, limiter := range providerRateLimiters {
+		logrus.Infof("Provider rate limit: %s rpm=%d tpm=%d", name, limiter.rpm, limiter.tpm)
+	}
+}
+
+// parseProviderLimits 解析 "name=limit" 列表，忽略无效项
+func parseProviderLimits(spec, kind string) map[string]int {
+	limits := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			logrus.Warnf("Ignoring invalid provider %s limit: %q", kind, part)
+			continue
+		}
+		limits[strings.ToLower(strings.TrimSpace(name))] = limit
+	}
+	return limits
+}
+
+// waitProviderRate 等待提供商的 RPM/TPM 限额放行
+func waitProviderRate(ctx context.Context, provider string) error {
+	providerRateLimitersMu.RLock()
+	limiter := providerRateLimiters[strings.ToLower(provider)]
+	maxWait := providerRateMaxWait
+	providerRateLimitersMu.RUnlock()
+	return limiter.Wait(ctx, provider, maxWait)
+}
+
+// RecordProviderTokens 将请求的实际 token 用量计入提供商的 TPM 窗口
+func RecordProviderTokens(provider string, tokens int) {
+	providerRateLimitersMu.RLock()
+	limiter := providerRateLimiters[strings.ToLower(provider)]
+	providerRateLimitersMu.RUnlock()
+	limiter.RecordTokens(tokens)
+}
@@ -0,0 +1,339 @@
+package middleware
+
+import (
+	"Curry2API-go/models"
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 请求优先级
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+)
+
+var (
+	ErrQueueTimeout = errors.New("timed out waiting for a free request slot")
+)
+
+// defaultSlotHold 尚无观测数据时假定的单个请求占用槽位时长，用于估算排队等待时间
+const defaultSlotHold = 5 * time.Second
+
+// queueProgressInterval 排队期间检查位置变化的间隔
+const queueProgressInterval = time.Second
+
+// QueueStatus 排队中请求的位置与预计等待时间
+type QueueStatus struct {
+	Position      int           `json:"position"`
+	EstimatedWait time.Duration `json:"-"`
+}
+
+// RetryAfterSeconds 预计等待时间（向上取整到秒，至少 1 秒），用于 Retry-After 头
+func (q QueueStatus) RetryAfterSeconds() int {
+	secs := int((q.EstimatedWait + time.Second - 1) / time.Second)
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
+// PriorityScheduler 优先级感知的并发调度器
+// 并发数达到上限时请求排队等待，空出的槽位优先分配给 high 优先级的请求
+type PriorityScheduler struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	high     *list.List
+	normal   *list.List
+	avgHold  time.Duration // 槽位占用时长的指数移动平均
+}
+
+// NewPriorityScheduler 创建调度器，limit <= 0 表示不限制并发
+func NewPriorityScheduler(limit int) *PriorityScheduler {
+	return &PriorityScheduler{
+		limit:  limit,
+		high:   list.New(),
+		normal: list.New(),
+	}
+}
+
+// Acquire 获取一个执行槽位，阻塞直到获得槽位或 ctx 结束
+func (s *PriorityScheduler) Acquire(ctx context.Context, priority string) error {
+	return s.AcquireWithProgress(ctx, priority, nil)
+}
+
+// AcquireWithProgress 同 Acquire；需要排队时先以当前位置调用 onQueued，之后位置变化时再次调用
+func (s *PriorityScheduler) AcquireWithProgress(ctx context.Context, priority string, onQueued func(QueueStatus)) error {
+	if s == nil || s.limit <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.inFlight < s.limit && s.high.Len() == 0 && (priority == PriorityHigh || s.normal.Len() == 0) {
+		s.inFlight++
+		s.mu.Unlock()
+		return nil
+	}
+
+	queue := s.normal
+	if priority == PriorityHigh {
+		queue = s.high
+	}
+	ready := make(chan struct{})
+	elem := queue.PushBack(ready)
+	status := s.statusLocked(elem, priority)
+	s.mu.Unlock()
+
+	var progress <-chan time.Time
+	if onQueued != nil {
+		onQueued(status)
+		ticker := time.NewTicker(queueProgressInterval)
+		defer ticker.Stop()
+		progress = ticker.C
+	}
+
+	for {
+		select {
+		case <-ready:
+			return nil
+		case <-progress:
+			s.mu.Lock()
+			select {
+			case <-ready:
+				s.mu.Unlock()
+				return nil
+			default:
+			}
+			current := s.statusLocked(elem, priority)
+			s.mu.Unlock()
+			if current.Position != status.Position {
+				status = current
+				onQueued(status)
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			select {
+			case <-ready:
+				// 槽位已经分配给了本请求，直接归还
+				s.mu.Unlock()
+				s.Release()
+			default:
+				queue.Remove(elem)
+				s.mu.Unlock()
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// statusLocked 计算排队元素的位置（high 队列始终排在 normal 之前），调用方须持有锁
+func (s *PriorityScheduler) statusLocked(elem *list.Element, priority string) QueueStatus {
+	position := 1
+	if priority != PriorityHigh {
+		position += s.high.Len()
+	}
+	queue := s.normal
+	if priority == PriorityHigh {
+		queue = s.high
+	}
+	for e := queue.Front(); e != nil && e != elem; e = e.Next() {
+		position++
+	}
+	return QueueStatus{Position: position, EstimatedWait: s.estimateLocked(position)}
+}
+
+// estimateLocked 按平均占用时长估算第 position 位的等待时间：每一轮释放 limit 个槽位
+func (s *PriorityScheduler) estimateLocked(position int) time.Duration {
+	hold := s.avgHold
+	if hold <= 0 {
+		hold = defaultSlotHold
+	}
+	rounds := (position + s.limit - 1) / s.limit
+	return time.Duration(rounds) * hold
+}
+
+// Estimate 返回新请求此时加入队列的位置与预计等待时间
+func (s *PriorityScheduler) Estimate(priority string) QueueStatus {
+	if s == nil || s.limit <= 0 {
+		return QueueStatus{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	position := s.high.Len() + 1
+	if priority != PriorityHigh {
+		position += s.normal.Len()
+	}
+	return QueueStatus{Position: position, EstimatedWait: s.estimateLocked(position)}
+}
+
+// releaseFunc 返回归还槽位的函数，并记录本次占用时长用于等待时间估算
+func (s *PriorityScheduler) releaseFunc() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.observeHold(time.Since(start))
+			s.Release()
+		})
+	}
+}
+
+// observeHold 以 EWMA（权重 0.2）更新平均占用时长
+func (s *PriorityScheduler) observeHold(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.avgHold <= 0 {
+		s.avgHold = d
+		return
+	}
+	s.avgHold = (s.avgHold*4 + d) / 5
+}
+
+// Release 归还槽位，并唤醒下一个等待者（high 优先）
+func (s *PriorityScheduler) Release() {
+	if s == nil || s.limit <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queue := range []*list.List{s.high, s.normal} {
+		if front := queue.Front(); front != nil {
+			queue.Remove(front)
+			close(front.Value.(chan struct{}))
+			return
+		}
+	}
+	if s.inFlight > 0 {
+		s.inFlight--
+	}
+}
+
+// Stats 返回当前执行中和排队中的请求数
+func (s *PriorityScheduler) Stats() (inFlight, queuedHigh, queuedNormal int) {
+	if s == nil {
+		return 0, 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight, s.high.Len(), s.normal.Len()
+}
+
+var (
+	providerSchedulers   = make(map[string]*PriorityScheduler)
+	providerSchedulersMu sync.RWMutex
+	qosQueueTimeout      = 30 * time.Second
+)
+
+// ConfigureProviderLimits 设置各提供商的并发上限
+// spec 格式为 "cursor=8,openai=4"，未列出的提供商不限制
+func ConfigureProviderLimits(spec string, queueTimeout time.Duration) {
+	providerSchedulersMu.Lock()
+	defer providerSchedulersMu.Unlock()
+
+	if queueTimeout > 0 {
+		qosQueueTimeout = queueTimeout
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			logrus.Warnf("Ignoring invalid provider concurrency limit: %q", part)
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		providerSchedulers[name] = NewPriorityScheduler(limit)
+		logrus.Infof("Provider concurrency limit: %s=%d", name, limit)
+	}
+}
+
+// AcquireProviderSlot 获取指定提供商的执行槽位，返回的函数用于归还槽位
+func AcquireProviderSlot(ctx context.Context, provider, priority string) (func(), error) {
+	return AcquireProviderSlotWithProgress(ctx, provider, priority, nil)
+}
+
+// AcquireProviderSlotWithProgress 同 AcquireProviderSlot，排队时通过 onQueued 报告位置与预计等待时间
+// 先等待提供商的 RPM/TPM 限额放行（超过最长排队时间返回 *ProviderRateLimitError），再获取并发槽位
+func AcquireProviderSlotWithProgress(ctx context.Context, provider, priority string, onQueued func(QueueStatus)) (func(), error) {
+	if err := waitProviderRate(ctx, provider); err != nil {
+		return nil, err
+	}
+
+	providerSchedulersMu.RLock()
+	scheduler := providerSchedulers[strings.ToLower(provider)]
+	timeout := qosQueueTimeout
+	providerSchedulersMu.RUnlock()
+
+	if scheduler == nil {
+		return func() {}, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := scheduler.AcquireWithProgress(waitCtx, priority, onQueued); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrQueueTimeout
+		}
+		return nil, err
+	}
+	return scheduler.releaseFunc(), nil
+}
+
+// EstimateProviderWait 返回新请求在指定提供商队列中的位置与预计等待时间
+func EstimateProviderWait(provider, priority string) QueueStatus {
+	providerSchedulersMu.RLock()
+	scheduler := providerSchedulers[strings.ToLower(provider)]
+	providerSchedulersMu.RUnlock()
+	return scheduler.Estimate(priority)
+}
+
+// GetRequestPriority 获取 AuthRequired 解析出的请求优先级
+func GetRequestPriority(c *gin.Context) string {
+	if priority := c.GetString("request_priority"); priority != "" {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// QoS 全局并发调度中间件，需放在 AuthRequired 之后以读取请求优先级
+func QoS(maxConcurrent int, queueTimeout time.Duration) gin.HandlerFunc {
+	scheduler := NewPriorityScheduler(maxConcurrent)
+
+	return func(c *gin.Context) {
+		if maxConcurrent <= 0 {
+			c.Next()
+			return
+		}
+
+		priority := GetRequestPriority(c)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), queueTimeout)
+		err := scheduler.AcquireWithProgress(ctx, priority, QueueFeedback(c, RequestWantsStream(c)))
+		cancel()
+		if err != nil {
+			logrus.WithField("priority", priority).Warn("QoS queue wait timed out")
+			WriteQueueTimeout(c, scheduler.Estimate(priority), models.NewErrorResponse(
+				"服务繁忙，请稍后重试",
+				"server_overloaded",
+				"queue_timeout",
+			))
+			c.Abort()
+			return
+		}
+		defer scheduler.releaseFunc()()
+
+		c.Next()
+	}
+}
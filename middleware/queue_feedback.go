@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"Curry2API-go/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queueStatusEvent 排队期间推送给流式请求的 SSE 事件（event: queue_status），客户端可忽略未知事件
+type queueStatusEvent struct {
+	Status               string `json:"status"`
+	Position             int    `json:"position"`
+	EstimatedWaitSeconds int    `json:"estimated_wait_seconds"`
+}
+
+// RequestWantsStream 读取 JSON 请求体中的 stream 字段（请求体会被还原，供后续处理器读取）
+func RequestWantsStream(c *gin.Context) bool {
+	if c.Request.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || !bytes.Contains(body, []byte(`"stream"`)) {
+		return false
+	}
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &req) == nil && req.Stream
+}
+
+// QueueFeedback 返回排队回调：流式请求在排队时立即开始 SSE 响应，并在位置变化时推送 queue_status 事件
+// 非流式请求返回 nil（排队超时时通过 Retry-After 告知预计等待时间）
+func QueueFeedback(c *gin.Context, stream bool) func(QueueStatus) {
+	if !stream {
+		return nil
+	}
+	return func(status QueueStatus) {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "text/event-stream; charset=utf-8")
+			c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+		}
+		data, err := json.Marshal(queueStatusEvent{
+			Status:               "queued",
+			Position:             status.Position,
+			EstimatedWaitSeconds: status.RetryAfterSeconds(),
+		})
+		if err != nil {
+			return
+		}
+		utils.WriteSSEEvent(c.Writer, "queue_status", string(data))
+	}
+}
+
+// WriteQueueTimeout 返回排队超时错误：已开始 SSE 响应时以 error 事件结束流，否则返回 503 与预计的 Retry-After
+func WriteQueueTimeout(c *gin.Context, status QueueStatus, errorResponse interface{}) {
+	if c.Writer.Written() {
+		if data, err := json.Marshal(errorResponse); err == nil {
+			utils.WriteSSEEvent(c.Writer, "error", string(data))
+		}
+		return
+	}
+	c.Header("Retry-After", strconv.Itoa(status.RetryAfterSeconds()))
+	c.JSON(http.StatusServiceUnavailable, errorResponse)
+}
+
+// WriteProviderSlotError 返回获取提供商槽位失败的错误：RPM/TPM 限额已满时返回 429 与 Retry-After，
+// 排队超时时同 WriteQueueTimeout
+func WriteProviderSlotError(c *gin.Context, err error, status QueueStatus, errorResponse, rateLimitResponse interface{}) {
+	var rateErr *ProviderRateLimitError
+	if !errors.As(err, &rateErr) {
+		WriteQueueTimeout(c, status, errorResponse)
+		return
+	}
+	if c.Writer.Written() {
+		if data, err := json.Marshal(rateLimitResponse); err == nil {
+			utils.WriteSSEEvent(c.Writer, "error", string(data))
+		}
+		return
+	}
+	c.Header("Retry-After", strconv.Itoa(rateErr.RetryAfterSeconds()))
+	c.JSON(http.StatusTooManyRequests, rateLimitResponse)
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestTimeoutHeader 客户端可通过该请求头（秒）在管理员设定的上下限内覆盖本次请求的超时；
+// 响应中同名头返回实际采用的超时
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// requestTimeouts 当前生效的请求超时配置
+var requestTimeouts = struct {
+	mu  sync.RWMutex
+	cfg database.RequestTimeoutConfig
+}{cfg: database.RequestTimeoutConfig{Default: 300, Min: 10, Max: 1800, Models: map[string]int{}}}
+
+// ParseModelTimeouts 解析按模型的超时配置，spec 格式为 "o1*=900,deepseek-reasoner=600"（秒）
+func ParseModelTimeouts(spec string) map[string]int {
+	timeouts := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		model, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || seconds <= 0 {
+			logrus.Warnf("Ignoring invalid model timeout: %q", part)
+			continue
+		}
+		timeouts[strings.ToLower(strings.TrimSpace(model))] = seconds
+	}
+	return timeouts
+}
+
+// ValidateRequestTimeoutConfig 校验请求超时配置
+func ValidateRequestTimeoutConfig(cfg *database.RequestTimeoutConfig) error {
+	if cfg.Default <= 0 || cfg.Min <= 0 || cfg.Max <= 0 {
+		return errors.New("default, min and max timeouts must be positive")
+	}
+	if cfg.Min > cfg.Max {
+		return errors.New("min timeout must not exceed max timeout")
+	}
+	for model, seconds := range cfg.Models {
+		if strings.TrimSpace(model) == "" || model == "*" {
+			return errors.New("model timeout keys must name a model or a model prefix ending in *")
+		}
+		if seconds <= 0 {
+			return errors.New("timeout for model " + model + " must be positive")
+		}
+	}
+	return nil
+}
+
+// ConfigureRequestTimeouts 设置全局默认、按模型覆盖及单请求覆盖上下限，立即生效
+func ConfigureRequestTimeouts(cfg *database.RequestTimeoutConfig) {
+	byModel := make(map[string]int, len(cfg.Models))
+	for model, seconds := range cfg.Models {
+		byModel[strings.ToLower(model)] = seconds
+	}
+	requestTimeouts.mu.Lock()
+	defer requestTimeouts.mu.Unlock()
+	requestTimeouts.cfg = database.RequestTimeoutConfig{Default: cfg.Default, Min: cfg.Min, Max: cfg.Max, Models: byModel}
+}
+
+// GetRequestTimeoutConfig 返回当前生效的请求超时配置（副本）
+func GetRequestTimeoutConfig() *database.RequestTimeoutConfig {
+	requestTimeouts.mu.RLock()
+	defer requestTimeouts.mu.RUnlock()
+	cfg := requestTimeouts.cfg
+	cfg.Models = make(map[string]int, len(requestTimeouts.cfg.Models))
+	for model, seconds := range requestTimeouts.cfg.Models {
+		cfg.Models[model] = seconds
+	}
+	return &cfg
+}
+
+// ResolveRequestTimeout 计算请求的超时：按模型精确匹配、其次最长前缀匹配，否则取全局默认；
+// requested（秒）大于 0 时改用客户端指定值，并限制在 [Min, Max] 内
+func ResolveRequestTimeout(model string, requested int) time.Duration {
+	requestTimeouts.mu.RLock()
+	defer requestTimeouts.mu.RUnlock()
+	cfg := requestTimeouts.cfg
+
+	if requested > 0 {
+		return time.Duration(min(max(requested, cfg.Min), cfg.Max)) * time.Second
+	}
+
+	model = strings.ToLower(model)
+	if seconds, ok := cfg.Models[model]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+	seconds, matched := cfg.Default, 0
+	for pattern, value := range cfg.Models {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) >= matched {
+			seconds, matched = value, len(prefix)
+		}
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// RequestedTimeout 读取客户端通过 X-Request-Timeout 指定的超时（秒），未指定时为 0；格式错误时写入 400 并返回 false
+func RequestedTimeout(c *gin.Context) (int, bool) {
+	header := c.GetHeader(RequestTimeoutHeader)
+	if header == "" {
+		return 0, true
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || seconds <= 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			RequestTimeoutHeader+" must be a positive number of seconds",
+			"invalid_request_error",
+			"invalid_request_timeout",
+		))
+		return 0, false
+	}
+	return seconds, true
+}
+
+// RequestTimeout 为模型请求设置截止时间：按请求体中的 model 与 X-Request-Timeout 头确定超时，
+// 写入请求上下文（排队等待与提供商调用均受其约束）
+func RequestTimeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requested, ok := RequestedTimeout(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		var model string
+		if c.ContentType() == "application/json" && c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+					"Failed to read request body",
+					"invalid_request_error",
+					"invalid_body",
+				))
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			var peek struct {
+				Model string `json:"model"`
+			}
+			if json.Unmarshal(body, &peek) == nil {
+				model = peek.Model
+			}
+		}
+		if model == "" {
+			model = c.GetString("request_model")
+		}
+
+		timeout := ResolveRequestTimeout(model, requested)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Set("request_timeout", timeout)
+		c.Header(RequestTimeoutHeader, strconv.Itoa(int(timeout/time.Second)))
+
+		c.Next()
+	}
+}
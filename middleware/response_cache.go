@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// ResponseCacheStatusHeader 响应头：HIT（命中缓存）、MISS（未命中，已转发上游）、BYPASS（客户端跳过缓存）
+const ResponseCacheStatusHeader = "X-Cache"
+
+// responseCacheRedisPrefix Redis 中缓存条目的键前缀
+const responseCacheRedisPrefix = "curry2api:response_cache:"
+
+// responseCacheRedisTimeout 单次 Redis 读写的超时，超时按未命中处理
+const responseCacheRedisTimeout = 500 * time.Millisecond
+
+// cachedResponse 缓存的响应
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+type responseCacheItem struct {
+	key       string
+	response  *cachedResponse
+	expiresAt time.Time
+}
+
+// responseCache 内存 LRU 缓存，配置 Redis 时作为二级缓存供多实例共享
+type responseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	items      map[string]*list.Element
+	order      *list.List // 最近使用的在前
+	redis      *redis.Client
+}
+
+var (
+	respCache   *responseCache
+	respCacheMu sync.RWMutex
+)
+
+// ConfigureResponseCache 启用相同请求的响应缓存；ttl <= 0 表示禁用。
+// redisURL 非空时连接 Redis，连接失败时返回错误并仅使用内存缓存
+func ConfigureResponseCache(ttl time.Duration, maxEntries, maxBytes int, redisURL string) error {
+	if ttl <= 0 {
+		respCacheMu.Lock()
+		respCache = nil
+		respCacheMu.Unlock()
+		return nil
+	}
+
+	cache := &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+
+	var err error
+	if redisURL != "" {
+		var opts *redis.Options
+		if opts, err = redis.ParseURL(redisURL); err == nil {
+			client := redis.NewClient(opts)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			err = client.Ping(ctx).Err()
+			cancel()
+			if err == nil {
+				cache.redis = client
+			} else {
+				client.Close()
+			}
+		}
+	}
+
+	respCacheMu.Lock()
+	respCache = cache
+	respCacheMu.Unlock()
+	return err
+}
+
+func getResponseCache() *responseCache {
+	respCacheMu.RLock()
+	defer respCacheMu.RUnlock()
+	return respCache
+}
+
+// get 先查内存，未命中时查 Redis 并回填内存
+func (rc *responseCache) get(ctx context.Context, key string) *cachedResponse {
+	rc.mu.Lock()
+	if elem, ok := rc.items[key]; ok {
+		item := elem.Value.(*responseCacheItem)
+		if time.Now().Before(item.expiresAt) {
+			rc.order.MoveToFront(elem)
+			rc.mu.Unlock()
+			return item.response
+		}
+		rc.order.Remove(elem)
+		delete(rc.items, key)
+	}
+	rc.mu.Unlock()
+
+	if rc.redis == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, responseCacheRedisTimeout)
+	defer cancel()
+	data, err := rc.redis.Get(ctx, responseCacheRedisPrefix+key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			logrus.WithError(err).Debug("Response cache Redis lookup failed")
+		}
+		return nil
+	}
+	var resp cachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil
+	}
+	ttl := rc.ttl
+	if remaining, err := rc.redis.PTTL(ctx, responseCacheRedisPrefix+key).Result(); err == nil && remaining > 0 {
+		ttl = remaining
+	}
+	rc.setMemory(key, &resp, ttl)
+	return &resp
+}
+
+// set 写入内存与 Redis（异步）
+func (rc *responseCache) set(key string, resp *cachedResponse) {
+	rc.setMemory(key, resp, rc.ttl)
+	if rc.redis == nil {
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), responseCacheRedisTimeout)
+		defer cancel()
+		if err := rc.redis.Set(ctx, responseCacheRedisPrefix+key, data, rc.ttl).Err(); err != nil {
+			logrus.WithError(err).Debug("Response cache Redis write failed")
+		}
+	}()
+}
+
+func (rc *responseCache) setMemory(key string, resp *cachedResponse, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	item := &responseCacheItem{key: key, response: resp, expiresAt: time.Now().Add(ttl)}
+	if elem, ok := rc.items[key]; ok {
+		elem.Value = item
+		rc.order.MoveToFront(elem)
+		return
+	}
+	rc.items[key] = rc.order.PushFront(item)
+	for rc.maxEntries > 0 && rc.order.Len() > rc.maxEntries {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.items, oldest.Value.(*responseCacheItem).key)
+	}
+}
+
+// AcceptsOnlyEventStream 客户端的 Accept 只接受 text/event-stream（不含 application/json 或 */*）。
+// 请求体未指定 stream 时，/v1/messages 按此协商为流式响应
+func AcceptsOnlyEventStream(c *gin.Context) bool {
+	accept := strings.ToLower(c.GetHeader("Accept"))
+	return strings.Contains(accept, "text/event-stream") &&
+		!strings.Contains(accept, "application/json") && !strings.Contains(accept, "*/*")
+}
+
+// responseCacheKey 按调用方密钥、接口路径与规范化后的请求体（模型、消息与全部参数）计算缓存键；
+// 流式请求（包括未指定 stream、经 Accept 协商为流式的请求）返回 false
+func responseCacheKey(c *gin.Context, body []byte) (string, bool) {
+	if strings.HasSuffix(c.Request.URL.Path, ":"+GeminiStreamGenerateContent) {
+		return "", false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return "", false
+	}
+	if stream, specified := fields["stream"]; specified {
+		if streaming, _ := stream.(bool); streaming {
+			return "", false
+		}
+	} else if AcceptsOnlyEventStream(c) {
+		return "", false
+	}
+	delete(fields, "stream")
+
+	// 重新序列化时对象键按字母序排列，字段顺序不同的相同请求得到相同的键
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	h.Write([]byte(c.GetString("api_key")))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Request.URL.Path))
+	h.Write([]byte{0})
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// responseCacheWriter 记录转发上游后的响应体
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ResponseCache 相同的非流式请求直接返回缓存的响应（不转发上游、不计费）。
+// 客户端发送 Cache-Control: no-cache 时跳过缓存读取但更新缓存，no-store 时既不读取也不写入
+func ResponseCache() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cache := getResponseCache()
+		if cache == nil || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+		if strings.Contains(cacheControl, "no-store") {
+			c.Header(ResponseCacheStatusHeader, "BYPASS")
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		key, ok := responseCacheKey(c, body)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if strings.Contains(cacheControl, "no-cache") {
+			c.Header(ResponseCacheStatusHeader, "BYPASS")
+		} else if cached := cache.get(c.Request.Context(), key); cached != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":     c.Request.URL.Path,
+				"key_hash": key[:12],
+			}).Debug("Serving response from cache")
+			c.Header(ResponseCacheStatusHeader, "HIT")
+			c.Data(cached.Status, cached.ContentType, cached.Body)
+			c.Abort()
+			return
+		} else {
+			c.Header(ResponseCacheStatusHeader, "MISS")
+		}
+
+		writer := &responseCacheWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		contentType := writer.Header().Get("Content-Type")
+		if writer.Status() != http.StatusOK || writer.body.Len() == 0 ||
+			strings.HasPrefix(contentType, "text/event-stream") ||
+			(cache.maxBytes > 0 && writer.body.Len() > cache.maxBytes) {
+			return
+		}
+		cache.set(key, &cachedResponse{
+			Status:      http.StatusOK,
+			ContentType: contentType,
+			Body:        append([]byte(nil), writer.body.Bytes()...),
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseCache_EventStreamAcceptSkipsCachedJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := ConfigureResponseCache(time.Minute, 100, 0, ""); err != nil {
+		t.Fatalf("ConfigureResponseCache() error = %v", err)
+	}
+	defer ConfigureResponseCache(0, 0, 0, "")
+
+	upstreamCalls := 0
+	router := gin.New()
+	router.POST("/v1/messages", ResponseCache(), func(c *gin.Context) {
+		upstreamCalls++
+		if AcceptsOnlyEventStream(c) {
+			c.Header("Content-Type", "text/event-stream")
+			c.String(http.StatusOK, "event: message_stop\ndata: {}\n\n")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"type": "message"})
+	})
+
+	body := `{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`
+	send := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("application/json"); w.Header().Get(ResponseCacheStatusHeader) != "MISS" {
+		t.Fatalf("First request X-Cache = %q, want MISS", w.Header().Get(ResponseCacheStatusHeader))
+	}
+	if w := send("application/json"); w.Header().Get(ResponseCacheStatusHeader) != "HIT" {
+		t.Fatalf("Second request X-Cache = %q, want HIT", w.Header().Get(ResponseCacheStatusHeader))
+	}
+
+	w := send("text/event-stream")
+	if status := w.Header().Get(ResponseCacheStatusHeader); status != "" {
+		t.Errorf("Event-stream request X-Cache = %q, want no cache status", status)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Errorf("Event-stream request Content-Type = %q, want text/event-stream", contentType)
+	}
+	if upstreamCalls != 2 {
+		t.Errorf("Handler called %d times, want 2", upstreamCalls)
+	}
+}
+
+func TestResponseCacheKey_StreamNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		body      string
+		accept    string
+		wantCache bool
+	}{
+		{"no stream field, JSON accept", `{"model":"m"}`, "application/json", true},
+		{"no stream field, no accept", `{"model":"m"}`, "", true},
+		{"no stream field, SSE accept", `{"model":"m"}`, "text/event-stream", false},
+		{"no stream field, SSE or anything", `{"model":"m"}`, "text/event-stream, */*", true},
+		{"stream false wins over SSE accept", `{"model":"m","stream":false}`, "text/event-stream", true},
+		{"stream true", `{"model":"m","stream":true}`, "application/json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			if _, ok := responseCacheKey(c, []byte(tt.body)); ok != tt.wantCache {
+				t.Errorf("responseCacheKey() cacheable = %v, want %v", ok, tt.wantCache)
+			}
+		})
+	}
+}
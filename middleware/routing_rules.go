@@ -0,0 +1,423 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RoutingRequest 路由规则匹配所用的请求属性
+type RoutingRequest struct {
+	UserID       *int64    `json:"user_id,omitempty"`
+	KeyTags      []string  `json:"key_tags,omitempty"`
+	Model        string    `json:"model"`
+	RequestBytes int       `json:"request_bytes"`
+	Language     string    `json:"language,omitempty"` // 提示词检测出的语言，未检测时为空
+	Time         time.Time `json:"time"`
+}
+
+// RoutingRuleMatch 命中的规则
+type RoutingRuleMatch struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// RoutingDecision 按顺序应用命中规则后的结果：路由与优先级取第一个设置它们的规则，
+// 系统提示词按顺序累加；拒绝规则命中后立即停止匹配
+type RoutingDecision struct {
+	Matched       []RoutingRuleMatch `json:"matched"`
+	RouteProvider string             `json:"route_provider,omitempty"`
+	Priority      string             `json:"priority,omitempty"`
+	SystemPrompts []string           `json:"system_prompts,omitempty"`
+	Denied        bool               `json:"denied"`
+	DenyMessage   string             `json:"deny_message,omitempty"`
+}
+
+// defaultDenyMessage 拒绝规则未设置消息时返回给客户端的消息
+const defaultDenyMessage = "Request denied by routing policy"
+
+var (
+	routingRules   []*database.RoutingRule
+	routingRulesMu sync.RWMutex
+)
+
+// ReloadRoutingRules 从数据库重新加载路由规则，管理员修改规则后调用
+func ReloadRoutingRules() error {
+	rules, err := database.ListRoutingRules()
+	if err != nil {
+		return err
+	}
+	SetRoutingRules(rules)
+	logrus.Infof("Loaded %d routing rules", len(rules))
+	return nil
+}
+
+// SetRoutingRules 替换当前生效的路由规则（须已按 position 排序）
+func SetRoutingRules(rules []*database.RoutingRule) {
+	routingRulesMu.Lock()
+	routingRules = rules
+	routingRulesMu.Unlock()
+}
+
+// CurrentRoutingRules 返回当前生效的路由规则
+func CurrentRoutingRules() []*database.RoutingRule {
+	routingRulesMu.RLock()
+	defer routingRulesMu.RUnlock()
+	return routingRules
+}
+
+// ValidateRoutingRule 检查规则的条件与动作是否有效
+func ValidateRoutingRule(rule *database.RoutingRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	cond := rule.Conditions
+	if (cond.TimeFrom == "") != (cond.TimeTo == "") {
+		return fmt.Errorf("time_from and time_to must be set together")
+	}
+	if cond.TimeFrom != "" {
+		if _, err := parseClock(cond.TimeFrom); err != nil {
+			return fmt.Errorf("invalid time_from: %w", err)
+		}
+		if _, err := parseClock(cond.TimeTo); err != nil {
+			return fmt.Errorf("invalid time_to: %w", err)
+		}
+	}
+	if cond.Timezone != "" {
+		if _, err := time.LoadLocation(cond.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", cond.Timezone)
+		}
+	}
+	if cond.MinBytes < 0 || cond.MaxBytes < 0 || (cond.MaxBytes > 0 && cond.MinBytes > cond.MaxBytes) {
+		return fmt.Errorf("invalid request size range")
+	}
+
+	actions := rule.Actions
+	switch actions.SetPriority {
+	case "", PriorityHigh, PriorityNormal:
+	default:
+		return fmt.Errorf("set_priority must be %q or %q", PriorityHigh, PriorityNormal)
+	}
+	if !actions.Deny && actions.RouteProvider == "" && actions.SetPriority == "" && actions.SystemPrompt == "" {
+		return fmt.Errorf("at least one action is required")
+	}
+	return nil
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matchModelPattern 匹配模型名，* 匹配任意字符序列
+func matchModelPattern(pattern, model string) bool {
+	pattern = strings.ToLower(pattern)
+	model = strings.ToLower(model)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == model
+	}
+	if !strings.HasPrefix(model, parts[0]) {
+		return false
+	}
+	model = model[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(model, part)
+		if idx < 0 {
+			return false
+		}
+		model = model[idx+len(part):]
+	}
+	return strings.HasSuffix(model, parts[len(parts)-1])
+}
+
+// matchRoutingConditions 判断请求是否满足规则的所有条件
+func matchRoutingConditions(cond database.RoutingRuleConditions, req RoutingRequest) bool {
+	if len(cond.UserIDs) > 0 {
+		if req.UserID == nil {
+			return false
+		}
+		found := false
+		for _, id := range cond.UserIDs {
+			if id == *req.UserID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(cond.KeyTags) > 0 {
+		found := false
+		for _, want := range cond.KeyTags {
+			for _, tag := range req.KeyTags {
+				if strings.EqualFold(want, tag) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(cond.Models) > 0 {
+		found := false
+		for _, pattern := range cond.Models {
+			if matchModelPattern(pattern, req.Model) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if cond.TimeFrom != "" && cond.TimeTo != "" {
+		from, errFrom := parseClock(cond.TimeFrom)
+		to, errTo := parseClock(cond.TimeTo)
+		if errFrom != nil || errTo != nil {
+			return false
+		}
+		now := req.Time
+		if cond.Timezone != "" {
+			if loc, err := time.LoadLocation(cond.Timezone); err == nil {
+				now = now.In(loc)
+			}
+		}
+		minute := now.Hour()*60 + now.Minute()
+		var inWindow bool
+		if from <= to {
+			inWindow = minute >= from && minute < to
+		} else {
+			// 跨午夜的时间段，如 22:00-06:00
+			inWindow = minute >= from || minute < to
+		}
+		if !inWindow {
+			return false
+		}
+	}
+
+	if len(cond.Languages) > 0 {
+		found := false
+		for _, lang := range cond.Languages {
+			if strings.EqualFold(lang, req.Language) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if cond.MinBytes > 0 && req.RequestBytes < cond.MinBytes {
+		return false
+	}
+	if cond.MaxBytes > 0 && req.RequestBytes > cond.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// EvaluateRoutingRules 按顺序匹配启用的规则并合并动作；设置了 stop 的规则命中后不再匹配后续规则
+func EvaluateRoutingRules(rules []*database.RoutingRule, req RoutingRequest) *RoutingDecision {
+	if req.Time.IsZero() {
+		req.Time = time.Now()
+	}
+	decision := &RoutingDecision{Matched: []RoutingRuleMatch{}}
+	for _, rule := range rules {
+		if !rule.Enabled || !matchRoutingConditions(rule.Conditions, req) {
+			continue
+		}
+		decision.Matched = append(decision.Matched, RoutingRuleMatch{ID: rule.ID, Name: rule.Name})
+
+		actions := rule.Actions
+		if actions.Deny {
+			decision.Denied = true
+			decision.DenyMessage = actions.DenyMessage
+			if decision.DenyMessage == "" {
+				decision.DenyMessage = defaultDenyMessage
+			}
+			return decision
+		}
+		if decision.RouteProvider == "" && actions.RouteProvider != "" {
+			decision.RouteProvider = strings.ToLower(actions.RouteProvider)
+		}
+		if decision.Priority == "" && actions.SetPriority != "" {
+			decision.Priority = actions.SetPriority
+		}
+		if actions.SystemPrompt != "" {
+			decision.SystemPrompts = append(decision.SystemPrompts, actions.SystemPrompt)
+		}
+		if rule.Stop {
+			break
+		}
+	}
+	return decision
+}
+
+// injectSystemPrompt 将系统提示词加到请求体最前面：OpenAI 格式插入 system 消息，
+// Anthropic Messages 格式（anthropic 为 true）合并到 system 字段，Gemini 格式插入 systemInstruction
+func injectSystemPrompt(body []byte, prompt string, anthropic bool) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	if _, ok := payload["contents"]; ok && !anthropic {
+		instruction := models.GeminiContent{}
+		if raw, ok := payload["systemInstruction"]; ok && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &instruction); err != nil {
+				return nil, err
+			}
+		}
+		instruction.Parts = append([]models.GeminiPart{{Text: prompt}}, instruction.Parts...)
+		raw, err := json.Marshal(instruction)
+		if err != nil {
+			return nil, err
+		}
+		payload["systemInstruction"] = raw
+		return json.Marshal(payload)
+	}
+
+	if !anthropic {
+		var messages []json.RawMessage
+		if raw, ok := payload["messages"]; ok {
+			if err := json.Unmarshal(raw, &messages); err != nil {
+				return nil, err
+			}
+		}
+		system, err := json.Marshal(models.Message{Role: "system", Content: prompt})
+		if err != nil {
+			return nil, err
+		}
+		messages = append([]json.RawMessage{system}, messages...)
+		if payload["messages"], err = json.Marshal(messages); err != nil {
+			return nil, err
+		}
+		return json.Marshal(payload)
+	}
+
+	var system interface{} = prompt
+	if raw, ok := payload["system"]; ok && string(raw) != "null" {
+		var text string
+		var blocks []json.RawMessage
+		if err := json.Unmarshal(raw, &text); err == nil {
+			system = prompt + "\n\n" + text
+		} else if err := json.Unmarshal(raw, &blocks); err == nil {
+			block, _ := json.Marshal(map[string]string{"type": "text", "text": prompt})
+			system = append([]json.RawMessage{block}, blocks...)
+		} else {
+			return nil, err
+		}
+	}
+	raw, err := json.Marshal(system)
+	if err != nil {
+		return nil, err
+	}
+	payload["system"] = raw
+	return json.Marshal(payload)
+}
+
+// RoutingRules 路由规则中间件，需放在 AuthRequired 之后、QoS 之前：
+// 拒绝命中的请求，设置请求优先级，注入系统提示词，并在上下文中记录 route_provider 供处理器选择提供商。
+// anthropic 表示请求体为 Anthropic Messages 格式
+func RoutingRules(anthropic bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := CurrentRoutingRules()
+		if len(rules) == 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.Next()
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		// 请求体格式错误时交给处理器返回校验错误
+		if json.Unmarshal(body, &req) != nil {
+			c.Next()
+			return
+		}
+
+		// Gemini 等模型在 URL 路径中的接口由前置中间件记录 request_model
+		if req.Model == "" {
+			req.Model = c.GetString("request_model")
+		}
+
+		routingReq := RoutingRequest{
+			KeyTags:      GetKeyManager().GetKeyTags(c.GetString("api_key")),
+			Model:        req.Model,
+			RequestBytes: len(body),
+			Language:     c.GetString("request_language"),
+			Time:         time.Now(),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			if id, ok := userID.(int64); ok {
+				routingReq.UserID = &id
+			}
+		}
+
+		decision := EvaluateRoutingRules(rules, routingReq)
+		if len(decision.Matched) == 0 {
+			c.Next()
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"model": req.Model,
+			"rules": decision.Matched,
+		}).Debug("Routing rules matched")
+
+		if decision.Denied {
+			errorResponse := models.NewErrorResponse(
+				decision.DenyMessage,
+				"permission_error",
+				"request_denied",
+			)
+			c.JSON(http.StatusForbidden, errorResponse)
+			c.Abort()
+			return
+		}
+
+		if decision.Priority != "" {
+			c.Set("request_priority", decision.Priority)
+		}
+		if decision.RouteProvider != "" {
+			c.Set("route_provider", decision.RouteProvider)
+		}
+		if len(decision.SystemPrompts) > 0 {
+			injected, err := injectSystemPrompt(body, strings.Join(decision.SystemPrompts, "\n\n"), anthropic)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to inject routing rule system prompt")
+			} else {
+				c.Request.Body = io.NopCloser(bytes.NewReader(injected))
+				c.Request.ContentLength = int64(len(injected))
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"Curry2API-go/database"
+
+	"github.com/sirupsen/logrus"
+)
+
+// API 密钥作用域：限定密钥可调用的端点，便于为不同应用签发最小权限的密钥
+const (
+	ScopeChat        = "chat"        // 对话补全：/v1/chat/completions、/v1/messages、/v1/responses 与 Gemini generateContent
+	ScopeEmbeddings  = "embeddings"  // /v1/embeddings
+	ScopeModels      = "models"      // /v1/models
+	ScopeImages      = "images"      // /v1/images/generations
+	ScopeAudio       = "audio"       // /v1/audio/transcriptions、/v1/audio/speech
+	ScopeModerations = "moderations" // /v1/moderations
+	ScopeBatches     = "batches"     // /v1/batches
+	ScopeFiles       = "files"       // /v1/files
+	ScopeAdminRead   = "admin-read"  // 以密钥所属用户身份调用 /admin 下的只读（GET）接口
+)
+
+// APIKeyScopes 所有可授予的作用域
+var APIKeyScopes = []string{
+	ScopeChat, ScopeEmbeddings, ScopeModels, ScopeImages, ScopeAudio,
+	ScopeModerations, ScopeBatches, ScopeFiles, ScopeAdminRead,
+}
+
+// routeScopes AuthRequired 保护的路由（gin 路由模板）所需的作用域
+var routeScopes = map[string]string{
+	"/v1/models":                ScopeModels,
+	"/v1/chat/completions":      ScopeChat,
+	"/v1/messages":              ScopeChat,
+	"/v1/messages/count_tokens": ScopeChat,
+	"/v1/responses":             ScopeChat,
+	"/v1beta/models/*action":    ScopeChat,
+	"/v1/embeddings":            ScopeEmbeddings,
+	"/v1/images/generations":    ScopeImages,
+	"/v1/audio/transcriptions":  ScopeAudio,
+	"/v1/audio/speech":          ScopeAudio,
+	"/v1/moderations":           ScopeModerations,
+	"/v1/batches":               ScopeBatches,
+	"/v1/batches/:id":           ScopeBatches,
+	"/v1/batches/:id/results":   ScopeBatches,
+	"/v1/batches/:id/cancel":    ScopeBatches,
+	"/v1/files":                 ScopeFiles,
+	"/v1/files/:id":             ScopeFiles,
+	"/v1/files/:id/content":     ScopeFiles,
+}
+
+// RouteScope 返回调用路由所需的作用域，未登记的路由返回空字符串
+func RouteScope(route string) string {
+	return routeScopes[route]
+}
+
+// IsValidScope 判断作用域名称是否有效
+func IsValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeScopes 去除空白与重复项并校验作用域，空列表表示不限制
+func NormalizeScopes(scopes []string) ([]string, error) {
+	result := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !IsValidScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q, valid scopes: %s", scope, strings.Join(APIKeyScopes, ", "))
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+// scopesAllow 判断密钥的作用域是否允许 scope。
+// 未设置作用域的密钥可使用 admin-read 以外的全部作用域（兼容已有密钥）；
+// 未登记作用域的路由只对未设置作用域的密钥开放
+func scopesAllow(scopes []string, scope string) bool {
+	if len(scopes) == 0 {
+		return scope != ScopeAdminRead
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 判断密钥是否拥有作用域
+func (km *KeyManager) HasScope(key, scope string) bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	info, exists := km.keys[key]
+	if !exists {
+		return false
+	}
+	return scopesAllow(info.Scopes, scope)
+}
+
+// SetKeyScopes 设置密钥作用域，空列表表示不限制（admin-read 除外）
+func (km *KeyManager) SetKeyScopes(key string, scopes []string) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeyScopes(key, scopes); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key scopes in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.Scopes = scopes
+	km.mu.Unlock()
+
+	logrus.Infof("Updated API key scopes: %s (scopes: %v)", maskKey(key), scopes)
+	return nil
+}
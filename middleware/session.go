@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -23,6 +25,16 @@ func SessionAuth() gin.HandlerFunc {
 		c.Set("role", nil)
 		c.Set("session_id", nil)
 		
+		// JWT 模式优先校验访问令牌，仍接受切换前创建的会话
+		tokenExpired := false
+		if JWTEnabled() {
+			var ok bool
+			if ok, tokenExpired = validateAccessToken(c); ok {
+				c.Next()
+				return
+			}
+		}
+
 		if ok := validateSessionCookie(c); ok {
 			c.Next()
 			return
@@ -50,6 +62,17 @@ func SessionAuth() gin.HandlerFunc {
 			"has_auth_header": c.GetHeader("Authorization") != "",
 		}).Info("Authentication failed - no valid session or token")
 		
+		// 访问令牌过期时提示前端用刷新令牌换取新令牌
+		if tokenExpired {
+			c.JSON(http.StatusUnauthorized, models.NewErrorResponse(
+				"登录令牌已过期，请刷新",
+				"invalid_session",
+				"token_expired",
+			))
+			c.Abort()
+			return
+		}
+
 		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(
 			"未登录，请先登录",
 			"invalid_session",
@@ -59,6 +82,49 @@ func SessionAuth() gin.HandlerFunc {
 	}
 }
 
+// sessionCache 最近验证通过的会话，数据库不可用时在降级窗口内继续使用
+var sessionCache sync.Map
+
+// ForgetSession 从会话缓存中移除会话（登出时调用）
+func ForgetSession(sessionID string) {
+	sessionCache.Delete(sessionID)
+}
+
+// ForgetUserSessions 从会话缓存中移除用户除 keepSessionID 外的全部会话（退出所有设备、重置密码时调用）
+func ForgetUserSessions(userID int64, keepSessionID string) {
+	sessionCache.Range(func(key, value interface{}) bool {
+		if id := key.(string); id != keepSessionID && value.(*database.Session).UserID == userID {
+			sessionCache.Delete(id)
+		}
+		return true
+	})
+}
+
+// cachedSession 获取缓存的会话（仅在降级窗口内且会话未过期时有效）
+func cachedSession(sessionID string) (*database.Session, bool) {
+	if !database.WithinDegradedWindow() {
+		return nil, false
+	}
+	value, ok := sessionCache.Load(sessionID)
+	if !ok {
+		return nil, false
+	}
+	session := value.(*database.Session)
+	if time.Now().After(session.ExpiresAt) {
+		sessionCache.Delete(sessionID)
+		return nil, false
+	}
+	return session, true
+}
+
+// setSessionContext 将会话信息写入上下文
+func setSessionContext(c *gin.Context, session *database.Session) {
+	c.Set("user_id", session.UserID)
+	c.Set("username", session.Username)
+	c.Set("role", session.Role)
+	c.Set("session_id", session.ID)
+}
+
 // ValidateSession 驗證會話ID並返回會話信息（公開函數供其他包使用）
 func ValidateSession(sessionID string) (*database.Session, error) {
 	if sessionID == "" {
@@ -98,8 +164,26 @@ func validateSessionCookie(c *gin.Context) bool {
 		return false
 	}
 
+	// 数据库不可用时使用缓存的会话，且不清除客户端 cookie
+	if database.IsDegraded() {
+		if session, ok := cachedSession(sessionID); ok {
+			setSessionContext(c, session)
+			return true
+		}
+		return false
+	}
+
 	session, err := ValidateSession(sessionID)
+	if err != nil && database.IsConnectionError(err) {
+		database.ReportError(err)
+		if cached, ok := cachedSession(sessionID); ok {
+			setSessionContext(c, cached)
+			return true
+		}
+		return false
+	}
 	if err != nil {
+		sessionCache.Delete(sessionID)
 		logrus.WithFields(logrus.Fields{
 			"session_id": sessionID[:8] + "...",
 			"error":      err.Error(),
@@ -126,6 +210,7 @@ func validateSessionCookie(c *gin.Context) bool {
 			}).Warn("Session IP mismatch - possible session hijacking")
 			
 			// IP不匹配，删除会话
+			sessionCache.Delete(sessionID)
 			_ = database.DeleteSession(sessionID)
 			
 			// 清除客户端cookie
@@ -146,7 +231,16 @@ func validateSessionCookie(c *gin.Context) bool {
 
 	// 验证用户是否仍然活跃
 	user, err := database.GetUserByID(session.UserID)
+	if err != nil && database.IsConnectionError(err) {
+		database.ReportError(err)
+		if cached, ok := cachedSession(sessionID); ok {
+			setSessionContext(c, cached)
+			return true
+		}
+		return false
+	}
 	if err != nil || !user.IsActive {
+		sessionCache.Delete(sessionID)
 		logrus.WithFields(logrus.Fields{
 			"user_id":    session.UserID,
 			"username":   session.Username,
@@ -163,10 +257,8 @@ func validateSessionCookie(c *gin.Context) bool {
 		return false
 	}
 
-	c.Set("user_id", session.UserID)
-	c.Set("username", session.Username)
-	c.Set("role", session.Role)
-	c.Set("session_id", session.ID)
+	sessionCache.Store(sessionID, session)
+	setSessionContext(c, session)
 	return true
 }
 
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConversationIDHeader 客户端用于标识同一多轮会话的请求头
+const ConversationIDHeader = "X-Conversation-ID"
+
+type conversationKey struct{}
+
+type affinityEntry struct {
+	email     string
+	expiresAt time.Time
+}
+
+// sessionAffinity 会话 → Cursor session 的粘性绑定
+type sessionAffinity struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]affinityEntry
+	lastSweep time.Time
+}
+
+var affinity = &sessionAffinity{entries: make(map[string]affinityEntry)}
+
+// ConfigureSessionAffinity 设置粘性绑定的有效期，ttl <= 0 表示禁用
+func ConfigureSessionAffinity(ttl time.Duration) {
+	affinity.mu.Lock()
+	defer affinity.mu.Unlock()
+	affinity.ttl = ttl
+	if ttl <= 0 {
+		affinity.entries = make(map[string]affinityEntry)
+	}
+}
+
+// ConversationContext 将请求头中的会话标识（按 API 密钥隔离）放入请求上下文
+// 未启用粘性绑定或未提供请求头时返回原上下文
+func ConversationContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	conversationID := c.GetHeader(ConversationIDHeader)
+	if conversationID == "" {
+		return ctx
+	}
+
+	affinity.mu.Lock()
+	enabled := affinity.ttl > 0
+	affinity.mu.Unlock()
+	if !enabled {
+		return ctx
+	}
+
+	return context.WithValue(ctx, conversationKey{}, c.GetString("api_key")+"/"+conversationID)
+}
+
+// ConversationFromContext 获取上下文中的会话标识
+func ConversationFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(conversationKey{}).(string)
+	return id
+}
+
+// GetSessionForConversation 获取会话绑定的 session；绑定的 session 不健康或已过期时
+// 按轮询重新选择并绑定。conversation 为空时等同于 GetValidSession
+func (csm *CursorSessionManager) GetSessionForConversation(conversation string) (*CursorSessionInfo, error) {
+	if conversation == "" {
+		return csm.GetValidSession()
+	}
+
+	now := time.Now()
+	affinity.mu.Lock()
+	entry, ok := affinity.entries[conversation]
+	affinity.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		csm.mu.RLock()
+		session := csm.sessions[entry.email]
+		healthy := session != nil && session.IsValid && session.FailCount == 0 && now.Before(session.ExpiresAt)
+		csm.mu.RUnlock()
+		if healthy {
+			csm.bindConversation(conversation, session.Email)
+			return session, nil
+		}
+	}
+
+	session, err := csm.GetValidSession()
+	if err != nil {
+		return nil, err
+	}
+	csm.bindConversation(conversation, session.Email)
+	return session, nil
+}
+
+// ReleaseConversation 解除会话绑定（session 请求失败时调用，下次重新选择）
+func (csm *CursorSessionManager) ReleaseConversation(conversation string) {
+	if conversation == "" {
+		return
+	}
+	affinity.mu.Lock()
+	delete(affinity.entries, conversation)
+	affinity.mu.Unlock()
+}
+
+// bindConversation 绑定或续期会话，并定期清理过期绑定
+func (csm *CursorSessionManager) bindConversation(conversation, email string) {
+	affinity.mu.Lock()
+	defer affinity.mu.Unlock()
+	if affinity.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	affinity.entries[conversation] = affinityEntry{email: email, expiresAt: now.Add(affinity.ttl)}
+
+	if now.Sub(affinity.lastSweep) > affinity.ttl {
+		for key, entry := range affinity.entries {
+			if now.After(entry.expiresAt) {
+				delete(affinity.entries, key)
+			}
+		}
+		affinity.lastSweep = now
+	}
+}
+
+// affinityCount 当前有效的会话绑定数量
+func affinityCount() int {
+	affinity.mu.Lock()
+	defer affinity.mu.Unlock()
+	return len(affinity.entries)
+}
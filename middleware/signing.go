@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 请求签名：启用签名的密钥除 Bearer 令牌外，每个请求还须携带 X-Signature-Timestamp（Unix 秒）
+// 与 X-Signature（hex(HMAC-SHA256(secret, timestamp + "." + method + "." + path + "." + body))），
+// path 为包含查询串的请求路径（如 /v1/chat/completions?stream=true）
+// 时间戳须在 SignatureTolerance 内，同一签名在有效期内只能使用一次（记录在数据库中，多实例共享）
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureTolerance       = 5 * time.Minute
+
+	signingSecretPrefix = "whsec_"
+
+	// signatureSpentPurpose 已使用签名在 signed_tokens_spent 中的用途
+	signatureSpentPurpose = "request_signature"
+)
+
+var (
+	ErrSignatureMissing  = errors.New("request signature required - this key requires X-Signature and X-Signature-Timestamp headers")
+	ErrSignatureExpired  = errors.New("request signature expired - timestamp is outside the allowed window")
+	ErrSignatureInvalid  = errors.New("request signature invalid")
+	ErrSignatureReplayed = errors.New("request signature already used")
+)
+
+// markSignatureSpent 记录签名已使用，已记录（重放）时返回 false；签名为 64 位 hex，取前 32 位作为记录 ID
+var markSignatureSpent = func(signature string, expiresAt time.Time) (bool, error) {
+	return database.MarkTokenSpent(signature[:32], signatureSpentPurpose, database.SpentTokenUsed, expiresAt)
+}
+
+// SignRequest 计算请求签名（供客户端参考实现与测试使用）
+func SignRequest(secret, timestamp, method, path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(method))
+	mac.Write([]byte("."))
+	mac.Write([]byte(path))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequiresSignature 密钥是否启用了请求签名
+func (km *KeyManager) RequiresSignature(key string) bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	info, exists := km.keys[key]
+	return exists && info.SigningEnabled
+}
+
+// VerifySignature 校验请求签名、时间窗口与重放
+func (km *KeyManager) VerifySignature(key, timestamp, signature, method, path string, body []byte) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	var secret string
+	if exists {
+		secret = info.SigningSecret
+	}
+	km.mu.RUnlock()
+	if secret == "" {
+		return nil
+	}
+
+	if timestamp == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	signedAt := time.Unix(ts, 0)
+	if skew := time.Since(signedAt); skew > SignatureTolerance || skew < -SignatureTolerance {
+		return ErrSignatureExpired
+	}
+
+	expected := SignRequest(secret, timestamp, method, path, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+	first, err := markSignatureSpent(signature, signedAt.Add(SignatureTolerance))
+	if err != nil {
+		return fmt.Errorf("failed to record request signature: %w", err)
+	}
+	if !first {
+		return ErrSignatureReplayed
+	}
+	return nil
+}
+
+// CheckRequestSignature 对启用签名的密钥校验请求签名（读取并还原请求体），
+// 所有接受 API key 的认证路径共用；未通过时写入 401 响应并中止请求
+func (km *KeyManager) CheckRequestSignature(c *gin.Context, key string) bool {
+	if !km.RequiresSignature(key) {
+		return true
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Failed to read request body",
+			"invalid_request_error",
+			"invalid_body",
+		))
+		c.Abort()
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = km.VerifySignature(key, c.GetHeader(SignatureTimestampHeader), c.GetHeader(SignatureHeader),
+		c.Request.Method, c.Request.URL.RequestURI(), body)
+	if err != nil {
+		var code string
+		switch err {
+		case ErrSignatureMissing:
+			code = "signature_missing"
+		case ErrSignatureExpired:
+			code = "signature_expired"
+		case ErrSignatureInvalid:
+			code = "signature_invalid"
+		case ErrSignatureReplayed:
+			code = "signature_replayed"
+		default:
+			logrus.WithError(err).Error("Failed to verify request signature")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Internal server error",
+				"internal_error",
+				"internal_error",
+			))
+			c.Abort()
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(
+			err.Error(),
+			"authentication_error",
+			code,
+		))
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// EnableSigning 为密钥生成新的签名密钥并启用签名校验，返回的密钥只展示一次
+// 对已启用的密钥调用会轮换签名密钥
+func (km *KeyManager) EnableSigning(key string) (string, error) {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return "", ErrKeyNotFound
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate signing secret: %w", err)
+	}
+	secret := signingSecretPrefix + hex.EncodeToString(buf)
+
+	if err := database.SetAPIKeySigningSecret(key, &secret); err != nil {
+		if err == database.ErrKeyNotFound {
+			return "", ErrKeyNotFound
+		}
+		return "", fmt.Errorf("failed to update key signing secret in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.SigningSecret = secret
+	info.SigningEnabled = true
+	km.mu.Unlock()
+
+	logrus.Infof("Enabled request signing for API key: %s", maskKey(key))
+	return secret, nil
+}
+
+// DisableSigning 关闭密钥的请求签名校验，之后仅需 Bearer 令牌
+func (km *KeyManager) DisableSigning(key string) error {
+	km.mu.RLock()
+	info, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	if err := database.SetAPIKeySigningSecret(key, nil); err != nil {
+		if err == database.ErrKeyNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key signing secret in database: %w", err)
+	}
+
+	km.mu.Lock()
+	info.SigningSecret = ""
+	info.SigningEnabled = false
+	km.mu.Unlock()
+
+	logrus.Infof("Disabled request signing for API key: %s", maskKey(key))
+	return nil
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckRequestSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	km := &KeyManager{keys: map[string]*KeyInfo{
+		"sk-plain":  {Key: "sk-plain"},
+		"sk-signed": {Key: "sk-signed", SigningEnabled: true, SigningSecret: "whsec_test"},
+	}}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	original := markSignatureSpent
+	defer func() { markSignatureSpent = original }()
+	spent := map[string]bool{}
+	markSignatureSpent = func(signature string, _ time.Time) (bool, error) {
+		if spent[signature] {
+			return false, nil
+		}
+		spent[signature] = true
+		return true, nil
+	}
+	valid := SignRequest("whsec_test", now, http.MethodGet, "/admin/stats?days=7", nil)
+	otherPath := SignRequest("whsec_test", now, http.MethodGet, "/admin/users", nil)
+
+	tests := []struct {
+		name      string
+		key       string
+		timestamp string
+		signature string
+		wantOK    bool
+		wantCode  string
+	}{
+		{"unsigned key", "sk-plain", "", "", true, ""},
+		{"missing headers", "sk-signed", "", "", false, "signature_missing"},
+		{"wrong signature", "sk-signed", now, "deadbeef", false, "signature_invalid"},
+		{"stale timestamp", "sk-signed", "1000000000", "deadbeef", false, "signature_expired"},
+		{"signed for another path", "sk-signed", now, otherPath, false, "signature_invalid"},
+		{"valid signature", "sk-signed", now, valid, true, ""},
+		{"replayed signature", "sk-signed", now, valid, false, "signature_replayed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/admin/stats?days=7", strings.NewReader(""))
+			if tt.timestamp != "" {
+				c.Request.Header.Set(SignatureTimestampHeader, tt.timestamp)
+				c.Request.Header.Set(SignatureHeader, tt.signature)
+			}
+
+			if ok := km.CheckRequestSignature(c, tt.key); ok != tt.wantOK {
+				t.Fatalf("CheckRequestSignature() = %v, want %v", ok, tt.wantOK)
+			}
+			if tt.wantOK {
+				return
+			}
+			if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), tt.wantCode) {
+				t.Errorf("response = %d %s, want 401 %s", w.Code, w.Body.String(), tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 用户自设消费上限触发时返回的错误
+var (
+	ErrSpendGuardDaily  = errors.New("daily spend guard reached - your self-imposed daily spending limit has been reached")
+	ErrSpendGuardWeekly = errors.New("weekly spend guard reached - your self-imposed weekly spending limit has been reached")
+)
+
+// spendGuardCacheTTL 消费上限配置的缓存时间；用户修改后立即失效
+const spendGuardCacheTTL = time.Minute
+
+type cachedSpendGuard struct {
+	guard    *database.SpendGuard
+	loadedAt time.Time
+}
+
+var (
+	spendGuardMu    sync.RWMutex
+	spendGuardCache = make(map[int64]cachedSpendGuard)
+)
+
+// InvalidateSpendGuard 清除用户的消费上限缓存（修改上限后调用）
+func InvalidateSpendGuard(userID int64) {
+	spendGuardMu.Lock()
+	defer spendGuardMu.Unlock()
+	delete(spendGuardCache, userID)
+}
+
+// loadSpendGuard 读取用户的消费上限，优先使用缓存
+func loadSpendGuard(userID int64) (*database.SpendGuard, error) {
+	spendGuardMu.RLock()
+	cached, ok := spendGuardCache[userID]
+	spendGuardMu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < spendGuardCacheTTL {
+		return cached.guard, nil
+	}
+
+	guard, err := database.GetSpendGuard(userID)
+	if err != nil {
+		return nil, err
+	}
+	spendGuardMu.Lock()
+	spendGuardCache[userID] = cachedSpendGuard{guard: guard, loadedAt: time.Now()}
+	spendGuardMu.Unlock()
+	return guard, nil
+}
+
+// CheckSpendGuard 检查密钥所属用户本日/本周的 API 消费是否已达到自设上限
+// 达到上限后立即拦截，直到下一个计费日/计费周或上限放宽生效
+func (km *KeyManager) CheckSpendGuard(key string) error {
+	if database.IsDegraded() {
+		return nil
+	}
+
+	km.mu.RLock()
+	keyInfo, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists || keyInfo.UserID == nil {
+		return nil
+	}
+	userID := *keyInfo.UserID
+
+	guard, err := loadSpendGuard(userID)
+	if err != nil {
+		logrus.Warnf("Failed to load spend guard for user %d: %v", userID, err)
+		return nil // Don't block on database errors
+	}
+	if !guard.IsActive() {
+		return nil
+	}
+
+	dayStart, weekStart := database.SpendGuardPeriods(time.Now())
+	if guard.WeeklyLimit != nil {
+		spent, err := database.GetUserAPISpend(userID, weekStart)
+		if err != nil {
+			logrus.Warnf("Failed to get weekly spend for user %d: %v", userID, err)
+			return nil
+		}
+		if spent >= *guard.WeeklyLimit {
+			return ErrSpendGuardWeekly
+		}
+	}
+	if guard.DailyLimit != nil {
+		spent, err := database.GetUserAPISpend(userID, dayStart)
+		if err != nil {
+			logrus.Warnf("Failed to get daily spend for user %d: %v", userID, err)
+			return nil
+		}
+		if spent >= *guard.DailyLimit {
+			return ErrSpendGuardDaily
+		}
+	}
+	return nil
+}
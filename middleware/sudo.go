@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SudoDuration 重新验证密码后 sudo 模式的有效期
+const SudoDuration = 10 * time.Minute
+
+// RequireSudo 危险的管理操作（删除用户、调整余额、批量轮换密钥、数据清理）要求会话处于 sudo 模式，
+// 即管理员在最近 SudoDuration 内通过 POST /auth/sudo 重新输入过密码，非管理员直接拒绝。每次放行都写入
+// 审计日志，无法写入时拒绝操作。管理员令牌本身就是最高权限凭据，不需要也无法进入 sudo 模式，直接放行并审计
+func RequireSudo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != "admin" {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"仅限管理员访问",
+				"admin_only",
+				"admin_only",
+			))
+			c.Abort()
+			return
+		}
+
+		details := gin.H{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+		}
+
+		var actorID *int64
+		if userID, ok := c.Get("user_id"); ok {
+			if v, ok := userID.(int64); ok && v > 0 {
+				actorID = &v
+			}
+		}
+
+		if actorID == nil {
+			details["via"] = "admin_token"
+		} else {
+			var until time.Time
+			if c.GetString("token_family") != "" {
+				// JWT 模式下 sudo 截止时间保存在访问令牌中
+				until = c.GetTime("elevated_until")
+			} else {
+				var err error
+				until, err = database.GetSessionElevation(c.GetString("session_id"))
+				if err != nil && err != database.ErrSessionNotFound {
+					logrus.WithError(err).Error("Failed to check sudo mode")
+					c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+						"服务器内部错误",
+						"internal_error",
+						"sudo_check_failed",
+					))
+					c.Abort()
+					return
+				}
+			}
+			if until.IsZero() {
+				c.JSON(http.StatusForbidden, models.NewErrorResponse(
+					"该操作需要重新验证身份，请先通过 POST /auth/sudo 输入密码",
+					"permission_error",
+					"sudo_required",
+				))
+				c.Abort()
+				return
+			}
+			details["elevated_until"] = until
+		}
+
+		if err := database.CreateAuditLog(actorID, database.AuditActionSudoOperate, "route", c.FullPath(), details); err != nil {
+			logrus.WithError(err).Error("Failed to audit sudo operation")
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"服务器内部错误",
+				"internal_error",
+				"audit_failed",
+			))
+			c.Abort()
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"username": c.GetString("username"),
+			"method":   c.Request.Method,
+			"path":     c.Request.URL.Path,
+		}).Warn("Dangerous admin operation in sudo mode")
+
+		c.Next()
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrTermsNotAccepted 密钥所属用户尚未接受当前版本的服务条款
+var ErrTermsNotAccepted = errors.New("terms not accepted - the current terms of service must be accepted before using the API")
+
+// termsGate 当前服务条款版本与用户接受状态的缓存，版本变化时清空
+type termsGate struct {
+	mu       sync.RWMutex
+	enforce  bool
+	version  string
+	accepted map[int64]bool
+}
+
+var terms = &termsGate{accepted: make(map[int64]bool)}
+
+// ConfigureTermsEnforcement 设置是否要求 API 密钥所属用户接受当前条款后才能调用 API
+func ConfigureTermsEnforcement(enforce bool) {
+	terms.mu.Lock()
+	defer terms.mu.Unlock()
+	terms.enforce = enforce
+}
+
+// LoadCurrentTermsVersion 从数据库加载当前条款版本（启动时调用）
+func LoadCurrentTermsVersion() {
+	current, err := database.GetCurrentTermsVersion()
+	if err != nil {
+		if err != database.ErrTermsNotFound {
+			logrus.WithError(err).Warn("Failed to load current terms of service version")
+		}
+		return
+	}
+	SetCurrentTermsVersion(current.Version)
+}
+
+// SetCurrentTermsVersion 更新当前条款版本，所有用户需重新接受
+func SetCurrentTermsVersion(version string) {
+	terms.mu.Lock()
+	defer terms.mu.Unlock()
+	if terms.version != version {
+		terms.version = version
+		terms.accepted = make(map[int64]bool)
+	}
+}
+
+// CurrentTermsVersion 当前条款版本，未发布任何版本时为空
+func CurrentTermsVersion() string {
+	terms.mu.RLock()
+	defer terms.mu.RUnlock()
+	return terms.version
+}
+
+// RememberTermsAcceptance 记录用户已接受指定版本（接受后立即解除 API 限制）
+func RememberTermsAcceptance(userID int64, version string) {
+	terms.mu.Lock()
+	defer terms.mu.Unlock()
+	if terms.version == version {
+		terms.accepted[userID] = true
+	}
+}
+
+// HasAcceptedCurrentTerms 用户是否已接受当前条款；未发布条款时视为已接受
+func HasAcceptedCurrentTerms(userID int64) (bool, error) {
+	terms.mu.RLock()
+	version := terms.version
+	accepted, cached := terms.accepted[userID]
+	terms.mu.RUnlock()
+
+	if version == "" {
+		return true, nil
+	}
+	if cached {
+		return accepted, nil
+	}
+
+	accepted, err := database.HasAcceptedTerms(userID, version)
+	if err != nil {
+		return false, err
+	}
+	terms.mu.Lock()
+	if terms.version == version {
+		terms.accepted[userID] = accepted
+	}
+	terms.mu.Unlock()
+	return accepted, nil
+}
+
+// CheckTermsAccepted 启用强制时检查密钥所属用户是否已接受当前条款
+// 无关联用户的密钥、数据库不可用时不拦截
+func (km *KeyManager) CheckTermsAccepted(key string) error {
+	terms.mu.RLock()
+	enforce := terms.enforce
+	terms.mu.RUnlock()
+	if !enforce || database.IsDegraded() {
+		return nil
+	}
+
+	km.mu.RLock()
+	keyInfo, exists := km.keys[key]
+	km.mu.RUnlock()
+	if !exists || keyInfo.UserID == nil {
+		return nil
+	}
+
+	accepted, err := HasAcceptedCurrentTerms(*keyInfo.UserID)
+	if err != nil {
+		logrus.Warnf("Failed to check terms acceptance for user %d: %v", *keyInfo.UserID, err)
+		return nil // Don't block on database errors
+	}
+	if !accepted {
+		return ErrTermsNotAccepted
+	}
+	return nil
+}
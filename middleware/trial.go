@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// TrialKeyPrefix 匿名试用临时密钥的前缀，AuthRequired 据此走试用密钥校验
+const TrialKeyPrefix = "trial-"
+
+// TrialTokenName 试用请求在用量记录中的令牌名称
+const TrialTokenName = "Anonymous trial"
+
+// IsTrialKey 判断是否为匿名试用临时密钥
+func IsTrialKey(key string) bool {
+	return strings.HasPrefix(key, TrialKeyPrefix)
+}
+
+// trialKeyState 临时密钥的内存状态：请求计数与限流器
+type trialKeyState struct {
+	ip        string
+	expiresAt time.Time
+	requests  int
+	limiter   *rate.Limiter
+}
+
+var (
+	trialKeys   = make(map[string]*trialKeyState)
+	trialKeysMu sync.Mutex
+
+	trialDefaultModels   []string
+	trialDefaultModelsMu sync.RWMutex
+)
+
+// SetTrialDefaultModels 设置试用配置未指定模型时可试用的模型（全部免费模型）
+func SetTrialDefaultModels(models []string) {
+	sorted := append([]string(nil), models...)
+	sort.Strings(sorted)
+	trialDefaultModelsMu.Lock()
+	trialDefaultModels = sorted
+	trialDefaultModelsMu.Unlock()
+}
+
+// TrialModels 返回可试用的模型：配置指定的模型，未指定时为全部免费模型
+func TrialModels(cfg *database.TrialConfig) []string {
+	if len(cfg.Models) > 0 {
+		return cfg.Models
+	}
+	trialDefaultModelsMu.RLock()
+	defer trialDefaultModelsMu.RUnlock()
+	return trialDefaultModels
+}
+
+// loadTrialKey 返回临时密钥的内存状态，不在内存中时（如重启后）从数据库加载
+func loadTrialKey(key string, cfg *database.TrialConfig) (*trialKeyState, error) {
+	trialKeysMu.Lock()
+	state, ok := trialKeys[key]
+	trialKeysMu.Unlock()
+	if ok {
+		return state, nil
+	}
+
+	record, err := database.GetTrialKey(key)
+	if err != nil {
+		return nil, err
+	}
+	state = &trialKeyState{
+		ip:        record.IPAddress,
+		expiresAt: record.ExpiresAt,
+		requests:  record.Requests,
+		limiter:   rate.NewLimiter(rate.Limit(float64(cfg.RequestsPerMinute)/60), cfg.RequestsPerMinute),
+	}
+
+	trialKeysMu.Lock()
+	defer trialKeysMu.Unlock()
+	if existing, ok := trialKeys[key]; ok {
+		return existing, nil
+	}
+	trialKeys[key] = state
+	return state, nil
+}
+
+// PurgeExpiredTrialKeys 从内存中移除已过期的临时密钥
+func PurgeExpiredTrialKeys() int {
+	now := time.Now()
+	trialKeysMu.Lock()
+	defer trialKeysMu.Unlock()
+	purged := 0
+	for key, state := range trialKeys {
+		if now.After(state.expiresAt) {
+			delete(trialKeys, key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// abortTrial 写入错误响应并中止请求
+func abortTrial(c *gin.Context, status int, message, errorType, code string) {
+	c.JSON(status, models.NewErrorResponse(message, errorType, code))
+	c.Abort()
+}
+
+// trialAuth 校验匿名试用临时密钥：试用须已开启，密钥未过期、来自签发时的 IP，
+// 未超过总请求数与每分钟请求数，且只能请求可试用的模型
+func trialAuth(c *gin.Context, token string) {
+	cfg, err := database.GetTrialConfig()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get trial config")
+		abortTrial(c, http.StatusServiceUnavailable, "Anonymous trial is temporarily unavailable", "server_error", "trial_unavailable")
+		return
+	}
+	if !cfg.Enabled {
+		abortTrial(c, http.StatusUnauthorized, "Anonymous trial is disabled", "authentication_error", "trial_disabled")
+		return
+	}
+
+	state, err := loadTrialKey(token, cfg)
+	if err != nil {
+		if err != database.ErrTrialKeyNotFound {
+			logrus.WithError(err).Error("Failed to load trial key")
+		}
+		abortTrial(c, http.StatusUnauthorized, "Invalid API key", "authentication_error", "invalid_api_key")
+		return
+	}
+	if time.Now().After(state.expiresAt) {
+		abortTrial(c, http.StatusUnauthorized, "Trial key expired - sign up to keep using the API", "authentication_error", "trial_expired")
+		return
+	}
+	if state.ip != c.ClientIP() {
+		abortTrial(c, http.StatusForbidden, "Trial key is bound to another IP address", "permission_error", "trial_ip_mismatch")
+		return
+	}
+
+	// 按请求体中的模型校验（路径中带模型的接口由前置中间件记录 request_model）
+	model := c.GetString("request_model")
+	if c.Request.Body != nil {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		var peek struct {
+			Model string `json:"model"`
+		}
+		if json.Unmarshal(body, &peek) == nil && peek.Model != "" {
+			model = peek.Model
+		}
+	}
+	allowed := false
+	for _, m := range TrialModels(cfg) {
+		if strings.EqualFold(m, model) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		abortTrial(c, http.StatusForbidden, "Model not available in the anonymous trial: "+model, "forbidden", "model_not_allowed")
+		return
+	}
+
+	trialKeysMu.Lock()
+	if cfg.MaxRequests > 0 && state.requests >= cfg.MaxRequests {
+		trialKeysMu.Unlock()
+		abortTrial(c, http.StatusTooManyRequests, "Trial request limit reached - sign up to keep using the API", "rate_limited", "trial_limit_reached")
+		return
+	}
+	if cfg.RequestsPerMinute > 0 && !state.limiter.Allow() {
+		trialKeysMu.Unlock()
+		c.Header("Retry-After", strconv.Itoa(60/cfg.RequestsPerMinute+1))
+		abortTrial(c, http.StatusTooManyRequests, "Trial rate limit reached, please retry later", "rate_limited", "rate_limit_exceeded")
+		return
+	}
+	state.requests++
+	trialKeysMu.Unlock()
+
+	go func() {
+		if err := database.IncrementTrialKeyRequests(token); err != nil {
+			logrus.WithError(err).Warn("Failed to record trial key request")
+		}
+	}()
+
+	c.Set("api_key", token)
+	c.Set("token_name", TrialTokenName)
+	c.Set("trial_key", true)
+	c.Set("request_priority", PriorityNormal)
+	c.Set("stream_flush", GetKeyManager().ResolveStreamFlush(token))
+	c.Next()
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"Curry2API-go/database"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// adminTwoFactorRequired 是否要求管理员账号启用两步验证后才能访问管理接口
+var adminTwoFactorRequired atomic.Bool
+
+// LoadTwoFactorPolicy 从系统设置加载管理员两步验证要求（启动时调用）
+func LoadTwoFactorPolicy() {
+	value, err := database.GetSetting(database.SettingKeyRequireAdminTwoFactor)
+	if err != nil {
+		if err != database.ErrSettingNotFound {
+			logrus.WithError(err).Warn("Failed to load admin two-factor policy")
+		}
+		return
+	}
+	required, _ := strconv.ParseBool(value)
+	adminTwoFactorRequired.Store(required)
+}
+
+// SetAdminTwoFactorRequired 保存并立即应用管理员两步验证要求
+func SetAdminTwoFactorRequired(required bool) error {
+	if err := database.SetSetting(database.SettingKeyRequireAdminTwoFactor, strconv.FormatBool(required)); err != nil {
+		return err
+	}
+	adminTwoFactorRequired.Store(required)
+	return nil
+}
+
+// AdminTwoFactorRequired 当前是否要求管理员启用两步验证
+func AdminTwoFactorRequired() bool {
+	return adminTwoFactorRequired.Load()
+}
+
+// NeedsTwoFactorSetup 管理员账号在要求两步验证时尚未启用，需要先完成设置才能访问管理接口
+func NeedsTwoFactorSetup(userID int64, role string) (bool, error) {
+	if role != "admin" || !AdminTwoFactorRequired() {
+		return false, nil
+	}
+	enabled, err := database.IsTwoFactorEnabled(userID)
+	if err != nil {
+		return false, err
+	}
+	return !enabled, nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import "io"
+
+// TranscriptionRequest OpenAI 兼容的 /v1/audio/transcriptions 请求（whisper-1、gpt-4o-transcribe 等）
+// File 为客户端上传的音频，转发时以流的方式写入上游的 multipart 请求体
+type TranscriptionRequest struct {
+	File                   io.Reader
+	FileName               string
+	Model                  string
+	Language               string
+	Prompt                 string
+	ResponseFormat         string   // json/text/srt/verbose_json/vtt
+	Temperature            string   // 0 - 1，原样转发
+	TimestampGranularities []string // verbose_json：word 和/或 segment
+}
+
+// TranscriptionResponse 上游返回的转写结果及其 Content-Type，格式取决于 response_format
+type TranscriptionResponse struct {
+	Body        []byte
+	ContentType string
+}
+
+// SpeechRequest OpenAI 兼容的 /v1/audio/speech 请求（tts-1、tts-1-hd、gpt-4o-mini-tts）
+type SpeechRequest struct {
+	Model          string   `json:"model" binding:"required"`
+	Input          string   `json:"input" binding:"required"`
+	Voice          string   `json:"voice" binding:"required"`
+	Instructions   string   `json:"instructions,omitempty"`    // gpt-4o-mini-tts：语气、语速等朗读指示
+	ResponseFormat string   `json:"response_format,omitempty"` // mp3/opus/aac/flac/wav/pcm，默认 mp3
+	Speed          *float64 `json:"speed,omitempty"`           // 0.25 - 4.0
+}
+
+// SpeechResponse 上游返回的音频数据及其 Content-Type
+type SpeechResponse struct {
+	Audio       []byte
+	ContentType string
+}
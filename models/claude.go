@@ -69,11 +69,98 @@ type ClaudeContentBlock struct {
 	IsError   bool                   `json:"is_error,omitempty"`    // for tool_result errors
 }
 
-// ClaudeImageSource Claude图片源
+// MarshalJSON text 块始终输出 text（流式 content_block_start 中为空字符串），
+// tool_use 块始终输出 input（流式开始时为 {}），以符合 Anthropic 客户端的解析要求
+func (b ClaudeContentBlock) MarshalJSON() ([]byte, error) {
+	type alias ClaudeContentBlock
+	switch b.Type {
+	case "text":
+		return json.Marshal(struct {
+			alias
+			Text string `json:"text"`
+		}{alias(b), b.Text})
+	case "tool_use":
+		input := b.Input
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		return json.Marshal(struct {
+			alias
+			Input map[string]interface{} `json:"input"`
+		}{alias(b), input})
+	}
+	return json.Marshal(alias(b))
+}
+
+// ClaudeImageSource Claude图片源：base64（media_type + data）或 url
 type ClaudeImageSource struct {
 	Type      string `json:"type"`
-	MediaType string `json:"media_type"`
-	Data      string `json:"data"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// ImageURL 将图片源转换为 OpenAI image_url 使用的地址，base64 图片转换为 data URL
+func (s *ClaudeImageSource) ImageURL() string {
+	if s == nil {
+		return ""
+	}
+	switch s.Type {
+	case "base64":
+		if s.Data == "" {
+			return ""
+		}
+		return fmt.Sprintf("data:%s;base64,%s", s.MediaType, s.Data)
+	case "url":
+		return s.URL
+	}
+	return ""
+}
+
+// claudeImageSourceFromMap 解析未类型化的 image 块中的 source
+func claudeImageSourceFromMap(block map[string]interface{}) *ClaudeImageSource {
+	source, ok := block["source"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	s := &ClaudeImageSource{}
+	s.Type, _ = source["type"].(string)
+	s.MediaType, _ = source["media_type"].(string)
+	s.Data, _ = source["data"].(string)
+	s.URL, _ = source["url"].(string)
+	return s
+}
+
+// claudeContentBuilder 按顺序收集消息的文本与图片：没有图片时合并为字符串，
+// 有图片时输出 OpenAI 多模态内容数组（text / image_url）
+type claudeContentBuilder struct {
+	textParts []string
+	parts     []interface{}
+	hasImage  bool
+}
+
+func (b *claudeContentBuilder) addText(text string) {
+	b.textParts = append(b.textParts, text)
+	b.parts = append(b.parts, map[string]interface{}{"type": "text", "text": text})
+}
+
+func (b *claudeContentBuilder) addImage(source *ClaudeImageSource) {
+	url := source.ImageURL()
+	if url == "" {
+		return
+	}
+	b.hasImage = true
+	b.parts = append(b.parts, map[string]interface{}{
+		"type":      "image_url",
+		"image_url": map[string]interface{}{"url": url},
+	})
+}
+
+func (b *claudeContentBuilder) content() interface{} {
+	if b.hasImage {
+		return b.parts
+	}
+	return strings.Join(b.textParts, "\n\n")
 }
 
 // ClaudeMetadata Claude元数据
@@ -103,14 +190,46 @@ type ClaudeStreamResponse struct {
 	Usage        *ClaudeUsage          `json:"usage,omitempty"`
 }
 
+// MarshalJSON content_block_* 事件必须携带 index（包括 0），其余事件不输出 index
+func (r ClaudeStreamResponse) MarshalJSON() ([]byte, error) {
+	type alias ClaudeStreamResponse
+	if strings.HasPrefix(r.Type, "content_block_") {
+		return json.Marshal(struct {
+			alias
+			Index int `json:"index"`
+		}{alias(r), r.Index})
+	}
+	return json.Marshal(alias(r))
+}
+
 // ClaudeStreamDelta Claude流式增量
 type ClaudeStreamDelta struct {
 	Type         string `json:"type,omitempty"`
 	Text         string `json:"text,omitempty"`
+	PartialJSON  string `json:"partial_json,omitempty"` // input_json_delta 的工具输入片段
 	StopReason   string `json:"stop_reason,omitempty"`
 	StopSequence *string `json:"stop_sequence"` // 使用指针以便输出null
 }
 
+// MarshalJSON content_block_delta 只输出对应增量类型的字段（text_delta 的 text、input_json_delta 的 partial_json），
+// message_delta 输出 stop_reason 与 stop_sequence
+func (d ClaudeStreamDelta) MarshalJSON() ([]byte, error) {
+	switch d.Type {
+	case "text_delta":
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{d.Type, d.Text})
+	case "input_json_delta":
+		return json.Marshal(struct {
+			Type        string `json:"type"`
+			PartialJSON string `json:"partial_json"`
+		}{d.Type, d.PartialJSON})
+	}
+	type alias ClaudeStreamDelta
+	return json.Marshal(alias(d))
+}
+
 // ClaudeUsage Claude使用统计
 type ClaudeUsage struct {
 	InputTokens  int `json:"input_tokens"`
@@ -189,7 +308,7 @@ func (r *ClaudeMessageRequest) ToOpenAIRequest() *ChatCompletionRequest {
 			openAIMsg.Content = content
 		case []interface{}:
 			// 处理多模态内容块数组
-			var textParts []string
+			var builder claudeContentBuilder
 			for _, item := range content {
 				if block, ok := item.(map[string]interface{}); ok {
 					blockType, _ := block["type"].(string)
@@ -197,25 +316,31 @@ func (r *ClaudeMessageRequest) ToOpenAIRequest() *ChatCompletionRequest {
 					switch blockType {
 					case "text":
 						if text, exists := block["text"].(string); exists && text != "" {
-							textParts = append(textParts, text)
+							builder.addText(text)
 						}
+					case "image":
+						builder.addImage(claudeImageSourceFromMap(block))
 					case "tool_result":
 						// 处理工具结果 - 这是 Claude Code CLI 发送的工具执行结果
 						// 使用简洁的格式，直接展示结果内容
 						isError, _ := block["is_error"].(bool)
 						
 						var resultContent string
+						var resultImages []*ClaudeImageSource
 						switch c := block["content"].(type) {
 						case string:
 							resultContent = c
 						case []interface{}:
-							// 处理嵌套的内容块
+							// 处理嵌套的内容块（截图等工具结果中的图片随结果一起传递）
 							for _, nested := range c {
 								if nestedBlock, ok := nested.(map[string]interface{}); ok {
-									if nestedBlock["type"] == "text" {
+									switch nestedBlock["type"] {
+									case "text":
 										if text, exists := nestedBlock["text"].(string); exists {
 											resultContent += text
 										}
+									case "image":
+										resultImages = append(resultImages, claudeImageSourceFromMap(nestedBlock))
 									}
 								}
 							}
@@ -224,10 +349,13 @@ func (r *ClaudeMessageRequest) ToOpenAIRequest() *ChatCompletionRequest {
 						// 简化格式：直接展示工具执行结果
 						// 不使用复杂的标签，避免模型混淆
 						if isError {
-							textParts = append(textParts, fmt.Sprintf("Tool execution failed:\n%s", resultContent))
+							builder.addText(fmt.Sprintf("Tool execution failed:\n%s", resultContent))
 						} else {
 							// 直接使用结果内容，不添加额外标签
-							textParts = append(textParts, resultContent)
+							builder.addText(resultContent)
+						}
+						for _, image := range resultImages {
+							builder.addImage(image)
 						}
 					case "tool_use":
 						// 处理工具调用（assistant 消息中的）
@@ -235,21 +363,22 @@ func (r *ClaudeMessageRequest) ToOpenAIRequest() *ChatCompletionRequest {
 						toolName, _ := block["name"].(string)
 						toolInput, _ := block["input"].(map[string]interface{})
 						inputJSON, _ := json.Marshal(toolInput)
-						textParts = append(textParts, fmt.Sprintf("Used tool %s with input: %s", toolName, string(inputJSON)))
+						builder.addText(fmt.Sprintf("Used tool %s with input: %s", toolName, string(inputJSON)))
 					}
-					// 注意: 图片类型暂时忽略，因为当前后端不支持
 				}
 			}
-			openAIMsg.Content = strings.Join(textParts, "\n\n")
+			openAIMsg.Content = builder.content()
 		case []ClaudeContentBlock:
 			// 处理已解析的内容块数组
-			var textParts []string
+			var builder claudeContentBuilder
 			for _, block := range content {
 				switch block.Type {
 				case "text":
 					if block.Text != "" {
-						textParts = append(textParts, block.Text)
+						builder.addText(block.Text)
 					}
+				case "image":
+					builder.addImage(block.Source)
 				case "tool_result":
 					var resultContent string
 					switch c := block.Content.(type) {
@@ -258,16 +387,16 @@ func (r *ClaudeMessageRequest) ToOpenAIRequest() *ChatCompletionRequest {
 					}
 					// 简化格式
 					if block.IsError {
-						textParts = append(textParts, fmt.Sprintf("Tool execution failed:\n%s", resultContent))
+						builder.addText(fmt.Sprintf("Tool execution failed:\n%s", resultContent))
 					} else {
-						textParts = append(textParts, resultContent)
+						builder.addText(resultContent)
 					}
 				case "tool_use":
 					inputJSON, _ := json.Marshal(block.Input)
-					textParts = append(textParts, fmt.Sprintf("Used tool %s with input: %s", block.Name, string(inputJSON)))
+					builder.addText(fmt.Sprintf("Used tool %s with input: %s", block.Name, string(inputJSON)))
 				}
 			}
-			openAIMsg.Content = strings.Join(textParts, "\n\n")
+			openAIMsg.Content = builder.content()
 		default:
 			openAIMsg.Content = ""
 		}
@@ -509,6 +638,7 @@ func NewClaudeStreamResponseWithDetails(eventType, text, stopReason, model, mess
 		resp.Delta = delta
 		
 		resp.Usage = &ClaudeUsage{
+			InputTokens:  inputTokens,
 			OutputTokens: outputTokens,
 		}
 	case "message_stop":
@@ -0,0 +1,70 @@
+package models
+
+import "encoding/json"
+
+// EmbeddingRequest OpenAI 兼容的 /v1/embeddings 请求
+// Input 可以是字符串、字符串数组、token 数组或 token 数组的数组
+type EmbeddingRequest struct {
+	Model          string      `json:"model" binding:"required"`
+	Input          interface{} `json:"input" binding:"required"`
+	EncodingFormat string      `json:"encoding_format,omitempty"` // float 或 base64
+	Dimensions     int         `json:"dimensions,omitempty"`
+	User           string      `json:"user,omitempty"`
+}
+
+// EmbeddingInputs 将 Input 规范化为输入列表，每项为字符串或 token 数组；格式无效时返回 nil
+func (r *EmbeddingRequest) EmbeddingInputs() []interface{} {
+	switch input := r.Input.(type) {
+	case string:
+		return []interface{}{input}
+	case []interface{}:
+		if len(input) == 0 {
+			return nil
+		}
+		// 单个 token 数组（[1, 2, 3]）视为一个输入
+		if _, isNumber := input[0].(float64); isNumber {
+			for _, v := range input {
+				if _, ok := v.(float64); !ok {
+					return nil
+				}
+			}
+			return []interface{}{input}
+		}
+		for _, v := range input {
+			switch item := v.(type) {
+			case string:
+			case []interface{}:
+				for _, token := range item {
+					if _, ok := token.(float64); !ok {
+						return nil
+					}
+				}
+			default:
+				return nil
+			}
+		}
+		return input
+	}
+	return nil
+}
+
+// EmbeddingData 单个输入的向量；Embedding 为浮点数组，encoding_format=base64 时为字符串
+type EmbeddingData struct {
+	Object    string          `json:"object"`
+	Index     int             `json:"index"`
+	Embedding json.RawMessage `json:"embedding"`
+}
+
+// EmbeddingUsage 向量请求的 token 用量
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EmbeddingResponse OpenAI 兼容的 /v1/embeddings 响应
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingUsage  `json:"usage"`
+}
@@ -19,6 +19,27 @@ type KeyInfo struct {
     QuotaUsed     float64    `json:"quota_used"`               // Quota used in USD
     ExpiresAt     *time.Time `json:"expires_at,omitempty"`     // Expiration time, nil means never expires
     AllowedModels []string   `json:"allowed_models,omitempty"` // Allowed models, nil/empty means all models
+    // QoS extension fields
+    PriorityTrusted bool     `json:"priority_trusted"`         // Whether X-Priority header from this key is honored
+    // Streaming extension fields
+    StreamFlushIntervalMs *int `json:"stream_flush_interval_ms,omitempty"` // SSE coalescing interval override, nil uses the server default
+    StreamFlushBytes      *int `json:"stream_flush_bytes,omitempty"`       // SSE coalescing size override, nil uses the server default
+    // Request signing extension fields
+    SigningSecret  string `json:"-"`               // HMAC secret for request signing, never serialized
+    SigningEnabled bool   `json:"signing_enabled"` // Whether requests with this key must carry an HMAC signature
+    // Routing rule extension fields
+    Tags []string `json:"tags,omitempty"` // Admin-assigned tags matched by routing rules
+    // Scope extension fields
+    Scopes []string `json:"scopes,omitempty"` // Endpoints the key may call, nil/empty means every scope except admin-read
+    // IP allowlist extension fields
+    AllowedIPs []string `json:"allowed_ips,omitempty"` // CIDR ranges the key may be used from, nil/empty means any address
+}
+
+// StreamFlushSettings SSE 输出合并策略：缓冲的数据达到 Bytes 字节或距上次刷新超过 IntervalMs 毫秒时才刷新
+// 两者均为 0 时每个事件立即刷新
+type StreamFlushSettings struct {
+    IntervalMs int `json:"interval_ms"`
+    Bytes      int `json:"bytes"`
 }
 
 // CursorSessionInfo 表示 Cursor session 的持久化結構
@@ -104,25 +125,88 @@ func (s *CursorSessionInfo) UpdateQuotaStatus(lowThreshold float64) {
 
 // Conversation 会话模型 - represents a chat conversation stored in the database
 type Conversation struct {
-	ID           int64     `json:"id"`
-	UserID       int64     `json:"user_id"`
-	Title        string    `json:"title"`
-	Model        string    `json:"model"`
-	SystemPrompt string    `json:"system_prompt,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID               int64     `json:"id"`
+	UserID           int64     `json:"user_id"`
+	Title            string    `json:"title"`
+	Model            string    `json:"model"`
+	SystemPrompt     string    `json:"system_prompt,omitempty"`
+	MaxCost          *float64  `json:"max_cost,omitempty"`           // Spend ceiling in USD, nil for none
+	TotalCost        float64   `json:"total_cost"`                   // Cumulative cost of the conversation's messages
+	Deterministic    bool      `json:"deterministic"`                // Pin temperature to 0 and a fixed seed for reproducible replies
+	Seed             *int64    `json:"seed,omitempty"`               // Seed used in deterministic mode, nil for DefaultDeterministicSeed
+	FolderID         *int64    `json:"folder_id"`                    // Folder the conversation is filed in, nil for none
+	Tags             []string  `json:"tags,omitempty"`               // User-defined labels, sorted
+	PromptTemplateID *int64    `json:"prompt_template_id,omitempty"` // System prompt template the conversation was started from
+	Pinned           bool      `json:"pinned"`                       // Listed before unpinned conversations
+	Archived         bool      `json:"archived"`                     // Hidden from the conversation list by default
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// DefaultDeterministicSeed is the seed deterministic conversations use when none is set
+const DefaultDeterministicSeed int64 = 42
+
+// SamplingParams returns the temperature and seed to send for the conversation's
+// next reply; both are nil unless deterministic mode is on
+func (c *Conversation) SamplingParams() (*float64, *int64) {
+	if !c.Deterministic {
+		return nil, nil
+	}
+	temperature := 0.0
+	seed := DefaultDeterministicSeed
+	if c.Seed != nil {
+		seed = *c.Seed
+	}
+	return &temperature, &seed
 }
 
 // ChatMessage 聊天消息模型 - represents a message in a chat conversation stored in the database
 // Note: Named ChatMessage to distinguish from the API Message type in models.go
 type ChatMessage struct {
-	ID             int64     `json:"id"`
-	ConversationID int64     `json:"conversation_id"`
-	Role           string    `json:"role"`
-	Content        string    `json:"content"`
-	Tokens         int       `json:"tokens"`
-	Cost           float64   `json:"cost"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID               int64            `json:"id"`
+	ConversationID   int64            `json:"conversation_id"`
+	ParentMessageID  *int64           `json:"parent_message_id,omitempty"` // Message this one follows in its branch
+	Role             string           `json:"role"`
+	Content          string           `json:"content"`
+	Tokens           int              `json:"tokens"`
+	PromptTokens     int              `json:"prompt_tokens"`     // Prompt tokens billed for an assistant reply
+	CompletionTokens int              `json:"completion_tokens"` // Completion tokens billed for an assistant reply
+	Cost             float64          `json:"cost"`
+	Artifacts        []ChatArtifact   `json:"artifacts,omitempty"`   // Code artifacts extracted from assistant replies
+	Seed             *int64           `json:"seed,omitempty"`        // Sampling seed the reply was generated with
+	Stopped          bool             `json:"stopped,omitempty"`     // Reply was cut short by the stop-generation endpoint
+	Attachments      []ChatAttachment `json:"attachments,omitempty"` // Files attached to a user message
+	Feedback         *ChatFeedback    `json:"feedback,omitempty"`    // The user's rating of an assistant reply
+	CreatedAt        time.Time        `json:"created_at"`
+}
+
+// ChatFeedback is the user's rating of an assistant reply
+type ChatFeedback struct {
+	Rating    string    `json:"rating"` // "up" or "down"
+	Comment   string    `json:"comment,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChatAttachment is a file attached to a chat message. Only the text extracted from it is
+// kept, and that text is sent to the model with the message
+type ChatAttachment struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Bytes       int64     `json:"bytes"`     // Size of the uploaded file
+	Chars       int       `json:"chars"`     // Characters of extracted text kept
+	Truncated   bool      `json:"truncated"` // Extracted text was cut to the configured limit
+	Text        string    `json:"-"`         // Extracted text, only loaded for context building
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ChatArtifact is a code block the model marked as an artifact (```lang artifact=name),
+// which the frontend renders as a runnable or downloadable file
+type ChatArtifact struct {
+	Index    int    `json:"index"` // Position among the message's artifacts, starting at 0
+	Language string `json:"language,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Content  string `json:"content"`
 }
 
 // ChatTokenUsage represents token usage information for AI responses in chat
@@ -139,4 +223,9 @@ type ChatStreamEvent struct {
 	Tokens    *ChatTokenUsage `json:"tokens,omitempty"`
 	Cost      float64         `json:"cost,omitempty"`
 	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"`     // Machine-readable error code on "error" events
+	Artifact  *ChatArtifact   `json:"artifact,omitempty"` // Set on "artifact" events once the code block is complete
+	Stopped   bool            `json:"stopped,omitempty"`  // Set on "done" events when the user stopped generation
+	Replaces  int64           `json:"replaces_message_id,omitempty"` // Set on "done" events of a regenerated reply: the reply it superseded
+	ResumeToken string        `json:"resume_token,omitempty"`        // Set on "start" events of SSE replies: token required to resume the stream
 }
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GeminiGenerateContentRequest Google Gemini API（generateContent / streamGenerateContent）请求；模型在 URL 路径中
+type GeminiGenerateContentRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
+	SafetySettings    []interface{}           `json:"safetySettings,omitempty"`
+}
+
+// GeminiContent 一条对话内容，role 为 user 或 model
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart 内容部分：text、inlineData、fileData、functionCall 或 functionResponse 之一
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob 内联的 base64 数据（如图片）
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFileData 通过 URI 引用的文件
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GeminiFunctionCall 模型发起的函数调用
+type GeminiFunctionCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// GeminiFunctionResponse 客户端返回的函数执行结果
+type GeminiFunctionResponse struct {
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// GeminiGenerationConfig 生成参数
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	TopK            *int     `json:"topK,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	CandidateCount  *int     `json:"candidateCount,omitempty"`
+	Seed            *int64   `json:"seed,omitempty"`
+}
+
+// GeminiTool 工具定义；仅支持 functionDeclarations，googleSearch 等内置工具被忽略
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+// GeminiFunctionDeclaration 函数声明
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// GeminiToolConfig 工具调用配置
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+// GeminiFunctionCallingConfig mode 为 AUTO、ANY 或 NONE；ANY 时可用 allowedFunctionNames 限定函数
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// geminiPartsContent 将内容部分转换为 Chat Completions 消息内容：纯文本合并为字符串，含图片时保留多模态数组
+func geminiPartsContent(parts []GeminiPart) interface{} {
+	var texts []string
+	hasImage := false
+	multimodal := make([]interface{}, 0, len(parts))
+	addImage := func(url string) {
+		hasImage = true
+		multimodal = append(multimodal, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": url},
+		})
+	}
+	for _, part := range parts {
+		switch {
+		case part.Text != "":
+			texts = append(texts, part.Text)
+			multimodal = append(multimodal, map[string]interface{}{"type": "text", "text": part.Text})
+		case part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/"):
+			addImage(fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data))
+		case part.FileData != nil && strings.HasPrefix(part.FileData.MimeType, "image/"):
+			addImage(part.FileData.FileURI)
+		}
+	}
+	if hasImage {
+		return multimodal
+	}
+	return strings.Join(texts, "\n")
+}
+
+// geminiToolChoice 将 functionCallingConfig 转换为 tool_choice
+func geminiToolChoice(config *GeminiToolConfig) interface{} {
+	if config == nil || config.FunctionCallingConfig == nil {
+		return nil
+	}
+	fc := config.FunctionCallingConfig
+	switch strings.ToUpper(fc.Mode) {
+	case "NONE":
+		return "none"
+	case "ANY":
+		if len(fc.AllowedFunctionNames) == 1 {
+			return map[string]interface{}{"type": "function", "name": fc.AllowedFunctionNames[0]}
+		}
+		return "required"
+	case "AUTO":
+		return "auto"
+	}
+	return nil
+}
+
+// ToChatCompletionRequest 将 Gemini 请求转换为内部使用的 Chat Completions 请求
+// systemInstruction 映射为 system 消息，model 角色映射为 assistant；历史中的 functionCall 与
+// functionResponse 按 Claude 工具历史相同的方式转换为文本
+func (r *GeminiGenerateContentRequest) ToChatCompletionRequest(model string, stream bool) (*ChatCompletionRequest, error) {
+	messages := make([]Message, 0, len(r.Contents)+1)
+	if r.SystemInstruction != nil {
+		if system, _ := geminiPartsContent(r.SystemInstruction.Parts).(string); system != "" {
+			messages = append(messages, Message{Role: "system", Content: system})
+		}
+	}
+
+	for _, content := range r.Contents {
+		role := "user"
+		switch content.Role {
+		case "", "user", "function":
+		case "model":
+			role = "assistant"
+		default:
+			return nil, fmt.Errorf("unsupported content role: %s", content.Role)
+		}
+
+		// 文本与图片部分合并为一条消息，遇到函数调用/结果时先输出之前的部分以保持顺序
+		var plain []GeminiPart
+		flush := func() {
+			if len(plain) > 0 {
+				messages = append(messages, Message{Role: role, Content: geminiPartsContent(plain)})
+				plain = nil
+			}
+		}
+		for _, part := range content.Parts {
+			if part.FunctionCall != nil || part.FunctionResponse != nil {
+				flush()
+			}
+			switch {
+			case part.FunctionCall != nil:
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				messages = append(messages, Message{
+					Role:    "assistant",
+					Content: fmt.Sprintf("Used tool %s with input: %s", part.FunctionCall.Name, args),
+				})
+			case part.FunctionResponse != nil:
+				result, _ := json.Marshal(part.FunctionResponse.Response)
+				messages = append(messages, Message{Role: "user", Content: string(result)})
+			default:
+				plain = append(plain, part)
+			}
+		}
+		flush()
+	}
+
+	req := &ChatCompletionRequest{
+		Model:      model,
+		Messages:   messages,
+		Stream:     stream,
+		ToolChoice: geminiToolChoice(r.ToolConfig),
+	}
+	if cfg := r.GenerationConfig; cfg != nil {
+		req.Temperature = cfg.Temperature
+		req.TopP = cfg.TopP
+		req.MaxTokens = cfg.MaxOutputTokens
+		req.Seed = cfg.Seed
+		if len(cfg.StopSequences) > 0 {
+			req.Stop = cfg.StopSequences
+		}
+	}
+
+	var allowed map[string]bool
+	if r.ToolConfig != nil && r.ToolConfig.FunctionCallingConfig != nil && len(r.ToolConfig.FunctionCallingConfig.AllowedFunctionNames) > 0 {
+		allowed = make(map[string]bool)
+		for _, name := range r.ToolConfig.FunctionCallingConfig.AllowedFunctionNames {
+			allowed[name] = true
+		}
+	}
+	for _, tool := range r.Tools {
+		for _, fn := range tool.FunctionDeclarations {
+			if fn.Name == "" || (allowed != nil && !allowed[fn.Name]) {
+				continue
+			}
+			req.Tools = append(req.Tools, Tool{
+				Type: "function",
+				Function: &FunctionDefinition{
+					Name:        fn.Name,
+					Description: fn.Description,
+					Parameters:  fn.Parameters,
+				},
+			})
+		}
+	}
+	return req, nil
+}
+
+// GeminiGenerateContentResponse generateContent 的响应，流式时每个数据块也使用此格式
+type GeminiGenerateContentResponse struct {
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string               `json:"modelVersion,omitempty"`
+	ResponseID    string               `json:"responseId,omitempty"`
+}
+
+// GeminiCandidate 一个候选回复
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// GeminiUsageMetadata 用量统计
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// NewGeminiUsageMetadata 将内部用量统计转换为 Gemini 格式
+func NewGeminiUsageMetadata(usage Usage) *GeminiUsageMetadata {
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &GeminiUsageMetadata{
+		PromptTokenCount:     usage.PromptTokens,
+		CandidatesTokenCount: usage.CompletionTokens,
+		TotalTokenCount:      total,
+	}
+}
+
+// GeminiErrorResponse Google API 错误格式
+type GeminiErrorResponse struct {
+	Error GeminiErrorDetail `json:"error"`
+}
+
+// GeminiErrorDetail 错误详情；status 为 google.rpc.Code 名称，如 INVALID_ARGUMENT
+type GeminiErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// NewGeminiError 创建 Google API 格式的错误响应
+func NewGeminiError(code int, message, status string) *GeminiErrorResponse {
+	return &GeminiErrorResponse{Error: GeminiErrorDetail{Code: code, Message: message, Status: status}}
+}
@@ -0,0 +1,38 @@
+package models
+
+// ImageGenerationRequest OpenAI 兼容的 /v1/images/generations 请求（DALL·E 与 gpt-image 模型）
+type ImageGenerationRequest struct {
+	Model             string `json:"model"`
+	Prompt            string `json:"prompt" binding:"required"`
+	N                 int    `json:"n,omitempty"`
+	Size              string `json:"size,omitempty"`
+	Quality           string `json:"quality,omitempty"`
+	Style             string `json:"style,omitempty"`           // DALL·E 3：vivid 或 natural
+	ResponseFormat    string `json:"response_format,omitempty"` // url 或 b64_json
+	Background        string `json:"background,omitempty"`      // gpt-image：transparent/opaque/auto
+	OutputFormat      string `json:"output_format,omitempty"`   // gpt-image：png/jpeg/webp
+	OutputCompression *int   `json:"output_compression,omitempty"`
+	Moderation        string `json:"moderation,omitempty"`
+	User              string `json:"user,omitempty"`
+}
+
+// ImageData 单张生成的图片，按 response_format 返回 URL 或 base64
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// ImageUsage gpt-image 模型返回的 token 用量
+type ImageUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ImageGenerationResponse OpenAI 兼容的图片生成响应
+type ImageGenerationResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+	Usage   *ImageUsage `json:"usage,omitempty"`
+}
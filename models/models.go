@@ -8,16 +8,33 @@ import (
 // ChatCompletionRequest OpenAI聊天完成请求
 type ChatCompletionRequest struct {
 	Model        string    `json:"model" binding:"required"`
-	Messages     []Message `json:"messages"` // 可选，Codex CLI 不使用
-	Instructions string    `json:"instructions,omitempty"` // Codex CLI 使用此字段
+	Messages     []Message `json:"messages"`
 	Stream       bool      `json:"stream,omitempty"`
 	Temperature  *float64  `json:"temperature,omitempty"`
 	MaxTokens    *int      `json:"max_tokens,omitempty"`
 	TopP         *float64  `json:"top_p,omitempty"`
 	Stop         []string  `json:"stop,omitempty"`
+	Seed         *int64    `json:"seed,omitempty"` // 采样种子，支持的提供商据此尽量返回可复现的结果
 	User         string    `json:"user,omitempty"`
 	Tools        []Tool    `json:"tools,omitempty"`        // 工具定义
 	ToolChoice   interface{} `json:"tool_choice,omitempty"` // 工具选择策略
+	ExtraBody    map[string]interface{} `json:"extra_body,omitempty"`    // 透传给直连上游的厂商参数（按提供商白名单校验）
+	VendorParams map[string]interface{} `json:"vendor_params,omitempty"` // extra_body 的别名
+}
+
+// VendorExtra 合并 vendor_params 与 extra_body（同名参数以 extra_body 为准）
+func (r *ChatCompletionRequest) VendorExtra() map[string]interface{} {
+	if len(r.VendorParams) == 0 {
+		return r.ExtraBody
+	}
+	extra := make(map[string]interface{}, len(r.VendorParams)+len(r.ExtraBody))
+	for k, v := range r.VendorParams {
+		extra[k] = v
+	}
+	for k, v := range r.ExtraBody {
+		extra[k] = v
+	}
+	return extra
 }
 
 // Tool OpenAI工具定义
@@ -79,6 +96,7 @@ type ChatCompletionStreamResponse struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"` // Final chunk only, when stream_options.include_usage is set
 }
 
 // Choice 选择结构
@@ -131,9 +149,19 @@ type ErrorResponse struct {
 
 // ErrorDetail 错误详情
 type ErrorDetail struct {
-	Message string `json:"message"`
-	Type    string `json:"type"`
-	Code    string `json:"code,omitempty"`
+	Message string           `json:"message"`
+	Type    string           `json:"type"`
+	Code    string           `json:"code,omitempty"`
+	Balance *BalanceGuidance `json:"balance,omitempty"` // Only set on 402 insufficient balance
+}
+
+// BalanceGuidance 余额不足（402）时返回的充值指引，金额单位为美元
+type BalanceGuidance struct {
+	Balance       float64 `json:"balance"`        // Current account balance
+	EstimatedCost float64 `json:"estimated_cost"` // Estimated cost of the rejected request
+	MinimumTopUp  float64 `json:"minimum_top_up"` // Smallest recharge that covers the estimated cost
+	Currency      string  `json:"currency"`
+	RechargeURL   string  `json:"recharge_url,omitempty"`
 }
 
 // CursorMessage Cursor消息格式
@@ -224,6 +252,35 @@ func (m *Message) GetStringContent() string {
 	}
 }
 
+// HasImageContent 判断消息是否包含 image_url 内容部分
+func (m *Message) HasImageContent() bool {
+	switch content := m.Content.(type) {
+	case []ContentPart:
+		for _, part := range content {
+			if part.Type == "image_url" {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range content {
+			if part, ok := item.(map[string]interface{}); ok && part["type"] == "image_url" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasImageContent 判断请求中是否有消息包含图片，此类请求需要支持视觉输入的提供商
+func (r *ChatCompletionRequest) HasImageContent() bool {
+	for i := range r.Messages {
+		if r.Messages[i].HasImageContent() {
+			return true
+		}
+	}
+	return false
+}
+
 // ToCursorMessages 将OpenAI消息转换为Cursor格式
 // 注意：Cursor API 要求对话必须以用户消息开始，所以系统消息会被合并到第一条用户消息中
 func ToCursorMessages(messages []Message, systemPromptInject string) []CursorMessage {
@@ -338,4 +395,11 @@ func NewErrorResponse(message, errorType, code string) *ErrorResponse {
 			Code:    code,
 		},
 	}
-}
\ No newline at end of file
+}
+
+// NewInsufficientBalanceResponse 创建附带充值指引的 402 错误响应
+func NewInsufficientBalanceResponse(message, code string, guidance *BalanceGuidance) *ErrorResponse {
+	resp := NewErrorResponse(message, "payment_required", code)
+	resp.Error.Balance = guidance
+	return resp
+}
@@ -0,0 +1,47 @@
+package models
+
+// ModerationRequest OpenAI 兼容的 /v1/moderations 请求；input 可以是字符串、字符串数组，
+// 或 omni-moderation 的多模态数组（{"type":"text","text":...} / {"type":"image_url",...}）
+type ModerationRequest struct {
+	Model string      `json:"model,omitempty"`
+	Input interface{} `json:"input" binding:"required"`
+}
+
+// ModerationTexts returns the text parts of the input; image parts are skipped
+func (r *ModerationRequest) ModerationTexts() []string {
+	switch v := r.Input.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch part := item.(type) {
+			case string:
+				texts = append(texts, part)
+			case map[string]interface{}:
+				if part["type"] == "text" {
+					if text, ok := part["text"].(string); ok {
+						texts = append(texts, text)
+					}
+				}
+			}
+		}
+		return texts
+	}
+	return nil
+}
+
+// ModerationResult 单个输入的审核结果
+type ModerationResult struct {
+	Flagged                   bool                `json:"flagged"`
+	Categories                map[string]bool     `json:"categories"`
+	CategoryScores            map[string]float64  `json:"category_scores"`
+	CategoryAppliedInputTypes map[string][]string `json:"category_applied_input_types,omitempty"`
+}
+
+// ModerationResponse OpenAI 兼容的审核响应
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
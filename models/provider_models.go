@@ -32,5 +32,7 @@ type ChatRequest struct {
 	Messages    []Message `json:"messages"`
 	Stream      bool      `json:"stream"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"` // nil leaves the upstream default; 0 is sent as-is
+	Seed        *int64    `json:"seed,omitempty"`        // Sampling seed, sent to providers that support one
+	ExtraBody   map[string]interface{} `json:"extra_body,omitempty"` // Vendor parameters passed through to the upstream
 }
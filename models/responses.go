@@ -0,0 +1,296 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ResponsesRequest OpenAI Responses API（/v1/responses）请求；input 可以是字符串或输入项数组
+type ResponsesRequest struct {
+	Model              string            `json:"model" binding:"required"`
+	Input              json.RawMessage   `json:"input"`
+	Instructions       string            `json:"instructions,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	Temperature        *float64          `json:"temperature,omitempty"`
+	TopP               *float64          `json:"top_p,omitempty"`
+	Tools              []ResponsesTool   `json:"tools,omitempty"`
+	ToolChoice         interface{}       `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	User               string            `json:"user,omitempty"`
+}
+
+// ResponsesTool Responses API 的工具定义；function 工具的字段直接位于顶层
+type ResponsesTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
+// ResponsesInputItem input 数组中的一项：消息（type 为空或 message）、function_call 或 function_call_output
+type ResponsesInputItem struct {
+	Type      string          `json:"type,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments string          `json:"arguments,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+}
+
+// ResponsesContentPart 消息内容部分：input_text / output_text / input_image / refusal
+type ResponsesContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Refusal  string `json:"refusal,omitempty"`
+}
+
+// InputItems 解析 input；字符串输入视为一条 user 消息
+func (r *ResponsesRequest) InputItems() ([]ResponsesInputItem, error) {
+	if len(r.Input) == 0 || string(r.Input) == "null" {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(r.Input, &text); err == nil {
+		content, _ := json.Marshal(text)
+		return []ResponsesInputItem{{Type: "message", Role: "user", Content: content}}, nil
+	}
+	var items []ResponsesInputItem
+	if err := json.Unmarshal(r.Input, &items); err != nil {
+		return nil, errors.New("input must be a string or an array of input items")
+	}
+	return items, nil
+}
+
+// responsesMessageContent 将消息内容转换为 Chat Completions 格式：纯文本合并为字符串，含图片时保留多模态数组
+func responsesMessageContent(raw json.RawMessage) interface{} {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []ResponsesContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+
+	var texts []string
+	hasImage := false
+	multimodal := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			texts = append(texts, part.Text)
+			multimodal = append(multimodal, map[string]interface{}{"type": "text", "text": part.Text})
+		case "refusal":
+			texts = append(texts, part.Refusal)
+			multimodal = append(multimodal, map[string]interface{}{"type": "text", "text": part.Refusal})
+		case "input_image":
+			if part.ImageURL != "" {
+				hasImage = true
+				multimodal = append(multimodal, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]interface{}{"url": part.ImageURL},
+				})
+			}
+		}
+	}
+	if hasImage {
+		return multimodal
+	}
+	return strings.Join(texts, "\n")
+}
+
+// responsesOutputText 将 function_call_output 的 output（字符串或内容数组）转换为文本
+func responsesOutputText(raw json.RawMessage) string {
+	content := responsesMessageContent(raw)
+	if text, ok := content.(string); ok {
+		return text
+	}
+	return string(raw)
+}
+
+// ToChatCompletionRequest 将 Responses 请求转换为内部使用的 Chat Completions 请求
+// instructions 与 developer 消息映射为 system 消息；历史中的 function_call 与 function_call_output
+// 按 Claude 工具历史相同的方式转换为文本；reasoning 等其他输入项被忽略
+func (r *ResponsesRequest) ToChatCompletionRequest() (*ChatCompletionRequest, error) {
+	items, err := r.InputItems()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(items)+1)
+	if r.Instructions != "" {
+		messages = append(messages, Message{Role: "system", Content: r.Instructions})
+	}
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			if role == "" {
+				return nil, errors.New("input message is missing role")
+			}
+			messages = append(messages, Message{Role: role, Content: responsesMessageContent(item.Content)})
+		case "function_call":
+			messages = append(messages, Message{
+				Role:    "assistant",
+				Content: fmt.Sprintf("Used tool %s with input: %s", item.Name, item.Arguments),
+			})
+		case "function_call_output":
+			messages = append(messages, Message{Role: "user", Content: responsesOutputText(item.Output)})
+		}
+	}
+
+	req := &ChatCompletionRequest{
+		Model:       r.Model,
+		Messages:    messages,
+		Stream:      r.Stream,
+		Temperature: r.Temperature,
+		MaxTokens:   r.MaxOutputTokens,
+		TopP:        r.TopP,
+		User:        r.User,
+		ToolChoice:  r.ToolChoice,
+	}
+	for _, tool := range r.Tools {
+		if tool.Type != "function" || tool.Name == "" {
+			continue
+		}
+		req.Tools = append(req.Tools, Tool{
+			Type: "function",
+			Function: &FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+				Strict:      tool.Strict,
+			},
+		})
+	}
+	return req, nil
+}
+
+// Response Responses API 的 response 对象
+type Response struct {
+	ID                 string                `json:"id"`
+	Object             string                `json:"object"`
+	CreatedAt          int64                 `json:"created_at"`
+	Status             string                `json:"status"`
+	Error              *ResponseError        `json:"error"`
+	IncompleteDetails  interface{}           `json:"incomplete_details"`
+	Instructions       interface{}           `json:"instructions"`
+	MaxOutputTokens    *int                  `json:"max_output_tokens"`
+	Model              string                `json:"model"`
+	Output             []ResponsesOutputItem `json:"output"`
+	ParallelToolCalls  bool                  `json:"parallel_tool_calls"`
+	PreviousResponseID interface{}           `json:"previous_response_id"`
+	Store              bool                  `json:"store"`
+	Temperature        *float64              `json:"temperature"`
+	TopP               *float64              `json:"top_p"`
+	ToolChoice         interface{}           `json:"tool_choice"`
+	Tools              []ResponsesTool       `json:"tools"`
+	Usage              *ResponsesUsage       `json:"usage"`
+	User               string                `json:"user,omitempty"`
+	Metadata           map[string]string     `json:"metadata"`
+}
+
+// ResponseError 失败的 response 中的错误信息
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponsesOutputItem output 数组中的一项：message 或 function_call
+type ResponsesOutputItem struct {
+	ID        string                   `json:"id"`
+	Type      string                   `json:"type"`
+	Status    string                   `json:"status"`
+	Role      string                   `json:"role,omitempty"`
+	Content   []ResponsesOutputContent `json:"content,omitempty"`
+	CallID    string                   `json:"call_id,omitempty"`
+	Name      string                   `json:"name,omitempty"`
+	Arguments string                   `json:"arguments,omitempty"`
+}
+
+// ResponsesOutputContent message 输出项的内容部分
+type ResponsesOutputContent struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// ResponsesUsage Responses API 的用量统计
+type ResponsesUsage struct {
+	InputTokens         int                    `json:"input_tokens"`
+	InputTokensDetails  ResponsesInputDetails  `json:"input_tokens_details"`
+	OutputTokens        int                    `json:"output_tokens"`
+	OutputTokensDetails ResponsesOutputDetails `json:"output_tokens_details"`
+	TotalTokens         int                    `json:"total_tokens"`
+}
+
+// ResponsesInputDetails 输入 token 明细
+type ResponsesInputDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// ResponsesOutputDetails 输出 token 明细
+type ResponsesOutputDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// NewResponse 按请求创建 status 为 in_progress 的 response 对象，回显请求参数
+func NewResponse(id string, createdAt int64, request *ResponsesRequest) *Response {
+	resp := &Response{
+		ID:                id,
+		Object:            "response",
+		CreatedAt:         createdAt,
+		Status:            "in_progress",
+		MaxOutputTokens:   request.MaxOutputTokens,
+		Model:             request.Model,
+		Output:            []ResponsesOutputItem{},
+		ParallelToolCalls: true,
+		Temperature:       request.Temperature,
+		TopP:              request.TopP,
+		ToolChoice:        request.ToolChoice,
+		Tools:             request.Tools,
+		User:              request.User,
+		Metadata:          request.Metadata,
+	}
+	if request.Instructions != "" {
+		resp.Instructions = request.Instructions
+	}
+	if request.ParallelToolCalls != nil {
+		resp.ParallelToolCalls = *request.ParallelToolCalls
+	}
+	if resp.ToolChoice == nil {
+		resp.ToolChoice = "auto"
+	}
+	if resp.Tools == nil {
+		resp.Tools = []ResponsesTool{}
+	}
+	if resp.Metadata == nil {
+		resp.Metadata = map[string]string{}
+	}
+	return resp
+}
+
+// NewResponsesUsage 将内部用量统计转换为 Responses API 格式
+func NewResponsesUsage(usage Usage) *ResponsesUsage {
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &ResponsesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  total,
+	}
+}
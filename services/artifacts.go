@@ -0,0 +1,149 @@
+package services
+
+import (
+	"Curry2API-go/models"
+	"path"
+	"strings"
+)
+
+// maxArtifactsPerMessage caps how many artifacts are extracted from one reply
+const maxArtifactsPerMessage = 32
+
+// ArtifactScanner detects code artifacts in a streamed assistant reply. A fenced
+// code block is an artifact when its info string carries an "artifact" marker:
+//
+//	```python artifact=fib.py
+//	```html artifact
+//	```bash artifact filename=setup.sh
+//
+// Ordinary code blocks are left alone. Feed content deltas to Write in order; each
+// artifact is returned once its closing fence has been seen
+type ArtifactScanner struct {
+	line    strings.Builder
+	fence   string               // Opening fence of the current code block, empty outside blocks
+	current *models.ChatArtifact // Artifact being collected, nil inside ordinary code blocks
+	body    strings.Builder
+	count   int
+}
+
+// Write consumes a content delta and returns the artifacts completed by it
+func (s *ArtifactScanner) Write(delta string) []models.ChatArtifact {
+	var done []models.ChatArtifact
+	for {
+		i := strings.IndexByte(delta, '\n')
+		if i < 0 {
+			s.line.WriteString(delta)
+			return done
+		}
+		s.line.WriteString(delta[:i+1])
+		if artifact := s.handleLine(s.line.String()); artifact != nil {
+			done = append(done, *artifact)
+		}
+		s.line.Reset()
+		delta = delta[i+1:]
+	}
+}
+
+// Flush ends the reply, completing an artifact whose closing fence had no trailing
+// newline. Artifacts left unclosed are incomplete and are dropped
+func (s *ArtifactScanner) Flush() []models.ChatArtifact {
+	if s.line.Len() == 0 {
+		return nil
+	}
+	last := s.line.String()
+	s.line.Reset()
+	if artifact := s.handleLine(last); artifact != nil {
+		return []models.ChatArtifact{*artifact}
+	}
+	return nil
+}
+
+// handleLine processes one line including its newline, returning a completed artifact
+func (s *ArtifactScanner) handleLine(line string) *models.ChatArtifact {
+	trimmed := strings.TrimSpace(line)
+
+	if s.fence == "" {
+		fence := leadingFence(trimmed)
+		if fence == "" {
+			return nil
+		}
+		s.fence = fence
+		s.body.Reset()
+		s.current = nil
+		if s.count < maxArtifactsPerMessage {
+			s.current = parseArtifactInfo(trimmed[len(fence):])
+		}
+		return nil
+	}
+
+	// A closing fence uses the same character and is at least as long as the opening one
+	if strings.HasPrefix(trimmed, s.fence) && strings.Trim(trimmed, s.fence[:1]) == "" {
+		s.fence = ""
+		artifact := s.current
+		s.current = nil
+		if artifact == nil {
+			return nil
+		}
+		artifact.Index = s.count
+		artifact.Content = s.body.String()
+		s.count++
+		return artifact
+	}
+
+	if s.current != nil {
+		s.body.WriteString(line)
+	}
+	return nil
+}
+
+// leadingFence returns the ``` or ~~~ run that opens a code block, or ""
+func leadingFence(line string) string {
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// parseArtifactInfo parses a code block info string, returning nil when it has no artifact marker
+func parseArtifactInfo(info string) *models.ChatArtifact {
+	fields := strings.Fields(info)
+	var artifact *models.ChatArtifact
+	filename := ""
+	for _, field := range fields {
+		switch {
+		case field == "artifact":
+			artifact = &models.ChatArtifact{}
+		case strings.HasPrefix(field, "artifact="):
+			artifact = &models.ChatArtifact{}
+			filename = strings.TrimPrefix(field, "artifact=")
+		case strings.HasPrefix(field, "filename="):
+			filename = strings.TrimPrefix(field, "filename=")
+		}
+	}
+	if artifact == nil {
+		return nil
+	}
+
+	if len(fields) > 0 && !strings.Contains(fields[0], "=") && fields[0] != "artifact" {
+		artifact.Language = strings.ToLower(fields[0])
+	}
+	// Only keep a plain file name so the frontend can offer it as a download name
+	filename = path.Base(strings.ReplaceAll(strings.Trim(filename, `"'`), `\`, "/"))
+	if filename != "." && filename != "/" && len(filename) <= 255 {
+		artifact.Filename = filename
+	}
+	return artifact
+}
+
+// ExtractArtifacts returns the artifacts in a complete assistant reply
+func ExtractArtifacts(content string) []models.ChatArtifact {
+	var s ArtifactScanner
+	return append(s.Write(content), s.Flush()...)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"Curry2API-go/services/providers"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultTranscriptionPricePerMinute is the USD price per minute of audio for transcription
+// models without a built-in price, such as models served by custom upstreams
+const defaultTranscriptionPricePerMinute = 0.006
+
+// estimatedAudioBitrate is the bitrate, in bits per second, assumed when the upstream
+// reports no duration; 128 kbps is typical of compressed speech recordings
+const estimatedAudioBitrate = 128_000
+
+// isBuiltinTranscriptionModel reports whether model is an OpenAI speech-to-text model
+func isBuiltinTranscriptionModel(model string) bool {
+	lower := strings.ToLower(model)
+	return strings.HasPrefix(lower, "whisper-") || strings.HasSuffix(lower, "-transcribe")
+}
+
+// TranscriptionPricePerMinute returns the USD price per minute of audio, following OpenAI's
+// list prices (gpt-4o transcription models are billed by token upstream; their prices are
+// OpenAI's estimated per-minute costs)
+func TranscriptionPricePerMinute(model string) float64 {
+	switch strings.ToLower(model) {
+	case "whisper-1", "gpt-4o-transcribe":
+		return 0.006
+	case "gpt-4o-mini-transcribe":
+		return 0.003
+	}
+	return defaultTranscriptionPricePerMinute
+}
+
+// TranscriptionCost returns the USD cost of transcribing seconds of audio with the model
+func TranscriptionCost(model string, seconds float64) float64 {
+	return seconds * TranscriptionPricePerMinute(model) / 60
+}
+
+// TranscriptionSeconds returns the audio duration reported in a transcription response:
+// usage.seconds (json), duration (verbose_json) or the end of the last cue (srt, vtt).
+// It returns 0 when the response carries no duration
+func TranscriptionSeconds(format string, body []byte) float64 {
+	if format == "srt" || format == "vtt" {
+		return lastCueEnd(body)
+	}
+
+	var resp struct {
+		Duration float64 `json:"duration"`
+		Usage    *struct {
+			Type    string  `json:"type"`
+			Seconds float64 `json:"seconds"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0
+	}
+	if resp.Usage != nil && resp.Usage.Type == "duration" && resp.Usage.Seconds > 0 {
+		return resp.Usage.Seconds
+	}
+	return resp.Duration
+}
+
+// EstimateAudioSeconds estimates the duration of an audio file from its size
+func EstimateAudioSeconds(size int64) float64 {
+	return float64(size) * 8 / estimatedAudioBitrate
+}
+
+// cueTimestampPattern matches SRT (00:01:02,345) and WebVTT (00:01:02.345) timestamps
+var cueTimestampPattern = regexp.MustCompile(`(\d{2,}):(\d{2}):(\d{2})[.,](\d{3})`)
+
+// lastCueEnd returns the latest timestamp in an SRT or WebVTT document, in seconds
+func lastCueEnd(body []byte) float64 {
+	var last float64
+	for _, m := range cueTimestampPattern.FindAllSubmatch(body, -1) {
+		hours, _ := strconv.Atoi(string(m[1]))
+		minutes, _ := strconv.Atoi(string(m[2]))
+		seconds, _ := strconv.Atoi(string(m[3]))
+		millis, _ := strconv.Atoi(string(m[4]))
+		if t := float64(hours*3600+minutes*60+seconds) + float64(millis)/1000; t > last {
+			last = t
+		}
+	}
+	return last
+}
+
+// transcriptionCandidates returns the providers that can serve the transcription model:
+// custom upstreams listing it, then OpenAI for its own models. Caller holds r.mu
+func (r *ProviderRouter) transcriptionCandidates(model string, keyTags []string) []string {
+	candidates := append([]string(nil), r.modelUpstreams[model]...)
+	if isBuiltinTranscriptionModel(model) {
+		candidates = append(candidates, "openai")
+	}
+
+	available := candidates[:0]
+	for _, name := range candidates {
+		provider, exists := r.providers[name]
+		if !exists || !provider.IsAvailable() || !r.allow(name, keyTags) {
+			continue
+		}
+		if _, ok := provider.(providers.TranscriptionClient); ok {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// GetTranscriptionProvider returns the provider that serves the transcription model,
+// balanced across the candidates like chat models
+func (r *ProviderRouter) GetTranscriptionProvider(model string, keyTags []string) (providers.TranscriptionClient, string, bool) {
+	if r == nil {
+		return nil, "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	candidates := r.transcriptionCandidates(model, keyTags)
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+	name, ok := r.balancer.pick(model, candidates)
+	if !ok {
+		return nil, "", false
+	}
+	return r.providers[name].(providers.TranscriptionClient), name, true
+}
+
+// defaultSpeechPricePerMillionChars is the USD price per million input characters for
+// speech models without a built-in price, such as models served by custom upstreams
+const defaultSpeechPricePerMillionChars = 15.0
+
+// isBuiltinSpeechModel reports whether model is an OpenAI text-to-speech model
+func isBuiltinSpeechModel(model string) bool {
+	lower := strings.ToLower(model)
+	return strings.HasPrefix(lower, "tts-") || strings.HasSuffix(lower, "-tts")
+}
+
+// SpeechPricePerMillionChars returns the USD price per million input characters, following
+// OpenAI's list prices (gpt-4o-mini-tts is billed by token upstream; its price is the
+// per-character equivalent of its estimated per-minute cost)
+func SpeechPricePerMillionChars(model string) float64 {
+	switch strings.ToLower(model) {
+	case "tts-1":
+		return 15.0
+	case "tts-1-hd":
+		return 30.0
+	case "gpt-4o-mini-tts":
+		return 12.0
+	}
+	return defaultSpeechPricePerMillionChars
+}
+
+// SpeechCost returns the USD cost of synthesizing input with the model
+func SpeechCost(model, input string) float64 {
+	return float64(utf8.RuneCountInString(input)) * SpeechPricePerMillionChars(model) / 1_000_000
+}
+
+// speechCandidates returns the providers that can serve the speech model: custom
+// upstreams listing it, then OpenAI for its own TTS models. Caller holds r.mu
+func (r *ProviderRouter) speechCandidates(model string, keyTags []string) []string {
+	candidates := append([]string(nil), r.modelUpstreams[model]...)
+	if isBuiltinSpeechModel(model) {
+		candidates = append(candidates, "openai")
+	}
+
+	available := candidates[:0]
+	for _, name := range candidates {
+		provider, exists := r.providers[name]
+		if !exists || !provider.IsAvailable() || !r.allow(name, keyTags) {
+			continue
+		}
+		if _, ok := provider.(providers.SpeechClient); ok {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// GetSpeechProvider returns the provider that serves the speech model, balanced
+// across the candidates like chat models
+func (r *ProviderRouter) GetSpeechProvider(model string, keyTags []string) (providers.SpeechClient, string, bool) {
+	if r == nil {
+		return nil, "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	candidates := r.speechCandidates(model, keyTags)
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+	name, ok := r.balancer.pick(model, candidates)
+	if !ok {
+		return nil, "", false
+	}
+	return r.providers[name].(providers.SpeechClient), name, true
+}
@@ -0,0 +1,600 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Endpoints a batch may target; every request of a batch uses the batch's endpoint
+const (
+	BatchEndpointChatCompletions = "/v1/chat/completions"
+	BatchEndpointEmbeddings      = "/v1/embeddings"
+)
+
+// batchMaxLineBytes bounds a single JSONL line of batch input
+const batchMaxLineBytes = 4 << 20
+
+// BatchInputLine is one request of the batch input JSONL, in the OpenAI batch format
+type BatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+
+	Model string `json:"-"` // Normalized model of the body
+}
+
+// BatchResultLine is one line of the batch results JSONL
+type BatchResultLine struct {
+	ID       string               `json:"id"`
+	CustomID string               `json:"custom_id"`
+	Response *BatchResultResponse `json:"response"`
+	Error    *BatchResultError    `json:"error"`
+}
+
+// BatchResultResponse is the upstream status and body of a processed request
+type BatchResultResponse struct {
+	StatusCode int             `json:"status_code"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// BatchResultError explains why a request was not processed
+type BatchResultError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewBatchResultLine converts a stored batch request to its results line; pending
+// requests have neither a response nor an error yet
+func NewBatchResultLine(r *database.BatchRequest) *BatchResultLine {
+	line := &BatchResultLine{ID: fmt.Sprintf("batch_req_%d", r.ID), CustomID: r.CustomID}
+	switch r.Status {
+	case database.BatchRequestCompleted, database.BatchRequestFailed:
+		body := json.RawMessage(r.Response)
+		if len(body) == 0 {
+			body = json.RawMessage("null")
+		}
+		line.Response = &BatchResultResponse{StatusCode: r.StatusCode, Body: body}
+	case database.BatchRequestCancelled:
+		line.Error = &BatchResultError{Code: "batch_cancelled", Message: "Batch was cancelled before this request was processed"}
+	}
+	return line
+}
+
+// BatchBillingFunc deducts the cost of a successful batch request from the key owner
+type BatchBillingFunc func(userID int64, tokens int, apiToken, model string)
+
+// BatchProcessor runs batch requests in the background on a fixed worker pool.
+// Unfinished batches are resumed on start, so a restart only re-runs the requests
+// that were in flight
+type BatchProcessor struct {
+	cfg         *config.Config
+	router      *ProviderRouter
+	bill        BatchBillingFunc
+	workers     int
+	maxRequests int
+
+	queue  chan *batchTask
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]*batchRun
+}
+
+// batchRun tracks a batch being dispatched
+type batchRun struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cancelled bool
+	pending   sync.WaitGroup
+}
+
+type batchTask struct {
+	batch   *database.Batch
+	request *database.BatchRequest
+	run     *batchRun
+}
+
+var (
+	batchProcessor     *BatchProcessor
+	batchProcessorOnce sync.Once
+)
+
+// InitBatchProcessor creates the singleton batch processor
+func InitBatchProcessor(cfg *config.Config, router *ProviderRouter, workers, maxRequests int, bill BatchBillingFunc) *BatchProcessor {
+	batchProcessorOnce.Do(func() {
+		if workers <= 0 {
+			workers = 1
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		batchProcessor = &BatchProcessor{
+			cfg:         cfg,
+			router:      router,
+			bill:        bill,
+			workers:     workers,
+			maxRequests: maxRequests,
+			queue:       make(chan *batchTask, workers),
+			ctx:         ctx,
+			cancel:      cancel,
+			active:      make(map[string]*batchRun),
+		}
+	})
+	return batchProcessor
+}
+
+// GetBatchProcessor returns the singleton processor, or nil if it was not initialized
+func GetBatchProcessor() *BatchProcessor {
+	return batchProcessor
+}
+
+// Start launches the workers and resumes batches left unfinished by the last run
+func (p *BatchProcessor) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	if cancelling, err := database.ListBatchesByStatus(database.BatchStatusCancelling); err != nil {
+		logrus.WithError(err).Warn("Failed to load cancelling batches")
+	} else {
+		for _, b := range cancelling {
+			if err := database.CancelBatch(b.ID); err != nil {
+				logrus.WithError(err).WithField("batch_id", b.ID).Warn("Failed to cancel batch")
+			}
+		}
+	}
+	inProgress, err := database.ListBatchesByStatus(database.BatchStatusInProgress)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load unfinished batches")
+	}
+	for _, b := range inProgress {
+		p.submit(b)
+	}
+	logrus.Infof("Batch processor started (%d workers, %d batches resumed)", p.workers, len(inProgress))
+}
+
+// Stop stops dispatching and waits for the workers. Requests interrupted by the
+// shutdown stay pending and run again when the processor next starts
+func (p *BatchProcessor) Stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// MaxRequests is the largest number of requests accepted in one batch
+func (p *BatchProcessor) MaxRequests() int {
+	return p.maxRequests
+}
+
+// ParseInput parses and validates batch input JSONL for endpoint. Model names are
+// normalized; access checks for the caller's key are left to the handler
+func (p *BatchProcessor) ParseInput(endpoint string, input []byte) ([]*BatchInputLine, error) {
+	if endpoint != BatchEndpointChatCompletions && endpoint != BatchEndpointEmbeddings {
+		return nil, fmt.Errorf("unsupported endpoint %q, expected %s or %s", endpoint, BatchEndpointChatCompletions, BatchEndpointEmbeddings)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64*1024), batchMaxLineBytes)
+	seen := make(map[string]bool)
+	var lines []*BatchInputLine
+	for n := 1; scanner.Scan(); n++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if len(lines) >= p.maxRequests {
+			return nil, fmt.Errorf("batch exceeds the limit of %d requests", p.maxRequests)
+		}
+
+		line := &BatchInputLine{}
+		if err := json.Unmarshal(raw, line); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %v", n, err)
+		}
+		line.CustomID = strings.TrimSpace(line.CustomID)
+		switch {
+		case line.CustomID == "":
+			return nil, fmt.Errorf("line %d: custom_id is required", n)
+		case len(line.CustomID) > 255:
+			return nil, fmt.Errorf("line %d: custom_id must be at most 255 characters", n)
+		case seen[line.CustomID]:
+			return nil, fmt.Errorf("line %d: duplicate custom_id %q", n, line.CustomID)
+		case line.Method != "" && !strings.EqualFold(line.Method, http.MethodPost):
+			return nil, fmt.Errorf("line %d: method must be POST", n)
+		case line.URL != "" && line.URL != endpoint:
+			return nil, fmt.Errorf("line %d: url must be %s", n, endpoint)
+		}
+		seen[line.CustomID] = true
+
+		if err := p.normalizeBody(endpoint, line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("batch input contains no requests")
+	}
+	return lines, nil
+}
+
+// normalizeBody validates the request body of a line and rewrites it with the
+// normalized model name
+func (p *BatchProcessor) normalizeBody(endpoint string, line *BatchInputLine) error {
+	switch endpoint {
+	case BatchEndpointChatCompletions:
+		var req models.ChatCompletionRequest
+		if err := json.Unmarshal(line.Body, &req); err != nil {
+			return fmt.Errorf("invalid body: %v", err)
+		}
+		if !p.cfg.IsValidModel(req.Model) {
+			return fmt.Errorf("invalid model specified: %s", req.Model)
+		}
+		if len(req.Messages) == 0 {
+			return errors.New("messages cannot be empty")
+		}
+		if len(req.Tools) > 0 {
+			return errors.New("tools are not supported in batches")
+		}
+		req.Model = p.cfg.NormalizeModelName(req.Model)
+		req.Stream = false
+		line.Model = req.Model
+		body, err := json.Marshal(&req)
+		if err != nil {
+			return err
+		}
+		line.Body = body
+
+	case BatchEndpointEmbeddings:
+		var req models.EmbeddingRequest
+		if err := json.Unmarshal(line.Body, &req); err != nil {
+			return fmt.Errorf("invalid body: %v", err)
+		}
+		if req.Model == "" {
+			return errors.New("model is required")
+		}
+		if len(req.EmbeddingInputs()) == 0 {
+			return errors.New("input must be a non-empty string, array of strings, or array of token arrays")
+		}
+		line.Model = req.Model
+	}
+	return nil
+}
+
+// Create stores a new batch of parsed lines and queues it for processing
+func (p *BatchProcessor) Create(batch *database.Batch, lines []*BatchInputLine) error {
+	batch.ID = "batch_" + utils.GenerateRandomString(24)
+	requests := make([]*database.BatchRequest, len(lines))
+	for i, line := range lines {
+		requests[i] = &database.BatchRequest{
+			Line:     i + 1,
+			CustomID: line.CustomID,
+			Body:     string(line.Body),
+		}
+	}
+	if err := database.CreateBatch(batch, requests); err != nil {
+		return err
+	}
+	p.submit(batch)
+	return nil
+}
+
+// Cancel stops a batch: requests not yet started are cancelled, running ones finish.
+// Returns false when the batch is no longer in progress
+func (p *BatchProcessor) Cancel(id string) (bool, error) {
+	changed, err := database.RequestBatchCancel(id)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	p.mu.Lock()
+	run, ok := p.active[id]
+	if ok {
+		run.cancelled = true
+		run.cancel()
+	}
+	p.mu.Unlock()
+	if !ok {
+		return true, database.CancelBatch(id)
+	}
+	return true, nil
+}
+
+// submit dispatches the pending requests of batch to the workers in the background
+func (p *BatchProcessor) submit(batch *database.Batch) {
+	run := &batchRun{}
+	run.ctx, run.cancel = context.WithCancel(p.ctx)
+	p.mu.Lock()
+	p.active[batch.ID] = run
+	p.mu.Unlock()
+
+	go func() {
+		defer run.cancel()
+
+		requests, err := database.ListBatchRequests(batch.ID, true)
+		if err != nil {
+			logrus.WithError(err).WithField("batch_id", batch.ID).Error("Failed to load batch requests")
+			p.mu.Lock()
+			delete(p.active, batch.ID)
+			p.mu.Unlock()
+			return
+		}
+	dispatch:
+		for _, r := range requests {
+			run.pending.Add(1)
+			select {
+			case p.queue <- &batchTask{batch: batch, request: r, run: run}:
+			case <-run.ctx.Done():
+				run.pending.Done()
+				break dispatch
+			}
+		}
+		run.pending.Wait()
+
+		// Leaving the active set under the same lock as reading cancelled: a Cancel that
+		// finds the batch gone finalizes the cancellation itself
+		p.mu.Lock()
+		cancelled := run.cancelled
+		delete(p.active, batch.ID)
+		p.mu.Unlock()
+		finished := false
+		switch {
+		case cancelled:
+			err = database.CancelBatch(batch.ID)
+		case p.ctx.Err() != nil:
+			// Shutting down: pending requests resume on the next start
+			return
+		default:
+			finished, err = database.FinishBatch(batch.ID)
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("batch_id", batch.ID).Error("Failed to finish batch")
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"batch_id":  batch.ID,
+			"cancelled": cancelled,
+		}).Info("Batch finished")
+
+		if finished {
+			p.notifyCompleted(batch.ID)
+		}
+	}()
+}
+
+// notifyCompleted sends the owner of a completed batch its webhook or email notification
+func (p *BatchProcessor) notifyCompleted(id string) {
+	batch, err := database.GetBatch(id)
+	if err != nil {
+		logrus.WithError(err).WithField("batch_id", id).Warn("Failed to load batch for completion notice")
+		return
+	}
+	NotifyBatchCompleted(p.cfg, batch)
+}
+
+// work processes queued batch requests until the processor stops
+func (p *BatchProcessor) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case task := <-p.queue:
+			if task.run.ctx.Err() == nil {
+				p.process(task)
+			}
+			task.run.pending.Done()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// process runs one request against the provider router, stores its result and
+// records usage and billing like the synchronous endpoints
+func (p *BatchProcessor) process(task *batchTask) {
+	batch, r := task.batch, task.request
+	started := time.Now()
+
+	var (
+		model, providerName string
+		body                interface{}
+		usage               models.Usage
+		err                 error
+	)
+	switch batch.Endpoint {
+	case BatchEndpointChatCompletions:
+		var req models.ChatCompletionRequest
+		if err = json.Unmarshal([]byte(r.Body), &req); err == nil {
+			model = req.Model
+			body, usage, providerName, err = p.chatCompletion(task.run.ctx, &req)
+		}
+	case BatchEndpointEmbeddings:
+		var req models.EmbeddingRequest
+		if err = json.Unmarshal([]byte(r.Body), &req); err == nil {
+			model = req.Model
+			body, usage, providerName, err = p.embeddings(task.run.ctx, &req)
+		}
+	default:
+		err = fmt.Errorf("unsupported endpoint %s", batch.Endpoint)
+	}
+	if err != nil && task.run.ctx.Err() != nil {
+		// Cancelled or shutting down; the request stays pending
+		return
+	}
+
+	r.Status, r.StatusCode = database.BatchRequestCompleted, http.StatusOK
+	if err != nil {
+		providerErr := WrapError(err, providerName, model, "")
+		LogProviderError(providerErr)
+		r.Status, r.StatusCode = database.BatchRequestFailed, providerErr.HTTPStatus()
+		r.Error = providerErr.GetUserFriendlyMessage()
+		body = models.NewErrorResponse(r.Error, "provider_error", string(providerErr.Code))
+	}
+	response, _ := json.Marshal(body)
+	r.Response = string(response)
+	r.PromptTokens, r.CompletionTokens = usage.PromptTokens, usage.CompletionTokens
+
+	success := err == nil
+	if success && usage.TotalTokens > 0 && IsBillableModel(model) {
+		r.Cost = database.CalculateCost(usage.TotalTokens)
+		if p.bill != nil {
+			p.bill(batch.UserID, usage.TotalTokens, batch.APIKey, model)
+		}
+	}
+	if providerName != "" {
+		middleware.RecordProviderTokens(providerName, usage.TotalTokens)
+	}
+	if err := GetUsageTracker().TrackUsage(&UsageRecord{
+		UserID:           batch.UserID,
+		Username:         batch.Username,
+		APIToken:         batch.APIKey,
+		TokenName:        batch.TokenName,
+		Model:            model,
+		Provider:         providerName,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		CursorSession:    providerName + "-batch",
+		StatusCode:       r.StatusCode,
+		ErrorMessage:     r.Error,
+		RequestTime:      started,
+		ResponseTime:     time.Now(),
+		Duration:         time.Since(started),
+		Language:         middleware.DetectPromptLanguage([]byte(r.Body)),
+	}); err != nil {
+		logrus.WithError(err).Warn("Failed to track batch request usage")
+	}
+
+	if err := database.CompleteBatchRequest(r); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"batch_id":  batch.ID,
+			"custom_id": r.CustomID,
+		}).Error("Failed to save batch request result")
+	}
+}
+
+// acquireBatchSlot waits for a provider slot at normal priority. Unlike interactive
+// requests, batch requests keep waiting through queue timeouts and rate limits
+func acquireBatchSlot(ctx context.Context, provider string) (func(), error) {
+	for {
+		release, err := middleware.AcquireProviderSlot(ctx, provider, middleware.PriorityNormal)
+		var rateErr *middleware.ProviderRateLimitError
+		if err == nil || (!errors.Is(err, middleware.ErrQueueTimeout) && !errors.As(err, &rateErr)) {
+			return release, err
+		}
+		delay := time.Second
+		if rateErr != nil && rateErr.RetryAfter > delay {
+			delay = rateErr.RetryAfter
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// chatCompletion sends a chat request through the model's failover chain and
+// collects the streamed events into a chat completion response
+func (p *BatchProcessor) chatCompletion(ctx context.Context, req *models.ChatCompletionRequest) (interface{}, models.Usage, string, error) {
+	var usage models.Usage
+	chain, err := p.router.GetFailoverChain(req.Model)
+	if err != nil {
+		return nil, usage, "", err
+	}
+
+	chatRequest := &models.ChatRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Stream:      true,
+		Temperature: req.Temperature,
+		Seed:        req.Seed,
+	}
+	if maxTokens := models.ValidateMaxTokens(req.Model, req.MaxTokens); maxTokens != nil {
+		chatRequest.MaxTokens = *maxTokens
+	}
+
+	var providerName string
+	for i, provider := range chain {
+		providerName = provider.GetProviderName()
+		var release func()
+		release, err = acquireBatchSlot(ctx, providerName)
+		if err != nil {
+			return nil, usage, providerName, err
+		}
+
+		started := time.Now()
+		var events <-chan models.StreamEvent
+		events, err = provider.ChatCompletion(ctx, chatRequest)
+		p.router.RecordProviderResult(providerName, err, time.Since(started))
+		if err == nil {
+			var content strings.Builder
+			for event := range events {
+				switch event.Type {
+				case "content":
+					content.WriteString(event.Content)
+				case "usage":
+					if event.Tokens != nil {
+						usage = models.Usage{
+							PromptTokens:     event.Tokens.PromptTokens,
+							CompletionTokens: event.Tokens.CompletionTokens,
+							TotalTokens:      event.Tokens.TotalTokens,
+						}
+					}
+				case "error":
+					err = errors.New(event.Error)
+				}
+			}
+			release()
+			if err != nil {
+				return nil, models.Usage{}, providerName, err
+			}
+			return models.NewChatCompletionResponse(utils.GenerateChatCompletionID(), req.Model, content.String(), usage), usage, providerName, nil
+		}
+		release()
+
+		if ctx.Err() != nil || !IsFailoverError(err) || i == len(chain)-1 {
+			break
+		}
+	}
+	return nil, usage, providerName, err
+}
+
+// embeddings sends an embeddings request to the provider serving the model
+func (p *BatchProcessor) embeddings(ctx context.Context, req *models.EmbeddingRequest) (interface{}, models.Usage, string, error) {
+	var usage models.Usage
+	client, providerName, ok := p.router.GetEmbeddingsProvider(req.Model, nil)
+	if !ok {
+		return nil, usage, "", fmt.Errorf("PROVIDER_NOT_AVAILABLE: no provider available for embedding model %s", req.Model)
+	}
+
+	release, err := acquireBatchSlot(ctx, providerName)
+	if err != nil {
+		return nil, usage, providerName, err
+	}
+	defer release()
+
+	resp, err := p.router.CreateEmbeddings(ctx, client, providerName, req, req.EmbeddingInputs())
+	if err != nil {
+		return nil, usage, providerName, err
+	}
+	usage.PromptTokens = resp.Usage.PromptTokens
+	usage.TotalTokens = resp.Usage.TotalTokens
+	return resp, usage, providerName, nil
+}
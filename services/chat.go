@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"Curry2API-go/config"
 	"Curry2API-go/database"
@@ -21,14 +23,38 @@ var (
 
 // Chat service errors
 var (
-	ErrConversationNotFound = errors.New("conversation not found")
-	ErrUnauthorized         = errors.New("unauthorized access to conversation")
-	ErrEmptyMessage         = errors.New("message content cannot be empty")
-	ErrAIServiceUnavailable = errors.New("AI service temporarily unavailable")
-	ErrAIServiceTimeout     = errors.New("AI service request timeout")
-	ErrInvalidModel         = errors.New("invalid model specified")
+	ErrConversationNotFound  = errors.New("conversation not found")
+	ErrUnauthorized          = errors.New("unauthorized access to conversation")
+	ErrEmptyMessage          = errors.New("message content cannot be empty")
+	ErrAIServiceUnavailable  = errors.New("AI service temporarily unavailable")
+	ErrAIServiceTimeout      = errors.New("AI service request timeout")
+	ErrInvalidModel          = errors.New("invalid model specified")
+	ErrGenerationNotFound    = errors.New("no reply is being generated for this message")
+	ErrGenerationStopped     = errors.New("generation stopped by user")
+	ErrGenerationInProgress  = errors.New("a reply is already being generated for this message")
+	ErrMessageNotFound       = errors.New("message not found")
+	ErrMessageNotRegenerable = errors.New("only the latest assistant reply can be regenerated")
+	ErrMessageNotEditable    = errors.New("only your messages on the current branch can be edited")
 )
 
+// ErrConversationCostLimit is matched by ConversationCostLimitError
+var ErrConversationCostLimit = errors.New("conversation cost limit reached")
+
+// ConversationCostLimitError is returned when a conversation's cumulative cost has
+// reached its spend ceiling; it matches ErrConversationCostLimit with errors.Is
+type ConversationCostLimitError struct {
+	Spent float64
+	Limit float64
+}
+
+func (e *ConversationCostLimitError) Error() string {
+	return fmt.Sprintf("conversation cost limit reached: spent $%.4f of $%.4f", e.Spent, e.Limit)
+}
+
+func (e *ConversationCostLimitError) Is(target error) bool {
+	return target == ErrConversationCostLimit
+}
+
 // Provider-specific errors are defined in provider_errors.go
 // ErrProviderNotAvailable, ErrInvalidAPIKey, ErrRateLimited, ErrProviderError, ErrTimeout, ErrContextTooLong
 
@@ -37,6 +63,24 @@ type SendMessageRequest struct {
 	ConversationID int64
 	UserID         int64
 	Content        string
+	Model          string   // Optional: override conversation model
+	AttachmentIDs  []string // Optional: uploaded attachments to send with the message
+}
+
+// RegenerateRequest asks for a new answer to the user message behind a conversation's latest reply
+type RegenerateRequest struct {
+	ConversationID int64
+	UserID         int64
+	MessageID      int64  // Assistant message to replace
+	Model          string // Optional: override conversation model
+}
+
+// EditMessageRequest asks to replace one of the user's messages, starting a new branch of the conversation
+type EditMessageRequest struct {
+	ConversationID int64
+	UserID         int64
+	MessageID      int64  // User message to edit
+	Content        string
 	Model          string // Optional: override conversation model
 }
 
@@ -44,6 +88,23 @@ type SendMessageRequest struct {
 type SendMessageResponse struct {
 	UserMessage *models.ChatMessage
 	StreamChan  <-chan models.StreamEvent
+	Seed        *int64 // Sampling seed sent with the request (deterministic mode), nil when none
+	Replaces    int64  // Assistant message a regenerated reply supersedes once saved, 0 for a new reply
+	Provider    string // Provider serving the reply, after any failover
+
+	ctx context.Context // Provider context, cancelled with ErrGenerationStopped by StopGeneration
+}
+
+// Stopped reports whether the reply was cut short by StopGeneration
+func (r *SendMessageResponse) Stopped() bool {
+	return r.ctx != nil && errors.Is(context.Cause(r.ctx), ErrGenerationStopped)
+}
+
+// activeGeneration is a reply being streamed, keyed by the user message it answers
+type activeGeneration struct {
+	userID         int64
+	conversationID int64
+	stop           context.CancelCauseFunc
 }
 
 // ChatService handles chat business logic including message processing and AI integration
@@ -52,6 +113,9 @@ type ChatService struct {
 	cursorService  *CursorService
 	providerRouter *ProviderRouter
 	config         *config.Config
+
+	generationsMu sync.Mutex
+	generations   map[int64]*activeGeneration // In-flight replies by user message ID
 }
 
 // NewChatService creates a new ChatService instance
@@ -105,16 +169,19 @@ func (s *ChatService) BuildContext(conversationID int64) ([]models.Message, erro
 		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
 	}
 
-	// Convert ChatMessage to models.Message for AI request
+	return toContextMessages(chatMessages), nil
+}
+
+// toContextMessages converts stored chat messages to messages for an AI request
+func toContextMessages(chatMessages []models.ChatMessage) []models.Message {
 	messages := make([]models.Message, 0, len(chatMessages))
 	for _, msg := range chatMessages {
 		messages = append(messages, models.Message{
 			Role:    msg.Role,
-			Content: msg.Content,
+			Content: withAttachments(msg),
 		})
 	}
-
-	return messages, nil
+	return messages
 }
 
 // BuildContextWithSystemPrompt builds context including an optional system prompt
@@ -124,8 +191,11 @@ func (s *ChatService) BuildContextWithSystemPrompt(conversationID int64, systemP
 	if err != nil {
 		return nil, err
 	}
+	return withSystemPrompt(messages, systemPrompt), nil
+}
 
-	// Prepend system prompt if provided
+// withSystemPrompt prepends the system prompt, if any, to the context messages
+func withSystemPrompt(messages []models.Message, systemPrompt string) []models.Message {
 	if systemPrompt != "" {
 		systemMsg := models.Message{
 			Role:    "system",
@@ -133,8 +203,7 @@ func (s *ChatService) BuildContextWithSystemPrompt(conversationID int64, systemP
 		}
 		messages = append([]models.Message{systemMsg}, messages...)
 	}
-
-	return messages, nil
+	return messages
 }
 
 // SendMessage sends a user message and streams the AI response
@@ -148,12 +217,171 @@ func (s *ChatService) SendMessage(ctx context.Context, req SendMessageRequest) (
 		return nil, ErrEmptyMessage
 	}
 
+	conv, err := s.checkReplyAllowed(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAttachments(req.UserID, req.AttachmentIDs); err != nil {
+		return nil, err
+	}
+
+	// Determine which model to use
+	model := conv.Model
+	if req.Model != "" {
+		model = req.Model
+	}
+
+	// Generate request ID for logging
+	requestID := fmt.Sprintf("chat-%d-%d", req.ConversationID, req.UserID)
+
+	// Log the model being used for debugging
+	logrus.WithFields(logrus.Fields{
+		"conversation_id":    req.ConversationID,
+		"conversation_model": conv.Model,
+		"request_model":      req.Model,
+		"final_model":        model,
+		"request_id":         requestID,
+	}).Info("Chat request model selection")
+
+	// Save user message to database first (Requirements: 2.1)
+	userMessage, err := database.CreateMessage(req.ConversationID, "user", req.Content, 0, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user message: %w", err)
+	}
+	if err := database.AttachChatAttachments(userMessage.ID, req.UserID, req.AttachmentIDs); err != nil {
+		if err == database.ErrChatAttachmentNotFound {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, fmt.Errorf("failed to attach files: %w", err)
+	}
+
+	// Build context with all previous messages (Requirements: 2.3)
+	contextMessages, err := s.BuildContextWithSystemPrompt(req.ConversationID, conv.SystemPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build context: %w", err)
+	}
+
+	return s.startReply(ctx, conv, model, contextMessages, userMessage, req.UserID, requestID)
+}
+
+// RegenerateMessage replaces the conversation's latest assistant reply and streams a new
+// answer to the user message before it, with the conversation's model or req.Model. The
+// old reply is superseded once the new one is saved and stays reachable as another branch;
+// it stays counted in the conversation cost, and the new reply is billed like any other
+func (s *ChatService) RegenerateMessage(ctx context.Context, req RegenerateRequest) (*SendMessageResponse, error) {
+	conv, err := s.checkReplyAllowed(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := database.GetAllMessages(req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+	index := -1
+	for i := range history {
+		if history[i].ID == req.MessageID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, ErrMessageNotFound
+	}
+	// Only the last exchange can be redone: later messages would have been answered with the old reply
+	if index != len(history)-1 || index == 0 || history[index].Role != "assistant" || history[index-1].Role != "user" {
+		return nil, ErrMessageNotRegenerable
+	}
+	userMessage := history[index-1]
+
+	model := conv.Model
+	if req.Model != "" {
+		model = req.Model
+	}
+	requestID := fmt.Sprintf("chat-%d-%d", req.ConversationID, req.UserID)
+	logrus.WithFields(logrus.Fields{
+		"conversation_id": req.ConversationID,
+		"message_id":      req.MessageID,
+		"model":           model,
+		"request_id":      requestID,
+	}).Info("Regenerating chat reply")
+
+	contextMessages := withSystemPrompt(toContextMessages(history[:index]), conv.SystemPrompt)
+	resp, err := s.startReply(ctx, conv, model, contextMessages, &userMessage, req.UserID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	resp.Replaces = req.MessageID
+	return resp, nil
+}
+
+// EditMessage saves an edited copy of one of the user's messages on the active branch and
+// streams a reply to it. The copy follows the same message as the original, so it starts a
+// new branch; the original and everything after it are kept and can be switched back to
+func (s *ChatService) EditMessage(ctx context.Context, req EditMessageRequest) (*SendMessageResponse, error) {
+	if req.Content == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	conv, err := s.checkReplyAllowed(req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := database.GetAllMessages(req.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+	index := -1
+	for i := range history {
+		if history[i].ID == req.MessageID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, ErrMessageNotFound
+	}
+	if history[index].Role != "user" {
+		return nil, ErrMessageNotEditable
+	}
+
+	// Branch off the message before the edited one, or the start of the conversation
+	parentID := int64(0)
+	if index > 0 {
+		parentID = history[index-1].ID
+	}
+	userMessage, err := database.CreateMessageWithArtifacts(req.ConversationID, "user", req.Content, 0, 0, 0, nil, nil, false, &parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user message: %w", err)
+	}
+
+	model := conv.Model
+	if req.Model != "" {
+		model = req.Model
+	}
+	requestID := fmt.Sprintf("chat-%d-%d", req.ConversationID, req.UserID)
+	logrus.WithFields(logrus.Fields{
+		"conversation_id": req.ConversationID,
+		"message_id":      req.MessageID,
+		"new_message_id":  userMessage.ID,
+		"model":           model,
+		"request_id":      requestID,
+	}).Info("Editing chat message")
+
+	contextMessages := toContextMessages(append(history[:index:index], *userMessage))
+	return s.startReply(ctx, conv, model, withSystemPrompt(contextMessages, conv.SystemPrompt), userMessage, req.UserID, requestID)
+}
+
+// checkReplyAllowed verifies the user can pay for a reply in the conversation and that the
+// conversation has not reached its spend ceiling
+func (s *ChatService) checkReplyAllowed(userID, conversationID int64) (*models.Conversation, error) {
 	// Check user balance before proceeding (Requirements: 6.2)
-	balance, err := database.GetUserBalance(req.UserID)
+	balance, err := database.GetUserBalance(userID)
 	if err != nil {
 		if errors.Is(err, database.ErrBalanceNotFound) {
 			// Auto-create balance for users who don't have one
-			balance, err = database.CreateUserBalance(req.UserID)
+			balance, err = database.CreateUserBalance(userID)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create user balance: %w", err)
 			}
@@ -174,7 +402,7 @@ func (s *ChatService) SendMessage(ctx context.Context, req SendMessageRequest) (
 	}
 
 	// Verify conversation exists and belongs to user
-	conv, err := database.GetConversation(req.ConversationID, req.UserID)
+	conv, err := database.GetConversation(conversationID, userID)
 	if err != nil {
 		if errors.Is(err, database.ErrConversationNotFound) {
 			return nil, ErrConversationNotFound
@@ -182,89 +410,193 @@ func (s *ChatService) SendMessage(ctx context.Context, req SendMessageRequest) (
 		return nil, fmt.Errorf("failed to get conversation: %w", err)
 	}
 
-	// Determine which model to use
-	model := conv.Model
-	if req.Model != "" {
-		model = req.Model
+	// Refuse further generations once the conversation's spend ceiling is reached
+	if conv.MaxCost != nil && conv.TotalCost >= *conv.MaxCost {
+		return nil, &ConversationCostLimitError{Spent: conv.TotalCost, Limit: *conv.MaxCost}
 	}
+	return conv, nil
+}
 
-	// Generate request ID for logging
-	requestID := fmt.Sprintf("chat-%d-%d", req.ConversationID, req.UserID)
-
-	// Log the model being used for debugging
-	logrus.WithFields(logrus.Fields{
-		"conversation_id":    req.ConversationID,
-		"conversation_model": conv.Model,
-		"request_model":      req.Model,
-		"final_model":        model,
-		"request_id":         requestID,
-	}).Info("Chat request model selection")
+// startReply requests the AI reply to userMessage and tracks it so StopGeneration can cancel it
+func (s *ChatService) startReply(ctx context.Context, conv *models.Conversation, model string, contextMessages []models.Message, userMessage *models.ChatMessage, userID int64, requestID string) (*SendMessageResponse, error) {
+	// Deterministic conversations pin temperature 0 and a fixed seed
+	sampling := samplingParams{}
+	sampling.Temperature, sampling.Seed = conv.SamplingParams()
+
+	// Track the reply so StopGeneration can cancel the provider stream
+	genCtx, stop := context.WithCancelCause(ctx)
+	if !s.trackGeneration(userMessage.ID, userID, conv.ID, stop) {
+		stop(nil)
+		return nil, ErrGenerationInProgress
+	}
 
-	// Save user message to database first (Requirements: 2.1)
-	userMessage, err := database.CreateMessage(req.ConversationID, "user", req.Content, 0, 0)
+	// Try to use ProviderRouter if available (Requirements: 2.1-2.6)
+	var resp *SendMessageResponse
+	var err error
+	if s.providerRouter != nil {
+		resp, err = s.sendMessageWithProvider(genCtx, model, contextMessages, userMessage, sampling, requestID)
+	} else {
+		// Fallback to legacy CursorService if ProviderRouter not configured
+		resp, err = s.sendMessageWithCursor(genCtx, model, contextMessages, userMessage, sampling)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to save user message: %w", err)
+		s.untrackGeneration(userMessage.ID)
+		stop(nil)
+		return nil, err
 	}
+	resp.Seed = sampling.Seed
+	resp.ctx = genCtx
+	resp.StreamChan = s.relayGeneration(genCtx, userMessage.ID, resp.StreamChan, stop)
+	return resp, nil
+}
 
-	// Build context with all previous messages (Requirements: 2.3)
-	contextMessages, err := s.BuildContextWithSystemPrompt(req.ConversationID, conv.SystemPrompt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build context: %w", err)
+// StopGeneration cancels the reply being generated for the user's message. The provider
+// stream ends and the handler streaming it saves the partial reply marked as stopped
+func (s *ChatService) StopGeneration(userID, conversationID, messageID int64) error {
+	s.generationsMu.Lock()
+	gen, ok := s.generations[messageID]
+	s.generationsMu.Unlock()
+	if !ok || gen.userID != userID || gen.conversationID != conversationID {
+		return ErrGenerationNotFound
 	}
+	gen.stop(ErrGenerationStopped)
+	return nil
+}
 
-	// Try to use ProviderRouter if available (Requirements: 2.1-2.6)
-	if s.providerRouter != nil {
-		return s.sendMessageWithProvider(ctx, model, contextMessages, userMessage, requestID)
+// trackGeneration registers the reply to messageID, returning false if one is already in progress
+func (s *ChatService) trackGeneration(messageID, userID, conversationID int64, stop context.CancelCauseFunc) bool {
+	s.generationsMu.Lock()
+	defer s.generationsMu.Unlock()
+	if s.generations == nil {
+		s.generations = make(map[int64]*activeGeneration)
 	}
+	if _, busy := s.generations[messageID]; busy {
+		return false
+	}
+	s.generations[messageID] = &activeGeneration{userID: userID, conversationID: conversationID, stop: stop}
+	return true
+}
+
+func (s *ChatService) untrackGeneration(messageID int64) {
+	s.generationsMu.Lock()
+	defer s.generationsMu.Unlock()
+	delete(s.generations, messageID)
+}
 
-	// Fallback to legacy CursorService if ProviderRouter not configured
-	return s.sendMessageWithCursor(ctx, model, contextMessages, userMessage)
+// relayGeneration forwards the provider stream until it ends or ctx is cancelled (stopped,
+// timed out or abandoned by the client), then releases the tracked generation. Events the
+// provider sends after cancellation are drained so its goroutine can finish
+func (s *ChatService) relayGeneration(ctx context.Context, messageID int64, src <-chan models.StreamEvent, stop context.CancelCauseFunc) <-chan models.StreamEvent {
+	out := make(chan models.StreamEvent)
+	go func() {
+		defer close(out)
+		defer func() {
+			s.untrackGeneration(messageID)
+			stop(nil)
+		}()
+		for {
+			select {
+			case event, ok := <-src:
+				if !ok {
+					return
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					drainStream(src)
+					return
+				}
+			case <-ctx.Done():
+				drainStream(src)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// drainStream discards the remaining events of a stream nobody reads anymore
+func drainStream(src <-chan models.StreamEvent) {
+	go func() {
+		for range src {
+		}
+	}()
+}
+
+// samplingParams are the sampling overrides for a chat reply; nil fields use the provider defaults
+type samplingParams struct {
+	Temperature *float64
+	Seed        *int64
 }
 
 // sendMessageWithProvider sends message using the ProviderRouter
 // Requirements: 2.1-2.6, 10.1-10.5
-func (s *ChatService) sendMessageWithProvider(ctx context.Context, model string, messages []models.Message, userMessage *models.ChatMessage, requestID string) (*SendMessageResponse, error) {
-	// Get the appropriate provider for the model (Requirements: 2.1-2.5)
-	provider, err := s.providerRouter.GetProvider(model)
+func (s *ChatService) sendMessageWithProvider(ctx context.Context, model string, messages []models.Message, userMessage *models.ChatMessage, sampling samplingParams, requestID string) (*SendMessageResponse, error) {
+	// Get the providers for the model (Requirements: 2.1-2.5); with a failover
+	// chain configured, later providers are tried when earlier ones fail
+	chain, err := s.providerRouter.GetFailoverChain(model)
 	if err != nil {
 		// Requirements: 2.6 - Return PROVIDER_NOT_AVAILABLE error
 		return nil, mapProviderError(err, "unknown", model, requestID)
 	}
 
-	providerName := provider.GetProviderName()
-
-	logrus.WithFields(logrus.Fields{
-		"model":      model,
-		"provider":   providerName,
-		"request_id": requestID,
-	}).Info("Routing request to provider")
-
 	// Create chat request for provider
 	chatRequest := &models.ChatRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   true,
+		Model:       model,
+		Messages:    messages,
+		Stream:      true,
+		Temperature: sampling.Temperature,
+		Seed:        sampling.Seed,
 	}
 
-	// Send to provider
-	streamChan, err := provider.ChatCompletion(ctx, chatRequest)
-	if err != nil {
-		return nil, mapProviderError(err, providerName, model, requestID)
-	}
+	var lastErr error
+	var lastProvider string
+	for i, provider := range chain {
+		providerName := provider.GetProviderName()
+		logrus.WithFields(logrus.Fields{
+			"model":      model,
+			"provider":   providerName,
+			"attempt":    i + 1,
+			"request_id": requestID,
+		}).Info("Routing request to provider")
+
+		// Send to provider
+		started := time.Now()
+		streamChan, err := provider.ChatCompletion(ctx, chatRequest)
+		s.providerRouter.RecordProviderResult(providerName, err, time.Since(started))
+		if err == nil {
+			return &SendMessageResponse{
+				UserMessage: userMessage,
+				StreamChan:  streamChan,
+				Provider:    providerName,
+			}, nil
+		}
 
-	return &SendMessageResponse{
-		UserMessage: userMessage,
-		StreamChan:  streamChan,
-	}, nil
+		lastErr, lastProvider = err, providerName
+		if ctx.Err() != nil || !IsFailoverError(err) {
+			break
+		}
+		if i < len(chain)-1 {
+			logrus.WithFields(logrus.Fields{
+				"model":      model,
+				"provider":   providerName,
+				"next":       chain[i+1].GetProviderName(),
+				"request_id": requestID,
+			}).WithError(err).Warn("Provider failed, failing over to next provider")
+		}
+	}
+	return nil, mapProviderError(lastErr, lastProvider, model, requestID)
 }
 
 // sendMessageWithCursor sends message using the legacy CursorService
-func (s *ChatService) sendMessageWithCursor(ctx context.Context, model string, messages []models.Message, userMessage *models.ChatMessage) (*SendMessageResponse, error) {
+func (s *ChatService) sendMessageWithCursor(ctx context.Context, model string, messages []models.Message, userMessage *models.ChatMessage, sampling samplingParams) (*SendMessageResponse, error) {
 	// Create chat completion request
 	chatRequest := &models.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   true,
+		Model:       model,
+		Messages:    messages,
+		Stream:      true,
+		Temperature: sampling.Temperature,
+		Seed:        sampling.Seed,
 	}
 
 	// Send to AI service
@@ -280,6 +612,7 @@ func (s *ChatService) sendMessageWithCursor(ctx context.Context, model string, m
 	return &SendMessageResponse{
 		UserMessage: userMessage,
 		StreamChan:  eventChan,
+		Provider:    "cursor",
 	}, nil
 }
 
@@ -377,9 +710,23 @@ func (s *ChatService) GetConversationMessages(conversationID, userID int64, page
 }
 
 // SaveAssistantMessage saves the AI response to the database
-// Requirements: 2.4 - Save response with token usage information
-func (s *ChatService) SaveAssistantMessage(conversationID int64, content string, tokens int, cost float64) (*models.ChatMessage, error) {
-	return database.CreateMessage(conversationID, "assistant", content, tokens, cost)
+// Requirements: 2.4 - Save response with token usage information, split into prompt and completion tokens
+// Code artifacts in the response are extracted and stored with the message, along with
+// the sampling seed the reply was requested with and whether the user stopped it.
+// The reply follows parentID, normally the user message it answers; nil follows the active branch.
+// The model and provider that generated it are recorded for feedback analytics
+func (s *ChatService) SaveAssistantMessage(conversationID int64, content string, promptTokens, completionTokens int, cost float64, seed *int64, stopped bool, parentID *int64, model, provider string) (*models.ChatMessage, error) {
+	msg, err := database.CreateMessageWithArtifacts(conversationID, "assistant", content, promptTokens, completionTokens, cost, ExtractArtifacts(content), seed, stopped, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if provider == "" {
+		provider = GetProviderFromModel(model)
+	}
+	if err := database.SetMessageModel(msg.ID, model, provider); err != nil {
+		logrus.WithError(err).WithField("message_id", msg.ID).Warn("Failed to record reply model")
+	}
+	return msg, nil
 }
 
 // GetAvailableModels returns the list of available AI models
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChatArchiveJob is the /admin/jobs name of the chat message archive
+const ChatArchiveJob = "chat_archive"
+
+const (
+	chatArchiveInterval   = 6 * time.Hour // Time between archive runs
+	chatArchiveBatch      = 100           // Conversations listed per query
+	chatArchiveMaxBatches = 50            // Batches per run, so a large backlog is spread over several runs
+)
+
+// ChatArchiveService periodically compresses the messages of conversations that have been
+// idle for longer than the configured age, reporting counts in the jobs dashboard
+type ChatArchiveService struct {
+	idleAfter time.Duration
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+var chatArchiveService *ChatArchiveService
+
+// InitChatArchiveService creates the archive service for conversations idle longer than idleAfter; 0 disables it
+func InitChatArchiveService(idleAfter time.Duration) *ChatArchiveService {
+	chatArchiveService = &ChatArchiveService{
+		idleAfter: idleAfter,
+		stopChan:  make(chan struct{}),
+	}
+	return chatArchiveService
+}
+
+// GetChatArchiveService returns the archive service, nil before InitChatArchiveService
+func GetChatArchiveService() *ChatArchiveService {
+	return chatArchiveService
+}
+
+// Enabled reports whether archiving is configured
+func (s *ChatArchiveService) Enabled() bool {
+	return s != nil && s.idleAfter > 0
+}
+
+// Start runs an archive immediately and then every chatArchiveInterval until Stop
+func (s *ChatArchiveService) Start() {
+	if !s.Enabled() {
+		logrus.Info("Chat archive job is disabled")
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.Run()
+		ticker := time.NewTicker(chatArchiveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Run()
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+	logrus.Infof("Chat archive job started (archiving conversations idle for %s)", s.idleAfter)
+}
+
+// Stop stops the archive loop
+func (s *ChatArchiveService) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// Run compresses the messages of conversations idle for longer than the configured age.
+// A failing conversation is reported and skipped; it is retried on the next run
+func (s *ChatArchiveService) Run() map[string]int64 {
+	job := StartJob(ChatArchiveJob, 0)
+	cutoff := time.Now().Add(-s.idleAfter)
+	counts := map[string]int64{"conversations": 0, "messages": 0, "bytes_saved": 0, "failed": 0}
+	var errs []error
+
+	failed := make(map[int64]bool)
+	for batch := 0; batch < chatArchiveMaxBatches; batch++ {
+		ids, err := database.ListArchivableConversations(cutoff, chatArchiveBatch+len(failed))
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		progressed := false
+		for _, id := range ids {
+			if failed[id] {
+				continue
+			}
+			progressed = true
+			result, err := database.ArchiveConversationMessages(id)
+			if err != nil {
+				logrus.WithError(err).WithField("conversation_id", id).Warn("Chat archive failed")
+				failed[id] = true
+				counts["failed"]++
+				errs = append(errs, err)
+				continue
+			}
+			counts["conversations"]++
+			counts["messages"] += int64(result.Messages)
+			counts["bytes_saved"] += result.BytesBefore - result.BytesAfter
+		}
+		for name, n := range counts {
+			job.SetCount(name, n)
+		}
+		job.Update(counts["conversations"], 0, fmt.Sprintf("%d conversations archived", counts["conversations"]))
+		if !progressed {
+			break
+		}
+	}
+	job.Finish(errors.Join(errs...))
+
+	logrus.WithFields(logrus.Fields{
+		"conversations": counts["conversations"],
+		"messages":      counts["messages"],
+		"bytes_saved":   counts["bytes_saved"],
+		"failed":        counts["failed"],
+	}).Debug("Chat archive completed")
+	return counts
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+)
+
+// MaxChatAttachments is the number of files one chat message can carry
+const MaxChatAttachments = 5
+
+// Chat attachment errors
+var (
+	ErrAttachmentNotFound    = errors.New("attachment not found or already sent")
+	ErrTooManyAttachments    = fmt.Errorf("a message can carry at most %d attachments", MaxChatAttachments)
+	ErrUnsupportedAttachment = errors.New("unsupported attachment type: only .txt, .md and .pdf files can be attached")
+	ErrEmptyAttachment       = errors.New("no text could be extracted from the attachment")
+)
+
+// chatAttachmentTypes maps the extensions of files whose text can be extracted to their content type
+var chatAttachmentTypes = map[string]string{
+	".txt":      "text/plain; charset=utf-8",
+	".md":       "text/markdown; charset=utf-8",
+	".markdown": "text/markdown; charset=utf-8",
+	".pdf":      "application/pdf",
+}
+
+// IsSupportedAttachment reports whether text can be extracted from a file with this name
+func IsSupportedAttachment(filename string) bool {
+	_, ok := chatAttachmentTypes[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// ExtractAttachment extracts the text of an uploaded txt, md or pdf file into an attachment
+// with a new ID, truncating it to maxChars characters
+func ExtractAttachment(filename string, data []byte, maxChars int) (*models.ChatAttachment, error) {
+	var text string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".md", ".markdown":
+		if !utf8.Valid(data) {
+			return nil, errors.New("text attachments must be UTF-8 encoded")
+		}
+		text = strings.TrimPrefix(string(data), "\uFEFF")
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	case ".pdf":
+		extracted, err := utils.ExtractPDFText(data)
+		if err != nil {
+			return nil, err
+		}
+		text = extracted
+	default:
+		return nil, ErrUnsupportedAttachment
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptyAttachment
+	}
+
+	attachment := &models.ChatAttachment{
+		ID:          "att-" + utils.GenerateRandomString(24),
+		Filename:    filename,
+		ContentType: chatAttachmentTypes[strings.ToLower(filepath.Ext(filename))],
+		Bytes:       int64(len(data)),
+	}
+	if maxChars > 0 && utf8.RuneCountInString(text) > maxChars {
+		text = truncateRunes(text, maxChars)
+		attachment.Truncated = true
+	}
+	attachment.Text = text
+	attachment.Chars = utf8.RuneCountInString(text)
+	return attachment, nil
+}
+
+// validateAttachments checks that a message's attachment IDs are distinct, within the limit,
+// and name the user's own attachments that have not been sent yet
+func validateAttachments(userID int64, ids []string) error {
+	if len(ids) > MaxChatAttachments {
+		return ErrTooManyAttachments
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return ErrAttachmentNotFound
+		}
+		seen[id] = true
+	}
+	count, err := database.CountPendingChatAttachments(userID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to check attachments: %w", err)
+	}
+	if count != len(ids) {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+// withAttachments returns a user message's content preceded by the text of its attachments
+func withAttachments(msg models.ChatMessage) string {
+	if len(msg.Attachments) == 0 {
+		return msg.Content
+	}
+	var b strings.Builder
+	for _, a := range msg.Attachments {
+		fmt.Fprintf(&b, "<attachment name=%q>\n%s\n", a.Filename, a.Text)
+		if a.Truncated {
+			b.WriteString("[... attachment truncated ...]\n")
+		}
+		b.WriteString("</attachment>\n\n")
+	}
+	b.WriteString(msg.Content)
+	return b.String()
+}
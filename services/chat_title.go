@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultConversationTitle is the title of a conversation created without one
+const DefaultConversationTitle = "新对话"
+
+// ErrNothingToTitle is returned when a conversation has no exchange to title yet
+var ErrNothingToTitle = errors.New("conversation has no messages to title")
+
+const (
+	chatTitleTimeout      = 30 * time.Second // Budget for one title request
+	chatTitleMaxTokens    = 32               // Completion tokens requested for a title
+	chatTitleMaxRunes     = 50               // Longer titles are cut
+	chatTitleExcerptRunes = 2000             // Characters of each message sent to the title model
+)
+
+// chatTitlePrompt instructs the title model
+const chatTitlePrompt = "Write a concise title of at most 8 words for the conversation below, " +
+	"in the language the user writes in. Reply with the title only, without quotes or a trailing period."
+
+// GenerateTitle asks the title model (CHAT_TITLE_MODEL, or the conversation's model) for a title
+// summarizing the first exchange of the conversation's active branch. The request is billed
+// to the user like a chat reply
+func (s *ChatService) GenerateTitle(ctx context.Context, conversationID, userID int64) (string, error) {
+	conv, err := s.checkReplyAllowed(userID, conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	messages, err := database.GetAllMessages(conversationID)
+	if err != nil {
+		return "", err
+	}
+	var question, answer string
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "user" && question == "":
+			question = msg.Content
+		case msg.Role == "assistant" && question != "" && answer == "":
+			answer = msg.Content
+		}
+	}
+	if question == "" {
+		return "", ErrNothingToTitle
+	}
+
+	model := s.config.ChatTitleModel
+	if model == "" {
+		model = conv.Model
+	}
+	excerpt := "User: " + truncateRunes(question, chatTitleExcerptRunes)
+	if answer != "" {
+		excerpt += "\n\nAssistant: " + truncateRunes(answer, chatTitleExcerptRunes)
+	}
+	temperature := 0.3
+	resp, err := s.completeOnce(ctx, &models.ChatRequest{
+		Model: model,
+		Messages: []models.Message{
+			{Role: "system", Content: chatTitlePrompt},
+			{Role: "user", Content: excerpt},
+		},
+		Stream:      true,
+		MaxTokens:   chatTitleMaxTokens,
+		Temperature: &temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 上游未返回用量时按 tokenizer 计算
+	if resp.tokens == 0 {
+		resp.tokens = utils.CountTokens(model, chatTitlePrompt+excerpt) + utils.CountTokens(model, resp.content)
+	}
+	if resp.tokens > 0 {
+		if _, err := database.DeductBalance(userID, resp.tokens, "chat_title", model); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"user_id":         userID,
+				"conversation_id": conversationID,
+				"tokens":          resp.tokens,
+			}).Error("Failed to deduct balance for title generation")
+		}
+	}
+
+	title := cleanGeneratedTitle(resp.content)
+	if title == "" {
+		return "", ErrProviderError
+	}
+	return title, nil
+}
+
+// AutoTitle names a conversation still carrying the default title in the background, after
+// a reply has been saved (normally the first one). A title the user sets meanwhile is kept
+func (s *ChatService) AutoTitle(conversationID, userID int64) {
+	if !s.config.ChatAutoTitle {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), chatTitleTimeout)
+		defer cancel()
+
+		logFields := logrus.Fields{"user_id": userID, "conversation_id": conversationID}
+		title, err := s.GenerateTitle(ctx, conversationID, userID)
+		if err != nil {
+			logrus.WithError(err).WithFields(logFields).Warn("Failed to generate conversation title")
+			return
+		}
+		if err := database.SetGeneratedConversationTitle(conversationID, userID, title, DefaultConversationTitle); err != nil {
+			logrus.WithError(err).WithFields(logFields).Warn("Failed to save generated conversation title")
+			return
+		}
+		logrus.WithFields(logFields).Debug("Conversation title generated")
+	}()
+}
+
+// completion is the collected result of a streamed provider request
+type completion struct {
+	content string
+	tokens  int
+}
+
+// completeOnce sends a short request through the provider router and collects the streamed reply
+func (s *ChatService) completeOnce(ctx context.Context, req *models.ChatRequest) (*completion, error) {
+	if s.providerRouter == nil {
+		return nil, ErrProviderNotAvailable
+	}
+	provider, err := s.providerRouter.GetProvider(req.Model)
+	if err != nil {
+		return nil, mapProviderError(err, "unknown", req.Model, "")
+	}
+
+	started := time.Now()
+	stream, err := provider.ChatCompletion(ctx, req)
+	s.providerRouter.RecordProviderResult(provider.GetProviderName(), err, time.Since(started))
+	if err != nil {
+		return nil, mapProviderError(err, provider.GetProviderName(), req.Model, "")
+	}
+
+	var result completion
+	var content strings.Builder
+	for event := range stream {
+		switch event.Type {
+		case "content":
+			content.WriteString(event.Content)
+		case "usage":
+			if event.Tokens != nil {
+				result.tokens = event.Tokens.PromptTokens + event.Tokens.CompletionTokens
+			}
+		case "error":
+			drainStream(stream)
+			return nil, mapProviderError(errors.New(event.Error), provider.GetProviderName(), req.Model, "")
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ErrTimeout
+	}
+	result.content = content.String()
+	return &result, nil
+}
+
+// cleanGeneratedTitle keeps the first line of a model's answer, without a "Title:" label,
+// quotes or trailing punctuation, cut to chatTitleMaxRunes
+func cleanGeneratedTitle(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	for _, label := range []string{"Title:", "title:", "标题：", "标题:"} {
+		s = strings.TrimPrefix(s, label)
+	}
+	s = strings.Trim(s, " \t*#\"'`“”‘’「」《》")
+	s = strings.TrimRight(s, ".。!！")
+	s = strings.Join(strings.Fields(s), " ")
+	return truncateRunes(s, chatTitleMaxRunes)
+}
+
+// truncateRunes cuts s to at most n characters
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConfigBundleVersion is the format version written by ExportConfigBundle
+const ConfigBundleVersion = 1
+
+// Settings keys for configuration that is stored in system_settings
+const (
+	settingKeyModels         = "models"
+	settingKeyModelAliases   = "model_aliases"
+	settingKeyRateLimits     = "rate_limits"
+	settingKeyFeatureFlags   = "feature_flags"
+	settingKeyEmailTemplates = "email_templates"
+)
+
+// Feature flag names that can be carried in a bundle
+const (
+	FeatureQuotaEnabled         = "quota_enabled"
+	FeatureUsageTrackingEnabled = "usage_tracking_enabled"
+)
+
+// RateLimitSettings are the request limits applied at startup
+type RateLimitSettings struct {
+	RPS               int    `json:"rps"`
+	Burst             int    `json:"burst"`
+	QoSMaxConcurrent  int    `json:"qos_max_concurrent"`
+	QoSQueueTimeout   int    `json:"qos_queue_timeout"`
+	QoSProviderLimits string `json:"qos_provider_limits"`
+}
+
+// ConfigBundle is the portable, secret-free configuration of an instance.
+// Sections left empty on import are not changed.
+type ConfigBundle struct {
+	Version        int                      `json:"version"`
+	ExportedAt     time.Time                `json:"exported_at"`
+	Models         []string                 `json:"models,omitempty"`
+	ModelAliases   map[string]string        `json:"model_aliases,omitempty"`
+	Pricing        []ModelPricing           `json:"pricing,omitempty"`
+	FXRates        map[string]float64       `json:"fx_rates,omitempty"`
+	TaxRates       map[string]float64       `json:"tax_rates,omitempty"`
+	RateLimits     *RateLimitSettings       `json:"rate_limits,omitempty"`
+	FeatureFlags   map[string]bool          `json:"feature_flags,omitempty"`
+	EmailTemplates map[string]EmailTemplate `json:"email_templates,omitempty"`
+}
+
+// ConfigBundleImportResult reports which sections were imported
+type ConfigBundleImportResult struct {
+	DryRun          bool     `json:"dry_run"`
+	Applied         []string `json:"applied"`          // Sections that took effect immediately
+	RestartRequired []string `json:"restart_required"` // Sections stored but only applied on next start
+}
+
+// ExportConfigBundle collects the current non-secret configuration
+func ExportConfigBundle(cfg *config.Config) *ConfigBundle {
+	pricing := make([]ModelPricing, 0)
+	for _, p := range GetAllPricing() {
+		pricing = append(pricing, p)
+	}
+	sort.Slice(pricing, func(i, j int) bool { return pricing[i].Model < pricing[j].Model })
+
+	return &ConfigBundle{
+		Version:      ConfigBundleVersion,
+		ExportedAt:   time.Now(),
+		Models:       cfg.GetModels(),
+		ModelAliases: config.GetModelAliases(),
+		Pricing:      pricing,
+		FXRates:      GetFXRates(),
+		TaxRates:     GetTaxRates(),
+		RateLimits: &RateLimitSettings{
+			RPS:               cfg.RateLimitRPS,
+			Burst:             cfg.RateLimitBurst,
+			QoSMaxConcurrent:  cfg.QoS.MaxConcurrent,
+			QoSQueueTimeout:   cfg.QoS.QueueTimeout,
+			QoSProviderLimits: cfg.QoS.ProviderLimits,
+		},
+		FeatureFlags: map[string]bool{
+			FeatureQuotaEnabled:         cfg.Quota.Enabled,
+			FeatureUsageTrackingEnabled: cfg.UsageTracking.Enabled,
+		},
+		EmailTemplates: GetEmailTemplates(),
+	}
+}
+
+// ValidateConfigBundle checks a bundle without changing anything
+func ValidateConfigBundle(bundle *ConfigBundle) error {
+	if bundle.Version > ConfigBundleVersion {
+		return fmt.Errorf("bundle version %d is newer than supported version %d", bundle.Version, ConfigBundleVersion)
+	}
+	for _, m := range bundle.Models {
+		if strings.TrimSpace(m) == "" || strings.Contains(m, ",") {
+			return fmt.Errorf("invalid model name: %q", m)
+		}
+	}
+	for alias, target := range bundle.ModelAliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(target) == "" {
+			return errors.New("model aliases must not be empty")
+		}
+	}
+	for _, p := range bundle.Pricing {
+		if p.Model == "" || p.InputPrice < 0 || p.OutputPrice < 0 {
+			return fmt.Errorf("invalid pricing entry for model %q", p.Model)
+		}
+	}
+	for code, rate := range bundle.FXRates {
+		if _, err := NormalizeCurrency(code); err != nil {
+			return err
+		}
+		if rate <= 0 {
+			return errors.New("fx rate must be positive")
+		}
+	}
+	for country, rate := range bundle.TaxRates {
+		if normalized, err := NormalizeCountry(country); err != nil || normalized == "" {
+			return ErrInvalidCountry
+		}
+		if rate < 0 || rate >= 1 {
+			return errors.New("tax rate must be between 0 and 1")
+		}
+	}
+	if rl := bundle.RateLimits; rl != nil {
+		if rl.RPS <= 0 || rl.Burst <= 0 || rl.QoSMaxConcurrent < 0 || rl.QoSQueueTimeout < 0 {
+			return errors.New("rate limits must be positive")
+		}
+	}
+	for name := range bundle.FeatureFlags {
+		if name != FeatureQuotaEnabled && name != FeatureUsageTrackingEnabled {
+			return fmt.Errorf("unknown feature flag: %s", name)
+		}
+	}
+	for name, tpl := range bundle.EmailTemplates {
+		if err := validateEmailTemplate(name, tpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportConfigBundle validates and applies a bundle. Models, aliases, pricing, rates and
+// email templates apply immediately; rate limits and feature flags apply on next start.
+func ImportConfigBundle(cfg *config.Config, bundle *ConfigBundle, dryRun bool) (*ConfigBundleImportResult, error) {
+	if err := ValidateConfigBundle(bundle); err != nil {
+		return nil, err
+	}
+
+	result := &ConfigBundleImportResult{DryRun: dryRun, Applied: []string{}, RestartRequired: []string{}}
+	apply := func(section string, present bool, restart bool, fn func() error) error {
+		if !present {
+			return nil
+		}
+		if restart {
+			result.RestartRequired = append(result.RestartRequired, section)
+		} else {
+			result.Applied = append(result.Applied, section)
+		}
+		if dryRun {
+			return nil
+		}
+		if err := fn(); err != nil {
+			return fmt.Errorf("failed to import %s: %w", section, err)
+		}
+		return nil
+	}
+
+	steps := []struct {
+		section string
+		present bool
+		restart bool
+		fn      func() error
+	}{
+		{"models", len(bundle.Models) > 0, false, func() error {
+			if err := database.SetJSONSetting(settingKeyModels, bundle.Models); err != nil {
+				return err
+			}
+			cfg.SetModels(bundle.Models)
+			return nil
+		}},
+		{"model_aliases", bundle.ModelAliases != nil, false, func() error {
+			if err := database.SetJSONSetting(settingKeyModelAliases, bundle.ModelAliases); err != nil {
+				return err
+			}
+			config.SetModelAliases(bundle.ModelAliases)
+			return nil
+		}},
+		{"pricing", len(bundle.Pricing) > 0, false, func() error {
+			for _, p := range bundle.Pricing {
+				if p.Provider == "" {
+					p.Provider = GetProviderFromModel(p.Model)
+				}
+				if err := UpdateModelPricing(p); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"fx_rates", bundle.FXRates != nil, false, func() error {
+			return UpdateFXRates(bundle.FXRates)
+		}},
+		{"tax_rates", bundle.TaxRates != nil, false, func() error {
+			return UpdateTaxRates(bundle.TaxRates)
+		}},
+		{"email_templates", bundle.EmailTemplates != nil, false, func() error {
+			if err := database.SetJSONSetting(settingKeyEmailTemplates, bundle.EmailTemplates); err != nil {
+				return err
+			}
+			return SetEmailTemplates(bundle.EmailTemplates)
+		}},
+		{"rate_limits", bundle.RateLimits != nil, true, func() error {
+			return database.SetJSONSetting(settingKeyRateLimits, bundle.RateLimits)
+		}},
+		{"feature_flags", bundle.FeatureFlags != nil, true, func() error {
+			return database.SetJSONSetting(settingKeyFeatureFlags, bundle.FeatureFlags)
+		}},
+	}
+	for _, step := range steps {
+		if err := apply(step.section, step.present, step.restart, step.fn); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ApplyStoredConfig overlays configuration imported from a bundle onto cfg.
+// It must run after the database is initialized and before the router is built.
+func ApplyStoredConfig(cfg *config.Config) {
+	var models []string
+	if err := database.GetJSONSetting(settingKeyModels, &models); err == nil && len(models) > 0 {
+		cfg.SetModels(models)
+	}
+
+	var aliases map[string]string
+	if err := database.GetJSONSetting(settingKeyModelAliases, &aliases); err == nil {
+		config.SetModelAliases(aliases)
+	}
+
+	var rateLimits RateLimitSettings
+	if err := database.GetJSONSetting(settingKeyRateLimits, &rateLimits); err == nil && rateLimits.RPS > 0 {
+		cfg.RateLimitRPS = rateLimits.RPS
+		cfg.RateLimitBurst = rateLimits.Burst
+		cfg.QoS.MaxConcurrent = rateLimits.QoSMaxConcurrent
+		cfg.QoS.QueueTimeout = rateLimits.QoSQueueTimeout
+		cfg.QoS.ProviderLimits = rateLimits.QoSProviderLimits
+	}
+
+	var flags map[string]bool
+	if err := database.GetJSONSetting(settingKeyFeatureFlags, &flags); err == nil {
+		if v, ok := flags[FeatureQuotaEnabled]; ok {
+			cfg.Quota.Enabled = v
+		}
+		if v, ok := flags[FeatureUsageTrackingEnabled]; ok {
+			cfg.UsageTracking.Enabled = v
+		}
+	}
+
+	var templates map[string]EmailTemplate
+	if err := database.GetJSONSetting(settingKeyEmailTemplates, &templates); err == nil {
+		if err := SetEmailTemplates(templates); err != nil {
+			logrus.WithError(err).Warn("Ignoring stored email templates")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BaseCurrency 内部记账货币，所有余额与交易均以 USD 存储
+const BaseCurrency = "USD"
+
+// ErrUnsupportedCurrency 不支持的显示货币
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// defaultFXRates 默认汇率（1 USD 兑换的目标货币数量），管理员未配置时使用
+var defaultFXRates = map[string]float64{
+	"USD": 1.0,
+	"CNY": 7.20,
+	"EUR": 0.92,
+}
+
+// fxCacheTTL 汇率缓存时间
+const fxCacheTTL = 5 * time.Minute
+
+var (
+	fxMu       sync.RWMutex
+	fxRates    map[string]float64
+	fxLoadedAt time.Time
+)
+
+// SupportedCurrencies 返回支持的显示货币列表
+func SupportedCurrencies() []string {
+	return []string{"USD", "CNY", "EUR"}
+}
+
+// NormalizeCurrency 规范化货币代码，不支持时返回 ErrUnsupportedCurrency
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := defaultFXRates[code]; !ok {
+		return "", ErrUnsupportedCurrency
+	}
+	return code, nil
+}
+
+// GetFXRates 返回当前生效的汇率表（默认值合并管理员配置）
+func GetFXRates() map[string]float64 {
+	fxMu.RLock()
+	if fxRates != nil && time.Since(fxLoadedAt) < fxCacheTTL {
+		result := copyRates(fxRates)
+		fxMu.RUnlock()
+		return result
+	}
+	fxMu.RUnlock()
+
+	rates := copyRates(defaultFXRates)
+	configured, err := database.GetFXRates()
+	if err != nil && err != database.ErrSettingNotFound {
+		logrus.WithError(err).Warn("Failed to load FX rates, using defaults")
+	}
+	for code, rate := range configured {
+		if _, ok := rates[code]; ok && rate > 0 {
+			rates[code] = rate
+		}
+	}
+	rates[BaseCurrency] = 1.0
+
+	fxMu.Lock()
+	fxRates = rates
+	fxLoadedAt = time.Now()
+	fxMu.Unlock()
+
+	return copyRates(rates)
+}
+
+// UpdateFXRates 保存管理员配置的汇率并刷新缓存
+func UpdateFXRates(rates map[string]float64) error {
+	cleaned := make(map[string]float64, len(rates))
+	for code, rate := range rates {
+		normalized, err := NormalizeCurrency(code)
+		if err != nil {
+			return err
+		}
+		if normalized == BaseCurrency {
+			continue
+		}
+		if rate <= 0 {
+			return errors.New("fx rate must be positive")
+		}
+		cleaned[normalized] = rate
+	}
+
+	if err := database.SaveFXRates(cleaned); err != nil {
+		return err
+	}
+
+	fxMu.Lock()
+	fxRates = nil
+	fxMu.Unlock()
+	return nil
+}
+
+// ConvertFromUSD 将 USD 金额换算为显示货币金额
+func ConvertFromUSD(amount float64, currency string) float64 {
+	rate, ok := GetFXRates()[currency]
+	if !ok {
+		return amount
+	}
+	return amount * rate
+}
+
+// GetUserDisplayCurrency 返回用户的显示货币及对应汇率，出错时回退到 USD
+func GetUserDisplayCurrency(userID int64) (string, float64) {
+	if userID <= 0 {
+		return BaseCurrency, 1.0
+	}
+	currency, err := database.GetUserDisplayCurrency(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Debug("Failed to get display currency, using USD")
+		return BaseCurrency, 1.0
+	}
+	rate, ok := GetFXRates()[currency]
+	if !ok {
+		return BaseCurrency, 1.0
+	}
+	return currency, rate
+}
+
+func copyRates(src map[string]float64) map[string]float64 {
+	dst := make(map[string]float64, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -49,9 +49,10 @@ func (h *httpClient) chatHeaders(xIsHuman string) map[string]string {
 func (h *httpClient) sendChatRequest(ctx context.Context, xIsHuman string, jsonPayload []byte) (*http.Response, *middleware.CursorSessionInfo, error) {
 	sessionMgr := middleware.GetCursorSessionManager()
 
-	// 1. 尝试使用 Cursor session（如果有）
+	// 1. 尝试使用 Cursor session（如果有），同一会话优先复用已绑定的 session
+	conversation := middleware.ConversationFromContext(ctx)
 	if sessionMgr.HasValidSessions() {
-		session, err := sessionMgr.GetValidSession()
+		session, err := sessionMgr.GetSessionForConversation(conversation)
 		if err == nil {
 			resp, err := h.sendWithSession(ctx, session, jsonPayload)
 			if err == nil && resp.StatusCode == http.StatusOK {
@@ -84,6 +85,7 @@ func (h *httpClient) sendChatRequest(ctx context.Context, xIsHuman string, jsonP
 				resp.Body.Close()
 			}
 			sessionMgr.MarkSessionFailed(session)
+			sessionMgr.ReleaseConversation(conversation)
 			logFields := logrus.Fields{
 				"session": session.Email,
 				"reason":  failReason,
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"Curry2API-go/utils"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Doctor check statuses
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// doctorProbeSetting stores a value encrypted with DATA_ENCRYPTION_KEY so later runs
+// can verify the key has not changed
+const (
+	doctorProbeSetting   = "doctor_encryption_probe"
+	doctorProbePlaintext = "curry2api-doctor-probe"
+)
+
+// DoctorCheck is the result of a single self-test
+type DoctorCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// DoctorReport is the structured result of a full self-test run
+type DoctorReport struct {
+	Passed      bool          `json:"passed"`
+	Checks      []DoctorCheck `json:"checks"`
+	GeneratedAt time.Time     `json:"generated_at"`
+}
+
+// RunDoctor validates the runtime configuration: database connectivity and schema,
+// encryption keys, SMTP, upstream provider keys and Turnstile reachability.
+// The database must already be connected (database.Connect or database.Init).
+func RunDoctor(cfg *config.Config) *DoctorReport {
+	report := &DoctorReport{Passed: true, GeneratedAt: time.Now()}
+
+	run := func(name string, check func() (string, string)) {
+		start := time.Now()
+		status, message := check()
+		report.Checks = append(report.Checks, DoctorCheck{
+			Name:       name,
+			Status:     status,
+			Message:    message,
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+		if status == CheckFail {
+			report.Passed = false
+		}
+	}
+
+	dbOK := false
+	run("database.connection", func() (string, string) {
+		if err := database.Ping(); err != nil {
+			return CheckFail, err.Error()
+		}
+		dbOK = true
+		return CheckPass, "connected"
+	})
+
+	run("database.schema", func() (string, string) {
+		if !dbOK {
+			return CheckSkip, "database not reachable"
+		}
+		status, err := database.CheckSchema()
+		if err != nil {
+			return CheckFail, err.Error()
+		}
+		if !status.UpToDate() {
+			return CheckFail, fmt.Sprintf("schema version %d/%d, missing tables %v, missing columns %v",
+				status.Version, status.LatestVersion, status.MissingTables, status.MissingColumns)
+		}
+		return CheckPass, fmt.Sprintf("schema version %d/%d", status.Version, status.LatestVersion)
+	})
+
+	run("encryption.data_key", func() (string, string) {
+		return checkDataEncryption(dbOK)
+	})
+
+	run("encryption.oauth_key", func() (string, string) {
+		if os.Getenv("OAUTH_ENCRYPTION_KEY") == "" {
+			return CheckFail, "OAUTH_ENCRYPTION_KEY not set"
+		}
+		crypto, err := utils.NewOAuthCrypto()
+		if err != nil {
+			return CheckFail, err.Error()
+		}
+		encrypted, err := crypto.EncryptToken(doctorProbePlaintext)
+		if err != nil {
+			return CheckFail, err.Error()
+		}
+		decrypted, err := crypto.DecryptToken(encrypted)
+		if err != nil || decrypted != doctorProbePlaintext {
+			return CheckFail, "round-trip decryption failed"
+		}
+		return CheckPass, "round-trip ok"
+	})
+
+	run("email.smtp", func() (string, string) {
+		if cfg.SMTPUser == "" || cfg.SMTPPassword == "" {
+			return CheckWarn, "SMTP not configured, verification emails cannot be sent"
+		}
+		if err := NewEmailService(cfg).CheckConnection(); err != nil {
+			return CheckFail, err.Error()
+		}
+		return CheckPass, fmt.Sprintf("authenticated with %s:%d", cfg.SMTPHost, cfg.SMTPPort)
+	})
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	providerChecks := []struct {
+		name    string
+		apiKey  string
+		request func() (*http.Request, error)
+	}{
+		{"provider.openai", cfg.Providers.OpenAI.APIKey, func() (*http.Request, error) {
+			req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(cfg.Providers.OpenAI.BaseURL, "/")+"/models", nil)
+			if err == nil {
+				req.Header.Set("Authorization", "Bearer "+cfg.Providers.OpenAI.APIKey)
+			}
+			return req, err
+		}},
+		{"provider.anthropic", cfg.Providers.Anthropic.APIKey, func() (*http.Request, error) {
+			req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(cfg.Providers.Anthropic.BaseURL, "/")+"/models", nil)
+			if err == nil {
+				req.Header.Set("x-api-key", cfg.Providers.Anthropic.APIKey)
+				req.Header.Set("anthropic-version", "2023-06-01")
+			}
+			return req, err
+		}},
+		{"provider.google", cfg.Providers.Google.APIKey, func() (*http.Request, error) {
+			req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(cfg.Providers.Google.BaseURL, "/")+"/models", nil)
+			if err == nil {
+				req.Header.Set("x-goog-api-key", cfg.Providers.Google.APIKey)
+			}
+			return req, err
+		}},
+		{"provider.deepseek", cfg.Providers.DeepSeek.APIKey, func() (*http.Request, error) {
+			req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(cfg.Providers.DeepSeek.BaseURL, "/")+"/models", nil)
+			if err == nil {
+				req.Header.Set("Authorization", "Bearer "+cfg.Providers.DeepSeek.APIKey)
+			}
+			return req, err
+		}},
+	}
+	for _, pc := range providerChecks {
+		pc := pc
+		run(pc.name, func() (string, string) {
+			if pc.apiKey == "" {
+				return CheckSkip, "not configured"
+			}
+			req, err := pc.request()
+			if err != nil {
+				return CheckFail, err.Error()
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return CheckFail, fmt.Sprintf("unreachable: %v", err)
+			}
+			resp.Body.Close()
+			switch {
+			case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+				return CheckFail, fmt.Sprintf("API key rejected (HTTP %d)", resp.StatusCode)
+			case resp.StatusCode >= 400:
+				return CheckWarn, fmt.Sprintf("unexpected HTTP %d", resp.StatusCode)
+			}
+			return CheckPass, "API key accepted"
+		})
+	}
+
+	run("turnstile", func() (string, string) {
+		secret := os.Getenv("TURNSTILE_SECRET_KEY")
+		if secret == "" {
+			return CheckFail, "TURNSTILE_SECRET_KEY not set"
+		}
+		if err := NewTurnstileService(secret).Probe(); err != nil {
+			return CheckFail, err.Error()
+		}
+		return CheckPass, "reachable, secret accepted"
+	})
+
+	return report
+}
+
+// checkDataEncryption verifies DATA_ENCRYPTION_KEY is set and still decrypts the stored probe value
+func checkDataEncryption(dbOK bool) (string, string) {
+	if os.Getenv("DATA_ENCRYPTION_KEY") == "" {
+		return CheckFail, "DATA_ENCRYPTION_KEY not set, encrypted data will be unreadable after restart"
+	}
+	if err := utils.InitDataCrypto(); err != nil {
+		return CheckFail, err.Error()
+	}
+	if !dbOK {
+		return CheckWarn, "key loaded, probe value not checked (database not reachable)"
+	}
+
+	stored, err := database.GetSetting(doctorProbeSetting)
+	if err == database.ErrSettingNotFound {
+		encrypted, err := utils.EncryptSensitiveData(doctorProbePlaintext)
+		if err != nil {
+			return CheckFail, err.Error()
+		}
+		if err := database.SetSetting(doctorProbeSetting, encrypted); err != nil {
+			return CheckWarn, "key loaded, failed to store probe value: " + err.Error()
+		}
+		return CheckPass, "key loaded, probe value stored for future checks"
+	}
+	if err != nil {
+		return CheckWarn, "key loaded, failed to read probe value: " + err.Error()
+	}
+
+	decrypted, err := utils.DecryptSensitiveData(stored)
+	if err != nil || decrypted != doctorProbePlaintext {
+		return CheckFail, "probe value cannot be decrypted, DATA_ENCRYPTION_KEY has changed"
+	}
+	return CheckPass, "probe value decrypted"
+}
@@ -3,7 +3,12 @@ package services
 import (
 	"crypto/tls"
 	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"Curry2API-go/utils"
 	"fmt"
+	"html"
+	"strings"
+	"time"
 
 	"gopkg.in/gomail.v2"
 )
@@ -20,6 +25,9 @@ func NewEmailService(cfg *config.Config) *EmailService {
 
 // SendVerificationCode 发送验证码邮件
 func (s *EmailService) SendVerificationCode(toEmail, code string) error {
+	if database.IsOAuthPlaceholderEmail(toEmail) {
+		return nil // 第三方登录的占位邮箱没有收件箱
+	}
 	if s.cfg.SMTPUser == "" || s.cfg.SMTPPassword == "" {
 		return fmt.Errorf("SMTP configuration is not set")
 	}
@@ -135,6 +143,9 @@ func (s *EmailService) SendVerificationCode(toEmail, code string) error {
 `, code)
 
 	m.SetBody("text/html", htmlBody)
+	if err := applyEmailOverride(m, EmailTemplateVerificationCode, code); err != nil {
+		return err
+	}
 
 	// 创建SMTP拨号器
 	d := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPassword)
@@ -150,8 +161,11 @@ func (s *EmailService) SendVerificationCode(toEmail, code string) error {
 	return nil
 }
 
-// SendPasswordResetCode 发送密码重置验证码（未来扩展）
+// SendPasswordResetCode 发送找回密码验证码
 func (s *EmailService) SendPasswordResetCode(toEmail, code string) error {
+	if database.IsOAuthPlaceholderEmail(toEmail) {
+		return nil // 第三方登录的占位邮箱没有收件箱
+	}
 	if s.cfg.SMTPUser == "" || s.cfg.SMTPPassword == "" {
 		return fmt.Errorf("SMTP configuration is not set")
 	}
@@ -266,6 +280,65 @@ func (s *EmailService) SendPasswordResetCode(toEmail, code string) error {
 </html>
 `, code)
 
+	m.SetBody("text/html", htmlBody)
+	if err := applyEmailOverride(m, EmailTemplatePasswordReset, code); err != nil {
+		return err
+	}
+
+	d := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPassword)
+	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendKeyRotationNotice 通知用户其 API 密钥已被管理员轮换
+// graceUntil 为 nil 表示旧密钥已立即失效
+func (s *EmailService) SendKeyRotationNotice(toEmail, username string, maskedKeys []string, graceUntil *time.Time, reason string) error {
+	if database.IsOAuthPlaceholderEmail(toEmail) {
+		return nil // 第三方登录的占位邮箱没有收件箱
+	}
+	if s.cfg.SMTPUser == "" || s.cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP configuration is not set")
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.cfg.SMTPFrom)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", "【Curry2API】API 密钥已轮换")
+
+	var keyList strings.Builder
+	for _, key := range maskedKeys {
+		keyList.WriteString("<li><code>" + html.EscapeString(key) + "</code></li>")
+	}
+	expiry := "旧密钥已<strong>立即失效</strong>。"
+	if graceUntil != nil {
+		expiry = fmt.Sprintf("旧密钥将在 <strong>%s</strong> 后失效，请在此之前完成替换。", graceUntil.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	reasonLine := ""
+	if reason != "" {
+		reasonLine = "<p>原因：" + html.EscapeString(reason) + "</p>"
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"></head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; color: #333;">
+    <p>%s 您好！</p>
+    <p>出于安全原因，管理员已轮换您账号下的以下 API 密钥：</p>
+    <ul>%s</ul>
+    %s
+    <p>%s</p>
+    <p>新的密钥已生成，请登录 Curry2API 控制台查看并更新您的应用配置。</p>
+    <p style="color: #999; font-size: 12px;">此邮件由系统自动发送，请勿直接回复</p>
+</body>
+</html>
+`, html.EscapeString(username), keyList.String(), reasonLine, expiry)
+
 	m.SetBody("text/html", htmlBody)
 
 	d := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPassword)
@@ -277,3 +350,116 @@ func (s *EmailService) SendPasswordResetCode(toEmail, code string) error {
 
 	return nil
 }
+
+// SendNewLoginNotice 提醒用户其账号在新设备上登录，附带设备指纹以便发现异常登录
+func (s *EmailService) SendNewLoginNotice(toEmail, username string, device utils.DeviceInfo, maskedIP string, loginAt time.Time) error {
+	if database.IsOAuthPlaceholderEmail(toEmail) {
+		return nil // 第三方登录的占位邮箱没有收件箱
+	}
+	if s.cfg.SMTPUser == "" || s.cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP configuration is not set")
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.cfg.SMTPFrom)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", "【Curry2API】新设备登录提醒")
+
+	if maskedIP == "" {
+		maskedIP = "未知"
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"></head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; color: #333;">
+    <p>%s 您好！</p>
+    <p>您的账号刚刚在一台新设备上登录：</p>
+    <ul>
+        <li>时间：%s</li>
+        <li>浏览器：%s</li>
+        <li>操作系统：%s（%s）</li>
+        <li>位置：%s</li>
+        <li>IP：%s</li>
+    </ul>
+    <p>如果这是您本人的操作，请忽略此邮件。</p>
+    <p>如果不是您本人操作，请立即修改密码，并在「个人设置 → 登录设备」中退出该设备。</p>
+    <p style="color: #999; font-size: 12px;">此邮件由系统自动发送，请勿直接回复</p>
+</body>
+</html>
+`, html.EscapeString(username), loginAt.UTC().Format("2006-01-02 15:04 MST"),
+		html.EscapeString(device.Browser), html.EscapeString(device.OS), html.EscapeString(device.DeviceType),
+		html.EscapeString(device.Location), html.EscapeString(maskedIP))
+
+	m.SetBody("text/html", htmlBody)
+
+	d := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPassword)
+	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendJobCompletedNotice 通知用户其异步任务已完成（未配置 Webhook 或 Webhook 投递失败时的回退通道）
+func (s *EmailService) SendJobCompletedNotice(toEmail, username, title, summary, link string) error {
+	if database.IsOAuthPlaceholderEmail(toEmail) {
+		return nil // 第三方登录的占位邮箱没有收件箱
+	}
+	if s.cfg.SMTPUser == "" || s.cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP configuration is not set")
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.cfg.SMTPFrom)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", "【Curry2API】"+title)
+
+	linkLine := ""
+	if link != "" {
+		linkLine = fmt.Sprintf(`<p>查看结果：<a href="%s">%s</a></p>`, html.EscapeString(link), html.EscapeString(link))
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"></head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; color: #333;">
+    <p>%s 您好！</p>
+    <p>%s</p>
+    %s
+    <p style="color: #999; font-size: 12px;">此邮件由系统自动发送，请勿直接回复</p>
+</body>
+</html>
+`, html.EscapeString(username), html.EscapeString(summary), linkLine)
+
+	m.SetBody("text/html", htmlBody)
+
+	d := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPassword)
+	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// CheckConnection 连接并登录 SMTP 服务器（不发送邮件），用于配置自检
+func (s *EmailService) CheckConnection() error {
+	if s.cfg.SMTPUser == "" || s.cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP configuration is not set")
+	}
+
+	d := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPassword)
+	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+	closer, err := d.Dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	return closer.Close()
+}
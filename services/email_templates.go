@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Email template names that can be overridden
+const (
+	EmailTemplateVerificationCode = "verification_code"
+	EmailTemplatePasswordReset    = "password_reset"
+)
+
+// EmailTemplate is an admin-provided override for a built-in email.
+// HTML is a Go text/template; {{.Code}} is replaced with the verification code.
+type EmailTemplate struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+}
+
+var (
+	emailTemplatesMu       sync.RWMutex
+	emailTemplateOverrides = map[string]EmailTemplate{}
+)
+
+// EmailTemplateNames returns the names of templates that can be overridden
+func EmailTemplateNames() []string {
+	return []string{EmailTemplateVerificationCode, EmailTemplatePasswordReset}
+}
+
+// GetEmailTemplates returns the current template overrides
+func GetEmailTemplates() map[string]EmailTemplate {
+	emailTemplatesMu.RLock()
+	defer emailTemplatesMu.RUnlock()
+	out := make(map[string]EmailTemplate, len(emailTemplateOverrides))
+	for k, v := range emailTemplateOverrides {
+		out[k] = v
+	}
+	return out
+}
+
+// SetEmailTemplates validates and replaces the template overrides
+func SetEmailTemplates(templates map[string]EmailTemplate) error {
+	for name, tpl := range templates {
+		if err := validateEmailTemplate(name, tpl); err != nil {
+			return err
+		}
+	}
+
+	emailTemplatesMu.Lock()
+	defer emailTemplatesMu.Unlock()
+	emailTemplateOverrides = make(map[string]EmailTemplate, len(templates))
+	for k, v := range templates {
+		emailTemplateOverrides[k] = v
+	}
+	return nil
+}
+
+// validateEmailTemplate checks that name is overridable and the HTML template parses
+func validateEmailTemplate(name string, tpl EmailTemplate) error {
+	known := false
+	for _, n := range EmailTemplateNames() {
+		if n == name {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown email template: %s", name)
+	}
+	if _, err := template.New(name).Parse(tpl.HTML); err != nil {
+		return fmt.Errorf("invalid email template %s: %w", name, err)
+	}
+	return nil
+}
+
+// applyEmailOverride replaces the subject and body of m with the admin override for name, if any
+func applyEmailOverride(m *gomail.Message, name, code string) error {
+	emailTemplatesMu.RLock()
+	tpl, exists := emailTemplateOverrides[name]
+	emailTemplatesMu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	t, err := template.New(name).Parse(tpl.HTML)
+	if err != nil {
+		return fmt.Errorf("invalid email template %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, struct{ Code string }{Code: code}); err != nil {
+		return fmt.Errorf("failed to render email template %s: %w", name, err)
+	}
+
+	if tpl.Subject != "" {
+		m.SetHeader("Subject", tpl.Subject)
+	}
+	m.SetBody("text/html", buf.String())
+	return nil
+}
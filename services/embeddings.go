@@ -0,0 +1,99 @@
+package services
+
+import (
+	"Curry2API-go/models"
+	"Curry2API-go/services/providers"
+	"context"
+	"strings"
+	"time"
+)
+
+// maxEmbeddingBatchInputs caps the inputs sent upstream in one embeddings call;
+// larger requests are split into batches and the results merged in input order
+const maxEmbeddingBatchInputs = 256
+
+// isBuiltinEmbeddingModel reports whether model is an OpenAI embedding model
+func isBuiltinEmbeddingModel(model string) bool {
+	return strings.HasPrefix(strings.ToLower(model), "text-embedding-")
+}
+
+// embeddingsCandidates returns the providers that can serve the embedding model:
+// custom upstreams listing it, then OpenAI for its own embedding models or
+// OpenRouter for vendor-prefixed IDs (e.g. openai/text-embedding-3-small). Caller holds r.mu
+func (r *ProviderRouter) embeddingsCandidates(model string, keyTags []string) []string {
+	candidates := append([]string(nil), r.modelUpstreams[model]...)
+	if isBuiltinEmbeddingModel(model) {
+		candidates = append(candidates, "openai")
+	} else if strings.Contains(model, "/") {
+		candidates = append(candidates, "openrouter")
+	}
+
+	available := candidates[:0]
+	for _, name := range candidates {
+		provider, exists := r.providers[name]
+		if !exists || !provider.IsAvailable() || !r.allow(name, keyTags) {
+			continue
+		}
+		if _, ok := provider.(providers.EmbeddingsClient); ok {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// GetEmbeddingsProvider returns the provider that serves the embedding model,
+// balanced across the candidates like chat models
+func (r *ProviderRouter) GetEmbeddingsProvider(model string, keyTags []string) (providers.EmbeddingsClient, string, bool) {
+	if r == nil {
+		return nil, "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	candidates := r.embeddingsCandidates(model, keyTags)
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+	name, ok := r.balancer.pick(model, candidates)
+	if !ok {
+		return nil, "", false
+	}
+	return r.providers[name].(providers.EmbeddingsClient), name, true
+}
+
+// CreateEmbeddings sends inputs to the provider in batches of at most
+// maxEmbeddingBatchInputs and merges the results, re-indexed to the original input order
+func (r *ProviderRouter) CreateEmbeddings(ctx context.Context, client providers.EmbeddingsClient, providerName string, req *models.EmbeddingRequest, inputs []interface{}) (*models.EmbeddingResponse, error) {
+	result := &models.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]models.EmbeddingData, 0, len(inputs)),
+		Model:  req.Model,
+	}
+
+	for offset := 0; offset < len(inputs); offset += maxEmbeddingBatchInputs {
+		end := offset + maxEmbeddingBatchInputs
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		batch := *req
+		batch.Input = inputs[offset:end]
+
+		started := time.Now()
+		resp, err := client.Embeddings(ctx, &batch)
+		r.RecordProviderResult(providerName, err, time.Since(started))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Data {
+			item.Object = "embedding"
+			item.Index += offset
+			result.Data = append(result.Data, item)
+		}
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrStoredFileMissing is returned when a file's content is gone from storage
+var ErrStoredFileMissing = errors.New("stored file content not found")
+
+// FileStorage stores uploaded file content by key. Keys are generated by the
+// file service and contain only [A-Za-z0-9/_-]
+type FileStorage interface {
+	Name() string
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFileStorage creates the storage backend selected by FILES_STORAGE
+func NewFileStorage(cfg config.FilesConfig) (FileStorage, error) {
+	switch cfg.Storage {
+	case "", "disk":
+		if cfg.Dir == "" {
+			return nil, errors.New("FILES_DIR is required for disk storage")
+		}
+		return &DiskFileStorage{dir: cfg.Dir}, nil
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for s3 storage")
+		}
+		endpoint, err := url.Parse(cfg.S3Endpoint)
+		if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+			return nil, fmt.Errorf("invalid S3_ENDPOINT %q", cfg.S3Endpoint)
+		}
+		return &S3FileStorage{
+			endpoint:  endpoint,
+			region:    cfg.S3Region,
+			bucket:    cfg.S3Bucket,
+			accessKey: cfg.S3AccessKey,
+			secretKey: cfg.S3SecretKey,
+			pathStyle: cfg.S3PathStyle,
+			client:    &http.Client{Timeout: 10 * time.Minute},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown FILES_STORAGE %q, expected disk or s3", cfg.Storage)
+	}
+}
+
+// DiskFileStorage stores files under a local directory
+type DiskFileStorage struct {
+	dir string
+}
+
+func (s *DiskFileStorage) Name() string { return "disk" }
+
+func (s *DiskFileStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// Put writes to a temporary file and renames it into place, so readers never see a partial file
+func (s *DiskFileStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *DiskFileStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrStoredFileMissing
+	}
+	return f, err
+}
+
+func (s *DiskFileStorage) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3FileStorage stores files in an S3-compatible bucket (AWS S3, MinIO, R2, ...),
+// signing requests with AWS Signature Version 4
+type S3FileStorage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func (s *S3FileStorage) Name() string { return "s3" }
+
+// objectURL addresses key in the bucket, virtual-hosted style unless path style is configured
+func (s *S3FileStorage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+func (s *S3FileStorage) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds a SigV4 Authorization header. The payload is sent unsigned so uploads
+// can stream without being buffered to compute their hash
+func (s *S3FileStorage) sign(req *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Error reads an error response into an error
+func s3Error(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s failed: %s: %s", op, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (s *S3FileStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, r, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", resp)
+	}
+	return nil
+}
+
+func (s *S3FileStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrStoredFileMissing
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("get", resp)
+	}
+}
+
+func (s *S3FileStorage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", resp)
+	}
+	return nil
+}
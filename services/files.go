@@ -0,0 +1,139 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"Curry2API-go/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// File purposes accepted on upload
+const (
+	FilePurposeBatch      = "batch"
+	FilePurposeUserData   = "user_data"
+	FilePurposeVision     = "vision"
+	FilePurposeAssistants = "assistants"
+)
+
+var filePurposes = map[string]bool{
+	FilePurposeBatch:      true,
+	FilePurposeUserData:   true,
+	FilePurposeVision:     true,
+	FilePurposeAssistants: true,
+}
+
+// IsValidFilePurpose reports whether purpose may be used on upload
+func IsValidFilePurpose(purpose string) bool {
+	return filePurposes[purpose]
+}
+
+// ErrFileTooLarge is returned when an upload exceeds FILES_MAX_BYTES
+var ErrFileTooLarge = errors.New("file exceeds the maximum upload size")
+
+// FileService stores uploaded files: metadata in MySQL, content in the configured storage
+type FileService struct {
+	storage  FileStorage
+	maxBytes int64
+}
+
+var (
+	fileService     *FileService
+	fileServiceOnce sync.Once
+	fileServiceErr  error
+)
+
+// InitFileService creates the singleton file service with the configured storage backend
+func InitFileService(cfg *config.Config) (*FileService, error) {
+	fileServiceOnce.Do(func() {
+		storage, err := NewFileStorage(cfg.Files)
+		if err != nil {
+			fileServiceErr = err
+			return
+		}
+		fileService = &FileService{storage: storage, maxBytes: cfg.Files.MaxBytes}
+	})
+	return fileService, fileServiceErr
+}
+
+// GetFileService returns the singleton service, or nil if it was not initialized
+func GetFileService() *FileService {
+	return fileService
+}
+
+// MaxBytes returns the maximum size of an uploaded file
+func (s *FileService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Upload stores the content of r as a new file owned by userID. size is the
+// declared size of the content; uploads larger than MaxBytes are rejected
+func (s *FileService) Upload(ctx context.Context, userID int64, filename, purpose, contentType string, r io.Reader, size int64) (*database.File, error) {
+	if size > s.maxBytes {
+		return nil, ErrFileTooLarge
+	}
+	id := "file-" + utils.GenerateRandomString(24)
+	file := &database.File{
+		ID:          id,
+		UserID:      userID,
+		Filename:    filename,
+		Purpose:     purpose,
+		Bytes:       size,
+		ContentType: contentType,
+		Storage:     s.storage.Name(),
+		StorageKey:  fmt.Sprintf("%d/%s", userID, id),
+	}
+
+	if err := s.storage.Put(ctx, file.StorageKey, io.LimitReader(r, size), size, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store file content: %w", err)
+	}
+	if err := database.CreateFile(file); err != nil {
+		if delErr := s.storage.Delete(context.Background(), file.StorageKey); delErr != nil {
+			logrus.WithError(delErr).WithField("file_id", id).Warn("Failed to remove content of unsaved file")
+		}
+		return nil, fmt.Errorf("failed to save file metadata: %w", err)
+	}
+	return file, nil
+}
+
+// Open returns the content of a file
+func (s *FileService) Open(ctx context.Context, file *database.File) (io.ReadCloser, error) {
+	if file.Storage != s.storage.Name() {
+		return nil, fmt.Errorf("file %s is stored in %s but the active storage is %s", file.ID, file.Storage, s.storage.Name())
+	}
+	return s.storage.Open(ctx, file.StorageKey)
+}
+
+// ReadAll returns the content of a file, refusing files larger than limit
+func (s *FileService) ReadAll(ctx context.Context, file *database.File, limit int64) ([]byte, error) {
+	if file.Bytes > limit {
+		return nil, ErrFileTooLarge
+	}
+	content, err := s.Open(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(io.LimitReader(content, limit))
+}
+
+// Delete removes a file's metadata and content. The metadata goes first so a
+// failed content delete leaves an orphaned object rather than a dangling record
+func (s *FileService) Delete(ctx context.Context, file *database.File) error {
+	if err := database.DeleteFile(file.ID); err != nil {
+		return err
+	}
+	if file.Storage != s.storage.Name() {
+		logrus.WithField("file_id", file.ID).Warnf("File content is in %s storage, which is not active; content was not removed", file.Storage)
+		return nil
+	}
+	if err := s.storage.Delete(ctx, file.StorageKey); err != nil {
+		logrus.WithError(err).WithField("file_id", file.ID).Warn("Failed to remove file content")
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported gateway export formats
+const (
+	ImportFormatOneAPI = "one-api"
+	ImportFormatNewAPI = "new-api"
+)
+
+// DefaultQuotaPerUnit is the one-api/new-api quota amount worth 1 USD
+const DefaultQuotaPerUnit = 500000
+
+// one-api/new-api log types
+const (
+	gatewayLogTopup   = 1
+	gatewayLogConsume = 2
+)
+
+var (
+	ErrUnsupportedImportFormat = errors.New("unsupported import format, expected one-api or new-api")
+)
+
+// GatewayExport is the JSON export of a one-api/new-api instance (rows of the users, tokens and logs tables)
+type GatewayExport struct {
+	Users  []GatewayUser  `json:"users"`
+	Tokens []GatewayToken `json:"tokens"`
+	Logs   []GatewayLog   `json:"logs"`
+}
+
+// GatewayUser is a row of the one-api/new-api users table
+type GatewayUser struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name"`
+	Role        int    `json:"role"`
+	Status      int    `json:"status"`
+	Email       string `json:"email"`
+	Quota       int64  `json:"quota"`
+	UsedQuota   int64  `json:"used_quota"`
+}
+
+// GatewayToken is a row of the one-api/new-api tokens table
+type GatewayToken struct {
+	ID             int64  `json:"id"`
+	UserID         int64  `json:"user_id"`
+	Key            string `json:"key"`
+	Status         int    `json:"status"`
+	Name           string `json:"name"`
+	CreatedTime    int64  `json:"created_time"`
+	AccessedTime   int64  `json:"accessed_time"`
+	ExpiredTime    int64  `json:"expired_time"`
+	RemainQuota    int64  `json:"remain_quota"`
+	UnlimitedQuota bool   `json:"unlimited_quota"`
+	UsedQuota      int64  `json:"used_quota"`
+	Models         string `json:"models"`       // one-api
+	ModelLimits    string `json:"model_limits"` // new-api
+}
+
+// GatewayLog is a row of the one-api/new-api logs table
+type GatewayLog struct {
+	UserID           int64  `json:"user_id"`
+	CreatedAt        int64  `json:"created_at"`
+	Type             int    `json:"type"`
+	Content          string `json:"content"`
+	Username         string `json:"username"`
+	TokenName        string `json:"token_name"`
+	TokenID          int64  `json:"token_id"`
+	ModelName        string `json:"model_name"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	ElapsedTime      int    `json:"elapsed_time"` // one-api, milliseconds
+	UseTime          int    `json:"use_time"`     // new-api, seconds
+}
+
+// ImportGatewayExport maps a one-api/new-api export into users, api_keys,
+// balance_transactions and usage_records. With dryRun the import is validated
+// inside a transaction that is rolled back.
+func ImportGatewayExport(format string, export *GatewayExport, quotaPerUnit float64, dryRun bool) (*database.ImportResult, error) {
+	if format != ImportFormatOneAPI && format != ImportFormatNewAPI {
+		return nil, ErrUnsupportedImportFormat
+	}
+	if quotaPerUnit <= 0 {
+		quotaPerUnit = DefaultQuotaPerUnit
+	}
+	toUSD := func(quota int64) float64 { return float64(quota) / quotaPerUnit }
+
+	data := &database.ImportData{}
+	warnings := []string{}
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	knownUsers := make(map[int64]*database.ImportUser)
+	for _, u := range export.Users {
+		username := strings.TrimSpace(u.Username)
+		if username == "" {
+			warn("user %d has no username, skipped", u.ID)
+			continue
+		}
+		if len(username) > 32 {
+			warn("username %q longer than 32 characters, skipped", username)
+			continue
+		}
+
+		email := strings.TrimSpace(u.Email)
+		if email == "" {
+			email = username + "@imported.invalid"
+			warn("user %q has no email, using placeholder %s", username, email)
+		}
+
+		passwordHash := u.Password
+		if !strings.HasPrefix(passwordHash, "$2") {
+			passwordHash, _ = unusablePasswordHash()
+			warn("user %q has no bcrypt password hash, password reset required", username)
+		}
+
+		role := "user"
+		if u.Role >= 10 {
+			role = "admin"
+		}
+
+		balance := toUSD(u.Quota)
+		consumed := toUSD(u.UsedQuota)
+		iu := &database.ImportUser{
+			SourceID:       u.ID,
+			Username:       username,
+			Email:          email,
+			PasswordHash:   passwordHash,
+			Role:           role,
+			IsActive:       u.Status == 1,
+			Balance:        balance,
+			TotalConsumed:  consumed,
+			TotalRecharged: balance + consumed,
+		}
+		knownUsers[u.ID] = iu
+		data.Users = append(data.Users, iu)
+	}
+
+	tokenKeys := make(map[int64]string)
+	for _, t := range export.Tokens {
+		if t.Key == "" {
+			warn("token %d has no key, skipped", t.ID)
+			continue
+		}
+		// one-api/new-api store keys without the sk- prefix that clients send
+		key := t.Key
+		if !strings.HasPrefix(key, "sk-") {
+			key = "sk-" + key
+		}
+		tokenKeys[t.ID] = key
+
+		it := &database.ImportToken{
+			SourceUserID: t.UserID,
+			Key:          key,
+			Name:         t.Name,
+			IsActive:     t.Status == 1,
+			QuotaUsed:    toUSD(t.UsedQuota),
+			CreatedAt:    unixOrNow(t.CreatedTime),
+		}
+		if !t.UnlimitedQuota {
+			limit := toUSD(t.RemainQuota + t.UsedQuota)
+			it.QuotaLimit = &limit
+		}
+		if t.ExpiredTime > 0 {
+			expires := time.Unix(t.ExpiredTime, 0)
+			it.ExpiresAt = &expires
+		}
+		if t.AccessedTime > 0 {
+			accessed := time.Unix(t.AccessedTime, 0)
+			it.LastUsedAt = &accessed
+		}
+		models := t.Models
+		if format == ImportFormatNewAPI && t.ModelLimits != "" {
+			models = t.ModelLimits
+		}
+		for _, m := range strings.Split(models, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				it.AllowedModels = append(it.AllowedModels, m)
+			}
+		}
+		data.Tokens = append(data.Tokens, it)
+	}
+
+	logsByUser := make(map[int64][]GatewayLog)
+	for _, l := range export.Logs {
+		if _, ok := knownUsers[l.UserID]; !ok {
+			continue
+		}
+		switch l.Type {
+		case gatewayLogTopup, gatewayLogConsume:
+			logsByUser[l.UserID] = append(logsByUser[l.UserID], l)
+		}
+	}
+
+	for _, user := range data.Users {
+		data.Transactions = append(data.Transactions, buildImportLedger(user, logsByUser[user.SourceID], tokenKeys, toUSD)...)
+	}
+
+	for userID, logs := range logsByUser {
+		for _, l := range logs {
+			if l.Type != gatewayLogConsume {
+				continue
+			}
+			durationMs := l.ElapsedTime
+			if format == ImportFormatNewAPI {
+				durationMs = l.UseTime * 1000
+			}
+			data.Usage = append(data.Usage, &database.ImportUsage{
+				SourceUserID:     userID,
+				Username:         l.Username,
+				APIToken:         tokenKeys[l.TokenID],
+				TokenName:        l.TokenName,
+				Model:            l.ModelName,
+				PromptTokens:     l.PromptTokens,
+				CompletionTokens: l.CompletionTokens,
+				RequestTime:      time.Unix(l.CreatedAt, 0),
+				DurationMs:       durationMs,
+			})
+		}
+	}
+
+	result, err := database.ImportGatewayData(data, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(warnings, result.Warnings...)
+	return result, nil
+}
+
+// buildImportLedger turns a user's top-up and consumption logs into balance
+// transactions whose running balance ends at the user's imported balance.
+// An opening "import" transaction accounts for history missing from the export.
+func buildImportLedger(user *database.ImportUser, logs []GatewayLog, tokenKeys map[int64]string, toUSD func(int64) float64) []*database.ImportTransaction {
+	sort.Slice(logs, func(i, j int) bool { return logs[i].CreatedAt < logs[j].CreatedAt })
+
+	txs := make([]*database.ImportTransaction, 0, len(logs)+1)
+	for _, l := range logs {
+		tx := &database.ImportTransaction{
+			SourceUserID: user.SourceID,
+			Description:  l.Content,
+			CreatedAt:    time.Unix(l.CreatedAt, 0),
+		}
+		if l.Type == gatewayLogTopup {
+			tx.Type = database.TransactionTypeImport
+			tx.Amount = toUSD(l.Quota)
+		} else {
+			tx.Type = database.TransactionTypeAPIUsage
+			tx.Amount = -toUSD(l.Quota)
+			tx.Tokens = l.PromptTokens + l.CompletionTokens
+			tx.APIToken = tokenKeys[l.TokenID]
+			tx.Model = l.ModelName
+		}
+		txs = append(txs, tx)
+	}
+
+	// Walk backwards from the final balance to fill in balance_after
+	balance := user.Balance
+	for i := len(txs) - 1; i >= 0; i-- {
+		txs[i].BalanceAfter = balance
+		balance -= txs[i].Amount
+	}
+
+	openedAt := time.Now()
+	if len(txs) > 0 {
+		openedAt = txs[0].CreatedAt.Add(-time.Second)
+	}
+	opening := &database.ImportTransaction{
+		SourceUserID: user.SourceID,
+		Type:         database.TransactionTypeImport,
+		Amount:       balance,
+		BalanceAfter: balance,
+		Description:  "Opening balance imported from previous gateway",
+		CreatedAt:    openedAt,
+	}
+	return append([]*database.ImportTransaction{opening}, txs...)
+}
+
+func unixOrNow(ts int64) time.Time {
+	if ts <= 0 {
+		return time.Now()
+	}
+	return time.Unix(ts, 0)
+}
+
+// unusablePasswordHash returns a bcrypt hash of a random secret so the imported
+// account exists but can only be accessed after a password reset
+func unusablePasswordHash() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	return string(hash), err
+}
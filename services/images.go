@@ -0,0 +1,124 @@
+package services
+
+import (
+	"Curry2API-go/models"
+	"Curry2API-go/services/providers"
+	"strings"
+)
+
+// defaultImagePrice is the USD price per image for models without a built-in price,
+// such as image models served by custom upstreams
+const defaultImagePrice = 0.04
+
+// IsGPTImageModel reports whether model is a gpt-image model, which always returns
+// base64 images and does not accept response_format
+func IsGPTImageModel(model string) bool {
+	return strings.HasPrefix(strings.ToLower(model), "gpt-image-")
+}
+
+// isBuiltinImageModel reports whether model is an OpenAI image model
+func isBuiltinImageModel(model string) bool {
+	lower := strings.ToLower(model)
+	return strings.HasPrefix(lower, "dall-e-") || IsGPTImageModel(lower)
+}
+
+// ImagePrice returns the USD price of one image, following OpenAI's per-image list prices
+func ImagePrice(model, size, quality string) float64 {
+	model = strings.ToLower(model)
+	quality = strings.ToLower(quality)
+	large := size != "" && size != "auto" && size != "1024x1024" && size != "512x512" && size != "256x256"
+
+	switch {
+	case model == "dall-e-2":
+		switch size {
+		case "256x256":
+			return 0.016
+		case "512x512":
+			return 0.018
+		}
+		return 0.02
+	case model == "dall-e-3":
+		if quality == "hd" {
+			if large {
+				return 0.12
+			}
+			return 0.08
+		}
+		if large {
+			return 0.08
+		}
+		return 0.04
+	case IsGPTImageModel(model):
+		switch quality {
+		case "low":
+			if large {
+				return 0.016
+			}
+			return 0.011
+		case "high":
+			if large {
+				return 0.25
+			}
+			return 0.167
+		}
+		// medium and auto
+		if large {
+			return 0.063
+		}
+		return 0.042
+	}
+	return defaultImagePrice
+}
+
+// imageCandidates returns the providers that can serve the image model: custom
+// upstreams listing it, then OpenAI for DALL·E and gpt-image models. Caller holds r.mu
+func (r *ProviderRouter) imageCandidates(model string, keyTags []string) []string {
+	candidates := append([]string(nil), r.modelUpstreams[model]...)
+	if isBuiltinImageModel(model) {
+		candidates = append(candidates, "openai")
+	}
+
+	available := candidates[:0]
+	for _, name := range candidates {
+		provider, exists := r.providers[name]
+		if !exists || !provider.IsAvailable() || !r.allow(name, keyTags) {
+			continue
+		}
+		if _, ok := provider.(providers.ImageGenerationClient); ok {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// GetImageProvider returns the provider that serves the image model, balanced
+// across the candidates like chat models
+func (r *ProviderRouter) GetImageProvider(model string, keyTags []string) (providers.ImageGenerationClient, string, bool) {
+	if r == nil {
+		return nil, "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	candidates := r.imageCandidates(model, keyTags)
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+	name, ok := r.balancer.pick(model, candidates)
+	if !ok {
+		return nil, "", false
+	}
+	return r.providers[name].(providers.ImageGenerationClient), name, true
+}
+
+// ImageUsageTokens converts the usage reported by gpt-image models to the usage
+// recorded in usage_records; DALL·E models report none
+func ImageUsageTokens(usage *models.ImageUsage) *models.Usage {
+	if usage == nil {
+		return &models.Usage{}
+	}
+	return &models.Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
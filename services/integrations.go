@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownIntegration is returned for an integration ID that has no snippet generator
+var ErrUnknownIntegration = errors.New("unknown integration")
+
+// integrationProviderID names this gateway in client configs that key providers by ID
+const integrationProviderID = "curry2api"
+
+// integrationKeyEnv is the environment variable that holds the API key for clients
+// that read it from the environment (Codex CLI)
+const integrationKeyEnv = "CURRY2API_API_KEY"
+
+// Integration describes a client tool that can be pointed at this gateway
+type Integration struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Integrations lists the supported client tools in display order
+var Integrations = []Integration{
+	{ID: "claude-code", Name: "Claude Code", Description: "Anthropic's terminal coding agent, via the /v1/messages endpoint"},
+	{ID: "codex", Name: "Codex CLI", Description: "OpenAI's terminal coding agent, via the /v1/chat/completions endpoint"},
+	{ID: "continue", Name: "Continue", Description: "continue.dev IDE extension, as an OpenAI-compatible provider"},
+	{ID: "cline", Name: "Cline", Description: "Cline VS Code extension, as an OpenAI Compatible provider"},
+}
+
+// IntegrationFile is one ready-to-paste file or shell snippet
+type IntegrationFile struct {
+	Path     string `json:"path"`     // Where the content goes, e.g. ~/.codex/config.toml
+	Language string `json:"language"` // Syntax for highlighting: json, toml, yaml or shell
+	Content  string `json:"content"`
+}
+
+// IntegrationConfig is the generated setup for one client tool and API key
+type IntegrationConfig struct {
+	Integration  string            `json:"integration"`
+	Name         string            `json:"name"`
+	BaseURL      string            `json:"base_url"`
+	Models       []string          `json:"models"`
+	ModelMapping map[string]string `json:"model_mapping"` // Client model slot → gateway model
+	Files        []IntegrationFile `json:"files"`
+	Instructions []string          `json:"instructions"`
+}
+
+// pickIntegrationModel returns the first model containing one of the preferred
+// substrings, trying them in order, or the first model when none match
+func pickIntegrationModel(models []string, preferred ...string) string {
+	for _, want := range preferred {
+		for _, model := range models {
+			if strings.Contains(strings.ToLower(model), want) {
+				return model
+			}
+		}
+	}
+	if len(models) > 0 {
+		return models[0]
+	}
+	return ""
+}
+
+// indentJSON renders v as indented JSON for a config file
+func indentJSON(v interface{}) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data) + "\n"
+}
+
+// BuildIntegrationConfig generates the config snippets that point the integration
+// at baseURL (the gateway root, without /v1) using apiKey and the models it may call
+func BuildIntegrationConfig(id, baseURL, apiKey string, models []string) (*IntegrationConfig, error) {
+	var integration *Integration
+	for i := range Integrations {
+		if Integrations[i].ID == id {
+			integration = &Integrations[i]
+			break
+		}
+	}
+	if integration == nil {
+		return nil, ErrUnknownIntegration
+	}
+
+	cfg := &IntegrationConfig{
+		Integration: integration.ID,
+		Name:        integration.Name,
+		BaseURL:     baseURL,
+		Models:      models,
+	}
+	openAIBase := baseURL + "/v1"
+
+	switch id {
+	case "claude-code":
+		model := pickIntegrationModel(models, "sonnet", "opus", "claude")
+		fastModel := pickIntegrationModel(models, "haiku", "sonnet", "claude")
+		cfg.ModelMapping = map[string]string{
+			"ANTHROPIC_MODEL":            model,
+			"ANTHROPIC_SMALL_FAST_MODEL": fastModel,
+		}
+		cfg.Files = []IntegrationFile{
+			{
+				Path:     "~/.claude/settings.json",
+				Language: "json",
+				Content: indentJSON(map[string]interface{}{
+					"env": map[string]string{
+						"ANTHROPIC_BASE_URL":         baseURL,
+						"ANTHROPIC_AUTH_TOKEN":       apiKey,
+						"ANTHROPIC_MODEL":            model,
+						"ANTHROPIC_SMALL_FAST_MODEL": fastModel,
+					},
+				}),
+			},
+			{
+				Path:     "shell",
+				Language: "shell",
+				Content: "export ANTHROPIC_BASE_URL=" + strconv.Quote(baseURL) + "\n" +
+					"export ANTHROPIC_AUTH_TOKEN=" + strconv.Quote(apiKey) + "\n" +
+					"export ANTHROPIC_MODEL=" + strconv.Quote(model) + "\n" +
+					"export ANTHROPIC_SMALL_FAST_MODEL=" + strconv.Quote(fastModel) + "\n",
+			},
+		}
+		cfg.Instructions = []string{
+			"Merge the env block into ~/.claude/settings.json, or export the variables in your shell profile",
+			"Run claude in your project directory",
+		}
+
+	case "codex":
+		model := pickIntegrationModel(models, "codex", "gpt-5", "gpt-")
+		cfg.ModelMapping = map[string]string{"model": model}
+		cfg.Files = []IntegrationFile{
+			{
+				Path:     "~/.codex/config.toml",
+				Language: "toml",
+				Content: "model = " + strconv.Quote(model) + "\n" +
+					"model_provider = " + strconv.Quote(integrationProviderID) + "\n\n" +
+					"[model_providers." + integrationProviderID + "]\n" +
+					"name = \"Curry2API\"\n" +
+					"base_url = " + strconv.Quote(openAIBase) + "\n" +
+					"env_key = " + strconv.Quote(integrationKeyEnv) + "\n" +
+					"wire_api = \"responses\"\n",
+			},
+			{
+				Path:     "shell",
+				Language: "shell",
+				Content:  "export " + integrationKeyEnv + "=" + strconv.Quote(apiKey) + "\n",
+			},
+		}
+		cfg.Instructions = []string{
+			"Add the provider to ~/.codex/config.toml",
+			"Export " + integrationKeyEnv + " in your shell profile, then run codex",
+		}
+
+	case "continue":
+		var b strings.Builder
+		b.WriteString("name: Curry2API\nversion: 1.0.0\nschema: v1\nmodels:\n")
+		cfg.ModelMapping = make(map[string]string, len(models))
+		for _, model := range models {
+			cfg.ModelMapping[model] = model
+			b.WriteString("  - name: " + strconv.Quote(model) + "\n")
+			b.WriteString("    provider: openai\n")
+			b.WriteString("    model: " + strconv.Quote(model) + "\n")
+			b.WriteString("    apiBase: " + strconv.Quote(openAIBase) + "\n")
+			b.WriteString("    apiKey: " + strconv.Quote(apiKey) + "\n")
+			b.WriteString("    roles:\n      - chat\n      - edit\n      - apply\n")
+		}
+		cfg.Files = []IntegrationFile{
+			{Path: "~/.continue/config.yaml", Language: "yaml", Content: b.String()},
+		}
+		cfg.Instructions = []string{
+			"Merge the models into ~/.continue/config.yaml (or the workspace .continue/config.yaml)",
+			"Reload the Continue extension and pick a model from the model selector",
+		}
+
+	case "cline":
+		model := pickIntegrationModel(models, "sonnet", "claude", "gpt-5")
+		cfg.ModelMapping = map[string]string{"openAiModelId": model}
+		cfg.Files = []IntegrationFile{
+			{
+				Path:     "Cline settings",
+				Language: "json",
+				Content: indentJSON(map[string]string{
+					"apiProvider":   "openai",
+					"openAiBaseUrl": openAIBase,
+					"openAiApiKey":  apiKey,
+					"openAiModelId": model,
+				}),
+			},
+		}
+		cfg.Instructions = []string{
+			"Open Cline settings and choose the \"OpenAI Compatible\" API provider",
+			"Enter the Base URL, API Key and Model ID shown above",
+		}
+	}
+	return cfg, nil
+}
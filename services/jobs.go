@@ -0,0 +1,122 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Background job states reported by /admin/jobs
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// JobStatus is the progress of a long-running background job
+type JobStatus struct {
+	Name       string           `json:"name"`
+	State      string           `json:"state"`
+	Processed  int64            `json:"processed"`
+	Total      int64            `json:"total"`
+	Progress   float64          `json:"progress"` // 0-1, Processed/Total
+	Message    string           `json:"message,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Counts     map[string]int64 `json:"counts,omitempty"` // Per-item results, e.g. rows removed per table
+	StartedAt  time.Time        `json:"started_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// Job reports the progress of one background job run
+type Job struct {
+	mu     sync.Mutex
+	status JobStatus
+}
+
+var (
+	jobsMu sync.RWMutex
+	jobs   = make(map[string]*Job)
+)
+
+// StartJob registers a running job, replacing an earlier run with the same name
+func StartJob(name string, total int64) *Job {
+	now := time.Now()
+	job := &Job{status: JobStatus{
+		Name:      name,
+		State:     JobRunning,
+		Total:     total,
+		StartedAt: now,
+		UpdatedAt: now,
+	}}
+	jobsMu.Lock()
+	jobs[name] = job
+	jobsMu.Unlock()
+	return job
+}
+
+// Update sets the processed and total counts
+func (j *Job) Update(processed, total int64, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Processed = processed
+	j.status.Total = total
+	j.status.Message = message
+	j.status.UpdatedAt = time.Now()
+}
+
+// SetCount records a named result count, such as the rows removed from one table
+func (j *Job) SetCount(name string, n int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Counts == nil {
+		j.status.Counts = make(map[string]int64)
+	}
+	j.status.Counts[name] = n
+	j.status.UpdatedAt = time.Now()
+}
+
+// Finish marks the job completed, or failed when err is not nil
+func (j *Job) Finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.status.State = JobCompleted
+	if err != nil {
+		j.status.State = JobFailed
+		j.status.Error = err.Error()
+	}
+	j.status.UpdatedAt = now
+	j.status.FinishedAt = &now
+}
+
+// Status returns a snapshot of the job's progress
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.status
+	if j.status.Counts != nil {
+		status.Counts = make(map[string]int64, len(j.status.Counts))
+		for name, n := range j.status.Counts {
+			status.Counts[name] = n
+		}
+	}
+	if status.Total > 0 {
+		status.Progress = float64(status.Processed) / float64(status.Total)
+	} else if status.State == JobCompleted {
+		status.Progress = 1
+	}
+	return status
+}
+
+// ListJobs returns the latest run of every job started since startup, newest first
+func ListJobs() []JobStatus {
+	jobsMu.RLock()
+	list := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job.Status())
+	}
+	jobsMu.RUnlock()
+	sort.Slice(list, func(i, k int) bool { return list[i].StartedAt.After(list[k].StartedAt) })
+	return list
+}
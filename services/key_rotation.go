@@ -0,0 +1,167 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RotatedKey describes one rotated key. NewKey is only returned in the rotation response.
+type RotatedKey struct {
+	OldKey    string `json:"old_key"` // masked
+	NewKey    string `json:"new_key"`
+	TokenName string `json:"token_name,omitempty"`
+}
+
+// KeyRotationResult is the outcome of rotating every key of a user
+type KeyRotationResult struct {
+	UserID     int64        `json:"user_id"`
+	Rotated    []RotatedKey `json:"rotated"`
+	Failed     []string     `json:"failed,omitempty"` // masked keys that could not be rotated
+	GraceUntil *time.Time   `json:"grace_until,omitempty"`
+	Notified   bool         `json:"notified"`
+}
+
+// GenerateAPIKey returns a new random sk- key
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
+
+// RotateUserKeys replaces every usable key of a user with a new value. Old keys keep
+// working until the grace period ends (grace <= 0 disables them immediately). The owner
+// is emailed and the rotation is recorded in the audit log.
+func RotateUserKeys(cfg *config.Config, actorID *int64, userID int64, grace time.Duration, reason string) (*KeyRotationResult, error) {
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := database.ListRotatableKeysByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &KeyRotationResult{UserID: userID, Rotated: []RotatedKey{}}
+	if grace > 0 {
+		until := time.Now().Add(grace)
+		result.GraceUntil = &until
+	}
+
+	km := middleware.GetKeyManager()
+	maskedOld := make([]string, 0, len(keys))
+	for _, oldKey := range keys {
+		masked := middleware.MaskKey(oldKey)
+		newKey, err := GenerateAPIKey()
+		if err == nil {
+			err = database.RotateAPIKey(oldKey, newKey, result.GraceUntil)
+		}
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to rotate API key %s", masked)
+			result.Failed = append(result.Failed, masked)
+			continue
+		}
+
+		rotated := RotatedKey{OldKey: masked, NewKey: newKey}
+		if info, err := database.GetAPIKey(newKey); err == nil {
+			rotated.TokenName = info.TokenName
+		}
+		result.Rotated = append(result.Rotated, rotated)
+		maskedOld = append(maskedOld, masked)
+	}
+
+	if len(result.Rotated) > 0 {
+		if err := km.ReloadKeys(); err != nil {
+			logrus.WithError(err).Warn("Failed to reload keys after rotation")
+		}
+
+		if user.Email != "" {
+			if err := NewEmailService(cfg).SendKeyRotationNotice(user.Email, user.Username, maskedOld, result.GraceUntil, reason); err != nil {
+				logrus.WithError(err).Warnf("Failed to send key rotation notice to user %d", userID)
+			} else {
+				result.Notified = true
+			}
+		}
+	}
+
+	details := map[string]interface{}{
+		"rotated":  maskedOld,
+		"failed":   result.Failed,
+		"grace":    grace.String(),
+		"reason":   reason,
+		"notified": result.Notified,
+	}
+	if err := database.CreateAuditLog(actorID, database.AuditActionKeyRotation, "user", strconv.FormatInt(userID, 10), details); err != nil {
+		logrus.WithError(err).Error("Failed to record key rotation in audit log")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"rotated": len(result.Rotated),
+		"failed":  len(result.Failed),
+		"grace":   grace,
+	}).Warnf("API keys rotated for user %s", user.Username)
+
+	return result, nil
+}
+
+// KeyRotation is the outcome of rotating a single key
+type KeyRotation struct {
+	RotatedKey
+	GraceUntil *time.Time `json:"grace_until,omitempty"`
+}
+
+// RotateKey replaces one key with a new value that inherits its settings. The old key keeps
+// working until the grace period ends (grace <= 0 disables it immediately). The rotation is
+// recorded in the audit log; database.ErrKeyNotFound means the key is missing or already rotated.
+func RotateKey(actorID *int64, key string, grace time.Duration, reason string) (*KeyRotation, error) {
+	newKey, err := GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &KeyRotation{RotatedKey: RotatedKey{OldKey: middleware.MaskKey(key), NewKey: newKey}}
+	if grace > 0 {
+		until := time.Now().Add(grace)
+		result.GraceUntil = &until
+	}
+	if err := database.RotateAPIKey(key, newKey, result.GraceUntil); err != nil {
+		return nil, err
+	}
+
+	var ownerID *int64
+	if info, err := database.GetAPIKey(newKey); err == nil {
+		result.TokenName = info.TokenName
+		ownerID = info.UserID
+	}
+	if err := middleware.GetKeyManager().ReloadKeys(); err != nil {
+		logrus.WithError(err).Warn("Failed to reload keys after rotation")
+	}
+
+	details := map[string]interface{}{
+		"new_key": middleware.MaskKey(newKey),
+		"user_id": ownerID,
+		"grace":   grace.String(),
+		"reason":  reason,
+	}
+	if err := database.CreateAuditLog(actorID, database.AuditActionKeyRotation, "api_key", result.OldKey, details); err != nil {
+		logrus.WithError(err).Error("Failed to record key rotation in audit log")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"old_key": result.OldKey,
+		"new_key": details["new_key"],
+		"grace":   grace,
+	}).Warn("API key rotated")
+
+	return result, nil
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Latency SLO webhook events
+const (
+	LatencyEventViolated = "latency_slo.violated"
+	LatencyEventResolved = "latency_slo.resolved"
+)
+
+// LatencyWebhookPayload is POSTed to the configured webhook when an SLO alert fires or resolves
+type LatencyWebhookPayload struct {
+	Event       string               `json:"event"`
+	SLO         *database.LatencySLO `json:"slo"`
+	ObservedMs  int                  `json:"observed_ms"`
+	ThresholdMs int                  `json:"threshold_ms"`
+	Windows     int                  `json:"windows"`
+	Samples     int                  `json:"samples"`
+	Message     string               `json:"message"`
+	Timestamp   time.Time            `json:"timestamp"`
+}
+
+// LatencySLOStatus is the live evaluation state of one SLO
+type LatencySLOStatus struct {
+	SLOID            int64     `json:"slo_id"`
+	LastObservedMs   int       `json:"last_observed_ms"`
+	LastSamples      int       `json:"last_samples"`
+	ConsecutiveFails int       `json:"consecutive_fails"`
+	Alerting         bool      `json:"alerting"`
+	EvaluatedAt      time.Time `json:"evaluated_at"`
+}
+
+// LatencySLOMonitor evaluates latency SLOs against the samples collected by
+// middleware.LatencyRecorder once per window
+type LatencySLOMonitor struct {
+	window     time.Duration
+	webhookURL string
+	client     *http.Client
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+
+	mu     sync.RWMutex
+	status map[int64]*LatencySLOStatus
+}
+
+var (
+	latencyMonitor     *LatencySLOMonitor
+	latencyMonitorOnce sync.Once
+)
+
+// InitLatencySLOMonitor creates the singleton monitor
+func InitLatencySLOMonitor(window time.Duration, webhookURL string) *LatencySLOMonitor {
+	latencyMonitorOnce.Do(func() {
+		if window <= 0 {
+			window = time.Minute
+		}
+		latencyMonitor = &LatencySLOMonitor{
+			window:     window,
+			webhookURL: webhookURL,
+			client:     &http.Client{Timeout: 10 * time.Second},
+			stopChan:   make(chan struct{}),
+			status:     make(map[int64]*LatencySLOStatus),
+		}
+	})
+	return latencyMonitor
+}
+
+// GetLatencySLOMonitor returns the singleton monitor, or nil if it was not initialized
+func GetLatencySLOMonitor() *LatencySLOMonitor {
+	return latencyMonitor
+}
+
+// Start begins evaluating SLOs at the end of every window
+func (m *LatencySLOMonitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Evaluate(middleware.TakeLatencySamples())
+			case <-m.stopChan:
+				return
+			}
+		}
+	}()
+	logrus.Infof("Latency SLO monitor started (window %s)", m.window)
+}
+
+// Stop stops the monitor
+func (m *LatencySLOMonitor) Stop() {
+	close(m.stopChan)
+	m.wg.Wait()
+}
+
+// Status returns the live evaluation state of all SLOs
+func (m *LatencySLOMonitor) Status() map[int64]*LatencySLOStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[int64]*LatencySLOStatus, len(m.status))
+	for id, s := range m.status {
+		copied := *s
+		out[id] = &copied
+	}
+	return out
+}
+
+// Evaluate checks every active SLO against one window of samples. An alert is raised
+// once an SLO has been violated for ConsecutiveWindows windows in a row and resolved
+// on the first window that is back within budget.
+func (m *LatencySLOMonitor) Evaluate(samples map[middleware.LatencyKey][]middleware.LatencySample) {
+	slos, err := database.ListLatencySLOs(true)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load latency SLOs")
+		return
+	}
+
+	now := time.Now()
+	active := make(map[int64]bool, len(slos))
+	for _, slo := range slos {
+		active[slo.ID] = true
+		values := matchingLatencies(slo, samples)
+
+		m.mu.Lock()
+		state, ok := m.status[slo.ID]
+		if !ok {
+			state = &LatencySLOStatus{SLOID: slo.ID}
+			m.status[slo.ID] = state
+		}
+		state.EvaluatedAt = now
+		state.LastSamples = len(values)
+
+		// Too little traffic to judge: keep the current streak unchanged
+		if len(values) == 0 || len(values) < slo.MinSamples {
+			m.mu.Unlock()
+			continue
+		}
+
+		observed := int(latencyPercentile(values, slo.Percentile).Milliseconds())
+		state.LastObservedMs = observed
+
+		var event string
+		if observed > slo.ThresholdMs {
+			state.ConsecutiveFails++
+			if !state.Alerting && state.ConsecutiveFails >= slo.ConsecutiveWindows {
+				state.Alerting = true
+				event = LatencyEventViolated
+			}
+		} else {
+			state.ConsecutiveFails = 0
+			if state.Alerting {
+				state.Alerting = false
+				event = LatencyEventResolved
+			}
+		}
+		windows := state.ConsecutiveFails
+		m.mu.Unlock()
+
+		if event != "" {
+			m.notify(event, slo, observed, windows, len(values))
+		}
+	}
+
+	m.mu.Lock()
+	for id := range m.status {
+		if !active[id] {
+			delete(m.status, id)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// notify records the alert for admins and calls the webhook
+func (m *LatencySLOMonitor) notify(event string, slo *database.LatencySLO, observedMs, windows, samples int) {
+	var message string
+	if event == LatencyEventViolated {
+		message = fmt.Sprintf("%s: p%g %s %dms exceeds %dms for %d consecutive windows (%d samples)",
+			slo.Name, slo.Percentile, slo.Metric, observedMs, slo.ThresholdMs, windows, samples)
+		if err := database.CreateLatencyAlert(&database.LatencyAlert{
+			SLOID:       slo.ID,
+			ObservedMs:  observedMs,
+			ThresholdMs: slo.ThresholdMs,
+			Windows:     windows,
+			Samples:     samples,
+			Message:     message,
+		}); err != nil {
+			logrus.WithError(err).Warn("Failed to record latency alert")
+		}
+		logrus.Warn("Latency SLO violated: " + message)
+	} else {
+		message = fmt.Sprintf("%s: p%g %s back within budget at %dms (threshold %dms)",
+			slo.Name, slo.Percentile, slo.Metric, observedMs, slo.ThresholdMs)
+		if err := database.ResolveLatencyAlerts(slo.ID); err != nil {
+			logrus.WithError(err).Warn("Failed to resolve latency alerts")
+		}
+		logrus.Info("Latency SLO resolved: " + message)
+	}
+
+	if m.webhookURL == "" {
+		return
+	}
+	body, _ := json.Marshal(LatencyWebhookPayload{
+		Event:       event,
+		SLO:         slo,
+		ObservedMs:  observedMs,
+		ThresholdMs: slo.ThresholdMs,
+		Windows:     windows,
+		Samples:     samples,
+		Message:     message,
+		Timestamp:   time.Now(),
+	})
+	resp, err := m.client.Post(m.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to deliver latency SLO webhook")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logrus.Warnf("Latency SLO webhook returned HTTP %d", resp.StatusCode)
+	}
+}
+
+// matchingLatencies collects the SLO's metric from every sample group it covers
+func matchingLatencies(slo *database.LatencySLO, samples map[middleware.LatencyKey][]middleware.LatencySample) []time.Duration {
+	var values []time.Duration
+	for key, group := range samples {
+		if slo.Endpoint != "" && slo.Endpoint != key.Endpoint {
+			continue
+		}
+		if slo.Model != "" && slo.Model != key.Model {
+			continue
+		}
+		for _, s := range group {
+			if slo.Metric == database.LatencyMetricTotal {
+				values = append(values, s.Total)
+			} else {
+				values = append(values, s.TTFT)
+			}
+		}
+	}
+	return values
+}
+
+// latencyPercentile returns the nearest-rank percentile (0-100) of values
+func latencyPercentile(values []time.Duration, percentile float64) time.Duration {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := int(math.Ceil(percentile / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(values) {
+		rank = len(values)
+	}
+	return values[rank-1]
+}
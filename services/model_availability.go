@@ -0,0 +1,164 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"Curry2API-go/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Machine-readable reasons a model is reported unavailable by /api/chat/models
+const (
+	UnavailableProviderDown   = "provider_down"
+	UnavailableQuotaExhausted = "quota_exhausted"
+	UnavailableAdminDisabled  = "admin_disabled"
+)
+
+// ErrIncidentNotFound is returned when resolving an unknown incident
+var ErrIncidentNotFound = errors.New("incident not found")
+
+// ModelUnavailability explains why a model cannot currently be used
+type ModelUnavailability struct {
+	Reason     string `json:"reason"`
+	IncidentID string `json:"incident_id,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// ModelAvailability is a snapshot of provider health, quota state and admin overrides,
+// taken once per model listing so every model is judged against the same state
+type ModelAvailability struct {
+	disabled       map[string]bool
+	incidents      []database.ProviderIncident
+	cursorHealthy  bool
+	cursorQuotaOut bool
+}
+
+// SnapshotModelAvailability collects the current availability inputs. quotaEnabled
+// mirrors config.Quota.Enabled; exhausted sessions only block requests when it is on.
+func SnapshotModelAvailability(quotaEnabled bool) *ModelAvailability {
+	snapshot := &ModelAvailability{disabled: make(map[string]bool)}
+
+	status, err := database.GetProviderStatus()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load provider status")
+		status = &database.ProviderStatus{}
+	}
+	for _, model := range status.DisabledModels {
+		snapshot.disabled[model] = true
+	}
+	snapshot.incidents = status.Incidents
+
+	// Cursor 会话的健康检查与配额状态决定 cursor 模型是否可用
+	now := time.Now()
+	usable := 0
+	for _, session := range middleware.GetCursorSessionManager().ListSessions() {
+		if !session.IsValid || now.After(session.ExpiresAt) {
+			continue
+		}
+		snapshot.cursorHealthy = true
+		if !quotaEnabled || session.QuotaStatus != "exhausted" {
+			usable++
+		}
+	}
+	snapshot.cursorQuotaOut = snapshot.cursorHealthy && usable == 0
+
+	return snapshot
+}
+
+// Check returns why the model is unavailable, or nil if it can be used.
+// Admin overrides take precedence over incidents, which take precedence over detected state.
+func (a *ModelAvailability) Check(model models.ModelInfo) *ModelUnavailability {
+	if a.disabled[model.ID] {
+		return &ModelUnavailability{Reason: UnavailableAdminDisabled}
+	}
+	for _, incident := range a.incidents {
+		if incident.Provider != model.Provider {
+			continue
+		}
+		if incident.Model == "" || incident.Model == model.ID {
+			return &ModelUnavailability{
+				Reason:     UnavailableProviderDown,
+				IncidentID: incident.ID,
+				Message:    incident.Message,
+			}
+		}
+	}
+	if !model.IsAvailable {
+		return &ModelUnavailability{Reason: UnavailableProviderDown}
+	}
+	if model.Provider == "cursor" {
+		if !a.cursorHealthy {
+			return &ModelUnavailability{Reason: UnavailableProviderDown}
+		}
+		if a.cursorQuotaOut {
+			return &ModelUnavailability{Reason: UnavailableQuotaExhausted}
+		}
+	}
+	return nil
+}
+
+// SetDisabledModels replaces the list of models disabled by admins
+func SetDisabledModels(modelIDs []string) ([]string, error) {
+	status, err := database.GetProviderStatus()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(modelIDs))
+	cleaned := make([]string, 0, len(modelIDs))
+	for _, id := range modelIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		cleaned = append(cleaned, id)
+	}
+	status.DisabledModels = cleaned
+	return cleaned, database.SaveProviderStatus(status)
+}
+
+// OpenIncident records a provider incident; models of that provider are reported
+// as provider_down with the incident ID until it is resolved
+func OpenIncident(provider, model, message string) (*database.ProviderIncident, error) {
+	status, err := database.GetProviderStatus()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	incident := database.ProviderIncident{
+		ID:        "inc_" + hex.EncodeToString(buf),
+		Provider:  provider,
+		Model:     model,
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+	status.Incidents = append(status.Incidents, incident)
+	if err := database.SaveProviderStatus(status); err != nil {
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// ResolveIncident removes an incident
+func ResolveIncident(id string) error {
+	status, err := database.GetProviderStatus()
+	if err != nil {
+		return err
+	}
+	for i, incident := range status.Incidents {
+		if incident.ID == id {
+			status.Incidents = append(status.Incidents[:i], status.Incidents[i+1:]...)
+			return database.SaveProviderStatus(status)
+		}
+	}
+	return ErrIncidentNotFound
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/models"
+	"Curry2API-go/services/providers"
+	"Curry2API-go/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// LocalModerationModel is the model name of the built-in rules engine, used when
+// requested explicitly or when no upstream serves the moderation model
+const LocalModerationModel = "local-rules"
+
+// DefaultModerationModel is used when the request does not name a model
+const DefaultModerationModel = "omni-moderation-latest"
+
+// ModerationCategories are the OpenAI moderation categories, all present in every result
+var ModerationCategories = []string{
+	"harassment", "harassment/threatening",
+	"hate", "hate/threatening",
+	"illicit", "illicit/violent",
+	"self-harm", "self-harm/intent", "self-harm/instructions",
+	"sexual", "sexual/minors",
+	"violence", "violence/graphic",
+}
+
+// compiledModerationRule is a local rule with its pattern compiled
+type compiledModerationRule struct {
+	category  string
+	pattern   *regexp.Regexp
+	score     float64
+	languages []string // Lowercased; empty applies the rule to every language
+}
+
+// appliesTo reports whether the rule applies to text detected as lang
+func (r compiledModerationRule) appliesTo(lang string) bool {
+	if len(r.languages) == 0 {
+		return true
+	}
+	for _, l := range r.languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	moderationRulesMu sync.RWMutex
+	moderationRules   []compiledModerationRule
+)
+
+// isBuiltinModerationModel reports whether model is an OpenAI moderation model
+func isBuiltinModerationModel(model string) bool {
+	lower := strings.ToLower(model)
+	return strings.HasPrefix(lower, "omni-moderation-") || strings.HasPrefix(lower, "text-moderation-")
+}
+
+// compileModerationRules validates rules and compiles their patterns
+func compileModerationRules(rules []database.ModerationRule) ([]compiledModerationRule, error) {
+	compiled := make([]compiledModerationRule, 0, len(rules))
+	for i, rule := range rules {
+		known := false
+		for _, category := range ModerationCategories {
+			if rule.Category == category {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("rule %d: unknown category %q", i+1, rule.Category)
+		}
+		if rule.Score < 0 || rule.Score > 1 {
+			return nil, fmt.Errorf("rule %d: score must be between 0 and 1", i+1)
+		}
+		pattern, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil || rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %d: invalid pattern %q", i+1, rule.Pattern)
+		}
+		score := rule.Score
+		if score == 0 {
+			score = 1
+		}
+		var languages []string
+		for _, lang := range rule.Languages {
+			lang = strings.ToLower(strings.TrimSpace(lang))
+			if lang == "" || len(lang) > 8 {
+				return nil, fmt.Errorf("rule %d: invalid language %q", i+1, lang)
+			}
+			languages = append(languages, lang)
+		}
+		compiled = append(compiled, compiledModerationRule{category: rule.Category, pattern: pattern, score: score, languages: languages})
+	}
+	return compiled, nil
+}
+
+// ValidateModerationRules checks that every rule names a known category and has a valid pattern
+func ValidateModerationRules(rules []database.ModerationRule) error {
+	_, err := compileModerationRules(rules)
+	return err
+}
+
+// LoadModerationRules loads the local moderation rules from the database
+func LoadModerationRules() error {
+	rules, err := database.GetModerationRules()
+	if err != nil {
+		return err
+	}
+	compiled, err := compileModerationRules(rules)
+	if err != nil {
+		return err
+	}
+	moderationRulesMu.Lock()
+	moderationRules = compiled
+	moderationRulesMu.Unlock()
+	return nil
+}
+
+// SaveModerationRules validates, stores and activates the local moderation rules
+func SaveModerationRules(rules []database.ModerationRule) error {
+	compiled, err := compileModerationRules(rules)
+	if err != nil {
+		return err
+	}
+	if err := database.SaveModerationRules(rules); err != nil {
+		return err
+	}
+	moderationRulesMu.Lock()
+	moderationRules = compiled
+	moderationRulesMu.Unlock()
+	return nil
+}
+
+// ModerateLocally scores each text against the local rules. A matching rule flags its
+// category with the rule's score and, for subcategories such as hate/threatening,
+// the parent category as well. Rules limited to languages only apply to texts detected as one of them
+func ModerateLocally(texts []string) *models.ModerationResponse {
+	moderationRulesMu.RLock()
+	rules := moderationRules
+	moderationRulesMu.RUnlock()
+
+	resp := &models.ModerationResponse{
+		ID:      newModerationID(),
+		Model:   LocalModerationModel,
+		Results: make([]models.ModerationResult, 0, len(texts)),
+	}
+	for _, text := range texts {
+		result := models.ModerationResult{
+			Categories:     make(map[string]bool, len(ModerationCategories)),
+			CategoryScores: make(map[string]float64, len(ModerationCategories)),
+		}
+		for _, category := range ModerationCategories {
+			result.Categories[category] = false
+			result.CategoryScores[category] = 0
+		}
+		lang := ""
+		for _, rule := range rules {
+			if len(rule.languages) > 0 && lang == "" {
+				lang, _ = utils.DetectLanguage(text)
+			}
+			if !rule.appliesTo(lang) || !rule.pattern.MatchString(text) {
+				continue
+			}
+			categories := []string{rule.category}
+			if parent, _, ok := strings.Cut(rule.category, "/"); ok {
+				categories = append(categories, parent)
+			}
+			for _, category := range categories {
+				result.Categories[category] = true
+				if rule.score > result.CategoryScores[category] {
+					result.CategoryScores[category] = rule.score
+				}
+			}
+			result.Flagged = true
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp
+}
+
+// newModerationID returns a random OpenAI-style moderation ID
+func newModerationID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "modr-" + hex.EncodeToString(b)
+}
+
+// moderationCandidates returns the providers that can serve the moderation model:
+// custom upstreams listing it, then OpenAI for its own moderation models. Caller holds r.mu
+func (r *ProviderRouter) moderationCandidates(model string, keyTags []string) []string {
+	candidates := append([]string(nil), r.modelUpstreams[model]...)
+	if isBuiltinModerationModel(model) {
+		candidates = append(candidates, "openai")
+	}
+
+	available := candidates[:0]
+	for _, name := range candidates {
+		provider, exists := r.providers[name]
+		if !exists || !provider.IsAvailable() || !r.allow(name, keyTags) {
+			continue
+		}
+		if _, ok := provider.(providers.ModerationClient); ok {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// GetModerationProvider returns the provider that serves the moderation model,
+// balanced across the candidates like chat models. ok is false when the local
+// rules engine should be used instead
+func (r *ProviderRouter) GetModerationProvider(model string, keyTags []string) (providers.ModerationClient, string, bool) {
+	if r == nil || model == LocalModerationModel {
+		return nil, "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	candidates := r.moderationCandidates(model, keyTags)
+	if len(candidates) == 0 {
+		return nil, "", false
+	}
+	name, ok := r.balancer.pick(model, candidates)
+	if !ok {
+		return nil, "", false
+	}
+	return r.providers[name].(providers.ModerationClient), name, true
+}
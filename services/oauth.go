@@ -11,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -24,12 +25,35 @@ type OAuthConfig struct {
 	GitHubClientID     string
 	GitHubClientSecret string
 	GitHubRedirectURL  string
+	// 通用 OIDC 提供商（Keycloak、Authentik、Azure AD 等），通过 discovery 文档获取端点
+	OIDCDiscoveryURL   string // Issuer 地址或完整的 /.well-known/openid-configuration 地址
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCRedirectURL    string
+	OIDCScopes         string // 空格分隔，默认 openid email profile
+	OIDCDisplayName    string // 登录按钮上显示的名称
+	OIDCTrustEmail     bool   // 未返回 email_verified 时仍信任 IdP 提供的邮箱（企业目录通常不返回该声明）
+	WeChatAppID        string // 微信开放平台网站应用
+	WeChatAppSecret    string
+	WeChatRedirectURL  string
+	QQAppID            string // QQ 互联网站应用
+	QQAppKey           string
+	QQRedirectURL      string
 	StateExpiry        int // State 过期时间（秒）
 }
 
 // OAuthService OAuth 服务
 type OAuthService struct {
 	config *OAuthConfig
+
+	oidcMu        sync.Mutex
+	oidcDiscovery *oidcDiscovery
+}
+
+// OAuthProviderInfo 登录页展示的已配置提供商
+type OAuthProviderInfo struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
 }
 
 // OAuthToken OAuth 令牌
@@ -37,6 +61,9 @@ type OAuthToken struct {
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token,omitempty"`
 	TokenType    string    `json:"token_type"`
+	IDToken      string    `json:"id_token,omitempty"` // 仅 OIDC
+	OpenID       string    `json:"openid,omitempty"`   // 仅微信、QQ：用户在本应用下的标识
+	UnionID      string    `json:"unionid,omitempty"`  // 仅微信、QQ：用户在同一开放平台账号下的标识
 	ExpiresIn    int       `json:"expires_in,omitempty"`
 	ExpiresAt    time.Time `json:"-"`
 }
@@ -77,6 +104,19 @@ func LoadOAuthConfig() (*OAuthConfig, error) {
 		GitHubClientID:     getEnv("GITHUB_CLIENT_ID", ""),
 		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
 		GitHubRedirectURL:  getEnv("GITHUB_REDIRECT_URL", ""),
+		OIDCDiscoveryURL:   strings.TrimRight(getEnv("OIDC_DISCOVERY_URL", ""), "/"),
+		OIDCClientID:       getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:   getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:    getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:         getEnv("OIDC_SCOPES", "openid email profile"),
+		OIDCDisplayName:    getEnv("OIDC_DISPLAY_NAME", "SSO"),
+		OIDCTrustEmail:     getEnv("OIDC_TRUST_EMAIL", "false") == "true",
+		WeChatAppID:        getEnv("WECHAT_APP_ID", ""),
+		WeChatAppSecret:    getEnv("WECHAT_APP_SECRET", ""),
+		WeChatRedirectURL:  getEnv("WECHAT_REDIRECT_URL", ""),
+		QQAppID:            getEnv("QQ_APP_ID", ""),
+		QQAppKey:           getEnv("QQ_APP_KEY", ""),
+		QQRedirectURL:      getEnv("QQ_REDIRECT_URL", ""),
 		StateExpiry:        getEnvAsInt("OAUTH_STATE_EXPIRY", 600), // 默认 10 分钟
 	}
 
@@ -94,10 +134,20 @@ func (c *OAuthConfig) validate() error {
 	hasGoogle := c.GoogleClientID != "" && c.GoogleClientSecret != "" && c.GoogleRedirectURL != ""
 	hasGitHub := c.GitHubClientID != "" && c.GitHubClientSecret != "" && c.GitHubRedirectURL != ""
 
-	if !hasGoogle && !hasGitHub {
+	hasWeChat := c.WeChatAppID != "" && c.WeChatAppSecret != "" && c.WeChatRedirectURL != ""
+	hasQQ := c.QQAppID != "" && c.QQAppKey != "" && c.QQRedirectURL != ""
+
+	if !hasGoogle && !hasGitHub && !c.hasOIDC() && !hasWeChat && !hasQQ {
 		logrus.Warn("No OAuth providers configured. OAuth login will not be available.")
 	}
 
+	if c.OIDCDiscoveryURL != "" && !c.hasOIDC() {
+		return fmt.Errorf("OIDC_DISCOVERY_URL requires OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL")
+	}
+	if c.hasOIDC() && !strings.Contains(" "+c.OIDCScopes+" ", " openid ") {
+		return fmt.Errorf("OIDC_SCOPES must include openid")
+	}
+
 	if c.StateExpiry <= 0 {
 		return fmt.Errorf("state expiry must be positive")
 	}
@@ -105,6 +155,41 @@ func (c *OAuthConfig) validate() error {
 	return nil
 }
 
+// hasOIDC 是否配置了通用 OIDC 提供商
+func (c *OAuthConfig) hasOIDC() bool {
+	return c.OIDCDiscoveryURL != "" && c.OIDCClientID != "" && c.OIDCClientSecret != "" && c.OIDCRedirectURL != ""
+}
+
+// Providers 返回已配置的提供商，供登录页显示对应的按钮
+func (s *OAuthService) Providers() []OAuthProviderInfo {
+	providers := []OAuthProviderInfo{}
+	if s.config.GoogleClientID != "" {
+		providers = append(providers, OAuthProviderInfo{ID: "google", DisplayName: "Google"})
+	}
+	if s.config.GitHubClientID != "" {
+		providers = append(providers, OAuthProviderInfo{ID: "github", DisplayName: "GitHub"})
+	}
+	if s.config.hasOIDC() {
+		providers = append(providers, OAuthProviderInfo{ID: "oidc", DisplayName: s.config.OIDCDisplayName})
+	}
+	if s.config.WeChatAppID != "" {
+		providers = append(providers, OAuthProviderInfo{ID: "wechat", DisplayName: "微信"})
+	}
+	if s.config.QQAppID != "" {
+		providers = append(providers, OAuthProviderInfo{ID: "qq", DisplayName: "QQ"})
+	}
+	return providers
+}
+
+// IsSupportedProvider 判断提供商名称是否受支持
+func IsSupportedProvider(provider string) bool {
+	switch provider {
+	case "google", "github", "oidc", "wechat", "qq":
+		return true
+	}
+	return false
+}
+
 // GenerateState 生成随机 state 参数
 func (s *OAuthService) GenerateState() (string, error) {
 	b := make([]byte, 32)
@@ -141,19 +226,6 @@ func (s *OAuthService) CleanupExpiredStates() error {
 	return cleanupExpiredOAuthStates()
 }
 
-// StartStateCleanupTask 启动定期清理过期 state 的任务
-func (s *OAuthService) StartStateCleanupTask() {
-	ticker := time.NewTicker(1 * time.Hour) // 每小时清理一次
-	go func() {
-		for range ticker.C {
-			if err := s.CleanupExpiredStates(); err != nil {
-				logrus.Errorf("Failed to cleanup expired OAuth states: %v", err)
-			}
-		}
-	}()
-	logrus.Info("OAuth state cleanup task started")
-}
-
 // GetAuthorizationURL 获取授权 URL
 func (s *OAuthService) GetAuthorizationURL(provider, state string) (string, error) {
 	switch provider {
@@ -161,6 +233,12 @@ func (s *OAuthService) GetAuthorizationURL(provider, state string) (string, erro
 		return s.getGoogleAuthURL(state)
 	case "github":
 		return s.getGitHubAuthURL(state)
+	case "oidc":
+		return s.getOIDCAuthURL(state)
+	case "wechat":
+		return s.getWeChatAuthURL(state)
+	case "qq":
+		return s.getQQAuthURL(state)
 	default:
 		return "", &OAuthError{
 			Code:     "invalid_provider",
@@ -177,6 +255,12 @@ func (s *OAuthService) ExchangeCode(provider, code string) (*OAuthToken, error)
 		return s.exchangeGoogleCode(code)
 	case "github":
 		return s.exchangeGitHubCode(code)
+	case "oidc":
+		return s.exchangeOIDCCode(code)
+	case "wechat":
+		return s.exchangeWeChatCode(code)
+	case "qq":
+		return s.exchangeQQCode(code)
 	default:
 		return nil, &OAuthError{
 			Code:     "invalid_provider",
@@ -193,6 +277,12 @@ func (s *OAuthService) GetUserInfo(provider string, token *OAuthToken) (*OAuthUs
 		return s.getGoogleUserInfo(token)
 	case "github":
 		return s.getGitHubUserInfo(token)
+	case "oidc":
+		return s.getOIDCUserInfo(token)
+	case "wechat":
+		return s.getWeChatUserInfo(token)
+	case "qq":
+		return s.getQQUserInfo(token)
 	default:
 		return nil, &OAuthError{
 			Code:     "invalid_provider",
@@ -628,6 +718,16 @@ func (s *OAuthService) getGitHubPrimaryEmail(token *OAuthToken) (string, bool, e
 	return "", false, fmt.Errorf("no email found")
 }
 
+// oauthNickname 整理微信、QQ 昵称作为用户名候选：去除首尾空白并截断，
+// 为重名时追加的后缀留出空间（用户名最长 32 个字符）
+func oauthNickname(nickname string) string {
+	runes := []rune(strings.TrimSpace(nickname))
+	if len(runes) > 24 {
+		runes = runes[:24]
+	}
+	return string(runes)
+}
+
 // Helper functions for environment variables
 
 // getEnv 获取环境变量，如果不存在则返回默认值
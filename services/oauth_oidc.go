@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// oidcDiscoveryTTL discovery 文档的缓存时间，IdP 更换端点后最迟在此时间后生效
+const oidcDiscoveryTTL = time.Hour
+
+// oidcDiscovery OpenID Provider 元数据（OpenID Connect Discovery 1.0）中用到的字段
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+
+	fetchedAt time.Time
+}
+
+// oidcClaims ID Token 与 UserInfo 中用到的标准声明
+type oidcClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"` // 字符串或字符串数组
+	ExpiresAt         int64           `json:"exp"`
+	Email             string          `json:"email"`
+	EmailVerified     json.RawMessage `json:"email_verified"` // 部分 IdP 以字符串 "true" 返回
+	Name              string          `json:"name"`
+	PreferredUsername string          `json:"preferred_username"`
+	Picture           string          `json:"picture"`
+}
+
+func (c *oidcClaims) emailVerified() bool {
+	v := strings.Trim(string(c.EmailVerified), `"`)
+	return v == "true"
+}
+
+func (c *oidcClaims) hasAudience(clientID string) bool {
+	var single string
+	if err := json.Unmarshal(c.Audience, &single); err == nil {
+		return single == clientID
+	}
+	var list []string
+	if err := json.Unmarshal(c.Audience, &list); err == nil {
+		for _, aud := range list {
+			if aud == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func oidcError(code, format string, args ...any) *OAuthError {
+	return &OAuthError{
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Provider: "oidc",
+	}
+}
+
+var oauthHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// oauthGetJSON 以 GET 请求 JSON 接口，bearer 非空时携带访问令牌（OIDC、微信、QQ 共用）
+func oauthGetJSON(endpoint, bearer string, out any) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// discoverOIDC 获取并缓存 IdP 的 discovery 文档
+func (s *OAuthService) discoverOIDC() (*oidcDiscovery, error) {
+	if !s.config.hasOIDC() {
+		return nil, oidcError("config_error", "OIDC not configured")
+	}
+
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	if s.oidcDiscovery != nil && time.Since(s.oidcDiscovery.fetchedAt) < oidcDiscoveryTTL {
+		return s.oidcDiscovery, nil
+	}
+
+	discoveryURL := s.config.OIDCDiscoveryURL
+	if !strings.HasSuffix(discoveryURL, "/.well-known/openid-configuration") {
+		discoveryURL += "/.well-known/openid-configuration"
+	}
+	doc := &oidcDiscovery{}
+	if err := oauthGetJSON(discoveryURL, "", doc); err != nil {
+		// 刷新失败时继续使用上一次的文档
+		if s.oidcDiscovery != nil {
+			return s.oidcDiscovery, nil
+		}
+		return nil, oidcError("discovery_failed", "failed to load OIDC discovery document: %v", err)
+	}
+	if doc.Issuer == "" || doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return nil, oidcError("discovery_failed", "OIDC discovery document is missing issuer or endpoints")
+	}
+	doc.fetchedAt = time.Now()
+	s.oidcDiscovery = doc
+	return doc, nil
+}
+
+// getOIDCAuthURL 生成 OIDC 授权 URL（授权码模式）
+func (s *OAuthService) getOIDCAuthURL(state string) (string, error) {
+	doc, err := s.discoverOIDC()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Add("client_id", s.config.OIDCClientID)
+	params.Add("redirect_uri", s.config.OIDCRedirectURL)
+	params.Add("response_type", "code")
+	params.Add("scope", s.config.OIDCScopes)
+	params.Add("state", state)
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// exchangeOIDCCode 在令牌端点用授权码换取访问令牌与 ID Token
+func (s *OAuthService) exchangeOIDCCode(code string) (*OAuthToken, error) {
+	doc, err := s.discoverOIDC()
+	if err != nil {
+		return nil, err
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", s.config.OIDCRedirectURL)
+	data.Set("client_id", s.config.OIDCClientID)
+	data.Set("client_secret", s.config.OIDCClientSecret)
+
+	req, err := http.NewRequest("POST", doc.TokenEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, oidcError("request_error", "failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, oidcError("network_error", "failed to exchange code: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, oidcError("read_error", "failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, oidcError("exchange_failed", "token exchange failed: %s", string(body))
+	}
+
+	var token OAuthToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, oidcError("parse_error", "failed to parse token response: %v", err)
+	}
+	if token.IDToken == "" {
+		return nil, oidcError("exchange_failed", "token response has no id_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+// parseOIDCIDToken 解析 ID Token 并校验 iss、aud 与 exp。ID Token 直接从令牌端点经 TLS 取得，
+// 按 OpenID Connect Core 3.1.3.7 可以用 TLS 校验代替签名校验
+func (s *OAuthService) parseOIDCIDToken(doc *oidcDiscovery, idToken string) (*oidcClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, oidcError("invalid_id_token", "malformed id_token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, oidcError("invalid_id_token", "malformed id_token payload")
+	}
+	claims := &oidcClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, oidcError("invalid_id_token", "failed to parse id_token: %v", err)
+	}
+	if claims.Issuer != doc.Issuer {
+		return nil, oidcError("invalid_id_token", "id_token issuer %q does not match %q", claims.Issuer, doc.Issuer)
+	}
+	if !claims.hasAudience(s.config.OIDCClientID) {
+		return nil, oidcError("invalid_id_token", "id_token was not issued for this client")
+	}
+	if claims.Subject == "" || time.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, oidcError("invalid_id_token", "id_token is expired or has no subject")
+	}
+	return claims, nil
+}
+
+// getOIDCUserInfo 从 ID Token 取得用户标识，并用 UserInfo 端点补全资料
+func (s *OAuthService) getOIDCUserInfo(token *OAuthToken) (*OAuthUserInfo, error) {
+	doc, err := s.discoverOIDC()
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.parseOIDCIDToken(doc, token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.UserinfoEndpoint != "" {
+		info := &oidcClaims{}
+		if err := oauthGetJSON(doc.UserinfoEndpoint, token.AccessToken, info); err != nil {
+			return nil, oidcError("userinfo_failed", "failed to get user info: %v", err)
+		}
+		// UserInfo 的 sub 必须与 ID Token 一致，防止令牌替换
+		if info.Subject != claims.Subject {
+			return nil, oidcError("userinfo_failed", "userinfo subject does not match id_token")
+		}
+		info.Issuer, info.Audience, info.ExpiresAt = claims.Issuer, claims.Audience, claims.ExpiresAt
+		claims = info
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: claims.Subject,
+		Email:          claims.Email,
+		Username:       username,
+		AvatarURL:      claims.Picture,
+		EmailVerified:  claims.Email != "" && (claims.emailVerified() || s.config.OIDCTrustEmail),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// QQ 互联登录。换取令牌后还需单独调用 /oauth2.0/me 获取 openid，用户信息接口需同时携带
+// access_token、应用 ID 与 openid；各接口加 fmt=json 返回 JSON，而不是默认的 JSONP 或表单编码
+const (
+	qqAuthorizeURL = "https://graph.qq.com/oauth2.0/authorize"
+	qqTokenURL     = "https://graph.qq.com/oauth2.0/token"
+	qqOpenIDURL    = "https://graph.qq.com/oauth2.0/me"
+	qqUserInfoURL  = "https://graph.qq.com/user/get_user_info"
+)
+
+// qqError QQ 互联 OAuth 接口的错误字段
+type qqError struct {
+	Error            int    `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e qqError) err(code string) error {
+	if e.Error == 0 {
+		return nil
+	}
+	return &OAuthError{
+		Code:     code,
+		Message:  fmt.Sprintf("qq error %d: %s", e.Error, e.ErrorDescription),
+		Provider: "qq",
+	}
+}
+
+// getQQAuthURL 生成 QQ 登录授权 URL
+func (s *OAuthService) getQQAuthURL(state string) (string, error) {
+	if s.config.QQAppID == "" {
+		return "", &OAuthError{
+			Code:     "config_error",
+			Message:  "QQ OAuth not configured",
+			Provider: "qq",
+		}
+	}
+
+	params := url.Values{}
+	params.Add("response_type", "code")
+	params.Add("client_id", s.config.QQAppID)
+	params.Add("redirect_uri", s.config.QQRedirectURL)
+	params.Add("state", state)
+	params.Add("scope", "get_user_info")
+
+	return qqAuthorizeURL + "?" + params.Encode(), nil
+}
+
+// exchangeQQCode 用 code 换取 access_token，再查询该令牌对应的 openid 与 unionid
+func (s *OAuthService) exchangeQQCode(code string) (*OAuthToken, error) {
+	params := url.Values{}
+	params.Set("grant_type", "authorization_code")
+	params.Set("client_id", s.config.QQAppID)
+	params.Set("client_secret", s.config.QQAppKey)
+	params.Set("code", code)
+	params.Set("redirect_uri", s.config.QQRedirectURL)
+	params.Set("fmt", "json")
+
+	var resp struct {
+		qqError
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"` // QQ 以字符串返回
+	}
+	if err := oauthGetJSON(qqTokenURL+"?"+params.Encode(), "", &resp); err != nil {
+		return nil, &OAuthError{
+			Code:     "network_error",
+			Message:  fmt.Sprintf("failed to exchange code: %v", err),
+			Provider: "qq",
+		}
+	}
+	if err := resp.qqError.err("exchange_failed"); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, &OAuthError{
+			Code:     "exchange_failed",
+			Message:  "token response has no access_token",
+			Provider: "qq",
+		}
+	}
+
+	token := &OAuthToken{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		TokenType:    "Bearer",
+	}
+	if seconds, err := resp.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.ExpiresIn = int(seconds)
+		token.ExpiresAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	me := url.Values{}
+	me.Set("access_token", token.AccessToken)
+	me.Set("unionid", "1")
+	me.Set("fmt", "json")
+	var ids struct {
+		qqError
+		OpenID  string `json:"openid"`
+		UnionID string `json:"unionid"`
+	}
+	if err := oauthGetJSON(qqOpenIDURL+"?"+me.Encode(), "", &ids); err != nil {
+		return nil, &OAuthError{
+			Code:     "network_error",
+			Message:  fmt.Sprintf("failed to get openid: %v", err),
+			Provider: "qq",
+		}
+	}
+	if err := ids.qqError.err("exchange_failed"); err != nil {
+		return nil, err
+	}
+	if ids.OpenID == "" {
+		return nil, &OAuthError{
+			Code:     "exchange_failed",
+			Message:  "openid response has no openid",
+			Provider: "qq",
+		}
+	}
+	token.OpenID = ids.OpenID
+	token.UnionID = ids.UnionID
+	return token, nil
+}
+
+// getQQUserInfo 获取 QQ 昵称与头像。QQ 不提供邮箱；与微信相同，优先以 unionid 作为用户标识
+func (s *OAuthService) getQQUserInfo(token *OAuthToken) (*OAuthUserInfo, error) {
+	params := url.Values{}
+	params.Set("access_token", token.AccessToken)
+	params.Set("oauth_consumer_key", s.config.QQAppID)
+	params.Set("openid", token.OpenID)
+
+	var info struct {
+		Ret          int    `json:"ret"`
+		Msg          string `json:"msg"`
+		Nickname     string `json:"nickname"`
+		FigureURLQQ2 string `json:"figureurl_qq_2"` // 100×100 头像，部分用户没有
+		FigureURLQQ1 string `json:"figureurl_qq_1"` // 40×40 头像
+	}
+	if err := oauthGetJSON(qqUserInfoURL+"?"+params.Encode(), "", &info); err != nil {
+		return nil, &OAuthError{
+			Code:     "network_error",
+			Message:  fmt.Sprintf("failed to get user info: %v", err),
+			Provider: "qq",
+		}
+	}
+	if info.Ret != 0 {
+		return nil, &OAuthError{
+			Code:     "userinfo_failed",
+			Message:  fmt.Sprintf("qq error %d: %s", info.Ret, info.Msg),
+			Provider: "qq",
+		}
+	}
+
+	userID := token.UnionID
+	if userID == "" {
+		userID = token.OpenID
+	}
+	avatar := info.FigureURLQQ2
+	if avatar == "" {
+		avatar = info.FigureURLQQ1
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: userID,
+		Username:       oauthNickname(info.Nickname),
+		AvatarURL:      avatar,
+	}, nil
+}
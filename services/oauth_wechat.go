@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// 微信开放平台「网站应用微信登录」，扫码授权后用 code 换取 access_token 与 openid。
+// 接口不是标准 OAuth 2.0：令牌接口为 GET 请求，出错时仍返回 HTTP 200 并携带 errcode
+const (
+	weChatAuthorizeURL = "https://open.weixin.qq.com/connect/qrconnect"
+	weChatTokenURL     = "https://api.weixin.qq.com/sns/oauth2/access_token"
+	weChatUserInfoURL  = "https://api.weixin.qq.com/sns/userinfo"
+)
+
+// weChatError 微信接口的错误字段，errcode 为 0 或缺省表示成功
+type weChatError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e weChatError) err(code string) error {
+	if e.ErrCode == 0 {
+		return nil
+	}
+	return &OAuthError{
+		Code:     code,
+		Message:  fmt.Sprintf("wechat error %d: %s", e.ErrCode, e.ErrMsg),
+		Provider: "wechat",
+	}
+}
+
+// getWeChatAuthURL 生成微信扫码登录 URL
+func (s *OAuthService) getWeChatAuthURL(state string) (string, error) {
+	if s.config.WeChatAppID == "" {
+		return "", &OAuthError{
+			Code:     "config_error",
+			Message:  "WeChat OAuth not configured",
+			Provider: "wechat",
+		}
+	}
+
+	params := url.Values{}
+	params.Add("appid", s.config.WeChatAppID)
+	params.Add("redirect_uri", s.config.WeChatRedirectURL)
+	params.Add("response_type", "code")
+	params.Add("scope", "snsapi_login")
+	params.Add("state", state)
+
+	// 微信要求在 URL 末尾附加 #wechat_redirect
+	return weChatAuthorizeURL + "?" + params.Encode() + "#wechat_redirect", nil
+}
+
+// exchangeWeChatCode 用 code 换取 access_token，响应中同时带有 openid（及绑定开放平台时的 unionid）
+func (s *OAuthService) exchangeWeChatCode(code string) (*OAuthToken, error) {
+	params := url.Values{}
+	params.Set("appid", s.config.WeChatAppID)
+	params.Set("secret", s.config.WeChatAppSecret)
+	params.Set("code", code)
+	params.Set("grant_type", "authorization_code")
+
+	var resp struct {
+		weChatError
+		OAuthToken
+	}
+	if err := oauthGetJSON(weChatTokenURL+"?"+params.Encode(), "", &resp); err != nil {
+		return nil, &OAuthError{
+			Code:     "network_error",
+			Message:  fmt.Sprintf("failed to exchange code: %v", err),
+			Provider: "wechat",
+		}
+	}
+	if err := resp.weChatError.err("exchange_failed"); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" || resp.OpenID == "" {
+		return nil, &OAuthError{
+			Code:     "exchange_failed",
+			Message:  "token response has no access_token or openid",
+			Provider: "wechat",
+		}
+	}
+
+	token := resp.OAuthToken
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+// getWeChatUserInfo 获取微信昵称与头像。微信不提供邮箱；优先以 unionid 作为用户标识，
+// 同一开放平台账号下的网站、App 与公众号登录会识别为同一用户
+func (s *OAuthService) getWeChatUserInfo(token *OAuthToken) (*OAuthUserInfo, error) {
+	params := url.Values{}
+	params.Set("access_token", token.AccessToken)
+	params.Set("openid", token.OpenID)
+	params.Set("lang", "zh_CN")
+
+	var info struct {
+		weChatError
+		OpenID     string `json:"openid"`
+		UnionID    string `json:"unionid"`
+		Nickname   string `json:"nickname"`
+		HeadImgURL string `json:"headimgurl"`
+	}
+	if err := oauthGetJSON(weChatUserInfoURL+"?"+params.Encode(), "", &info); err != nil {
+		return nil, &OAuthError{
+			Code:     "network_error",
+			Message:  fmt.Sprintf("failed to get user info: %v", err),
+			Provider: "wechat",
+		}
+	}
+	if err := info.weChatError.err("userinfo_failed"); err != nil {
+		return nil, err
+	}
+
+	userID := info.UnionID
+	if userID == "" {
+		userID = token.UnionID
+	}
+	if userID == "" {
+		userID = token.OpenID
+	}
+
+	return &OAuthUserInfo{
+		ProviderUserID: userID,
+		Username:       oauthNickname(info.Nickname),
+		AvatarURL:      info.HeadImgURL,
+	}, nil
+}
@@ -29,8 +29,8 @@ func NewOpenRouterService(cfg *config.Config) *OpenRouterService {
 	return &OpenRouterService{
 		config:  cfg,
 		client:  &http.Client{Timeout: 120 * time.Second},
-		apiKey:  "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",
-		baseURL: "https://openrouter.ai/api/v1",
+		apiKey:  cfg.Providers.OpenRouter.APIKey,
+		baseURL: cfg.Providers.OpenRouter.BaseURL,
 	}
 }
 
@@ -102,6 +102,10 @@ func GetOpenRouterFreeModels() []string {
 
 // ChatCompletion 调用 OpenRouter API
 func (s *OpenRouterService) ChatCompletion(ctx context.Context, request *models.ChatCompletionRequest) (<-chan interface{}, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("OpenRouter API key not configured (OPENROUTER_API_KEY)")
+	}
+
 	// 构建请求体
 	reqBody := map[string]interface{}{
 		"model":    request.Model,
@@ -237,12 +241,7 @@ func (s *OpenRouterService) handleStreamResponse(body io.Reader, respChan chan<-
 		logrus.WithError(err).Error("Error reading OpenRouter stream")
 	}
 
-	// 发送使用统计
-	respChan <- models.Usage{
-		PromptTokens:     100,
-		CompletionTokens: 50,
-		TotalTokens:      150,
-	}
+	// 流式响应不带用量统计，由处理器按 tokenizer 计算（utils.WithUsageFallback）
 }
 
 // handleNonStreamResponse 处理非流式响应
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// opsSummaryCheckInterval is how often the reporter checks whether today's summary is due
+const opsSummaryCheckInterval = 10 * time.Minute
+
+// OpsWebhookResult is the delivery outcome for one webhook
+type OpsWebhookResult struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// OpsSummaryReporter pushes the previous day's operations summary to the admin
+// configured Slack / Feishu / DingTalk webhooks once per day
+type OpsSummaryReporter struct {
+	client   *http.Client
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex // serializes scheduled and manual sends
+}
+
+var (
+	opsSummaryReporter     *OpsSummaryReporter
+	opsSummaryReporterOnce sync.Once
+)
+
+// InitOpsSummaryReporter creates the singleton reporter
+func InitOpsSummaryReporter() *OpsSummaryReporter {
+	opsSummaryReporterOnce.Do(func() {
+		opsSummaryReporter = &OpsSummaryReporter{
+			client:   &http.Client{Timeout: 10 * time.Second},
+			stopChan: make(chan struct{}),
+		}
+	})
+	return opsSummaryReporter
+}
+
+// GetOpsSummaryReporter returns the singleton reporter, initializing it if needed
+func GetOpsSummaryReporter() *OpsSummaryReporter {
+	return InitOpsSummaryReporter()
+}
+
+// Start begins checking whether the daily summary is due
+func (r *OpsSummaryReporter) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(opsSummaryCheckInterval)
+		defer ticker.Stop()
+		for {
+			r.checkAndSend(time.Now())
+			select {
+			case <-ticker.C:
+			case <-r.stopChan:
+				return
+			}
+		}
+	}()
+	logrus.Info("Ops summary reporter started")
+}
+
+// Stop stops the reporter
+func (r *OpsSummaryReporter) Stop() {
+	close(r.stopChan)
+	r.wg.Wait()
+}
+
+// checkAndSend sends yesterday's summary once the configured UTC hour has passed
+// and nothing has been sent for today yet
+func (r *OpsSummaryReporter) checkAndSend(now time.Time) {
+	cfg, err := database.GetOpsSummaryConfig()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load ops summary config")
+		return
+	}
+	if !cfg.Enabled || len(cfg.Webhooks) == 0 {
+		return
+	}
+
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if now.Hour() < cfg.Hour {
+		return
+	}
+	if cfg.LastSent != nil && !cfg.LastSent.UTC().Before(today) {
+		return
+	}
+
+	results, err := r.Send(today.AddDate(0, 0, -1))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to send daily ops summary")
+		return
+	}
+	for _, res := range results {
+		if !res.Success {
+			logrus.WithFields(logrus.Fields{
+				"webhook": res.Name,
+				"type":    res.Type,
+			}).Warn("Ops summary webhook delivery failed: " + res.Error)
+		}
+	}
+
+	// 无论单个渠道是否成功都记录发送时间，避免每 10 分钟重复推送
+	cfg.LastSent = &now
+	if err := database.SaveOpsSummaryConfig(cfg); err != nil {
+		logrus.WithError(err).Warn("Failed to record ops summary send time")
+	}
+}
+
+// Send builds the summary for the UTC day starting at day and delivers it to every
+// enabled webhook
+func (r *OpsSummaryReporter) Send(day time.Time) ([]OpsWebhookResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := database.GetOpsSummaryConfig()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	summary, err := database.GetOpsSummary(start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]OpsWebhookResult, 0, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		if !wh.Enabled {
+			continue
+		}
+		res := OpsWebhookResult{ID: wh.ID, Name: wh.Name, Type: wh.Type, Success: true}
+		if err := r.deliver(wh, summary); err != nil {
+			res.Success = false
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// RenderOpsSummary renders the summary as plain text lines
+func RenderOpsSummary(s *database.OpsSummary) []string {
+	successRate := 100.0
+	if s.TotalRequests > 0 {
+		successRate = float64(int64(s.TotalRequests)-s.FailedRequests) / float64(s.TotalRequests) * 100
+	}
+
+	lines := []string{
+		fmt.Sprintf("Curry2API 每日运营摘要 %s (UTC)", s.PeriodStart.Format("2006-01-02")),
+		fmt.Sprintf("请求数: %d（失败 %d，成功率 %.2f%%）", s.TotalRequests, s.FailedRequests, successRate),
+		fmt.Sprintf("Token 用量: %d", s.TotalTokens),
+		fmt.Sprintf("API 扣费收入: $%.4f", s.RevenueUSD),
+		fmt.Sprintf("活跃用户: %d，新增用户: %d", s.ActiveUsers, s.NewUsers),
+	}
+
+	if len(s.TopModels) > 0 {
+		lines = append(lines, "热门模型:")
+		for _, m := range s.TopModels {
+			lines = append(lines, fmt.Sprintf("- %s: %d 次请求，%d tokens", m.Model, m.RequestCount, m.TotalTokens))
+		}
+	}
+	if len(s.TopErrors) > 0 {
+		lines = append(lines, "主要错误:")
+		for _, e := range s.TopErrors {
+			message := e.Message
+			if message == "" {
+				message = http.StatusText(e.StatusCode)
+			}
+			lines = append(lines, fmt.Sprintf("- [%d] %s × %d", e.StatusCode, message, e.Count))
+		}
+	}
+	if len(s.FailingSessions) > 0 {
+		lines = append(lines, "异常 Session:")
+		for _, fs := range s.FailingSessions {
+			state := "有效"
+			if !fs.IsValid {
+				state = "已失效"
+			}
+			lines = append(lines, fmt.Sprintf("- %s: 失败 %d 次（%s）", fs.Email, fs.FailCount, state))
+		}
+	}
+	return lines
+}
+
+// deliver posts the summary to one webhook in the channel's message format
+func (r *OpsSummaryReporter) deliver(wh database.OpsSummaryWebhook, s *database.OpsSummary) error {
+	lines := RenderOpsSummary(s)
+	target := wh.URL
+	var payload map[string]interface{}
+
+	switch wh.Type {
+	case database.OpsWebhookSlack:
+		payload = map[string]interface{}{"text": strings.Join(lines, "\n")}
+	case database.OpsWebhookFeishu:
+		payload = map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": strings.Join(lines, "\n")},
+		}
+		if wh.Secret != "" {
+			// 飞书加签：以 timestamp + "\n" + secret 为密钥对空串做 HMAC-SHA256
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(ts+"\n"+wh.Secret))
+			payload["timestamp"] = ts
+			payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+	case database.OpsWebhookDingTalk:
+		payload = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": lines[0],
+				"text":  strings.Join(lines, "\n\n"),
+			},
+		}
+		if wh.Secret != "" {
+			// 钉钉加签：以 secret 为密钥对 timestamp + "\n" + secret 做 HMAC-SHA256，附加到 URL
+			ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(wh.Secret))
+			mac.Write([]byte(ts + "\n" + wh.Secret))
+			sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+			sep := "?"
+			if strings.Contains(target, "?") {
+				sep = "&"
+			}
+			target += sep + "timestamp=" + ts + "&sign=" + sign
+		}
+	default:
+		return fmt.Errorf("unsupported webhook type %q", wh.Type)
+	}
+
+	body, _ := json.Marshal(payload)
+	resp, err := r.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+
+	// 飞书和钉钉在 HTTP 200 中通过业务错误码返回失败
+	var result struct {
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.Unmarshal(respBody, &result) == nil {
+		if result.Code != nil && *result.Code != 0 {
+			return fmt.Errorf("webhook error %d: %s", *result.Code, result.Msg)
+		}
+		if result.ErrCode != nil && *result.ErrCode != 0 {
+			return fmt.Errorf("webhook error %d: %s", *result.ErrCode, result.ErrMsg)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package services
 
 import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // ModelPricing represents pricing information for a model
@@ -329,10 +335,85 @@ var pricingTable = map[string]ModelPricing{
 	},
 }
 
+// pricingMu guards pricingTable, which is refreshed from the model_pricing table
+var (
+	pricingMu        sync.RWMutex
+	pricingUpdatedAt time.Time
+)
+
+// LoadPricingFromDB seeds the model_pricing table with the built-in defaults
+// and loads the stored prices, so billing and public pricing share one source
+func LoadPricingFromDB() error {
+	pricingMu.RLock()
+	defaults := make([]*database.ModelPricingRecord, 0, len(pricingTable))
+	for _, p := range pricingTable {
+		defaults = append(defaults, &database.ModelPricingRecord{
+			Model:       p.Model,
+			Provider:    p.Provider,
+			InputPrice:  p.InputPrice,
+			OutputPrice: p.OutputPrice,
+		})
+	}
+	pricingMu.RUnlock()
+
+	if err := database.SeedModelPricing(defaults); err != nil {
+		return err
+	}
+
+	records, err := database.ListModelPricing()
+	if err != nil {
+		return err
+	}
+
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	for _, r := range records {
+		pricingTable[strings.ToLower(r.Model)] = ModelPricing{
+			Model:       r.Model,
+			Provider:    r.Provider,
+			InputPrice:  r.InputPrice,
+			OutputPrice: r.OutputPrice,
+		}
+		if r.UpdatedAt.After(pricingUpdatedAt) {
+			pricingUpdatedAt = r.UpdatedAt
+		}
+	}
+
+	logrus.Infof("Loaded %d model prices from database", len(records))
+	return nil
+}
+
+// UpdateModelPricing persists a model price and updates the in-memory table
+func UpdateModelPricing(pricing ModelPricing) error {
+	if err := database.UpsertModelPricing(&database.ModelPricingRecord{
+		Model:       pricing.Model,
+		Provider:    pricing.Provider,
+		InputPrice:  pricing.InputPrice,
+		OutputPrice: pricing.OutputPrice,
+	}); err != nil {
+		return err
+	}
+
+	pricingMu.Lock()
+	pricingTable[strings.ToLower(pricing.Model)] = pricing
+	pricingUpdatedAt = time.Now()
+	pricingMu.Unlock()
+	return nil
+}
+
+// GetPricingUpdatedAt returns the last time the pricing table changed
+func GetPricingUpdatedAt() time.Time {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	return pricingUpdatedAt
+}
+
 // GetModelPricing returns the pricing information for a given model
 // Returns nil if the model is not found in the pricing table
 func GetModelPricing(model string) *ModelPricing {
 	modelLower := strings.ToLower(model)
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
 	if pricing, exists := pricingTable[modelLower]; exists {
 		return &pricing
 	}
@@ -362,6 +443,8 @@ func CalculateCostWithPricing(promptTokens, completionTokens int, inputPrice, ou
 // GetAllPricing returns all pricing information
 func GetAllPricing() map[string]ModelPricing {
 	// Return a copy to prevent modification
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
 	result := make(map[string]ModelPricing, len(pricingTable))
 	for k, v := range pricingTable {
 		result[k] = v
@@ -373,6 +456,20 @@ func GetAllPricing() map[string]ModelPricing {
 // GetProviderFromModel determines the provider name from a model name
 // This is used for logging and usage tracking
 func GetProviderFromModel(model string) string {
+	// Admin-registered upstreams take precedence for the models they allow
+	if provider, ok := config.CustomProviderForModel(model); ok {
+		return provider
+	}
+	return builtinProviderFromModel(model)
+}
+
+// builtinProviderFromModel returns the built-in provider for a model, ignoring custom upstreams
+func builtinProviderFromModel(model string) string {
+	// OpenRouter free models use vendor-prefixed IDs (e.g. openai/gpt-oss-20b)
+	if config.IsOpenRouterFreeModel(model) {
+		return "openrouter"
+	}
+
 	modelLower := strings.ToLower(model)
 
 	// OpenAI models: gpt-*, o1*, o3*, o4*
@@ -398,6 +495,34 @@ func GetProviderFromModel(model string) string {
 		return "deepseek"
 	}
 
+	// Local Ollama models: ollama/*
+	if strings.HasPrefix(modelLower, config.OllamaModelPrefix) {
+		return "ollama"
+	}
+
 	// Default to cursor for unknown models
 	return "cursor"
 }
+
+// unbilledProviders lists providers whose usage is tracked but not deducted from
+// user balances (e.g. self-hosted Ollama models)
+var unbilledProviders = map[string]bool{}
+
+// SetProviderBilling enables or disables balance deduction for a provider's models
+func SetProviderBilling(provider string, enabled bool) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	if enabled {
+		delete(unbilledProviders, provider)
+	} else {
+		unbilledProviders[provider] = true
+	}
+}
+
+// IsBillableModel reports whether usage of model should be deducted from user balances
+func IsBillableModel(model string) bool {
+	provider := GetProviderFromModel(model)
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	return !unbilledProviders[provider]
+}
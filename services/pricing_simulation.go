@@ -0,0 +1,160 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PricingSimulationCost is the spend of a group under the current and the proposed prices
+type PricingSimulationCost struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CurrentCost      float64 `json:"current_cost"`
+	SimulatedCost    float64 `json:"simulated_cost"`
+	Delta            float64 `json:"delta"`
+	DeltaPercent     float64 `json:"delta_percent"`
+}
+
+// PricingSimulationModel is the simulated spend of one model, across all users or for one user
+type PricingSimulationModel struct {
+	Model string `json:"model"`
+	PricingSimulationCost
+}
+
+// PricingSimulationUser is the simulated spend of one user
+type PricingSimulationUser struct {
+	UserID   int64                    `json:"user_id"`
+	Username string                   `json:"username"`
+	Models   []PricingSimulationModel `json:"models"`
+	PricingSimulationCost
+}
+
+// PricingSimulation is the impact of proposed prices on past usage
+type PricingSimulation struct {
+	Days    int                      `json:"days"`
+	Since   time.Time                `json:"since"`
+	Prices  []ModelPricing           `json:"prices"`
+	Totals  PricingSimulationCost    `json:"totals"`
+	ByModel []PricingSimulationModel `json:"by_model"`
+	// ByUser is omitted when per-user usage data may not be shown
+	ByUser []PricingSimulationUser `json:"by_user,omitempty"`
+}
+
+// add accumulates the tokens and costs of one usage group
+func (c *PricingSimulationCost) add(usage database.ModelTokenUsage, current, simulated float64) {
+	c.Requests += usage.Requests
+	c.PromptTokens += usage.PromptTokens
+	c.CompletionTokens += usage.CompletionTokens
+	c.CurrentCost += current
+	c.SimulatedCost += simulated
+}
+
+// finish rounds the costs and computes the deltas
+func (c *PricingSimulationCost) finish() {
+	c.CurrentCost = roundCost(c.CurrentCost)
+	c.SimulatedCost = roundCost(c.SimulatedCost)
+	c.Delta = roundCost(c.SimulatedCost - c.CurrentCost)
+	if c.CurrentCost > 0 {
+		c.DeltaPercent = math.Round(c.Delta/c.CurrentCost*10000) / 100
+	}
+}
+
+// roundCost rounds a USD amount to six decimals, the precision of balance transactions
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1_000_000) / 1_000_000
+}
+
+// SimulatePricing recomputes the spend of the last days of successful usage under
+// the proposed prices, without changing the live pricing table. Models without a
+// proposed price keep their current price; models of unbilled providers cost nothing.
+// perUser controls whether the per-user breakdown is included
+func SimulatePricing(proposed []ModelPricing, days int, perUser bool) (*PricingSimulation, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	usage, err := database.GetModelTokenUsageSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	proposedByModel := make(map[string]ModelPricing, len(proposed))
+	for _, p := range proposed {
+		proposedByModel[strings.ToLower(p.Model)] = p
+	}
+
+	result := &PricingSimulation{Days: days, Since: since, Prices: proposed}
+	models := make(map[string]*PricingSimulationModel)
+	users := make(map[int64]*PricingSimulationUser)
+	for _, u := range usage {
+		var current, simulated float64
+		if IsBillableModel(u.Model) {
+			current = CalculateCost(u.Model, int(u.PromptTokens), int(u.CompletionTokens))
+			simulated = current
+			if p, ok := proposedByModel[strings.ToLower(u.Model)]; ok {
+				simulated = CalculateCostWithPricing(int(u.PromptTokens), int(u.CompletionTokens), p.InputPrice, p.OutputPrice)
+			}
+		}
+
+		result.Totals.add(u, current, simulated)
+
+		m, ok := models[u.Model]
+		if !ok {
+			m = &PricingSimulationModel{Model: u.Model}
+			models[u.Model] = m
+		}
+		m.add(u, current, simulated)
+
+		if !perUser {
+			continue
+		}
+		user, ok := users[u.UserID]
+		if !ok {
+			user = &PricingSimulationUser{UserID: u.UserID, Username: u.Username}
+			users[u.UserID] = user
+		}
+		user.add(u, current, simulated)
+		userModel := PricingSimulationModel{Model: u.Model}
+		userModel.add(u, current, simulated)
+		userModel.finish()
+		user.Models = append(user.Models, userModel)
+	}
+
+	result.Totals.finish()
+	result.ByModel = make([]PricingSimulationModel, 0, len(models))
+	for _, m := range models {
+		m.finish()
+		result.ByModel = append(result.ByModel, *m)
+	}
+	sort.Slice(result.ByModel, func(i, j int) bool {
+		a, b := math.Abs(result.ByModel[i].Delta), math.Abs(result.ByModel[j].Delta)
+		if a != b {
+			return a > b
+		}
+		return result.ByModel[i].Model < result.ByModel[j].Model
+	})
+
+	if perUser {
+		result.ByUser = make([]PricingSimulationUser, 0, len(users))
+		for _, user := range users {
+			user.finish()
+			sort.Slice(user.Models, func(i, j int) bool {
+				a, b := math.Abs(user.Models[i].Delta), math.Abs(user.Models[j].Delta)
+				if a != b {
+					return a > b
+				}
+				return user.Models[i].Model < user.Models[j].Model
+			})
+			result.ByUser = append(result.ByUser, *user)
+		}
+		sort.Slice(result.ByUser, func(i, j int) bool {
+			a, b := math.Abs(result.ByUser[i].Delta), math.Abs(result.ByUser[j].Delta)
+			if a != b {
+				return a > b
+			}
+			return result.ByUser[i].UserID < result.ByUser[j].UserID
+		})
+	}
+	return result, nil
+}
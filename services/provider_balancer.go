@@ -0,0 +1,334 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/services/providers"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Load balancing strategies used when several providers can serve the same model
+const (
+	BalancePriority     = "priority"      // Highest-priority available provider, the default
+	BalanceWeighted     = "weighted"      // Random pick proportional to the per-provider weights
+	BalanceLeastLatency = "least_latency" // Provider with the lowest average response latency
+	BalanceTTFTSLA      = "ttft_sla"      // Sticky provider chosen by rolling first-token latency and error rate
+)
+
+// latencyAlpha is the smoothing factor of the latency moving average
+const latencyAlpha = 0.3
+
+// ProviderTrafficStats is the traffic and latency of one provider since startup, and its
+// first-token latency and error rate over the rolling SLA window
+type ProviderTrafficStats struct {
+	Provider       string   `json:"provider"`
+	Weight         int      `json:"weight"`
+	Requests       int64    `json:"requests"`
+	AvgLatencyMs   float64  `json:"avg_latency_ms"`
+	LatencySamples int64    `json:"latency_samples"`
+	SLA            SLAStats `json:"sla"`
+}
+
+// ModelTrafficShare is the part of a model's traffic routed to one provider
+type ModelTrafficShare struct {
+	Provider string  `json:"provider"`
+	Requests int64   `json:"requests"`
+	Share    float64 `json:"share"`
+}
+
+// ModelTrafficStats is how the requests for one model were distributed
+type ModelTrafficStats struct {
+	Model     string              `json:"model"`
+	Requests  int64               `json:"requests"`
+	Providers []ModelTrafficShare `json:"providers"`
+}
+
+// ProviderBalancingStatus is the load balancing configuration and traffic metrics
+type ProviderBalancingStatus struct {
+	Strategy     string                 `json:"strategy"`
+	Weights      map[string]int         `json:"weights"`
+	TTFTTargetMs int                    `json:"ttft_target_ms"`
+	MaxErrorRate float64                `json:"max_error_rate"`
+	Since        time.Time              `json:"since"`
+	Providers    []ProviderTrafficStats `json:"providers"`
+	Models       []ModelTrafficStats    `json:"models"`
+	Routes       []SLARouteDecision     `json:"routes"` // Latest ttft_sla decision per model
+}
+
+// latencyStats is the moving average latency of one provider
+type latencyStats struct {
+	avg     float64 // Milliseconds
+	samples int64
+}
+
+// loadBalancer picks one of the providers able to serve a model and records how
+// traffic was distributed. Metrics are in-memory and reset on restart
+type loadBalancer struct {
+	mu       sync.Mutex
+	strategy string
+	weights  map[string]int
+	latency  map[string]*latencyStats
+	routed   map[string]map[string]int64 // Model -> provider -> requests
+	since    time.Time
+	rand     *rand.Rand
+
+	ttftTarget   time.Duration
+	maxErrorRate float64
+	windows      map[string]*callWindow // Provider -> recent calls for ttft_sla
+	routes       map[string]*slaRoute   // Model -> provider ttft_sla currently sticks to
+}
+
+func newLoadBalancer() *loadBalancer {
+	return &loadBalancer{
+		strategy: BalancePriority,
+		weights:  make(map[string]int),
+		latency:  make(map[string]*latencyStats),
+		routed:   make(map[string]map[string]int64),
+		since:    time.Now(),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		windows:  make(map[string]*callWindow),
+		routes:   make(map[string]*slaRoute),
+	}
+}
+
+// ValidateBalancingConfig checks the strategy name and weights of an admin update
+func ValidateBalancingConfig(cfg *database.ProviderBalancingConfig) error {
+	switch cfg.Strategy {
+	case BalancePriority, BalanceWeighted, BalanceLeastLatency, BalanceTTFTSLA:
+	default:
+		return fmt.Errorf("strategy must be one of %s, %s, %s, %s", BalancePriority, BalanceWeighted, BalanceLeastLatency, BalanceTTFTSLA)
+	}
+	if cfg.TTFTTargetMs < 0 {
+		return fmt.Errorf("ttft_target_ms must not be negative")
+	}
+	if cfg.MaxErrorRate < 0 || cfg.MaxErrorRate > 1 {
+		return fmt.Errorf("max_error_rate must be between 0 and 1")
+	}
+	for name, weight := range cfg.Weights {
+		if weight < 0 {
+			return fmt.Errorf("weight of %s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// configure replaces the strategy, weights and SLA targets; traffic metrics are kept
+func (b *loadBalancer) configure(cfg *database.ProviderBalancingConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.strategy = cfg.Strategy
+	if b.strategy == "" {
+		b.strategy = BalancePriority
+	}
+	b.weights = make(map[string]int, len(cfg.Weights))
+	for name, weight := range cfg.Weights {
+		b.weights[name] = weight
+	}
+	b.ttftTarget = time.Duration(cfg.TTFTTargetMs) * time.Millisecond
+	b.maxErrorRate = cfg.MaxErrorRate
+}
+
+// weightOf returns the configured weight of a provider, 1 when not configured. Caller holds mu
+func (b *loadBalancer) weightOf(name string) int {
+	if weight, ok := b.weights[name]; ok {
+		return weight
+	}
+	return 1
+}
+
+// pick chooses one of the candidate provider names, given in priority order, and
+// counts the request. It returns false when every candidate has weight 0
+func (b *loadBalancer) pick(model string, candidates []string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var chosen string
+	switch b.strategy {
+	case BalanceWeighted:
+		total := 0
+		for _, name := range candidates {
+			total += b.weightOf(name)
+		}
+		if total == 0 {
+			return "", false
+		}
+		n := b.rand.Intn(total)
+		for _, name := range candidates {
+			if n -= b.weightOf(name); n < 0 {
+				chosen = name
+				break
+			}
+		}
+	case BalanceTTFTSLA:
+		chosen = b.pickBySLA(model, candidates)
+	case BalanceLeastLatency:
+		// Providers without samples are tried first so every candidate gets measured
+		best := -1.0
+		for _, name := range candidates {
+			if b.weightOf(name) == 0 {
+				continue
+			}
+			stats := b.latency[name]
+			if stats == nil || stats.samples == 0 {
+				chosen = name
+				break
+			}
+			if best < 0 || stats.avg < best {
+				chosen, best = name, stats.avg
+			}
+		}
+	default:
+		for _, name := range candidates {
+			if b.weightOf(name) > 0 {
+				chosen = name
+				break
+			}
+		}
+	}
+	if chosen == "" {
+		return "", false
+	}
+
+	if b.routed[model] == nil {
+		b.routed[model] = make(map[string]int64)
+	}
+	b.routed[model][chosen]++
+	return chosen, true
+}
+
+// recordLatency adds a successful response latency to the provider's moving average
+func (b *loadBalancer) recordLatency(name string, latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.latency[name]
+	if stats == nil {
+		stats = &latencyStats{}
+		b.latency[name] = stats
+	}
+	if stats.samples == 0 {
+		stats.avg = ms
+	} else {
+		stats.avg = latencyAlpha*ms + (1-latencyAlpha)*stats.avg
+	}
+	stats.samples++
+}
+
+// status returns the configuration and traffic metrics, sorted by name
+func (b *loadBalancer) status() ProviderBalancingStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := ProviderBalancingStatus{
+		Strategy:     b.strategy,
+		Weights:      make(map[string]int, len(b.weights)),
+		TTFTTargetMs: int(b.ttftTarget / time.Millisecond),
+		MaxErrorRate: b.maxErrorRate,
+		Since:        b.since,
+		Providers:    []ProviderTrafficStats{},
+		Models:       []ModelTrafficStats{},
+		Routes:       []SLARouteDecision{},
+	}
+	for name, weight := range b.weights {
+		status.Weights[name] = weight
+	}
+
+	perProvider := make(map[string]*ProviderTrafficStats)
+	providerStats := func(name string) *ProviderTrafficStats {
+		if perProvider[name] == nil {
+			perProvider[name] = &ProviderTrafficStats{Provider: name, Weight: b.weightOf(name)}
+		}
+		return perProvider[name]
+	}
+
+	for model, counts := range b.routed {
+		stats := ModelTrafficStats{Model: model}
+		for name, n := range counts {
+			stats.Requests += n
+			providerStats(name).Requests += n
+		}
+		for name, n := range counts {
+			stats.Providers = append(stats.Providers, ModelTrafficShare{
+				Provider: name,
+				Requests: n,
+				Share:    float64(n) / float64(stats.Requests),
+			})
+		}
+		sort.Slice(stats.Providers, func(i, j int) bool {
+			return stats.Providers[i].Provider < stats.Providers[j].Provider
+		})
+		status.Models = append(status.Models, stats)
+	}
+	for name, latency := range b.latency {
+		stats := providerStats(name)
+		stats.AvgLatencyMs = latency.avg
+		stats.LatencySamples = latency.samples
+	}
+	now := time.Now()
+	for name := range b.windows {
+		providerStats(name).SLA = b.slaStats(name, now)
+	}
+	for model, route := range b.routes {
+		status.Routes = append(status.Routes, route.decision(model))
+	}
+
+	for _, stats := range perProvider {
+		status.Providers = append(status.Providers, *stats)
+	}
+	sort.Slice(status.Providers, func(i, j int) bool {
+		return status.Providers[i].Provider < status.Providers[j].Provider
+	})
+	sort.Slice(status.Models, func(i, j int) bool {
+		return status.Models[i].Model < status.Models[j].Model
+	})
+	sort.Slice(status.Routes, func(i, j int) bool {
+		return status.Routes[i].Model < status.Routes[j].Model
+	})
+	return status
+}
+
+// SetLoadBalancing applies the load balancing strategy and provider weights
+func (r *ProviderRouter) SetLoadBalancing(cfg *database.ProviderBalancingConfig) {
+	r.balancer.configure(cfg)
+}
+
+// GetLoadBalancingStatus returns the load balancing configuration and how traffic was distributed
+func (r *ProviderRouter) GetLoadBalancingStatus() ProviderBalancingStatus {
+	return r.balancer.status()
+}
+
+// directCandidates returns the names of the providers that can serve model directly,
+// in priority order: custom upstreams listing the model, then the model's built-in
+// provider when it is configured for direct routing. Caller holds r.mu
+func (r *ProviderRouter) directCandidates(model string, keyTags []string) []string {
+	candidates := append([]string(nil), r.modelUpstreams[model]...)
+	if name := builtinProviderFromModel(model); name != "cursor" && r.direct[name] && !r.custom[name] {
+		candidates = append(candidates, name)
+	}
+
+	available := candidates[:0]
+	for _, name := range candidates {
+		if provider, exists := r.providers[name]; exists && provider.IsAvailable() && r.allow(name, keyTags) {
+			available = append(available, name)
+		}
+	}
+	return available
+}
+
+// selectDirectProvider balances model across its direct candidates
+func (r *ProviderRouter) selectDirectProvider(model string, keyTags []string) (providers.ProviderClient, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	candidates := r.directCandidates(model, keyTags)
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	name, ok := r.balancer.pick(model, candidates)
+	if !ok {
+		return nil, false
+	}
+	return r.providers[name], true
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"Curry2API-go/utils"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,6 +10,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// providersLog logs upstream provider errors and health probes
+var providersLog = utils.SubsystemLogger(utils.LogSubsystemProviders)
+
 // Provider error types (Requirements: 10.1-10.5)
 var (
 	// ErrProviderNotAvailable indicates the provider is not configured or unavailable
@@ -191,7 +195,7 @@ func extractContextLengthMessage(responseBody string) string {
 // LogProviderError logs a provider error with structured fields
 // Requirements: 10.6
 func LogProviderError(err *ProviderError) {
-	logrus.WithFields(logrus.Fields{
+	providersLog.WithFields(logrus.Fields{
 		"request_id":   err.RequestID,
 		"provider":     err.Provider,
 		"model":        err.Model,
@@ -204,7 +208,7 @@ func LogProviderError(err *ProviderError) {
 // LogProviderErrorWithContext logs a provider error with additional context
 // Requirements: 10.6
 func LogProviderErrorWithContext(requestID, provider, model string, errorCode ProviderErrorCode, errorMessage string) {
-	logrus.WithFields(logrus.Fields{
+	providersLog.WithFields(logrus.Fields{
 		"request_id":    requestID,
 		"provider":      provider,
 		"model":         model,
@@ -293,6 +297,23 @@ func WrapError(err error, provider, model, requestID string) *ProviderError {
 	}
 }
 
+// HTTPStatus returns the status code to report to API clients. An upstream 401
+// means the server's provider key is wrong, so it is reported as 502, not 401.
+func (e *ProviderError) HTTPStatus() int {
+	switch e.Code {
+	case ErrorCodeRateLimited:
+		return http.StatusTooManyRequests
+	case ErrorCodeContextTooLong, ErrorCodeBadRequest:
+		return http.StatusBadRequest
+	case ErrorCodeTimeout:
+		return http.StatusGatewayTimeout
+	case ErrorCodeProviderNotAvailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
 // GetUserFriendlyMessage returns a user-friendly error message
 func (e *ProviderError) GetUserFriendlyMessage() string {
 	switch e.Code {
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"Curry2API-go/services/providers"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultFailoverKey is the chain used for models without their own chain
+const defaultFailoverKey = "*"
+
+// ParseFailoverChains parses per-model failover chains in the form
+// "gpt-4o=openai,azure,cursor;claude-3.5-sonnet=anthropic,cursor".
+// Malformed entries are logged and skipped
+func ParseFailoverChains(spec string) map[string][]string {
+	chains := make(map[string][]string)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, list, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			routerLog.WithField("entry", entry).Warn("Ignoring malformed provider failover chain")
+			continue
+		}
+
+		var names []string
+		seen := make(map[string]bool)
+		for _, name := range strings.Split(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			routerLog.WithField("model", model).Warn("Ignoring provider failover chain without providers")
+			continue
+		}
+		chains[model] = names
+	}
+	return chains
+}
+
+// GetFailoverChain returns the providers to try for model, in order. Providers that
+// are not configured, unavailable, whose circuit is open or that reached their spend cap are left out. Models
+// without a configured chain get the single provider chosen by GetProvider
+func (r *ProviderRouter) GetFailoverChain(model string) ([]providers.ProviderClient, error) {
+	names, ok := r.failover[model]
+	if !ok {
+		names, ok = r.failover[defaultFailoverKey]
+	}
+	if !ok {
+		provider, err := r.GetProvider(model)
+		if err != nil {
+			return nil, err
+		}
+		return []providers.ProviderClient{provider}, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	chain := make([]providers.ProviderClient, 0, len(names))
+	for _, name := range names {
+		provider, exists := r.providers[name]
+		if !exists || !provider.IsAvailable() || !r.allow(name, nil) {
+			continue
+		}
+		chain = append(chain, provider)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("PROVIDER_NOT_AVAILABLE: no provider in the failover chain for %s is available", model)
+	}
+	return chain, nil
+}
+
+// IsFailoverError reports whether a failed provider call should be retried on the
+// next provider of the chain: upstream 5xx, rate limits, timeouts and unreachable
+// providers. Request errors would fail the same way everywhere and are returned as-is
+func IsFailoverError(err error) bool {
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) {
+		providerErr = WrapError(err, "", "", "")
+	}
+	switch providerErr.Code {
+	case ErrorCodeProviderError, ErrorCodeRateLimited, ErrorCodeTimeout, ErrorCodeProviderNotAvailable:
+		return true
+	}
+	return false
+}
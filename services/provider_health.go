@@ -0,0 +1,320 @@
+package services
+
+import (
+	"Curry2API-go/models"
+	"Curry2API-go/services/providers"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Circuit breaker states reported by GetProviderHealth
+const (
+	CircuitClosed   = "closed"    // Provider is healthy and receives traffic
+	CircuitOpen     = "open"      // Provider failed repeatedly and is skipped until the cooldown ends
+	CircuitHalfOpen = "half_open" // Cooldown ended; traffic is let through again and the next failure reopens it
+)
+
+// probeTimeout bounds each health probe
+const probeTimeout = 15 * time.Second
+
+// ProviderHealthStatus is the circuit breaker state of one provider
+type ProviderHealthStatus struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastProbeAt         *time.Time `json:"last_probe_at,omitempty"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
+}
+
+// circuit holds the failure history of one provider
+type circuit struct {
+	failures    int
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+	lastProbe   time.Time
+	openUntil   time.Time
+}
+
+// circuitBreakers tracks consecutive failures per provider. A provider's circuit
+// opens after threshold consecutive failures and rejects traffic for cooldown;
+// after that it is half-open until a success closes it or a failure reopens it
+type circuitBreakers struct {
+	mu        sync.Mutex
+	threshold int // 0 disables the breaker
+	cooldown  time.Duration
+	circuits  map[string]*circuit
+}
+
+func newCircuitBreakers(threshold int, cooldown time.Duration) *circuitBreakers {
+	return &circuitBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// stateLocked derives the circuit state; the caller must hold b.mu
+func (b *circuitBreakers) stateLocked(c *circuit, now time.Time) string {
+	if c == nil || b.threshold <= 0 || c.failures < b.threshold {
+		return CircuitClosed
+	}
+	if now.Before(c.openUntil) {
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+// get returns the circuit for name, creating it if needed; the caller must hold b.mu
+func (b *circuitBreakers) get(name string) *circuit {
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+// State returns the current circuit state of a provider
+func (b *circuitBreakers) State(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked(b.circuits[name], time.Now())
+}
+
+// Allow reports whether traffic may be sent to the provider
+func (b *circuitBreakers) Allow(name string) bool {
+	return b.State(name) != CircuitOpen
+}
+
+// RecordSuccess closes the provider's circuit
+func (b *circuitBreakers) RecordSuccess(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.get(name)
+	now := time.Now()
+	if b.stateLocked(c, now) != CircuitClosed {
+		providersLog.WithField("provider", name).Info("Provider recovered, circuit closed")
+	}
+	c.failures = 0
+	c.lastSuccess = now
+	c.openUntil = time.Time{}
+}
+
+// RecordFailure counts a failure and opens the circuit once the threshold is reached
+func (b *circuitBreakers) RecordFailure(name string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.get(name)
+	now := time.Now()
+	prev := b.stateLocked(c, now)
+	c.failures++
+	c.lastFailure = now
+	c.lastError = err.Error()
+	if b.threshold <= 0 || c.failures < b.threshold {
+		return
+	}
+	c.openUntil = now.Add(b.cooldown)
+	if prev != CircuitOpen {
+		providersLog.WithFields(logrus.Fields{
+			"provider": name,
+			"failures": c.failures,
+			"cooldown": b.cooldown,
+		}).WithError(err).Warn("Provider marked unavailable, circuit opened")
+	}
+}
+
+// markProbed records when a provider was last probed
+func (b *circuitBreakers) markProbed(name string) {
+	b.mu.Lock()
+	b.get(name).lastProbe = time.Now()
+	b.mu.Unlock()
+}
+
+// Reset forgets a provider's history, e.g. after its configuration changed
+func (b *circuitBreakers) Reset(name string) {
+	b.mu.Lock()
+	delete(b.circuits, name)
+	b.mu.Unlock()
+}
+
+// Status returns the state of a provider's circuit
+func (b *circuitBreakers) Status(name string) ProviderHealthStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := ProviderHealthStatus{Provider: name, State: CircuitClosed}
+	c, ok := b.circuits[name]
+	if !ok {
+		return status
+	}
+	status.State = b.stateLocked(c, time.Now())
+	status.ConsecutiveFailures = c.failures
+	status.LastError = c.lastError
+	status.LastFailureAt = timePtr(c.lastFailure)
+	status.LastSuccessAt = timePtr(c.lastSuccess)
+	status.LastProbeAt = timePtr(c.lastProbe)
+	if status.State == CircuitOpen {
+		status.OpenUntil = timePtr(c.openUntil)
+	}
+	return status
+}
+
+// timePtr returns nil for the zero time
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// RecordProviderResult records the outcome of a provider call for the provider
+// metrics and feeds it into the provider's circuit breaker. The latency of a
+// successful call (0 when unknown) also feeds least-latency load balancing; for
+// streamed calls it is the time until the upstream started responding, which
+// ttft_sla balancing uses as the first-token latency
+func (r *ProviderRouter) RecordProviderResult(providerName string, err error, latency time.Duration) {
+	if r == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	call := providerCall{at: time.Now(), latency: latency}
+	if err != nil {
+		call.code = WrapError(err, providerName, "", "").Code
+	}
+	r.calls.record(providerName, call)
+	r.recordHealth(providerName, err, latency)
+}
+
+// recordHealth feeds a call or probe outcome into the provider's circuit breaker.
+// Client cancellations are ignored and request errors (400, context too long) prove
+// the upstream is reachable, so only outages, timeouts, auth and rate limit errors count.
+// Cursor has its own session health management and is not tracked
+func (r *ProviderRouter) recordHealth(providerName string, err error, latency time.Duration) {
+	if providerName == "cursor" {
+		return
+	}
+	if err == nil {
+		r.health.RecordSuccess(providerName)
+		r.balancer.recordCall(providerName, latency, false)
+		if latency > 0 {
+			r.balancer.recordLatency(providerName, latency)
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	switch WrapError(err, providerName, "", "").Code {
+	case ErrorCodeBadRequest, ErrorCodeContextTooLong:
+		r.health.RecordSuccess(providerName)
+	default:
+		r.health.RecordFailure(providerName, err)
+		r.balancer.recordCall(providerName, 0, true)
+	}
+}
+
+// GetProviderHealth returns the circuit breaker state of every configured provider except Cursor
+func (r *ProviderRouter) GetProviderHealth() []ProviderHealthStatus {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		if name != "cursor" {
+			names = append(names, name)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	statuses := make([]ProviderHealthStatus, 0, len(names))
+	for _, name := range names {
+		statuses = append(statuses, r.health.Status(name))
+	}
+	return statuses
+}
+
+// StartHealthProbe probes every configured provider each interval. Providers with a
+// cheap health endpoint are always probed; the others are probed with a one-token
+// completion only once their circuit is half-open, to confirm recovery without spending
+// tokens while healthy. A non-positive interval disables probing
+func (r *ProviderRouter) StartHealthProbe(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			r.probeProviders()
+		}
+	}()
+}
+
+// probeProviders runs one round of health probes concurrently
+func (r *ProviderRouter) probeProviders() {
+	r.mu.RLock()
+	targets := make(map[string]providers.ProviderClient, len(r.providers))
+	for name, provider := range r.providers {
+		if name != "cursor" && provider.IsAvailable() {
+			targets[name] = provider
+		}
+	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, provider := range targets {
+		checker, hasCheck := provider.(providers.HealthChecker)
+		if !hasCheck && r.health.State(name) != CircuitHalfOpen {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, provider providers.ProviderClient, checker providers.HealthChecker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+
+			var err error
+			if checker != nil {
+				err = checker.HealthCheck(ctx)
+			} else {
+				err = probeWithCompletion(ctx, provider)
+			}
+			r.health.markProbed(name)
+			if err != nil {
+				providersLog.WithField("provider", name).WithError(err).Debug("Provider health probe failed")
+			}
+			r.recordHealth(name, err, 0)
+		}(name, provider, checker)
+	}
+	wg.Wait()
+}
+
+// probeWithCompletion sends a one-token completion to the provider's first model
+func probeWithCompletion(ctx context.Context, provider providers.ProviderClient) error {
+	supported := provider.GetSupportedModels()
+	if len(supported) == 0 {
+		return nil // Nothing to probe with; live traffic decides
+	}
+	events, err := provider.ChatCompletion(ctx, &models.ChatRequest{
+		Model:     supported[0].ID,
+		Messages:  []models.Message{{Role: "user", Content: "ping"}},
+		MaxTokens: 1,
+		Stream:    true,
+	})
+	if err != nil {
+		return err
+	}
+	var streamErr error
+	for event := range events {
+		if event.Type == "error" && streamErr == nil {
+			streamErr = errors.New(event.Error)
+		}
+	}
+	return streamErr
+}
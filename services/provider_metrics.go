@@ -0,0 +1,133 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxProviderCallSamples caps the in-memory call history kept per provider
+const maxProviderCallSamples = 5000
+
+// ProviderCallMetrics summarizes the upstream calls made to one provider within a
+// window, as observed by the router. Latency is the time until the upstream answered
+type ProviderCallMetrics struct {
+	Provider     string           `json:"provider"`
+	Calls        int64            `json:"calls"`
+	Failures     int64            `json:"failures"`
+	ErrorRate    float64          `json:"error_rate"`
+	ErrorsByCode map[string]int64 `json:"errors_by_code"`
+	P50LatencyMs int64            `json:"p50_latency_ms"`
+	P95LatencyMs int64            `json:"p95_latency_ms"`
+}
+
+// ProviderMetrics combines the usage_records summary of a provider with the router's in-memory call counters
+type ProviderMetrics struct {
+	database.ProviderUsageMetrics
+	Upstream *ProviderCallMetrics `json:"upstream,omitempty"`
+}
+
+// providerCall is one upstream call outcome
+type providerCall struct {
+	at      time.Time
+	latency time.Duration
+	code    ProviderErrorCode // Empty on success
+}
+
+// providerCallLog keeps the most recent calls of each provider in a ring buffer
+type providerCallLog struct {
+	mu    sync.Mutex
+	calls map[string][]providerCall
+	next  map[string]int
+}
+
+func newProviderCallLog() *providerCallLog {
+	return &providerCallLog{
+		calls: make(map[string][]providerCall),
+		next:  make(map[string]int),
+	}
+}
+
+func (l *providerCallLog) record(provider string, call providerCall) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	calls := l.calls[provider]
+	if len(calls) < maxProviderCallSamples {
+		l.calls[provider] = append(calls, call)
+		return
+	}
+	calls[l.next[provider]] = call
+	l.next[provider] = (l.next[provider] + 1) % maxProviderCallSamples
+}
+
+// summary returns the metrics of the calls made since the given time, sorted by provider
+func (l *providerCallLog) summary(since time.Time) []*ProviderCallMetrics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := []*ProviderCallMetrics{}
+	for provider, calls := range l.calls {
+		m := &ProviderCallMetrics{Provider: provider, ErrorsByCode: map[string]int64{}}
+		var latencies []time.Duration
+		for _, call := range calls {
+			if call.at.Before(since) {
+				continue
+			}
+			m.Calls++
+			if call.code != "" {
+				m.Failures++
+				m.ErrorsByCode[string(call.code)]++
+				continue
+			}
+			if call.latency > 0 {
+				latencies = append(latencies, call.latency)
+			}
+		}
+		if m.Calls == 0 {
+			continue
+		}
+		m.ErrorRate = float64(m.Failures) / float64(m.Calls)
+		if len(latencies) > 0 {
+			m.P50LatencyMs = latencyPercentile(latencies, 50).Milliseconds()
+			m.P95LatencyMs = latencyPercentile(latencies, 95).Milliseconds()
+		}
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Provider < result[j].Provider })
+	return result
+}
+
+// GetProviderMetrics returns per-provider request counts, error rates, latency
+// percentiles and tokens served since the given time. Request and token totals come
+// from usage_records; upstream call counters come from the router's in-memory history,
+// which only covers calls since startup
+func (r *ProviderRouter) GetProviderMetrics(since time.Time) ([]*ProviderMetrics, error) {
+	usage, err := database.GetProviderUsageMetrics(since)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]*ProviderMetrics, 0, len(usage))
+	byProvider := make(map[string]*ProviderMetrics, len(usage))
+	for _, u := range usage {
+		m := &ProviderMetrics{ProviderUsageMetrics: *u}
+		metrics = append(metrics, m)
+		byProvider[u.Provider] = m
+	}
+	if r == nil {
+		return metrics, nil
+	}
+
+	for _, calls := range r.calls.summary(since) {
+		m := byProvider[calls.Provider]
+		if m == nil {
+			// Calls whose usage has not been flushed yet, or that failed before usage was recorded
+			m = &ProviderMetrics{ProviderUsageMetrics: database.ProviderUsageMetrics{Provider: calls.Provider}}
+			metrics = append(metrics, m)
+		}
+		m.Upstream = calls
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Provider < metrics[j].Provider })
+	return metrics, nil
+}
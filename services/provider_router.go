@@ -2,23 +2,59 @@ package services
 
 import (
 	"Curry2API-go/config"
+	"Curry2API-go/database"
 	"Curry2API-go/models"
 	"Curry2API-go/services/providers"
+	"Curry2API-go/utils"
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
+// routerLog logs provider selection, model syncs and custom upstream reloads
+var routerLog = utils.SubsystemLogger(utils.LogSubsystemRouter)
+
 // ProviderRouter routes model requests to the appropriate provider
 type ProviderRouter struct {
+	mu        sync.RWMutex // Guards providers, direct and custom, which change on custom upstream reloads
 	providers map[string]providers.ProviderClient
+	direct    map[string]bool // Providers preferred over Cursor for their own models
+	custom    map[string]bool // Names of admin-registered upstreams currently loaded
 	config    *config.Config
+	health    *circuitBreakers // Marks providers unavailable after consecutive failures
+	failover  map[string][]string // Model -> ordered provider names tried by the chat service
+	balancer  *loadBalancer       // Spreads a model's traffic across the direct providers able to serve it
+	calls     *providerCallLog    // Recent upstream call outcomes for the provider metrics
+
+	modelUpstreams map[string][]string // Model -> custom upstreams listing it, highest priority first
+
+	openRouter *providers.OpenRouterProvider
+	ollama     *providers.OllamaProvider
 }
 
 // NewProviderRouter creates a new provider router with the given configuration
 func NewProviderRouter(cfg *config.Config) *ProviderRouter {
 	router := &ProviderRouter{
 		providers: make(map[string]providers.ProviderClient),
+		direct:    make(map[string]bool),
+		custom:    make(map[string]bool),
 		config:    cfg,
+		health: newCircuitBreakers(
+			cfg.ProviderHealth.FailureThreshold,
+			time.Duration(cfg.ProviderHealth.Cooldown)*time.Second,
+		),
+		failover: ParseFailoverChains(cfg.Providers.FailoverChains),
+		balancer: newLoadBalancer(),
+		calls:    newProviderCallLog(),
+
+		modelUpstreams: make(map[string][]string),
 	}
 	
 	// Initialize providers based on available API keys
@@ -30,6 +66,7 @@ func NewProviderRouter(cfg *config.Config) *ProviderRouter {
 			cfg.Providers.OpenAI.BaseURL,
 		)
 		router.providers["openai"] = openaiProvider
+		router.direct["openai"] = cfg.Providers.OpenAI.Direct
 	}
 	
 	// Initialize Anthropic provider if API key is configured
@@ -39,14 +76,17 @@ func NewProviderRouter(cfg *config.Config) *ProviderRouter {
 			cfg.Providers.Anthropic.BaseURL,
 		)
 		router.providers["anthropic"] = anthropicProvider
+		router.direct["anthropic"] = cfg.Providers.Anthropic.Direct
 	}
 	
 	// Initialize Google provider if API key is configured
 	if cfg.Providers.Google.APIKey != "" {
-		googleProvider := providers.NewGoogleProvider(
+		googleProvider := providers.NewGoogleProviderWithBaseURL(
 			cfg.Providers.Google.APIKey,
+			cfg.Providers.Google.BaseURL,
 		)
 		router.providers["google"] = googleProvider
+		router.direct["google"] = cfg.Providers.Google.Direct
 	}
 	
 	// Initialize DeepSeek provider if API key is configured
@@ -58,92 +98,348 @@ func NewProviderRouter(cfg *config.Config) *ProviderRouter {
 		router.providers["deepseek"] = deepseekProvider
 	}
 	
+	// Initialize OpenRouter provider if API key is configured
+	// Cursor cannot serve the OpenRouter free models, so they are always routed directly
+	if cfg.Providers.OpenRouter.APIKey != "" {
+		router.openRouter = providers.NewOpenRouterProvider(
+			cfg.Providers.OpenRouter.APIKey,
+			cfg.Providers.OpenRouter.BaseURL,
+			GetOpenRouterFreeModelInfos(),
+		)
+		router.providers["openrouter"] = router.openRouter
+		router.direct["openrouter"] = true
+	}
+	
+	// Initialize Ollama provider if a base URL is configured
+	// Local models exist only on the Ollama server, so they are always routed directly
+	if cfg.Providers.Ollama.BaseURL != "" {
+		router.ollama = providers.NewOllamaProvider(
+			cfg.Providers.Ollama.BaseURL,
+			cfg.Providers.Ollama.APIKey,
+		)
+		router.providers["ollama"] = router.ollama
+		router.direct["ollama"] = true
+	}
+	
 	return router
 }
 
 // GetProvider returns the appropriate provider for the given model
-// Always uses Cursor provider as the primary provider for all models
-// This ensures consistent behavior using the CursorSession system
+// Providers configured for direct routing serve their own models; everything
+// else uses the Cursor provider so the CursorSession system stays the default
 func (r *ProviderRouter) GetProvider(model string) (providers.ProviderClient, error) {
-	// Always use Cursor provider as the primary provider
+	if provider, ok := r.GetDirectProvider(model, nil); ok {
+		return provider, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Use Cursor provider as the primary provider
 	// Cursor provider supports all models through the CursorSession system
 	if cursorProvider, exists := r.providers["cursor"]; exists && cursorProvider.IsAvailable() {
 		return cursorProvider, nil
 	}
 	
 	// If Cursor is not available, try to find an alternative provider based on model
-	modelLower := strings.ToLower(model)
-	
-	// Helper function to get provider
-	getProvider := func(providerName string) (providers.ProviderClient, error) {
-		if provider, exists := r.providers[providerName]; exists && provider.IsAvailable() {
-			return provider, nil
-		}
-		return nil, fmt.Errorf("PROVIDER_NOT_AVAILABLE: %s provider is not available", providerName)
+	providerName := GetProviderFromModel(model)
+	if providerName == "cursor" {
+		return nil, fmt.Errorf("PROVIDER_NOT_AVAILABLE: No provider available for model %s", model)
 	}
-	
-	// Route based on model name prefix as fallback
-	// OpenAI models: gpt-*, o1*, o3*, o4*
-	if strings.HasPrefix(modelLower, "gpt-") || 
-	   strings.HasPrefix(modelLower, "o1") || 
-	   strings.HasPrefix(modelLower, "o3") ||
-	   strings.HasPrefix(modelLower, "o4") {
-		return getProvider("openai")
+	if provider, exists := r.providers[providerName]; exists && provider.IsAvailable() && r.allow(providerName, nil) {
+		return provider, nil
 	}
-	
-	// Anthropic models: claude-*
-	if strings.HasPrefix(modelLower, "claude-") {
-		return getProvider("anthropic")
+	return nil, fmt.Errorf("PROVIDER_NOT_AVAILABLE: %s provider is not available", providerName)
+}
+
+// GetDirectProvider returns the native provider for model when its provider is
+// configured for direct routing (e.g. OPENAI_DIRECT=true), bypassing Cursor.
+// When several direct providers serve the model, the configured load balancing
+// strategy picks one. Providers whose circuit is open, or that reached their spend
+// cap and don't allowlist any of keyTags, are skipped so their requests fall back to Cursor
+func (r *ProviderRouter) GetDirectProvider(model string, keyTags []string) (providers.ProviderClient, bool) {
+	if r == nil {
+		return nil, false
 	}
-	
-	// Google models: gemini-*
-	if strings.HasPrefix(modelLower, "gemini-") {
-		return getProvider("google")
+	return r.selectDirectProvider(model, keyTags)
+}
+
+// GetRoutedProvider returns the provider a routing rule sent the request to, when it
+// is configured, available and may be routed to with keyTags
+func (r *ProviderRouter) GetRoutedProvider(name string, keyTags []string) (providers.ProviderClient, bool) {
+	if r == nil {
+		return nil, false
 	}
-	
-	// DeepSeek models: deepseek-*
-	if strings.HasPrefix(modelLower, "deepseek-") {
-		return getProvider("deepseek")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, exists := r.providers[name]
+	if !exists || !provider.IsAvailable() || !r.allow(name, keyTags) {
+		return nil, false
 	}
-	
-	return nil, fmt.Errorf("PROVIDER_NOT_AVAILABLE: No provider available for model %s", model)
+	return provider, true
 }
 
-// GetAvailableProviders returns list of configured providers
+// allow reports whether a request from a key with keyTags may be routed to the provider:
+// its circuit is not open, and it has not reached its monthly spend cap unless the cap
+// allowlists one of the tags
+func (r *ProviderRouter) allow(name string, keyTags []string) bool {
+	return !providerSpendBlocked(name, keyTags) && r.health.Allow(name)
+}
+
+// GetAvailableProviders returns list of configured providers whose circuit is not open
 func (r *ProviderRouter) GetAvailableProviders() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	available := make([]string, 0, len(r.providers))
 	for name, provider := range r.providers {
-		if provider.IsAvailable() {
+		if provider.IsAvailable() && r.health.Allow(name) {
 			available = append(available, name)
 		}
 	}
 	return available
 }
 
-// GetAllModels returns all available models from all providers
+// GetAllModels returns all available models from all providers; models of a
+// provider whose circuit is open are reported with is_available=false
 func (r *ProviderRouter) GetAllModels() []models.ModelInfo {
 	allModels := make([]models.ModelInfo, 0)
 	
-	for _, provider := range r.providers {
-		models := provider.GetSupportedModels()
-		allModels = append(allModels, models...)
+	r.mu.RLock()
+	for name, provider := range r.providers {
+		start := len(allModels)
+		allModels = append(allModels, provider.GetSupportedModels()...)
+		if !r.health.Allow(name) {
+			for i := start; i < len(allModels); i++ {
+				allModels[i].IsAvailable = false
+			}
+		}
 	}
+	r.mu.RUnlock()
 	
-	// 添加 OpenRouter 免费模型
-	openRouterModels := GetOpenRouterFreeModelInfos()
-	allModels = append(allModels, openRouterModels...)
+	// 未配置 OpenRouter 时仍展示内置的免费模型列表
+	if r.openRouter == nil {
+		allModels = append(allModels, GetOpenRouterFreeModelInfos()...)
+	}
 	
 	return allModels
 }
 
+// SyncOpenRouterModels refreshes the OpenRouter free model list and registers the
+// synced models as valid model names. It is a no-op when OpenRouter is not configured
+func (r *ProviderRouter) SyncOpenRouterModels(ctx context.Context) error {
+	if r.openRouter == nil {
+		return nil
+	}
+	ids, err := r.openRouter.SyncModels(ctx)
+	if err != nil {
+		return err
+	}
+	config.RegisterOpenRouterFreeModels(ids)
+	routerLog.WithField("count", len(ids)).Info("OpenRouter free model list synced")
+	return nil
+}
+
+// StartOpenRouterModelSync syncs the OpenRouter model list now and then every interval
+func (r *ProviderRouter) StartOpenRouterModelSync(interval time.Duration) {
+	if r.openRouter == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := r.SyncOpenRouterModels(ctx); err != nil {
+				routerLog.WithError(err).Warn("Failed to sync OpenRouter model list, keeping previous list")
+			}
+			cancel()
+			<-ticker.C
+		}
+	}()
+}
+
+// SyncOllamaModels refreshes the list of models installed on the Ollama server.
+// It is a no-op when Ollama is not configured
+func (r *ProviderRouter) SyncOllamaModels(ctx context.Context) error {
+	if r.ollama == nil {
+		return nil
+	}
+	ids, err := r.ollama.SyncModels(ctx)
+	if err != nil {
+		return err
+	}
+	routerLog.WithField("count", len(ids)).Info("Ollama model list synced")
+	return nil
+}
+
+// StartOllamaModelSync syncs the Ollama model list now and then every interval;
+// a non-positive interval syncs only once
+func (r *ProviderRouter) StartOllamaModelSync(interval time.Duration) {
+	if r.ollama == nil {
+		return
+	}
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := r.SyncOllamaModels(ctx); err != nil {
+				routerLog.WithError(err).Warn("Failed to sync Ollama model list, keeping previous list")
+			}
+			cancel()
+			if interval <= 0 {
+				return
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
 // RegisterProvider registers a provider with the router
 // This is used for testing and for adding providers after initialization
 func (r *ProviderRouter) RegisterProvider(name string, provider providers.ProviderClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.providers[name] = provider
 }
 
+// customProviderNamePattern 自定义上游名称，同时用作路由与用量统计中的提供商名称
+var customProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// reservedProviderNames 内置提供商名称，不可用于自定义上游
+var reservedProviderNames = map[string]bool{
+	"cursor": true, "openai": true, "anthropic": true, "google": true,
+	"deepseek": true, "openrouter": true, "ollama": true,
+}
+
+// ValidateCustomProviderName returns why name cannot be used for a custom upstream, or "" when it can
+func ValidateCustomProviderName(name string) string {
+	if !customProviderNamePattern.MatchString(name) {
+		return "name must be 1-32 lowercase letters, digits, '-' or '_'"
+	}
+	if reservedProviderNames[name] {
+		return "name is reserved for a built-in provider"
+	}
+	return ""
+}
+
+// lookupCustomProviderHost resolves a custom upstream host; replaceable in tests
+var lookupCustomProviderHost = net.DefaultResolver.LookupIP
+
+// ValidateCustomProviderHost returns why host cannot be used for a custom upstream, or "" when it can.
+// Loopback, private and link-local addresses are rejected so the gateway cannot be pointed at internal services;
+// hostnames are resolved and every address must be public
+func ValidateCustomProviderHost(ctx context.Context, host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "base_url must not point to a loopback, private or link-local address"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return "base_url must not point to a loopback, private or link-local address"
+		}
+		return ""
+	}
+	ips, err := lookupCustomProviderHost(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return "base_url host cannot be resolved"
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return "base_url must not point to a loopback, private or link-local address"
+		}
+	}
+	return ""
+}
+
+// customProviderValidationTimeout bounds the credential check made when a custom upstream is enabled
+const customProviderValidationTimeout = 15 * time.Second
+
+// ValidateCustomProviderCredentials calls the upstream with the given credentials and returns
+// the upstream's error when it is unreachable or rejects them
+func ValidateCustomProviderCredentials(ctx context.Context, name, baseURL, apiKey string, allowedModels []string) error {
+	ctx, cancel := context.WithTimeout(ctx, customProviderValidationTimeout)
+	defer cancel()
+	return providers.NewCustomProvider(name, baseURL, apiKey, allowedModels, 0).ValidateCredentials(ctx)
+}
+
+// ReloadCustomProviders replaces the admin-registered OpenAI-compatible upstreams
+// with the active ones stored in the database. Each allowlisted model is routed
+// directly to the upstreams that list it, balanced by SetLoadBalancing
+func (r *ProviderRouter) ReloadCustomProviders() error {
+	records, err := database.ListCustomProviders(true)
+	if err != nil {
+		return err
+	}
+
+	modelRoutes := make(map[string]string)
+	modelUpstreams := make(map[string][]string)
+	r.mu.Lock()
+	for name := range r.custom {
+		delete(r.providers, name)
+		delete(r.direct, name)
+	}
+	for name := range r.custom {
+		r.health.Reset(name) // Upstream settings may have changed, start with a clean history
+	}
+	r.custom = make(map[string]bool, len(records))
+	for _, rec := range records { // Ordered by priority, highest first
+		if _, builtin := r.providers[rec.Name]; builtin {
+			routerLog.WithField("provider", rec.Name).Warn("Custom provider name collides with a built-in provider, skipping")
+			continue
+		}
+		r.providers[rec.Name] = providers.NewCustomProvider(rec.Name, rec.BaseURL, rec.APIKey, rec.Models, rec.Priority)
+		r.direct[rec.Name] = true
+		r.custom[rec.Name] = true
+		for _, model := range rec.Models {
+			if _, taken := modelRoutes[model]; !taken {
+				modelRoutes[model] = rec.Name
+			}
+			modelUpstreams[model] = append(modelUpstreams[model], rec.Name)
+		}
+	}
+	r.modelUpstreams = modelUpstreams
+	r.mu.Unlock()
+
+	config.SetCustomProviderModels(modelRoutes)
+	routerLog.WithFields(logrus.Fields{
+		"providers": len(records),
+		"models":    len(modelRoutes),
+	}).Info("Custom providers loaded")
+	return nil
+}
+
 // NewCursorProvider creates a new Cursor provider instance
 // This is a wrapper function to avoid exposing the providers package directly
 func NewCursorProvider(cursorService providers.CursorServiceInterface) providers.ProviderClient {
 	return providers.NewCursorProvider(cursorService)
 }
+
+// StreamEventsToChunks adapts a provider event stream to the chunk stream consumed by
+// utils.StreamChatCompletion: content as string, usage as models.Usage, failures as error
+func StreamEventsToChunks(events <-chan models.StreamEvent) <-chan interface{} {
+	out := make(chan interface{}, 32)
+	go func() {
+		defer close(out)
+		failed := false
+		for event := range events {
+			if failed {
+				continue // drain so the provider goroutine can exit
+			}
+			switch event.Type {
+			case "content":
+				out <- event.Content
+			case "usage":
+				if event.Tokens != nil {
+					out <- models.Usage{
+						PromptTokens:     event.Tokens.PromptTokens,
+						CompletionTokens: event.Tokens.CompletionTokens,
+						TotalTokens:      event.Tokens.TotalTokens,
+					}
+				}
+			case "error":
+				out <- errors.New(event.Error)
+				failed = true
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ttft_sla balancing: each model sticks to one provider, chosen by the rolling p90
+// first-token latency and error rate of its candidates. Hysteresis keeps the choice
+// stable: a model only moves after staying on its provider for slaMinDwell, and only
+// to a provider that meets the SLA when the current one doesn't, or that is clearly faster
+const (
+	slaWindow            = 5 * time.Minute // Calls older than this are forgotten
+	slaWindowMaxCalls    = 500             // Calls kept per provider within the window
+	slaMinSamples        = 5               // Calls needed before a provider's metrics are trusted
+	slaMinDwell          = time.Minute     // Time a model stays on a provider before it may move
+	slaSwitchMargin      = 0.2             // A faster provider must beat the current score by this fraction
+	slaErrorPenalty      = 5.0             // Score multiplier per unit of error rate
+	slaExploreRate       = 0.05            // Share of requests sent to unmeasured providers
+	slaNoLatencyMs       = 60000.0         // Latency assumed for providers with only failed calls
+	slaLatencyPercentile = 90              // Percentile of first-token latency that is scored
+)
+
+// windowCall is one provider call remembered for ttft_sla balancing
+type windowCall struct {
+	at     time.Time
+	ttft   time.Duration // 0 when unknown or failed
+	failed bool
+}
+
+// callWindow holds a provider's calls within slaWindow, oldest first
+type callWindow struct {
+	calls []windowCall
+}
+
+// prune drops calls that left the window
+func (w *callWindow) prune(now time.Time) {
+	cutoff := now.Add(-slaWindow)
+	i := 0
+	for i < len(w.calls) && w.calls[i].at.Before(cutoff) {
+		i++
+	}
+	w.calls = w.calls[i:]
+}
+
+// SLAStats is a provider's first-token latency and error rate over the rolling window,
+// and how ttft_sla balancing scores it (lower is better)
+type SLAStats struct {
+	Samples   int     `json:"samples"`
+	TTFTP90Ms float64 `json:"ttft_p90_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	Score     float64 `json:"score"`
+	Measured  bool    `json:"measured"`  // Enough samples for the metrics to be used
+	MeetsSLA  bool    `json:"meets_sla"` // Within the configured latency and error rate targets
+}
+
+// SLARouteDecision explains which provider ttft_sla balancing routes a model to and why
+type SLARouteDecision struct {
+	Model        string    `json:"model"`
+	Provider     string    `json:"provider"`
+	Reason       string    `json:"reason"`
+	Since        time.Time `json:"since"`
+	Previous     string    `json:"previous,omitempty"`
+	Switches     int64     `json:"switches"`
+	Explorations int64     `json:"explorations"` // Requests sent to unmeasured providers to measure them
+}
+
+// slaRoute is the provider a model currently sticks to
+type slaRoute struct {
+	provider     string
+	reason       string
+	since        time.Time
+	previous     string
+	switches     int64
+	explorations int64
+}
+
+func (r *slaRoute) decision(model string) SLARouteDecision {
+	return SLARouteDecision{
+		Model:        model,
+		Provider:     r.provider,
+		Reason:       r.reason,
+		Since:        r.since,
+		Previous:     r.previous,
+		Switches:     r.switches,
+		Explorations: r.explorations,
+	}
+}
+
+// recordCall adds a call outcome to the provider's rolling window. ttft is 0 when unknown
+func (b *loadBalancer) recordCall(name string, ttft time.Duration, failed bool) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w := b.windows[name]
+	if w == nil {
+		w = &callWindow{}
+		b.windows[name] = w
+	}
+	w.prune(now)
+	if len(w.calls) >= slaWindowMaxCalls {
+		w.calls = w.calls[1:]
+	}
+	w.calls = append(w.calls, windowCall{at: now, ttft: ttft, failed: failed})
+}
+
+// slaStats computes a provider's rolling metrics. Caller holds mu
+func (b *loadBalancer) slaStats(name string, now time.Time) SLAStats {
+	var stats SLAStats
+	w := b.windows[name]
+	if w == nil {
+		return stats
+	}
+	w.prune(now)
+
+	var latencies []time.Duration
+	failures := 0
+	for _, call := range w.calls {
+		if call.failed {
+			failures++
+		} else if call.ttft > 0 {
+			latencies = append(latencies, call.ttft)
+		}
+	}
+	stats.Samples = len(w.calls)
+	if stats.Samples == 0 {
+		return stats
+	}
+	stats.ErrorRate = float64(failures) / float64(stats.Samples)
+	latencyMs := slaNoLatencyMs
+	if len(latencies) > 0 {
+		stats.TTFTP90Ms = float64(latencyPercentile(latencies, slaLatencyPercentile)) / float64(time.Millisecond)
+		latencyMs = stats.TTFTP90Ms
+	}
+	stats.Score = latencyMs * (1 + slaErrorPenalty*stats.ErrorRate)
+	stats.Measured = stats.Samples >= slaMinSamples
+	stats.MeetsSLA = stats.Measured && len(latencies) > 0 &&
+		(b.ttftTarget <= 0 || stats.TTFTP90Ms <= float64(b.ttftTarget/time.Millisecond)) &&
+		(b.maxErrorRate <= 0 || stats.ErrorRate <= b.maxErrorRate)
+	return stats
+}
+
+// slaBetter reports whether a ranks above b: providers meeting the SLA first, then by score
+func slaBetter(a, b SLAStats) bool {
+	if a.MeetsSLA != b.MeetsSLA {
+		return a.MeetsSLA
+	}
+	return a.Score < b.Score
+}
+
+// pickBySLA chooses the provider for model among candidates, given in priority order,
+// keeping the model on its current provider unless hysteresis allows a move. A small
+// share of requests goes to providers without enough samples so they get measured.
+// Caller holds mu
+func (b *loadBalancer) pickBySLA(model string, candidates []string) string {
+	now := time.Now()
+	stats := make(map[string]SLAStats, len(candidates))
+	var eligible, unmeasured []string
+	best := ""
+	for _, name := range candidates {
+		if b.weightOf(name) == 0 {
+			continue
+		}
+		s := b.slaStats(name, now)
+		stats[name] = s
+		eligible = append(eligible, name)
+		if !s.Measured {
+			unmeasured = append(unmeasured, name)
+		} else if best == "" || slaBetter(s, stats[best]) {
+			best = name
+		}
+	}
+	if len(eligible) == 0 {
+		return ""
+	}
+
+	route := b.routes[model]
+	current := ""
+	if route != nil {
+		for _, name := range eligible {
+			if name == route.provider {
+				current = name
+				break
+			}
+		}
+	}
+
+	switch {
+	case current == "":
+		next, reason := best, "lowest p90 first-token latency and error rate"
+		if next == "" {
+			next, reason = eligible[0], "no provider measured yet, using priority order"
+		}
+		if route != nil {
+			reason = fmt.Sprintf("%s unavailable; %s", route.provider, reason)
+		}
+		b.switchRoute(model, next, reason, now)
+	case best != "" && best != current && stats[current].Measured && now.Sub(route.since) >= slaMinDwell:
+		cur, cand := stats[current], stats[best]
+		if !cur.MeetsSLA && cand.MeetsSLA {
+			b.switchRoute(model, best, fmt.Sprintf(
+				"%s misses the SLA (p90 first token %.0fms, error rate %.1f%%); %s meets it (%.0fms, %.1f%%)",
+				current, cur.TTFTP90Ms, cur.ErrorRate*100, best, cand.TTFTP90Ms, cand.ErrorRate*100,
+			), now)
+		} else if cur.MeetsSLA == cand.MeetsSLA && cand.Score < cur.Score*(1-slaSwitchMargin) {
+			b.switchRoute(model, best, fmt.Sprintf(
+				"%s scores %.0f, more than %.0f%% better than %s at %.0f",
+				best, cand.Score, slaSwitchMargin*100, current, cur.Score,
+			), now)
+		}
+	}
+	route = b.routes[model]
+
+	if len(unmeasured) > 0 && b.rand.Float64() < slaExploreRate {
+		if name := unmeasured[b.rand.Intn(len(unmeasured))]; name != route.provider {
+			route.explorations++
+			return name
+		}
+	}
+	return route.provider
+}
+
+// switchRoute moves model to provider and logs why. Caller holds mu
+func (b *loadBalancer) switchRoute(model, provider, reason string, now time.Time) {
+	route := b.routes[model]
+	if route == nil {
+		route = &slaRoute{}
+		b.routes[model] = route
+	} else {
+		route.previous = route.provider
+		route.switches++
+	}
+	route.provider, route.reason, route.since = provider, reason, now
+	logrus.WithFields(logrus.Fields{
+		"model":    model,
+		"provider": provider,
+		"previous": route.previous,
+	}).Infof("ttft_sla routing: %s", reason)
+}
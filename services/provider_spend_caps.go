@@ -0,0 +1,318 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Provider spend cap webhook events
+const (
+	ProviderSpendEventWarning  = "provider_spend.warning"
+	ProviderSpendEventCapped   = "provider_spend.capped"
+	ProviderSpendEventReleased = "provider_spend.released"
+)
+
+// ProviderSpendWebhookPayload is POSTed to the configured webhook when a provider approaches
+// or reaches its monthly spend cap, and when routing to it resumes
+type ProviderSpendWebhookPayload struct {
+	Event        string    `json:"event"`
+	Provider     string    `json:"provider"`
+	Month        string    `json:"month"`
+	Spent        float64   `json:"spent"`
+	MonthlyLimit float64   `json:"monthly_limit"`
+	Percent      float64   `json:"percent"`
+	Message      string    `json:"message"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// ProviderSpendStatus is the latest evaluation of one provider's monthly spend cap
+type ProviderSpendStatus struct {
+	Provider     string    `json:"provider"`
+	Month        string    `json:"month"`
+	Spent        float64   `json:"spent"` // Estimated upstream spend this month in USD
+	MonthlyLimit float64   `json:"monthly_limit"`
+	Percent      float64   `json:"percent"`
+	Level        string    `json:"level,omitempty"` // warning or capped, empty when below the alert threshold
+	Blocked      bool      `json:"blocked"`         // Routing is disabled except for allowlisted keys
+	EvaluatedAt  time.Time `json:"evaluated_at"`
+
+	allowlist []string
+}
+
+// ProviderSpendMonitor estimates each capped provider's spend for the current month from
+// usage_records and model pricing, alerts as it approaches the cap and stops routing to the
+// provider once the cap is reached
+type ProviderSpendMonitor struct {
+	interval   time.Duration
+	webhookURL string
+	client     *http.Client
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+	evalMu     sync.Mutex // Serializes evaluations so each alert is sent once
+
+	mu     sync.RWMutex
+	status map[string]*ProviderSpendStatus
+}
+
+var (
+	providerSpendMonitor     *ProviderSpendMonitor
+	providerSpendMonitorOnce sync.Once
+)
+
+// InitProviderSpendMonitor creates the singleton monitor
+func InitProviderSpendMonitor(interval time.Duration, webhookURL string) *ProviderSpendMonitor {
+	providerSpendMonitorOnce.Do(func() {
+		if interval <= 0 {
+			interval = 5 * time.Minute
+		}
+		providerSpendMonitor = &ProviderSpendMonitor{
+			interval:   interval,
+			webhookURL: webhookURL,
+			client:     &http.Client{Timeout: 10 * time.Second},
+			stopChan:   make(chan struct{}),
+			status:     make(map[string]*ProviderSpendStatus),
+		}
+	})
+	return providerSpendMonitor
+}
+
+// GetProviderSpendMonitor returns the singleton monitor, or nil if it was not initialized
+func GetProviderSpendMonitor() *ProviderSpendMonitor {
+	return providerSpendMonitor
+}
+
+// Start evaluates the caps immediately and then every interval
+func (m *ProviderSpendMonitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.Evaluate()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Evaluate()
+			case <-m.stopChan:
+				return
+			}
+		}
+	}()
+	logrus.Infof("Provider spend monitor started (interval %s)", m.interval)
+}
+
+// Stop stops the monitor
+func (m *ProviderSpendMonitor) Stop() {
+	close(m.stopChan)
+	m.wg.Wait()
+}
+
+// Status returns the latest evaluation of every enabled cap, keyed by provider
+func (m *ProviderSpendMonitor) Status() map[string]*ProviderSpendStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]*ProviderSpendStatus, len(m.status))
+	for name, s := range m.status {
+		copied := *s
+		out[name] = &copied
+	}
+	return out
+}
+
+// Blocked reports whether routing to provider is disabled for a key with keyTags: the
+// provider reached its cap this month and the key carries none of the cap's allowlisted tags
+func (m *ProviderSpendMonitor) Blocked(provider string, keyTags []string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.status[provider]
+	if !ok || !s.Blocked {
+		return false
+	}
+	for _, allowed := range s.allowlist {
+		for _, tag := range keyTags {
+			if tag == allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidateProviderSpendCapName returns why a spend cap cannot be set for provider, or "" when it
+// can. Cursor is the fallback for every capped provider and cannot be capped itself
+func ValidateProviderSpendCapName(provider string) string {
+	if !customProviderNamePattern.MatchString(provider) {
+		return "provider must be 1-32 lowercase letters, digits, '-' or '_'"
+	}
+	if provider == "cursor" {
+		return "cursor is the fallback provider and cannot be capped"
+	}
+	return ""
+}
+
+// providerSpendBlocked reports whether the spend monitor, when running, keeps keyTags from provider
+func providerSpendBlocked(provider string, keyTags []string) bool {
+	return providerSpendMonitor != nil && providerSpendMonitor.Blocked(provider, keyTags)
+}
+
+// Evaluate recomputes this month's estimated spend of every capped provider. Alerts are sent
+// once per level per month: when spend reaches the alert percentage, when it reaches the cap
+// (routing stops) and when routing resumes because the cap was raised or disabled or a new
+// month began
+func (m *ProviderSpendMonitor) Evaluate() {
+	m.evalMu.Lock()
+	defer m.evalMu.Unlock()
+
+	caps, err := database.ListProviderSpendCaps()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load provider spend caps")
+		return
+	}
+
+	now := time.Now()
+	month := now.Format("2006-01")
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	spent, err := estimateProviderSpend(monthStart)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to estimate provider spend")
+		return
+	}
+
+	status := make(map[string]*ProviderSpendStatus, len(caps))
+	for _, spendCap := range caps {
+		s := &ProviderSpendStatus{
+			Provider:     spendCap.Provider,
+			Month:        month,
+			Spent:        spent[spendCap.Provider],
+			MonthlyLimit: spendCap.MonthlyLimit,
+			EvaluatedAt:  now,
+			allowlist:    spendCap.AllowlistTags,
+		}
+		if spendCap.MonthlyLimit > 0 {
+			s.Percent = s.Spent / spendCap.MonthlyLimit * 100
+		}
+		if spendCap.Enabled {
+			switch {
+			case s.Spent >= spendCap.MonthlyLimit:
+				s.Level = database.ProviderSpendCapped
+			case s.Percent >= float64(spendCap.AlertPercent):
+				s.Level = database.ProviderSpendWarning
+			}
+			s.Blocked = s.Level == database.ProviderSpendCapped
+			status[spendCap.Provider] = s
+		}
+
+		// Levels recorded for an earlier month no longer apply, but a provider capped last
+		// month stays blocked until this evaluation releases it
+		stored, previous := spendCap.AlertLevel, spendCap.AlertLevel
+		if spendCap.AlertMonth != month {
+			previous = ""
+		}
+		if s.Level == previous && (spendCap.AlertMonth == month || stored == "") {
+			continue
+		}
+		if stored == database.ProviderSpendCapped && s.Level != database.ProviderSpendCapped {
+			m.notify(database.ProviderSpendReleased, s)
+		}
+		if providerSpendLevelRank(s.Level) > providerSpendLevelRank(previous) {
+			m.notify(s.Level, s)
+		}
+		if err := database.SetProviderSpendAlertState(spendCap.Provider, month, s.Level); err != nil {
+			logrus.WithError(err).WithField("provider", spendCap.Provider).Warn("Failed to record provider spend alert state")
+		}
+	}
+
+	m.mu.Lock()
+	m.status = status
+	m.mu.Unlock()
+}
+
+// providerSpendLevelRank orders alert levels by severity
+func providerSpendLevelRank(level string) int {
+	switch level {
+	case database.ProviderSpendWarning:
+		return 1
+	case database.ProviderSpendCapped:
+		return 2
+	}
+	return 0
+}
+
+// estimateProviderSpend prices each provider's token usage since the given time with the
+// model pricing table
+func estimateProviderSpend(since time.Time) (map[string]float64, error) {
+	usage, err := database.GetProviderModelUsage(since)
+	if err != nil {
+		return nil, err
+	}
+	spent := make(map[string]float64)
+	for _, u := range usage {
+		spent[u.Provider] += CalculateCost(u.Model, int(u.PromptTokens), int(u.CompletionTokens))
+	}
+	return spent, nil
+}
+
+// notify records the alert for admins and calls the webhook
+func (m *ProviderSpendMonitor) notify(level string, s *ProviderSpendStatus) {
+	var event, message string
+	switch level {
+	case database.ProviderSpendWarning:
+		event = ProviderSpendEventWarning
+		message = fmt.Sprintf("%s has spent an estimated $%.2f of its $%.2f monthly cap (%.0f%%)",
+			s.Provider, s.Spent, s.MonthlyLimit, s.Percent)
+	case database.ProviderSpendCapped:
+		event = ProviderSpendEventCapped
+		message = fmt.Sprintf("%s reached its $%.2f monthly cap (estimated $%.2f spent); routing disabled except for allowlisted keys",
+			s.Provider, s.MonthlyLimit, s.Spent)
+	default:
+		event = ProviderSpendEventReleased
+		message = fmt.Sprintf("%s is below its monthly cap again (estimated $%.2f of $%.2f); routing resumed",
+			s.Provider, s.Spent, s.MonthlyLimit)
+	}
+
+	if err := database.CreateProviderSpendAlert(&database.ProviderSpendAlert{
+		Provider:     s.Provider,
+		Month:        s.Month,
+		Level:        level,
+		Spent:        s.Spent,
+		MonthlyLimit: s.MonthlyLimit,
+		Message:      message,
+	}); err != nil {
+		logrus.WithError(err).Warn("Failed to record provider spend alert")
+	}
+	if level == database.ProviderSpendReleased {
+		logrus.Info("Provider spend cap released: " + message)
+	} else {
+		logrus.Warn("Provider spend cap alert: " + message)
+	}
+
+	if m.webhookURL == "" {
+		return
+	}
+	body, _ := json.Marshal(ProviderSpendWebhookPayload{
+		Event:        event,
+		Provider:     s.Provider,
+		Month:        s.Month,
+		Spent:        s.Spent,
+		MonthlyLimit: s.MonthlyLimit,
+		Percent:      s.Percent,
+		Message:      message,
+		Timestamp:    time.Now(),
+	})
+	resp, err := m.client.Post(m.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to deliver provider spend webhook")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logrus.Warnf("Provider spend webhook returned HTTP %d", resp.StatusCode)
+	}
+}
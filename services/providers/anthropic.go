@@ -110,7 +110,7 @@ type AnthropicRequest struct {
 	MaxTokens   int                 `json:"max_tokens"`
 	Stream      bool                `json:"stream"`
 	System      string              `json:"system,omitempty"`
-	Temperature float64             `json:"temperature,omitempty"`
+	Temperature *float64            `json:"temperature,omitempty"`
 }
 
 // AnthropicStreamEvent represents different event types from Anthropic's streaming API
@@ -243,11 +243,9 @@ func (p *AnthropicProvider) ChatCompletion(ctx context.Context, req *models.Chat
 		System:    systemPrompt,
 	}
 
-	if req.Temperature > 0 {
-		requestBody.Temperature = req.Temperature
-	}
+	requestBody.Temperature = req.Temperature
 
-	jsonData, err := json.Marshal(requestBody)
+	jsonData, err := marshalWithExtra(requestBody, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -285,6 +283,39 @@ func (p *AnthropicProvider) ChatCompletion(ctx context.Context, req *models.Chat
 	return eventChan, nil
 }
 
+// Messages forwards a native Messages API request body. beta is passed through as the
+// anthropic-beta header when set. Non-200 responses are mapped like ChatCompletion errors.
+func (p *AnthropicProvider) Messages(ctx context.Context, body []byte, beta string) (*http.Response, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("Anthropic provider not available: API key not configured")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	if beta != "" {
+		httpReq.Header.Set("anthropic-beta", beta)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, p.handleErrorResponse(resp.StatusCode, respBody)
+	}
+
+	return resp, nil
+}
+
 // processStream processes the SSE stream from Anthropic
 func (p *AnthropicProvider) processStream(resp *http.Response, eventChan chan<- models.StreamEvent) {
 	defer close(eventChan)
@@ -1,6 +1,10 @@
 package providers
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
@@ -427,3 +431,43 @@ func TestAnthropicProvider_ProcessAnthropicEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestAnthropicProvider_Messages_Passthrough(t *testing.T) {
+	requestBody := `{"model":"claude-sonnet-4-5-20250929","max_tokens":1024,"messages":[{"role":"user","content":"Hi"}],"tools":[{"name":"get_weather","input_schema":{"type":"object"}}]}`
+	responseBody := `{"id":"msg_1","type":"message","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":2}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, "2023-06-01", r.Header.Get("anthropic-version"))
+		assert.Equal(t, "tools-2024-04-04", r.Header.Get("anthropic-beta"))
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, requestBody, string(body))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responseBody))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.URL)
+	resp, err := provider.Messages(context.Background(), []byte(requestBody), "tools-2024-04-04")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, responseBody, string(body))
+}
+
+func TestAnthropicProvider_Messages_ErrorMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.URL)
+	resp, err := provider.Messages(context.Background(), []byte(`{}`), "")
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "RATE_LIMITED")
+}
@@ -351,10 +351,7 @@ func (p *CursorProvider) ChatCompletion(ctx context.Context, req *models.ChatReq
 		cursorReq.MaxTokens = &maxTokens
 	}
 
-	if req.Temperature > 0 {
-		temperature := req.Temperature
-		cursorReq.Temperature = &temperature
-	}
+	cursorReq.Temperature = req.Temperature
 
 	// Call existing CursorService
 	cursorStreamChan, _, err := p.cursorService.ChatCompletion(ctx, cursorReq)
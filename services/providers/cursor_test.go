@@ -224,6 +224,7 @@ func TestCursorProvider_FormatConversion(t *testing.T) {
 	provider := NewCursorProvider(cursorService)
 
 	// Test ChatRequest to CursorService format conversion
+	temperature := 0.7
 	req := &models.ChatRequest{
 		Model: "claude-3.5-sonnet",
 		Messages: []models.Message{
@@ -232,7 +233,7 @@ func TestCursorProvider_FormatConversion(t *testing.T) {
 		},
 		Stream:      true,
 		MaxTokens:   1000,
-		Temperature: 0.7,
+		Temperature: &temperature,
 	}
 
 	// We can't easily test the actual conversion without mocking CursorService,
@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"Curry2API-go/models"
+)
+
+// CredentialError reports the upstream's rejection of a credential validation call
+type CredentialError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *CredentialError) Error() string {
+	return fmt.Sprintf("upstream returned HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// CustomProvider is an admin-registered OpenAI-compatible upstream. It reuses the
+// OpenAI wire format and serves only the models on its allowlist
+type CustomProvider struct {
+	*OpenAIProvider
+	name     string
+	models   []string
+	priority int
+}
+
+// NewCustomProvider creates a provider for an OpenAI-compatible upstream
+func NewCustomProvider(name, baseURL, apiKey string, allowedModels []string, priority int) *CustomProvider {
+	return &CustomProvider{
+		OpenAIProvider: NewOpenAIProvider(apiKey, baseURL),
+		name:           name,
+		models:         allowedModels,
+		priority:       priority,
+	}
+}
+
+// GetProviderName returns the admin-assigned provider name
+func (p *CustomProvider) GetProviderName() string {
+	return p.name
+}
+
+// Priority returns the routing priority; higher wins when upstreams share a model
+func (p *CustomProvider) Priority() int {
+	return p.priority
+}
+
+// GetSupportedModels returns the allowlisted models
+func (p *CustomProvider) GetSupportedModels() []models.ModelInfo {
+	isAvailable := p.IsAvailable()
+	result := make([]models.ModelInfo, 0, len(p.models))
+	for _, id := range p.models {
+		result = append(result, models.ModelInfo{
+			ID:          id,
+			Name:        id,
+			Provider:    p.name,
+			IsAvailable: isAvailable,
+		})
+	}
+	return result
+}
+
+// ValidateCredentials checks that the upstream is reachable and accepts the API key by
+// listing models. Upstreams without a model list (404/405) are checked with a one-token
+// completion on the first allowlisted model instead. Rejections return a *CredentialError
+// carrying the upstream's status and error message
+func (p *CustomProvider) ValidateCredentials(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	statusCode, body, err := p.doValidation(httpReq)
+	if err != nil {
+		return err
+	}
+	if statusCode == http.StatusOK {
+		return nil
+	}
+	if (statusCode != http.StatusNotFound && statusCode != http.StatusMethodNotAllowed) || len(p.models) == 0 {
+		return newCredentialError(statusCode, body)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"model":      p.models[0],
+		"messages":   []models.Message{{Role: "user", Content: "ping"}},
+		"max_tokens": 1,
+	})
+	httpReq, err = http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	statusCode, body, err = p.doValidation(httpReq)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return newCredentialError(statusCode, body)
+	}
+	return nil
+}
+
+// doValidation sends an authenticated validation request and returns the status and body
+func (p *CustomProvider) doValidation(httpReq *http.Request) (int, []byte, error) {
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reach upstream: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, body, nil
+}
+
+// newCredentialError extracts the upstream error message, falling back to the raw body
+func newCredentialError(statusCode int, body []byte) *CredentialError {
+	var errorResp models.ErrorResponse
+	message := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+		message = errorResp.Error.Message
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return &CredentialError{StatusCode: statusCode, Message: message}
+}
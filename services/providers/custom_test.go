@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Curry2API-go/models"
+)
+
+func TestCustomProvider_Identity(t *testing.T) {
+	provider := NewCustomProvider("acme", "https://llm.example.com/v1", "key", []string{"acme-large", "gpt-4o"}, 5)
+
+	if provider.GetProviderName() != "acme" {
+		t.Errorf("GetProviderName() = %q, want acme", provider.GetProviderName())
+	}
+	if provider.Priority() != 5 {
+		t.Errorf("Priority() = %d, want 5", provider.Priority())
+	}
+	supported := provider.GetSupportedModels()
+	if len(supported) != 2 || supported[0].ID != "acme-large" || supported[0].Provider != "acme" || !supported[0].IsAvailable {
+		t.Errorf("Unexpected supported models: %+v", supported)
+	}
+}
+
+func TestCustomProvider_ChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Expected /v1/chat/completions, got %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer upstream-key" {
+			t.Errorf("Expected upstream key, got %q", auth)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "acme-large" {
+			t.Errorf("Expected model acme-large, got %v", body["model"])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewCustomProvider("acme", server.URL+"/v1", "upstream-key", []string{"acme-large"}, 0)
+	events, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "acme-large",
+		Messages: []models.Message{{Role: "user", Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var content string
+	for event := range events {
+		if event.Type == "content" {
+			content += event.Content
+		}
+	}
+	if content != "ok" {
+		t.Errorf("content = %q, want ok", content)
+	}
+}
+
+func TestCustomProvider_ValidateCredentials(t *testing.T) {
+	t.Run("rejected key returns upstream error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/models" {
+				t.Errorf("Expected /v1/models, got %s", r.URL.Path)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error"}}`))
+		}))
+		defer server.Close()
+
+		provider := NewCustomProvider("acme", server.URL+"/v1", "bad-key", []string{"acme-large"}, 0)
+		err := provider.ValidateCredentials(context.Background())
+		credErr, ok := err.(*CredentialError)
+		if !ok {
+			t.Fatalf("ValidateCredentials() error = %v, want *CredentialError", err)
+		}
+		if credErr.StatusCode != http.StatusUnauthorized || credErr.Message != "Incorrect API key provided" {
+			t.Errorf("Unexpected credential error: %+v", credErr)
+		}
+	})
+
+	t.Run("falls back to completion without model list", func(t *testing.T) {
+		var completed bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/models":
+				w.WriteHeader(http.StatusNotFound)
+			case "/v1/chat/completions":
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				if body["model"] != "acme-large" || body["max_tokens"] != float64(1) {
+					t.Errorf("Unexpected validation completion body: %v", body)
+				}
+				completed = true
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"p"}}]}`))
+			}
+		}))
+		defer server.Close()
+
+		provider := NewCustomProvider("acme", server.URL+"/v1", "upstream-key", []string{"acme-large"}, 0)
+		if err := provider.ValidateCredentials(context.Background()); err != nil {
+			t.Fatalf("ValidateCredentials() error = %v", err)
+		}
+		if !completed {
+			t.Error("Expected a validation completion request")
+		}
+	})
+}
@@ -95,11 +95,11 @@ func (p *DeepSeekProvider) ChatCompletion(ctx context.Context, req *models.ChatR
 	if req.MaxTokens > 0 {
 		requestBody["max_tokens"] = req.MaxTokens
 	}
-	if req.Temperature > 0 {
-		requestBody["temperature"] = req.Temperature
+	if req.Temperature != nil {
+		requestBody["temperature"] = *req.Temperature
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	jsonData, err := marshalWithExtra(requestBody, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -136,6 +136,29 @@ func (p *DeepSeekProvider) ChatCompletion(ctx context.Context, req *models.ChatR
 	return eventChan, nil
 }
 
+// HealthCheck lists models, which needs a valid API key but costs no tokens
+func (p *DeepSeekProvider) HealthCheck(ctx context.Context) error {
+	if !p.IsAvailable() {
+		return fmt.Errorf("DeepSeek provider not available: API key not configured")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return p.handleErrorResponse(resp.StatusCode, body)
+	}
+	return nil
+}
+
 // processStream processes the SSE stream from DeepSeek
 func (p *DeepSeekProvider) processStream(resp *http.Response, eventChan chan<- models.StreamEvent) {
 	defer close(eventChan)
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// extraBodyAllowlist lists the vendor parameters each provider accepts from a
+// client's extra_body. Fields the gateway sets itself (model, messages, stream,
+// max_tokens...) are never overridden, even when listed
+var extraBodyAllowlist = map[string]map[string]bool{
+	"openai": paramSet(
+		"frequency_penalty", "presence_penalty", "top_p", "stop", "seed", "user",
+		"logit_bias", "logprobs", "top_logprobs", "response_format", "reasoning_effort",
+		"service_tier", "metadata", "store", "prediction", "verbosity",
+	),
+	"deepseek": paramSet(
+		"frequency_penalty", "presence_penalty", "top_p", "stop", "logprobs",
+		"top_logprobs", "response_format", "user",
+	),
+	"openrouter": paramSet(
+		"provider", "transforms", "route", "models", "reasoning", "user",
+		"top_p", "top_k", "min_p", "top_a", "frequency_penalty", "presence_penalty",
+		"repetition_penalty", "seed", "stop", "response_format", "logit_bias",
+	),
+	"anthropic": paramSet("metadata", "top_k", "top_p", "stop_sequences", "thinking", "service_tier"),
+	"google":    paramSet("safetySettings", "cachedContent"),
+	"ollama": paramSet(
+		"frequency_penalty", "presence_penalty", "top_p", "stop", "seed", "response_format",
+	),
+}
+
+// customExtraBodyParams applies to admin-registered OpenAI-compatible upstreams,
+// which are commonly vLLM/TGI style servers with extra sampling options
+var customExtraBodyParams = paramSet(
+	"frequency_penalty", "presence_penalty", "top_p", "top_k", "min_p", "stop", "seed",
+	"user", "logit_bias", "logprobs", "top_logprobs", "response_format",
+	"repetition_penalty", "chat_template_kwargs",
+)
+
+func paramSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// DisallowedExtraParams returns the extra_body keys the provider does not accept,
+// sorted. Providers without a built-in allowlist are treated as custom upstreams
+func DisallowedExtraParams(provider string, extra map[string]interface{}) []string {
+	allowed, ok := extraBodyAllowlist[provider]
+	if !ok {
+		allowed = customExtraBodyParams
+	}
+	var disallowed []string
+	for key := range extra {
+		if !allowed[key] {
+			disallowed = append(disallowed, key)
+		}
+	}
+	sort.Strings(disallowed)
+	return disallowed
+}
+
+// marshalWithExtra marshals the request body and adds the extra_body parameters
+// that the body does not already set
+func marshalWithExtra(body interface{}, extra map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	merged := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, exists := merged[key]; exists {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = raw
+	}
+	return json.Marshal(merged)
+}
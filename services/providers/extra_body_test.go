@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"Curry2API-go/models"
+)
+
+func TestDisallowedExtraParams(t *testing.T) {
+	extra := map[string]interface{}{
+		"provider":  map[string]interface{}{"order": []string{"Together"}},
+		"reasoning": map[string]interface{}{"effort": "low"},
+		"api_key":   "x",
+	}
+	if got := DisallowedExtraParams("openrouter", extra); !reflect.DeepEqual(got, []string{"api_key"}) {
+		t.Errorf("Expected [api_key], got %v", got)
+	}
+	if got := DisallowedExtraParams("anthropic", map[string]interface{}{"metadata": nil}); len(got) != 0 {
+		t.Errorf("Expected metadata to be allowed for anthropic, got %v", got)
+	}
+	// Unknown names are custom upstreams
+	if got := DisallowedExtraParams("my-vllm", map[string]interface{}{"top_k": 20, "provider": nil}); !reflect.DeepEqual(got, []string{"provider"}) {
+		t.Errorf("Expected [provider], got %v", got)
+	}
+}
+
+func TestOpenAIProvider_ExtraBodyPassthrough(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("test-key", server.URL)
+	events, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []models.Message{{Role: "user", Content: "hi"}},
+		ExtraBody: map[string]interface{}{
+			"seed":  float64(7),
+			"model": "gpt-4", // Gateway-set fields are never overridden
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for range events {
+	}
+
+	if body["seed"] != float64(7) {
+		t.Errorf("Expected seed to be passed through, got %v", body["seed"])
+	}
+	if body["model"] != "gpt-4o" {
+		t.Errorf("Expected model to stay gpt-4o, got %v", body["model"])
+	}
+}
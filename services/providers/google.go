@@ -14,16 +14,27 @@ import (
 	"Curry2API-go/models"
 )
 
-// GoogleProvider implements the ProviderClient interface for Google AI
+// GoogleProvider implements the ProviderClient interface for Google AI (Gemini)
 type GoogleProvider struct {
-	apiKey string
-	client *http.Client
+	apiKey  string
+	baseURL string
+	client  *http.Client
 }
 
 // NewGoogleProvider creates a new Google AI provider instance
 func NewGoogleProvider(apiKey string) *GoogleProvider {
+	return NewGoogleProviderWithBaseURL(apiKey, "")
+}
+
+// NewGoogleProviderWithBaseURL creates a Google AI provider that talks to baseURL
+// (e.g. a proxy or a test server) instead of the public Generative Language API
+func NewGoogleProviderWithBaseURL(apiKey, baseURL string) *GoogleProvider {
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
 	return &GoogleProvider{
-		apiKey: apiKey,
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
 		client: &http.Client{
 			Timeout: 120 * time.Second,
 		},
@@ -44,6 +55,33 @@ func (p *GoogleProvider) GetProviderName() string {
 func (p *GoogleProvider) GetSupportedModels() []models.ModelInfo {
 	isAvailable := p.IsAvailable()
 	return []models.ModelInfo{
+		{
+			ID:            "gemini-3-pro-preview",
+			Name:          "Gemini 3 Pro Preview",
+			Provider:      "google",
+			ContextWindow: 1048576,
+			InputPrice:    1.25,
+			OutputPrice:   5.00,
+			IsAvailable:   isAvailable,
+		},
+		{
+			ID:            "gemini-2.5-pro",
+			Name:          "Gemini 2.5 Pro",
+			Provider:      "google",
+			ContextWindow: 1048576,
+			InputPrice:    1.25,
+			OutputPrice:   5.00,
+			IsAvailable:   isAvailable,
+		},
+		{
+			ID:            "gemini-2.5-flash",
+			Name:          "Gemini 2.5 Flash",
+			Provider:      "google",
+			ContextWindow: 1048576,
+			InputPrice:    0.075,
+			OutputPrice:   0.30,
+			IsAvailable:   isAvailable,
+		},
 		{
 			ID:            "gemini-1.5-pro",
 			Name:          "Gemini 1.5 Pro",
@@ -76,31 +114,40 @@ func (p *GoogleProvider) GetSupportedModels() []models.ModelInfo {
 
 // GoogleContent represents a content part in Google's format
 type GoogleContent struct {
-	Role  string        `json:"role"`
+	Role  string        `json:"role,omitempty"`
 	Parts []GooglePart  `json:"parts"`
 }
 
 // GooglePart represents a part of the content
 type GooglePart struct {
-	Text string `json:"text"`
+	Text    string `json:"text"`
+	Thought bool   `json:"thought,omitempty"` // Thinking summary, not part of the answer
 }
 
 // GoogleRequest represents the request body for Google AI API
 type GoogleRequest struct {
-	Contents         []GoogleContent           `json:"contents"`
-	GenerationConfig *GoogleGenerationConfig   `json:"generationConfig,omitempty"`
+	Contents          []GoogleContent           `json:"contents"`
+	SystemInstruction *GoogleContent            `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GoogleGenerationConfig   `json:"generationConfig,omitempty"`
 }
 
 // GoogleGenerationConfig represents generation configuration
 type GoogleGenerationConfig struct {
-	Temperature  float64 `json:"temperature,omitempty"`
-	MaxOutputTokens int  `json:"maxOutputTokens,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int   `json:"maxOutputTokens,omitempty"`
+	Seed         *int64   `json:"seed,omitempty"`
 }
 
 // GoogleStreamResponse represents a streaming response from Google AI
 type GoogleStreamResponse struct {
-	Candidates    []GoogleCandidate    `json:"candidates,omitempty"`
-	UsageMetadata *GoogleUsageMetadata `json:"usageMetadata,omitempty"`
+	Candidates     []GoogleCandidate     `json:"candidates,omitempty"`
+	UsageMetadata  *GoogleUsageMetadata  `json:"usageMetadata,omitempty"`
+	PromptFeedback *GooglePromptFeedback `json:"promptFeedback,omitempty"`
+}
+
+// GooglePromptFeedback is set when the prompt itself was blocked
+type GooglePromptFeedback struct {
+	BlockReason string `json:"blockReason,omitempty"`
 }
 
 // GoogleCandidate represents a candidate response
@@ -113,24 +160,31 @@ type GoogleCandidate struct {
 type GoogleUsageMetadata struct {
 	PromptTokenCount     int `json:"promptTokenCount"`
 	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"` // Billed as output on thinking models
 	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
-// convertToGoogleFormat converts OpenAI-style messages to Google format
-func (p *GoogleProvider) convertToGoogleFormat(messages []models.Message) ([]GoogleContent, error) {
+// tokenUsage converts Gemini usage metadata; thinking tokens count as completion tokens
+func (u *GoogleUsageMetadata) tokenUsage() *models.TokenUsage {
+	usage := &models.TokenUsage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+// convertToGoogleFormat converts OpenAI-style messages to Google format.
+// System messages become the systemInstruction, and consecutive messages with the
+// same role are merged because Gemini expects user and model turns to alternate.
+func (p *GoogleProvider) convertToGoogleFormat(messages []models.Message) ([]GoogleContent, *GoogleContent, error) {
 	var googleContents []GoogleContent
+	var systemInstruction *GoogleContent
 
 	for _, msg := range messages {
-		// Google uses "user" and "model" roles (not "assistant")
-		role := msg.Role
-		if role == "assistant" {
-			role = "model"
-		}
-		// System messages are typically prepended to the first user message in Google
-		if role == "system" {
-			role = "user"
-		}
-
 		// Extract text content
 		content := ""
 		switch v := msg.Content.(type) {
@@ -147,6 +201,24 @@ func (p *GoogleProvider) convertToGoogleFormat(messages []models.Message) ([]Goo
 			}
 		}
 
+		if msg.Role == "system" {
+			if systemInstruction == nil {
+				systemInstruction = &GoogleContent{}
+			}
+			systemInstruction.Parts = append(systemInstruction.Parts, GooglePart{Text: content})
+			continue
+		}
+
+		// Google uses "user" and "model" roles (not "assistant")
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		if n := len(googleContents); n > 0 && googleContents[n-1].Role == role {
+			googleContents[n-1].Parts = append(googleContents[n-1].Parts, GooglePart{Text: content})
+			continue
+		}
 		googleContents = append(googleContents, GoogleContent{
 			Role: role,
 			Parts: []GooglePart{
@@ -155,7 +227,7 @@ func (p *GoogleProvider) convertToGoogleFormat(messages []models.Message) ([]Goo
 		})
 	}
 
-	return googleContents, nil
+	return googleContents, systemInstruction, nil
 }
 
 // ChatCompletion sends a chat request and returns a streaming channel
@@ -165,41 +237,46 @@ func (p *GoogleProvider) ChatCompletion(ctx context.Context, req *models.ChatReq
 	}
 
 	// Convert messages to Google format
-	googleContents, err := p.convertToGoogleFormat(req.Messages)
+	googleContents, systemInstruction, err := p.convertToGoogleFormat(req.Messages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert messages: %w", err)
 	}
 
 	// Build the request body
 	requestBody := GoogleRequest{
-		Contents: googleContents,
+		Contents:          googleContents,
+		SystemInstruction: systemInstruction,
 	}
 
 	// Add generation config if needed
-	if req.Temperature > 0 || req.MaxTokens > 0 {
-		requestBody.GenerationConfig = &GoogleGenerationConfig{}
-		if req.Temperature > 0 {
-			requestBody.GenerationConfig.Temperature = req.Temperature
+	if req.Temperature != nil || req.Seed != nil || req.MaxTokens > 0 {
+		requestBody.GenerationConfig = &GoogleGenerationConfig{
+			Temperature: req.Temperature,
+			Seed:        req.Seed,
 		}
 		if req.MaxTokens > 0 {
 			requestBody.GenerationConfig.MaxOutputTokens = req.MaxTokens
 		}
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	jsonData, err := marshalWithExtra(requestBody, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Create HTTP request with API key as query parameter
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?key=%s&alt=sse",
-		req.Model, p.apiKey)
+	// Streaming uses streamGenerateContent as SSE; otherwise generateContent returns one response.
+	// The API key goes in a header so it never appears in URLs or error messages.
+	url := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, req.Model)
+	if req.Stream {
+		url = fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.baseURL, req.Model)
+	}
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
 
 	// Send request
 	resp, err := p.client.Do(httpReq)
@@ -217,18 +294,93 @@ func (p *GoogleProvider) ChatCompletion(ctx context.Context, req *models.ChatReq
 	// Create channel for streaming events
 	eventChan := make(chan models.StreamEvent)
 
-	// Start goroutine to process streaming response
-	go p.processStream(resp, eventChan)
+	// Start goroutine to process the response
+	if req.Stream {
+		go p.processStream(resp, eventChan)
+	} else {
+		go p.processResponse(resp, eventChan)
+	}
 
 	return eventChan, nil
 }
 
+// processResponse processes a single generateContent response
+func (p *GoogleProvider) processResponse(resp *http.Response, eventChan chan<- models.StreamEvent) {
+	defer close(eventChan)
+	defer resp.Body.Close()
+
+	eventChan <- models.StreamEvent{
+		Type: "start",
+	}
+
+	var genResp GoogleStreamResponse
+	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil {
+		eventChan <- models.StreamEvent{
+			Type:  "error",
+			Error: fmt.Sprintf("failed to parse response: %v", err),
+		}
+		return
+	}
+
+	if !p.emitResponse(&genResp, eventChan) {
+		return
+	}
+
+	if genResp.UsageMetadata != nil {
+		eventChan <- models.StreamEvent{
+			Type:   "usage",
+			Tokens: genResp.UsageMetadata.tokenUsage(),
+		}
+	}
+
+	eventChan <- models.StreamEvent{
+		Type: "done",
+	}
+}
+
+// emitResponse sends the answer text of one response chunk as content events.
+// It returns false after sending an error event for blocked prompts or responses.
+func (p *GoogleProvider) emitResponse(genResp *GoogleStreamResponse, eventChan chan<- models.StreamEvent) bool {
+	if genResp.PromptFeedback != nil && genResp.PromptFeedback.BlockReason != "" {
+		eventChan <- models.StreamEvent{
+			Type:  "error",
+			Error: fmt.Sprintf("BAD_REQUEST: prompt blocked by Gemini (%s)", genResp.PromptFeedback.BlockReason),
+		}
+		return false
+	}
+
+	if len(genResp.Candidates) == 0 {
+		return true
+	}
+	candidate := genResp.Candidates[0]
+
+	for _, part := range candidate.Content.Parts {
+		if part.Text != "" && !part.Thought {
+			eventChan <- models.StreamEvent{
+				Type:    "content",
+				Content: part.Text,
+			}
+		}
+	}
+
+	switch candidate.FinishReason {
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		eventChan <- models.StreamEvent{
+			Type:  "error",
+			Error: fmt.Sprintf("response blocked by Gemini (%s)", candidate.FinishReason),
+		}
+		return false
+	}
+	return true
+}
+
 // processStream processes the SSE stream from Google AI
 func (p *GoogleProvider) processStream(resp *http.Response, eventChan chan<- models.StreamEvent) {
 	defer close(eventChan)
 	defer resp.Body.Close()
 
 	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	var totalUsage *models.TokenUsage
 
 	// Send start event
@@ -263,29 +415,13 @@ func (p *GoogleProvider) processStream(resp *http.Response, eventChan chan<- mod
 		}
 
 		// Process candidates
-		if len(streamResp.Candidates) > 0 {
-			candidate := streamResp.Candidates[0]
-
-			// Send content
-			if len(candidate.Content.Parts) > 0 {
-				for _, part := range candidate.Content.Parts {
-					if part.Text != "" {
-						eventChan <- models.StreamEvent{
-							Type:    "content",
-							Content: part.Text,
-						}
-					}
-				}
-			}
+		if !p.emitResponse(&streamResp, eventChan) {
+			return
 		}
 
-		// Extract usage metadata
+		// Extract usage metadata (cumulative, the last chunk carries the final counts)
 		if streamResp.UsageMetadata != nil {
-			totalUsage = &models.TokenUsage{
-				PromptTokens:     streamResp.UsageMetadata.PromptTokenCount,
-				CompletionTokens: streamResp.UsageMetadata.CandidatesTokenCount,
-				TotalTokens:      streamResp.UsageMetadata.TotalTokenCount,
-			}
+			totalUsage = streamResp.UsageMetadata.tokenUsage()
 		}
 	}
 
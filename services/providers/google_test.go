@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -83,13 +84,13 @@ func TestGoogleProvider_GetSupportedModels(t *testing.T) {
 		{
 			name:       "with API key",
 			apiKey:     "test-key",
-			wantModels: []string{"gemini-1.5-pro", "gemini-1.5-flash", "gemini-pro"},
+			wantModels: []string{"gemini-3-pro-preview", "gemini-2.5-pro", "gemini-2.5-flash", "gemini-1.5-pro", "gemini-1.5-flash", "gemini-pro"},
 			wantAvail:  true,
 		},
 		{
 			name:       "without API key",
 			apiKey:     "",
-			wantModels: []string{"gemini-1.5-pro", "gemini-1.5-flash", "gemini-pro"},
+			wantModels: []string{"gemini-3-pro-preview", "gemini-2.5-pro", "gemini-2.5-flash", "gemini-1.5-pro", "gemini-1.5-flash", "gemini-pro"},
 			wantAvail:  false,
 		},
 	}
@@ -122,10 +123,11 @@ func TestGoogleProvider_convertToGoogleFormat(t *testing.T) {
 	provider := NewGoogleProvider("test-key")
 
 	tests := []struct {
-		name     string
-		messages []models.Message
-		wantLen  int
-		wantRole string
+		name       string
+		messages   []models.Message
+		wantLen    int
+		wantRole   string
+		wantSystem bool
 	}{
 		{
 			name: "convert user message",
@@ -144,9 +146,20 @@ func TestGoogleProvider_convertToGoogleFormat(t *testing.T) {
 			wantRole: "model",
 		},
 		{
-			name: "convert system to user",
+			name: "system becomes systemInstruction",
 			messages: []models.Message{
 				{Role: "system", Content: "You are helpful"},
+				{Role: "user", Content: "Hello"},
+			},
+			wantLen:    1,
+			wantRole:   "user",
+			wantSystem: true,
+		},
+		{
+			name: "consecutive same-role messages are merged",
+			messages: []models.Message{
+				{Role: "user", Content: "Hello"},
+				{Role: "user", Content: "Are you there?"},
 			},
 			wantLen:  1,
 			wantRole: "user",
@@ -164,7 +177,7 @@ func TestGoogleProvider_convertToGoogleFormat(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			contents, err := provider.convertToGoogleFormat(tt.messages)
+			contents, system, err := provider.convertToGoogleFormat(tt.messages)
 			if err != nil {
 				t.Errorf("convertToGoogleFormat() error = %v", err)
 			}
@@ -174,6 +187,9 @@ func TestGoogleProvider_convertToGoogleFormat(t *testing.T) {
 			if tt.wantLen == 1 && contents[0].Role != tt.wantRole {
 				t.Errorf("convertToGoogleFormat() role = %v, want %v", contents[0].Role, tt.wantRole)
 			}
+			if (system != nil) != tt.wantSystem {
+				t.Errorf("convertToGoogleFormat() systemInstruction = %v, want present %v", system, tt.wantSystem)
+			}
 		})
 	}
 }
@@ -206,52 +222,129 @@ func TestGoogleProvider_ChatCompletion_Success(t *testing.T) {
 		if r.Method != "POST" {
 			t.Errorf("Expected POST request, got %s", r.Method)
 		}
-		if r.Header.Get("Content-Type") != "application/json" {
-			t.Errorf("Expected Content-Type application/json")
+		if r.URL.Path != "/models/gemini-2.5-pro:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("Unexpected URL %s", r.URL.String())
+		}
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("Expected API key in x-goog-api-key header")
+		}
+		if r.URL.Query().Get("key") != "" {
+			t.Errorf("API key must not be sent in the URL")
 		}
-		// Verify API key in query parameter
-		if !strings.Contains(r.URL.RawQuery, "key=test-key") {
-			t.Errorf("Expected API key in query parameter")
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"systemInstruction":{"parts":[{"text":"Be brief"}]}`) {
+			t.Errorf("Expected systemInstruction in request, got %s", body)
 		}
 
 		// Send streaming response
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"thinking...","thought":true},{"text":"Hello"}],"role":"model"}}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}` + "\n\n"))
+		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":" World"}],"role":"model"},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":7,"thoughtsTokenCount":4,"totalTokenCount":21}}` + "\n\n"))
+	}))
+	defer server.Close()
 
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			t.Fatal("Expected http.ResponseWriter to be an http.Flusher")
+	provider := NewGoogleProviderWithBaseURL("test-key", server.URL)
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model: "gemini-2.5-pro",
+		Messages: []models.Message{
+			{Role: "system", Content: "Be brief"},
+			{Role: "user", Content: "Hello"},
+		},
+		Stream: true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var content string
+	var usage *models.TokenUsage
+	var last models.StreamEvent
+	for event := range eventChan {
+		switch event.Type {
+		case "content":
+			content += event.Content
+		case "usage":
+			usage = event.Tokens
+		case "error":
+			t.Fatalf("unexpected error event: %s", event.Error)
 		}
+		last = event
+	}
 
-		// Send content chunks
-		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"Hello"}],"role":"model"}}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}` + "\n\n"))
-		flusher.Flush()
+	if content != "Hello World" {
+		t.Errorf("content = %q, want %q", content, "Hello World")
+	}
+	if usage == nil || usage.PromptTokens != 10 || usage.CompletionTokens != 11 || usage.TotalTokens != 21 {
+		t.Errorf("usage = %+v, want prompt 10, completion 11 (7 + 4 thinking), total 21", usage)
+	}
+	if last.Type != "done" {
+		t.Errorf("Last event type = %v, want done", last.Type)
+	}
+}
 
-		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":" World"}],"role":"model"}}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":7,"totalTokenCount":17}}` + "\n\n"))
-		flusher.Flush()
+func TestGoogleProvider_ChatCompletion_NonStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.5-flash:generateContent" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"Hi"}],"role":"model"},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":1,"totalTokenCount":4}}`))
 	}))
 	defer server.Close()
 
-	// Override the URL construction in the provider
-	provider := NewGoogleProvider("test-key")
-	ctx := context.Background()
+	provider := NewGoogleProviderWithBaseURL("test-key", server.URL)
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []models.Message{{Role: "user", Content: "Hello"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
 
-	req := &models.ChatRequest{
-		Model: "gemini-1.5-pro",
-		Messages: []models.Message{
-			{Role: "user", Content: "Hello"},
-		},
-		Stream: true,
+	var content string
+	var usage *models.TokenUsage
+	for event := range eventChan {
+		switch event.Type {
+		case "content":
+			content += event.Content
+		case "usage":
+			usage = event.Tokens
+		}
+	}
+	if content != "Hi" {
+		t.Errorf("content = %q, want %q", content, "Hi")
+	}
+	if usage == nil || usage.TotalTokens != 4 {
+		t.Errorf("usage = %+v, want total 4", usage)
 	}
+}
 
-	// Note: This test will fail because we can't easily mock the URL construction
-	// In a real scenario, we'd need to refactor the provider to accept a base URL
-	// For now, we'll test the error case
-	_, err := provider.ChatCompletion(ctx, req)
-	// We expect an error because we're not actually hitting the Google API
-	if err == nil {
-		// If no error, collect events
-		// This would only work if we could properly mock the URL
+func TestGoogleProvider_ChatCompletion_Blocked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"promptFeedback":{"blockReason":"SAFETY"}}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewGoogleProviderWithBaseURL("test-key", server.URL)
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "gemini-2.5-pro",
+		Messages: []models.Message{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var gotError bool
+	for event := range eventChan {
+		if event.Type == "error" && strings.Contains(event.Error, "SAFETY") {
+			gotError = true
+		}
+	}
+	if !gotError {
+		t.Error("Expected an error event for a blocked prompt")
 	}
 }
 
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"net/http"
 
 	"Curry2API-go/models"
 )
@@ -20,3 +21,56 @@ type ProviderClient interface {
 	// IsAvailable returns true if the provider is properly configured
 	IsAvailable() bool
 }
+
+// NativeMessagesClient is implemented by providers that accept Anthropic Messages API
+// request bodies as-is, so /v1/messages can be forwarded without format conversion
+type NativeMessagesClient interface {
+	// Messages sends the request body unchanged and returns the upstream response,
+	// SSE when the body sets stream=true. The caller must close the response body.
+	Messages(ctx context.Context, body []byte, beta string) (*http.Response, error)
+}
+
+// VisionClient is implemented by providers that forward OpenAI image_url content
+// parts to their upstream unchanged, so requests with images can be routed to them
+type VisionClient interface {
+	// SupportsVision reports whether image content parts reach the upstream model
+	SupportsVision() bool
+}
+
+// EmbeddingsClient is implemented by providers that serve the OpenAI embeddings API
+type EmbeddingsClient interface {
+	// Embeddings sends one batch of inputs and returns the upstream response
+	Embeddings(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error)
+}
+
+// ImageGenerationClient is implemented by providers that serve the OpenAI images API
+type ImageGenerationClient interface {
+	// GenerateImages sends an image generation request and returns the upstream response
+	GenerateImages(ctx context.Context, req *models.ImageGenerationRequest) (*models.ImageGenerationResponse, error)
+}
+
+// TranscriptionClient is implemented by providers that serve the OpenAI audio/transcriptions API
+type TranscriptionClient interface {
+	// CreateTranscription uploads the audio and returns the transcript in the requested format
+	CreateTranscription(ctx context.Context, req *models.TranscriptionRequest) (*models.TranscriptionResponse, error)
+}
+
+// SpeechClient is implemented by providers that serve the OpenAI audio/speech API
+type SpeechClient interface {
+	// CreateSpeech sends a text-to-speech request and returns the generated audio
+	CreateSpeech(ctx context.Context, req *models.SpeechRequest) (*models.SpeechResponse, error)
+}
+
+// ModerationClient is implemented by providers that serve the OpenAI moderations API
+type ModerationClient interface {
+	// Moderations sends a moderation request and returns the upstream category scores
+	Moderations(ctx context.Context, req *models.ModerationRequest) (*models.ModerationResponse, error)
+}
+
+// HealthChecker is implemented by providers that expose a cheap endpoint (such as
+// the model list) for liveness probing. Providers without it are probed with a
+// minimal chat completion, and only while their circuit is open
+type HealthChecker interface {
+	// HealthCheck returns nil when the upstream is reachable and accepts the API key
+	HealthCheck(ctx context.Context) error
+}
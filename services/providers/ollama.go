@@ -0,0 +1,336 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Curry2API-go/models"
+)
+
+// OllamaModelPrefix namespaces local models so they never collide with hosted model IDs
+const OllamaModelPrefix = "ollama/"
+
+// OllamaProvider implements the ProviderClient interface for a self-hosted Ollama
+// server (or any server exposing Ollama's /api/tags and OpenAI-compatible /v1 API)
+type OllamaProvider struct {
+	baseURL string
+	apiKey  string // Optional, for Ollama instances behind an authenticating proxy
+	client  *http.Client
+
+	mu       sync.RWMutex
+	models   []models.ModelInfo
+	syncedAt time.Time
+}
+
+// ollamaTag is an entry of the Ollama /api/tags response
+type ollamaTag struct {
+	Name    string `json:"name"`
+	Details struct {
+		ParameterSize string `json:"parameter_size"`
+	} `json:"details"`
+}
+
+// NewOllamaProvider creates a new Ollama provider instance. baseURL is the server
+// root, e.g. http://localhost:11434
+func NewOllamaProvider(baseURL, apiKey string) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL: strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1"),
+		apiKey:  apiKey,
+		// Local models can take minutes to load and generate; the request context bounds the call
+		client: &http.Client{Timeout: 10 * time.Minute},
+		models: []models.ModelInfo{},
+	}
+}
+
+// IsAvailable returns true if the provider is properly configured
+func (p *OllamaProvider) IsAvailable() bool {
+	return p.baseURL != ""
+}
+
+// GetProviderName returns the provider identifier
+func (p *OllamaProvider) GetProviderName() string {
+	return "ollama"
+}
+
+// GetSupportedModels returns the models installed on the Ollama server at the last sync
+func (p *OllamaProvider) GetSupportedModels() []models.ModelInfo {
+	isAvailable := p.IsAvailable()
+
+	p.mu.RLock()
+	result := make([]models.ModelInfo, len(p.models))
+	copy(result, p.models)
+	p.mu.RUnlock()
+
+	for i := range result {
+		result[i].IsAvailable = isAvailable
+	}
+	return result
+}
+
+// LastSynced returns when the model list was last refreshed from the Ollama server
+func (p *OllamaProvider) LastSynced() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.syncedAt
+}
+
+// SyncModels refreshes the model list from the Ollama server and returns the public
+// model IDs (the local model name prefixed with "ollama/")
+func (p *OllamaProvider) SyncModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	p.setAuth(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	var tagsResp struct {
+		Models []ollamaTag `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+
+	list := make([]models.ModelInfo, 0, len(tagsResp.Models))
+	ids := make([]string, 0, len(tagsResp.Models))
+	for _, m := range tagsResp.Models {
+		if m.Name == "" {
+			continue
+		}
+		name := m.Name
+		if m.Details.ParameterSize != "" {
+			name += " (" + m.Details.ParameterSize + ")"
+		}
+		id := OllamaModelPrefix + m.Name
+		list = append(list, models.ModelInfo{
+			ID:       id,
+			Name:     "🖥️ " + name,
+			Provider: "ollama",
+		})
+		ids = append(ids, id)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	sort.Strings(ids)
+
+	p.mu.Lock()
+	p.models = list
+	p.syncedAt = time.Now()
+	p.mu.Unlock()
+	return ids, nil
+}
+
+// HealthCheck refreshes the installed model list, which doubles as a liveness probe
+func (p *OllamaProvider) HealthCheck(ctx context.Context) error {
+	_, err := p.SyncModels(ctx)
+	return err
+}
+
+// setAuth adds the optional bearer token
+func (p *OllamaProvider) setAuth(httpReq *http.Request) {
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+}
+
+// ChatCompletion sends a chat request and returns a streaming channel
+func (p *OllamaProvider) ChatCompletion(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamEvent, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("Ollama provider not available: base URL not configured")
+	}
+
+	// Build the request body (OpenAI-compatible format)
+	requestBody := map[string]interface{}{
+		"model":    strings.TrimPrefix(req.Model, OllamaModelPrefix),
+		"messages": req.Messages,
+		"stream":   true,
+		// Ask Ollama to append token usage to the final chunk for usage tracking
+		"stream_options": map[string]interface{}{"include_usage": true},
+	}
+
+	if req.MaxTokens > 0 {
+		requestBody["max_tokens"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		requestBody["temperature"] = *req.Temperature
+	}
+	if req.Seed != nil {
+		requestBody["seed"] = *req.Seed
+	}
+
+	jsonData, err := marshalWithExtra(requestBody, req.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	url := p.baseURL + "/v1/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	p.setAuth(httpReq)
+
+	// Send request
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("PROVIDER_ERROR: Ollama server unreachable: %v", err)
+	}
+
+	// Handle error responses
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	// Create channel for streaming events
+	eventChan := make(chan models.StreamEvent)
+
+	// Start goroutine to process streaming response
+	go p.processStream(resp, eventChan)
+
+	return eventChan, nil
+}
+
+// processStream processes the SSE stream from Ollama
+func (p *OllamaProvider) processStream(resp *http.Response, eventChan chan<- models.StreamEvent) {
+	defer close(eventChan)
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var totalUsage *models.TokenUsage
+
+	// Send start event
+	eventChan <- models.StreamEvent{
+		Type: "start",
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Skip empty lines
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		// Extract data after "data: " prefix
+		data := strings.TrimPrefix(line, "data: ")
+
+		// Check for [DONE] marker
+		if data == "[DONE]" {
+			break
+		}
+
+		// Errors after the stream has started arrive as a chunk with an error object
+		var streamErr struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &streamErr); err == nil && streamErr.Error != nil {
+			eventChan <- models.StreamEvent{
+				Type:  "error",
+				Error: fmt.Sprintf("PROVIDER_ERROR: %s", streamErr.Error.Message),
+			}
+			return
+		}
+
+		// Parse JSON
+		var streamResp models.ChatCompletionStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			eventChan <- models.StreamEvent{
+				Type:  "error",
+				Error: fmt.Sprintf("failed to parse stream response: %v", err),
+			}
+			return
+		}
+
+		// The usage chunk arrives last with an empty choices array
+		if streamResp.Usage != nil {
+			totalUsage = &models.TokenUsage{
+				PromptTokens:     streamResp.Usage.PromptTokens,
+				CompletionTokens: streamResp.Usage.CompletionTokens,
+				TotalTokens:      streamResp.Usage.TotalTokens,
+			}
+		}
+
+		// Send content delta
+		if len(streamResp.Choices) > 0 && streamResp.Choices[0].Delta.Content != "" {
+			eventChan <- models.StreamEvent{
+				Type:    "content",
+				Content: streamResp.Choices[0].Delta.Content,
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		eventChan <- models.StreamEvent{
+			Type:  "error",
+			Error: fmt.Sprintf("stream reading error: %v", err),
+		}
+		return
+	}
+
+	// Send usage event if we have token information
+	if totalUsage != nil {
+		eventChan <- models.StreamEvent{
+			Type:   "usage",
+			Tokens: totalUsage,
+		}
+	}
+
+	// Send done event
+	eventChan <- models.StreamEvent{
+		Type: "done",
+	}
+}
+
+// handleErrorResponse converts HTTP error responses to appropriate errors
+func (p *OllamaProvider) handleErrorResponse(statusCode int, body []byte) error {
+	var errorResp models.ErrorResponse
+	message := string(body)
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+		message = errorResp.Error.Message
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("INVALID_API_KEY: Ollama server rejected the configured credentials")
+	case http.StatusNotFound:
+		// The model has not been pulled on the Ollama server
+		return fmt.Errorf("BAD_REQUEST: %s", message)
+	case http.StatusBadRequest:
+		lowerMsg := strings.ToLower(message)
+		if strings.Contains(lowerMsg, "context") || strings.Contains(lowerMsg, "length") {
+			return fmt.Errorf("CONTEXT_TOO_LONG: %s", message)
+		}
+		return fmt.Errorf("BAD_REQUEST: %s", message)
+	default:
+		if statusCode >= 500 {
+			return fmt.Errorf("PROVIDER_ERROR: %s", message)
+		}
+		return fmt.Errorf("UNKNOWN_ERROR: %s", message)
+	}
+}
@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Curry2API-go/models"
+)
+
+func TestOllamaProvider_IsAvailable(t *testing.T) {
+	if NewOllamaProvider("", "").IsAvailable() {
+		t.Error("Expected provider without base URL to be unavailable")
+	}
+	if !NewOllamaProvider("http://localhost:11434", "").IsAvailable() {
+		t.Error("Expected provider with base URL to be available")
+	}
+}
+
+func TestOllamaProvider_SyncModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("Expected /api/tags, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"models":[
+			{"name":"qwen2.5:7b","details":{"parameter_size":"7.6B"}},
+			{"name":"llama3.2:latest","details":{"parameter_size":"3.2B"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	// A trailing /v1 is accepted and stripped
+	provider := NewOllamaProvider(server.URL+"/v1", "")
+	ids, err := provider.SyncModels(context.Background())
+	if err != nil {
+		t.Fatalf("SyncModels() error = %v", err)
+	}
+	want := []string{"ollama/llama3.2:latest", "ollama/qwen2.5:7b"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("SyncModels() = %v, want %v", ids, want)
+	}
+
+	supported := provider.GetSupportedModels()
+	if len(supported) != 2 || supported[0].Provider != "ollama" || !supported[0].IsAvailable {
+		t.Errorf("Unexpected supported models: %+v", supported)
+	}
+	if provider.LastSynced().IsZero() {
+		t.Error("Expected LastSynced to be set")
+	}
+}
+
+func TestOllamaProvider_ChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Expected /v1/chat/completions, got %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer proxy-token" {
+			t.Errorf("Expected proxy token, got %q", auth)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "llama3.2:latest" {
+			t.Errorf("Expected prefix to be stripped, got model %v", body["model"])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1,\"total_tokens\":6}}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "proxy-token")
+	events, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "ollama/llama3.2:latest",
+		Messages: []models.Message{{Role: "user", Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var content string
+	var usage *models.TokenUsage
+	for event := range events {
+		switch event.Type {
+		case "content":
+			content += event.Content
+		case "usage":
+			usage = event.Tokens
+		case "error":
+			t.Fatalf("Unexpected error event: %s", event.Error)
+		}
+	}
+	if content != "Hello" {
+		t.Errorf("content = %q, want Hello", content)
+	}
+	if usage == nil || usage.TotalTokens != 6 {
+		t.Errorf("usage = %+v, want 6 total tokens", usage)
+	}
+}
+
+func TestOllamaProvider_ModelNotPulled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"message":"model \"missing\" not found, try pulling it first"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOllamaProvider(server.URL, "").ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "ollama/missing",
+		Messages: []models.Message{{Role: "user", Content: "Hi"}},
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "BAD_REQUEST:") || !strings.Contains(err.Error(), "pulling") {
+		t.Errorf("Expected BAD_REQUEST with upstream message, got %v", err)
+	}
+}
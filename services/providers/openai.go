@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 	"time"
@@ -45,6 +46,11 @@ func (p *OpenAIProvider) GetProviderName() string {
 	return "openai"
 }
 
+// SupportsVision reports that messages, including image_url parts, are sent as-is
+func (p *OpenAIProvider) SupportsVision() bool {
+	return true
+}
+
 // GetSupportedModels returns the list of models supported by this provider
 func (p *OpenAIProvider) GetSupportedModels() []models.ModelInfo {
 	isAvailable := p.IsAvailable()
@@ -144,16 +150,21 @@ func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req *models.ChatReq
 		"model":    req.Model,
 		"messages": req.Messages,
 		"stream":   true,
+		// Ask OpenAI to append a final chunk with token usage for billing
+		"stream_options": map[string]interface{}{"include_usage": true},
 	}
 
 	if req.MaxTokens > 0 {
 		requestBody["max_tokens"] = req.MaxTokens
 	}
-	if req.Temperature > 0 {
-		requestBody["temperature"] = req.Temperature
+	if req.Temperature != nil {
+		requestBody["temperature"] = *req.Temperature
+	}
+	if req.Seed != nil {
+		requestBody["seed"] = *req.Seed
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	jsonData, err := marshalWithExtra(requestBody, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -190,12 +201,266 @@ func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req *models.ChatReq
 	return eventChan, nil
 }
 
+// Embeddings sends an embeddings request and returns the upstream response
+func (p *OpenAIProvider) Embeddings(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenAI provider not available: API key not configured")
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	var result models.EmbeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+	}
+	return &result, nil
+}
+
+// GenerateImages sends an image generation request and returns the upstream response
+func (p *OpenAIProvider) GenerateImages(ctx context.Context, req *models.ImageGenerationRequest) (*models.ImageGenerationResponse, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenAI provider not available: API key not configured")
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/images/generations", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	var result models.ImageGenerationResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse images response: %w", err)
+	}
+	return &result, nil
+}
+
+// CreateTranscription streams the audio file to the transcriptions endpoint as multipart
+// form data and returns the response body, whose format depends on response_format
+func (p *OpenAIProvider) CreateTranscription(ctx context.Context, req *models.TranscriptionRequest) (*models.TranscriptionResponse, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenAI provider not available: API key not configured")
+	}
+
+	// Write the form through a pipe so the file is never held in memory in full
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeTranscriptionForm(form, req))
+	}()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/audio/transcriptions", pr)
+	if err != nil {
+		pr.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	return &models.TranscriptionResponse{
+		Body:        body,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// writeTranscriptionForm writes the transcription fields followed by the file, then closes the form
+func writeTranscriptionForm(form *multipart.Writer, req *models.TranscriptionRequest) error {
+	fields := []struct{ name, value string }{
+		{"model", req.Model},
+		{"language", req.Language},
+		{"prompt", req.Prompt},
+		{"response_format", req.ResponseFormat},
+		{"temperature", req.Temperature},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if err := form.WriteField(field.name, field.value); err != nil {
+			return err
+		}
+	}
+	for _, granularity := range req.TimestampGranularities {
+		if err := form.WriteField("timestamp_granularities[]", granularity); err != nil {
+			return err
+		}
+	}
+
+	part, err := form.CreateFormFile("file", req.FileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, req.File); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// CreateSpeech sends a text-to-speech request and returns the audio bytes with the upstream content type
+func (p *OpenAIProvider) CreateSpeech(ctx context.Context, req *models.SpeechRequest) (*models.SpeechResponse, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenAI provider not available: API key not configured")
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/audio/speech", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	return &models.SpeechResponse{
+		Audio:       body,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// Moderations sends a moderation request and returns the upstream response
+func (p *OpenAIProvider) Moderations(ctx context.Context, req *models.ModerationRequest) (*models.ModerationResponse, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenAI provider not available: API key not configured")
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/moderations", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	var result models.ModerationResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse moderations response: %w", err)
+	}
+	return &result, nil
+}
+
+// HealthCheck lists models, which needs a valid API key but costs no tokens
+func (p *OpenAIProvider) HealthCheck(ctx context.Context) error {
+	if !p.IsAvailable() {
+		return fmt.Errorf("OpenAI provider not available: API key not configured")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return p.handleErrorResponse(resp.StatusCode, body)
+	}
+	return nil
+}
+
 // processStream processes the SSE stream from OpenAI
 func (p *OpenAIProvider) processStream(resp *http.Response, eventChan chan<- models.StreamEvent) {
 	defer close(eventChan)
 	defer resp.Body.Close()
 
 	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	var totalUsage *models.TokenUsage
 
 	// Send start event
@@ -234,6 +499,15 @@ func (p *OpenAIProvider) processStream(resp *http.Response, eventChan chan<- mod
 			return
 		}
 
+		// The usage chunk arrives last with an empty choices array
+		if streamResp.Usage != nil {
+			totalUsage = &models.TokenUsage{
+				PromptTokens:     streamResp.Usage.PromptTokens,
+				CompletionTokens: streamResp.Usage.CompletionTokens,
+				TotalTokens:      streamResp.Usage.TotalTokens,
+			}
+		}
+
 		// Process choices
 		if len(streamResp.Choices) > 0 {
 			choice := streamResp.Choices[0]
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -250,6 +251,79 @@ func TestOpenAIProvider_ChatCompletion_Success(t *testing.T) {
 	}
 }
 
+func TestOpenAIProvider_ChatCompletion_StreamUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"include_usage":true`) {
+			t.Errorf("Expected stream_options.include_usage in request, got %s", body)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`data: {"id":"chatcmpl-123","object":"chat.completion.chunk","created":1234567890,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}` + "\n\n"))
+		w.Write([]byte(`data: {"id":"chatcmpl-123","object":"chat.completion.chunk","created":1234567890,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("test-key", server.URL)
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []models.Message{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var usage *models.TokenUsage
+	for event := range eventChan {
+		if event.Type == "usage" {
+			usage = event.Tokens
+		}
+	}
+	if usage == nil {
+		t.Fatal("Expected a usage event")
+	}
+	if usage.PromptTokens != 12 || usage.CompletionTokens != 3 || usage.TotalTokens != 15 {
+		t.Errorf("Usage = %+v, want 12/3/15", *usage)
+	}
+}
+
+func TestOpenAIProvider_ChatCompletion_DeterministicParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// temperature 0 must be sent explicitly rather than dropped as a zero value
+		if !strings.Contains(string(body), `"temperature":0`) {
+			t.Errorf("Expected temperature 0 in request, got %s", body)
+		}
+		if !strings.Contains(string(body), `"seed":42`) {
+			t.Errorf("Expected seed in request, got %s", body)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	temperature := 0.0
+	seed := int64(42)
+	provider := NewOpenAIProvider("test-key", server.URL)
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:       "gpt-4o",
+		Messages:    []models.Message{{Role: "user", Content: "Hello"}},
+		Stream:      true,
+		Temperature: &temperature,
+		Seed:        &seed,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	for range eventChan {
+	}
+}
+
 func TestOpenAIProvider_ErrorHandling(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -314,3 +388,229 @@ func TestOpenAIProvider_ErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenAIProvider_HealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/models" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") == "Bearer bad-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer server.Close()
+
+	if err := NewOpenAIProvider("test-key", server.URL).HealthCheck(context.Background()); err != nil {
+		t.Errorf("Expected healthy provider, got %v", err)
+	}
+
+	err := NewOpenAIProvider("bad-key", server.URL).HealthCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "INVALID_API_KEY") {
+		t.Errorf("Expected INVALID_API_KEY error, got %v", err)
+	}
+}
+
+func TestOpenAIProvider_Embeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/embeddings" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"input":["a","b"]`) {
+			t.Errorf("Unexpected request body %s", body)
+		}
+		w.Write([]byte(`{"object":"list","model":"text-embedding-3-small","data":[
+			{"object":"embedding","index":0,"embedding":[0.1,0.2]},
+			{"object":"embedding","index":1,"embedding":[0.3,0.4]}
+		],"usage":{"prompt_tokens":4,"total_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAIProvider("test-key", server.URL).Embeddings(context.Background(), &models.EmbeddingRequest{
+		Model: "text-embedding-3-small",
+		Input: []interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Embeddings() error = %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[1].Index != 1 || string(resp.Data[1].Embedding) != "[0.3,0.4]" {
+		t.Errorf("Unexpected data %+v", resp.Data)
+	}
+	if resp.Usage.TotalTokens != 4 {
+		t.Errorf("usage = %+v, want total 4", resp.Usage)
+	}
+}
+
+func TestOpenAIProvider_Embeddings_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIProvider("test-key", server.URL).Embeddings(context.Background(), &models.EmbeddingRequest{
+		Model: "text-embedding-3-small",
+		Input: "hello",
+	})
+	if err == nil || !strings.Contains(err.Error(), "RATE_LIMITED") {
+		t.Errorf("Expected RATE_LIMITED error, got %v", err)
+	}
+}
+
+func TestOpenAIProvider_GenerateImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/images/generations" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"response_format":"b64_json"`) {
+			t.Errorf("Expected response_format to be forwarded, got %s", body)
+		}
+		w.Write([]byte(`{"created":1700000000,"data":[{"b64_json":"aGVsbG8=","revised_prompt":"a red cat"}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAIProvider("test-key", server.URL).GenerateImages(context.Background(), &models.ImageGenerationRequest{
+		Model:          "dall-e-3",
+		Prompt:         "a cat",
+		ResponseFormat: "b64_json",
+	})
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].B64JSON != "aGVsbG8=" || resp.Data[0].RevisedPrompt != "a red cat" {
+		t.Errorf("Unexpected data %+v", resp.Data)
+	}
+}
+
+func TestOpenAIProvider_CreateTranscription(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("Expected file part: %v", err)
+		}
+		audio, _ := io.ReadAll(file)
+		if header.Filename != "speech.mp3" || string(audio) != "ID3-audio" {
+			t.Errorf("Unexpected file %s %q", header.Filename, audio)
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "zh" || r.FormValue("response_format") != "verbose_json" {
+			t.Errorf("Expected fields to be forwarded, got %v", r.MultipartForm.Value)
+		}
+		if got := r.MultipartForm.Value["timestamp_granularities[]"]; len(got) != 2 {
+			t.Errorf("Expected timestamp granularities to be forwarded, got %v", got)
+		}
+		if _, ok := r.MultipartForm.Value["prompt"]; ok {
+			t.Error("Expected empty prompt to be omitted")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"你好","duration":1.5}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAIProvider("test-key", server.URL).CreateTranscription(context.Background(), &models.TranscriptionRequest{
+		File:                   strings.NewReader("ID3-audio"),
+		FileName:               "speech.mp3",
+		Model:                  "whisper-1",
+		Language:               "zh",
+		ResponseFormat:         "verbose_json",
+		TimestampGranularities: []string{"word", "segment"},
+	})
+	if err != nil {
+		t.Fatalf("CreateTranscription() error = %v", err)
+	}
+	if string(resp.Body) != `{"text":"你好","duration":1.5}` || resp.ContentType != "application/json" {
+		t.Errorf("Unexpected response %s (%s)", resp.Body, resp.ContentType)
+	}
+}
+
+func TestOpenAIProvider_CreateTranscription_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"Invalid file format","type":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIProvider("test-key", server.URL).CreateTranscription(context.Background(), &models.TranscriptionRequest{
+		File:     strings.NewReader("not audio"),
+		FileName: "notes.txt",
+		Model:    "whisper-1",
+	})
+	if err == nil {
+		t.Fatal("Expected error for upstream 400")
+	}
+}
+
+func TestOpenAIProvider_CreateSpeech(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/audio/speech" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"voice":"alloy"`) || !strings.Contains(string(body), `"response_format":"opus"`) {
+			t.Errorf("Expected voice and response_format to be forwarded, got %s", body)
+		}
+		w.Header().Set("Content-Type", "audio/ogg")
+		w.Write([]byte("OggS-audio"))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAIProvider("test-key", server.URL).CreateSpeech(context.Background(), &models.SpeechRequest{
+		Model:          "tts-1",
+		Input:          "Hello",
+		Voice:          "alloy",
+		ResponseFormat: "opus",
+	})
+	if err != nil {
+		t.Fatalf("CreateSpeech() error = %v", err)
+	}
+	if string(resp.Audio) != "OggS-audio" || resp.ContentType != "audio/ogg" {
+		t.Errorf("Unexpected response %q (%s)", resp.Audio, resp.ContentType)
+	}
+}
+
+func TestOpenAIProvider_CreateSpeech_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"Invalid voice","type":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIProvider("test-key", server.URL).CreateSpeech(context.Background(), &models.SpeechRequest{
+		Model: "tts-1",
+		Input: "Hello",
+		Voice: "nobody",
+	})
+	if err == nil {
+		t.Fatal("Expected error for upstream 400")
+	}
+}
+
+func TestOpenAIProvider_Moderations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/moderations" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"modr-1","model":"omni-moderation-latest","results":[{"flagged":true,"categories":{"violence":true,"hate":false},"category_scores":{"violence":0.91,"hate":0.01}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAIProvider("test-key", server.URL).Moderations(context.Background(), &models.ModerationRequest{
+		Model: "omni-moderation-latest",
+		Input: "some text",
+	})
+	if err != nil {
+		t.Fatalf("Moderations() error = %v", err)
+	}
+	if len(resp.Results) != 1 || !resp.Results[0].Flagged || !resp.Results[0].Categories["violence"] {
+		t.Errorf("Unexpected results %+v", resp.Results)
+	}
+	if resp.Results[0].CategoryScores["violence"] != 0.91 {
+		t.Errorf("CategoryScores[violence] = %v, want 0.91", resp.Results[0].CategoryScores["violence"])
+	}
+}
@@ -0,0 +1,444 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Curry2API-go/models"
+)
+
+// openRouterFreeSuffix marks the zero-cost variant of a model in the OpenRouter catalog
+const openRouterFreeSuffix = ":free"
+
+// OpenRouterProvider implements the ProviderClient interface for OpenRouter's free models
+type OpenRouterProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+
+	mu       sync.RWMutex
+	catalog  map[string]models.ModelInfo // Public model ID -> model info
+	upstream map[string]string           // Public model ID -> OpenRouter model ID
+	syncedAt time.Time
+}
+
+// openRouterModel is an entry of the OpenRouter /models response
+type openRouterModel struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContextLength int    `json:"context_length"`
+	Pricing       struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+}
+
+// NewOpenRouterProvider creates a new OpenRouter provider instance. seed is the
+// model list served until the first SyncModels call succeeds
+func NewOpenRouterProvider(apiKey, baseURL string, seed []models.ModelInfo) *OpenRouterProvider {
+	if baseURL == "" {
+		baseURL = "https://openrouter.ai/api/v1"
+	}
+	p := &OpenRouterProvider{
+		apiKey:   apiKey,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		client:   &http.Client{Timeout: 120 * time.Second},
+		catalog:  make(map[string]models.ModelInfo, len(seed)),
+		upstream: make(map[string]string, len(seed)),
+	}
+	for _, m := range seed {
+		p.catalog[m.ID] = m
+		p.upstream[m.ID] = m.ID + openRouterFreeSuffix
+	}
+	return p
+}
+
+// IsAvailable returns true if the provider is properly configured
+func (p *OpenRouterProvider) IsAvailable() bool {
+	return p.apiKey != ""
+}
+
+// GetProviderName returns the provider identifier
+func (p *OpenRouterProvider) GetProviderName() string {
+	return "openrouter"
+}
+
+// SupportsVision reports that messages, including image_url parts, are sent as-is
+func (p *OpenRouterProvider) SupportsVision() bool {
+	return true
+}
+
+// GetSupportedModels returns the list of models supported by this provider
+func (p *OpenRouterProvider) GetSupportedModels() []models.ModelInfo {
+	isAvailable := p.IsAvailable()
+
+	p.mu.RLock()
+	result := make([]models.ModelInfo, 0, len(p.catalog))
+	for _, m := range p.catalog {
+		m.IsAvailable = isAvailable
+		result = append(result, m)
+	}
+	p.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// LastSynced returns when the model list was last refreshed from OpenRouter
+func (p *OpenRouterProvider) LastSynced() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.syncedAt
+}
+
+// SyncModels refreshes the free model list from the OpenRouter catalog and returns
+// the public model IDs. Models are exposed without the ":free" suffix; seeded IDs
+// that omit the vendor prefix (e.g. "glm-4.5-air") keep working as aliases
+func (p *OpenRouterProvider) SyncModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	var listResp struct {
+		Data []openRouterModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+
+	catalog := make(map[string]models.ModelInfo)
+	upstream := make(map[string]string)
+	for _, m := range listResp.Data {
+		if !isFreeOpenRouterModel(m) {
+			continue
+		}
+		id := strings.TrimSuffix(m.ID, openRouterFreeSuffix)
+		name := strings.TrimSpace(strings.TrimSuffix(m.Name, "(free)"))
+		if name == "" {
+			name = id
+		}
+		catalog[id] = models.ModelInfo{
+			ID:            id,
+			Name:          "🆓 " + name,
+			Provider:      "openrouter-free",
+			ContextWindow: m.ContextLength,
+		}
+		upstream[id] = m.ID
+	}
+	if len(catalog) == 0 {
+		return nil, fmt.Errorf("OpenRouter returned no free models")
+	}
+
+	p.mu.Lock()
+	for id, info := range p.catalog {
+		if _, exists := catalog[id]; exists || strings.Contains(id, "/") {
+			continue
+		}
+		for publicID, upstreamID := range upstream {
+			if strings.HasSuffix(publicID, "/"+id) {
+				info.ContextWindow = catalog[publicID].ContextWindow
+				catalog[id] = info
+				upstream[id] = upstreamID
+				break
+			}
+		}
+	}
+	p.catalog = catalog
+	p.upstream = upstream
+	p.syncedAt = time.Now()
+	p.mu.Unlock()
+
+	ids := make([]string, 0, len(catalog))
+	for id := range catalog {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// isFreeOpenRouterModel reports whether a catalog entry is a zero-cost variant
+func isFreeOpenRouterModel(m openRouterModel) bool {
+	if strings.HasSuffix(m.ID, openRouterFreeSuffix) {
+		return true
+	}
+	prompt, err1 := strconv.ParseFloat(m.Pricing.Prompt, 64)
+	completion, err2 := strconv.ParseFloat(m.Pricing.Completion, 64)
+	return err1 == nil && err2 == nil && prompt == 0 && completion == 0
+}
+
+// upstreamModel maps a public model ID to the ID OpenRouter expects
+func (p *OpenRouterProvider) upstreamModel(model string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if id, ok := p.upstream[model]; ok {
+		return id
+	}
+	return model
+}
+
+// ChatCompletion sends a chat request and returns a streaming channel
+func (p *OpenRouterProvider) ChatCompletion(ctx context.Context, req *models.ChatRequest) (<-chan models.StreamEvent, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenRouter provider not available: API key not configured")
+	}
+
+	// Build the request body (OpenAI-compatible format)
+	requestBody := map[string]interface{}{
+		"model":    p.upstreamModel(req.Model),
+		"messages": req.Messages,
+		"stream":   true,
+		// Ask OpenRouter to append token usage to the final chunk for billing
+		"usage": map[string]interface{}{"include": true},
+	}
+
+	if req.MaxTokens > 0 {
+		requestBody["max_tokens"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		requestBody["temperature"] = *req.Temperature
+	}
+	if req.Seed != nil {
+		requestBody["seed"] = *req.Seed
+	}
+
+	jsonData, err := marshalWithExtra(requestBody, req.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	url := p.baseURL + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	httpReq.Header.Set("HTTP-Referer", "https://cursor2api.com")
+	httpReq.Header.Set("X-Title", "Cursor2API")
+
+	// Send request
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	// Handle error responses
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	// Create channel for streaming events
+	eventChan := make(chan models.StreamEvent)
+
+	// Start goroutine to process streaming response
+	go p.processStream(resp, eventChan)
+
+	return eventChan, nil
+}
+
+// Embeddings sends an embeddings request and returns the upstream response.
+// OpenRouter serves embedding models under vendor-prefixed IDs (e.g. openai/text-embedding-3-small)
+func (p *OpenRouterProvider) Embeddings(ctx context.Context, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("OpenRouter provider not available: API key not configured")
+	}
+
+	upstreamReq := *req
+	upstreamReq.Model = p.upstreamModel(req.Model)
+	jsonData, err := json.Marshal(&upstreamReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	httpReq.Header.Set("HTTP-Referer", "https://cursor2api.com")
+	httpReq.Header.Set("X-Title", "Cursor2API")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.handleErrorResponse(resp.StatusCode, body)
+	}
+
+	var result models.EmbeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+	}
+	// Report the model the client asked for rather than the upstream ID
+	result.Model = req.Model
+	return &result, nil
+}
+
+// processStream processes the SSE stream from OpenRouter
+func (p *OpenRouterProvider) processStream(resp *http.Response, eventChan chan<- models.StreamEvent) {
+	defer close(eventChan)
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var totalUsage *models.TokenUsage
+
+	// Send start event
+	eventChan <- models.StreamEvent{
+		Type: "start",
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Skip empty lines and keep-alive comments (": OPENROUTER PROCESSING")
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		// Extract data after "data: " prefix
+		data := strings.TrimPrefix(line, "data: ")
+
+		// Check for [DONE] marker
+		if data == "[DONE]" {
+			break
+		}
+
+		// Errors after the stream has started arrive as a chunk with an error object
+		var streamErr struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &streamErr); err == nil && streamErr.Error != nil {
+			eventChan <- models.StreamEvent{
+				Type:  "error",
+				Error: fmt.Sprintf("PROVIDER_ERROR: %s", streamErr.Error.Message),
+			}
+			return
+		}
+
+		// Parse JSON
+		var streamResp models.ChatCompletionStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			eventChan <- models.StreamEvent{
+				Type:  "error",
+				Error: fmt.Sprintf("failed to parse stream response: %v", err),
+			}
+			return
+		}
+
+		// The usage chunk arrives last with an empty choices array
+		if streamResp.Usage != nil {
+			totalUsage = &models.TokenUsage{
+				PromptTokens:     streamResp.Usage.PromptTokens,
+				CompletionTokens: streamResp.Usage.CompletionTokens,
+				TotalTokens:      streamResp.Usage.TotalTokens,
+			}
+		}
+
+		// Send content delta
+		if len(streamResp.Choices) > 0 && streamResp.Choices[0].Delta.Content != "" {
+			eventChan <- models.StreamEvent{
+				Type:    "content",
+				Content: streamResp.Choices[0].Delta.Content,
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		eventChan <- models.StreamEvent{
+			Type:  "error",
+			Error: fmt.Sprintf("stream reading error: %v", err),
+		}
+		return
+	}
+
+	// Send usage event if we have token information
+	if totalUsage != nil {
+		eventChan <- models.StreamEvent{
+			Type:   "usage",
+			Tokens: totalUsage,
+		}
+	}
+
+	// Send done event
+	eventChan <- models.StreamEvent{
+		Type: "done",
+	}
+}
+
+// handleErrorResponse converts HTTP error responses to appropriate errors
+func (p *OpenRouterProvider) handleErrorResponse(statusCode int, body []byte) error {
+	var errorResp models.ErrorResponse
+	message := string(body)
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+		message = errorResp.Error.Message
+	}
+
+	return p.mapErrorCode(statusCode, message)
+}
+
+// mapErrorCode maps HTTP status codes to appropriate error messages
+func (p *OpenRouterProvider) mapErrorCode(statusCode int, message string) error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("INVALID_API_KEY: API key is invalid or expired")
+	case http.StatusPaymentRequired:
+		// The OpenRouter account has run out of credits
+		return fmt.Errorf("PROVIDER_ERROR: %s", message)
+	case http.StatusTooManyRequests:
+		// Free models are rate limited per day and per minute on OpenRouter
+		return fmt.Errorf("RATE_LIMITED: Rate limit exceeded, please try again later")
+	case http.StatusBadRequest:
+		lowerMsg := strings.ToLower(message)
+		if strings.Contains(lowerMsg, "context") ||
+			strings.Contains(lowerMsg, "maximum") ||
+			strings.Contains(lowerMsg, "length") {
+			return fmt.Errorf("CONTEXT_TOO_LONG: %s", message)
+		}
+		return fmt.Errorf("BAD_REQUEST: %s", message)
+	default:
+		if statusCode >= 500 {
+			return fmt.Errorf("PROVIDER_ERROR: AI service temporarily unavailable")
+		}
+		return fmt.Errorf("UNKNOWN_ERROR: %s", message)
+	}
+}
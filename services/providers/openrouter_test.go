@@ -0,0 +1,190 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Curry2API-go/models"
+)
+
+func TestOpenRouterProvider_IsAvailable(t *testing.T) {
+	if NewOpenRouterProvider("", "", nil).IsAvailable() {
+		t.Error("Expected provider without API key to be unavailable")
+	}
+	if !NewOpenRouterProvider("test-key", "", nil).IsAvailable() {
+		t.Error("Expected provider with API key to be available")
+	}
+}
+
+func TestOpenRouterProvider_SyncModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("Expected /models, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"data":[
+			{"id":"meta-llama/llama-3.3-70b-instruct:free","name":"Meta: Llama 3.3 70B Instruct (free)","context_length":65536,"pricing":{"prompt":"0","completion":"0"}},
+			{"id":"meta-llama/llama-3.3-70b-instruct","name":"Meta: Llama 3.3 70B Instruct","context_length":131072,"pricing":{"prompt":"0.00000013","completion":"0.0000004"}},
+			{"id":"z-ai/glm-4.5-air:free","name":"Z.AI: GLM 4.5 Air (free)","context_length":131072,"pricing":{"prompt":"0","completion":"0"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenRouterProvider("test-key", server.URL, []models.ModelInfo{
+		{ID: "glm-4.5-air", Name: "GLM 4.5 Air", Provider: "openrouter-free"},
+		{ID: "qwen/qwen3-4b", Name: "Qwen 3 4B", Provider: "openrouter-free"},
+	})
+
+	ids, err := provider.SyncModels(context.Background())
+	if err != nil {
+		t.Fatalf("SyncModels() error = %v", err)
+	}
+	want := []string{"glm-4.5-air", "meta-llama/llama-3.3-70b-instruct", "z-ai/glm-4.5-air"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("SyncModels() = %v, want %v", ids, want)
+	}
+
+	if got := provider.upstreamModel("meta-llama/llama-3.3-70b-instruct"); got != "meta-llama/llama-3.3-70b-instruct:free" {
+		t.Errorf("upstreamModel() = %q, want the :free variant", got)
+	}
+	if got := provider.upstreamModel("glm-4.5-air"); got != "z-ai/glm-4.5-air:free" {
+		t.Errorf("upstreamModel(alias) = %q, want z-ai/glm-4.5-air:free", got)
+	}
+
+	for _, m := range provider.GetSupportedModels() {
+		if m.ID == "qwen/qwen3-4b" {
+			t.Error("Expected models missing from the catalog to be dropped after sync")
+		}
+		if m.ID == "meta-llama/llama-3.3-70b-instruct" && (m.ContextWindow != 65536 || !m.IsAvailable) {
+			t.Errorf("Unexpected synced model info: %+v", m)
+		}
+	}
+	if provider.LastSynced().IsZero() {
+		t.Error("Expected LastSynced to be set")
+	}
+}
+
+func TestOpenRouterProvider_SyncModels_KeepsListOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	provider := NewOpenRouterProvider("test-key", server.URL, []models.ModelInfo{{ID: "qwen/qwen3-4b"}})
+	if _, err := provider.SyncModels(context.Background()); err == nil {
+		t.Fatal("Expected an error for a failed sync")
+	}
+	if got := provider.GetSupportedModels(); len(got) != 1 || got[0].ID != "qwen/qwen3-4b" {
+		t.Errorf("Expected seed list to be kept, got %+v", got)
+	}
+}
+
+func TestOpenRouterProvider_ChatCompletion_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "qwen/qwen3-4b:free" {
+			t.Errorf("Expected upstream model qwen/qwen3-4b:free, got %v", body["model"])
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(": OPENROUTER PROCESSING\n\n"))
+		w.Write([]byte(`data: {"id":"gen-1","object":"chat.completion.chunk","created":1234567890,"model":"qwen/qwen3-4b:free","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}` + "\n\n"))
+		w.Write([]byte(`data: {"id":"gen-1","object":"chat.completion.chunk","created":1234567890,"model":"qwen/qwen3-4b:free","choices":[],"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewOpenRouterProvider("test-key", server.URL, []models.ModelInfo{{ID: "qwen/qwen3-4b"}})
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "qwen/qwen3-4b",
+		Messages: []models.Message{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var content string
+	var usage *models.TokenUsage
+	for event := range eventChan {
+		switch event.Type {
+		case "content":
+			content += event.Content
+		case "usage":
+			usage = event.Tokens
+		case "error":
+			t.Fatalf("Unexpected error event: %s", event.Error)
+		}
+	}
+	if content != "Hi" {
+		t.Errorf("content = %q, want %q", content, "Hi")
+	}
+	if usage == nil || usage.TotalTokens != 9 {
+		t.Errorf("usage = %+v, want total 9", usage)
+	}
+}
+
+func TestOpenRouterProvider_ChatCompletion_MidStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`data: {"error":{"code":502,"message":"Upstream provider error"}}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewOpenRouterProvider("test-key", server.URL, nil)
+	eventChan, err := provider.ChatCompletion(context.Background(), &models.ChatRequest{
+		Model:    "qwen/qwen3-4b",
+		Messages: []models.Message{{Role: "user", Content: "Hello"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	var gotError string
+	for event := range eventChan {
+		if event.Type == "error" {
+			gotError = event.Error
+		}
+	}
+	if !strings.Contains(gotError, "PROVIDER_ERROR") {
+		t.Errorf("Expected PROVIDER_ERROR event, got %q", gotError)
+	}
+}
+
+func TestOpenRouterProvider_Embeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("Expected /embeddings, got %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "openai/text-embedding-3-small" {
+			t.Errorf("Unexpected upstream model %v", body["model"])
+		}
+		w.Write([]byte(`{"object":"list","model":"text-embedding-3-small","data":[{"object":"embedding","index":0,"embedding":[0.5]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenRouterProvider("test-key", server.URL, nil).Embeddings(context.Background(), &models.EmbeddingRequest{
+		Model: "openai/text-embedding-3-small",
+		Input: "hello",
+	})
+	if err != nil {
+		t.Fatalf("Embeddings() error = %v", err)
+	}
+	if resp.Model != "openai/text-embedding-3-small" {
+		t.Errorf("model = %q, want the requested model", resp.Model)
+	}
+	if len(resp.Data) != 1 || resp.Usage.TotalTokens != 2 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+}
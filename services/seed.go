@@ -0,0 +1,362 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// SeedFile declares the admins, custom providers, model aliases, pricing and feature
+// flags a deployment must have. Seeding only creates what is missing; existing rows
+// and settings changed by hand are left alone unless Force (or the entry's own force) is set.
+type SeedFile struct {
+	Force        bool              `yaml:"force"`
+	Admins       []SeedAdmin       `yaml:"admins"`
+	Providers    []SeedProvider    `yaml:"providers"`
+	ModelAliases map[string]string `yaml:"model_aliases"`
+	Pricing      []SeedPricing     `yaml:"pricing"`
+	FeatureFlags map[string]bool   `yaml:"feature_flags"`
+}
+
+// SeedAdmin ensures an admin account exists. The password may be read from an
+// environment variable so the seed file itself can be committed.
+type SeedAdmin struct {
+	Username    string `yaml:"username"`
+	Email       string `yaml:"email"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+	Force       bool   `yaml:"force"`
+}
+
+// SeedProvider ensures a custom OpenAI-compatible upstream exists
+type SeedProvider struct {
+	Name      string   `yaml:"name"`
+	BaseURL   string   `yaml:"base_url"`
+	APIKey    string   `yaml:"api_key"`
+	APIKeyEnv string   `yaml:"api_key_env"`
+	Models    []string `yaml:"models"`
+	Priority  int      `yaml:"priority"`
+	Active    *bool    `yaml:"active"` // Defaults to true
+	Force     bool     `yaml:"force"`
+}
+
+// SeedPricing ensures a model price exists (USD / 1M tokens)
+type SeedPricing struct {
+	Model       string  `yaml:"model"`
+	Provider    string  `yaml:"provider"`
+	InputPrice  float64 `yaml:"input_price"`
+	OutputPrice float64 `yaml:"output_price"`
+	Force       bool    `yaml:"force"`
+}
+
+// SeedResult lists what a seed run did, as "section:name" entries
+type SeedResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+func (r *SeedResult) record(created, updated bool, section, name string) {
+	entry := section + ":" + name
+	switch {
+	case created:
+		r.Created = append(r.Created, entry)
+	case updated:
+		r.Updated = append(r.Updated, entry)
+	default:
+		r.Skipped = append(r.Skipped, entry)
+	}
+}
+
+// LoadSeedFile reads and validates a YAML seed file. Unknown keys are rejected so
+// typos fail the deployment instead of being silently ignored.
+func LoadSeedFile(path string) (*SeedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := &SeedFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(seed); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := seed.resolve(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return seed, nil
+}
+
+// resolve normalizes entries, reads secrets from the environment and validates the seed
+func (s *SeedFile) resolve() error {
+	for i := range s.Admins {
+		a := &s.Admins[i]
+		a.Username = strings.TrimSpace(a.Username)
+		a.Email = strings.TrimSpace(a.Email)
+		if a.Username == "" || a.Email == "" {
+			return fmt.Errorf("admins[%d]: username and email are required", i)
+		}
+		if a.PasswordEnv != "" {
+			a.Password = os.Getenv(a.PasswordEnv)
+		}
+		if a.Password == "" {
+			return fmt.Errorf("admin %s: password or password_env is required", a.Username)
+		}
+	}
+
+	for i := range s.Providers {
+		p := &s.Providers[i]
+		p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+		if msg := ValidateCustomProviderName(p.Name); msg != "" {
+			return fmt.Errorf("providers[%d]: %s", i, msg)
+		}
+		p.BaseURL = strings.TrimSuffix(strings.TrimSpace(p.BaseURL), "/")
+		if u, err := url.Parse(p.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("provider %s: base_url must be an http(s) URL", p.Name)
+		}
+		if p.APIKeyEnv != "" {
+			p.APIKey = os.Getenv(p.APIKeyEnv)
+		}
+		if len(p.Models) == 0 {
+			return fmt.Errorf("provider %s: models must list at least one model", p.Name)
+		}
+	}
+
+	for i := range s.Pricing {
+		p := &s.Pricing[i]
+		p.Model = strings.TrimSpace(p.Model)
+		if p.Model == "" {
+			return fmt.Errorf("pricing[%d]: model is required", i)
+		}
+		if p.InputPrice < 0 || p.OutputPrice < 0 {
+			return fmt.Errorf("pricing %s: prices must not be negative", p.Model)
+		}
+		if p.Provider == "" {
+			p.Provider = GetProviderFromModel(p.Model)
+		}
+	}
+
+	for name := range s.FeatureFlags {
+		if name != FeatureQuotaEnabled && name != FeatureUsageTrackingEnabled {
+			return fmt.Errorf("unknown feature flag: %s", name)
+		}
+	}
+	return nil
+}
+
+// ApplySeedFile loads the seed file at path and applies it. force overrides every
+// entry's force setting (SEED_FORCE).
+func ApplySeedFile(cfg *config.Config, path string, force bool) (*SeedResult, error) {
+	seed, err := LoadSeedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if force {
+		seed.Force = true
+	}
+
+	result, err := ApplySeed(cfg, seed)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"file":    path,
+		"created": len(result.Created),
+		"updated": len(result.Updated),
+		"skipped": len(result.Skipped),
+	}).Info("Seed file applied")
+	return result, nil
+}
+
+// ApplySeed creates the missing entries of seed and, for forced entries, overwrites
+// existing ones. It must run after the database and data encryption are initialized.
+func ApplySeed(cfg *config.Config, seed *SeedFile) (*SeedResult, error) {
+	result := &SeedResult{Created: []string{}, Updated: []string{}, Skipped: []string{}}
+
+	for _, a := range seed.Admins {
+		created, updated, err := seedAdmin(a, seed.Force || a.Force)
+		if err != nil {
+			return nil, fmt.Errorf("seed admin %s: %w", a.Username, err)
+		}
+		result.record(created, updated, "admin", a.Username)
+	}
+
+	if len(seed.Providers) > 0 {
+		existing, err := database.ListCustomProviders(false)
+		if err != nil {
+			return nil, fmt.Errorf("list custom providers: %w", err)
+		}
+		byName := make(map[string]*database.CustomProvider, len(existing))
+		for _, p := range existing {
+			byName[p.Name] = p
+		}
+		for _, p := range seed.Providers {
+			created, updated, err := seedProvider(p, byName[p.Name], seed.Force || p.Force)
+			if err != nil {
+				return nil, fmt.Errorf("seed provider %s: %w", p.Name, err)
+			}
+			result.record(created, updated, "provider", p.Name)
+		}
+	}
+
+	if len(seed.Pricing) > 0 {
+		records, err := database.ListModelPricing()
+		if err != nil {
+			return nil, fmt.Errorf("list model pricing: %w", err)
+		}
+		priced := make(map[string]bool, len(records))
+		for _, r := range records {
+			priced[r.Model] = true
+		}
+		for _, p := range seed.Pricing {
+			record := &database.ModelPricingRecord{
+				Model:       p.Model,
+				Provider:    p.Provider,
+				InputPrice:  p.InputPrice,
+				OutputPrice: p.OutputPrice,
+			}
+			switch {
+			case !priced[p.Model]:
+				err = database.SeedModelPricing([]*database.ModelPricingRecord{record})
+			case seed.Force || p.Force:
+				err = database.UpsertModelPricing(record)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("seed pricing %s: %w", p.Model, err)
+			}
+			result.record(!priced[p.Model], priced[p.Model] && (seed.Force || p.Force), "pricing", p.Model)
+		}
+	}
+
+	aliasesChanged, err := seedSettingMap(settingKeyModelAliases, config.GetModelAliases(), seed.ModelAliases, seed.Force, "model_alias", result)
+	if err != nil {
+		return nil, err
+	}
+	flagsChanged, err := seedSettingMap(settingKeyFeatureFlags, map[string]bool{}, seed.FeatureFlags, seed.Force, "feature_flag", result)
+	if err != nil {
+		return nil, err
+	}
+	if aliasesChanged || flagsChanged {
+		ApplyStoredConfig(cfg)
+	}
+	return result, nil
+}
+
+// seedAdmin creates the admin account if missing; when forced, an existing account
+// is promoted to admin and its password reset
+func seedAdmin(a SeedAdmin, force bool) (created, updated bool, err error) {
+	user, err := database.GetUserByUsername(a.Username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		if _, err := database.CreateUser(a.Username, a.Email, a.Password, "admin"); err != nil {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+	if err != nil || !force {
+		return false, false, err
+	}
+
+	if user.Role != "admin" {
+		if err := database.UpdateUserRole(user.ID, "admin"); err != nil {
+			return false, false, err
+		}
+	}
+	if err := database.UpdateUserPassword(user.ID, a.Password); err != nil {
+		return false, false, err
+	}
+	return false, true, nil
+}
+
+// seedProvider creates the custom provider if missing; when forced, an existing one
+// is overwritten (keeping its API key if the seed does not provide one)
+func seedProvider(p SeedProvider, existing *database.CustomProvider, force bool) (created, updated bool, err error) {
+	active := p.Active == nil || *p.Active
+	if existing == nil {
+		provider := &database.CustomProvider{
+			Name:     p.Name,
+			BaseURL:  p.BaseURL,
+			APIKey:   p.APIKey,
+			Models:   p.Models,
+			Priority: p.Priority,
+			IsActive: active,
+		}
+		if err := database.CreateCustomProvider(provider); err != nil {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+	if !force {
+		return false, false, nil
+	}
+
+	existing.BaseURL = p.BaseURL
+	if p.APIKey != "" {
+		existing.APIKey = p.APIKey
+	}
+	existing.Models = p.Models
+	existing.Priority = p.Priority
+	existing.IsActive = active
+	if err := database.UpdateCustomProvider(existing); err != nil {
+		return false, false, err
+	}
+	return false, true, nil
+}
+
+// seedSettingMap merges seeded entries into a JSON map setting. Keys already in the
+// stored setting (or in fallback when nothing is stored yet) are kept unless forced.
+func seedSettingMap[V comparable](key string, fallback, seeded map[string]V, force bool, section string, result *SeedResult) (bool, error) {
+	if len(seeded) == 0 {
+		return false, nil
+	}
+
+	current := make(map[string]V)
+	err := database.GetJSONSetting(key, &current)
+	if errors.Is(err, database.ErrSettingNotFound) {
+		for k, v := range fallback {
+			current[k] = v
+		}
+	} else if err != nil {
+		return false, fmt.Errorf("read setting %s: %w", key, err)
+	}
+
+	names := make([]string, 0, len(seeded))
+	for name := range seeded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		value, exists := current[name]
+		switch {
+		case !exists:
+			current[name] = seeded[name]
+			changed = true
+			result.record(true, false, section, name)
+		case force && value != seeded[name]:
+			current[name] = seeded[name]
+			changed = true
+			result.record(false, true, section, name)
+		default:
+			result.record(false, false, section, name)
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := database.SetJSONSetting(key, current); err != nil {
+		return false, fmt.Errorf("save setting %s: %w", key, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Signed token purposes. A token only verifies for the purpose it was issued for
+const (
+	TokenPurposeUsageExport       = "usage_export"       // One-time download link for the admin usage CSV export
+	TokenPurposeConversationShare = "conversation_share" // Public read-only link to a shared conversation
+	TokenPurposeTwoFactorLogin    = "two_factor_login"   // Password verified, waiting for the second factor
+	TokenPurposeStreamResume      = "stream_resume"      // Reconnect to a chat reply streamed over SSE
+)
+
+var (
+	ErrSignedTokenInvalid  = errors.New("token is malformed or has an invalid signature")
+	ErrSignedTokenPurpose  = errors.New("token was issued for a different purpose")
+	ErrSignedTokenExpired  = errors.New("token has expired")
+	ErrSignedTokenRevoked  = errors.New("token has been revoked")
+	ErrSignedTokenReplayed = errors.New("token has already been used")
+)
+
+// SignedTokenClaims is the payload carried inside a signed token
+type SignedTokenClaims struct {
+	ID        string `json:"jti"`
+	Purpose   string `json:"pur"`
+	Subject   string `json:"sub,omitempty"` // What the token grants access to, e.g. a share ID or export query
+	UserID    int64  `json:"uid,omitempty"`
+	SingleUse bool   `json:"once,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Expiry returns the expiry time of the token
+func (c *SignedTokenClaims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// SignedTokenService issues and verifies HMAC-signed, expiring tokens for share
+// links, download links and stream resumption. Tokens are stateless; the database
+// only records single-use tokens that were consumed and revoked tokens until they expire
+type SignedTokenService struct {
+	secret   []byte
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+var signedTokenService *SignedTokenService
+
+// InitSignedTokenService creates the singleton service. When secret is empty a
+// random secret is generated once and kept in the settings table, so tokens stay
+// valid across restarts and instances sharing the database
+func InitSignedTokenService(secret string) (*SignedTokenService, error) {
+	if secret == "" {
+		err := database.GetJSONSetting(database.SettingKeyTokenSigningSecret, &secret)
+		if err == database.ErrSettingNotFound {
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err != nil {
+				return nil, err
+			}
+			secret = hex.EncodeToString(b)
+			if err := database.SetJSONSetting(database.SettingKeyTokenSigningSecret, secret); err != nil {
+				return nil, err
+			}
+			logrus.Info("Generated token signing secret")
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	signedTokenService = &SignedTokenService{
+		secret:   []byte(secret),
+		stopChan: make(chan struct{}),
+	}
+	return signedTokenService, nil
+}
+
+// GetSignedTokenService returns the singleton service, or nil if it was not initialized
+func GetSignedTokenService() *SignedTokenService {
+	return signedTokenService
+}
+
+// Start purges records of expired tokens once an hour
+func (s *SignedTokenService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := database.PurgeExpiredSpentTokens(); err != nil {
+					logrus.WithError(err).Warn("Failed to purge expired signed tokens")
+				} else if n > 0 {
+					logrus.Debugf("Purged %d expired signed token records", n)
+				}
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the purge loop
+func (s *SignedTokenService) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// Issue creates a token for purpose and subject that expires after ttl.
+// Single-use tokens are rejected with ErrSignedTokenReplayed after the first Consume
+func (s *SignedTokenService) Issue(purpose, subject string, userID int64, ttl time.Duration, singleUse bool) (string, *SignedTokenClaims, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims := &SignedTokenClaims{
+		ID:        hex.EncodeToString(id),
+		Purpose:   purpose,
+		Subject:   subject,
+		UserID:    userID,
+		SingleUse: singleUse,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), claims, nil
+}
+
+// sign returns the URL-safe HMAC-SHA256 signature of the encoded payload
+func (s *SignedTokenService) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse checks the signature and decodes the claims without checking expiry
+func (s *SignedTokenService) parse(token string) (*SignedTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, ErrSignedTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrSignedTokenInvalid
+	}
+	claims := &SignedTokenClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil || claims.ID == "" {
+		return nil, ErrSignedTokenInvalid
+	}
+	return claims, nil
+}
+
+// Verify checks the signature, purpose, expiry and revocation of a token without
+// consuming it; single-use tokens that were already consumed fail with ErrSignedTokenReplayed
+func (s *SignedTokenService) Verify(token, purpose string) (*SignedTokenClaims, error) {
+	claims, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, ErrSignedTokenPurpose
+	}
+	if time.Now().After(claims.Expiry()) {
+		return nil, ErrSignedTokenExpired
+	}
+
+	reason, err := database.GetTokenSpentReason(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch reason {
+	case database.SpentTokenRevoked:
+		return nil, ErrSignedTokenRevoked
+	case database.SpentTokenUsed:
+		return nil, ErrSignedTokenReplayed
+	}
+	return claims, nil
+}
+
+// Consume verifies a token and, for single-use tokens, atomically marks it used so
+// a concurrent or later replay fails with ErrSignedTokenReplayed
+func (s *SignedTokenService) Consume(token, purpose string) (*SignedTokenClaims, error) {
+	claims, err := s.Verify(token, purpose)
+	if err != nil || !claims.SingleUse {
+		return claims, err
+	}
+	first, err := database.MarkTokenSpent(claims.ID, claims.Purpose, database.SpentTokenUsed, claims.Expiry())
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return nil, ErrSignedTokenReplayed
+	}
+	return claims, nil
+}
+
+// Revoke invalidates a token before it expires. Revoking an expired token is a no-op
+func (s *SignedTokenService) Revoke(token string) (*SignedTokenClaims, error) {
+	claims, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.RevokeID(claims.ID, claims.Purpose, claims.Expiry()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// RevokeID invalidates a token by its ID, for callers that kept the ID and expiry of a token
+// they issued rather than the token itself. Revoking an expired token is a no-op
+func (s *SignedTokenService) RevokeID(id, purpose string, expiresAt time.Time) error {
+	if time.Now().After(expiresAt) {
+		return nil
+	}
+	_, err := database.MarkTokenSpent(id, purpose, database.SpentTokenRevoked, expiresAt)
+	return err
+}
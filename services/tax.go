@@ -0,0 +1,70 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidCountry 无效的国家代码
+var ErrInvalidCountry = errors.New("invalid country code")
+
+// NormalizeCountry 规范化 ISO 3166-1 alpha-2 国家代码（空字符串表示未设置）
+func NormalizeCountry(country string) (string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		return "", nil
+	}
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return "", ErrInvalidCountry
+	}
+	return country, nil
+}
+
+// GetTaxRates 返回管理员配置的税率表，未配置时为空
+func GetTaxRates() map[string]float64 {
+	rates, err := database.GetTaxRates()
+	if err != nil {
+		if err != database.ErrSettingNotFound {
+			logrus.WithError(err).Warn("Failed to load tax rates")
+		}
+		return map[string]float64{}
+	}
+	return rates
+}
+
+// UpdateTaxRates 校验并保存税率表
+func UpdateTaxRates(rates map[string]float64) error {
+	cleaned := make(map[string]float64, len(rates))
+	for country, rate := range rates {
+		normalized, err := NormalizeCountry(country)
+		if err != nil || normalized == "" {
+			return ErrInvalidCountry
+		}
+		if rate < 0 || rate >= 1 {
+			return errors.New("tax rate must be between 0 and 1")
+		}
+		cleaned[normalized] = rate
+	}
+	return database.SaveTaxRates(cleaned)
+}
+
+// CalculateTax 按用户所在国家计算充值金额的税额
+// 返回 (税率, 税额)，税额保留 6 位小数，与余额精度一致
+func CalculateTax(userID int64, amount float64) (float64, float64) {
+	if amount <= 0 {
+		return 0, 0
+	}
+	info, err := database.GetUserTaxInfo(userID)
+	if err != nil || info.Country == "" {
+		return 0, 0
+	}
+	rate, ok := GetTaxRates()[info.Country]
+	if !ok || rate <= 0 {
+		return 0, 0
+	}
+	return rate, math.Round(amount*rate*1e6) / 1e6
+}
@@ -256,6 +256,33 @@ func (te *ToolExecutor) getToolChoicePrompt(toolChoice interface{}) string {
 	return ""
 }
 
+// BuildFunctionToolPrompt 为 OpenAI function 工具（Responses API）构建工具提示
+// tool_choice 为 "none" 时不注入；{"type":"function","name":...} 按指定工具处理
+func (te *ToolExecutor) BuildFunctionToolPrompt(tools []models.Tool, toolChoice interface{}) string {
+	if toolChoice == "none" {
+		return ""
+	}
+	claudeTools := make([]models.ClaudeTool, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		claudeTools = append(claudeTools, models.ClaudeTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+	if len(claudeTools) == 0 {
+		return ""
+	}
+
+	if choice, ok := toolChoice.(map[string]interface{}); ok && choice["type"] == "function" {
+		toolChoice = map[string]interface{}{"type": "tool", "name": choice["name"]}
+	}
+	return te.getToolChoicePrompt(toolChoice) + te.BuildToolSystemPrompt(claudeTools)
+}
+
 // ConvertToolResultToMessage 将工具结果转换为消息格式
 func (te *ToolExecutor) ConvertToolResultToMessage(toolResult *models.ClaudeToolResult) string {
 	var content string
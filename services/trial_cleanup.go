@@ -0,0 +1,61 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/middleware"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// trialCleanupInterval 清理过期匿名试用密钥的间隔
+const trialCleanupInterval = 10 * time.Minute
+
+// TrialKeyCleaner periodically drops expired anonymous trial keys from memory and
+// deletes their records once the conversion-tracking retention has passed
+type TrialKeyCleaner struct {
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewTrialKeyCleaner creates a cleaner; call Start to run it
+func NewTrialKeyCleaner() *TrialKeyCleaner {
+	return &TrialKeyCleaner{stopChan: make(chan struct{})}
+}
+
+// Start runs the cleanup loop
+func (t *TrialKeyCleaner) Start() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(trialCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.cleanup()
+			case <-t.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// cleanup performs one cleanup pass
+func (t *TrialKeyCleaner) cleanup() {
+	if n := middleware.PurgeExpiredTrialKeys(); n > 0 {
+		logrus.Debugf("Dropped %d expired trial keys from memory", n)
+	}
+	if n, err := database.PurgeExpiredTrialKeys(); err != nil {
+		logrus.WithError(err).Warn("Failed to purge expired trial keys")
+	} else if n > 0 {
+		logrus.Debugf("Purged %d expired trial key records", n)
+	}
+}
+
+// Stop stops the cleanup loop
+func (t *TrialKeyCleaner) Stop() {
+	close(t.stopChan)
+	t.wg.Wait()
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// turnstileVerifyURL Turnstile 服务端验证地址
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
 // TurnstileService Cloudflare Turnstile 验证服务
 type TurnstileService struct {
 	secretKey string
@@ -68,7 +71,7 @@ func (s *TurnstileService) VerifyToken(token, remoteIP string) (bool, error) {
 	}
 
 	resp, err := s.client.Post(
-		"https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		turnstileVerifyURL,
 		"application/json",
 		bytes.NewBuffer(jsonData),
 	)
@@ -95,3 +98,37 @@ func (s *TurnstileService) VerifyToken(token, remoteIP string) (bool, error) {
 	logrus.Infof("Turnstile verification successful for IP: %s", remoteIP)
 	return true, nil
 }
+
+// Probe 检查 Turnstile 服务是否可达以及密钥是否被接受，用于配置自检
+// 使用一个无效的 token 请求验证接口，只要返回的错误不是密钥无效即视为通过
+func (s *TurnstileService) Probe() error {
+	if s.secretKey == "" {
+		return fmt.Errorf("turnstile secret key not configured")
+	}
+
+	jsonData, err := json.Marshal(TurnstileVerifyRequest{
+		Secret:   s.secretKey,
+		Response: "doctor-probe",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := s.client.Post(turnstileVerifyURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("turnstile unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var verifyResp TurnstileVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&verifyResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for _, code := range verifyResp.ErrorCodes {
+		if code == "invalid-input-secret" || code == "missing-input-secret" {
+			return fmt.Errorf("turnstile rejected the secret key: %s", code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"Curry2API-go/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// TwoFactorIssuer is the issuer shown in authenticator apps
+const TwoFactorIssuer = "Curry2API"
+
+// BackupCodeCount is the number of backup codes issued at a time
+const BackupCodeCount = 10
+
+var (
+	ErrTwoFactorCodeInvalid = errors.New("invalid two-factor code")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+)
+
+// TwoFactorSetup is returned when a user starts enrolling an authenticator
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// backupCodeAlphabet omits characters that are easy to misread (0/O, 1/I/L)
+const backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateBackupCodes returns fresh backup codes formatted as xxxxx-xxxxx and their hashes
+func GenerateBackupCodes() ([]string, []string, error) {
+	codes := make([]string, BackupCodeCount)
+	hashes := make([]string, BackupCodeCount)
+	alphabetSize := big.NewInt(int64(len(backupCodeAlphabet)))
+	for i := range codes {
+		var sb strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return nil, nil, err
+			}
+			sb.WriteByte(backupCodeAlphabet[n.Int64()])
+		}
+		codes[i] = sb.String()
+		hashes[i] = HashBackupCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// HashBackupCode normalizes a backup code (case, dashes, spaces) and returns its SHA-256 hex digest
+func HashBackupCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeginTwoFactorSetup generates a new secret for the user and stores it unconfirmed.
+// It replaces any unfinished setup and fails if 2FA is already enabled.
+func BeginTwoFactorSetup(user *database.User) (*TwoFactorSetup, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SaveTwoFactorSecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	return &TwoFactorSetup{
+		Secret: secret,
+		URI:    utils.TOTPProvisioningURI(TwoFactorIssuer, account, secret),
+	}, nil
+}
+
+// ConfirmTwoFactorSetup enables 2FA once the user proves the authenticator works, and
+// returns the backup codes. The codes are only shown this once.
+func ConfirmTwoFactorSetup(userID int64, code string) ([]string, error) {
+	tf, err := database.GetUserTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled {
+		return nil, database.ErrTwoFactorAlreadyEnabled
+	}
+	step, ok := utils.ValidateTOTP(tf.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrTwoFactorCodeInvalid
+	}
+
+	codes, hashes, err := GenerateBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.EnableTwoFactor(userID, hashes, step); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactorCode checks an authenticator code or, failing that, a backup code.
+// Each authenticator code and each backup code is accepted only once. It returns
+// "totp" or "backup_code" to tell which kind was used.
+func VerifyTwoFactorCode(userID int64, code string) (string, error) {
+	tf, err := database.GetUserTwoFactor(userID)
+	if err == database.ErrTwoFactorNotFound || (err == nil && !tf.Enabled) {
+		return "", ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if step, ok := utils.ValidateTOTP(tf.Secret, code, time.Now()); ok {
+		accepted, err := database.MarkTwoFactorStep(userID, step)
+		if err != nil {
+			return "", err
+		}
+		if !accepted {
+			return "", ErrTwoFactorCodeInvalid
+		}
+		return "totp", nil
+	}
+
+	used, err := database.ConsumeTwoFactorBackupCode(userID, HashBackupCode(code))
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", ErrTwoFactorCodeInvalid
+	}
+	return "backup_code", nil
+}
+
+// RegenerateBackupCodes replaces all backup codes of a user with a fresh set
+func RegenerateBackupCodes(userID int64) ([]string, error) {
+	codes, hashes, err := GenerateBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SetTwoFactorBackupCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UsageTokenMigrationJob is the /admin/jobs name of the usage_records token fingerprint migration
+const UsageTokenMigrationJob = "usage_token_fingerprint"
+
+const (
+	usageTokenMigrationBatch = 1000                   // Rows per UPDATE, keeps each statement short
+	usageTokenMigrationPause = 100 * time.Millisecond // Pause between batches so request traffic is not starved
+)
+
+// StartUsageTokenMigration replaces the plaintext API tokens left in historical
+// usage_records with fingerprints, in the background. Progress is saved after every
+// batch, so an interrupted migration resumes where it stopped on the next start.
+// Records written since the fingerprint change already store fingerprints
+func StartUsageTokenMigration() {
+	state, err := database.GetUsageTokenMigrationState()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load usage token migration state")
+		return
+	}
+	if state != nil && state.Done {
+		return
+	}
+	if state == nil {
+		maxID, err := database.GetMaxUsageRecordID()
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to start usage token migration")
+			return
+		}
+		state = &database.UsageTokenMigrationState{MaxID: maxID, StartedAt: time.Now()}
+	}
+	go runUsageTokenMigration(state)
+}
+
+func runUsageTokenMigration(state *database.UsageTokenMigrationState) {
+	job := StartJob(UsageTokenMigrationJob, state.MaxID)
+	job.Update(state.LastID, state.MaxID, fmt.Sprintf("%d tokens replaced", state.Migrated))
+	logrus.WithFields(logrus.Fields{
+		"last_id": state.LastID,
+		"max_id":  state.MaxID,
+	}).Info("Usage token fingerprint migration started")
+
+	for state.LastID < state.MaxID {
+		upTo := state.LastID + usageTokenMigrationBatch
+		if upTo > state.MaxID {
+			upTo = state.MaxID
+		}
+		n, err := database.FingerprintUsageTokens(state.LastID, upTo)
+		if err == nil {
+			state.LastID = upTo
+			state.Migrated += n
+			err = database.SaveUsageTokenMigrationState(state)
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("last_id", state.LastID).Error("Usage token fingerprint migration stopped, it resumes on the next start")
+			job.Finish(err)
+			return
+		}
+		job.Update(state.LastID, state.MaxID, fmt.Sprintf("%d tokens replaced", state.Migrated))
+		time.Sleep(usageTokenMigrationPause)
+	}
+
+	now := time.Now()
+	state.Done = true
+	state.FinishedAt = &now
+	if err := database.SaveUsageTokenMigrationState(state); err != nil {
+		logrus.WithError(err).Error("Failed to save usage token migration state")
+		job.Finish(err)
+		return
+	}
+	job.Finish(nil)
+	logrus.WithField("migrated", state.Migrated).Info("Usage token fingerprint migration completed")
+}
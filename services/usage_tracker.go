@@ -6,9 +6,12 @@ import (
 	"time"
 
 	"Curry2API-go/database"
-	"github.com/sirupsen/logrus"
+	"Curry2API-go/utils"
 )
 
+// usageTrackerLog logs the asynchronous usage record writer
+var usageTrackerLog = utils.SubsystemLogger(utils.LogSubsystemUsageTracker)
+
 // UsageTrackerError represents an error from the usage tracker
 type UsageTrackerError struct {
 	Message string
@@ -35,6 +38,7 @@ type UsageRecord struct {
 	APIToken         string
 	TokenName        string
 	Model            string
+	Provider         string
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
@@ -44,6 +48,8 @@ type UsageRecord struct {
 	RequestTime      time.Time
 	ResponseTime     time.Time
 	Duration         time.Duration
+	Seed             *int64 // Sampling seed sent to the provider, nil when none
+	Language         string // Detected prompt language, empty when not detected
 }
 
 // UsageTracker manages asynchronous usage tracking
@@ -85,9 +91,9 @@ func NewUsageTracker(config *UsageTrackerConfig) *UsageTracker {
 	if config.Enabled {
 		tracker.wg.Add(1)
 		go tracker.processRecords()
-		logrus.Info("Usage tracker started")
+		usageTrackerLog.Info("Usage tracker started")
 	} else {
-		logrus.Info("Usage tracking is disabled")
+		usageTrackerLog.Info("Usage tracking is disabled")
 	}
 
 	return tracker
@@ -129,7 +135,7 @@ func (ut *UsageTracker) TrackUsage(record *UsageRecord) error {
 		return nil
 	default:
 		// Channel is full, log and drop the record to prevent blocking
-		logrus.Warn("Usage tracking channel full, dropping record")
+		usageTrackerLog.Warn("Usage tracking channel full, dropping record")
 		return ErrChannelFull
 	}
 }
@@ -164,7 +170,7 @@ func (ut *UsageTracker) processRecords() {
 		case <-ut.stopChan:
 			// Graceful shutdown: flush remaining records
 			if len(batch) > 0 {
-				logrus.Infof("Flushing %d remaining records before shutdown", len(batch))
+				usageTrackerLog.Infof("Flushing %d remaining records before shutdown", len(batch))
 				ut.flushBatch(batch)
 			}
 			return
@@ -189,6 +195,7 @@ func (ut *UsageTracker) flushBatch(batch []*UsageRecord) {
 			APIToken:         record.APIToken,
 			TokenName:        record.TokenName,
 			Model:            record.Model,
+			Provider:         record.Provider,
 			PromptTokens:     record.PromptTokens,
 			CompletionTokens: record.CompletionTokens,
 			TotalTokens:      record.TotalTokens,
@@ -198,6 +205,8 @@ func (ut *UsageTracker) flushBatch(batch []*UsageRecord) {
 			RequestTime:      record.RequestTime,
 			ResponseTime:     record.ResponseTime,
 			DurationMs:       int(record.Duration.Milliseconds()),
+			Seed:             record.Seed,
+			Language:         record.Language,
 		}
 	}
 
@@ -207,7 +216,7 @@ func (ut *UsageTracker) flushBatch(batch []*UsageRecord) {
 		if attempt > 0 {
 			// Calculate backoff duration
 			backoff := time.Duration(ut.config.RetryBackoffMs) * time.Millisecond * time.Duration(1<<uint(attempt-1))
-			logrus.Infof("Retrying batch write (attempt %d/%d) after %v", attempt+1, ut.config.MaxRetries, backoff)
+			usageTrackerLog.Infof("Retrying batch write (attempt %d/%d) after %v", attempt+1, ut.config.MaxRetries, backoff)
 			time.Sleep(backoff)
 		}
 
@@ -215,17 +224,17 @@ func (ut *UsageTracker) flushBatch(batch []*UsageRecord) {
 		if err == nil {
 			// Success
 			duration := time.Since(startTime)
-			logrus.Infof("Successfully flushed batch of %d records in %v", len(batch), duration)
+			usageTrackerLog.Infof("Successfully flushed batch of %d records in %v", len(batch), duration)
 			return
 		}
 
 		lastErr = err
-		logrus.Warnf("Failed to flush batch (attempt %d/%d): %v", attempt+1, ut.config.MaxRetries, err)
+		usageTrackerLog.Warnf("Failed to flush batch (attempt %d/%d): %v", attempt+1, ut.config.MaxRetries, err)
 	}
 
 	// All retries failed
-	logrus.Errorf("Failed to flush batch after %d attempts: %v", ut.config.MaxRetries, lastErr)
-	logrus.Errorf("Lost %d usage records - manual recovery may be required", len(batch))
+	usageTrackerLog.Errorf("Failed to flush batch after %d attempts: %v", ut.config.MaxRetries, lastErr)
+	usageTrackerLog.Errorf("Lost %d usage records - manual recovery may be required", len(batch))
 }
 
 // Shutdown gracefully shuts down the usage tracker
@@ -234,10 +243,10 @@ func (ut *UsageTracker) Shutdown() {
 		return
 	}
 
-	logrus.Info("Shutting down usage tracker...")
+	usageTrackerLog.Info("Shutting down usage tracker...")
 	close(ut.stopChan)
 	ut.wg.Wait()
-	logrus.Info("Usage tracker shut down complete")
+	usageTrackerLog.Info("Usage tracker shut down complete")
 }
 
 // ErrChannelFull is returned when the tracking channel is full
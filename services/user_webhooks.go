@@ -0,0 +1,400 @@
+package services
+
+import (
+	"Curry2API-go/config"
+	"Curry2API-go/database"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Events delivered to user webhooks
+const (
+	UserWebhookEventBatchCompleted = "batch.completed"
+	UserWebhookEventTest           = "webhook.test"
+)
+
+// Headers sent with every user webhook delivery
+const (
+	UserWebhookSignatureHeader = "X-Curry2API-Signature" // t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">
+	UserWebhookEventHeader     = "X-Curry2API-Event"
+	UserWebhookDeliveryHeader  = "X-Curry2API-Delivery"
+)
+
+const (
+	userWebhookPollInterval = 15 * time.Second // How often due retries are picked up
+	userWebhookClaimBatch   = 20               // Deliveries claimed per pass
+	userWebhookTimeout      = 10 * time.Second
+)
+
+// userWebhookRetryDelays is the wait before each retry; a delivery fails for good once they are used up
+var userWebhookRetryDelays = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+}
+
+// userWebhookEventTitles are the email subjects used when a notification falls back to email
+var userWebhookEventTitles = map[string]string{
+	UserWebhookEventBatchCompleted: "批处理已完成",
+	UserWebhookEventTest:           "Webhook 测试",
+}
+
+// UserWebhookEvent is the JSON body POSTed to a user webhook
+type UserWebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt int64       `json:"created_at"`
+	Summary   string      `json:"summary"`
+	Link      string      `json:"link,omitempty"` // Where the result can be downloaded
+	Data      interface{} `json:"data"`
+}
+
+// UserWebhookTestResult is the outcome of a synchronous test delivery
+type UserWebhookTestResult struct {
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// errUserWebhookPrivateAddress is returned when a webhook host resolves to a non-public address
+var errUserWebhookPrivateAddress = errors.New("webhook host resolves to a private or loopback address")
+
+// UserWebhookNotifier notifies users when their background jobs finish: a signed POST to
+// the user's webhook, retried with backoff, with an email fallback when the user has no
+// webhook or every attempt fails
+type UserWebhookNotifier struct {
+	cfg      *config.Config
+	client   *http.Client
+	kick     chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+var (
+	userWebhookNotifier     *UserWebhookNotifier
+	userWebhookNotifierOnce sync.Once
+)
+
+// InitUserWebhookNotifier creates the singleton notifier
+func InitUserWebhookNotifier(cfg *config.Config) *UserWebhookNotifier {
+	userWebhookNotifierOnce.Do(func() {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		if !cfg.UserWebhookAllowPrivate {
+			// Checked on the resolved address so DNS names pointing inside the network are refused too
+			dialer.Control = func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return errUserWebhookPrivateAddress
+				}
+				return nil
+			}
+		}
+		userWebhookNotifier = &UserWebhookNotifier{
+			cfg: cfg,
+			client: &http.Client{
+				Timeout:   userWebhookTimeout,
+				Transport: &http.Transport{DialContext: dialer.DialContext},
+				// Redirects are not followed: the signature is bound to the registered URL
+				CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+			},
+			kick:     make(chan struct{}, 1),
+			stopChan: make(chan struct{}),
+		}
+	})
+	return userWebhookNotifier
+}
+
+// GetUserWebhookNotifier returns the notifier, nil before InitUserWebhookNotifier
+func GetUserWebhookNotifier() *UserWebhookNotifier {
+	return userWebhookNotifier
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// ValidateUserWebhookURL checks that url is an absolute http(s) URL
+func ValidateUserWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("webhook URL must not contain credentials")
+	}
+	return nil
+}
+
+// NewUserWebhookSecret generates a signing secret for a user webhook
+func NewUserWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// SignUserWebhookPayload returns the signature header value for body sent at t
+func SignUserWebhookPayload(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start runs the delivery loop
+func (n *UserWebhookNotifier) Start() {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(userWebhookPollInterval)
+		defer ticker.Stop()
+		for {
+			n.deliverDue()
+			select {
+			case <-ticker.C:
+			case <-n.kick:
+			case <-n.stopChan:
+				return
+			}
+		}
+	}()
+	logrus.Info("User webhook notifier started")
+}
+
+// Stop stops the delivery loop; undelivered notifications are picked up after a restart
+func (n *UserWebhookNotifier) Stop() {
+	close(n.stopChan)
+	n.wg.Wait()
+}
+
+// Notify tells userID that a job finished. With an enabled webhook the event is queued
+// for delivery; otherwise it is emailed unless the user turned the email fallback off
+func (n *UserWebhookNotifier) Notify(userID int64, eventType, summary, link string, data interface{}) {
+	if userID <= 0 {
+		return
+	}
+	log := logrus.WithFields(logrus.Fields{"user_id": userID, "event": eventType})
+
+	webhook, err := database.GetUserWebhook(userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to load user webhook")
+		return
+	}
+	if webhook == nil || !webhook.Enabled {
+		if webhook == nil || webhook.EmailFallback {
+			n.sendEmail(userID, eventType, summary, link)
+		}
+		return
+	}
+
+	event := UserWebhookEvent{
+		ID:        newUserWebhookEventID(),
+		Type:      eventType,
+		CreatedAt: time.Now().Unix(),
+		Summary:   summary,
+		Link:      link,
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode webhook event")
+		return
+	}
+	if _, err := database.CreateWebhookDelivery(userID, eventType, string(payload)); err != nil {
+		log.WithError(err).Error("Failed to queue webhook delivery")
+		return
+	}
+	select {
+	case n.kick <- struct{}{}:
+	default:
+	}
+}
+
+// SendTest POSTs a webhook.test event to the user's webhook right away, without retries
+func (n *UserWebhookNotifier) SendTest(webhook *database.UserWebhook) UserWebhookTestResult {
+	event := UserWebhookEvent{
+		ID:        newUserWebhookEventID(),
+		Type:      UserWebhookEventTest,
+		CreatedAt: time.Now().Unix(),
+		Summary:   "This is a test notification from Curry2API",
+		Data:      map[string]interface{}{},
+	}
+	payload, _ := json.Marshal(event)
+	status, err := n.post(webhook, 0, UserWebhookEventTest, payload)
+	if err != nil {
+		return UserWebhookTestResult{StatusCode: status, Error: err.Error()}
+	}
+	return UserWebhookTestResult{Delivered: true, StatusCode: status}
+}
+
+// deliverDue delivers every due delivery, a page at a time
+func (n *UserWebhookNotifier) deliverDue() {
+	for {
+		deliveries, err := database.ClaimDueWebhookDeliveries(userWebhookClaimBatch)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to claim webhook deliveries")
+		}
+		for _, d := range deliveries {
+			n.deliver(d)
+		}
+		if err != nil || len(deliveries) < userWebhookClaimBatch {
+			return
+		}
+		select {
+		case <-n.stopChan:
+			return
+		default:
+		}
+	}
+}
+
+// deliver makes one attempt and records the outcome, scheduling a retry or falling back to email
+func (n *UserWebhookNotifier) deliver(d *database.WebhookDelivery) {
+	log := logrus.WithFields(logrus.Fields{"delivery_id": d.ID, "user_id": d.UserID, "event": d.Event})
+	attempts := d.Attempts + 1
+
+	webhook, err := database.GetUserWebhook(d.UserID)
+	if err != nil {
+		log.WithError(err).Error("Failed to load user webhook")
+		database.MarkWebhookRetry(d.ID, d.Attempts, err.Error(), time.Now().Add(userWebhookRetryDelays[0]))
+		return
+	}
+	if webhook == nil || !webhook.Enabled {
+		database.MarkWebhookFailed(d.ID, d.Attempts, "webhook disabled")
+		if webhook != nil && webhook.EmailFallback {
+			n.emailDelivery(d)
+		}
+		return
+	}
+
+	_, err = n.post(webhook, d.ID, d.Event, []byte(d.Payload))
+	if err == nil {
+		if err := database.MarkWebhookDelivered(d.ID, attempts); err != nil {
+			log.WithError(err).Error("Failed to record webhook delivery")
+		}
+		return
+	}
+
+	if attempts > len(userWebhookRetryDelays) {
+		log.WithError(err).WithField("attempts", attempts).Warn("Webhook delivery failed, giving up")
+		database.MarkWebhookFailed(d.ID, attempts, err.Error())
+		if webhook.EmailFallback {
+			n.emailDelivery(d)
+		}
+		return
+	}
+	delay := userWebhookRetryDelays[attempts-1]
+	log.WithError(err).WithField("retry_in", delay).Debug("Webhook delivery failed, will retry")
+	database.MarkWebhookRetry(d.ID, attempts, err.Error(), time.Now().Add(delay))
+}
+
+// post sends a signed payload to the webhook, returning the HTTP status; only 2xx counts as delivered
+func (n *UserWebhookNotifier) post(webhook *database.UserWebhook, deliveryID int64, eventType string, payload []byte) (int, error) {
+	if err := ValidateUserWebhookURL(webhook.URL); err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), userWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Curry2API-Webhook/1.0")
+	req.Header.Set(UserWebhookEventHeader, eventType)
+	req.Header.Set(UserWebhookSignatureHeader, SignUserWebhookPayload(webhook.Secret, time.Now(), payload))
+	if deliveryID > 0 {
+		req.Header.Set(UserWebhookDeliveryHeader, strconv.FormatInt(deliveryID, 10))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// emailDelivery emails the notification of a delivery that could not be made
+func (n *UserWebhookNotifier) emailDelivery(d *database.WebhookDelivery) {
+	if d.Event == UserWebhookEventTest {
+		return
+	}
+	var event UserWebhookEvent
+	if err := json.Unmarshal([]byte(d.Payload), &event); err != nil {
+		logrus.WithError(err).WithField("delivery_id", d.ID).Warn("Failed to decode webhook payload for email fallback")
+		return
+	}
+	n.sendEmail(d.UserID, d.Event, event.Summary, event.Link)
+}
+
+// sendEmail emails a job notification to the user
+func (n *UserWebhookNotifier) sendEmail(userID int64, eventType, summary, link string) {
+	log := logrus.WithFields(logrus.Fields{"user_id": userID, "event": eventType})
+	user, err := database.GetUserByID(userID)
+	if err != nil || user.Email == "" {
+		if err != nil {
+			log.WithError(err).Warn("Failed to load user for job notification email")
+		}
+		return
+	}
+	title := userWebhookEventTitles[eventType]
+	if title == "" {
+		title = "任务已完成"
+	}
+	if err := NewEmailService(n.cfg).SendJobCompletedNotice(user.Email, user.Username, title, summary, link); err != nil {
+		log.WithError(err).Warn("Failed to send job notification email")
+	}
+}
+
+// newUserWebhookEventID returns a random event ID, stable across retries of the same delivery
+func newUserWebhookEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// NotifyBatchCompleted notifies the owner of a finished batch, with a link to its results
+func NotifyBatchCompleted(cfg *config.Config, batch *database.Batch) {
+	notifier := GetUserWebhookNotifier()
+	if notifier == nil {
+		return
+	}
+	link := "/v1/batches/" + batch.ID + "/results"
+	if cfg.PublicBaseURL != "" {
+		link = cfg.PublicBaseURL + link
+	}
+	summary := fmt.Sprintf("Batch %s finished: %d of %d requests succeeded, %d failed. Download the results with the API key that created the batch.",
+		batch.ID, batch.Completed, batch.Total, batch.Failed)
+	notifier.Notify(batch.UserID, UserWebhookEventBatchCompleted, summary, link, batch)
+}
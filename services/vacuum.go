@@ -0,0 +1,100 @@
+package services
+
+import (
+	"Curry2API-go/database"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VacuumJob is the /admin/jobs name of the stale row vacuum
+const VacuumJob = "vacuum"
+
+// vacuumTask removes stale rows from one table and returns how many were removed
+type vacuumTask struct {
+	table string
+	run   func() (int64, error)
+}
+
+// vacuumTasks lists the tables purged by each vacuum run
+var vacuumTasks = []vacuumTask{
+	{table: "sessions", run: database.VacuumExpiredSessions},
+	{table: "verification_codes", run: database.VacuumVerificationCodes},
+	{table: "oauth_states", run: database.VacuumExpiredOAuthStates},
+	{table: "chat_attachments", run: database.VacuumStaleChatAttachments},
+}
+
+// VacuumService periodically purges expired sessions, used or expired verification
+// codes, expired OAuth states and chat attachments never sent, reporting per-table counts in the jobs dashboard
+type VacuumService struct {
+	interval time.Duration
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewVacuumService creates a vacuum service that runs every interval; 0 disables it
+func NewVacuumService(interval time.Duration) *VacuumService {
+	return &VacuumService{
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start runs a vacuum immediately and then every interval until Stop
+func (s *VacuumService) Start() {
+	if s.interval <= 0 {
+		logrus.Info("Vacuum job is disabled")
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		RunVacuum()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				RunVacuum()
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+	logrus.Infof("Vacuum job started (interval: %s)", s.interval)
+}
+
+// Stop stops the vacuum loop
+func (s *VacuumService) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// RunVacuum purges every vacuum table once. A failing table is reported and the
+// remaining tables are still vacuumed
+func RunVacuum() map[string]int64 {
+	job := StartJob(VacuumJob, int64(len(vacuumTasks)))
+	counts := make(map[string]int64, len(vacuumTasks))
+	var errs []error
+	for i, task := range vacuumTasks {
+		n, err := task.run()
+		if err != nil {
+			logrus.WithError(err).WithField("table", task.table).Warn("Vacuum failed")
+			errs = append(errs, err)
+		}
+		counts[task.table] = n
+		job.SetCount(task.table, n)
+		job.Update(int64(i+1), int64(len(vacuumTasks)), fmt.Sprintf("vacuumed %s", task.table))
+	}
+	job.Finish(errors.Join(errs...))
+
+	logrus.WithFields(logrus.Fields{
+		"sessions":           counts["sessions"],
+		"verification_codes": counts["verification_codes"],
+		"oauth_states":       counts["oauth_states"],
+	}).Debug("Vacuum completed")
+	return counts
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"Curry2API-go/models"
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// claudeRelayUsage 上游 Messages API 响应中的用量字段
+type claudeRelayUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// trackRelayUsage 调用上下文中的用量统计函数
+func trackRelayUsage(c *gin.Context, usage *models.Usage, statusCode int, errorMsg string) {
+	if trackFunc, exists := c.Get("track_usage_func"); exists {
+		if fn, ok := trackFunc.(UsageTrackingFunc); ok {
+			fn(c, usage, statusCode, errorMsg)
+		}
+	}
+}
+
+// RelayClaudeStream 原样转发 Anthropic 原生 SSE 流，
+// 同时从 message_start / message_delta 事件中提取用量用于计费
+func RelayClaudeStream(c *gin.Context, body io.Reader) {
+	c.Header("Content-Type", "text/event-stream; charset=utf-8")
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// 空闲时定期发送心跳，避免代理或客户端因长时间没有数据而断开
+	defer BeginSSEKeepAlive(c, claudePingEvent)()
+
+	// 按密钥/服务器配置合并小块输出，流结束时刷新剩余数据
+	defer beginStreamCoalescing(c)()
+
+	var usage models.Usage
+	reader := bufio.NewReaderSize(body, 64*1024)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if _, werr := c.Writer.WriteString(line); werr != nil {
+				trackRelayUsage(c, nil, 499, "Client disconnected")
+				return
+			}
+			if strings.HasPrefix(line, "data:") {
+				accumulateRelayUsage(strings.TrimSpace(strings.TrimPrefix(line, "data:")), &usage)
+			} else if strings.TrimSpace(line) == "" {
+				c.Writer.Flush() // 空行结束一个事件
+			}
+		}
+		if err != nil {
+			c.Writer.Flush()
+			if err != io.EOF {
+				if c.Request.Context().Err() != nil {
+					trackRelayUsage(c, nil, 499, "Client disconnected")
+					return
+				}
+				logrus.WithError(err).Warn("Anthropic stream interrupted")
+				trackRelayUsage(c, nil, http.StatusBadGateway, err.Error())
+				return
+			}
+			break
+		}
+	}
+
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	trackRelayUsage(c, &usage, http.StatusOK, "")
+}
+
+// accumulateRelayUsage 读取单个 SSE data 负载中的用量：
+// message_start 携带输入 token，message_delta 携带累计输出 token
+func accumulateRelayUsage(data string, usage *models.Usage) {
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Usage claudeRelayUsage `json:"usage"`
+		} `json:"message"`
+		Usage claudeRelayUsage `json:"usage"`
+	}
+	if json.Unmarshal([]byte(data), &event) != nil {
+		return
+	}
+	switch event.Type {
+	case "message_start":
+		usage.PromptTokens = event.Message.Usage.InputTokens
+		usage.CompletionTokens = event.Message.Usage.OutputTokens
+	case "message_delta":
+		if event.Usage.InputTokens > 0 {
+			usage.PromptTokens = event.Usage.InputTokens
+		}
+		usage.CompletionTokens = event.Usage.OutputTokens
+	}
+}
+
+// RelayClaudeResponse 原样返回 Anthropic 原生非流式响应并记录用量
+func RelayClaudeResponse(c *gin.Context, body io.Reader) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to read Anthropic response")
+		trackRelayUsage(c, nil, http.StatusBadGateway, err.Error())
+		c.JSON(http.StatusBadGateway, models.NewClaudeAPIError("Failed to read upstream response"))
+		return
+	}
+
+	var resp struct {
+		Usage claudeRelayUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &resp); err == nil {
+		trackRelayUsage(c, &models.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		}, http.StatusOK, "")
+	}
+
+	c.Data(http.StatusOK, "application/json", data)
+}
@@ -59,14 +59,26 @@ func ParseToolCallFromContent(content string) (*models.ClaudeToolUse, string, bo
 	return toolUse, beforeText, true
 }
 
+// claudeDefaultModel 上下文中没有请求模型时响应中使用的模型名称
+const claudeDefaultModel = "claude-3-5-sonnet-20241022"
+
+// claudeResponseModel 返回响应中的模型名称（请求的模型）
+func claudeResponseModel(c *gin.Context) string {
+	if model := c.GetString("request_model"); model != "" {
+		return model
+	}
+	return claudeDefaultModel
+}
+
 // StreamClaudeCompletion 处理Claude流式响应
 // 按照Claude API规范发送SSE事件序列:
 // 1. message_start - 消息开始
 // 2. content_block_start - 内容块开始
-// 3. content_block_delta - 内容增量（多次）
-// 4. content_block_stop - 内容块结束
-// 5. message_delta - 消息元数据（包含stop_reason和usage）
-// 6. message_stop - 消息结束
+// 3. ping - 连接保活（生成期间长时间没有输出时重复发送）
+// 4. content_block_delta - 内容增量（多次）
+// 5. content_block_stop - 内容块结束
+// 6. message_delta - 消息元数据（包含stop_reason和usage）
+// 7. message_stop - 消息结束
 func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 	// 设置SSE头 - 关键配置以确保流式响应立即发送
 	c.Header("Content-Type", "text/event-stream; charset=utf-8")
@@ -85,9 +97,15 @@ func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 		flusher.Flush()
 	}
 
+	// 空闲时定期发送心跳，避免代理或客户端因长时间没有数据而断开
+	defer BeginSSEKeepAlive(c, claudePingEvent)()
+
+	// 按密钥/服务器配置合并小块输出，流结束时刷新剩余数据
+	defer beginStreamCoalescing(c)()
+
 	// 生成消息ID
 	messageID := "msg-" + GenerateRandomString(29)
-	model := "claude-3-5-sonnet-20241022" // 默认模型
+	model := claudeResponseModel(c)
 
 	// 发送 message_start 事件
 	messageStartEvent := models.NewClaudeStreamResponseWithDetails(
@@ -111,10 +129,15 @@ func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 		return
 	}
 
+	pingEvent := &models.ClaudeStreamResponse{Type: "ping"}
+	if err := writeClaudeSSEEvent(c.Writer, pingEvent); err != nil {
+		logrus.WithError(err).Error("Failed to write ping event")
+		return
+	}
+
 	// 处理流式数据
 	ctx := c.Request.Context()
 	var usage models.Usage
-	hasContent := false
 	stopReason := "end_turn"
 	
 	// 检查是否需要解析工具调用
@@ -172,8 +195,8 @@ func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 							Type:  "content_block_delta",
 							Index: 1,
 							Delta: &models.ClaudeStreamDelta{
-								Type: "input_json_delta",
-								Text: string(inputJSON),
+								Type:        "input_json_delta",
+								PartialJSON: string(inputJSON),
 							},
 						}
 						writeClaudeSSEEvent(c.Writer, toolDeltaEvent)
@@ -204,7 +227,7 @@ func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 					stopReason,
 					"",
 					"",
-					0,
+					usage.PromptTokens,
 					usage.CompletionTokens,
 				)
 				if err := writeClaudeSSEEvent(c.Writer, messageDeltaEvent); err != nil {
@@ -231,8 +254,6 @@ func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 			case string:
 				// 文本内容 - 发送 content_block_delta 事件
 				if v != "" {
-					hasContent = true
-					
 					// 如果启用了工具调用检测，缓冲内容并智能发送
 					if hasToolUse == true {
 						contentBuffer.WriteString(v)
@@ -283,32 +304,12 @@ func StreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 			case error:
 				logrus.WithError(v).Error("Stream generator error")
 				
-				// 发送错误事件
+				// 发送错误事件后结束流（Anthropic 规范中 error 事件即流的终点，"error" 不是合法的 stop_reason）
 				errorResp := models.NewClaudeAPIError(v.Error())
 				if jsonData, err := json.Marshal(errorResp); err == nil {
 					WriteSSEEvent(c.Writer, "error", string(jsonData))
 				}
 				
-				// 如果已经发送了内容，需要正常结束流
-				if hasContent {
-					contentBlockStopEvent := models.NewClaudeStreamResponse("content_block_stop", "", "")
-					writeClaudeSSEEvent(c.Writer, contentBlockStopEvent)
-					
-					messageDeltaEvent := models.NewClaudeStreamResponseWithDetails(
-						"message_delta",
-						"",
-						"error",
-						"",
-						"",
-						0,
-						usage.CompletionTokens,
-					)
-					writeClaudeSSEEvent(c.Writer, messageDeltaEvent)
-					
-					messageStopEvent := models.NewClaudeStreamResponse("message_stop", "", "")
-					writeClaudeSSEEvent(c.Writer, messageStopEvent)
-				}
-				
 				// Track failed streaming request if tracking function is available
 				if trackFunc, exists := c.Get("track_usage_func"); exists {
 					if fn, ok := trackFunc.(UsageTrackingFunc); ok {
@@ -351,7 +352,7 @@ func NonStreamClaudeCompletion(c *gin.Context, chatGenerator <-chan interface{})
 			if !ok {
 				// 数据收集完成，构建并返回Claude响应
 				messageID := "msg-" + GenerateRandomString(29)
-				model := "claude-3-5-sonnet-20241022" // 默认模型
+				model := claudeResponseModel(c)
 				
 				content := fullContent.String()
 				stopReason := "end_turn"
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Device types reported by ParseUserAgent
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// DeviceInfo 登录设备的轻量指纹：由 User-Agent 解析出的浏览器、操作系统、设备类型，
+// 以及根据 IP 得到的粗略位置（国家/地区级别）
+type DeviceInfo struct {
+	Browser    string `json:"browser"`
+	OS         string `json:"os"`
+	DeviceType string `json:"device_type"`
+	Location   string `json:"location"`
+}
+
+// browserPatterns 按匹配优先级排列：Edge、Opera 等基于 Chromium 的浏览器同时带有 Chrome 标识
+var browserPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+).*Safari/`)},
+	{"curl", regexp.MustCompile(`^curl/(\d+)`)},
+	{"Python", regexp.MustCompile(`python-requests/(\d+)|Python/(\d+)`)},
+}
+
+// ParseUserAgent 从 User-Agent 中解析浏览器（含主版本号）、操作系统与设备类型，无法识别时返回 "Unknown"
+func ParseUserAgent(ua string) (browser, os, deviceType string) {
+	browser, os = "Unknown", "Unknown"
+	for _, p := range browserPatterns {
+		if m := p.pattern.FindStringSubmatch(ua); m != nil {
+			browser = p.name
+			for _, version := range m[1:] {
+				if version != "" {
+					browser += " " + version
+					break
+				}
+			}
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "iPad"):
+		os = "iPadOS"
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod"):
+		os = "iOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "CrOS"):
+		os = "ChromeOS"
+	case strings.Contains(ua, "Mac OS X") || strings.Contains(ua, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	lower := strings.ToLower(ua)
+	switch {
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "spider") || strings.Contains(lower, "crawl"):
+		deviceType = DeviceBot
+	case os == "iPadOS" || (os == "Android" && !strings.Contains(ua, "Mobile")):
+		deviceType = DeviceTablet
+	case os == "iOS" || os == "Android" || strings.Contains(ua, "Mobi"):
+		deviceType = DeviceMobile
+	default:
+		deviceType = DeviceDesktop
+	}
+	return browser, os, deviceType
+}
+
+// countryHeaders 由 CDN/反向代理注入的访客国家代码请求头
+var countryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Vercel-IP-Country", "X-Country-Code"}
+
+// CoarseLocation 返回客户端的粗略位置：内网地址返回 "Local network"，
+// 否则使用 CDN 注入的国家代码（如 "US"），都没有时返回 "Unknown"
+func CoarseLocation(c *gin.Context) string {
+	if ip := net.ParseIP(c.ClientIP()); ip != nil && (ip.IsLoopback() || ip.IsPrivate()) {
+		return "Local network"
+	}
+	for _, header := range countryHeaders {
+		country := strings.ToUpper(strings.TrimSpace(c.GetHeader(header)))
+		// Cloudflare 用 XX 表示未知、T1 表示 Tor
+		if len(country) == 2 && country != "XX" {
+			if country == "T1" {
+				return "Tor network"
+			}
+			return country
+		}
+	}
+	return "Unknown"
+}
+
+// DeviceFromRequest 采集当前请求的设备指纹
+func DeviceFromRequest(c *gin.Context) DeviceInfo {
+	browser, os, deviceType := ParseUserAgent(c.GetHeader("User-Agent"))
+	return DeviceInfo{
+		Browser:    browser,
+		OS:         os,
+		DeviceType: deviceType,
+		Location:   CoarseLocation(c),
+	}
+}
+
+// Fingerprint 返回设备指纹的短哈希，用于判断是否为新设备登录；浏览器只取名称，升级版本不视为新设备
+func (d DeviceInfo) Fingerprint() string {
+	browser := d.Browser
+	if i := strings.LastIndex(browser, " "); i > 0 {
+		browser = browser[:i]
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{browser, d.OS, d.DeviceType, d.Location}, "|")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MaskIP 隐去 IP 的主机部分（IPv4 保留 /24，IPv6 保留 /48），用于通知等展示场景
+func MaskIP(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return net.IPv4(v4[0], v4[1], v4[2], 0).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"Curry2API-go/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// geminiChunk 创建只有一个候选的 Gemini 响应
+func geminiChunk(model, responseID string, parts []models.GeminiPart, finishReason string) *models.GeminiGenerateContentResponse {
+	return &models.GeminiGenerateContentResponse{
+		Candidates: []models.GeminiCandidate{{
+			Content:      models.GeminiContent{Role: "model", Parts: parts},
+			FinishReason: finishReason,
+		}},
+		ModelVersion: model,
+		ResponseID:   responseID,
+	}
+}
+
+// geminiFunctionCallPart 将解析出的工具调用转换为 functionCall 部分
+func geminiFunctionCallPart(toolUse *models.ClaudeToolUse) models.GeminiPart {
+	args := toolUse.Input
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return models.GeminiPart{FunctionCall: &models.GeminiFunctionCall{Name: toolUse.Name, Args: args}}
+}
+
+// geminiChunkWriter 按 alt=sse（SSE 数据行）或默认的 JSON 数组格式逐块写出流式响应
+type geminiChunkWriter struct {
+	c       *gin.Context
+	sse     bool
+	written int
+}
+
+func (w *geminiChunkWriter) write(chunk interface{}) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	if w.sse {
+		_, err = fmt.Fprintf(w.c.Writer, "data: %s\r\n\r\n", data)
+	} else {
+		sep := "["
+		if w.written > 0 {
+			sep = ",\r\n"
+		}
+		_, err = fmt.Fprintf(w.c.Writer, "%s%s", sep, data)
+	}
+	if err != nil {
+		return err
+	}
+	w.written++
+	if flusher, ok := w.c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// close 结束 JSON 数组
+func (w *geminiChunkWriter) close() {
+	if w.sse {
+		return
+	}
+	if w.written == 0 {
+		w.c.Writer.WriteString("[")
+	}
+	w.c.Writer.WriteString("]")
+}
+
+// StreamGemini 以 streamGenerateContent 的格式输出生成结果：每个文本增量一个响应块，
+// 最后一块带 finishReason 与 usageMetadata。sse 为 true（alt=sse）时输出 SSE 数据行，否则输出流式 JSON 数组
+// 请求带工具时 <tool_call> 块不作为文本输出，而是转换为 functionCall 部分
+func StreamGemini(c *gin.Context, chatGenerator <-chan interface{}, model string, sse bool) {
+	if sse {
+		c.Header("Content-Type", "text/event-stream; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Encoding", "identity")
+	c.Header("Transfer-Encoding", "chunked")
+	c.Status(http.StatusOK)
+
+	// SSE 模式下空闲时定期发送心跳（JSON 数组模式无法插入注释）
+	if sse {
+		defer BeginSSEKeepAlive(c, SSEPingComment)()
+	}
+
+	// 按密钥/服务器配置合并小块输出，流结束时刷新剩余数据
+	defer beginStreamCoalescing(c)()
+
+	chunks := &geminiChunkWriter{c: c, sse: sse}
+	defer chunks.close()
+
+	responseID := GenerateRandomString(24)
+	hasToolUse := c.GetBool("has_tool_use")
+	var (
+		usage       models.Usage
+		fullContent strings.Builder
+		sent        int
+		inToolCall  bool
+	)
+	sendText := func(delta string) error {
+		if delta == "" {
+			return nil
+		}
+		sent += len(delta)
+		return chunks.write(geminiChunk(model, responseID, []models.GeminiPart{{Text: delta}}, ""))
+	}
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Debug("Client disconnected during Gemini streaming")
+			trackResponsesUsage(c, nil, 499, "Client disconnected")
+			return
+
+		case data, ok := <-chatGenerator:
+			if !ok {
+				content := fullContent.String()
+				var parts []models.GeminiPart
+				if inToolCall {
+					if toolUse, _, found := ParseToolCallFromContent(content); found {
+						parts = append(parts, geminiFunctionCallPart(toolUse))
+					} else {
+						// 未能解析为工具调用，按普通文本补发
+						parts = append(parts, models.GeminiPart{Text: content[sent:]})
+					}
+				} else if sent < len(content) {
+					parts = append(parts, models.GeminiPart{Text: content[sent:]})
+				}
+				if parts == nil {
+					parts = []models.GeminiPart{{Text: ""}}
+				}
+
+				final := geminiChunk(model, responseID, parts, "STOP")
+				final.UsageMetadata = models.NewGeminiUsageMetadata(usage)
+				if err := chunks.write(final); err != nil {
+					logrus.WithError(err).Error("Failed to write final Gemini chunk")
+				}
+				trackResponsesUsage(c, &usage, http.StatusOK, "")
+				return
+			}
+
+			switch v := data.(type) {
+			case string:
+				fullContent.WriteString(v)
+				if inToolCall {
+					continue
+				}
+				content := fullContent.String()
+				end := len(content)
+				if hasToolUse {
+					if idx := strings.Index(content, toolCallTag); idx >= 0 {
+						// 工具调用之后的内容不再作为文本发送
+						inToolCall = true
+						end = idx
+					} else {
+						end -= pendingToolCallPrefix(content)
+					}
+				}
+				if end > sent {
+					if err := sendText(content[sent:end]); err != nil {
+						logrus.WithError(err).Error("Failed to write Gemini chunk")
+						return
+					}
+				}
+
+			case models.Usage:
+				usage = v
+
+			case error:
+				logrus.WithError(v).Error("Gemini stream generator error")
+				chunks.write(models.NewGeminiError(http.StatusInternalServerError, v.Error(), "INTERNAL"))
+				trackResponsesUsage(c, nil, http.StatusInternalServerError, v.Error())
+				return
+
+			default:
+				logrus.Warnf("Unknown data type in Gemini stream: %T", v)
+			}
+		}
+	}
+}
+
+// NonStreamGemini 收集生成结果后返回完整的 generateContent 响应
+func NonStreamGemini(c *gin.Context, chatGenerator <-chan interface{}, model string) {
+	var fullContent strings.Builder
+	var usage models.Usage
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			c.JSON(http.StatusGatewayTimeout, models.NewGeminiError(http.StatusGatewayTimeout, "Request timeout", "DEADLINE_EXCEEDED"))
+			trackResponsesUsage(c, nil, http.StatusRequestTimeout, "Request timeout")
+			return
+
+		case data, ok := <-chatGenerator:
+			if !ok {
+				content := fullContent.String()
+				var parts []models.GeminiPart
+				if c.GetBool("has_tool_use") {
+					if toolUse, beforeText, found := ParseToolCallFromContent(content); found {
+						if beforeText != "" {
+							parts = append(parts, models.GeminiPart{Text: beforeText})
+						}
+						parts = append(parts, geminiFunctionCallPart(toolUse))
+					}
+				}
+				if parts == nil {
+					parts = []models.GeminiPart{{Text: content}}
+				}
+
+				response := geminiChunk(model, GenerateRandomString(24), parts, "STOP")
+				response.UsageMetadata = models.NewGeminiUsageMetadata(usage)
+				trackResponsesUsage(c, &usage, http.StatusOK, "")
+				c.JSON(http.StatusOK, response)
+				return
+			}
+
+			switch v := data.(type) {
+			case string:
+				fullContent.WriteString(v)
+			case models.Usage:
+				usage = v
+			case error:
+				logrus.WithError(v).Error("Gemini generator error")
+				c.JSON(http.StatusInternalServerError, models.NewGeminiError(http.StatusInternalServerError, "Internal server error", "INTERNAL"))
+				trackResponsesUsage(c, nil, http.StatusInternalServerError, v.Error())
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrJWTInvalid 令牌格式错误、算法不支持或签名不匹配
+var ErrJWTInvalid = errors.New("jwt is malformed or has an invalid signature")
+
+// jwtHeader 只签发和接受 HS256，拒绝 alg=none 等其他算法
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+var jwtHS256Header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignJWT 使用 HMAC-SHA256 签发 JWT（RFC 7519），claims 序列化为载荷
+func SignJWT(claims any, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := jwtHS256Header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + jwtSignature(signingInput, secret), nil
+}
+
+// ParseJWT 校验 HS256 签名并把载荷解码到 claims，不检查过期时间等声明
+func ParseJWT(token string, secret []byte, claims any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrJWTInvalid
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrJWTInvalid
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg != "HS256" {
+		return ErrJWTInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(jwtSignature(parts[0]+"."+parts[1], secret))) {
+		return ErrJWTInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrJWTInvalid
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrJWTInvalid
+	}
+	return nil
+}
+
+// LooksLikeJWT 粗略判断字符串是否为 JWT（三段式），用于区分 Bearer 头中的 API 密钥
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+func jwtSignature(signingInput string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
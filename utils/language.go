@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// LanguageUndetermined is the code returned when the language of a text can't be told
+const LanguageUndetermined = "und"
+
+const (
+	languageSampleRunes = 2000 // Only the start of long texts is examined
+	languageMinLetters  = 12   // Texts with fewer letters are undetermined
+	languageMinMarkers  = 2    // Letters specific to a Latin-script language needed to decide on them
+)
+
+// languageTrigrams are the most frequent character trigrams of common Latin-script languages,
+// most frequent first; '_' stands for a word boundary
+var languageTrigrams = map[string]string{
+	"en": "_th the he_ _an and nd_ ing ng_ _of of_ _to to_ ed_ _in ion is_ in_ er_ ent tio re_ es_ at_ on_ _is for _fo or_ hat tha _yo you ou_ it_ _it _wh _be _ha",
+	"es": "_de de_ os_ _la la_ el_ _el es_ _qu que ue_ _en en_ as_ ent ión do_ _lo nte ara par _pa _co con ado _se ien est _es una _un por _po _y_ los ón_",
+	"fr": "_de es_ de_ le_ _le ent _la la_ _et et_ ne_ les re_ nt_ ion que _qu ue_ _pa our _po ous e_d _un une _co est _es ait ur_ des ité ans dan _vo vou pas",
+	"de": "en_ er_ ch_ _de der ie_ die _di ich ein _ei und _un nd_ sch cht _ge den in_ ung gen te_ es_ _da das ine ten ist _is st_ nic ht_ auf _au mit _mi ber _zu",
+	"pt": "_de de_ os_ _qu que ue_ ão_ do_ _co _a_ as_ ent _pa ara par da_ com ção em_ _em nte _o_ es_ _se não _nã uma _um est ado _e_ mos ar_ _do _da",
+	"it": "_di di_ la_ _la to_ _ch che he_ re_ one _il il_ ell del _de per _pe ent are _in no_ lla zio ion _co con ere ato ta_ non _no _e_ gli _un una",
+	"nl": "en_ de_ _de et_ an_ van _va een _ee het _he _ge er_ aar _in in_ ij_ te_ _te oor ing ver _ve nde ijk cht ede sch dat _da iet _ni _is _zi",
+	"id": "an_ _me _di kan ang ng_ _da dan _ya yan _be ya_ ah_ _pe ber _ke men ada _se _ad ini _in ata eng nga aka per ala _ak _un tuk unt uk_ _ti",
+}
+
+// Letters that only (or mostly) occur in one Latin-script language
+var languageMarkers = map[string]string{
+	"vi": "ăđơưạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ",
+	"pl": "ąćęłńśźż",
+	"tr": "ığş",
+}
+
+// languageProfiles maps each trigram to its weight per language, built from languageTrigrams
+var languageProfiles = buildLanguageProfiles()
+
+func buildLanguageProfiles() map[string]map[string]int {
+	profiles := make(map[string]map[string]int, len(languageTrigrams))
+	for lang, list := range languageTrigrams {
+		trigrams := strings.Fields(list)
+		profile := make(map[string]int, len(trigrams))
+		for i, trigram := range trigrams {
+			// Higher ranked trigrams weigh more
+			profile[strings.ReplaceAll(trigram, "_", " ")] = len(trigrams) - i
+		}
+		profiles[lang] = profile
+	}
+	return profiles
+}
+
+// scriptCounts is how many letters of each script a text contains
+type scriptCounts struct {
+	letters, latin, han, kana, hangul, cyrillic, ukrainian, arabic, devanagari, thai, hebrew, greek int
+}
+
+// DetectLanguage guesses the ISO 639-1 code of text with a confidence between 0 and 1, or
+// LanguageUndetermined. Letters of a script used by one main language (Hangul, kana, Thai...)
+// decide directly; Latin text is matched against trigram profiles of common languages.
+// It is a fast heuristic for statistics and policy matching, not a full classifier
+func DetectLanguage(text string) (string, float64) {
+	var counts scriptCounts
+	markers := make(map[string]int)
+	var sample strings.Builder
+	n := 0
+	for _, r := range text {
+		if n++; n > languageSampleRunes {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			sample.WriteByte(' ')
+			continue
+		}
+		counts.letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			counts.latin++
+			lower := unicode.ToLower(r)
+			sample.WriteRune(lower)
+			for lang, set := range languageMarkers {
+				if strings.ContainsRune(set, lower) {
+					markers[lang]++
+				}
+			}
+		case unicode.Is(unicode.Han, r):
+			counts.han++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			counts.kana++
+		case unicode.Is(unicode.Hangul, r):
+			counts.hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts.cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				counts.ukrainian++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			counts.arabic++
+		case unicode.Is(unicode.Devanagari, r):
+			counts.devanagari++
+		case unicode.Is(unicode.Thai, r):
+			counts.thai++
+		case unicode.Is(unicode.Hebrew, r):
+			counts.hebrew++
+		case unicode.Is(unicode.Greek, r):
+			counts.greek++
+		}
+	}
+	if counts.letters < languageMinLetters {
+		return LanguageUndetermined, 0
+	}
+
+	// Chinese and Japanese share Han characters; any kana makes the text Japanese
+	lang, count := "", 0
+	for _, s := range []struct {
+		lang  string
+		count int
+	}{
+		{"latin", counts.latin},
+		{"zh", counts.han + counts.kana},
+		{"ko", counts.hangul},
+		{"ru", counts.cyrillic},
+		{"ar", counts.arabic},
+		{"hi", counts.devanagari},
+		{"th", counts.thai},
+		{"he", counts.hebrew},
+		{"el", counts.greek},
+	} {
+		if s.count > count {
+			lang, count = s.lang, s.count
+		}
+	}
+	share := float64(count) / float64(counts.letters)
+	switch {
+	case lang == "zh" && counts.kana > 0:
+		return "ja", share
+	case lang == "ru" && counts.ukrainian > 0:
+		return "uk", share
+	case lang != "latin":
+		return lang, share
+	}
+
+	marked, most := "", languageMinMarkers-1
+	for lang, n := range markers {
+		if n > most || (n == most && marked != "" && lang < marked) {
+			marked, most = lang, n
+		}
+	}
+	if marked != "" {
+		return marked, share
+	}
+	return detectLatinLanguage(sample.String(), share)
+}
+
+// detectLatinLanguage scores lowercased Latin text against the trigram profiles.
+// The confidence is the winner's share of the two best scores, scaled by share
+func detectLatinLanguage(sample string, share float64) (string, float64) {
+	scores := make(map[string]int, len(languageProfiles))
+	for _, word := range strings.Fields(sample) {
+		padded := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigram := string(padded[i : i+3])
+			for lang, profile := range languageProfiles {
+				scores[lang] += profile[trigram]
+			}
+		}
+	}
+
+	best, bestScore, second := LanguageUndetermined, 0, 0
+	for lang, score := range scores {
+		switch {
+		case score > bestScore || (score == bestScore && score > 0 && lang < best):
+			best, bestScore, second = lang, score, bestScore
+		case score > second:
+			second = score
+		}
+	}
+	if bestScore == 0 {
+		return LanguageUndetermined, 0
+	}
+	return best, share * float64(bestScore) / float64(bestScore+second)
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log subsystems whose level can be changed independently at runtime.
+// LogSubsystemDefault is the standard logger used by everything else
+const (
+	LogSubsystemDefault      = "default"
+	LogSubsystemAccess       = "access"
+	LogSubsystemRouter       = "router"
+	LogSubsystemProviders    = "providers"
+	LogSubsystemBilling      = "billing"
+	LogSubsystemUsageTracker = "usage-tracker"
+	LogSubsystemDB           = "db"
+)
+
+// LogSubsystems lists every subsystem accepted by SetLogLevel
+var LogSubsystems = []string{
+	LogSubsystemDefault,
+	LogSubsystemAccess,
+	LogSubsystemRouter,
+	LogSubsystemProviders,
+	LogSubsystemBilling,
+	LogSubsystemUsageTracker,
+	LogSubsystemDB,
+}
+
+// subsystemLoggers share the standard logger's output, formatter and hooks but
+// keep their own level. The map is built once and never modified, so reads need no lock
+var subsystemLoggers = func() map[string]*logrus.Logger {
+	loggers := make(map[string]*logrus.Logger, len(LogSubsystems))
+	for _, name := range LogSubsystems {
+		if name == LogSubsystemDefault {
+			loggers[name] = logrus.StandardLogger()
+			continue
+		}
+		std := logrus.StandardLogger()
+		logger := logrus.New()
+		logger.Out = std.Out
+		logger.Formatter = std.Formatter
+		logger.Hooks = std.Hooks
+		logger.ExitFunc = std.ExitFunc
+		logger.SetLevel(std.GetLevel())
+		loggers[name] = logger
+	}
+	return loggers
+}()
+
+// SubsystemLogger returns the logger of a subsystem; entries carry a "subsystem"
+// field. The level is checked when logging, so package-level entries follow runtime changes
+func SubsystemLogger(name string) *logrus.Entry {
+	logger, ok := subsystemLoggers[name]
+	if !ok {
+		logger = logrus.StandardLogger()
+	}
+	return logrus.NewEntry(logger).WithField("subsystem", name)
+}
+
+// LogEnabled reports whether a subsystem logs at the given level
+func LogEnabled(name string, level logrus.Level) bool {
+	logger, ok := subsystemLoggers[name]
+	if !ok {
+		logger = logrus.StandardLogger()
+	}
+	return logger.IsLevelEnabled(level)
+}
+
+// SetLogLevel changes the level of one subsystem
+func SetLogLevel(name string, level logrus.Level) error {
+	logger, ok := subsystemLoggers[name]
+	if !ok {
+		return fmt.Errorf("unknown log subsystem %q (valid: %s)", name, strings.Join(LogSubsystems, ", "))
+	}
+	logger.SetLevel(level)
+	return nil
+}
+
+// SetAllLogLevels sets every subsystem, including the default logger, to level
+func SetAllLogLevels(level logrus.Level) {
+	for _, logger := range subsystemLoggers {
+		logger.SetLevel(level)
+	}
+}
+
+// LogLevels returns the current level of each subsystem
+func LogLevels() map[string]string {
+	levels := make(map[string]string, len(subsystemLoggers))
+	for name, logger := range subsystemLoggers {
+		levels[name] = logger.GetLevel().String()
+	}
+	return levels
+}
+
+// ParseLogLevels parses subsystem levels in the form "providers=debug,db=warn"
+func ParseLogLevels(spec string) (map[string]logrus.Level, error) {
+	raw := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log level entry %q, expected subsystem=level", entry)
+		}
+		raw[strings.TrimSpace(name)] = strings.TrimSpace(level)
+	}
+	return ValidateLogLevels(raw)
+}
+
+// ValidateLogLevels converts subsystem -> level names into logrus levels
+func ValidateLogLevels(raw map[string]string) (map[string]logrus.Level, error) {
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	levels := make(map[string]logrus.Level, len(raw))
+	for _, key := range names {
+		name := strings.ToLower(key)
+		if _, ok := subsystemLoggers[name]; !ok {
+			return nil, fmt.Errorf("unknown log subsystem %q (valid: %s)", key, strings.Join(LogSubsystems, ", "))
+		}
+		level, err := logrus.ParseLevel(raw[key])
+		if err != nil {
+			return nil, err
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
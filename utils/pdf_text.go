@@ -0,0 +1,558 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// PDF text extraction errors
+var (
+	ErrPDFInvalid   = errors.New("not a PDF file")
+	ErrPDFEncrypted = errors.New("encrypted PDFs are not supported")
+	ErrPDFNoText    = errors.New("the PDF contains no extractable text (scanned documents need OCR)")
+)
+
+const pdfMaxStreamBytes = 32 << 20 // Decompressed size limit of one stream, against zip bombs
+
+var (
+	pdfStreamStart = regexp.MustCompile(`stream\r?\n`)
+	pdfBlankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// ExtractPDFText returns the text drawn by a PDF's page content streams. It is a best-effort
+// extractor without a full PDF parser: uncompressed and FlateDecode streams are read, text
+// operators are interpreted, and ToUnicode CMaps found in the file are applied to encoded
+// strings. Images, and text drawn as images, are ignored
+func ExtractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return "", ErrPDFInvalid
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", ErrPDFEncrypted
+	}
+
+	var contents [][]byte
+	cmap := pdfCMap{}
+	for _, stream := range pdfStreams(data) {
+		switch {
+		case bytes.Contains(stream, []byte("beginbfchar")) || bytes.Contains(stream, []byte("beginbfrange")):
+			cmap.parse(stream)
+		case bytes.Contains(stream, []byte("BT")) && (bytes.Contains(stream, []byte("Tj")) || bytes.Contains(stream, []byte("TJ"))):
+			contents = append(contents, stream)
+		}
+	}
+
+	var out strings.Builder
+	for _, content := range contents {
+		pdfContentText(content, cmap, &out)
+		out.WriteString("\n")
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	text := strings.TrimSpace(pdfBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+	if text == "" {
+		return "", ErrPDFNoText
+	}
+	return text, nil
+}
+
+// pdfStreams returns the decoded content of every stream the extractor can read
+func pdfStreams(data []byte) [][]byte {
+	var streams [][]byte
+	offset := 0
+	for {
+		loc := pdfStreamStart.FindIndex(data[offset:])
+		if loc == nil {
+			return streams
+		}
+		start := offset + loc[1]
+		dictStart := offset + loc[0]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		raw := data[start : start+end]
+		offset = start + end + len("endstream")
+
+		// The stream dictionary ends right before the keyword
+		dict := data[max(0, dictStart-1024):dictStart]
+		if i := bytes.LastIndex(dict, []byte("obj")); i >= 0 {
+			dict = dict[i:]
+		}
+		if bytes.Contains(dict, []byte("/Image")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/Filter")) {
+			if !bytes.Contains(dict, []byte("/FlateDecode")) || pdfOtherFilter(dict) {
+				continue
+			}
+			decoded, err := pdfInflate(raw)
+			if err != nil {
+				continue
+			}
+			raw = decoded
+		}
+		streams = append(streams, raw)
+	}
+}
+
+// pdfOtherFilter reports whether a stream dictionary names a filter besides FlateDecode
+func pdfOtherFilter(dict []byte) bool {
+	for _, filter := range []string{"/DCTDecode", "/JPXDecode", "/LZWDecode", "/ASCII85Decode", "/ASCIIHexDecode", "/RunLengthDecode", "/CCITTFaxDecode", "/JBIG2Decode"} {
+		if bytes.Contains(dict, []byte(filter)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfInflate decompresses a FlateDecode stream, tolerating trailing garbage
+func pdfInflate(raw []byte) ([]byte, error) {
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+		defer zr.Close()
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(raw))
+	}
+	out, err := io.ReadAll(io.LimitReader(r, pdfMaxStreamBytes))
+	if len(out) > 0 {
+		return out, nil
+	}
+	return nil, err
+}
+
+// pdfCMap maps character codes of a ToUnicode CMap, keyed by code width in bytes
+type pdfCMap map[int]map[uint32]string
+
+var (
+	pdfBfChar  = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]*)>`)
+	pdfBfRange = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>\s*(<[0-9A-Fa-f]*>|\[[^\]]*\])`)
+	pdfHexItem = regexp.MustCompile(`<([0-9A-Fa-f]*)>`)
+)
+
+// parse adds the bfchar and bfrange mappings of a CMap stream
+func (m pdfCMap) parse(stream []byte) {
+	text := string(stream)
+	for _, section := range pdfSections(text, "beginbfchar", "endbfchar") {
+		for _, match := range pdfBfChar.FindAllStringSubmatch(section, -1) {
+			m.set(match[1], pdfDecodeUTF16Hex(match[2]))
+		}
+	}
+	for _, section := range pdfSections(text, "beginbfrange", "endbfrange") {
+		for _, match := range pdfBfRange.FindAllStringSubmatch(section, -1) {
+			lo, err1 := strconv.ParseUint(match[1], 16, 32)
+			hi, err2 := strconv.ParseUint(match[2], 16, 32)
+			if err1 != nil || err2 != nil || hi < lo || hi-lo > 0xFFFF {
+				continue
+			}
+			width := len(match[1]) / 2
+			if strings.HasPrefix(match[3], "[") {
+				for i, item := range pdfHexItem.FindAllStringSubmatch(match[3], -1) {
+					if lo+uint64(i) > hi {
+						break
+					}
+					m.setCode(width, uint32(lo)+uint32(i), pdfDecodeUTF16Hex(item[1]))
+				}
+				continue
+			}
+			base := []rune(pdfDecodeUTF16Hex(strings.Trim(match[3], "<>")))
+			if len(base) == 0 {
+				continue
+			}
+			for code := lo; code <= hi; code++ {
+				r := append([]rune{}, base...)
+				r[len(r)-1] += rune(code - lo)
+				m.setCode(width, uint32(code), string(r))
+			}
+		}
+	}
+}
+
+func (m pdfCMap) set(hexCode, value string) {
+	code, err := strconv.ParseUint(hexCode, 16, 32)
+	if err != nil {
+		return
+	}
+	m.setCode(len(hexCode)/2, uint32(code), value)
+}
+
+func (m pdfCMap) setCode(width int, code uint32, value string) {
+	if width < 1 || width > 4 {
+		return
+	}
+	if m[width] == nil {
+		m[width] = make(map[uint32]string)
+	}
+	m[width][code] = value
+}
+
+// decode maps a string's bytes through the CMap, widest codes first, or returns false when
+// the CMap does not cover them
+func (m pdfCMap) decode(s []byte) (string, bool) {
+	for _, width := range []int{2, 1} {
+		codes := m[width]
+		if len(codes) == 0 || len(s)%width != 0 {
+			continue
+		}
+		var out strings.Builder
+		ok := true
+		for i := 0; i < len(s); i += width {
+			var code uint32
+			for _, b := range s[i : i+width] {
+				code = code<<8 | uint32(b)
+			}
+			value, found := codes[code]
+			if !found {
+				ok = false
+				break
+			}
+			out.WriteString(value)
+		}
+		if ok {
+			return out.String(), true
+		}
+	}
+	return "", false
+}
+
+// pdfSections returns the text between each begin and end keyword pair
+func pdfSections(text, begin, end string) []string {
+	var sections []string
+	for {
+		i := strings.Index(text, begin)
+		if i < 0 {
+			return sections
+		}
+		text = text[i+len(begin):]
+		j := strings.Index(text, end)
+		if j < 0 {
+			return append(sections, text)
+		}
+		sections = append(sections, text[:j])
+		text = text[j+len(end):]
+	}
+}
+
+// pdfDecodeUTF16Hex decodes a CMap destination, big-endian UTF-16 in hex
+func pdfDecodeUTF16Hex(h string) string {
+	b := pdfHexBytes(h)
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfHexBytes decodes a hex string, padding an odd final digit with 0 as PDF does
+func pdfHexBytes(h string) []byte {
+	h = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return r
+		}
+		return -1
+	}, h)
+	if len(h)%2 == 1 {
+		h += "0"
+	}
+	b := make([]byte, len(h)/2)
+	for i := range b {
+		v, _ := strconv.ParseUint(h[2*i:2*i+2], 16, 8)
+		b[i] = byte(v)
+	}
+	return b
+}
+
+// pdfContentText interprets the text operators of a content stream, writing the strings shown
+func pdfContentText(content []byte, cmap pdfCMap, out *strings.Builder) {
+	lex := pdfLexer{data: content}
+	var operands []pdfToken
+	lineY := 0.0
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			return
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch tok.text {
+		case "Tj":
+			if n := len(operands); n > 0 {
+				out.WriteString(pdfShow(operands[n-1], cmap))
+			}
+		case "'", "\"":
+			out.WriteString("\n")
+			if n := len(operands); n > 0 {
+				out.WriteString(pdfShow(operands[n-1], cmap))
+			}
+		case "TJ":
+			if n := len(operands); n > 0 && operands[n-1].kind == pdfArray {
+				for _, item := range operands[n-1].items {
+					if item.kind == pdfNumber {
+						// A large negative adjustment moves far enough to separate words
+						if v, err := strconv.ParseFloat(item.text, 64); err == nil && v < -200 {
+							out.WriteString(" ")
+						}
+						continue
+					}
+					out.WriteString(pdfShow(item, cmap))
+				}
+			}
+		case "Td", "TD":
+			if n := len(operands); n >= 2 {
+				if ty, err := strconv.ParseFloat(operands[n-1].text, 64); err == nil && ty != 0 {
+					out.WriteString("\n")
+				} else {
+					out.WriteString(" ")
+				}
+			}
+		case "Tm":
+			if n := len(operands); n >= 6 {
+				if y, err := strconv.ParseFloat(operands[n-1].text, 64); err == nil {
+					if y != lineY {
+						out.WriteString("\n")
+					} else {
+						out.WriteString(" ")
+					}
+					lineY = y
+				}
+			}
+		case "T*":
+			out.WriteString("\n")
+		case "ET":
+			out.WriteString("\n")
+		}
+		operands = operands[:0]
+	}
+}
+
+// pdfShow decodes a shown string: through the CMap when it covers the codes, otherwise as
+// UTF-16 with a byte order mark, UTF-8 or Latin-1
+func pdfShow(tok pdfToken, cmap pdfCMap) string {
+	if tok.kind != pdfString {
+		return ""
+	}
+	s := tok.bytes
+	if text, ok := cmap.decode(s); ok {
+		return text
+	}
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if utf8.Valid(s) {
+		return string(s)
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// PDF content stream token kinds
+const (
+	pdfOperator = iota
+	pdfNumber
+	pdfString
+	pdfName
+	pdfArray
+	pdfOther
+)
+
+type pdfToken struct {
+	kind  int
+	text  string     // Operator, number or name
+	bytes []byte     // String content
+	items []pdfToken // Array items
+}
+
+// pdfLexer splits a content stream into operands and operators
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case pdfIsSpace(c):
+			l.pos++
+		case c == '(':
+			return pdfToken{kind: pdfString, bytes: l.literal()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.skipDict()
+		case c == '<':
+			end := bytes.IndexByte(l.data[l.pos:], '>')
+			if end < 0 {
+				l.pos = len(l.data)
+				return pdfToken{}, false
+			}
+			h := string(l.data[l.pos+1 : l.pos+end])
+			l.pos += end + 1
+			return pdfToken{kind: pdfString, bytes: pdfHexBytes(h)}, true
+		case c == '[':
+			l.pos++
+			arr := pdfToken{kind: pdfArray}
+			for {
+				item, ok := l.next()
+				if !ok || (item.kind == pdfOther && item.text == "]") {
+					return arr, ok
+				}
+				arr.items = append(arr.items, item)
+			}
+		case c == ']':
+			l.pos++
+			return pdfToken{kind: pdfOther, text: "]"}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.data) && !pdfIsSpace(l.data[l.pos]) && !pdfIsDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			return pdfToken{kind: pdfName, text: string(l.data[start:l.pos])}, true
+		default:
+			start := l.pos
+			for l.pos < len(l.data) && !pdfIsSpace(l.data[l.pos]) && !pdfIsDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			if l.pos == start {
+				l.pos++ // Stray delimiter such as '>' or ')'
+				continue
+			}
+			word := string(l.data[start:l.pos])
+			if word == "BI" {
+				l.skipInlineImage()
+				continue
+			}
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				return pdfToken{kind: pdfNumber, text: word}, true
+			}
+			return pdfToken{kind: pdfOperator, text: word}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// literal reads a parenthesized string with escapes and balanced nested parentheses
+func (l *pdfLexer) literal() []byte {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Line continuation
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// skipDict skips an inline dictionary such as a marked-content property list
+func (l *pdfLexer) skipDict() {
+	depth := 0
+	for l.pos+1 < len(l.data) {
+		switch {
+		case l.data[l.pos] == '<' && l.data[l.pos+1] == '<':
+			depth++
+			l.pos += 2
+		case l.data[l.pos] == '>' && l.data[l.pos+1] == '>':
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+		case l.data[l.pos] == '(':
+			l.literal()
+		default:
+			l.pos++
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// skipInlineImage skips inline image data up to the EI operator
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if pdfIsSpace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' &&
+			(l.pos+3 == len(l.data) || pdfIsSpace(l.data[l.pos+3])) {
+			l.pos += 3
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+func pdfIsSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func pdfIsDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
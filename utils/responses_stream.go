@@ -0,0 +1,328 @@
+package utils
+
+import (
+	"Curry2API-go/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// toolCallTag 工具提示要求模型输出的工具调用起始标签
+const toolCallTag = "<tool_call>"
+
+// NewResponseID 生成 Responses API 的 response ID
+func NewResponseID() string {
+	return "resp_" + GenerateRandomString(32)
+}
+
+// pendingToolCallPrefix 返回 content 末尾可能是 <tool_call> 开头的部分的长度，这部分需暂缓发送
+func pendingToolCallPrefix(content string) int {
+	for n := min(len(toolCallTag)-1, len(content)); n > 0; n-- {
+		if strings.HasSuffix(content, toolCallTag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// responsesFunctionCall 将解析出的工具调用转换为 function_call 输出项
+func responsesFunctionCall(toolUse *models.ClaudeToolUse) models.ResponsesOutputItem {
+	arguments, _ := json.Marshal(toolUse.Input)
+	return models.ResponsesOutputItem{
+		ID:        "fc_" + GenerateRandomString(24),
+		Type:      "function_call",
+		Status:    "completed",
+		CallID:    "call_" + GenerateRandomString(24),
+		Name:      toolUse.Name,
+		Arguments: string(arguments),
+	}
+}
+
+// responsesMessage 创建 assistant message 输出项
+func responsesMessage(id, text, status string) models.ResponsesOutputItem {
+	item := models.ResponsesOutputItem{
+		ID:      id,
+		Type:    "message",
+		Status:  status,
+		Role:    "assistant",
+		Content: []models.ResponsesOutputContent{},
+	}
+	if status == "completed" {
+		item.Content = append(item.Content, models.ResponsesOutputContent{Type: "output_text", Text: text, Annotations: []interface{}{}})
+	}
+	return item
+}
+
+// trackResponsesUsage 调用上下文中的用量统计函数（如已设置）
+func trackResponsesUsage(c *gin.Context, usage *models.Usage, statusCode int, errorMsg string) {
+	if trackFunc, exists := c.Get("track_usage_func"); exists {
+		if fn, ok := trackFunc.(UsageTrackingFunc); ok {
+			fn(c, usage, statusCode, errorMsg)
+		}
+	}
+}
+
+// responsesEventWriter 按 Responses API 规范写入带 sequence_number 的 SSE 事件
+type responsesEventWriter struct {
+	c   *gin.Context
+	seq int
+}
+
+func (w *responsesEventWriter) write(eventType string, fields gin.H) error {
+	fields["type"] = eventType
+	fields["sequence_number"] = w.seq
+	w.seq++
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return WriteSSEEvent(w.c.Writer, eventType, string(data))
+}
+
+// StreamResponse 以 Responses API 的 SSE 事件序列输出生成结果：
+// response.created → response.in_progress → response.output_item.added → response.content_part.added →
+// response.output_text.delta（多次）→ response.output_text.done → response.content_part.done →
+// response.output_item.done → response.completed；出错时以 response.failed 结束
+// 请求带工具时 <tool_call> 块不作为文本输出，而是转换为 function_call 输出项
+func StreamResponse(c *gin.Context, chatGenerator <-chan interface{}, response *models.Response) {
+	c.Header("Content-Type", "text/event-stream; charset=utf-8")
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Encoding", "identity")
+	c.Header("Transfer-Encoding", "chunked")
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	// 空闲时定期发送心跳，避免代理或客户端因长时间没有数据而断开
+	defer BeginSSEKeepAlive(c, SSEPingComment)()
+
+	// 按密钥/服务器配置合并小块输出，流结束时刷新剩余数据
+	defer beginStreamCoalescing(c)()
+
+	events := &responsesEventWriter{c: c}
+	if err := events.write("response.created", gin.H{"response": response}); err != nil {
+		logrus.WithError(err).Error("Failed to write response.created event")
+		return
+	}
+	events.write("response.in_progress", gin.H{"response": response})
+
+	hasToolUse := c.GetBool("has_tool_use")
+	messageID := "msg_" + GenerateRandomString(24)
+	var (
+		usage       models.Usage
+		fullContent strings.Builder // 模型输出的全部文本
+		sent        int             // 已作为 output_text 发送的字节数
+		messageOpen bool
+		inToolCall  bool
+	)
+
+	// sendText 发送一段文本增量，首次发送时先打开 message 输出项与 output_text 内容部分
+	sendText := func(delta string) error {
+		if delta == "" {
+			return nil
+		}
+		if !messageOpen {
+			messageOpen = true
+			events.write("response.output_item.added", gin.H{
+				"output_index": 0,
+				"item":         responsesMessage(messageID, "", "in_progress"),
+			})
+			events.write("response.content_part.added", gin.H{
+				"item_id":       messageID,
+				"output_index":  0,
+				"content_index": 0,
+				"part":          models.ResponsesOutputContent{Type: "output_text", Annotations: []interface{}{}},
+			})
+		}
+		sent += len(delta)
+		return events.write("response.output_text.delta", gin.H{
+			"item_id":       messageID,
+			"output_index":  0,
+			"content_index": 0,
+			"delta":         delta,
+		})
+	}
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Debug("Client disconnected during Responses streaming")
+			trackResponsesUsage(c, nil, 499, "Client disconnected")
+			return
+
+		case data, ok := <-chatGenerator:
+			if !ok {
+				content := fullContent.String()
+				var toolUse *models.ClaudeToolUse
+				if inToolCall {
+					if parsed, _, found := ParseToolCallFromContent(content); found {
+						toolUse = parsed
+					} else {
+						// 未能解析为工具调用，按普通文本补发
+						sendText(content[sent:])
+					}
+				} else if sent < len(content) {
+					sendText(content[sent:])
+				}
+
+				if messageOpen {
+					text := content[:sent]
+					events.write("response.output_text.done", gin.H{
+						"item_id":       messageID,
+						"output_index":  0,
+						"content_index": 0,
+						"text":          text,
+					})
+					events.write("response.content_part.done", gin.H{
+						"item_id":       messageID,
+						"output_index":  0,
+						"content_index": 0,
+						"part":          models.ResponsesOutputContent{Type: "output_text", Text: text, Annotations: []interface{}{}},
+					})
+					item := responsesMessage(messageID, text, "completed")
+					events.write("response.output_item.done", gin.H{"output_index": 0, "item": item})
+					response.Output = append(response.Output, item)
+				}
+
+				if toolUse != nil {
+					call := responsesFunctionCall(toolUse)
+					outputIndex := len(response.Output)
+					added := call
+					added.Status, added.Arguments = "in_progress", ""
+					events.write("response.output_item.added", gin.H{"output_index": outputIndex, "item": added})
+					events.write("response.function_call_arguments.delta", gin.H{
+						"item_id":      call.ID,
+						"output_index": outputIndex,
+						"delta":        call.Arguments,
+					})
+					events.write("response.function_call_arguments.done", gin.H{
+						"item_id":      call.ID,
+						"output_index": outputIndex,
+						"arguments":    call.Arguments,
+					})
+					events.write("response.output_item.done", gin.H{"output_index": outputIndex, "item": call})
+					response.Output = append(response.Output, call)
+				}
+
+				response.Status = "completed"
+				response.Usage = models.NewResponsesUsage(usage)
+				if err := events.write("response.completed", gin.H{"response": response}); err != nil {
+					logrus.WithError(err).Error("Failed to write response.completed event")
+				}
+				trackResponsesUsage(c, &usage, http.StatusOK, "")
+				return
+			}
+
+			switch v := data.(type) {
+			case string:
+				fullContent.WriteString(v)
+				if inToolCall {
+					continue
+				}
+				content := fullContent.String()
+				end := len(content)
+				if hasToolUse {
+					if idx := strings.Index(content, toolCallTag); idx >= 0 {
+						// 工具调用之后的内容不再作为文本发送
+						inToolCall = true
+						end = idx
+					} else {
+						end -= pendingToolCallPrefix(content)
+					}
+				}
+				if end > sent {
+					if err := sendText(content[sent:end]); err != nil {
+						logrus.WithError(err).Error("Failed to write response.output_text.delta event")
+						return
+					}
+				}
+
+			case models.Usage:
+				usage = v
+
+			case error:
+				logrus.WithError(v).Error("Responses stream generator error")
+				response.Status = "failed"
+				response.Error = &models.ResponseError{Code: "server_error", Message: v.Error()}
+				events.write("response.failed", gin.H{"response": response})
+				trackResponsesUsage(c, nil, http.StatusInternalServerError, v.Error())
+				return
+
+			default:
+				logrus.Warnf("Unknown data type in Responses stream: %T", v)
+			}
+		}
+	}
+}
+
+// NonStreamResponse 收集生成结果后返回完整的 response 对象
+func NonStreamResponse(c *gin.Context, chatGenerator <-chan interface{}, response *models.Response) {
+	var fullContent strings.Builder
+	var usage models.Usage
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			c.JSON(http.StatusRequestTimeout, models.NewErrorResponse(
+				"Request timeout",
+				"timeout_error",
+				"request_timeout",
+			))
+			trackResponsesUsage(c, nil, http.StatusRequestTimeout, "Request timeout")
+			return
+
+		case data, ok := <-chatGenerator:
+			if !ok {
+				content := fullContent.String()
+				messageID := "msg_" + GenerateRandomString(24)
+				if c.GetBool("has_tool_use") {
+					if toolUse, beforeText, found := ParseToolCallFromContent(content); found {
+						if beforeText != "" {
+							response.Output = append(response.Output, responsesMessage(messageID, beforeText, "completed"))
+						}
+						response.Output = append(response.Output, responsesFunctionCall(toolUse))
+					}
+				}
+				if len(response.Output) == 0 {
+					response.Output = append(response.Output, responsesMessage(messageID, content, "completed"))
+				}
+
+				response.Status = "completed"
+				response.Usage = models.NewResponsesUsage(usage)
+				trackResponsesUsage(c, &usage, http.StatusOK, "")
+				c.JSON(http.StatusOK, response)
+				return
+			}
+
+			switch v := data.(type) {
+			case string:
+				fullContent.WriteString(v)
+			case models.Usage:
+				usage = v
+			case error:
+				logrus.WithError(v).Error("Responses generator error")
+				c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+					"Internal server error",
+					"stream_error",
+					"",
+				))
+				trackResponsesUsage(c, nil, http.StatusInternalServerError, v.Error())
+				return
+			}
+		}
+	}
+}
+
+// NewResponseForRequest 创建回显请求参数的 response 对象
+func NewResponseForRequest(request *models.ResponsesRequest) *models.Response {
+	return models.NewResponse(NewResponseID(), time.Now().Unix(), request)
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSEPingComment SSE 注释形式的心跳，符合规范的客户端会忽略
+const SSEPingComment = ": ping\n\n"
+
+// claudePingEvent Anthropic 规范的 ping 事件，/v1/messages 的客户端按事件处理
+const claudePingEvent = "event: ping\ndata: {\"type\":\"ping\"}\n\n"
+
+// sseKeepAliveInterval 流式响应空闲超过该时长时发送心跳（纳秒，0 表示禁用）
+var sseKeepAliveInterval atomic.Int64
+
+// ConfigureSSEKeepAlive 设置 SSE 心跳间隔，interval <= 0 表示禁用
+func ConfigureSSEKeepAlive(interval time.Duration) {
+	if interval < 0 {
+		interval = 0
+	}
+	sseKeepAliveInterval.Store(int64(interval))
+}
+
+// keepAliveWriter 记录最后一次写入的时间，空闲超过间隔时由后台协程写入心跳。
+// 心跳只在事件边界（空行）之后写入，不会插入到被拆成多次写入的事件中间
+type keepAliveWriter struct {
+	gin.ResponseWriter
+	mu        sync.Mutex
+	ping      string
+	lastWrite time.Time
+	tail      []byte // 最近写入的末尾字节，用于判断是否位于事件边界
+	stopped   bool
+}
+
+func (w *keepAliveWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.ResponseWriter.Write(data)
+	w.recordLocked(data[:n])
+	return n, err
+}
+
+func (w *keepAliveWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *keepAliveWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ResponseWriter.Flush()
+}
+
+func (w *keepAliveWriter) recordLocked(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	w.lastWrite = time.Now()
+	if len(data) > 4 {
+		data = data[len(data)-4:]
+	}
+	w.tail = append(w.tail, data...)
+	if len(w.tail) > 4 {
+		w.tail = w.tail[len(w.tail)-4:]
+	}
+}
+
+// atBoundaryLocked 已写入的内容是否以空行（\n\n 或 \r\n\r\n）结尾
+func (w *keepAliveWriter) atBoundaryLocked() bool {
+	if len(w.tail) == 0 {
+		return true
+	}
+	tail := string(w.tail)
+	return strings.HasSuffix(tail, "\n\n") || tail == "\r\n\r\n"
+}
+
+// pingIfIdle 空闲超过 interval 且位于事件边界时写入心跳并刷新
+func (w *keepAliveWriter) pingIfIdle(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped || time.Since(w.lastWrite) < interval || !w.atBoundaryLocked() {
+		return
+	}
+	if _, err := w.ResponseWriter.WriteString(w.ping); err != nil {
+		return
+	}
+	w.ResponseWriter.Flush()
+	w.lastWrite = time.Now()
+}
+
+// BeginSSEKeepAlive 在流式响应空闲时定期写入心跳 ping，避免代理或客户端因长时间没有数据而断开。
+// 应在响应头发送之后调用；未配置心跳间隔时不做任何处理，返回的函数在流结束时停止心跳
+func BeginSSEKeepAlive(c *gin.Context, ping string) func() {
+	interval := time.Duration(sseKeepAliveInterval.Load())
+	if interval <= 0 {
+		return func() {}
+	}
+
+	original := c.Writer
+	writer := &keepAliveWriter{ResponseWriter: original, ping: ping, lastWrite: time.Now()}
+	c.Writer = writer
+
+	// 按半个间隔检查，两次输出之间的最长空闲不超过 1.5 个间隔
+	ticker := time.NewTicker(interval / 2)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-c.Request.Context().Done():
+				return
+			case <-ticker.C:
+				writer.pingIfIdle(interval)
+			}
+		}
+	}()
+
+	return func() {
+		// 加锁标记停止，保证返回后后台协程不会再写入响应
+		writer.mu.Lock()
+		writer.stopped = true
+		writer.mu.Unlock()
+		close(done)
+		c.Writer = original
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"Curry2API-go/models"
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coalescingWriter 合并 SSE 输出：Flush 只在缓冲达到字节阈值或距上次刷新超过时间间隔时
+// 真正刷新；未达阈值的尾部数据由定时器在间隔到期后刷新
+type coalescingWriter struct {
+	gin.ResponseWriter
+	mu        sync.Mutex
+	settings  models.StreamFlushSettings
+	buf       bytes.Buffer
+	lastFlush time.Time
+	timer     *time.Timer
+	closed    bool
+}
+
+func (w *coalescingWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buf.Write(data)
+}
+
+func (w *coalescingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *coalescingWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.buf.Len() == 0 {
+		return
+	}
+
+	interval := time.Duration(w.settings.IntervalMs) * time.Millisecond
+	if (w.settings.Bytes > 0 && w.buf.Len() >= w.settings.Bytes) ||
+		(interval > 0 && time.Since(w.lastFlush) >= interval) {
+		w.flushLocked()
+		return
+	}
+
+	if interval > 0 && w.timer == nil {
+		w.timer = time.AfterFunc(interval-time.Since(w.lastFlush), func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.timer = nil
+			if !w.closed && w.buf.Len() > 0 {
+				w.flushLocked()
+			}
+		})
+	}
+}
+
+func (w *coalescingWriter) flushLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	w.ResponseWriter.Flush()
+	w.lastFlush = time.Now()
+}
+
+// close 刷新剩余数据，之后的写入直接透传
+func (w *coalescingWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.flushLocked()
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.closed = true
+}
+
+// beginStreamCoalescing 按上下文中的 stream_flush 策略合并 SSE 输出
+// 未配置合并时不做任何处理（每个事件立即刷新）；返回的函数在流结束时刷新剩余数据
+func beginStreamCoalescing(c *gin.Context) func() {
+	value, exists := c.Get("stream_flush")
+	if !exists {
+		return func() {}
+	}
+	settings, ok := value.(models.StreamFlushSettings)
+	if !ok || (settings.IntervalMs <= 0 && settings.Bytes <= 0) {
+		return func() {}
+	}
+
+	original := c.Writer
+	writer := &coalescingWriter{ResponseWriter: original, settings: settings, lastFlush: time.Now()}
+	c.Writer = writer
+	return func() {
+		writer.close()
+		c.Writer = original
+	}
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"Curry2API-go/models"
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/sirupsen/logrus"
+)
+
+// BPE 词表随二进制内嵌，不在运行时从 OpenAI 下载
+func init() {
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+const (
+	encodingO200K  = "o200k_base"
+	encodingCL100K = "cl100k_base"
+
+	// claudeTokenRatio Claude 的分词器未公开，实测同一文本的 token 数约为 cl100k_base 的 1.1 倍
+	claudeTokenRatio = 1.1
+
+	// 按 OpenAI 的 Chat 格式计算：每条消息额外 3 个 token，回复前缀 3 个 token
+	tokensPerMessage = 3
+	tokensReplyPrime = 3
+
+	// imageTokenEstimate 单张图片按 1024×1024 高精度图计算（OpenAI 765 token，Claude 约 1,400）
+	imageTokenEstimate = 765
+	claudeImageTokens  = 1400
+)
+
+// o200kModelPrefixes 使用 o200k_base 的模型；其余模型（GPT-4/3.5、Gemini、DeepSeek 等）按 cl100k_base 计算
+var o200kModelPrefixes = []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "chatgpt-4o", "o1", "o3", "o4"}
+
+var (
+	encoders   = make(map[string]*tiktoken.Tiktoken)
+	encodersMu sync.Mutex
+)
+
+// getEncoder 按需加载并缓存编码器；加载失败时返回 nil，调用方回退到按字符估算
+func getEncoder(name string) *tiktoken.Tiktoken {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	if enc, ok := encoders[name]; ok {
+		return enc
+	}
+	enc, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		logrus.WithError(err).WithField("encoding", name).Warn("Failed to load tokenizer, falling back to estimates")
+	}
+	encoders[name] = enc
+	return enc
+}
+
+// isClaudeModel 判断模型是否为 Claude 系列（可带 anthropic/ 等提供商前缀）
+func isClaudeModel(model string) bool {
+	return strings.Contains(strings.ToLower(model), "claude")
+}
+
+// encodingForModel 返回模型对应的 tiktoken 编码名，可带 openai/ 等提供商前缀
+func encodingForModel(model string) string {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, prefix := range o200kModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return encodingO200K
+		}
+	}
+	return encodingCL100K
+}
+
+// countRawTokens 返回文本按模型编码的 token 数，不做 Claude 换算
+func countRawTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	enc := getEncoder(encodingForModel(model))
+	if enc == nil {
+		return EstimateTokensFromText(text)
+	}
+	return len(enc.EncodeOrdinary(text))
+}
+
+// scaleForModel 对 Claude 模型按 claudeTokenRatio 换算
+func scaleForModel(model string, tokens int) int {
+	if !isClaudeModel(model) {
+		return tokens
+	}
+	return int(math.Ceil(float64(tokens) * claudeTokenRatio))
+}
+
+// CountTokens 返回文本在指定模型下的 token 数：OpenAI 模型使用 tiktoken 精确计算，
+// Claude 模型按 cl100k_base 换算近似，其他模型按 cl100k_base 计算
+func CountTokens(model, text string) int {
+	return scaleForModel(model, countRawTokens(model, text))
+}
+
+// countContentTokens 计算消息内容中文本的 token 数，并返回多模态内容中的图片数量
+func countContentTokens(model string, content interface{}) (tokens, images int) {
+	switch v := content.(type) {
+	case nil:
+	case string:
+		tokens = countRawTokens(model, v)
+	case []models.ContentPart:
+		for _, part := range v {
+			if part.Type == "text" {
+				tokens += countRawTokens(model, part.Text)
+			} else {
+				images++
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			part, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch part["type"] {
+			case "text":
+				text, _ := part["text"].(string)
+				tokens += countRawTokens(model, text)
+			case "image_url", "image":
+				images++
+			}
+		}
+	default:
+		data, _ := json.Marshal(v)
+		tokens = countRawTokens(model, string(data))
+	}
+	return tokens, images
+}
+
+// CountMessageTokens 按 Chat 格式计算请求的输入 token 数，包括消息开销、工具定义与图片
+func CountMessageTokens(model string, messages []models.Message, tools []models.Tool) int {
+	tokens, images := tokensReplyPrime, 0
+	for _, msg := range messages {
+		contentTokens, contentImages := countContentTokens(model, msg.Content)
+		tokens += tokensPerMessage + countRawTokens(model, msg.Role) + contentTokens
+		images += contentImages
+		for _, call := range msg.ToolCalls {
+			tokens += countRawTokens(model, call.Function.Name) + countRawTokens(model, call.Function.Arguments)
+		}
+	}
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		data, err := json.Marshal(tool.Function)
+		if err != nil {
+			continue
+		}
+		tokens += countRawTokens(model, string(data))
+	}
+
+	perImage := imageTokenEstimate
+	if isClaudeModel(model) {
+		perImage = claudeImageTokens
+	}
+	return scaleForModel(model, tokens) + images*perImage
+}
+
+// CountRequestTokens 计算 Chat Completions 请求的输入 token 数
+func CountRequestTokens(request *models.ChatCompletionRequest) int {
+	return CountMessageTokens(request.Model, request.Messages, request.Tools)
+}
+
+// WithUsageFallback 转发生成器输出；上游未返回用量（或用量为 0）时，
+// 在结束前按请求与已输出文本计算用量，保证计费不因上游缺失 usage 而漏记
+func WithUsageFallback(ctx context.Context, chatGenerator <-chan interface{}, request *models.ChatCompletionRequest) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		send := func(data interface{}) bool {
+			select {
+			case out <- data:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var completion strings.Builder
+		hasUsage, failed := false, false
+		for data := range chatGenerator {
+			switch v := data.(type) {
+			case string:
+				completion.WriteString(v)
+			case models.Usage:
+				if v.PromptTokens == 0 && v.CompletionTokens == 0 {
+					// 空用量由下方的计算结果代替
+					continue
+				}
+				hasUsage = true
+			case error:
+				failed = true
+			}
+			if !send(data) {
+				return
+			}
+		}
+		if hasUsage || failed {
+			return
+		}
+		promptTokens := CountRequestTokens(request)
+		completionTokens := CountTokens(request.Model, completion.String())
+		send(models.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		})
+	}()
+	return out
+}
@@ -103,6 +103,12 @@ func StreamChatCompletion(c *gin.Context, chatGenerator <-chan interface{}) {
 		flusher.Flush()
 	}
 
+	// 空闲时定期发送心跳，避免代理或客户端因长时间没有数据而断开
+	defer BeginSSEKeepAlive(c, SSEPingComment)()
+
+	// 按密钥/服务器配置合并小块输出，流结束时刷新剩余数据
+	defer beginStreamCoalescing(c)()
+
 	// 生成响应ID
 	responseID := GenerateChatCompletionID()
 	